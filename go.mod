@@ -110,6 +110,7 @@ require (
 	k8s.io/kubectl v0.19.1
 	k8s.io/utils v0.0.0-20200821003339-5e75c0163111
 	sigs.k8s.io/controller-runtime v0.6.2
+	sigs.k8s.io/kustomize v2.0.3+incompatible
 	sigs.k8s.io/service-apis v0.0.0-20200916220245-b060b8df63c9
 	sigs.k8s.io/yaml v1.2.0
 )
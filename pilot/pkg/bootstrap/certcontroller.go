@@ -137,8 +137,9 @@ func (s *Server) initDNSCerts(hostname, customHost, namespace string) error {
 	var err error
 	if features.PilotCertProvider.Get() == KubernetesCAProvider {
 		log.Infof("Generating K8S-signed cert for %v", names)
-		certChain, keyPEM, _, err = chiron.GenKeyCertK8sCA(s.kubeClient.CertificatesV1beta1().CertificateSigningRequests(),
-			strings.Join(names, ","), hostnamePrefix+".csr.secret", namespace, defaultCACertPath)
+		certChain, keyPEM, _, err = chiron.GenKeyCertK8sCAWithSigner(
+			s.kubeClient.CertificatesV1beta1().CertificateSigningRequests(), strings.Join(names, ","),
+			hostnamePrefix+".csr.secret", namespace, defaultCACertPath, features.PilotCertSigner.Get())
 
 		s.caBundlePath = defaultCACertPath
 	} else if features.PilotCertProvider.Get() == IstiodCAProvider {
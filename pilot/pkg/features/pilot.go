@@ -268,6 +268,10 @@ var (
 	PilotCertProvider = env.RegisterStringVar("PILOT_CERT_PROVIDER", "istiod",
 		"The provider of Pilot DNS certificate.")
 
+	PilotCertSigner = env.RegisterStringVar("PILOT_CERT_SIGNER", "",
+		"The signer name of the CertificateSigningRequest of the Pilot DNS certificate, when provided by kubernetes. "+
+			"Defaults to none, which Kubernetes sets to kubernetes.io/legacy-unknown.")
+
 	JwtPolicy = env.RegisterStringVar("JWT_POLICY", jwt.PolicyThirdParty,
 		"The JWT validation policy.")
 
@@ -417,8 +417,14 @@ spec:
 	)
 }
 
+// ingressParams are the parameters of the Ingress template of TestIngress.
+type ingressParams struct {
+	Name string `tmpl:"required"`
+	Time string `tmpl:"required"`
+}
+
 func TestIngress(t *testing.T) {
-	cfg := `
+	cfg := tmpl.MustNewTyped(`
 apiVersion: networking.k8s.io/v1beta1
 kind: Ingress
 metadata:
@@ -440,13 +446,13 @@ spec:
   - hosts:
     - example.com
     secretName: ingressgateway-certs
----`
+---`)
 	runGatewayTest(t, gatewayTest{
 		name: "ingress shared TLS cert conflict - beta first",
 		// TODO(https://github.com/istio/istio/issues/24385) this is a bug
 		// "alpha" is created after "beta". This triggers a mismatch in the conflict resolution logic in Ingress and VirtualService, leading to unexpected results
-		kubeConfig: tmpl.MustEvaluate(cfg, map[string]string{"Name": "alpha", "Time": "2020-01-01T00:00:00Z"}) +
-			tmpl.MustEvaluate(cfg, map[string]string{"Name": "beta", "Time": "2010-01-01T00:00:00Z"}),
+		kubeConfig: cfg.MustEvaluate(ingressParams{Name: "alpha", Time: "2020-01-01T00:00:00Z"}) +
+			cfg.MustEvaluate(ingressParams{Name: "beta", Time: "2010-01-01T00:00:00Z"}),
 		calls: []simulation.Expect{
 			{
 				"http alpha",
@@ -508,8 +514,8 @@ spec:
 	}, gatewayTest{
 		name: "ingress shared TLS cert conflict - alpha first",
 		// "alpha" is created before "beta". This avoids the bug in the previous test
-		kubeConfig: tmpl.MustEvaluate(cfg, map[string]string{"Name": "alpha", "Time": "2010-01-01T00:00:00Z"}) +
-			tmpl.MustEvaluate(cfg, map[string]string{"Name": "beta", "Time": "2020-01-01T00:00:00Z"}),
+		kubeConfig: cfg.MustEvaluate(ingressParams{Name: "alpha", Time: "2010-01-01T00:00:00Z"}) +
+			cfg.MustEvaluate(ingressParams{Name: "beta", Time: "2020-01-01T00:00:00Z"}),
 		calls: []simulation.Expect{
 			{
 				"http alpha",
@@ -617,7 +623,14 @@ func createGateway(name, namespace string, servers ...string) string {
 	if namespace == "" {
 		namespace = "default"
 	}
-	return tmpl.MustEvaluate(`apiVersion: networking.istio.io/v1alpha3
+	return gatewayTemplate.MustEvaluate(struct {
+		Name      string
+		Namespace string
+		Servers   []string
+	}{name, namespace, servers})
+}
+
+var gatewayTemplate = tmpl.MustNewTyped(`apiVersion: networking.istio.io/v1alpha3
 kind: Gateway
 metadata:
   name: "{{.Name}}"
@@ -631,9 +644,4 @@ spec:
 {{$p | trim | indent 4}}
 {{- end }}
 ---
-`, struct {
-		Name      string
-		Namespace string
-		Servers   []string
-	}{name, namespace, servers})
-}
+`)
@@ -27,8 +27,7 @@ import (
 	"istio.io/istio/pkg/test/util/tmpl"
 )
 
-const (
-	istioConfTemplate = `
+var istioConfTemplate = tmpl.MustNewTyped(`
 [ req ]
 encrypt_key = no
 prompt = no
@@ -51,15 +50,17 @@ DNS.4 = istio-pilot.{{ .SystemNamespace }}.svc
 [ req_dn ]
 O = Istio
 CN = Intermediate CA
-`
-)
+`)
+
+// istioConfParams are the parameters of istioConfTemplate.
+type istioConfParams struct {
+	SystemNamespace string `tmpl:"required"`
+}
 
 // NewIstioConfig creates an extensions configuration for Istio, using the given system namespace in
 // the DNS SANs.
 func NewIstioConfig(systemNamespace string) (string, error) {
-	return tmpl.Evaluate(istioConfTemplate, map[string]interface{}{
-		"SystemNamespace": systemNamespace,
-	})
+	return istioConfTemplate.Evaluate(istioConfParams{SystemNamespace: systemNamespace})
 }
 
 // IntermediateCA is an intermediate CA for a single cluster.
@@ -0,0 +1,210 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// MalformedKind is a kind of deliberately malformed traffic.
+type MalformedKind string
+
+const (
+	// BadContentLength sends an HTTP/1.1 request with a non-numeric Content-Length.
+	BadContentLength MalformedKind = "bad-content-length"
+	// ConflictingContentLength sends an HTTP/1.1 request with two different Content-Length headers.
+	ConflictingContentLength MalformedKind = "conflicting-content-length"
+	// OversizedHeaders sends an HTTP/1.1 request with headers exceeding the configured size.
+	OversizedHeaders MalformedKind = "oversized-headers"
+	// BadHTTP2Frame sends an HTTP/2 (prior knowledge) HEADERS frame on stream 0, which is a connection error.
+	BadHTTP2Frame MalformedKind = "bad-http2-frame"
+	// InvalidSNI starts a TLS handshake with an SNI that does not match any server, then sends a valid request.
+	InvalidSNI MalformedKind = "invalid-sni"
+)
+
+// MalformedOptions configures SendMalformed.
+type MalformedOptions struct {
+	// Kind of malformed traffic to send. Required.
+	Kind MalformedKind
+	// Address to connect to, in the form host:port. Required.
+	Address string
+	// Host header of the request, or the SNI for InvalidSNI.
+	Host string
+	// HeaderSize is the total size of the headers sent for OversizedHeaders. Defaults to 96KiB, which exceeds the
+	// Envoy default limit of 60KiB.
+	HeaderSize int
+	// Timeout for the entire exchange. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// MalformedResult is the observed response to malformed traffic.
+type MalformedResult struct {
+	// StatusCode of the HTTP response, or 0 if none was received.
+	StatusCode int
+	// GoAway is the error code of the HTTP/2 GOAWAY frame, if one was received.
+	GoAway string
+	// Closed is set if the connection was closed or reset, or the TLS handshake failed, without a response.
+	Closed bool
+	// Err is the error that closed the connection, if any.
+	Err error
+}
+
+// SendMalformed sends the malformed traffic and returns the response. An error is only returned if the traffic
+// could not be sent at all.
+func SendMalformed(opts MalformedOptions) (MalformedResult, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.HeaderSize <= 0 {
+		opts.HeaderSize = 96 * 1024
+	}
+	if opts.Host == "" {
+		opts.Host = opts.Address
+	}
+
+	conn, err := net.DialTimeout("tcp", opts.Address, opts.Timeout)
+	if err != nil {
+		return MalformedResult{}, fmt.Errorf("failed connecting to %s: %v", opts.Address, err)
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(opts.Timeout))
+
+	switch opts.Kind {
+	case BadContentLength:
+		return sendHTTP1(conn, fmt.Sprintf("POST / HTTP/1.1\r\nHost: %s\r\nContent-Length: abc\r\n\r\nbody", opts.Host))
+	case ConflictingContentLength:
+		return sendHTTP1(conn, fmt.Sprintf("POST / HTTP/1.1\r\nHost: %s\r\nContent-Length: 4\r\nContent-Length: 5\r\n\r\nbody",
+			opts.Host))
+	case OversizedHeaders:
+		return sendHTTP1(conn, fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\nX-Oversized: %s\r\n\r\n",
+			opts.Host, strings.Repeat("a", opts.HeaderSize)))
+	case BadHTTP2Frame:
+		return sendBadHTTP2Frame(conn, opts.Host)
+	case InvalidSNI:
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName: opts.Host,
+			// nolint: gosec
+			// Test only code
+			InsecureSkipVerify: true,
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			return MalformedResult{Closed: true, Err: err}, nil
+		}
+		return sendHTTP1(tlsConn, fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\n\r\n", opts.Host))
+	default:
+		return MalformedResult{}, fmt.Errorf("unknown malformed traffic kind %q", opts.Kind)
+	}
+}
+
+func sendHTTP1(conn net.Conn, raw string) (MalformedResult, error) {
+	if _, err := conn.Write([]byte(raw)); err != nil {
+		// The server may close the connection before the whole request is written.
+		return MalformedResult{Closed: true, Err: err}, nil
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return MalformedResult{Closed: true, Err: err}, nil
+	}
+	_ = resp.Body.Close()
+	return MalformedResult{StatusCode: resp.StatusCode}, nil
+}
+
+func sendBadHTTP2Frame(conn net.Conn, host string) (MalformedResult, error) {
+	if _, err := conn.Write([]byte(http2.ClientPreface)); err != nil {
+		return MalformedResult{}, fmt.Errorf("failed writing HTTP/2 preface: %v", err)
+	}
+	framer := http2.NewFramer(conn, conn)
+	framer.AllowIllegalWrites = true
+	if err := framer.WriteSettings(); err != nil {
+		return MalformedResult{}, fmt.Errorf("failed writing HTTP/2 settings: %v", err)
+	}
+
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	for _, f := range []hpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: host},
+		{Name: ":path", Value: "/"},
+	} {
+		_ = enc.WriteField(f)
+	}
+	if err := framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      0,
+		BlockFragment: block.Bytes(),
+		EndStream:     true,
+		EndHeaders:    true,
+	}); err != nil {
+		return MalformedResult{Closed: true, Err: err}, nil
+	}
+
+	for {
+		f, err := framer.ReadFrame()
+		if err != nil {
+			return MalformedResult{Closed: true, Err: err}, nil
+		}
+		if ga, ok := f.(*http2.GoAwayFrame); ok {
+			return MalformedResult{GoAway: ga.ErrCode.String()}, nil
+		}
+	}
+}
+
+// CheckStatus verifies that the malformed request was rejected with the given status code.
+func (r MalformedResult) CheckStatus(code int) error {
+	if r.StatusCode != code {
+		return fmt.Errorf("expected status code %d, got %d (closed=%v, err=%v)", code, r.StatusCode, r.Closed, r.Err)
+	}
+	return nil
+}
+
+// CheckRejected verifies that the malformed traffic was rejected without an HTTP response, either by closing the
+// connection or with an HTTP/2 GOAWAY.
+func (r MalformedResult) CheckRejected() error {
+	if !r.Closed && r.GoAway == "" {
+		return fmt.Errorf("expected connection to be rejected, got status code %d", r.StatusCode)
+	}
+	return nil
+}
+
+// accessLogFlagsRegex matches the response code and flags that follow the request line in the default Envoy access
+// log format used by Istio.
+var accessLogFlagsRegex = regexp.MustCompile(`"[^"]*" (\d+) (\S+) `)
+
+// ResponseFlags counts the response flags (e.g. DPE for downstream protocol errors) in the access logs of a proxy,
+// keyed by flag. Entries without flags ("-") are not counted.
+func ResponseFlags(logs string) map[string]int {
+	out := map[string]int{}
+	for _, line := range strings.Split(logs, "\n") {
+		m := accessLogFlagsRegex.FindStringSubmatch(line)
+		if m == nil || m[2] == "-" {
+			continue
+		}
+		for _, flag := range strings.Split(m[2], ",") {
+			out[flag]++
+		}
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+}
+
+func TestSendMalformedHTTP1(t *testing.T) {
+	srv := httptest.NewUnstartedServer(okHandler())
+	srv.Config.MaxHeaderBytes = 1024
+	srv.Start()
+	defer srv.Close()
+	address := srv.Listener.Addr().String()
+
+	for _, tc := range []struct {
+		kind   MalformedKind
+		status int
+	}{
+		{BadContentLength, http.StatusBadRequest},
+		{ConflictingContentLength, http.StatusBadRequest},
+		{OversizedHeaders, http.StatusRequestHeaderFieldsTooLarge},
+	} {
+		t.Run(string(tc.kind), func(t *testing.T) {
+			result, err := SendMalformed(MalformedOptions{Kind: tc.kind, Address: address})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := result.CheckStatus(tc.status); err != nil {
+				t.Fatal(err)
+			}
+			if err := result.CheckRejected(); err == nil {
+				t.Fatal("expected a response not to count as a rejected connection")
+			}
+		})
+	}
+}
+
+func TestSendMalformedHTTP2(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		(&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Handler: okHandler()})
+	}()
+
+	result, err := SendMalformed(MalformedOptions{Kind: BadHTTP2Frame, Address: l.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.GoAway != http2.ErrCodeProtocol.String() {
+		t.Fatalf("expected a GOAWAY with %v, got %+v", http2.ErrCodeProtocol, result)
+	}
+	if err := result.CheckRejected(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendMalformedInvalidSNI(t *testing.T) {
+	srv := httptest.NewUnstartedServer(okHandler())
+	srv.TLS = &tls.Config{GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello.ServerName != "known.example.com" {
+			return nil, fmt.Errorf("no certificate for %q", hello.ServerName)
+		}
+		return &srv.TLS.Certificates[0], nil
+	}}
+	srv.StartTLS()
+	defer srv.Close()
+	address := srv.Listener.Addr().String()
+
+	result, err := SendMalformed(MalformedOptions{Kind: InvalidSNI, Address: address, Host: "unknown.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.CheckRejected(); err != nil {
+		t.Fatal(err)
+	}
+	// The request is sent once the handshake succeeds.
+	result, err = SendMalformed(MalformedOptions{Kind: InvalidSNI, Address: address, Host: "known.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := result.CheckStatus(http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendMalformedErrors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	_, err = SendMalformed(MalformedOptions{Kind: "unknown", Address: address})
+	if err == nil || !strings.Contains(err.Error(), `unknown malformed traffic kind "unknown"`) {
+		t.Fatalf("expected an error for an unknown kind, got %v", err)
+	}
+	_ = l.Close()
+	if _, err := SendMalformed(MalformedOptions{Kind: BadContentLength, Address: address}); err == nil {
+		t.Fatal("expected an error connecting to a closed port")
+	}
+}
+
+func TestResponseFlags(t *testing.T) {
+	logs := strings.Join([]string{
+		`[2020-10-01T00:00:00.000Z] "POST / HTTP/1.1" 400 DPE http1.codec_error - "-" 0 11 0 - "-" "-" "-" "-" "-" - -`,
+		`[2020-10-01T00:00:01.000Z] "GET / HTTP/1.1" 431 DPE - - "-" 0 31 0 - "-" "-" "-" "-" "-" - -`,
+		`[2020-10-01T00:00:02.000Z] "GET / HTTP/1.1" 200 - - "-" 0 0 1 1 "-" "-" "-" "-" "10.0.0.3:80" outbound|80||a -`,
+		`[2020-10-01T00:00:03.000Z] "- - -" 0 UF,URX - "-" 0 0 1 - "-" "-" "-" "-" "10.0.0.3:80" outbound|80||a -`,
+		`2020-10-01T00:00:04.000000Z	info	Envoy proxy is ready`,
+	}, "\n")
+	want := map[string]int{"DPE": 2, "UF": 1, "URX": 1}
+	if got := ResponseFlags(logs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
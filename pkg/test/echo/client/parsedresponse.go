@@ -15,14 +15,18 @@
 package client
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/echo/common/response"
 	"istio.io/istio/pkg/test/echo/proto"
 	"istio.io/istio/pkg/test/framework/resource"
@@ -38,8 +42,24 @@ var (
 	responseHeaderFieldRegex = regexp.MustCompile(string(response.ResponseHeader) + "=(.*)")
 	URLFieldRegex            = regexp.MustCompile(string(response.URLField) + "=(.*)")
 	ClusterFieldRegex        = regexp.MustCompile(string(response.ClusterField) + "=(.*)")
+	LocalityFieldRegex       = regexp.MustCompile(string(response.LocalityField) + "=(.*)")
+	EventFieldRegex          = regexp.MustCompile(string(response.EventField) + "=(.*):([0-9]+)")
+	closedAfterFieldRegex    = regexp.MustCompile(string(response.ClosedAfterField) + "=([0-9]+)")
+	tlsVersionFieldRegex     = regexp.MustCompile(`\] ` + string(response.TLSVersionField) + "=(.*)")
+	cipherSuiteFieldRegex    = regexp.MustCompile(`\] ` + string(response.CipherSuiteField) + "=(.*)")
+	alpnFieldRegex           = regexp.MustCompile(`\] ` + string(response.ALPNField) + "=(.*)")
+	tlsResumedFieldRegex     = regexp.MustCompile(`\] ` + string(response.TLSResumedField) + "=(true|false)")
+	sentTraceIDFieldRegex    = regexp.MustCompile(`\] ` + string(response.SentTraceIDField) + "=(.*)")
 )
 
+// Event is a server-sent event received by the client.
+type Event struct {
+	// ID of the event.
+	ID string
+	// Received is the time the event was received, relative to the start of the request.
+	Received time.Duration
+}
+
 // ParsedResponse represents a response to a single echo request.
 type ParsedResponse struct {
 	// Body is the body of the response
@@ -60,10 +80,39 @@ type ParsedResponse struct {
 	Hostname string
 	// The cluster where the server is deployed.
 	Cluster string
+	// The locality where the server is deployed.
+	Locality string
+	// Events are the server-sent events received, if the response was an event stream.
+	Events []Event
+	// ClosedAfter is how long the connection was idle before being closed by its peer, if the call kept it idle and
+	// it was closed.
+	ClosedAfter time.Duration
+	// TLSVersion is the TLS version negotiated by the client, such as TLSV1_3, if it used TLS.
+	TLSVersion string
+	// CipherSuite is the cipher suite negotiated by the client, if it used TLS.
+	CipherSuite string
+	// ALPN is the application protocol negotiated by the client, if any.
+	ALPN string
+	// TLSResumed is whether the TLS connection of the client resumed a previous session.
+	TLSResumed bool
+	// ForwardedClientCert are the elements of the X-Forwarded-Client-Cert header received by the server, if any.
+	ForwardedClientCert []ForwardedClientCert
+	// ForwardedClientCertErr is the error parsing the X-Forwarded-Client-Cert header received by the server, if any.
+	ForwardedClientCertErr error
+	// SentTraceID is the ID of the trace the client started, if the call sent trace context.
+	SentTraceID string
+	// PeerMetadata is the metadata of the source workload observed by the proxy of the server, if exposed.
+	PeerMetadata *PeerMetadata
 	// RawResponse gives a map of all values returned in the response (headers, etc)
 	RawResponse map[string]string
 }
 
+// ReceivedTraceID returns the ID of the trace the server received in the trace context format, or empty if it
+// received none.
+func (r *ParsedResponse) ReceivedTraceID(f common.TraceFormat) string {
+	return f.TraceID(r.RawResponse[f.Header()])
+}
+
 // IsOK indicates whether or not the code indicates a successful request.
 func (r *ParsedResponse) IsOK() bool {
 	return r.Code == response.StatusCodeOK
@@ -85,6 +134,7 @@ func (r *ParsedResponse) String() string {
 	out += fmt.Sprintf("Host:     %s\n", r.Host)
 	out += fmt.Sprintf("Hostname: %s\n", r.Hostname)
 	out += fmt.Sprintf("Cluster:  %s\n", r.Cluster)
+	out += fmt.Sprintf("Locality: %s\n", r.Locality)
 
 	return out
 }
@@ -135,6 +185,146 @@ func (r ParsedResponses) CheckOKOrFail(t test.Failer) ParsedResponses {
 	return r
 }
 
+func (r ParsedResponses) CheckCode(expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.Code != expected {
+			return fmt.Errorf("response[%d] Status Code: expected %s, received %s", i, expected, response.Code)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckCodeOrFail(t test.Failer, expected string) ParsedResponses {
+	t.Helper()
+	if err := r.CheckCode(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckRequestBodySize verifies that the server received a request body of the expected number of bytes.
+func (r ParsedResponses) CheckRequestBodySize(expected int) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		received, ok := resp.RawResponse[string(response.RequestBodySizeField)]
+		if !ok {
+			return fmt.Errorf("response[%d] does not report the request body size", i)
+		}
+		if received != strconv.Itoa(expected) {
+			return fmt.Errorf("response[%d] request body size: expected %d, received %s", i, expected, received)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckRequestBodySizeOrFail(t test.Failer, expected int) ParsedResponses {
+	t.Helper()
+	if err := r.CheckRequestBodySize(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckRequestBody verifies that the server received the expected request body intact, after decoding any
+// Content-Encoding.
+func (r ParsedResponses) CheckRequestBody(expected string) error {
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(expected)))
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if received := resp.RawResponse[string(response.RequestBodyHashField)]; received != hash {
+			return fmt.Errorf("response[%d] request body hash: expected %s, received %q", i, hash, received)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckRequestBodyOrFail(t test.Failer, expected string) ParsedResponses {
+	t.Helper()
+	if err := r.CheckRequestBody(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckContentEncoding verifies that the responses were received with the expected Content-Encoding. An empty
+// encoding expects the responses to be uncompressed.
+func (r ParsedResponses) CheckContentEncoding(expected string) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if received := resp.RawResponse["Content-Encoding"]; received != expected {
+			return fmt.Errorf("response[%d] Content-Encoding: expected %q, received %q", i, expected, received)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckContentEncodingOrFail(t test.Failer, expected string) ParsedResponses {
+	t.Helper()
+	if err := r.CheckContentEncoding(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// AddressFamily is the IP address family of a connection.
+type AddressFamily string
+
+const (
+	IPv4 AddressFamily = "IPv4"
+	IPv6 AddressFamily = "IPv6"
+)
+
+// AddressFamilyOf returns the family of the address, in the form ip or ip:port, or an empty family if it is not an
+// IP address.
+func AddressFamilyOf(addr string) AddressFamily {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return IPv4
+	default:
+		return IPv6
+	}
+}
+
+// AddressFamily returns the family of the address the client connected to. For a dual-stack destination, this is the
+// family picked by the client, or by its proxy if it has one. If the destination has a sidecar, its inbound proxy
+// connects to the application over loopback whatever the family it received, so the family is only known if the
+// sidecar reports the original destination, as done by originaldst.Expose. Otherwise, it is empty.
+func (r *ParsedResponse) AddressFamily() AddressFamily {
+	return AddressFamilyOf(r.RawResponse[string(response.DestinationAddrField)])
+}
+
+// AddressFamilies counts the responses by the address family of the connection to the server.
+func (r ParsedResponses) AddressFamilies() map[AddressFamily]int {
+	out := map[AddressFamily]int{}
+	for _, resp := range r {
+		out[resp.AddressFamily()]++
+	}
+	return out
+}
+
+// CheckAddressFamily verifies that every request reached the server over the expected address family.
+func (r ParsedResponses) CheckAddressFamily(expected AddressFamily) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if received := resp.AddressFamily(); received != expected {
+			return fmt.Errorf("response[%d] address family: expected %s, received %q (destination address %q, "+
+				"local address %q)", i, expected, received, resp.RawResponse[string(response.DestinationAddrField)],
+				resp.RawResponse[string(response.LocalAddrField)])
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckAddressFamilyOrFail(t test.Failer, expected AddressFamily) ParsedResponses {
+	t.Helper()
+	if err := r.CheckAddressFamily(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
 func (r ParsedResponses) CheckHost(expected string) error {
 	return r.Check(func(i int, response *ParsedResponse) error {
 		if response.Host != expected {
@@ -170,6 +360,54 @@ func (r ParsedResponses) CheckPortOrFail(t test.Failer, expected int) ParsedResp
 	return r
 }
 
+// CheckSticky returns an error if the responses did not all come from the same endpoint, as expected for
+// requests within a stateful session or with a consistent hash key.
+func (r ParsedResponses) CheckSticky() error {
+	hits := map[string]int{}
+	for _, rr := range r {
+		hits[rr.Hostname]++
+	}
+	if len(hits) > 1 {
+		return fmt.Errorf("requests were not sticky to a single endpoint: %v", hits)
+	}
+	return nil
+}
+
+func (r ParsedResponses) CheckStickyOrFail(t test.Failer) ParsedResponses {
+	t.Helper()
+	if err := r.CheckSticky(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// LocalityDistribution returns the number of responses from each locality.
+func (r ParsedResponses) LocalityDistribution() map[string]int {
+	hits := map[string]int{}
+	for _, rr := range r {
+		hits[rr.Locality]++
+	}
+	return hits
+}
+
+// CheckLocality returns an error if any of the responses came from a locality other than the expected one.
+func (r ParsedResponses) CheckLocality(expected string) error {
+	return r.Check(func(i int, response *ParsedResponse) error {
+		if response.Locality != expected {
+			return fmt.Errorf("response[%d] Locality: expected %s, received %s", i, expected, response.Locality)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckLocalityOrFail(t test.Failer, expected string) ParsedResponses {
+	t.Helper()
+	if err := r.CheckLocality(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
 func (r ParsedResponses) clusterDistribution() map[string]int {
 	hits := map[string]int{}
 	for _, rr := range r {
@@ -239,6 +477,204 @@ func (r ParsedResponses) CheckClusterOrFail(t test.Failer, expected string) Pars
 	return r
 }
 
+// StreamDuration returns the time from the start of the request until the last server-sent event was received.
+func (r *ParsedResponse) StreamDuration() time.Duration {
+	if len(r.Events) == 0 {
+		return 0
+	}
+	return r.Events[len(r.Events)-1].Received
+}
+
+// CheckClosedAfter verifies that each idle connection was closed by its peer between min and max after the response
+// was received, such as by the idle timeout of a proxy.
+func (r ParsedResponses) CheckClosedAfter(min, max time.Duration) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if resp.ClosedAfter == 0 {
+			return fmt.Errorf("response[%d] connection was not closed while idle, expected closed between %v and %v",
+				i, min, max)
+		}
+		if resp.ClosedAfter < min || resp.ClosedAfter > max {
+			return fmt.Errorf("response[%d] connection closed after %v idle, expected between %v and %v",
+				i, resp.ClosedAfter, min, max)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckClosedAfterOrFail(t test.Failer, min, max time.Duration) ParsedResponses {
+	t.Helper()
+	if err := r.CheckClosedAfter(min, max); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckNotClosed verifies that no idle connection was closed by its peer while the call kept it idle.
+func (r ParsedResponses) CheckNotClosed() error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if resp.ClosedAfter != 0 {
+			return fmt.Errorf("response[%d] connection unexpectedly closed after %v idle", i, resp.ClosedAfter)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckNotClosedOrFail(t test.Failer) ParsedResponses {
+	t.Helper()
+	if err := r.CheckNotClosed(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckTLSVersion verifies that each response was received over a connection that negotiated the TLS version, such
+// as TLSV1_2.
+func (r ParsedResponses) CheckTLSVersion(expected string) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if resp.TLSVersion != expected {
+			return fmt.Errorf("response[%d] TLS version %q, expected %q", i, resp.TLSVersion, expected)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckTLSVersionOrFail(t test.Failer, expected string) ParsedResponses {
+	t.Helper()
+	if err := r.CheckTLSVersion(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckCipherSuite verifies that each response was received over a connection that negotiated the cipher suite, by
+// its OpenSSL name, such as ECDHE-RSA-AES128-GCM-SHA256.
+func (r ParsedResponses) CheckCipherSuite(expected string) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if resp.CipherSuite != expected {
+			return fmt.Errorf("response[%d] cipher suite %q, expected %q", i, resp.CipherSuite, expected)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckCipherSuiteOrFail(t test.Failer, expected string) ParsedResponses {
+	t.Helper()
+	if err := r.CheckCipherSuite(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckALPN verifies that each response was received over a connection that negotiated the application protocol,
+// or none if empty.
+func (r ParsedResponses) CheckALPN(expected string) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if resp.ALPN != expected {
+			return fmt.Errorf("response[%d] ALPN %q, expected %q", i, resp.ALPN, expected)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckALPNOrFail(t test.Failer, expected string) ParsedResponses {
+	t.Helper()
+	if err := r.CheckALPN(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckTLSResumed verifies that the responses were received over TLS connections that resumed a session, or that
+// none did if expected is false. As connections opened concurrently with the first handshake have no session to
+// resume, only one is required to have resumed.
+func (r ParsedResponses) CheckTLSResumed(expected bool) error {
+	resumed := false
+	if err := r.Check(func(i int, resp *ParsedResponse) error {
+		if resp.TLSVersion == "" {
+			return fmt.Errorf("response[%d] was not received over TLS", i)
+		}
+		if resp.TLSResumed && !expected {
+			return fmt.Errorf("response[%d] unexpectedly resumed a TLS session", i)
+		}
+		resumed = resumed || resp.TLSResumed
+		return nil
+	}); err != nil {
+		return err
+	}
+	if expected && !resumed {
+		return fmt.Errorf("none of %d responses resumed a TLS session", r.Len())
+	}
+	return nil
+}
+
+func (r ParsedResponses) CheckTLSResumedOrFail(t test.Failer, expected bool) ParsedResponses {
+	t.Helper()
+	if err := r.CheckTLSResumed(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckEvents verifies that each response is an event stream of the expected number of events.
+func (r ParsedResponses) CheckEvents(expected int) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if len(resp.Events) != expected {
+			return fmt.Errorf("response[%d] events: expected %d, received %d", i, expected, len(resp.Events))
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckEventsOrFail(t test.Failer, expected int) ParsedResponses {
+	t.Helper()
+	if err := r.CheckEvents(expected); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckEventIntervals verifies that the time between consecutive events of each stream is between min and max.
+// Events arriving together, rather than at the interval they were sent, indicate that the stream was buffered.
+func (r ParsedResponses) CheckEventIntervals(min, max time.Duration) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		for j := 1; j < len(resp.Events); j++ {
+			gap := resp.Events[j].Received - resp.Events[j-1].Received
+			if gap < min || gap > max {
+				return fmt.Errorf("response[%d] event %s received %v after the previous event, expected between %v and %v",
+					i, resp.Events[j].ID, gap, min, max)
+			}
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckEventIntervalsOrFail(t test.Failer, min, max time.Duration) ParsedResponses {
+	t.Helper()
+	if err := r.CheckEventIntervals(min, max); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckStreamDuration verifies that the last event of each stream was received between min and max after the start
+// of the request.
+func (r ParsedResponses) CheckStreamDuration(min, max time.Duration) error {
+	return r.Check(func(i int, resp *ParsedResponse) error {
+		if d := resp.StreamDuration(); d < min || d > max {
+			return fmt.Errorf("response[%d] stream lasted %v, expected between %v and %v", i, d, min, max)
+		}
+		return nil
+	})
+}
+
+func (r ParsedResponses) CheckStreamDurationOrFail(t test.Failer, min, max time.Duration) ParsedResponses {
+	t.Helper()
+	if err := r.CheckStreamDuration(min, max); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
 // Count occurrences of the given text within the bodies of all responses.
 func (r ParsedResponses) Count(text string) int {
 	count := 0
@@ -320,11 +756,53 @@ func parseResponse(output string) *ParsedResponse {
 		out.Cluster = match[1]
 	}
 
+	match = LocalityFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.Locality = match[1]
+	}
+
+	for _, m := range EventFieldRegex.FindAllStringSubmatch(output, -1) {
+		ms, _ := strconv.Atoi(m[2])
+		out.Events = append(out.Events, Event{ID: m[1], Received: time.Duration(ms) * time.Millisecond})
+	}
+
+	match = closedAfterFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		ms, _ := strconv.Atoi(match[1])
+		out.ClosedAfter = time.Duration(ms) * time.Millisecond
+	}
+
+	match = tlsVersionFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.TLSVersion = match[1]
+	}
+
+	match = cipherSuiteFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.CipherSuite = match[1]
+	}
+
+	match = alpnFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.ALPN = match[1]
+	}
+
+	match = tlsResumedFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.TLSResumed = match[1] == "true"
+	}
+
+	match = sentTraceIDFieldRegex.FindStringSubmatch(output)
+	if match != nil {
+		out.SentTraceID = match[1]
+	}
+
 	out.RawResponse = map[string]string{}
 
 	matches := responseHeaderFieldRegex.FindAllStringSubmatch(output, -1)
 	for _, kv := range matches {
-		sl := strings.Split(kv[1], ":")
+		// Split on the first colon only, since header values (e.g. cookie expiry times) may contain colons.
+		sl := strings.SplitN(kv[1], ":", 2)
 		if len(sl) != 2 {
 			continue
 		}
@@ -343,5 +821,16 @@ func parseResponse(output string) *ParsedResponse {
 		out.RawResponse[kv[0]] = kv[1]
 	}
 
+	if xfcc, ok := out.RawResponse[string(response.ForwardedClientCertField)]; ok {
+		out.ForwardedClientCert, out.ForwardedClientCertErr = ParseForwardedClientCert(xfcc)
+	}
+
+	// Malformed peer metadata is left unparsed.
+	if md, ok := out.RawResponse[string(response.PeerMetadataField)]; ok {
+		out.PeerMetadata, _ = ParsePeerMetadata(md)
+	} else if baggage, ok := out.RawResponse[string(response.BaggageField)]; ok {
+		out.PeerMetadata, _ = ParseBaggage(baggage)
+	}
+
 	return &out
 }
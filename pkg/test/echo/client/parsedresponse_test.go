@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocality(t *testing.T) {
+	resp := ParsedResponses{
+		parseResponse("[1 body] Locality=region.zone.subzone\n"),
+		parseResponse("[1 body] Locality=region.zone2.subzone\n"),
+		parseResponse("[1 body] Locality=region.zone.subzone\n"),
+	}
+	want := map[string]int{"region.zone.subzone": 2, "region.zone2.subzone": 1}
+	if got := resp.LocalityDistribution(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got distribution %v, want %v", got, want)
+	}
+	if err := resp[:1].CheckLocality("region.zone.subzone"); err != nil {
+		t.Fatal(err)
+	}
+	err := resp.CheckLocality("region.zone.subzone")
+	if err == nil || !strings.Contains(err.Error(), "response[1] Locality: expected region.zone.subzone, received region.zone2.subzone") {
+		t.Fatalf("expected the response from another locality to be reported, got %v", err)
+	}
+	if l := parseResponse("[1 body] Cluster=cluster-0\n").Locality; l != "" {
+		t.Fatalf("got locality %q for a response without one", l)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	// Events as recorded by the forwarder, with an ID containing colons and one without an ID.
+	r := parseResponse("[1] Event=1:100\n[1] Event=urn:a:250\n[1] Event=:400\n")
+	want := []Event{
+		{ID: "1", Received: 100 * time.Millisecond},
+		{ID: "urn:a", Received: 250 * time.Millisecond},
+		{ID: "", Received: 400 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(r.Events, want) {
+		t.Fatalf("got events %+v, want %+v", r.Events, want)
+	}
+	if d := r.StreamDuration(); d != 400*time.Millisecond {
+		t.Fatalf("got stream duration %v, want 400ms", d)
+	}
+	resp := ParsedResponses{r}
+	if err := resp.CheckEvents(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.CheckEvents(2); err == nil {
+		t.Fatal("expected the number of events to be checked")
+	}
+	if err := resp.CheckEventIntervals(100*time.Millisecond, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := resp.CheckEventIntervals(0, 100*time.Millisecond); err == nil {
+		t.Fatal("expected the events received 150ms apart to be reported")
+	}
+
+	empty := parseResponse("[1 body] StatusCode=200\n")
+	if len(empty.Events) != 0 || empty.StreamDuration() != 0 {
+		t.Fatalf("got events %+v for a response without any", empty.Events)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	pstruct "github.com/golang/protobuf/ptypes/struct"
+)
+
+// PeerMetadata is the metadata of the source workload of a request, as observed by the proxy of the server through
+// metadata exchange.
+type PeerMetadata struct {
+	// Name of the source pod.
+	Name string
+	// Namespace of the source workload.
+	Namespace string
+	// WorkloadName is the name of the source workload, such as its deployment.
+	WorkloadName string
+	// ServiceAccount of the source workload.
+	ServiceAccount string
+	// ClusterID is the cluster of the source workload.
+	ClusterID string
+	// Labels of the source workload.
+	Labels map[string]string
+}
+
+// ParsePeerMetadata parses the peer metadata exchanged between sidecars, the base64 encoded google.protobuf.Struct
+// of the x-envoy-peer-metadata header.
+func ParsePeerMetadata(header string) (*PeerMetadata, error) {
+	b, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer metadata encoding: %v", err)
+	}
+	s := &pstruct.Struct{}
+	if err := proto.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("invalid peer metadata: %v", err)
+	}
+	str := func(key string) string {
+		return s.GetFields()[key].GetStringValue()
+	}
+	md := &PeerMetadata{
+		Name:           str("NAME"),
+		Namespace:      str("NAMESPACE"),
+		WorkloadName:   str("WORKLOAD_NAME"),
+		ServiceAccount: str("SERVICE_ACCOUNT"),
+		ClusterID:      str("CLUSTER_ID"),
+		Labels:         map[string]string{},
+	}
+	for k, v := range s.GetFields()["LABELS"].GetStructValue().GetFields() {
+		md.Labels[k] = v.GetStringValue()
+	}
+	return md, nil
+}
+
+// ParseBaggage parses the peer metadata carried by the baggage header, as propagated over HBONE by ambient proxies
+// rather than exchanged between sidecars. The app and version labels are those of the canonical service.
+func ParseBaggage(header string) (*PeerMetadata, error) {
+	md := &PeerMetadata{Labels: map[string]string{}}
+	for _, member := range strings.Split(header, ",") {
+		// Properties of a member, following a semicolon, are ignored.
+		kv := strings.SplitN(strings.SplitN(member, ";", 2)[0], "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid baggage member %q", member)
+		}
+		key := strings.TrimSpace(kv[0])
+		value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid baggage member %q: %v", member, err)
+		}
+		switch {
+		case key == "k8s.namespace.name":
+			md.Namespace = value
+		case key == "k8s.pod.name":
+			md.Name = value
+		case key == "k8s.cluster.name":
+			md.ClusterID = value
+		case key == "service.name":
+			md.Labels["app"] = value
+		case key == "service.version":
+			md.Labels["version"] = value
+		case strings.HasPrefix(key, "k8s.") && strings.HasSuffix(key, ".name"):
+			// The workload, named by its kind, such as k8s.deployment.name.
+			md.WorkloadName = value
+		}
+	}
+	return md, nil
+}
@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pstruct "github.com/golang/protobuf/ptypes/struct"
+)
+
+func stringValue(s string) *pstruct.Value {
+	return &pstruct.Value{Kind: &pstruct.Value_StringValue{StringValue: s}}
+}
+
+func TestParsePeerMetadata(t *testing.T) {
+	// The metadata as encoded by the metadata exchange filter of a sidecar.
+	b, err := proto.Marshal(&pstruct.Struct{Fields: map[string]*pstruct.Value{
+		"NAME":            stringValue("a-v1-5d9c7b8f6-abcde"),
+		"NAMESPACE":       stringValue("echo"),
+		"WORKLOAD_NAME":   stringValue("a-v1"),
+		"SERVICE_ACCOUNT": stringValue("a"),
+		"CLUSTER_ID":      stringValue("cluster-0"),
+		"MESH_ID":         stringValue("mesh"),
+		"LABELS": {Kind: &pstruct.Value_StructValue{StructValue: &pstruct.Struct{Fields: map[string]*pstruct.Value{
+			"app":     stringValue("a"),
+			"version": stringValue("v1"),
+		}}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParsePeerMetadata(base64.StdEncoding.EncodeToString(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PeerMetadata{
+		Name:           "a-v1-5d9c7b8f6-abcde",
+		Namespace:      "echo",
+		WorkloadName:   "a-v1",
+		ServiceAccount: "a",
+		ClusterID:      "cluster-0",
+		Labels:         map[string]string{"app": "a", "version": "v1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		header string
+		err    string
+	}{
+		{"not base64", "not base64!", "invalid peer metadata encoding"},
+		{"not a struct", base64.StdEncoding.EncodeToString([]byte{0xff, 0xff, 0xff}), "invalid peer metadata"},
+	} {
+		if _, err := ParsePeerMetadata(tc.header); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected error %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
+
+func TestParseBaggage(t *testing.T) {
+	got, err := ParseBaggage("k8s.namespace.name=echo, k8s.pod.name=a-v1-abcde,k8s.cluster.name=cluster-0," +
+		"k8s.deployment.name=a-v1,service.name=a;p=1,service.version=v%201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PeerMetadata{
+		Name:         "a-v1-abcde",
+		Namespace:    "echo",
+		WorkloadName: "a-v1",
+		ClusterID:    "cluster-0",
+		Labels:       map[string]string{"app": "a", "version": "v 1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for _, header := range []string{"k8s.pod.name", "k8s.pod.name=%zz"} {
+		if _, err := ParseBaggage(header); err == nil || !strings.Contains(err.Error(), "invalid baggage member") {
+			t.Fatalf("expected an error for %q, got %v", header, err)
+		}
+	}
+}
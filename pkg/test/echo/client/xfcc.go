@@ -0,0 +1,140 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ForwardedClientCert is an element of the X-Forwarded-Client-Cert header, describing the client certificate seen
+// by one proxy, as configured by its forwardClientCertDetails.
+type ForwardedClientCert struct {
+	// By is the URI SAN of the certificate of the proxy.
+	By string
+	// Hash is the hex encoded SHA-256 hash of the DER encoded client certificate.
+	Hash string
+	// Cert is the PEM encoded client certificate.
+	Cert string
+	// Chain is the PEM encoded client certificate chain, including the client certificate.
+	Chain string
+	// Subject is the subject of the client certificate.
+	Subject string
+	// URI are the URI SANs of the client certificate.
+	URI []string
+	// DNS are the DNS SANs of the client certificate.
+	DNS []string
+}
+
+// ParseForwardedClientCert parses the value of an X-Forwarded-Client-Cert header into its elements, one for each
+// proxy that appended to it, in order.
+func ParseForwardedClientCert(header string) ([]ForwardedClientCert, error) {
+	var out []ForwardedClientCert
+	for _, element := range splitUnquoted(header, ',') {
+		if strings.TrimSpace(element) == "" {
+			continue
+		}
+		var c ForwardedClientCert
+		for _, pair := range splitUnquoted(element, ';') {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid X-Forwarded-Client-Cert pair %q", pair)
+			}
+			value := unquote(kv[1])
+			switch kv[0] {
+			case "By":
+				c.By = value
+			case "Hash":
+				c.Hash = value
+			case "Cert", "Chain":
+				// Certificates are URL encoded.
+				decoded, err := url.PathUnescape(value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid X-Forwarded-Client-Cert %s: %v", kv[0], err)
+				}
+				if kv[0] == "Cert" {
+					c.Cert = decoded
+				} else {
+					c.Chain = decoded
+				}
+			case "Subject":
+				c.Subject = value
+			case "URI":
+				c.URI = append(c.URI, value)
+			case "DNS":
+				c.DNS = append(c.DNS, value)
+			default:
+				return nil, fmt.Errorf("unknown X-Forwarded-Client-Cert key %q", kv[0])
+			}
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// CertHash returns the hash of a PEM encoded certificate, as set in the Hash of X-Forwarded-Client-Cert.
+func CertHash(certPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return "", fmt.Errorf("no PEM encoded certificate found")
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(block.Bytes)), nil
+}
+
+// ChainLength returns the number of certificates of the chain.
+func (c ForwardedClientCert) ChainLength() int {
+	n := 0
+	rest := []byte(c.Chain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return n
+		}
+		n++
+	}
+}
+
+// splitUnquoted splits s on sep, other than within double quoted values.
+func splitUnquoted(s string, sep byte) []string {
+	var out []string
+	quoted, escaped := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\' && quoted:
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
+
+// unquote removes the double quotes around a value, and the escaping of the quotes within it.
+func unquote(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		return strings.Replace(v[1:len(v)-1], `\"`, `"`, -1)
+	}
+	return v
+}
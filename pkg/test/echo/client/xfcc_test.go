@@ -0,0 +1,142 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func certPEM(der string) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte(der)}))
+}
+
+func TestParseForwardedClientCert(t *testing.T) {
+	cert := certPEM("a")
+	chain := cert + certPEM("b")
+	cases := []struct {
+		name   string
+		header string
+		want   []ForwardedClientCert
+		// err is a substring of the expected error, or empty if the header is valid.
+		err string
+	}{
+		{name: "empty", header: ""},
+		{
+			name:   "single element",
+			header: `By=spiffe://cluster.local/ns/b/sa/b;Hash=abc;URI=spiffe://cluster.local/ns/a/sa/a`,
+			want: []ForwardedClientCert{{
+				By:   "spiffe://cluster.local/ns/b/sa/b",
+				Hash: "abc",
+				URI:  []string{"spiffe://cluster.local/ns/a/sa/a"},
+			}},
+		},
+		{
+			name:   "elements in order",
+			header: `By=spiffe://c;URI=spiffe://b, By=spiffe://b;URI=spiffe://a`,
+			want: []ForwardedClientCert{
+				{By: "spiffe://c", URI: []string{"spiffe://b"}},
+				{By: "spiffe://b", URI: []string{"spiffe://a"}},
+			},
+		},
+		{
+			name:   "quoted values with separators",
+			header: `Subject="CN=a,O=b;c";By=spiffe://b`,
+			want:   []ForwardedClientCert{{Subject: "CN=a,O=b;c", By: "spiffe://b"}},
+		},
+		{
+			name:   "escaped quotes",
+			header: `Subject="CN=\"a\""`,
+			want:   []ForwardedClientCert{{Subject: `CN="a"`}},
+		},
+		{
+			name:   "URL encoded certificates",
+			header: "Cert=" + url.PathEscape(cert) + ";Chain=" + url.PathEscape(chain),
+			want:   []ForwardedClientCert{{Cert: cert, Chain: chain}},
+		},
+		{
+			name:   "repeated SANs",
+			header: `URI=spiffe://a;URI=spiffe://b;DNS=a.example.com;DNS=b.example.com`,
+			want: []ForwardedClientCert{{
+				URI: []string{"spiffe://a", "spiffe://b"},
+				DNS: []string{"a.example.com", "b.example.com"},
+			}},
+		},
+		{name: "missing value", header: `By=spiffe://b;Hash`, err: `invalid X-Forwarded-Client-Cert pair "Hash"`},
+		{name: "unknown key", header: `By=spiffe://b;Other=x`, err: `unknown X-Forwarded-Client-Cert key "Other"`},
+		{name: "invalid encoding", header: `Cert=%zz`, err: "invalid X-Forwarded-Client-Cert Cert"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseForwardedClientCert(tc.header)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestCertHash(t *testing.T) {
+	got, err := CertHash(certPEM("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The SHA-256 hash of "a".
+	if want := "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"; got != want {
+		t.Fatalf("got hash %s, want %s", got, want)
+	}
+	if _, err := CertHash("not a certificate"); err == nil {
+		t.Fatal("expected an error hashing a value without a certificate")
+	}
+}
+
+func TestChainLength(t *testing.T) {
+	for _, tc := range []struct {
+		chain string
+		want  int
+	}{
+		{"", 0},
+		{certPEM("a"), 1},
+		{certPEM("a") + certPEM("b") + certPEM("c"), 3},
+	} {
+		if got := (ForwardedClientCert{Chain: tc.chain}).ChainLength(); got != tc.want {
+			t.Fatalf("got chain length %d, want %d", got, tc.want)
+		}
+	}
+}
+
+func TestParseResponseForwardedClientCert(t *testing.T) {
+	r := parseResponse("[1 body] X-Forwarded-Client-Cert=By=spiffe://b;URI=spiffe://a\n")
+	if r.ForwardedClientCertErr != nil || len(r.ForwardedClientCert) != 1 || r.ForwardedClientCert[0].By != "spiffe://b" {
+		t.Fatalf("unexpected X-Forwarded-Client-Cert %+v: %v", r.ForwardedClientCert, r.ForwardedClientCertErr)
+	}
+	r = parseResponse("[1 body] X-Forwarded-Client-Cert=By\n")
+	if r.ForwardedClientCertErr == nil {
+		t.Fatal("expected the malformed header to be reported")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -39,8 +40,11 @@ var (
 	uds              string
 	version          string
 	cluster          string
+	locality         string
 	crt              string
 	key              string
+	tcpKeepAlive     time.Duration
+	tcpLinger        int
 
 	loggingOptions = log.DefaultOptions()
 
@@ -99,7 +103,12 @@ var (
 				TLSKey:    key,
 				Version:   version,
 				Cluster:   cluster,
+				Locality:  locality,
 				UDSServer: uds,
+				Socket: &common.SocketOptions{
+					KeepAlive: tcpKeepAlive,
+					Linger:    tcpLinger,
+				},
 			})
 
 			if err := s.Start(); err != nil {
@@ -135,8 +144,13 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&uds, "uds", "", "HTTP server on unix domain socket")
 	rootCmd.PersistentFlags().StringVar(&version, "version", "", "Version string")
 	rootCmd.PersistentFlags().StringVar(&cluster, "cluster", "", "Cluster where this server is deployed")
+	rootCmd.PersistentFlags().StringVar(&locality, "locality", "", "Locality (region/zone/subzone) where this server is deployed")
 	rootCmd.PersistentFlags().StringVar(&crt, "crt", "", "gRPC TLS server-side certificate")
 	rootCmd.PersistentFlags().StringVar(&key, "key", "", "gRPC TLS server-side key")
+	rootCmd.PersistentFlags().DurationVar(&tcpKeepAlive, "tcp-keepalive", 0,
+		"Period of TCP keepalive probes of connections accepted on TCP ports. Zero keeps the default, negative disables them.")
+	rootCmd.PersistentFlags().IntVar(&tcpLinger, "tcp-linger", -1,
+		"Linger in seconds of connections accepted on TCP ports. Negative keeps the default, zero resets on close.")
 
 	loggingOptions.AttachCobraFlags(rootCmd)
 
@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Content encodings supported by the echo client and server. Others, such as br, are rejected, as there is no encoder
+// for them in the standard library. As in HTTP, deflate is the zlib format of RFC 1950, not raw DEFLATE data.
+const (
+	GzipEncoding    = "gzip"
+	DeflateEncoding = "deflate"
+)
+
+// ValidateEncoding returns an error if the content encoding is not supported by Compress and Decompress.
+func ValidateEncoding(encoding string) error {
+	switch encoding {
+	case "", "identity", GzipEncoding, DeflateEncoding:
+		return nil
+	default:
+		return fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// Compress encodes the data with the given content encoding. An empty encoding returns the data unchanged.
+func Compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "", "identity":
+		return data, nil
+	case GzipEncoding:
+		w = gzip.NewWriter(&buf)
+	case DeflateEncoding:
+		zw, err := zlib.NewWriterLevel(&buf, zlib.DefaultCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, ValidateEncoding(encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress reads all of r, decoding it with the given content encoding. An empty encoding reads r unchanged.
+// Unsupported encodings are an error, rather than being read unchanged, so that content that cannot be checked
+// against what was sent, such as a br response of a proxy, fails the call.
+func Decompress(encoding string, r io.Reader) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+		return ioutil.ReadAll(r)
+	case GzipEncoding:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip content: %v", err)
+		}
+		defer func() { _ = gr.Close() }()
+		return ioutil.ReadAll(gr)
+	case DeflateEncoding:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deflate content: %v", err)
+		}
+		defer func() { _ = zr.Close() }()
+		return ioutil.ReadAll(zr)
+	default:
+		return nil, ValidateEncoding(encoding)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("HelloWorld\n", 100))
+	for _, encoding := range []string{"", "identity", GzipEncoding, DeflateEncoding} {
+		t.Run(encoding, func(t *testing.T) {
+			compressed, err := Compress(encoding, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoding == GzipEncoding || encoding == DeflateEncoding {
+				if len(compressed) >= len(data) {
+					t.Fatalf("expected %s to compress %d bytes, got %d", encoding, len(data), len(compressed))
+				}
+			} else if !bytes.Equal(compressed, data) {
+				t.Fatalf("expected %q to leave the data unchanged", encoding)
+			}
+			got, err := Decompress(encoding, bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("got %q after the round trip, want %q", got, data)
+			}
+		})
+	}
+}
+
+// TestHTTPFraming verifies that the content is framed as HTTP clients and servers expect for each encoding, by reading
+// and writing it with the decoders and encoders of the standard library rather than Compress and Decompress.
+func TestHTTPFraming(t *testing.T) {
+	data := []byte(strings.Repeat("HelloWorld\n", 100))
+	for _, encoding := range []string{GzipEncoding, DeflateEncoding} {
+		t.Run(encoding, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				out, err := Compress(encoding, data)
+				if err != nil {
+					t.Error(err)
+				}
+				w.Header().Set("Content-Encoding", encoding)
+				_, _ = w.Write(out)
+			}))
+			defer s.Close()
+			req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept-Encoding", encoding)
+			resp, err := s.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = resp.Body.Close() }()
+			if got := resp.Header.Get("Content-Encoding"); got != encoding {
+				t.Fatalf("got Content-Encoding %q, want %q", got, encoding)
+			}
+			var got []byte
+			if encoding == GzipEncoding {
+				gr, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err = ioutil.ReadAll(gr)
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				// The deflate content coding is zlib framed (RFC 9110, section 8.4.1.2).
+				zr, err := zlib.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("expected zlib framed content: %v", err)
+				}
+				got, err = ioutil.ReadAll(zr)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("got %q, want %q", got, data)
+			}
+		})
+	}
+
+	// The transport of net/http transparently decodes gzip responses to requests it adds Accept-Encoding to.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, err := Compress(GzipEncoding, data)
+		if err != nil {
+			t.Error(err)
+		}
+		w.Header().Set("Content-Encoding", GzipEncoding)
+		_, _ = w.Write(out)
+	}))
+	defer s.Close()
+	resp, err := s.Client().Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	got, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Uncompressed || !bytes.Equal(got, data) {
+		t.Fatalf("expected net/http to decode the gzip content, got %q", got)
+	}
+}
+
+func TestDecompressStandardEncoders(t *testing.T) {
+	data := []byte(strings.Repeat("HelloWorld\n", 100))
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	zw := zlib.NewWriter(&zl)
+	for _, w := range []io.WriteCloser{gw, zw} {
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for encoding, content := range map[string][]byte{GzipEncoding: gz.Bytes(), DeflateEncoding: zl.Bytes()} {
+		got, err := Decompress(encoding, bytes.NewReader(content))
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: got %q, want %q", encoding, got, data)
+		}
+	}
+}
+
+func TestUnsupportedEncoding(t *testing.T) {
+	for _, encoding := range []string{"", "identity", GzipEncoding, DeflateEncoding} {
+		if err := ValidateEncoding(encoding); err != nil {
+			t.Fatalf("expected %q to be supported: %v", encoding, err)
+		}
+	}
+	if err := ValidateEncoding("br"); err == nil {
+		t.Fatal("expected br to be unsupported")
+	}
+	if _, err := Compress("br", []byte("HelloWorld")); err == nil {
+		t.Fatal("expected compressing with br to fail")
+	}
+	if _, err := Decompress("br", strings.NewReader("HelloWorld")); err == nil {
+		t.Fatal("expected decompressing br to fail")
+	}
+	if _, err := Decompress(GzipEncoding, strings.NewReader("HelloWorld")); err == nil {
+		t.Fatal("expected decompressing invalid gzip content to fail")
+	}
+	// Raw DEFLATE data lacks the zlib header that HTTP requires.
+	if _, err := Decompress(DeflateEncoding, strings.NewReader("\x4b\x4c\x4a\x06\x00")); err == nil {
+		t.Fatal("expected decompressing raw DEFLATE content to fail")
+	}
+}
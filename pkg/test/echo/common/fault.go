@@ -0,0 +1,156 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// FaultDelayHeader holds an HTTP request on the server for the given duration before its response headers are
+	// written.
+	FaultDelayHeader = "X-Echo-Fault-Delay"
+
+	// FaultChunkSizeHeader writes the response body in flushed writes of at most the given number of bytes.
+	FaultChunkSizeHeader = "X-Echo-Fault-Chunk-Size"
+
+	// FaultChunkDelayHeader waits for the given duration between the writes of the response body.
+	FaultChunkDelayHeader = "X-Echo-Fault-Chunk-Delay"
+
+	// FaultCloseHeader closes the connection mid-body, either "truncate" or "reset".
+	FaultCloseHeader = "X-Echo-Fault-Close"
+
+	// FaultCloseAfterHeader is the number of bytes of the response body written before the connection is closed.
+	FaultCloseAfterHeader = "X-Echo-Fault-Close-After"
+
+	// FaultMalformedChunkedHeader, if "true", ends the response body with an invalid chunk.
+	FaultMalformedChunkedHeader = "X-Echo-Fault-Malformed-Chunked"
+)
+
+// CloseMode is how the server closes the connection of a response mid-body.
+type CloseMode string
+
+const (
+	// Truncate closes the connection gracefully, without terminating the body.
+	Truncate CloseMode = "truncate"
+
+	// Reset closes the connection with a TCP RST.
+	Reset CloseMode = "reset"
+)
+
+// Fault is the misbehavior of the server in responding to an HTTP request, to test how clients and proxies handle a
+// slow or faulty upstream. The zero value responds normally.
+//
+// The body of a faulty response is sent with chunked transfer encoding. HTTP/2 has neither connection closes scoped to
+// a request nor chunked encoding, so for HTTP/2 requests Close and MalformedChunked instead abort the stream with a
+// RST_STREAM.
+type Fault struct {
+	// Delay holds the request for the given duration before the response headers are written.
+	Delay time.Duration
+
+	// ChunkSize, if set, writes the body in flushed writes of at most the given number of bytes.
+	ChunkSize int
+
+	// ChunkDelay waits for the given duration between the writes of the body.
+	ChunkDelay time.Duration
+
+	// Close, if set, closes the connection once CloseAfter bytes of the body are written.
+	Close      CloseMode
+	CloseAfter int
+
+	// MalformedChunked writes an invalid chunk after the body, or after CloseAfter bytes of it if Close is set, and
+	// closes the connection.
+	MalformedChunked bool
+}
+
+// IsSet returns true if any fault is set.
+func (f Fault) IsSet() bool {
+	return f != Fault{}
+}
+
+// Headers returns the headers requesting the fault from the server.
+func (f Fault) Headers() http.Header {
+	h := http.Header{}
+	if f.Delay > 0 {
+		h.Set(FaultDelayHeader, f.Delay.String())
+	}
+	if f.ChunkSize > 0 {
+		h.Set(FaultChunkSizeHeader, strconv.Itoa(f.ChunkSize))
+	}
+	if f.ChunkDelay > 0 {
+		h.Set(FaultChunkDelayHeader, f.ChunkDelay.String())
+	}
+	if f.Close != "" {
+		h.Set(FaultCloseHeader, string(f.Close))
+		h.Set(FaultCloseAfterHeader, strconv.Itoa(f.CloseAfter))
+	}
+	if f.MalformedChunked {
+		h.Set(FaultMalformedChunkedHeader, "true")
+	}
+	return h
+}
+
+// Validate returns an error if the fault can't be applied.
+func (f Fault) Validate() error {
+	if f.Delay < 0 || f.ChunkDelay < 0 {
+		return fmt.Errorf("invalid fault: negative delay")
+	}
+	if f.ChunkSize < 0 || f.CloseAfter < 0 {
+		return fmt.Errorf("invalid fault: negative size")
+	}
+	switch f.Close {
+	case "", Truncate, Reset:
+	default:
+		return fmt.Errorf("invalid fault: unknown close mode %q", f.Close)
+	}
+	return nil
+}
+
+// GetFault returns the fault requested by the headers of an HTTP request.
+func GetFault(headers http.Header) (Fault, error) {
+	f := Fault{
+		Close: CloseMode(headers.Get(FaultCloseHeader)),
+	}
+	var err error
+	if v := headers.Get(FaultDelayHeader); v != "" {
+		if f.Delay, err = time.ParseDuration(v); err != nil {
+			return f, fmt.Errorf("invalid %s %q: %v", FaultDelayHeader, v, err)
+		}
+	}
+	if v := headers.Get(FaultChunkSizeHeader); v != "" {
+		if f.ChunkSize, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid %s %q: %v", FaultChunkSizeHeader, v, err)
+		}
+	}
+	if v := headers.Get(FaultChunkDelayHeader); v != "" {
+		if f.ChunkDelay, err = time.ParseDuration(v); err != nil {
+			return f, fmt.Errorf("invalid %s %q: %v", FaultChunkDelayHeader, v, err)
+		}
+	}
+	if v := headers.Get(FaultCloseAfterHeader); v != "" {
+		if f.CloseAfter, err = strconv.Atoi(v); err != nil {
+			return f, fmt.Errorf("invalid %s %q: %v", FaultCloseAfterHeader, v, err)
+		}
+	}
+	if v := headers.Get(FaultMalformedChunkedHeader); v != "" {
+		if f.MalformedChunked, err = strconv.ParseBool(v); err != nil {
+			return f, fmt.Errorf("invalid %s %q: %v", FaultMalformedChunkedHeader, v, err)
+		}
+	}
+	return f, f.Validate()
+}
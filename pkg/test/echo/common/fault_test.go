@@ -0,0 +1,80 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetFault(t *testing.T) {
+	cases := []struct {
+		name  string
+		fault Fault
+	}{
+		{"none", Fault{}},
+		{"delay", Fault{Delay: time.Second}},
+		{"chunks", Fault{ChunkSize: 10, ChunkDelay: 100 * time.Millisecond}},
+		{"truncate", Fault{Close: Truncate, CloseAfter: 5}},
+		{"reset", Fault{Close: Reset}},
+		{"malformed chunked", Fault{MalformedChunked: true, ChunkSize: 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := GetFault(tc.fault.Headers())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.fault {
+				t.Fatalf("got %+v, want %+v", got, tc.fault)
+			}
+			if got.IsSet() != (tc.fault != Fault{}) {
+				t.Fatalf("got IsSet %v for %+v", got.IsSet(), got)
+			}
+		})
+	}
+}
+
+func TestGetFaultInvalid(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers map[string]string
+		err     string
+	}{
+		{"delay", map[string]string{FaultDelayHeader: "1"}, "invalid " + FaultDelayHeader},
+		{"negative delay", map[string]string{FaultDelayHeader: "-1s"}, "negative delay"},
+		{"chunk size", map[string]string{FaultChunkSizeHeader: "x"}, "invalid " + FaultChunkSizeHeader},
+		{"negative chunk size", map[string]string{FaultChunkSizeHeader: "-1"}, "negative size"},
+		{"chunk delay", map[string]string{FaultChunkDelayHeader: "x"}, "invalid " + FaultChunkDelayHeader},
+		{"close mode", map[string]string{FaultCloseHeader: "abort"}, `unknown close mode "abort"`},
+		{"close after", map[string]string{FaultCloseHeader: "reset", FaultCloseAfterHeader: "x"},
+			"invalid " + FaultCloseAfterHeader},
+		{"malformed chunked", map[string]string{FaultMalformedChunkedHeader: "x"},
+			"invalid " + FaultMalformedChunkedHeader},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			if _, err := GetFault(h); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
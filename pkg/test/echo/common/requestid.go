@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, which Envoy generates if it is not set.
+const RequestIDHeader = "X-Request-Id"
+
+// requestIDSpread is added to the leading 32 bits of a UUID request ID for each request of a call. Envoy makes its
+// random trace sampling decision from the leading 32 bits, so consecutive requests must not have consecutive values.
+const requestIDSpread = 2654435761
+
+// RequestIDForIndex returns the ID of the request of the index within a call with the given ID, so that each request
+// of the call has its own. UUIDs, such as those generated by the framework and by Envoy, remain UUIDs, with the index
+// spread over their leading 32 bits, so that Envoy still packs the trace reason into them and samples each request of
+// the call independently. Other IDs are suffixed with the index. The first request of a call keeps the ID of the call.
+func RequestIDForIndex(id string, index int) string {
+	if index == 0 {
+		return id
+	}
+	if IsUUID(id) {
+		lead, _ := strconv.ParseUint(id[:8], 16, 32)
+		return fmt.Sprintf("%08x", uint32(lead+uint64(index)*requestIDSpread)) + id[8:]
+	}
+	return id + "-" + strconv.Itoa(index)
+}
+
+// IsUUID returns true if the ID is in the textual form of a UUID, the only form Envoy packs trace reasons into.
+func IsUUID(id string) bool {
+	if len(id) != 36 {
+		return false
+	}
+	for i, c := range id {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestRequestIDForIndex(t *testing.T) {
+	const id = "0000000a-bbbb-4ccc-8ddd-eeeeeeeeeeee"
+	cases := []struct {
+		name  string
+		id    string
+		index int
+		want  string
+	}{
+		{"first uuid", id, 0, id},
+		{"second uuid", id, 1, "9e3779bb-bbbb-4ccc-8ddd-eeeeeeeeeeee"},
+		{"wrapping uuid", "ffffffff-bbbb-4ccc-8ddd-eeeeeeeeeeee", 1, "9e3779b0-bbbb-4ccc-8ddd-eeeeeeeeeeee"},
+		{"first other", "req", 0, "req"},
+		{"second other", "req", 1, "req-1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RequestIDForIndex(tc.id, tc.index)
+			if got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+			if IsUUID(tc.id) && !IsUUID(got) {
+				t.Fatalf("%q is no longer a UUID", got)
+			}
+		})
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		lead := RequestIDForIndex(id, i)[:8]
+		if seen[lead] {
+			t.Fatalf("request %d has the leading bits %s of an earlier request", i, lead)
+		}
+		seen[lead] = true
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	cases := []struct {
+		id   string
+		want bool
+	}{
+		{"0000000a-bbbb-4ccc-8ddd-eeeeeeeeeeee", true},
+		{"0000000A-BBBB-4CCC-8DDD-EEEEEEEEEEEE", true},
+		{"0000000a-bbbb-4ccc-8ddd-eeeeeeeeeee", false},
+		{"0000000a_bbbb-4ccc-8ddd-eeeeeeeeeeee", false},
+		{"0000000g-bbbb-4ccc-8ddd-eeeeeeeeeeee", false},
+		{"req-1", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if got := IsUUID(tc.id); got != tc.want {
+			t.Errorf("IsUUID(%q) = %v, want %v", tc.id, got, tc.want)
+		}
+	}
+}
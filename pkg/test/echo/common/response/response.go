@@ -28,6 +28,10 @@ var (
 	StatusCodeTooManyRequests = strconv.Itoa(http.StatusTooManyRequests)
 )
 
+// OriginalDstHeader carries the address the client connected to, as set by the proxy of the server, since the server
+// only sees the loopback connection of its sidecar.
+const OriginalDstHeader = "X-Envoy-Original-Dst-Host"
+
 // Field is a list of fields returned in responses from the Echo server.
 type Field string
 
@@ -42,4 +46,42 @@ const (
 	MethodField         Field = "Method"
 	ResponseHeader      Field = "ResponseHeader"
 	ClusterField        Field = "Cluster"
+	LocalityField       Field = "Locality"
+
+	// RequestBodySizeField is the number of bytes of the request body received by the server, after decoding any
+	// Content-Encoding.
+	RequestBodySizeField Field = "RequestBodySize"
+	// EventField is a server-sent event received by the client, in the form <id>:<milliseconds since request>.
+	EventField Field = "Event"
+	// LocalAddrField is the address on which the server accepted the connection. With a sidecar, this is the loopback
+	// address the inbound proxy connects from, whatever the address the client connected to.
+	LocalAddrField Field = "LocalAddr"
+	// DestinationAddrField is the address the client connected to. It is the OriginalDstHeader set by the proxy of the
+	// server if any, such as with originaldst.Expose, and the local address otherwise, unless it is a loopback address.
+	// For dual-stack destinations, its address family is the one picked by the client or its proxy.
+	DestinationAddrField Field = "DestinationAddr"
+	// RequestBodyHashField is the hex encoded SHA-256 hash of the request body received by the server.
+	RequestBodyHashField Field = "RequestBodyHash"
+	// ClosedAfterField is the number of milliseconds an idle connection was kept open by the client before being
+	// closed by its peer.
+	ClosedAfterField Field = "ClosedAfter"
+	// TLSVersionField is the TLS version negotiated by the client, such as TLSV1_3.
+	TLSVersionField Field = "TLSVersion"
+	// CipherSuiteField is the cipher suite negotiated by the client, by its OpenSSL name if it has one.
+	CipherSuiteField Field = "CipherSuite"
+	// ALPNField is the application protocol negotiated by the client, if any.
+	ALPNField Field = "ALPN"
+	// TLSResumedField is whether the TLS connection of the client resumed a previous session.
+	TLSResumedField Field = "TLSResumed"
+	// ForwardedClientCertField is the X-Forwarded-Client-Cert header received by the server, describing the client
+	// certificates seen by the proxies the request went through.
+	ForwardedClientCertField Field = "X-Forwarded-Client-Cert"
+	// PeerMetadataField is the metadata of the source workload exchanged between sidecars, copied by the proxy of the
+	// server before it is removed, as done by peermetadata.Expose.
+	PeerMetadataField Field = "X-Echo-Peer-Metadata"
+	// BaggageField is the baggage header received by the server, carrying the metadata of the source workload in
+	// ambient mode.
+	BaggageField Field = "Baggage"
+	// SentTraceIDField is the ID of the trace started by the client, sent in the trace context formats of the call.
+	SentTraceIDField Field = "SentTraceId"
 )
@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// KeepAliveHeader sets the period of the TCP keepalive probes of the connections of a TCP call, such as "30s", or
+	// disables them if negative. It is read by the forwarder rather than sent.
+	KeepAliveHeader = "X-Echo-Keepalive"
+
+	// LingerHeader sets the linger of the connections of a TCP call, in seconds, as with net.TCPConn.SetLinger. It is
+	// read by the forwarder rather than sent.
+	LingerHeader = "X-Echo-Linger"
+
+	// IdleHeader, if set, keeps each connection of a TCP call open and idle for up to the given duration after the
+	// response is received, recording when it is closed by the peer, such as by the idle timeout of a proxy. It is
+	// read by the forwarder rather than sent.
+	IdleHeader = "X-Echo-Idle"
+)
+
+// SocketOptions are options of TCP connections.
+type SocketOptions struct {
+	// KeepAlive is the period of TCP keepalive probes. Zero keeps the default of Go, which sends them every 15s, and
+	// negative disables them.
+	KeepAlive time.Duration
+
+	// Linger is passed to net.TCPConn.SetLinger: negative, the default of Go, sends unsent data in the background
+	// after close, zero discards it and resets the connection, and positive sends it for up to the given seconds.
+	Linger int
+}
+
+// DefaultSocketOptions keeps the defaults of Go.
+var DefaultSocketOptions = SocketOptions{Linger: -1}
+
+// Apply the options to the connection. Connections other than TCP, such as TLS, are left unchanged.
+func (o SocketOptions) Apply(conn net.Conn) error {
+	c, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if o.KeepAlive < 0 {
+		if err := c.SetKeepAlive(false); err != nil {
+			return err
+		}
+	} else if o.KeepAlive > 0 {
+		if err := c.SetKeepAlive(true); err != nil {
+			return err
+		}
+		if err := c.SetKeepAlivePeriod(o.KeepAlive); err != nil {
+			return err
+		}
+	}
+	if o.Linger >= 0 {
+		return c.SetLinger(o.Linger)
+	}
+	return nil
+}
+
+// Headers returns the headers setting the options of the connections of a TCP call.
+func (o SocketOptions) Headers() http.Header {
+	h := http.Header{}
+	if o.KeepAlive != 0 {
+		h.Set(KeepAliveHeader, o.KeepAlive.String())
+	}
+	if o.Linger >= 0 {
+		h.Set(LingerHeader, strconv.Itoa(o.Linger))
+	}
+	return h
+}
+
+// GetSocketOptions returns the options set by the headers of a TCP call.
+func GetSocketOptions(headers http.Header) (SocketOptions, error) {
+	o := DefaultSocketOptions
+	if v := headers.Get(KeepAliveHeader); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return o, fmt.Errorf("invalid %s %q: %v", KeepAliveHeader, v, err)
+		}
+		o.KeepAlive = d
+	}
+	if v := headers.Get(LingerHeader); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil {
+			return o, fmt.Errorf("invalid %s %q: %v", LingerHeader, v, err)
+		}
+		o.Linger = l
+	}
+	return o, nil
+}
+
+// GetIdle returns the duration connections of a TCP call are kept idle for, or zero if they are closed once the
+// response is received.
+func GetIdle(headers http.Header) (time.Duration, error) {
+	v := headers.Get(IdleHeader)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %v", IdleHeader, v, err)
+	}
+	return d, nil
+}
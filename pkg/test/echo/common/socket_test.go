@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetSocketOptions(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    SocketOptions
+		// err is a substring of the expected error, or empty if the headers are valid.
+		err string
+	}{
+		{name: "none", want: DefaultSocketOptions},
+		{name: "empty values", headers: map[string]string{KeepAliveHeader: "", LingerHeader: ""}, want: DefaultSocketOptions},
+		{
+			name:    "keepalive and linger",
+			headers: map[string]string{KeepAliveHeader: "30s", LingerHeader: "0"},
+			want:    SocketOptions{KeepAlive: 30 * time.Second, Linger: 0},
+		},
+		{name: "keepalive disabled", headers: map[string]string{KeepAliveHeader: "-1s"}, want: SocketOptions{KeepAlive: -time.Second, Linger: -1}},
+		{name: "keepalive without unit", headers: map[string]string{KeepAliveHeader: "30"}, err: "invalid " + KeepAliveHeader},
+		{name: "malformed keepalive", headers: map[string]string{KeepAliveHeader: "soon"}, err: "invalid " + KeepAliveHeader},
+		{name: "fractional linger", headers: map[string]string{LingerHeader: "1.5"}, err: "invalid " + LingerHeader},
+		{name: "malformed linger", headers: map[string]string{LingerHeader: "5s"}, err: "invalid " + LingerHeader},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			got, err := GetSocketOptions(h)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			// The options round trip through the headers of a call.
+			if rt, err := GetSocketOptions(got.Headers()); err != nil || rt != got {
+				t.Fatalf("got %+v after a round trip through the headers, want %+v: %v", rt, got, err)
+			}
+		})
+	}
+}
+
+func TestGetIdle(t *testing.T) {
+	for v, want := range map[string]time.Duration{"": 0, "2s": 2 * time.Second, "100ms": 100 * time.Millisecond} {
+		h := http.Header{}
+		h.Set(IdleHeader, v)
+		got, err := GetIdle(h)
+		if err != nil || got != want {
+			t.Fatalf("got %v for %q, want %v: %v", got, v, want, err)
+		}
+	}
+	for _, v := range []string{"2", "idle"} {
+		h := http.Header{}
+		h.Set(IdleHeader, v)
+		if _, err := GetIdle(h); err == nil || !strings.Contains(err.Error(), "invalid "+IdleHeader) {
+			t.Fatalf("expected an error for %q, got %v", v, err)
+		}
+	}
+}
+
+func TestApplySocketOptions(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}
+	}()
+	for _, o := range []SocketOptions{
+		DefaultSocketOptions,
+		{KeepAlive: 30 * time.Second, Linger: 0},
+		{KeepAlive: -1, Linger: 5},
+	} {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Apply(conn); err != nil {
+			t.Fatalf("failed applying %+v: %v", o, err)
+		}
+		_ = conn.Close()
+	}
+
+	// Connections other than TCP are left unchanged.
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	defer func() { _ = server.Close() }()
+	if err := (SocketOptions{KeepAlive: time.Second, Linger: 0}).Apply(client); err != nil {
+		t.Fatalf("expected a connection other than TCP to be left unchanged, got %v", err)
+	}
+}
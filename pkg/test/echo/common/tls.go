@@ -0,0 +1,236 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// TLSMinVersionHeader sets the minimum TLS version offered by a call, such as "TLSV1_2". It is read by the
+	// forwarder rather than sent.
+	TLSMinVersionHeader = "X-Echo-Tls-Min-Version"
+
+	// TLSMaxVersionHeader sets the maximum TLS version offered by a call. It is read by the forwarder rather than sent.
+	TLSMaxVersionHeader = "X-Echo-Tls-Max-Version"
+
+	// TLSCipherSuitesHeader sets the comma separated cipher suites offered by a call. It is read by the forwarder
+	// rather than sent.
+	TLSCipherSuitesHeader = "X-Echo-Tls-Cipher-Suites"
+
+	// ALPNHeader sets the comma separated application protocols offered by a call. It is read by the forwarder
+	// rather than sent.
+	ALPNHeader = "X-Echo-Alpn"
+
+	// TLSResumptionHeader, if "true", caches the TLS sessions of a call so that its later connections resume them. It
+	// is read by the forwarder rather than sent.
+	TLSResumptionHeader = "X-Echo-Tls-Resumption"
+)
+
+var tlsHeaders = map[string]bool{
+	TLSMinVersionHeader:   true,
+	TLSMaxVersionHeader:   true,
+	TLSCipherSuitesHeader: true,
+	ALPNHeader:            true,
+	TLSResumptionHeader:   true,
+}
+
+// IsTLSHeader returns true if the canonical header key is one of the TLS options of a call, rather than a header to
+// send.
+func IsTLSHeader(key string) bool {
+	return tlsHeaders[key]
+}
+
+// tlsVersions are the TLS versions, named as in the TLS settings of MeshConfig and Gateways.
+var tlsVersions = map[string]uint16{
+	"TLSV1_0": tls.VersionTLS10,
+	"TLSV1_1": tls.VersionTLS11,
+	"TLSV1_2": tls.VersionTLS12,
+	"TLSV1_3": tls.VersionTLS13,
+}
+
+// opensslCipherSuites maps the OpenSSL names of cipher suites, used by Envoy and the TLS settings of Gateways, to the
+// IANA names used by Go, for the suites both support.
+var opensslCipherSuites = map[string]string{
+	"ECDHE-ECDSA-AES128-GCM-SHA256": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	"ECDHE-RSA-AES128-GCM-SHA256":   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	"ECDHE-ECDSA-AES256-GCM-SHA384": "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	"ECDHE-RSA-AES256-GCM-SHA384":   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	"ECDHE-ECDSA-CHACHA20-POLY1305": "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	"ECDHE-RSA-CHACHA20-POLY1305":   "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	"ECDHE-ECDSA-AES128-SHA":        "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	"ECDHE-RSA-AES128-SHA":          "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	"ECDHE-ECDSA-AES256-SHA":        "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	"ECDHE-RSA-AES256-SHA":          "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	"AES128-GCM-SHA256":             "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	"AES256-GCM-SHA384":             "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	"AES128-SHA":                    "TLS_RSA_WITH_AES_128_CBC_SHA",
+	"AES256-SHA":                    "TLS_RSA_WITH_AES_256_CBC_SHA",
+}
+
+// TLSOptions are options of the TLS handshakes of a call.
+type TLSOptions struct {
+	// MinVersion and MaxVersion bound the TLS versions offered, named as in MeshConfig and Gateways, such as
+	// "TLSV1_2". Empty keeps the defaults of Go.
+	MinVersion string
+	MaxVersion string
+
+	// CipherSuites offered, by their OpenSSL names as in Gateways, such as "ECDHE-RSA-AES128-GCM-SHA256", or their
+	// IANA names. As with Go, they do not apply to TLS 1.3.
+	CipherSuites []string
+
+	// ALPN are the application protocols offered, such as "h2" and "http/1.1".
+	ALPN []string
+
+	// Resumption caches the sessions of the call, so that each connection after the first resumes one. HTTP calls
+	// then make a new connection for each request.
+	Resumption bool
+}
+
+// Headers returns the headers setting the TLS options of a call.
+func (o TLSOptions) Headers() http.Header {
+	h := http.Header{}
+	if o.MinVersion != "" {
+		h.Set(TLSMinVersionHeader, o.MinVersion)
+	}
+	if o.MaxVersion != "" {
+		h.Set(TLSMaxVersionHeader, o.MaxVersion)
+	}
+	if len(o.CipherSuites) > 0 {
+		h.Set(TLSCipherSuitesHeader, strings.Join(o.CipherSuites, ","))
+	}
+	if len(o.ALPN) > 0 {
+		h.Set(ALPNHeader, strings.Join(o.ALPN, ","))
+	}
+	if o.Resumption {
+		h.Set(TLSResumptionHeader, "true")
+	}
+	return h
+}
+
+// IsSet returns true if any option is set.
+func (o TLSOptions) IsSet() bool {
+	return o.MinVersion != "" || o.MaxVersion != "" || len(o.CipherSuites) > 0 || len(o.ALPN) > 0 || o.Resumption
+}
+
+// Apply the options to the TLS configuration of a call.
+func (o TLSOptions) Apply(cfg *tls.Config) error {
+	var err error
+	if o.MinVersion != "" {
+		if cfg.MinVersion, err = parseTLSVersion(o.MinVersion); err != nil {
+			return err
+		}
+	}
+	if o.MaxVersion != "" {
+		if cfg.MaxVersion, err = parseTLSVersion(o.MaxVersion); err != nil {
+			return err
+		}
+	}
+	if len(o.CipherSuites) > 0 {
+		ids := make([]uint16, 0, len(o.CipherSuites))
+		for _, name := range o.CipherSuites {
+			id, err := parseCipherSuite(name)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		cfg.CipherSuites = ids
+	}
+	if len(o.ALPN) > 0 {
+		for _, p := range o.ALPN {
+			if p == "" {
+				return fmt.Errorf("empty application protocol in %q", strings.Join(o.ALPN, ","))
+			}
+		}
+		cfg.NextProtos = o.ALPN
+	}
+	if o.Resumption {
+		cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	return nil
+}
+
+// GetTLSOptions returns the TLS options set by the headers of a call.
+func GetTLSOptions(headers http.Header) (TLSOptions, error) {
+	o := TLSOptions{
+		MinVersion: headers.Get(TLSMinVersionHeader),
+		MaxVersion: headers.Get(TLSMaxVersionHeader),
+	}
+	if v := headers.Get(TLSCipherSuitesHeader); v != "" {
+		o.CipherSuites = strings.Split(v, ",")
+	}
+	if v := headers.Get(ALPNHeader); v != "" {
+		o.ALPN = strings.Split(v, ",")
+	}
+	if v := headers.Get(TLSResumptionHeader); v != "" {
+		r, err := strconv.ParseBool(v)
+		if err != nil {
+			return o, fmt.Errorf("invalid %s %q: %v", TLSResumptionHeader, v, err)
+		}
+		o.Resumption = r
+	}
+	// Validate the options, rather than failing once connecting.
+	if err := o.Apply(&tls.Config{}); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+// TLSVersionName returns the name of a TLS version, as in MeshConfig and Gateways.
+func TLSVersionName(v uint16) string {
+	for name, id := range tlsVersions {
+		if id == v {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// CipherSuiteName returns the OpenSSL name of a cipher suite, or its IANA name if it has none, such as for the suites
+// of TLS 1.3.
+func CipherSuiteName(id uint16) string {
+	name := tls.CipherSuiteName(id)
+	for openssl, iana := range opensslCipherSuites {
+		if iana == name {
+			return openssl
+		}
+	}
+	return name
+}
+
+func parseTLSVersion(name string) (uint16, error) {
+	v, ok := tlsVersions[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q", name)
+	}
+	return v, nil
+}
+
+func parseCipherSuite(name string) (uint16, error) {
+	if iana, ok := opensslCipherSuites[name]; ok {
+		name = iana
+	}
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if s.Name == name {
+			return s.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
@@ -0,0 +1,134 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/tls"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetTLSOptions(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    TLSOptions
+		// err is a substring of the expected error, or empty if the headers are valid.
+		err string
+	}{
+		{name: "none"},
+		{
+			name: "all options",
+			headers: map[string]string{
+				TLSMinVersionHeader:   "TLSV1_2",
+				TLSMaxVersionHeader:   "tlsv1_3",
+				TLSCipherSuitesHeader: "ECDHE-RSA-AES128-GCM-SHA256,TLS_RSA_WITH_AES_128_CBC_SHA",
+				ALPNHeader:            "h2,http/1.1",
+				TLSResumptionHeader:   "true",
+			},
+			want: TLSOptions{
+				MinVersion:   "TLSV1_2",
+				MaxVersion:   "tlsv1_3",
+				CipherSuites: []string{"ECDHE-RSA-AES128-GCM-SHA256", "TLS_RSA_WITH_AES_128_CBC_SHA"},
+				ALPN:         []string{"h2", "http/1.1"},
+				Resumption:   true,
+			},
+		},
+		{name: "empty values", headers: map[string]string{TLSMinVersionHeader: "", ALPNHeader: "", TLSResumptionHeader: ""}},
+		{name: "invalid min version", headers: map[string]string{TLSMinVersionHeader: "TLSV1_4"}, err: `unknown TLS version "TLSV1_4"`},
+		{name: "invalid max version", headers: map[string]string{TLSMaxVersionHeader: "1.2"}, err: `unknown TLS version "1.2"`},
+		{name: "unknown cipher suite", headers: map[string]string{TLSCipherSuitesHeader: "RC5"}, err: `unknown cipher suite "RC5"`},
+		{name: "empty cipher suite", headers: map[string]string{TLSCipherSuitesHeader: "AES128-SHA,"}, err: `unknown cipher suite ""`},
+		{name: "empty application protocol", headers: map[string]string{ALPNHeader: "h2,,http/1.1"}, err: "empty application protocol"},
+		{name: "invalid resumption", headers: map[string]string{TLSResumptionHeader: "yes"}, err: "invalid " + TLSResumptionHeader},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			got, err := GetTLSOptions(h)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			// The options round trip through the headers of a call.
+			if rt, err := GetTLSOptions(got.Headers()); err != nil || !reflect.DeepEqual(rt, got) {
+				t.Fatalf("got %+v after a round trip through the headers, want %+v: %v", rt, got, err)
+			}
+		})
+	}
+}
+
+func TestApplyTLSOptions(t *testing.T) {
+	cfg := &tls.Config{}
+	if err := (TLSOptions{
+		MinVersion:   "TLSV1_1",
+		MaxVersion:   "TLSV1_2",
+		CipherSuites: []string{"ECDHE-ECDSA-AES256-GCM-SHA384", "TLS_RSA_WITH_AES_256_CBC_SHA"},
+		ALPN:         []string{"h2"},
+		Resumption:   true,
+	}).Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MinVersion != tls.VersionTLS11 || cfg.MaxVersion != tls.VersionTLS12 {
+		t.Fatalf("got versions 0x%04x-0x%04x, want TLS 1.1-1.2", cfg.MinVersion, cfg.MaxVersion)
+	}
+	if want := []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_RSA_WITH_AES_256_CBC_SHA}; !reflect.DeepEqual(cfg.CipherSuites, want) {
+		t.Fatalf("got cipher suites %v, want %v", cfg.CipherSuites, want)
+	}
+	if !reflect.DeepEqual(cfg.NextProtos, []string{"h2"}) || cfg.ClientSessionCache == nil {
+		t.Fatalf("got ALPN %v and session cache %v, want h2 and a cache", cfg.NextProtos, cfg.ClientSessionCache)
+	}
+
+	// Unset options keep the configuration unchanged.
+	cfg = &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: []string{"http/1.1"}}
+	if err := (TLSOptions{}).Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MinVersion != tls.VersionTLS12 || cfg.MaxVersion != 0 || cfg.CipherSuites != nil || !reflect.DeepEqual(cfg.NextProtos, []string{"http/1.1"}) {
+		t.Fatalf("expected the configuration to be unchanged, got %+v", cfg)
+	}
+}
+
+func TestTLSNames(t *testing.T) {
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{TLSVersionName(tls.VersionTLS12), "TLSV1_2"},
+		{TLSVersionName(0x0999), "0x0999"},
+		{CipherSuiteName(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), "ECDHE-RSA-AES128-GCM-SHA256"},
+		// TLS 1.3 suites have no OpenSSL name.
+		{CipherSuiteName(tls.TLS_AES_128_GCM_SHA256), "TLS_AES_128_GCM_SHA256"},
+	} {
+		if tc.got != tc.want {
+			t.Fatalf("got %q, want %q", tc.got, tc.want)
+		}
+	}
+	if !IsTLSHeader(ALPNHeader) || IsTLSHeader("X-Other") {
+		t.Fatal("expected only the TLS options to be TLS headers")
+	}
+}
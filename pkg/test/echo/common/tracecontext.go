@@ -0,0 +1,152 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceContextHeader sets the comma separated trace context formats sent with each request of an HTTP call, such as
+// "b3,w3c", each request starting a new trace. It is read by the forwarder rather than sent.
+const TraceContextHeader = "X-Echo-Trace-Context"
+
+// TraceFormat is a format of trace context headers.
+type TraceFormat string
+
+const (
+	// B3 is the multi-header B3 format of Zipkin: x-b3-traceid, x-b3-spanid and x-b3-sampled.
+	B3 TraceFormat = "b3"
+	// B3Single is the single-header B3 format: b3.
+	B3Single TraceFormat = "b3-single"
+	// W3C is the W3C Trace Context format: traceparent.
+	W3C TraceFormat = "w3c"
+)
+
+// CustomTraceFormat returns the format carrying the trace ID alone in the header, such as those propagated by
+// applications with their own tracing.
+func CustomTraceFormat(header string) TraceFormat {
+	return TraceFormat("custom:" + http.CanonicalHeaderKey(header))
+}
+
+// Header is the header carrying the trace ID in the format.
+func (f TraceFormat) Header() string {
+	switch f {
+	case B3:
+		return "X-B3-Traceid"
+	case B3Single:
+		return "B3"
+	case W3C:
+		return "Traceparent"
+	}
+	return strings.TrimPrefix(string(f), "custom:")
+}
+
+// Valid returns an error if the format is not known.
+func (f TraceFormat) Valid() error {
+	switch f {
+	case B3, B3Single, W3C:
+		return nil
+	}
+	if strings.HasPrefix(string(f), "custom:") && f.Header() != "" {
+		return nil
+	}
+	return fmt.Errorf("unknown trace context format %q", f)
+}
+
+// TraceID extracts the trace ID from the value of the header of the format, or returns empty if it has none.
+func (f TraceFormat) TraceID(value string) string {
+	switch f {
+	case B3Single:
+		// {trace id}-{span id}[-{sampling}[-{parent span id}]]
+		return strings.SplitN(value, "-", 2)[0]
+	case W3C:
+		// {version}-{trace id}-{parent id}-{flags}
+		parts := strings.Split(value, "-")
+		if len(parts) < 4 {
+			return ""
+		}
+		return parts[1]
+	}
+	return value
+}
+
+// TraceContext is a new trace, sent in several formats.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+}
+
+// NewTraceContext returns a new sampled trace.
+func NewTraceContext() (TraceContext, error) {
+	trace := make([]byte, 16)
+	span := make([]byte, 8)
+	if _, err := rand.Read(trace); err != nil {
+		return TraceContext{}, err
+	}
+	if _, err := rand.Read(span); err != nil {
+		return TraceContext{}, err
+	}
+	return TraceContext{TraceID: hex.EncodeToString(trace), SpanID: hex.EncodeToString(span)}, nil
+}
+
+// Headers returns the headers of the trace in the formats.
+func (c TraceContext) Headers(formats []TraceFormat) http.Header {
+	h := http.Header{}
+	for _, f := range formats {
+		switch f {
+		case B3:
+			h.Set("X-B3-Traceid", c.TraceID)
+			h.Set("X-B3-Spanid", c.SpanID)
+			h.Set("X-B3-Sampled", "1")
+		case B3Single:
+			h.Set("B3", fmt.Sprintf("%s-%s-1", c.TraceID, c.SpanID))
+		case W3C:
+			h.Set("Traceparent", fmt.Sprintf("00-%s-%s-01", c.TraceID, c.SpanID))
+		default:
+			h.Set(f.Header(), c.TraceID)
+		}
+	}
+	return h
+}
+
+// TraceFormatsHeader returns the value of TraceContextHeader sending the formats.
+func TraceFormatsHeader(formats []TraceFormat) string {
+	out := make([]string, 0, len(formats))
+	for _, f := range formats {
+		out = append(out, string(f))
+	}
+	return strings.Join(out, ",")
+}
+
+// GetTraceFormats returns the trace context formats set by the headers of an HTTP call.
+func GetTraceFormats(headers http.Header) ([]TraceFormat, error) {
+	v := headers.Get(TraceContextHeader)
+	if v == "" {
+		return nil, nil
+	}
+	var out []TraceFormat
+	for _, f := range strings.Split(v, ",") {
+		tf := TraceFormat(strings.TrimSpace(f))
+		if err := tf.Valid(); err != nil {
+			return nil, err
+		}
+		out = append(out, tf)
+	}
+	return out, nil
+}
@@ -25,6 +25,24 @@ const (
 	ConnectionTimeout     = 2 * time.Second
 	DefaultRequestTimeout = 15 * time.Second
 	DefaultCount          = 1
+
+	// EventStreamContentType is the content type of server-sent event streams.
+	EventStreamContentType = "text/event-stream"
+
+	// GRPCHealthParam is the query parameter of HTTP requests setting the status reported by the gRPC health service
+	// of the echo server, such as ?grpcHealth=NOT_SERVING.
+	GRPCHealthParam = "grpcHealth"
+
+	// ReadinessParam is the query parameter of HTTP requests setting whether the echo server reports itself ready, such
+	// as ?setReady=false. While not ready, every other HTTP request, including readiness probes, fails with 503.
+	ReadinessParam = "setReady"
+
+	// LivenessParam is the query parameter of HTTP requests setting whether the echo server reports itself live, such
+	// as ?setLive=false. While not live, requests to LivenessPath fail with 503.
+	LivenessParam = "setLive"
+
+	// LivenessPath is the path of the liveness probe of the echo server.
+	LivenessPath = "/livez"
 )
 
 // FillInDefaults fills in the timeout and count if not specified in the given message.
@@ -25,6 +25,7 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 
 	"istio.io/istio/pkg/test/echo/common"
@@ -75,6 +76,7 @@ func (s *grpcInstance) Start(onReady OnReadyFunc) error {
 	proto.RegisterEchoTestServiceServer(s.server, &grpcHandler{
 		Config: s.Config,
 	})
+	grpc_health_v1.RegisterHealthServer(s.server, grpcHealth)
 
 	// Start serving GRPC traffic.
 	go func() {
@@ -158,6 +160,7 @@ func (h *grpcHandler) Echo(ctx context.Context, req *proto.EchoRequest) (*proto.
 	writeField(&body, response.ServiceVersionField, h.Version)
 	writeField(&body, response.ServicePortField, strconv.Itoa(portNumber))
 	writeField(&body, response.ClusterField, h.Cluster)
+	writeField(&body, response.LocalityField, h.Locality)
 	writeField(&body, "Echo", req.GetMessage())
 
 	if hostname, err := os.Hostname(); err == nil {
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoint
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	grpchealth "google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"istio.io/istio/pkg/test/echo/common"
+)
+
+// grpcHealth is the health service of every gRPC endpoint of the server. Its status is shared by all of them, and set
+// by HTTP requests with the common.GRPCHealthParam query parameter, so that tests control the health reported to gRPC
+// health checks.
+var grpcHealth = grpchealth.NewServer()
+
+// setGRPCHealth sets the status of the gRPC health service to the value of the query parameter, such as SERVING or
+// NOT_SERVING.
+func setGRPCHealth(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query().Get(common.GRPCHealthParam)
+	status, ok := grpc_health_v1.HealthCheckResponse_ServingStatus_value[v]
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid gRPC health status %q", v), http.StatusBadRequest)
+		return
+	}
+	grpcHealth.SetServingStatus("", grpc_health_v1.HealthCheckResponse_ServingStatus(status))
+	epLog.Infof("gRPC health set to %s", v)
+	w.WriteHeader(http.StatusOK)
+}
+
+// appHealth is the health of the application set by tests, shared by every endpoint of the server. It is reported to
+// the readiness and liveness probes of the echo deployment, to drive outlier detection, the propagation of endpoint
+// health and failover without killing pods.
+var appHealth = &health{}
+
+type health struct {
+	notReady uint32
+	notLive  uint32
+}
+
+func (h *health) isReady() bool {
+	return atomic.LoadUint32(&h.notReady) == 0
+}
+
+func (h *health) isLive() bool {
+	return atomic.LoadUint32(&h.notLive) == 0
+}
+
+// setAppHealth sets the readiness and liveness of the application to the values of the query parameters, if present.
+func setAppHealth(w http.ResponseWriter, r *http.Request) {
+	for param, state := range map[string]*uint32{
+		common.ReadinessParam: &appHealth.notReady,
+		common.LivenessParam:  &appHealth.notLive,
+	} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			continue
+		}
+		ok, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s %q", param, v), http.StatusBadRequest)
+			return
+		}
+		if ok {
+			atomic.StoreUint32(state, 0)
+		} else {
+			atomic.StoreUint32(state, 1)
+		}
+		epLog.Infof("application health %s set to %v", param, ok)
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoint
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"istio.io/istio/pkg/test/echo/common"
+)
+
+func TestSetGRPCHealth(t *testing.T) {
+	defer grpcHealth.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	cases := []struct {
+		value string
+		code  int
+		want  grpc_health_v1.HealthCheckResponse_ServingStatus
+	}{
+		{"NOT_SERVING", http.StatusOK, grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{"invalid", http.StatusBadRequest, grpc_health_v1.HealthCheckResponse_NOT_SERVING},
+		{"SERVING", http.StatusOK, grpc_health_v1.HealthCheckResponse_SERVING},
+	}
+	for _, tc := range cases {
+		t.Run(tc.value, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setGRPCHealth(w, httptest.NewRequest(http.MethodGet, "/?"+common.GRPCHealthParam+"="+tc.value, nil))
+			if w.Code != tc.code {
+				t.Fatalf("got code %d, want %d", w.Code, tc.code)
+			}
+			resp, err := grpcHealth.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status != tc.want {
+				t.Fatalf("got status %s, want %s", resp.Status, tc.want)
+			}
+		})
+	}
+}
+
+func TestAppHealth(t *testing.T) {
+	defer func() { appHealth = &health{} }()
+	h := &httpHandler{Config: Config{
+		IsServerReady: func() bool { return true },
+		Port:          &common.Port{Port: 8080},
+	}}
+	steps := []struct {
+		name string
+		url  string
+		code int
+	}{
+		{"live", common.LivenessPath, http.StatusOK},
+		{"ready", "/", http.StatusOK},
+		{"set not live", "/?" + common.LivenessParam + "=false", http.StatusOK},
+		{"not live", common.LivenessPath, http.StatusServiceUnavailable},
+		{"still ready", "/", http.StatusOK},
+		{"set not ready", "/?" + common.ReadinessParam + "=false", http.StatusOK},
+		{"not ready", "/", http.StatusServiceUnavailable},
+		{"invalid", "/?" + common.ReadinessParam + "=maybe", http.StatusBadRequest},
+		{"set healthy while not ready", "/?" + common.ReadinessParam + "=true&" + common.LivenessParam + "=true", http.StatusOK},
+		{"live again", common.LivenessPath, http.StatusOK},
+		{"ready again", "/", http.StatusOK},
+	}
+	for _, s := range steps {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, s.url, nil))
+		if w.Code != s.code {
+			t.Fatalf("%s: got code %d, want %d", s.name, w.Code, s.code)
+		}
+	}
+}
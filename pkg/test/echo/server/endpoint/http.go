@@ -17,7 +17,9 @@ package endpoint
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
@@ -28,6 +30,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
@@ -194,7 +197,20 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	epLog.Infof("HTTP Request:\n  Method: %s\n  URL: %v,\n  Host: %s\n  Headers: %v",
 		r.Method, r.URL, r.Host, r.Header)
 	defer common.Metrics.HTTPRequests.With(common.PortLabel.Value(strconv.Itoa(h.Port.Port))).Increment()
-	if !h.IsServerReady() {
+	// Health is set even while the server is not ready, so that it can be made ready again.
+	if q := r.URL.Query(); q.Get(common.ReadinessParam) != "" || q.Get(common.LivenessParam) != "" {
+		setAppHealth(w, r)
+		return
+	}
+	if r.URL.Path == common.LivenessPath {
+		if !appHealth.isLive() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if !h.IsServerReady() || !appHealth.isReady() {
 		// Handle readiness probe failure.
 		epLog.Infof("HTTP service not ready, returning 503")
 		w.WriteHeader(http.StatusServiceUnavailable)
@@ -203,6 +219,10 @@ func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if common.IsWebSocketRequest(r) {
 		h.webSocketEcho(w, r)
+	} else if r.URL.Query().Get("events") != "" {
+		h.eventStream(w, r)
+	} else if r.URL.Query().Get(common.GRPCHealthParam) != "" {
+		setGRPCHealth(w, r)
 	} else {
 		h.echo(w, r)
 	}
@@ -221,27 +241,198 @@ func (h *httpHandler) echo(w http.ResponseWriter, r *http.Request) {
 		writeError(&body, "ParseForm() error: "+err.Error())
 	}
 
+	// Read and decode the whole request body, to confirm what was received.
+	received, err := common.Decompress(r.Header.Get("Content-Encoding"), r.Body)
+	if err != nil {
+		writeError(&body, "body read error: "+err.Error())
+	}
+
+	// If the request has form ?compress=encoding, compress the response with that encoding. Unsupported encodings
+	// are rejected before anything is written, so that no response is labelled with an encoding it does not have.
+	encoding := r.FormValue("compress")
+	if err := common.ValidateEncoding(encoding); err != nil {
+		epLog.Warnf("compress error: %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("compress error: " + err.Error() + "\n"))
+		return
+	}
+
+	// If the request has form ?delay=duration hold the request for that long before responding.
+	// This keeps requests in flight, for example to observe their behavior while the server is draining.
+	if err := delayResponse(r); err != nil {
+		writeError(&body, "delay error: "+err.Error())
+	}
+
+	// If the request has X-Echo-Fault-* headers, misbehave in responding as they request.
+	fault, err := common.GetFault(r.Header)
+	if err != nil {
+		writeError(&body, "fault error: "+err.Error())
+		fault = common.Fault{}
+	}
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-r.Context().Done():
+		}
+	}
+
 	// If the request has form ?headers=name:value[,name:value]* return those headers in response
 	if err := setHeaderResponseFromHeaders(r, w); err != nil {
 		writeError(&body, "response headers error: "+err.Error())
 	}
 
+	// If the request has form ?session=cookie:name or ?session=header:name, issue a session in that cookie or header
+	// if the request does not carry one, as an application managing its own sessions does.
+	if err := setSession(r, w); err != nil {
+		writeError(&body, "session error: "+err.Error())
+	}
+
 	// If the request has form ?codes=code[:chance][,code[:chance]]* return those codes, rather than 200
 	// For example, ?codes=500:1,200:1 returns 500 1/2 times and 200 1/2 times
 	// For example, ?codes=500:90,200:10 returns 500 90% of times and 200 10% of times
-	if err := setResponseFromCodes(r, w); err != nil {
+	code, err := responseCodeFromCodes(r)
+	if err != nil {
 		writeError(&body, "codes error: "+err.Error())
 	}
 
 	h.addResponsePayload(r, &body)
+	writeField(&body, response.RequestBodySizeField, strconv.Itoa(len(received)))
+	writeField(&body, response.RequestBodyHashField, fmt.Sprintf("%x", sha256.Sum256(received)))
 
+	// The headers are only set once the body is final, as they are sent with the status code.
+	out, err := common.Compress(encoding, body.Bytes())
+	if err != nil {
+		writeError(&body, "compress error: "+err.Error())
+		out = body.Bytes()
+	} else if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	epLog.Infof("Response status code: %d", code)
+	w.WriteHeader(code)
 	w.Header().Set("Content-Type", "application/text")
-	if _, err := w.Write(body.Bytes()); err != nil {
+	if fault.IsSet() {
+		err = writeWithFault(w, r, out, fault)
+	} else {
+		_, err = w.Write(out)
+	}
+	if err != nil {
 		epLog.Warna(err)
 	}
 	epLog.Infof("Response Headers: %+v", w.Header())
 }
 
+// writeWithFault writes the body of a response, misbehaving as requested by the fault.
+func writeWithFault(w http.ResponseWriter, r *http.Request, body []byte, f common.Fault) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("fault: streaming is not supported")
+	}
+	end := len(body)
+	if f.Close != "" && f.CloseAfter < end {
+		end = f.CloseAfter
+	}
+	size := f.ChunkSize
+	if size <= 0 {
+		size = end
+	}
+	// Flush the headers, so that the body is chunked and faults mid-body follow them.
+	flusher.Flush()
+	for i := 0; i < end; i += size {
+		if i > 0 && f.ChunkDelay > 0 {
+			select {
+			case <-time.After(f.ChunkDelay):
+			case <-r.Context().Done():
+				return r.Context().Err()
+			}
+		}
+		n := i + size
+		if n > end {
+			n = end
+		}
+		if _, err := w.Write(body[i:n]); err != nil {
+			return err
+		}
+		flusher.Flush()
+	}
+
+	switch {
+	case f.MalformedChunked:
+		// A chunk size must be hexadecimal.
+		return closeConnection(w, r, f.Close == common.Reset, []byte("zz\r\nmalformed\r\n"))
+	case f.Close != "":
+		return closeConnection(w, r, f.Close == common.Reset, nil)
+	}
+	return nil
+}
+
+// closeConnection closes the connection of an HTTP/1.1 request after writing the data, resetting it if requested. The
+// stream of an HTTP/2 request is aborted instead.
+func closeConnection(w http.ResponseWriter, r *http.Request, reset bool, data []byte) error {
+	hijacker, ok := w.(http.Hijacker)
+	if r.ProtoMajor != 1 || !ok {
+		// The server resets the stream, and recovers without logging.
+		panic(http.ErrAbortHandler)
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := rw.Write(data); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return err
+	}
+	if tcp, ok := tcpConnOf(conn); ok && reset {
+		// Discard any unsent data and send a RST, rather than a FIN, on close, for TLS connections too.
+		return tcp.SetLinger(0)
+	}
+	return nil
+}
+
+// eventStream responds to requests of the form ?events=count[&interval=duration] with a stream of server-sent
+// events, flushing each event as it is written. The interval between events defaults to 1s.
+func (h *httpHandler) eventStream(w http.ResponseWriter, r *http.Request) {
+	count, err := strconv.Atoi(r.URL.Query().Get("events"))
+	if err != nil || count < 0 {
+		http.Error(w, fmt.Sprintf("invalid events %q", r.URL.Query().Get("events")), http.StatusBadRequest)
+		return
+	}
+	interval := time.Second
+	if s := r.URL.Query().Get("interval"); s != "" {
+		if interval, err = time.ParseDuration(s); err != nil {
+			http.Error(w, fmt.Sprintf("invalid interval %q: %v", s, err), http.StatusBadRequest)
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	w.Header().Set("Content-Type", common.EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(interval):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", i, hostname); err != nil {
+			epLog.Warnf("event stream write failed: %v", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
 func (h *httpHandler) webSocketEcho(w http.ResponseWriter, r *http.Request) {
 	// adapted from https://github.com/gorilla/websocket/blob/master/examples/echo/server.go
 	// First send upgrade headers
@@ -286,10 +477,17 @@ func (h *httpHandler) addResponsePayload(r *http.Request, body *bytes.Buffer) {
 	writeField(body, response.HostField, r.Host)
 	writeField(body, response.URLField, r.URL.String())
 	writeField(body, response.ClusterField, h.Cluster)
+	writeField(body, response.LocalityField, h.Locality)
 
 	writeField(body, "Method", r.Method)
 	writeField(body, "Proto", r.Proto)
 	writeField(body, "RemoteAddr", r.RemoteAddr)
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		writeField(body, response.LocalAddrField, addr.String())
+	}
+	if addr := destinationAddr(r); addr != "" {
+		writeField(body, response.DestinationAddrField, addr)
+	}
 
 	keys := []string{}
 	for k := range r.Header {
@@ -308,6 +506,39 @@ func (h *httpHandler) addResponsePayload(r *http.Request, body *bytes.Buffer) {
 	}
 }
 
+// destinationAddr returns the address the client connected to. With a sidecar, the server accepts the connection of
+// the inbound proxy on a loopback address, of the family of the inbound cluster rather than that picked by the client,
+// so the address is only known if the proxy sets it in the OriginalDstHeader.
+func destinationAddr(r *http.Request) string {
+	if addr := r.Header.Get(response.OriginalDstHeader); addr != "" {
+		return addr
+	}
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return ""
+	}
+	if tcp, ok := addr.(*net.TCPAddr); ok && tcp.IP.IsLoopback() {
+		return ""
+	}
+	return addr.String()
+}
+
+func delayResponse(request *http.Request) error {
+	s := request.FormValue("delay")
+	if len(s) == 0 {
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid delay %q: %v", s, err)
+	}
+	select {
+	case <-time.After(d):
+	case <-request.Context().Done():
+	}
+	return nil
+}
+
 func setHeaderResponseFromHeaders(request *http.Request, response http.ResponseWriter) error {
 	s := request.FormValue("headers")
 	if len(s) == 0 {
@@ -327,12 +558,43 @@ func setHeaderResponseFromHeaders(request *http.Request, response http.ResponseW
 	return nil
 }
 
-func setResponseFromCodes(request *http.Request, response http.ResponseWriter) error {
+func setSession(request *http.Request, response http.ResponseWriter) error {
+	s := request.FormValue("session")
+	if len(s) == 0 {
+		return nil
+	}
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("invalid %q (want cookie:name or header:name)", s)
+	}
+	name := parts[1]
+	switch parts[0] {
+	case "cookie":
+		if _, err := request.Cookie(name); err == nil {
+			return nil
+		}
+		http.SetCookie(response, &http.Cookie{Name: name, Value: uuid.New().String(), Path: "/"})
+	case "header":
+		// Clients carry header sessions themselves, so the session is returned with every response.
+		v := request.Header.Get(name)
+		if v == "" {
+			v = uuid.New().String()
+		}
+		response.Header().Set(name, v)
+	default:
+		return fmt.Errorf("invalid %q (want cookie:name or header:name)", s)
+	}
+	return nil
+}
+
+// responseCodeFromCodes chooses the status code of the response to the request. If its codes are invalid, it
+// returns 200 along with the error.
+func responseCodeFromCodes(request *http.Request) (int, error) {
 	responseCodes := request.FormValue("codes")
 
 	codes, err := validateCodes(responseCodes)
 	if err != nil {
-		return err
+		return http.StatusOK, err
 	}
 
 	// Choose a random "slice" from a pie
@@ -353,9 +615,7 @@ func setResponseFromCodes(request *http.Request, response http.ResponseWriter) e
 		position += flavor.slices
 	}
 
-	epLog.Infof("Response status code: %d", responseCode)
-	response.WriteHeader(responseCode)
-	return nil
+	return responseCode, nil
 }
 
 // codes must be comma-separated HTTP response code, colon, positive integer
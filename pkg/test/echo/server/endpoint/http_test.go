@@ -0,0 +1,185 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoint
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/response"
+)
+
+func TestDestinationAddr(t *testing.T) {
+	cases := []struct {
+		name      string
+		localAddr string
+		header    string
+		want      string
+	}{
+		{"direct IPv4", "10.0.0.1:8080", "", "10.0.0.1:8080"},
+		{"direct IPv6", "[fd00::1]:8080", "", "[fd00::1]:8080"},
+		{"sidecar", "127.0.0.1:8080", "", ""},
+		{"sidecar with original destination", "127.0.0.1:8080", "[fd00::1]:8080", "[fd00::1]:8080"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr, err := net.ResolveTCPAddr("tcp", tc.localAddr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r = r.WithContext(context.WithValue(r.Context(), http.LocalAddrContextKey, addr))
+			if tc.header != "" {
+				r.Header.Set(response.OriginalDstHeader, tc.header)
+			}
+			if got := destinationAddr(r); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSetSession(t *testing.T) {
+	cases := []struct {
+		name       string
+		session    string
+		cookie     string
+		header     string
+		wantCookie bool
+		wantHeader string
+		wantErr    bool
+	}{
+		{name: "none"},
+		{name: "new cookie", session: "cookie:s", wantCookie: true},
+		{name: "existing cookie", session: "cookie:s", cookie: "s=abc"},
+		{name: "new header", session: "header:x-s", wantHeader: "*"},
+		{name: "existing header", session: "header:x-s", header: "abc", wantHeader: "abc"},
+		{name: "missing name", session: "cookie:", wantErr: true},
+		{name: "unknown kind", session: "query:s", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			target := "/"
+			if tc.session != "" {
+				target = "/?session=" + tc.session
+			}
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			if tc.cookie != "" {
+				r.Header.Set("Cookie", tc.cookie)
+			}
+			if tc.header != "" {
+				r.Header.Set("x-s", tc.header)
+			}
+			w := httptest.NewRecorder()
+			err := setSession(r, w)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			cookies := w.Result().Cookies()
+			if gotCookie := len(cookies) == 1 && cookies[0].Name == "s" && cookies[0].Value != ""; gotCookie != tc.wantCookie {
+				t.Errorf("got cookies %v, want a new cookie %v", cookies, tc.wantCookie)
+			}
+			got := w.Header().Get("x-s")
+			switch tc.wantHeader {
+			case "*":
+				if got == "" {
+					t.Errorf("got no session header, want a new one")
+				}
+			default:
+				if got != tc.wantHeader {
+					t.Errorf("got session header %q, want %q", got, tc.wantHeader)
+				}
+			}
+		})
+	}
+}
+
+func TestTCPConnOfTLS(t *testing.T) {
+	found := make(chan bool, 1)
+	s := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, ok := tcpConnOf(conn)
+		select {
+		case found <- ok:
+		default:
+		}
+		_ = conn.Close()
+	}))
+	s.Listener = trackingListener{s.Listener}
+	s.StartTLS()
+	defer s.Close()
+
+	// The hijacked connection is closed without a response.
+	_, _ = s.Client().Get(s.URL)
+	if !<-found {
+		t.Fatal("expected the TCP connection of the TLS connection to be found, so that it can be reset")
+	}
+	n := 0
+	tcpConns.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	if n != 0 {
+		t.Fatalf("expected closed connections to be forgotten, got %d", n)
+	}
+}
+
+func TestEchoCompression(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		code     int
+		encoding string
+		body     string
+	}{
+		{name: "uncompressed", query: "", code: http.StatusOK, body: "Method=GET"},
+		{name: "gzip", query: "compress=gzip", code: http.StatusOK, encoding: "gzip", body: "Method=GET"},
+		{name: "deflate with codes", query: "compress=deflate&codes=503", code: http.StatusServiceUnavailable,
+			encoding: "deflate", body: "Method=GET"},
+		{name: "gzip with invalid codes", query: "compress=gzip&codes=x", code: http.StatusOK, encoding: "gzip",
+			body: "codes error"},
+		{name: "unsupported", query: "compress=br", code: http.StatusBadRequest, body: `unsupported content encoding "br"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			(&httpHandler{}).echo(w, httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil))
+			resp := w.Result()
+			if resp.StatusCode != tc.code {
+				t.Fatalf("got status code %d, want %d", resp.StatusCode, tc.code)
+			}
+			encoding := resp.Header.Get("Content-Encoding")
+			if encoding != tc.encoding {
+				t.Fatalf("got Content-Encoding %q, want %q", encoding, tc.encoding)
+			}
+			body, err := common.Decompress(encoding, resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(body), tc.body) {
+				t.Fatalf("expected the body to contain %q, got:\n%s", tc.body, body)
+			}
+		})
+	}
+}
@@ -33,11 +33,13 @@ type Config struct {
 	IsServerReady IsServerReadyFunc
 	Version       string
 	Cluster       string
+	Locality      string
 	TLSCert       string
 	TLSKey        string
 	UDSServer     string
 	Dialer        common.Dialer
 	Port          *common.Port
+	Socket        *common.SocketOptions
 }
 
 // Instance of an endpoint that serves the Echo application on a single port/protocol.
@@ -100,6 +100,12 @@ func (s *tcpInstance) echo(conn net.Conn) {
 		_ = conn.Close()
 	}()
 
+	if s.Socket != nil {
+		if err := s.Socket.Apply(conn); err != nil {
+			epLog.Warnf("TCP socket options failed: %v", err)
+		}
+	}
+
 	// If this is server first, client expects a message from server. Send the magic string.
 	if s.Port.ServerFirst {
 		_, _ = conn.Write([]byte(common.ServerFirstMagicString))
@@ -143,6 +149,7 @@ func (s *tcpInstance) writeResponse(conn net.Conn) {
 	respFields := map[response.Field]string{
 		response.StatusCodeField:     response.StatusCodeOK,
 		response.ClusterField:        s.Cluster,
+		response.LocalityField:       s.Locality,
 		response.ServiceVersionField: s.Version,
 		response.ServicePortField:    strconv.Itoa(s.Port.Port),
 	}
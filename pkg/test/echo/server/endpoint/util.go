@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"sync"
 
 	"istio.io/istio/pkg/test/echo/common/response"
 	"istio.io/pkg/log"
@@ -38,13 +39,60 @@ func listenOnPort(port int) (net.Listener, int, error) {
 }
 
 func listenOnPortTLS(port int, cfg *tls.Config) (net.Listener, int, error) {
-	ln, err := tls.Listen("tcp", fmt.Sprintf(":%d", port), cfg)
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return nil, 0, err
 	}
 
 	port = ln.Addr().(*net.TCPAddr).Port
-	return ln, port, nil
+	return tls.NewListener(trackingListener{ln}, cfg), port, nil
+}
+
+// tcpConns are the open TCP connections accepted by TLS listeners, by their addresses, so that the TCP connection of
+// a TLS connection, which hides it, can be found.
+var tcpConns sync.Map
+
+// trackingListener adds the connections it accepts to tcpConns until they are closed.
+type trackingListener struct {
+	net.Listener
+}
+
+func (l trackingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tcp, ok := c.(*net.TCPConn)
+	if !ok {
+		return c, nil
+	}
+	tcpConns.Store(connKey(tcp), tcp)
+	return trackedConn{tcp}, nil
+}
+
+type trackedConn struct {
+	*net.TCPConn
+}
+
+func (c trackedConn) Close() error {
+	tcpConns.Delete(connKey(c))
+	return c.TCPConn.Close()
+}
+
+func connKey(c net.Conn) string {
+	return c.LocalAddr().String() + "-" + c.RemoteAddr().String()
+}
+
+// tcpConnOf returns the TCP connection of a connection, which is either a TCP connection or a TLS connection accepted
+// by a listener of listenOnPortTLS.
+func tcpConnOf(c net.Conn) (*net.TCPConn, bool) {
+	if tcp, ok := c.(*net.TCPConn); ok {
+		return tcp, true
+	}
+	if tcp, ok := tcpConns.Load(connKey(c)); ok {
+		return tcp.(*net.TCPConn), true
+	}
+	return nil, false
 }
 
 func listenOnUDS(uds string) (net.Listener, error) {
@@ -18,12 +18,16 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net/textproto"
 	"strconv"
 	"strings"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
+	"istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/echo/proto"
 )
 
@@ -42,12 +46,15 @@ func (c *grpcProtocol) makeRequest(ctx context.Context, req *request) (string, e
 	// Add headers to the request context.
 	outMD := make(metadata.MD)
 	for k, v := range req.Header {
-		// Exclude the Host header from the GRPC context.
-		if !strings.EqualFold(hostHeader, k) {
+		// Exclude the Host header and the TLS options of the call from the GRPC context.
+		if !strings.EqualFold(hostHeader, k) && !common.IsTLSHeader(textproto.CanonicalMIMEHeaderKey(k)) {
 			outMD.Set(k, v...)
 		}
 	}
-	outMD.Set("X-Request-Id", strconv.Itoa(req.RequestID))
+	// Requests sent without an ID are identified by their index within the call.
+	if len(outMD.Get(common.RequestIDHeader)) == 0 {
+		outMD.Set(common.RequestIDHeader, strconv.Itoa(req.RequestID))
+	}
 	ctx = metadata.NewOutgoingContext(ctx, outMD)
 
 	var outBuffer bytes.Buffer
@@ -56,10 +63,14 @@ func (c *grpcProtocol) makeRequest(ctx context.Context, req *request) (string, e
 	}
 	outBuffer.WriteString(fmt.Sprintf("[%d] grpcecho.Echo(%v)\n", req.RequestID, req))
 
-	resp, err := c.client.Echo(ctx, grpcReq)
+	p := &peer.Peer{}
+	resp, err := c.client.Echo(ctx, grpcReq, grpc.Peer(p))
 	if err != nil {
 		return "", err
 	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		writeTLSState(req.RequestID, info.State, &outBuffer)
+	}
 
 	// when the underlying HTTP2 request returns status 404, GRPC
 	// request does not return an error in grpc-go.
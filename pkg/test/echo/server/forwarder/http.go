@@ -15,13 +15,15 @@
 package forwarder
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"golang.org/x/net/http2"
 
@@ -29,11 +31,21 @@ import (
 	"istio.io/istio/pkg/test/echo/common/response"
 )
 
+// TransferEncodingHeader, if requested with a value of "chunked", streams the request body with chunked transfer
+// encoding rather than sending a Content-Length.
+const TransferEncodingHeader = "Transfer-Encoding"
+
+// ContentLengthHeader, if requested, sends the message as the body of the request even if its method does not take
+// one. The length is that of the message, whatever the value of the header.
+const ContentLengthHeader = "Content-Length"
+
 var _ protocol = &httpProtocol{}
 
 type httpProtocol struct {
 	client *http.Client
 	do     common.HTTPDoFunc
+	// traceFormats, if set, start a new trace for each request, sent in each of the formats.
+	traceFormats []common.TraceFormat
 }
 
 func (c *httpProtocol) setHost(r *http.Request, host string) {
@@ -52,12 +64,32 @@ func (c *httpProtocol) setHost(r *http.Request, host string) {
 	}
 }
 
+// sendsBody returns whether a request carries its message as the body: requests with a method that takes a body, and
+// requests that explicitly ask for one with a Content-Length or Transfer-Encoding header. Other requests, such as a
+// GET with the default message of the client, carry no body.
+func sendsBody(method string, header http.Header) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return header.Get(ContentLengthHeader) != "" || header.Get(TransferEncodingHeader) != ""
+}
+
 func (c *httpProtocol) makeRequest(ctx context.Context, req *request) (string, error) {
 	method := req.Method
 	if method == "" {
 		method = "GET"
 	}
-	httpReq, err := http.NewRequest(method, req.URL, nil)
+	var body io.Reader
+	if req.Message != "" && sendsBody(method, req.Header) {
+		// Encode the body as described by the Content-Encoding header, if any.
+		data, err := common.Compress(req.Header.Get("Content-Encoding"), []byte(req.Message))
+		if err != nil {
+			return "", err
+		}
+		body = bytes.NewReader(data)
+	}
+	httpReq, err := http.NewRequest(method, req.URL, body)
 	if err != nil {
 		return "", err
 	}
@@ -73,19 +105,39 @@ func (c *httpProtocol) makeRequest(ctx context.Context, req *request) (string, e
 	writeHeaders(req.RequestID, req.Header, outBuffer, func(key string, value string) {
 		if key == hostHeader {
 			host = value
-		} else {
+		} else if key == TransferEncodingHeader {
+			if value == "chunked" && httpReq.Body != nil {
+				// An unknown length makes the client stream the body in chunks.
+				httpReq.ContentLength = -1
+			}
+		} else if !common.IsTLSHeader(key) && key != common.TraceContextHeader {
 			httpReq.Header.Add(key, value)
 		}
 	})
 
 	c.setHost(httpReq, host)
 
+	if len(c.traceFormats) > 0 {
+		trace, err := common.NewTraceContext()
+		if err != nil {
+			return outBuffer.String(), err
+		}
+		for k, v := range trace.Headers(c.traceFormats) {
+			httpReq.Header[k] = v
+		}
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", req.RequestID, response.SentTraceIDField, trace.TraceID))
+	}
+
+	start := time.Now()
 	httpResp, err := c.do(c.client, httpReq)
 	if err != nil {
 		return outBuffer.String(), err
 	}
 
 	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.StatusCodeField, httpResp.StatusCode))
+	if httpResp.TLS != nil {
+		writeTLSState(req.RequestID, *httpResp.TLS, &outBuffer)
+	}
 
 	keys := []string{}
 	for k := range httpResp.Header {
@@ -99,7 +151,15 @@ func (c *httpProtocol) makeRequest(ctx context.Context, req *request) (string, e
 		}
 	}
 
-	data, err := ioutil.ReadAll(httpResp.Body)
+	if strings.HasPrefix(httpResp.Header.Get("Content-Type"), common.EventStreamContentType) {
+		err := readEvents(req.RequestID, start, httpResp.Body, &outBuffer)
+		_ = httpResp.Body.Close()
+		return outBuffer.String(), err
+	}
+
+	// Unless the request set Accept-Encoding, the transport requests gzip and decodes it transparently. Otherwise,
+	// decode the response as described by its Content-Encoding.
+	data, err := common.Decompress(httpResp.Header.Get("Content-Encoding"), httpResp.Body)
 	defer func() {
 		if err = httpResp.Body.Close(); err != nil {
 			outBuffer.WriteString(fmt.Sprintf("[%d error] %s\n", req.RequestID, err))
@@ -119,6 +179,71 @@ func (c *httpProtocol) makeRequest(ctx context.Context, req *request) (string, e
 	return outBuffer.String(), nil
 }
 
+// readEvents reads a stream of server-sent events, recording when each event is received relative to the start of
+// the request. Events are dispatched as specified by the HTML standard: at a blank line, if they have data, with the
+// last event ID seen. An event the stream ends before the blank line of is not dispatched.
+func readEvents(requestID int, start time.Time, body io.Reader, out *bytes.Buffer) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Split(scanEventLines)
+	id := ""
+	hasData := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// A blank line completes the event.
+			if hasData {
+				out.WriteString(fmt.Sprintf("[%d] %s=%s:%d\n", requestID, response.EventField, id, time.Since(start).Milliseconds()))
+			}
+			hasData = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// A comment, such as sent to keep the stream alive.
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				id = value
+			}
+		case "data":
+			// Lines of data are joined into the data of the event, which is not recorded.
+			hasData = true
+		}
+	}
+	return scanner.Err()
+}
+
+// scanEventLines splits an event stream into lines, which end with CRLF, LF or CR.
+func scanEventLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i, b := range data {
+		switch b {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// Wait for the next byte, to tell CRLF from CR.
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 func (c *httpProtocol) Close() error {
 	c.client.CloseIdleConnections()
 	return nil
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forwarder
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/common/response"
+)
+
+func TestSendsBody(t *testing.T) {
+	cases := []struct {
+		method string
+		header http.Header
+		want   bool
+	}{
+		{http.MethodGet, nil, false},
+		{http.MethodHead, nil, false},
+		{http.MethodDelete, nil, false},
+		{http.MethodPost, nil, true},
+		{http.MethodPut, nil, true},
+		{http.MethodPatch, nil, true},
+		{http.MethodGet, http.Header{ContentLengthHeader: {"10"}}, true},
+		{http.MethodGet, http.Header{TransferEncodingHeader: {"chunked"}}, true},
+	}
+	for _, tc := range cases {
+		if got := sendsBody(tc.method, tc.header); got != tc.want {
+			t.Errorf("sendsBody(%s, %v): got %v, want %v", tc.method, tc.header, got, tc.want)
+		}
+	}
+}
+
+func TestReadEvents(t *testing.T) {
+	eventRegex := regexp.MustCompile(`\[7\] ` + string(response.EventField) + `=(.*):[0-9]+\n`)
+	cases := []struct {
+		name   string
+		stream string
+		want   []string
+	}{
+		{"single", "id: 1\ndata: a\n\n", []string{"1"}},
+		{"multi-line data", "id: 1\ndata: a\ndata: b\n\nid: 2\ndata: c\n\n", []string{"1", "2"}},
+		{"comments", ": keepalive\n\nid: 1\n: note\ndata: a\n\n", []string{"1"}},
+		{"missing trailing blank line", "id: 1\ndata: a\n\nid: 2\ndata: b\n", []string{"1"}},
+		{"CRLF", "id: 1\r\ndata: a\r\n\r\nid: 2\r\ndata: b\r\n\r\n", []string{"1", "2"}},
+		{"CR", "id: 1\rdata: a\r\rid: 2\rdata: b\r\r", []string{"1", "2"}},
+		{"ID kept across events", "id: 1\ndata: a\n\ndata: b\n\n", []string{"1", "1"}},
+		{"no space after colon", "id:7\ndata:x\n\n", []string{"7"}},
+		{"no data", "id: 1\n\n", nil},
+		{"empty", "", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Reading a byte at a time splits line endings across reads.
+			for _, r := range []io.Reader{strings.NewReader(tc.stream), iotest.OneByteReader(strings.NewReader(tc.stream))} {
+				var out bytes.Buffer
+				if err := readEvents(7, time.Now(), r, &out); err != nil {
+					t.Fatal(err)
+				}
+				var got []string
+				for _, m := range eventRegex.FindAllStringSubmatch(out.String(), -1) {
+					got = append(got, m[1])
+				}
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Fatalf("got events %v, want %v, from output:\n%s", got, tc.want, out.String())
+				}
+			}
+		})
+	}
+}
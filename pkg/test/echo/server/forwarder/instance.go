@@ -97,11 +97,17 @@ func (i *Instance) Run(ctx context.Context) (*proto.ForwardEchoResponse, error)
 
 	sem := semaphore.NewWeighted(maxConcurrency)
 	for reqIndex := 0; reqIndex < i.count; reqIndex++ {
+		header := i.header
+		// Each request of the call gets its own request ID, so that the requests are traced and logged separately.
+		if id := header.Get(common.RequestIDHeader); id != "" && reqIndex > 0 {
+			header = header.Clone()
+			header.Set(common.RequestIDHeader, common.RequestIDForIndex(id, reqIndex))
+		}
 		r := request{
 			RequestID:   reqIndex,
 			URL:         i.url,
 			Message:     i.message,
-			Header:      i.header,
+			Header:      header,
 			Timeout:     i.timeout,
 			ServerFirst: i.serverFirst,
 			Method:      i.method,
@@ -121,9 +121,22 @@ func newProtocol(cfg Config) (protocol, error) {
 	} else {
 		tlsConfig.InsecureSkipVerify = true
 	}
+	tlsOptions, err := common.GetTLSOptions(headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := tlsOptions.Apply(tlsConfig); err != nil {
+		return nil, err
+	}
+	// TLS is used by GRPC and TCP calls when they have a client certificate or TLS options.
+	useTLS := getClientCertificate != nil || tlsOptions.IsSet()
 
 	switch scheme.Instance(u.Scheme) {
 	case scheme.HTTP, scheme.HTTPS:
+		traceFormats, err := common.GetTraceFormats(headers)
+		if err != nil {
+			return nil, err
+		}
 		proto := &httpProtocol{
 			client: &http.Client{
 				Transport: &http.Transport{
@@ -133,10 +146,13 @@ func newProtocol(cfg Config) (protocol, error) {
 					IdleConnTimeout: time.Second,
 					TLSClientConfig: tlsConfig,
 					DialContext:     httpDialContext,
+					// Sessions are only resumed by new connections.
+					DisableKeepAlives: tlsOptions.Resumption,
 				},
 				Timeout: timeout,
 			},
-			do: cfg.Dialer.HTTP,
+			do:           cfg.Dialer.HTTP,
+			traceFormats: traceFormats,
 		}
 		if cfg.Request.Http2 && scheme.Instance(u.Scheme) == scheme.HTTPS {
 			proto.client.Transport = &http2.Transport{
@@ -164,7 +180,7 @@ func newProtocol(cfg Config) (protocol, error) {
 
 		// transport security
 		security := grpc.WithInsecure()
-		if getClientCertificate != nil {
+		if useTLS {
 			security = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
 		}
 
@@ -195,20 +211,38 @@ func newProtocol(cfg Config) (protocol, error) {
 			dialer: dialer,
 		}, nil
 	case scheme.TCP:
+		socket, err := common.GetSocketOptions(headers)
+		if err != nil {
+			return nil, err
+		}
+		idle, err := common.GetIdle(headers)
+		if err != nil {
+			return nil, err
+		}
 		return &tcpProtocol{
+			idle: idle,
 			conn: func() (net.Conn, error) {
 				dialer := net.Dialer{
-					Timeout: timeout,
+					Timeout:   timeout,
+					KeepAlive: socket.KeepAlive,
 				}
 				address := rawURL[len(u.Scheme+"://"):]
 
 				ctx, cancel := context.WithTimeout(context.Background(), common.ConnectionTimeout)
 				defer cancel()
 
-				if getClientCertificate == nil {
-					return cfg.Dialer.TCP(dialer, ctx, address)
+				if !useTLS {
+					conn, err := cfg.Dialer.TCP(dialer, ctx, address)
+					if err != nil {
+						return nil, err
+					}
+					if err := socket.Apply(conn); err != nil {
+						_ = conn.Close()
+						return nil, err
+					}
+					return conn, nil
 				}
-				return tls.Dial("tcp", address, tlsConfig)
+				return tls.DialWithDialer(&dialer, "tcp", address, tlsConfig)
 
 			},
 		}, nil
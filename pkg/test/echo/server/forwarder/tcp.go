@@ -18,10 +18,12 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
 	"strings"
+	"time"
 
 	"istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/echo/common/response"
@@ -33,6 +35,8 @@ type tcpProtocol struct {
 	// conn returns a new connection. This is not just a shared connection as we will
 	// not re-use the connection for multiple requests with TCP
 	conn func() (net.Conn, error)
+	// idle, if set, keeps the connection open and idle after the response is received, for up to the given duration.
+	idle time.Duration
 }
 
 func (c *tcpProtocol) makeRequest(ctx context.Context, req *request) (string, error) {
@@ -106,14 +110,51 @@ func (c *tcpProtocol) makeRequest(ctx context.Context, req *request) (string, er
 		}
 	}
 
+	if tc, ok := conn.(*tls.Conn); ok {
+		var state bytes.Buffer
+		writeTLSState(req.RequestID, tc.ConnectionState(), &state)
+		msgBuilder.WriteString(state.String())
+	}
+
 	msg := msgBuilder.String()
 	expected := fmt.Sprintf("%s=%s", string(response.StatusCodeField), response.StatusCodeOK)
 	if !strings.Contains(msg, expected) {
 		return msg, fmt.Errorf("expect to recv message with %s, got %s. Return EOF", expected, msg)
 	}
+	if c.idle > 0 {
+		closedAfter, err := awaitClose(conn, c.idle)
+		if err != nil {
+			return msg, err
+		}
+		if closedAfter > 0 {
+			msg += fmt.Sprintf("[%d] %s=%d\n", req.RequestID, response.ClosedAfterField, closedAfter.Milliseconds())
+		}
+	}
 	return msg, nil
 }
 
+// awaitClose keeps the connection idle for up to the given duration, returning how long it took the peer to close
+// it, or zero if it was not closed.
+func awaitClose(conn net.Conn, idle time.Duration) (time.Duration, error) {
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(idle)); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1024)
+	for {
+		_, err := conn.Read(buf)
+		if err == nil {
+			// Data sent by the peer while idle is ignored.
+			continue
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return 0, nil
+		}
+		// Closed, or reset, by the peer.
+		return time.Since(start), nil
+	}
+}
+
 func (c *tcpProtocol) Close() error {
 	return nil
 }
@@ -16,10 +16,13 @@ package forwarder
 
 import (
 	"bytes"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/textproto"
 
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/response"
 	"istio.io/pkg/log"
 )
 
@@ -42,3 +45,13 @@ func writeHeaders(requestID int, header http.Header, outBuffer bytes.Buffer, add
 		}
 	}
 }
+
+// writeTLSState writes the parameters negotiated by a TLS connection.
+func writeTLSState(requestID int, state tls.ConnectionState, outBuffer *bytes.Buffer) {
+	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", requestID, response.TLSVersionField, common.TLSVersionName(state.Version)))
+	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", requestID, response.CipherSuiteField, common.CipherSuiteName(state.CipherSuite)))
+	if state.NegotiatedProtocol != "" {
+		outBuffer.WriteString(fmt.Sprintf("[%d] %s=%s\n", requestID, response.ALPNField, state.NegotiatedProtocol))
+	}
+	outBuffer.WriteString(fmt.Sprintf("[%d] %s=%t\n", requestID, response.TLSResumedField, state.DidResume))
+}
@@ -41,7 +41,10 @@ type Config struct {
 	Version   string
 	UDSServer string
 	Cluster   string
+	Locality  string
 	Dialer    common.Dialer
+	// Socket sets the options of the connections accepted on TCP ports, if set.
+	Socket *common.SocketOptions
 }
 
 var _ io.Closer = &Instance{}
@@ -116,9 +119,11 @@ func (s *Instance) newEndpoint(port *common.Port, udsServer string) (endpoint.In
 		IsServerReady: s.isReady,
 		Version:       s.Version,
 		Cluster:       s.Cluster,
+		Locality:      s.Locality,
 		TLSCert:       s.TLSCert,
 		TLSKey:        s.TLSKey,
 		Dialer:        s.Dialer,
+		Socket:        s.Socket,
 	})
 }
 
@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	"github.com/golang/protobuf/jsonpb"
+
+	// Import all XDS config types
+	_ "istio.io/istio/pkg/config/xds"
+)
+
+const (
+	// BootstrapConfigDumpType is the type URL of the bootstrap section of a config dump.
+	BootstrapConfigDumpType = "type.googleapis.com/envoy.admin.v3.BootstrapConfigDump"
+	// SecretsConfigDumpType is the type URL of the secrets section of a config dump.
+	SecretsConfigDumpType = "type.googleapis.com/envoy.admin.v3.SecretsConfigDump"
+	// ListenersConfigDumpType is the type URL of the listeners section of a config dump.
+	ListenersConfigDumpType = "type.googleapis.com/envoy.admin.v3.ListenersConfigDump"
+)
+
+var (
+	// volatileFields change between pushes, or between proxies with identical config, and are removed when sanitizing.
+	volatileFields = map[string]struct{}{
+		"version_info": {},
+		"last_updated": {},
+	}
+
+	// unorderedFields are lists in the config dump whose order is not meaningful, and are sorted when sanitizing.
+	unorderedFields = map[string]struct{}{
+		"static_listeners":         {},
+		"dynamic_listeners":        {},
+		"static_clusters":          {},
+		"dynamic_active_clusters":  {},
+		"dynamic_warming_clusters": {},
+		"static_route_configs":     {},
+		"dynamic_route_configs":    {},
+	}
+)
+
+// FilterConfigDump returns a copy of the config dump without the sections of the given types.
+func FilterConfigDump(dump *envoyAdmin.ConfigDump, excludeTypes ...string) *envoyAdmin.ConfigDump {
+	out := &envoyAdmin.ConfigDump{}
+outer:
+	for _, c := range dump.Configs {
+		for _, t := range excludeTypes {
+			if c.TypeUrl == t {
+				continue outer
+			}
+		}
+		out.Configs = append(out.Configs, c)
+	}
+	return out
+}
+
+// SanitizeConfigDump converts the config dump to indented JSON that can be compared across proxies and runs. Each key
+// of replacements is replaced with its value, fields that change between pushes are removed, and lists whose order is
+// not meaningful are sorted. Replacements are typically used to substitute names that are unique to the proxy, such
+// as its namespace, pod name and IP, with placeholders. They are applied before sorting, so that the order of the
+// lists does not depend on the names they replace.
+func SanitizeConfigDump(dump *envoyAdmin.ConfigDump, replacements map[string]string) (string, error) {
+	js, err := (&jsonpb.Marshaler{OrigName: true}).MarshalToString(dump)
+	if err != nil {
+		return "", fmt.Errorf("failed marshaling config dump: %v", err)
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(replace(js, replacements)), &parsed); err != nil {
+		return "", fmt.Errorf("failed parsing config dump: %v", err)
+	}
+	parsed = sanitize(parsed)
+	out, err := json.MarshalIndent(parsed, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed marshaling sanitized config dump: %v", err)
+	}
+	return string(out), nil
+}
+
+// replace each key of replacements in s with its value.
+func replace(s string, replacements map[string]string) string {
+	// Replace longer strings first, so that a replacement is not broken by a shorter one it contains.
+	keys := make([]string, 0, len(replacements))
+	for k := range replacements {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	pairs := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k, replacements[k])
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+func sanitize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if _, f := volatileFields[k]; f {
+				delete(t, k)
+				continue
+			}
+			t[k] = sanitize(child)
+			if _, f := unorderedFields[k]; f {
+				sortList(t[k])
+			}
+		}
+		return t
+	case []interface{}:
+		for i, child := range t {
+			t[i] = sanitize(child)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+func sortList(v interface{}) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return
+	}
+	keys := make([]string, len(list))
+	for i, item := range list {
+		b, _ := json.Marshal(item)
+		keys[i] = string(b)
+	}
+	sort.Sort(byKey{list: list, keys: keys})
+}
+
+type byKey struct {
+	list []interface{}
+	keys []string
+}
+
+func (b byKey) Len() int           { return len(b.list) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] < b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.list[i], b.list[j] = b.list[j], b.list[i]
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+}
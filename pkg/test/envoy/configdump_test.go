@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoy
+
+import (
+	"strings"
+	"testing"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func listenersDump(t *testing.T, version string, names ...string) *envoyAdmin.ConfigDump {
+	t.Helper()
+	listeners := &envoyAdmin.ListenersConfigDump{VersionInfo: version}
+	for _, n := range names {
+		listeners.DynamicListeners = append(listeners.DynamicListeners,
+			&envoyAdmin.ListenersConfigDump_DynamicListener{Name: n})
+	}
+	a, err := ptypes.MarshalAny(listeners)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &envoyAdmin.ConfigDump{Configs: []*any.Any{a}}
+}
+
+func TestSanitizeConfigDump(t *testing.T) {
+	// The names sort differently before the replacements, which must not change the order of the result.
+	a, err := SanitizeConfigDump(listenersDump(t, "1", "10.0.0.9_80", "10.0.0.1_80"), map[string]string{
+		"10.0.0.9": "{{.ServiceIP.a}}",
+		"10.0.0.1": "{{.ServiceIP.b}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := SanitizeConfigDump(listenersDump(t, "2", "10.0.0.2_80", "10.0.0.3_80"), map[string]string{
+		"10.0.0.2": "{{.ServiceIP.a}}",
+		"10.0.0.3": "{{.ServiceIP.b}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatalf("expected identical sanitized config, got:\n%s\nand:\n%s", a, b)
+	}
+	if strings.Contains(a, "version_info") {
+		t.Fatalf("expected the version to be removed, got:\n%s", a)
+	}
+	if i, j := strings.Index(a, "{{.ServiceIP.a}}_80"), strings.Index(a, "{{.ServiceIP.b}}_80"); i < 0 || j < i {
+		t.Fatalf("expected the listeners to be sorted by their sanitized names, got:\n%s", a)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	got := replace("pod-a.ns-a ns-a", map[string]string{
+		"ns-a":  "{{.Namespace}}",
+		"pod-a": "{{.Pod}}",
+		// Longer strings are replaced first.
+		"pod-a.ns-a": "{{.ID}}",
+		"":           "ignored",
+	})
+	if want := "{{.ID}} {{.Namespace}}"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
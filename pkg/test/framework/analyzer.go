@@ -52,6 +52,7 @@ type suiteAnalyzer struct {
 	commonAnalyzer
 	envFactoryCalls    int
 	requiredEnvVersion string
+	requirements       []string
 }
 
 func newSuiteAnalyzer(testID string, fn mRunFn, osExit func(int)) Suite {
@@ -103,6 +104,26 @@ func (s *suiteAnalyzer) RequireEnvironmentVersion(version string) Suite {
 	return s
 }
 
+func (s *suiteAnalyzer) RequireK8sVersion(minor int) Suite {
+	s.requiredEnvVersion = fmt.Sprintf("1.%d", minor)
+	return s
+}
+
+func (s *suiteAnalyzer) RequireMultiNetwork() Suite {
+	s.requirements = append(s.requirements, "multiNetwork")
+	return s
+}
+
+func (s *suiteAnalyzer) RequireLoadBalancer() Suite {
+	s.requirements = append(s.requirements, "loadBalancer")
+	return s
+}
+
+func (s *suiteAnalyzer) RequireArch(archs ...string) Suite {
+	s.requirements = append(s.requirements, "arch="+strings.Join(archs, ","))
+	return s
+}
+
 func (s *suiteAnalyzer) Setup(fn resource.SetupFn) Suite {
 	// TODO track setup fns?
 	return s
@@ -130,6 +151,7 @@ func (s *suiteAnalyzer) track() *suiteAnalysis {
 		Labels:           s.labels.All(),
 		MultiCluster:     s.maxClusters != 1,
 		MultiClusterOnly: s.minCusters > 1,
+		Requirements:     s.requirements,
 		Tests:            map[string]*testAnalysis{},
 	}
 }
@@ -206,6 +228,10 @@ func (t *testAnalyzer) RequiresSingleCluster() Test {
 	return t
 }
 
+func (t *testAnalyzer) RequiresNodeAccess() Test {
+	return t
+}
+
 func (t *testAnalyzer) Run(_ func(ctx TestContext)) {
 	defer t.track()
 	if t.hasRun {
@@ -94,6 +94,7 @@ type suiteAnalysis struct {
 	Labels           []label.Instance         `yaml:"labels,omitempty"`
 	MultiCluster     bool                     `yaml:"multicluster,omitempty"`
 	MultiClusterOnly bool                     `yaml:"multiclusterOnly,omitempty"`
+	Requirements     []string                 `yaml:"requirements,omitempty"`
 	Tests            map[string]*testAnalysis `yaml:"tests"`
 }
 
@@ -0,0 +1,417 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apiaudit records the Kubernetes API calls made by the framework, once enabled with
+// --istio.test.auditAPI, so that pathological call patterns, such as tight poll loops, show up in a summary per test,
+// and the permissions needed to run a suite can be derived from the calls it made.
+package apiaudit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v2"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Record of an API call.
+type Record struct {
+	// Server is the URL of the API server of the cluster called.
+	Server      string
+	Verb        string
+	Group       string
+	Version     string
+	Resource    string
+	Subresource string
+	Namespace   string
+	// Name of the object, empty for collections.
+	Name string
+	// NonResourceURL is the path of calls not to resources, such as /version.
+	NonResourceURL string
+	Latency        time.Duration
+	// Code is the HTTP status of the response, or 0 if the call failed without one.
+	Code int
+}
+
+var (
+	enabled int32
+
+	mu sync.Mutex
+	// records made since the oldest mark still held, the first of which is at position offset. Records are only
+	// retained while marks are held, so that memory does not grow with the length of the suite.
+	records []Record
+	offset  int
+	// held counts the marks held at each position.
+	held = map[int]int{}
+	// totals summarizes all the calls recorded, and distinct holds each distinct call, with its latency and code
+	// cleared, so that they outlive the records.
+	totals   = map[string]*Summary{}
+	distinct = map[Record]bool{}
+)
+
+// Enable recording of the calls made through transports returned by Transport.
+func Enable() {
+	atomic.StoreInt32(&enabled, 1)
+}
+
+// Enabled returns whether calls are recorded.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// Transport returns a wrapper of the transport of the clients of the API server, for rest.Config.Wrap, recording the
+// calls made through it while recording is enabled.
+func Transport(server string) func(http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &transport{server: server, delegate: rt}
+	}
+}
+
+type transport struct {
+	server   string
+	delegate http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Enabled() {
+		return t.delegate.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.delegate.RoundTrip(req)
+	r := parse(req)
+	r.Server = t.server
+	r.Latency = time.Since(start)
+	if err == nil {
+		r.Code = resp.StatusCode
+	}
+	add(r)
+	return resp, err
+}
+
+func add(r Record) {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(held) > 0 {
+		records = append(records, r)
+	}
+	summarize(totals, r)
+	r.Latency = 0
+	r.Code = 0
+	distinct[r] = true
+}
+
+// parse the request into the verb and resource it calls, as the API server does for authorization.
+func parse(req *http.Request) Record {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	r := Record{}
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		r.Version = parts[1]
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		r.Group = parts[1]
+		r.Version = parts[2]
+		parts = parts[3:]
+	default:
+		r.NonResourceURL = req.URL.Path
+		r.Verb = strings.ToLower(req.Method)
+		return r
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		r.Namespace = parts[1]
+		parts = parts[2:]
+	}
+	switch len(parts) {
+	case 0:
+		// Discovery of the group version.
+		r.NonResourceURL = req.URL.Path
+		r.Verb = strings.ToLower(req.Method)
+		return r
+	case 1:
+		r.Resource = parts[0]
+	case 2:
+		r.Resource = parts[0]
+		r.Name = parts[1]
+	default:
+		r.Resource = parts[0]
+		r.Name = parts[1]
+		r.Subresource = parts[2]
+	}
+	if r.Resource == "namespaces" && r.Namespace == "" && r.Name != "" && r.Subresource == "" {
+		r.Namespace = r.Name
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1":
+			r.Verb = "watch"
+		case r.Name == "":
+			r.Verb = "list"
+		default:
+			r.Verb = "get"
+		}
+	case http.MethodPost:
+		r.Verb = "create"
+	case http.MethodPut:
+		r.Verb = "update"
+	case http.MethodPatch:
+		r.Verb = "patch"
+	case http.MethodDelete:
+		if r.Name == "" {
+			r.Verb = "deletecollection"
+		} else {
+			r.Verb = "delete"
+		}
+	default:
+		r.Verb = strings.ToLower(req.Method)
+	}
+	return r
+}
+
+// Mark returns the position of the next call to be recorded, for Since. Calls are retained from the mark until it is
+// released with Release.
+func Mark() int {
+	mu.Lock()
+	defer mu.Unlock()
+	pos := offset + len(records)
+	held[pos]++
+	return pos
+}
+
+// Since returns the calls recorded since the mark, which must be held. Calls made concurrently, such as by tests
+// running in parallel, are included.
+func Since(mark int) []Record {
+	mu.Lock()
+	defer mu.Unlock()
+	if mark < offset || mark > offset+len(records) {
+		return nil
+	}
+	return append([]Record{}, records[mark-offset:]...)
+}
+
+// Release the mark, discarding the calls no longer retained by any mark.
+func Release(mark int) {
+	mu.Lock()
+	defer mu.Unlock()
+	if held[mark]--; held[mark] <= 0 {
+		delete(held, mark)
+	}
+	end := offset + len(records)
+	oldest := end
+	for m := range held {
+		if m < oldest {
+			oldest = m
+		}
+	}
+	if oldest > offset {
+		records = append([]Record(nil), records[oldest-offset:]...)
+		offset = oldest
+	}
+}
+
+// Totals summarizes all the calls recorded, most frequent first.
+func Totals() []Summary {
+	mu.Lock()
+	defer mu.Unlock()
+	return sorted(totals)
+}
+
+// Distinct returns each distinct call recorded, such as for deriving the permissions of a suite, without latencies
+// or codes.
+func Distinct() []Record {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]Record, 0, len(distinct))
+	for r := range distinct {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return fmt.Sprintf("%+v", out[i]) < fmt.Sprintf("%+v", out[j])
+	})
+	return out
+}
+
+// Summary aggregates the calls of a verb to a resource.
+type Summary struct {
+	Verb     string
+	Resource string
+	Calls    int
+	// Errors are the calls that failed, or were answered with an error status.
+	Errors       int
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// Summarize the calls, most frequent first.
+func Summarize(recs []Record) []Summary {
+	byKey := map[string]*Summary{}
+	for _, r := range recs {
+		summarize(byKey, r)
+	}
+	return sorted(byKey)
+}
+
+// summarize adds the call to the summary of its verb and resource.
+func summarize(byKey map[string]*Summary, r Record) {
+	res := r.NonResourceURL
+	if res == "" {
+		res = r.Resource
+		if r.Subresource != "" {
+			res += "/" + r.Subresource
+		}
+		if r.Group != "" {
+			res += "." + r.Group
+		}
+	}
+	key := r.Verb + " " + res
+	s, ok := byKey[key]
+	if !ok {
+		s = &Summary{Verb: r.Verb, Resource: res}
+		byKey[key] = s
+	}
+	s.Calls++
+	if r.Code == 0 || r.Code >= 400 {
+		s.Errors++
+	}
+	s.TotalLatency += r.Latency
+	if r.Latency > s.MaxLatency {
+		s.MaxLatency = r.Latency
+	}
+}
+
+func sorted(byKey map[string]*Summary) []Summary {
+	out := make([]Summary, 0, len(byKey))
+	for _, s := range byKey {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Calls != out[j].Calls {
+			return out[i].Calls > out[j].Calls
+		}
+		return out[i].Verb+out[i].Resource < out[j].Verb+out[j].Resource
+	})
+	return out
+}
+
+// Format the summaries as a table.
+func Format(summaries []Summary) string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "VERB\tRESOURCE\tCALLS\tERRORS\tMEAN LATENCY\tMAX LATENCY")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%v\t%v\n", s.Verb, s.Resource, s.Calls, s.Errors,
+			(s.TotalLatency / time.Duration(s.Calls)).Round(time.Millisecond), s.MaxLatency.Round(time.Millisecond))
+	}
+	_ = w.Flush()
+	return b.String()
+}
+
+// Write the calls to the file as YAML, such as for deriving the permissions of a suite later.
+func Write(file string, recs []Record) error {
+	by, err := yaml.Marshal(recs)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, by, 0644)
+}
+
+// Load the calls written to the file by Write.
+func Load(file string) ([]Record, error) {
+	by, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var recs []Record
+	if err := yaml.Unmarshal(by, &recs); err != nil {
+		return nil, fmt.Errorf("failed parsing API calls in %s: %v", file, err)
+	}
+	return recs, nil
+}
+
+// Rules returns the RBAC rules granting exactly the calls, such as for a ClusterRole to run a suite without
+// cluster-admin. Resources of a group called with the same verbs share a rule. Failed calls are included, as they may
+// have failed for lack of permissions.
+func Rules(recs []Record) []rbacv1.PolicyRule {
+	// verbs by group, then by resource.
+	verbs := map[string]map[string]map[string]bool{}
+	groups := map[string]bool{}
+	nonResource := map[string]map[string]bool{}
+	urls := map[string]bool{}
+	for _, r := range recs {
+		if r.NonResourceURL != "" {
+			if nonResource[r.NonResourceURL] == nil {
+				nonResource[r.NonResourceURL] = map[string]bool{}
+				urls[r.NonResourceURL] = true
+			}
+			nonResource[r.NonResourceURL][r.Verb] = true
+			continue
+		}
+		res := r.Resource
+		if r.Subresource != "" {
+			res += "/" + r.Subresource
+		}
+		if verbs[r.Group] == nil {
+			verbs[r.Group] = map[string]map[string]bool{}
+			groups[r.Group] = true
+		}
+		if verbs[r.Group][res] == nil {
+			verbs[r.Group][res] = map[string]bool{}
+		}
+		verbs[r.Group][res][r.Verb] = true
+	}
+
+	var out []rbacv1.PolicyRule
+	for _, group := range sortedSet(groups) {
+		// Resources by their sorted verbs.
+		byVerbs := map[string][]string{}
+		verbSets := map[string]bool{}
+		for res, vs := range verbs[group] {
+			key := strings.Join(sortedSet(vs), ",")
+			byVerbs[key] = append(byVerbs[key], res)
+			verbSets[key] = true
+		}
+		for _, key := range sortedSet(verbSets) {
+			resources := byVerbs[key]
+			sort.Strings(resources)
+			out = append(out, rbacv1.PolicyRule{
+				APIGroups: []string{group},
+				Resources: resources,
+				Verbs:     strings.Split(key, ","),
+			})
+		}
+	}
+	for _, url := range sortedSet(urls) {
+		out = append(out, rbacv1.PolicyRule{
+			NonResourceURLs: []string{url},
+			Verbs:           sortedSet(nonResource[url]),
+		})
+	}
+	return out
+}
+
+func sortedSet(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,154 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiaudit
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		method string
+		url    string
+		want   Record
+	}{
+		{"GET", "/api/v1/namespaces/ns/pods", Record{Verb: "list", Version: "v1", Namespace: "ns", Resource: "pods"}},
+		{"GET", "/api/v1/namespaces/ns/pods?watch=true",
+			Record{Verb: "watch", Version: "v1", Namespace: "ns", Resource: "pods"}},
+		{"POST", "/api/v1/namespaces/ns/pods/a/exec",
+			Record{Verb: "create", Version: "v1", Namespace: "ns", Resource: "pods", Name: "a", Subresource: "exec"}},
+		{"PATCH", "/apis/networking.istio.io/v1alpha3/namespaces/ns/virtualservices/a", Record{Verb: "patch",
+			Group: "networking.istio.io", Version: "v1alpha3", Namespace: "ns", Resource: "virtualservices", Name: "a"}},
+		{"DELETE", "/api/v1/namespaces/ns", Record{Verb: "delete", Version: "v1", Namespace: "ns", Resource: "namespaces",
+			Name: "ns"}},
+		{"GET", "/apis/apiextensions.k8s.io/v1/customresourcedefinitions/a", Record{Verb: "get",
+			Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions", Name: "a"}},
+		{"GET", "/version", Record{Verb: "get", NonResourceURL: "/version"}},
+	}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.url, func(t *testing.T) {
+			if got := parse(httptest.NewRequest(c.method, c.url, nil)); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	recs := []Record{
+		{Verb: "list", Version: "v1", Resource: "pods"},
+		{Verb: "get", Version: "v1", Resource: "pods"},
+		{Verb: "get", Version: "v1", Resource: "services"},
+		{Verb: "list", Version: "v1", Resource: "services"},
+		{Verb: "create", Version: "v1", Resource: "pods", Subresource: "exec"},
+		{Verb: "patch", Group: "networking.istio.io", Version: "v1alpha3", Resource: "virtualservices"},
+		{Verb: "get", NonResourceURL: "/version"},
+	}
+	want := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods/exec"}, Verbs: []string{"create"}},
+		{APIGroups: []string{""}, Resources: []string{"pods", "services"}, Verbs: []string{"get", "list"}},
+		{APIGroups: []string{"networking.istio.io"}, Resources: []string{"virtualservices"}, Verbs: []string{"patch"}},
+		{NonResourceURLs: []string{"/version"}, Verbs: []string{"get"}},
+	}
+	if got := Rules(recs); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	records = nil
+	offset = 0
+	held = map[int]int{}
+	totals = map[string]*Summary{}
+	distinct = map[Record]bool{}
+}
+
+func TestMarks(t *testing.T) {
+	reset()
+	defer reset()
+	get := Record{Verb: "get", Version: "v1", Resource: "pods", Code: 200}
+	list := Record{Verb: "list", Version: "v1", Resource: "pods", Code: 200}
+
+	// Calls made while no mark is held are not retained.
+	add(get)
+	if len(records) != 0 {
+		t.Fatalf("retained %d records with no mark held", len(records))
+	}
+
+	outer := Mark()
+	add(get)
+	inner := Mark()
+	add(list)
+	if got := Since(outer); !reflect.DeepEqual(got, []Record{get, list}) {
+		t.Fatalf("since outer: got %+v", got)
+	}
+	if got := Since(inner); !reflect.DeepEqual(got, []Record{list}) {
+		t.Fatalf("since inner: got %+v", got)
+	}
+
+	// Releasing the inner mark keeps the calls of the outer one.
+	Release(inner)
+	if got := Since(outer); !reflect.DeepEqual(got, []Record{get, list}) {
+		t.Fatalf("since outer after releasing inner: got %+v", got)
+	}
+
+	// Releasing the outer mark first keeps only the calls of the inner one.
+	inner = Mark()
+	add(get)
+	Release(outer)
+	if len(records) != 1 || offset != inner {
+		t.Fatalf("got %d records at %d, want 1 at %d", len(records), offset, inner)
+	}
+	if got := Since(inner); !reflect.DeepEqual(got, []Record{get}) {
+		t.Fatalf("since inner after releasing outer: got %+v", got)
+	}
+
+	Release(inner)
+	if len(records) != 0 || len(held) != 0 {
+		t.Fatalf("got %d records and %d marks after releasing all marks", len(records), len(held))
+	}
+	if got := Since(inner); got != nil {
+		t.Fatalf("since released mark: got %+v", got)
+	}
+}
+
+func TestTotalsAndDistinct(t *testing.T) {
+	reset()
+	defer reset()
+	add(Record{Verb: "get", Version: "v1", Resource: "pods", Code: 200, Latency: 2})
+	m := Mark()
+	add(Record{Verb: "get", Version: "v1", Resource: "pods", Code: 404, Latency: 3})
+	add(Record{Verb: "list", Version: "v1", Resource: "services", Code: 200, Latency: 1})
+	Release(m)
+
+	got := Totals()
+	if len(got) != 2 || got[0].Verb != "get" || got[0].Calls != 2 || got[0].Errors != 1 ||
+		got[0].TotalLatency != 5 || got[0].MaxLatency != 3 {
+		t.Fatalf("got totals %+v", got)
+	}
+	want := []Record{
+		{Verb: "get", Version: "v1", Resource: "pods"},
+		{Verb: "list", Version: "v1", Resource: "services"},
+	}
+	if got := Distinct(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got distinct %+v, want %+v", got, want)
+	}
+}
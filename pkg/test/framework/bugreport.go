@@ -0,0 +1,82 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+const bugReportTimeout = "10m"
+
+// captureBugReports runs istioctl bug-report against every cluster, leaving an archive per cluster in the working
+// directory of the suite. bug-report writes its archive to its current directory and may exit the process on
+// failure, so it is run as a separate istioctl process in the directory of each cluster.
+func captureBugReports(ctx resource.Context) {
+	if ctx.Environment() == nil {
+		return
+	}
+	systemNamespace := istio.DefaultSystemNamespace
+	if i, err := istio.Get(ctx); err == nil {
+		systemNamespace = i.Settings().SystemNamespace
+	}
+	istioctl := istioctlBinary()
+
+	for _, c := range ctx.Clusters() {
+		kc, ok := c.(kube.Cluster)
+		if !ok {
+			continue
+		}
+		dir, err := ctx.CreateDirectory("bug-report-" + c.Name())
+		if err != nil {
+			scopes.CI.Errorf("failed creating bug report directory for cluster %s: %v", c.Name(), err)
+			continue
+		}
+		scopes.CI.Infof("capturing bug report of cluster %s to %s", c.Name(), dir)
+		if out, err := bugReportCommand(istioctl, kc.Filename(), systemNamespace, dir).CombinedOutput(); err != nil {
+			scopes.CI.Errorf("bug report of cluster %s failed: %v: %s", c.Name(), err, out)
+			continue
+		}
+		scopes.CI.Infof("bug report of cluster %s written to %s", c.Name(), filepath.Join(dir, "bug-report.tgz"))
+	}
+}
+
+// bugReportCommand returns the istioctl bug-report command for the cluster of the kubeconfig, run in dir.
+func bugReportCommand(istioctl, kubeconfig, systemNamespace, dir string) *exec.Cmd {
+	cmd := exec.Command(istioctl, "bug-report",
+		"--kubeconfig", kubeconfig,
+		"--istio-namespace", systemNamespace,
+		"--timeout", bugReportTimeout,
+		"--dir", dir,
+	)
+	cmd.Dir = dir
+	return cmd
+}
+
+// istioctlBinary returns the istioctl built in the local output directory, if any, and the one on the path otherwise.
+func istioctlBinary() string {
+	if env.LocalOut != "" {
+		if local := filepath.Join(env.LocalOut, "istioctl"); env.CheckFileExists(local) == nil {
+			return local
+		}
+	}
+	return "istioctl"
+}
@@ -0,0 +1,84 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func TestBugReportCommand(t *testing.T) {
+	cmd := bugReportCommand("/out/istioctl", "/kube/config", "istio-system", "/work/bug-report-cluster-0")
+	want := []string{
+		"/out/istioctl", "bug-report",
+		"--kubeconfig", "/kube/config",
+		"--istio-namespace", "istio-system",
+		"--timeout", bugReportTimeout,
+		"--dir", "/work/bug-report-cluster-0",
+	}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("got args %v, want %v", cmd.Args, want)
+	}
+	if cmd.Dir != "/work/bug-report-cluster-0" {
+		t.Errorf("got dir %q, want the report directory", cmd.Dir)
+	}
+}
+
+func TestIstioctlBinary(t *testing.T) {
+	defer func(out string) { env.LocalOut = out }(env.LocalOut)
+
+	env.LocalOut = ""
+	if got := istioctlBinary(); got != "istioctl" {
+		t.Errorf("without a local output directory got %q, want istioctl", got)
+	}
+
+	env.LocalOut = t.TempDir()
+	if got := istioctlBinary(); got != "istioctl" {
+		t.Errorf("without a local istioctl got %q, want istioctl", got)
+	}
+
+	local := filepath.Join(env.LocalOut, "istioctl")
+	if err := ioutil.WriteFile(local, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := istioctlBinary(); got != local {
+		t.Errorf("got %q, want %q", got, local)
+	}
+}
+
+func TestCaptureBugReportsSkipsNonKubeClusters(t *testing.T) {
+	s := resource.DefaultSettings()
+	s.TestID = "bugreport"
+	s.BaseDir = t.TempDir()
+	ctx, err := newSuiteContext(s, newFakeEnvironmentFactory(2), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	captureBugReports(ctx)
+
+	dirs, err := filepath.Glob(filepath.Join(ctx.workDir, "bug-report-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 0 {
+		t.Errorf("got bug report directories %v for clusters that are not Kubernetes clusters", dirs)
+	}
+}
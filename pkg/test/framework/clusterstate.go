@@ -0,0 +1,173 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// clusterState is the global state of the clusters that suites must leave as they found it: the names of their CRDs,
+// webhook configurations, and cluster-scoped Istio resources, keyed by cluster and kind.
+type clusterState map[string][]string
+
+// retainedCRDGroups are the groups of the CRDs that the framework deliberately leaves installed when it removes
+// Istio, such as the Istio and Gateway API CRDs, so that they are not reported as leaked.
+var retainedCRDGroups = []string{"istio.io", "x-k8s.io"}
+
+// retainedCRD returns whether the CRD of the group is left installed by the framework.
+func retainedCRD(group string) bool {
+	for _, g := range retainedCRDGroups {
+		if group == g || strings.HasSuffix(group, "."+g) {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotClusterState records the global state of the clusters of the context.
+func snapshotClusterState(ctx resource.Context) (clusterState, error) {
+	state := clusterState{}
+	if ctx.Environment() == nil {
+		return state, nil
+	}
+	for _, c := range ctx.Clusters() {
+		add := func(kind string, names []string) {
+			sort.Strings(names)
+			state[c.Name()+"/"+kind] = names
+		}
+		crds, err := c.Ext().ApiextensionsV1().CustomResourceDefinitions().List(context.TODO(), kubeApiMeta.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing CRDs of cluster %s: %v", c.Name(), err)
+		}
+		var names []string
+		for _, crd := range crds.Items {
+			if retainedCRD(crd.Spec.Group) {
+				continue
+			}
+			names = append(names, crd.Name)
+		}
+		add("CustomResourceDefinition", names)
+
+		mutating, err := c.AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.TODO(),
+			kubeApiMeta.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing webhooks of cluster %s: %v", c.Name(), err)
+		}
+		names = nil
+		for _, wh := range mutating.Items {
+			names = append(names, wh.Name)
+		}
+		add("MutatingWebhookConfiguration", names)
+
+		validating, err := c.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(context.TODO(),
+			kubeApiMeta.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing webhooks of cluster %s: %v", c.Name(), err)
+		}
+		names = nil
+		for _, wh := range validating.Items {
+			names = append(names, wh.Name)
+		}
+		add("ValidatingWebhookConfiguration", names)
+
+		// Discovery fails partially when an aggregated API, unrelated to Istio, is unavailable.
+		resources, err := c.Discovery().ServerPreferredResources()
+		if err != nil && len(resources) == 0 {
+			return nil, fmt.Errorf("failed discovering resources of cluster %s: %v", c.Name(), err)
+		}
+		for _, list := range resources {
+			gv, err := schema.ParseGroupVersion(list.GroupVersion)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasSuffix(gv.Group, "istio.io") {
+				continue
+			}
+			for _, r := range list.APIResources {
+				if r.Namespaced || strings.Contains(r.Name, "/") {
+					continue
+				}
+				objs, err := c.Dynamic().Resource(gv.WithResource(r.Name)).List(context.TODO(), kubeApiMeta.ListOptions{})
+				if err != nil {
+					return nil, fmt.Errorf("failed listing %s of cluster %s: %v", r.Name, c.Name(), err)
+				}
+				names = nil
+				for _, o := range objs.Items {
+					names = append(names, o.GetName())
+				}
+				add(r.Kind, names)
+			}
+		}
+	}
+	return state, nil
+}
+
+// diff returns the resources added to and removed from the state, or an empty string if there are none.
+func (s clusterState) diff(after clusterState) string {
+	var keys []string
+	for k := range s {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := s[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	out := &strings.Builder{}
+	for _, k := range keys {
+		before := map[string]bool{}
+		for _, n := range s[k] {
+			before[n] = true
+		}
+		for _, n := range after[k] {
+			if !before[n] {
+				fmt.Fprintf(out, "+ %s %s\n", k, n)
+			}
+			delete(before, n)
+		}
+		for _, n := range s[k] {
+			if before[n] {
+				fmt.Fprintf(out, "- %s %s\n", k, n)
+			}
+		}
+	}
+	return out.String()
+}
+
+// checkClusterState verifies that the clusters returned to the state recorded before the suite, waiting for
+// resources being deleted to be gone.
+func checkClusterState(ctx resource.Context, before clusterState) error {
+	return retry.UntilSuccess(func() error {
+		after, err := snapshotClusterState(ctx)
+		if err != nil {
+			return err
+		}
+		if d := before.diff(after); d != "" {
+			return fmt.Errorf("global state of the clusters leaked by the suite (+ added, - removed):\n%s", d)
+		}
+		return nil
+	}, retry.Timeout(time.Minute), retry.Delay(5*time.Second))
+}
@@ -0,0 +1,56 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"testing"
+)
+
+func TestRetainedCRD(t *testing.T) {
+	cases := map[string]bool{
+		"networking.istio.io":       true,
+		"istio.io":                  true,
+		"gateway.networking.k8s.io": false,
+		"networking.x-k8s.io":       true,
+		"cert-manager.io":           false,
+		"fooistio.io":               false,
+		"":                          false,
+	}
+	for group, want := range cases {
+		if got := retainedCRD(group); got != want {
+			t.Errorf("retainedCRD(%q) = %v, want %v", group, got, want)
+		}
+	}
+}
+
+func TestClusterStateDiff(t *testing.T) {
+	before := clusterState{
+		"a/CustomResourceDefinition":       {"x", "y"},
+		"a/MutatingWebhookConfiguration":   {"w"},
+		"a/ValidatingWebhookConfiguration": nil,
+	}
+	if d := before.diff(before); d != "" {
+		t.Fatalf("got diff of identical states:\n%s", d)
+	}
+	after := clusterState{
+		"a/CustomResourceDefinition":       {"x", "z"},
+		"a/MutatingWebhookConfiguration":   {"w"},
+		"a/ValidatingWebhookConfiguration": {"v"},
+	}
+	want := "- a/CustomResourceDefinition y\n+ a/CustomResourceDefinition z\n+ a/ValidatingWebhookConfiguration v\n"
+	if d := before.diff(after); d != want {
+		t.Fatalf("got diff:\n%s\nwant:\n%s", d, want)
+	}
+}
@@ -0,0 +1,323 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package abtest compares two control plane builds installed side by side in the same cluster. Identical config and
+// workloads are deployed against each revision, and the xDS generated for the workloads is diffed.
+package abtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/env"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/istioctl"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/image"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// Revision is a control plane build installed under its own revision.
+type Revision struct {
+	// Name of the revision. Required, and must differ between the two control planes.
+	Name string
+
+	// Hub and Tag of the control plane and proxy images. If unset, the images from the test settings are used.
+	Hub string
+	Tag string
+}
+
+// controlPlaneValues returns the IstioOperator spec that installs the revision. Only the baseline installs the shared
+// components, such as the gateways. Unset images are taken from the image settings.
+func (r Revision) controlPlaneValues(baseline bool, s *image.Settings) string {
+	hub, tag := r.Hub, r.Tag
+	if hub == "" {
+		hub = s.Hub
+	}
+	if tag == "" {
+		tag = s.Tag
+	}
+	var b strings.Builder
+	if !baseline {
+		b.WriteString("profile: empty\ncomponents:\n  pilot:\n    enabled: true\n")
+	}
+	fmt.Fprintf(&b, "revision: %s\nhub: %s\ntag: %s\n", r.Name, hub, tag)
+	return b.String()
+}
+
+// Setup returns a setup function that installs the baseline and candidate control planes. The baseline is installed
+// as the Istio component of the suite, the candidate is added next to it as a second revision of istiod only, and is
+// removed once the suite completes. The Istio component is deployed once, so the suite must not call istio.Setup.
+func Setup(baseline, candidate Revision) resource.SetupFn {
+	return func(ctx resource.Context) error {
+		if baseline.Name == "" || candidate.Name == "" || baseline.Name == candidate.Name {
+			return fmt.Errorf("baseline and candidate must have distinct revision names, got %q and %q",
+				baseline.Name, candidate.Name)
+		}
+		s, err := image.SettingsFromCommandLine()
+		if err != nil {
+			return err
+		}
+		var i istio.Instance
+		if err := istio.Setup(&i, func(_ resource.Context, cfg *istio.Config) {
+			// Set explicitly, since a tenant would otherwise override the revision of the spec.
+			cfg.Revision = baseline.Name
+			cfg.ControlPlaneValues = baseline.controlPlaneValues(true, s)
+		})(ctx); err != nil {
+			return fmt.Errorf("failed installing baseline revision %s: %v", baseline.Name, err)
+		}
+		if err := installCandidate(ctx, i.Settings(), candidate, s); err != nil {
+			return fmt.Errorf("failed installing candidate revision %s: %v", candidate.Name, err)
+		}
+		return nil
+	}
+}
+
+// candidateControlPlane is the istiod of the candidate revision, uninstalled when closed.
+type candidateControlPlane struct {
+	id       resource.ID
+	ctx      resource.Context
+	cluster  resource.Cluster
+	manifest string
+}
+
+func (c *candidateControlPlane) ID() resource.ID {
+	return c.id
+}
+
+func (c *candidateControlPlane) Close() error {
+	return c.ctx.Config(c.cluster).DeleteYAML("", c.manifest)
+}
+
+// installCandidate installs istiod of the candidate revision with the same defaults as the baseline.
+func installCandidate(ctx resource.Context, cfg istio.Config, candidate Revision, s *image.Settings) error {
+	cluster := ctx.Clusters().Default()
+	workDir, err := ctx.CreateTmpDirectory("abtest-" + candidate.Name)
+	if err != nil {
+		return err
+	}
+	iopFile := filepath.Join(workDir, "iop.yaml")
+	iop := "apiVersion: install.istio.io/v1alpha1\nkind: IstioOperator\nspec:\n" +
+		istio.Indent(candidate.controlPlaneValues(false, s), "  ")
+	if err := ioutil.WriteFile(iopFile, []byte(iop), os.ModePerm); err != nil {
+		return err
+	}
+	defaultsIOPFile := cfg.IOPFile
+	if !filepath.IsAbs(defaultsIOPFile) {
+		defaultsIOPFile = filepath.Join(env.IstioSrc, defaultsIOPFile)
+	}
+	args := []string{
+		"-f", defaultsIOPFile,
+		"-f", iopFile,
+		"--set", "values.global.imagePullPolicy=" + s.PullPolicy,
+		"--manifests", filepath.Join(env.IstioSrc, "manifests"),
+	}
+	if cfg.SystemNamespace != istio.DefaultSystemNamespace {
+		args = append(args,
+			"--set", "namespace="+cfg.SystemNamespace,
+			"--set", "values.global.istioNamespace="+cfg.SystemNamespace)
+	}
+	istioCtl, err := istioctl.New(ctx, istioctl.Config{Cluster: cluster})
+	if err != nil {
+		return err
+	}
+	manifest, _, err := istioCtl.Invoke(append([]string{"manifest", "generate"}, args...))
+	if err != nil {
+		return err
+	}
+	c := &candidateControlPlane{ctx: ctx, cluster: cluster, manifest: manifest}
+	c.id = ctx.TrackResource(c)
+	_, _, err = istioCtl.Invoke(append([]string{"install", "--skip-confirmation"}, args...))
+	return err
+}
+
+// Harness deploys identical config and workloads against both revisions.
+type Harness struct {
+	ctx       resource.Context
+	baseline  Revision
+	candidate Revision
+
+	// BaselineNamespace is injected by the baseline revision.
+	BaselineNamespace namespace.Instance
+	// CandidateNamespace is injected by the candidate revision.
+	CandidateNamespace namespace.Instance
+}
+
+// sidecarScope limits the proxies of a namespace to the services of the namespace. Both revisions watch every
+// namespace, so without it each workload would also receive the config and services of the other revision.
+const sidecarScope = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: default
+spec:
+  egress:
+  - hosts:
+    - "./*"
+`
+
+// New creates a namespace for each revision, injected by that revision only and scoped to itself with a Sidecar. The
+// revisions must have been installed with Setup.
+func New(ctx resource.Context, prefix string, baseline, candidate Revision) (*Harness, error) {
+	h := &Harness{
+		ctx:       ctx,
+		baseline:  baseline,
+		candidate: candidate,
+	}
+	var err error
+	h.BaselineNamespace, err = namespace.New(ctx, namespace.Config{
+		Prefix:   prefix + "-" + baseline.Name,
+		Inject:   true,
+		Revision: baseline.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.CandidateNamespace, err = namespace.New(ctx, namespace.Config{
+		Prefix:   prefix + "-" + candidate.Name,
+		Inject:   true,
+		Revision: candidate.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := h.ApplyConfig(sidecarScope); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// NewOrFail calls New and fails the test if it returns an error.
+func NewOrFail(t test.Failer, ctx resource.Context, prefix string, baseline, candidate Revision) *Harness {
+	t.Helper()
+	h, err := New(ctx, prefix, baseline, candidate)
+	if err != nil {
+		t.Fatalf("abtest.NewOrFail: %v", err)
+	}
+	return h
+}
+
+// ApplyConfig applies the config to the namespace of each revision. Namespaced resources in the config should not
+// specify a namespace, and references to other namespaces are not rewritten.
+func (h *Harness) ApplyConfig(yamlText ...string) error {
+	if err := h.ctx.Config().ApplyYAML(h.BaselineNamespace.Name(), yamlText...); err != nil {
+		return fmt.Errorf("failed applying config for revision %s: %v", h.baseline.Name, err)
+	}
+	if err := h.ctx.Config().ApplyYAML(h.CandidateNamespace.Name(), yamlText...); err != nil {
+		return fmt.Errorf("failed applying config for revision %s: %v", h.candidate.Name, err)
+	}
+	return nil
+}
+
+// ApplyConfigOrFail calls ApplyConfig and fails the test if it returns an error.
+func (h *Harness) ApplyConfigOrFail(t test.Failer, yamlText ...string) {
+	t.Helper()
+	if err := h.ApplyConfig(yamlText...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Pair is the same workload deployed against each revision.
+type Pair struct {
+	Baseline  echo.Instance
+	Candidate echo.Instance
+}
+
+// Deploy deploys each of the echo configs once in the namespace of each revision. The Namespace of the configs is
+// overridden.
+func (h *Harness) Deploy(configs ...echo.Config) ([]Pair, error) {
+	pairs := make([]Pair, len(configs))
+	builder := echoboot.NewBuilder(h.ctx)
+	for i, cfg := range configs {
+		b := cfg
+		b.Namespace = h.BaselineNamespace
+		c := cfg
+		c.Namespace = h.CandidateNamespace
+		builder = builder.With(&pairs[i].Baseline, b).With(&pairs[i].Candidate, c)
+	}
+	if _, err := builder.Build(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// DeployOrFail calls Deploy and fails the test if it returns an error.
+func (h *Harness) DeployOrFail(t test.Failer, configs ...echo.Config) []Pair {
+	t.Helper()
+	pairs, err := h.Deploy(configs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pairs
+}
+
+// Diff returns the differences between the xDS config received by the first workload of the baseline and candidate
+// instances, as sanitized by echo.SanitizedConfig. The addresses of the pair itself and of the peers, which the
+// instances of the pair may call, are replaced on each side by placeholders. An empty string is returned if the
+// config is identical.
+func (h *Harness) Diff(p Pair, peers ...Pair) (string, error) {
+	baselinePeers := []echo.Instance{p.Baseline}
+	candidatePeers := []echo.Instance{p.Candidate}
+	for _, peer := range peers {
+		if peer == p {
+			continue
+		}
+		baselinePeers = append(baselinePeers, peer.Baseline)
+		candidatePeers = append(candidatePeers, peer.Candidate)
+	}
+	baseline, err := echo.SanitizedConfig(p.Baseline, h.baseline.Name, baselinePeers...)
+	if err != nil {
+		return "", err
+	}
+	candidate, err := echo.SanitizedConfig(p.Candidate, h.candidate.Name, candidatePeers...)
+	if err != nil {
+		return "", err
+	}
+	return cmp.Diff(baseline, candidate), nil
+}
+
+// CheckNoDiff verifies that each of the pairs receives identical xDS config from both revisions. The pairs are peers
+// of each other.
+func (h *Harness) CheckNoDiff(pairs ...Pair) error {
+	var errs error
+	for _, p := range pairs {
+		diff, err := h.Diff(p, pairs...)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if diff != "" {
+			errs = multierror.Append(errs, fmt.Errorf("xDS for %s differs between revisions %s and %s (-%s +%s):\n%s",
+				p.Baseline.Config().Service, h.baseline.Name, h.candidate.Name, h.baseline.Name, h.candidate.Name, diff))
+		}
+	}
+	return errs
+}
+
+// CheckNoDiffOrFail calls CheckNoDiff and fails the test if it returns an error.
+func (h *Harness) CheckNoDiffOrFail(t test.Failer, pairs ...Pair) {
+	t.Helper()
+	if err := h.CheckNoDiff(pairs...); err != nil {
+		t.Fatal(err)
+	}
+}
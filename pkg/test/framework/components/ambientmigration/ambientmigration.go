@@ -0,0 +1,414 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ambientmigration migrates a namespace from sidecars to ambient mode while traffic flows between two of its
+// workloads, following the documented procedure: the namespace is labeled for ambient, which does not capture pods
+// that still have sidecars, the injection labels are removed, and the workloads are restarted, servers first, so that
+// they come up without sidecars and are captured by ztunnel. Requests are checked against an error budget, and the
+// identity of the client against an AuthorizationPolicy only allowing its principal, which is enforced by the sidecar
+// of the server before the migration and by ztunnel after it.
+//
+// The sidecar injector and control plane of this tree have no ambient data plane, so migrations are meant for
+// control planes installing ztunnel, and fail if none is running.
+package ambientmigration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeRetry "k8s.io/client-go/util/retry"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common/response"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// DataplaneModeLabel is the namespace label enrolling its pods in ambient mode.
+	DataplaneModeLabel = "istio.io/dataplane-mode"
+	ztunnelSelector    = "app=ztunnel"
+	proxyContainer     = "istio-proxy"
+	policyName         = "ambient-migration-identity"
+)
+
+// injectionLabels are the namespace labels removed so that restarted pods are not injected.
+var injectionLabels = []string{"istio-injection", "istio.io/rev"}
+
+// Options of a Migrate.
+type Options struct {
+	// From is the client sending traffic during the migration, and To the server. Both must be in the migrated
+	// namespace, and From must call To with Call.
+	From echo.Instance
+	To   echo.Instance
+	Call echo.CallOptions
+	// SystemNamespace in which ztunnel runs. Defaults to istio-system.
+	SystemNamespace string
+	// Concurrency is the number of concurrent traffic generators. Defaults to 2.
+	Concurrency int
+	// MaxFailureRate is the fraction of requests allowed to fail, or to get a response other than OK, during the
+	// migration. Defaults to none. Requests denied by the identity policy fail the migration regardless.
+	MaxFailureRate float64
+	// RolloutTimeout is how long each restart may take. Defaults to 5m.
+	RolloutTimeout time.Duration
+}
+
+func (o *Options) fillDefaults() {
+	if o.SystemNamespace == "" {
+		o.SystemNamespace = "istio-system"
+	}
+	if o.Concurrency == 0 {
+		o.Concurrency = 2
+	}
+	if o.RolloutTimeout == 0 {
+		o.RolloutTimeout = 5 * time.Minute
+	}
+}
+
+// errDenied is returned for requests denied by the AuthorizationPolicy checking the identity of the client.
+var errDenied = errors.New("request denied by the identity policy")
+
+// checkResponses returns an error unless every response is OK. Denied requests are reported with errDenied.
+func checkResponses(resp client.ParsedResponses) error {
+	return resp.Check(func(i int, r *client.ParsedResponse) error {
+		switch {
+		case r.Code == response.StatusCodeForbidden:
+			return fmt.Errorf("response[%d]: %w", i, errDenied)
+		case !r.IsOK():
+			return fmt.Errorf("response[%d] Status Code: %s", i, r.Code)
+		}
+		return nil
+	})
+}
+
+// StepResult is the traffic sent during a step of the migration.
+type StepResult struct {
+	Name     string
+	Duration time.Duration
+	Requests int
+	// Failures of the requests, including those that were denied.
+	Failures []error
+	// Denied requests were rejected by the AuthorizationPolicy, as the identity of the client was not preserved.
+	Denied int
+	// Abandoned requests could not be sent, as the pod of the client was being replaced. They are not counted as
+	// requests.
+	Abandoned int
+}
+
+func (r StepResult) String() string {
+	return fmt.Sprintf("%s: %d requests, %d failed, %d denied, %d abandoned, in %v", r.Name, r.Requests,
+		len(r.Failures), r.Denied, r.Abandoned, r.Duration)
+}
+
+// add records a request that was sent, with the error of its call.
+func (r *StepResult) add(err error) {
+	r.Requests++
+	if err == nil {
+		return
+	}
+	r.Failures = append(r.Failures, err)
+	if errors.Is(err, errDenied) {
+		r.Denied++
+	}
+}
+
+// Result of a Migrate.
+type Result struct {
+	Steps []StepResult
+}
+
+// Requests returns the number of requests sent during all the steps.
+func (r Result) Requests() int {
+	n := 0
+	for _, s := range r.Steps {
+		n += s.Requests
+	}
+	return n
+}
+
+// Failures returns the failed requests of all the steps.
+func (r Result) Failures() []error {
+	var out []error
+	for _, s := range r.Steps {
+		out = append(out, s.Failures...)
+	}
+	return out
+}
+
+// Denied returns the number of requests denied by the identity policy during all the steps.
+func (r Result) Denied() int {
+	n := 0
+	for _, s := range r.Steps {
+		n += s.Denied
+	}
+	return n
+}
+
+// check returns an error if the identity of the client was not preserved by every request, or if more requests
+// failed than the error budget allows.
+func (r Result) check(maxFailureRate float64) error {
+	if r.Requests() == 0 {
+		return fmt.Errorf("no requests were sent during the migration")
+	}
+	if denied := r.Denied(); denied > 0 {
+		return fmt.Errorf("%d requests were denied during the migration, so the identity of the client was not "+
+			"preserved (%v)", denied, r)
+	}
+	failures := r.Failures()
+	if rate := float64(len(failures)) / float64(r.Requests()); rate > maxFailureRate {
+		return fmt.Errorf("%.2f%% of requests failed during the migration, expected at most %.2f%% (%v): %v",
+			rate*100, maxFailureRate*100, r, failures[0])
+	}
+	return nil
+}
+
+func (r Result) String() string {
+	out := make([]string, 0, len(r.Steps))
+	for _, s := range r.Steps {
+		out = append(out, s.String())
+	}
+	return strings.Join(out, "; ")
+}
+
+type step struct {
+	name string
+	run  func() error
+	// replacesClient is set for steps replacing the pods of the client, from which requests can no longer be sent
+	// once they are gone.
+	replacesClient bool
+}
+
+// Migrate the namespace of the workloads from sidecars to ambient mode while traffic flows, and checks the error
+// budget and the identity of the client across the migration. The workloads of the client and server are refreshed
+// once restarted, and the AuthorizationPolicy checking the identity of the client is deleted on return.
+func Migrate(ctx resource.Context, opts Options) (result Result, err error) {
+	opts.fillDefaults()
+	c := opts.To.Config().Cluster
+	ns := opts.To.Config().Namespace.Name()
+	if opts.From.Config().Namespace.Name() != ns {
+		return Result{}, fmt.Errorf("the client and server must be in the migrated namespace %s", ns)
+	}
+	if _, err := kube.CheckPodsAreReady(kube.NewPodMustFetch(c, opts.SystemNamespace, ztunnelSelector)); err != nil {
+		return Result{}, fmt.Errorf("ambient mode requires ztunnel in %s of cluster %s: %v", opts.SystemNamespace,
+			c.Name(), err)
+	}
+	policy := identityPolicy(opts.From, opts.To)
+	if err := ctx.Config(c).ApplyYAML(ns, policy); err != nil {
+		return Result{}, err
+	}
+	defer func() {
+		if derr := ctx.Config(c).DeleteYAML(ns, policy); derr != nil && err == nil {
+			err = fmt.Errorf("failed deleting AuthorizationPolicy %s/%s: %v", ns, policyName, derr)
+		}
+	}()
+	// Check the identity of the client before the migration, so that failures are attributed to it.
+	if err := retry.UntilSuccess(func() error {
+		return call(opts)
+	}, retry.Timeout(time.Minute), retry.Delay(time.Second)); err != nil {
+		return Result{}, fmt.Errorf("client is not allowed by its identity before the migration: %v", err)
+	}
+
+	steps := []step{
+		{name: "label-ambient", run: func() error {
+			return updateLabels(c, ns, map[string]string{DataplaneModeLabel: "ambient"}, nil)
+		}},
+		{name: "remove-injection", run: func() error {
+			return updateLabels(c, ns, nil, injectionLabels)
+		}},
+		{name: "restart-server", run: func() error {
+			return restart(c, opts.To, opts.RolloutTimeout)
+		}},
+		{name: "restart-client", run: func() error {
+			return restart(c, opts.From, opts.RolloutTimeout)
+		}, replacesClient: true},
+	}
+	for _, s := range steps {
+		r, err := during(s, opts)
+		result.Steps = append(result.Steps, r)
+		if err != nil {
+			return result, fmt.Errorf("step %s of the migration failed: %v", s.name, err)
+		}
+	}
+	scopes.Framework.Infof("migration of %s to ambient: %v", ns, result)
+
+	for _, i := range []echo.Instance{opts.From, opts.To} {
+		if err := checkNoSidecar(c, i); err != nil {
+			return result, err
+		}
+	}
+	return result, result.check(opts.MaxFailureRate)
+}
+
+// MigrateOrFail calls Migrate and fails the test if it returns an error.
+func MigrateOrFail(t test.Failer, ctx resource.Context, opts Options) Result {
+	t.Helper()
+	r, err := Migrate(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// call makes the call of the client, returning an error unless every response is OK.
+func call(opts Options) error {
+	resp, err := opts.From.Call(opts.Call)
+	if err != nil {
+		return err
+	}
+	return checkResponses(resp)
+}
+
+// during runs the step while sending traffic.
+func during(s step, opts Options) (StepResult, error) {
+	var (
+		mu     sync.Mutex
+		result = StepResult{Name: s.name}
+		wg     sync.WaitGroup
+	)
+	start := time.Now()
+	done := make(chan struct{})
+	for n := 0; n < opts.Concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				workloads, err := opts.From.Workloads()
+				if err == nil {
+					err = call(opts)
+				}
+				if err != nil && s.replacesClient && len(workloads) > 0 && replaced(opts.From, workloads[0]) {
+					// The request was not sent from the pod of the client, which went away, so send the next ones
+					// from its replacement.
+					err = opts.From.Refresh()
+					mu.Lock()
+					result.Abandoned++
+					if err != nil {
+						result.Failures = append(result.Failures, err)
+					}
+					mu.Unlock()
+					continue
+				}
+				mu.Lock()
+				result.add(err)
+				mu.Unlock()
+			}
+		}()
+	}
+	err := s.run()
+	close(done)
+	wg.Wait()
+	result.Duration = time.Since(start)
+	return result, err
+}
+
+func updateLabels(c resource.Cluster, ns string, set map[string]string, remove []string) error {
+	return kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		n, err := c.CoreV1().Namespaces().Get(context.TODO(), ns, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if n.Labels == nil {
+			n.Labels = map[string]string{}
+		}
+		for k, v := range set {
+			n.Labels[k] = v
+		}
+		for _, k := range remove {
+			delete(n.Labels, k)
+		}
+		_, err = c.CoreV1().Namespaces().Update(context.TODO(), n, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+}
+
+// restart the deployments of the instance, and refresh its workloads.
+func restart(c resource.Cluster, i echo.Instance, timeout time.Duration) error {
+	if err := kube.RestartDeployments(c, i.Config().Namespace.Name(), "app="+i.Config().Service,
+		retry.Timeout(timeout)); err != nil {
+		return err
+	}
+	return i.Refresh()
+}
+
+// replaced returns true if the pod of the workload of the instance is being deleted or is gone.
+func replaced(i echo.Instance, w echo.Workload) bool {
+	pods, err := i.Config().Cluster.PodsForSelector(context.TODO(), i.Config().Namespace.Name(),
+		"app="+i.Config().Service)
+	if err != nil {
+		return false
+	}
+	for _, p := range pods.Items {
+		if p.Status.PodIP == w.Address() && p.DeletionTimestamp == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func checkNoSidecar(c resource.Cluster, i echo.Instance) error {
+	pods, err := c.PodsForSelector(context.TODO(), i.Config().Namespace.Name(), "app="+i.Config().Service)
+	if err != nil {
+		return err
+	}
+	for _, p := range pods.Items {
+		if p.DeletionTimestamp != nil {
+			continue
+		}
+		for _, container := range p.Spec.Containers {
+			if container.Name == proxyContainer {
+				return fmt.Errorf("pod %s/%s still has a sidecar after the migration", p.Namespace, p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// identityPolicy only allows the principal of the client to call the server. Its trust domain is matched with a
+// wildcard, so that the policy does not depend on the mesh configuration.
+func identityPolicy(from, to echo.Instance) string {
+	sa := "default"
+	if from.Config().ServiceAccount {
+		sa = from.Config().Service
+	}
+	return fmt.Sprintf(`
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: %s
+spec:
+  selector:
+    matchLabels:
+      app: %s
+  action: ALLOW
+  rules:
+  - from:
+    - source:
+        principals:
+        - "*/ns/%s/sa/%s"
+`, policyName, to.Config().Service, from.Config().Namespace.Name(), sa)
+}
@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ambientmigration
+
+import (
+	"errors"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common/response"
+)
+
+func responses(codes ...string) client.ParsedResponses {
+	out := make(client.ParsedResponses, 0, len(codes))
+	for _, c := range codes {
+		out = append(out, &client.ParsedResponse{Code: c})
+	}
+	return out
+}
+
+func TestCheckResponses(t *testing.T) {
+	cases := []struct {
+		name   string
+		resp   client.ParsedResponses
+		err    bool
+		denied bool
+	}{
+		{"ok", responses(response.StatusCodeOK, response.StatusCodeOK), false, false},
+		{"unavailable", responses(response.StatusCodeOK, response.StatusCodeUnavailable), true, false},
+		{"forbidden", responses(response.StatusCodeForbidden), true, true},
+		{"no responses", responses(), true, false},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkResponses(tt.resp)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, expected error: %v", err, tt.err)
+			}
+			if errors.Is(err, errDenied) != tt.denied {
+				t.Fatalf("got error %v, expected denied: %v", err, tt.denied)
+			}
+		})
+	}
+}
+
+func TestResultCheck(t *testing.T) {
+	unavailable := checkResponses(responses(response.StatusCodeUnavailable))
+	denied := checkResponses(responses(response.StatusCodeForbidden))
+	step := func(errs ...error) StepResult {
+		r := StepResult{Name: "step"}
+		for _, err := range errs {
+			r.add(err)
+		}
+		return r
+	}
+	cases := []struct {
+		name           string
+		steps          []StepResult
+		maxFailureRate float64
+		err            bool
+	}{
+		{"no requests", []StepResult{step()}, 1, true},
+		{"all ok", []StepResult{step(nil, nil), step(nil)}, 0, false},
+		{"unavailable over budget", []StepResult{step(nil, unavailable), step(nil)}, 0, true},
+		{"unavailable within budget", []StepResult{step(nil, unavailable), step(nil, nil)}, 0.25, false},
+		{"transport error over budget", []StepResult{step(errors.New("connection reset"))}, 0.5, true},
+		{"denied within budget", []StepResult{step(nil, nil, nil), step(denied)}, 0.5, true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Result{Steps: tt.steps}.check(tt.maxFailureRate)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, expected error: %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestStepResultAdd(t *testing.T) {
+	var r StepResult
+	r.add(nil)
+	r.add(checkResponses(responses(response.StatusCodeUnavailable)))
+	r.add(checkResponses(responses(response.StatusCodeForbidden)))
+	if r.Requests != 3 || len(r.Failures) != 2 || r.Denied != 1 {
+		t.Fatalf("got %d requests, %d failures, %d denied, expected 3, 2 and 1", r.Requests, len(r.Failures), r.Denied)
+	}
+}
@@ -0,0 +1,506 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package carotation rotates the root of the plugged-in Istio CA while workloads keep communicating, following the
+// root rotation runbook: the new root is first distributed alongside the old one, then the intermediate is reissued
+// under the new root, and finally the old root is removed.
+package carotation
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hashicorp/go-multierror"
+	kubeApiCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/cert/ca"
+	"istio.io/istio/pkg/test/envoy"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	testKube "istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/file"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Phase of the root rotation.
+type Phase int
+
+const (
+	// Initial issues workload certificates from the old intermediate, trusting only the old root.
+	Initial Phase = iota
+	// MultiRoot distributes a trust bundle with both roots, while still issuing from the old intermediate.
+	MultiRoot
+	// NewIntermediate issues workload certificates from an intermediate signed by the new root, trusting both roots.
+	NewIntermediate
+	// NewRoot removes the old root from the trust bundle.
+	NewRoot
+)
+
+// Phases of the rotation, in the order they are performed.
+var Phases = []Phase{Initial, MultiRoot, NewIntermediate, NewRoot}
+
+func (p Phase) String() string {
+	switch p {
+	case Initial:
+		return "initial"
+	case MultiRoot:
+		return "multi-root"
+	case NewIntermediate:
+		return "new-intermediate"
+	case NewRoot:
+		return "new-root"
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+const (
+	caSecretName   = "cacerts"
+	rootConfigMap  = "istio-ca-root-cert"
+	rootConfigKey  = "root-cert.pem"
+	rootSecretName = "ROOTCA"
+	certSecretName = "default"
+)
+
+// Config for New.
+type Config struct {
+	// SystemNamespace in which istiod runs and the cacerts secret is created. Required.
+	SystemNamespace string
+	// IstiodSelector selects the istiod deployments, which are restarted to load the CA. Defaults to "app=istiod".
+	IstiodSelector string
+	// Workloads whose certificates are migrated. They are restarted in each phase so that their trust bundle and
+	// certificate are reloaded, and refreshed once restarted. Required.
+	Workloads []echo.Instance
+	// Traffic is called continuously during each phase, as istiod and the workloads restart. Any error it returns
+	// fails the phase. CallOK returns one failing on any non-OK response. Optional.
+	Traffic func() error
+}
+
+// CallOK returns a Traffic function making the call from the source, which fails unless every response is OK, so that
+// requests rejected during the rotation, such as with a 503 on a TLS failure, fail the phase as well as failed calls.
+func CallOK(src echo.Instance, opts echo.CallOptions) func() error {
+	return func() error {
+		resp, err := src.Call(opts)
+		if err != nil {
+			return err
+		}
+		return resp.CheckOK()
+	}
+}
+
+// Harness performs the root rotation.
+type Harness struct {
+	ctx resource.Context
+	cfg Config
+
+	oldRoot, newRoot                 ca.Root
+	oldIntermediate, newIntermediate ca.Intermediate
+}
+
+// New generates the old and new roots and their intermediates.
+func New(ctx resource.Context, cfg Config) (*Harness, error) {
+	if cfg.SystemNamespace == "" || len(cfg.Workloads) == 0 {
+		return nil, fmt.Errorf("system namespace and workloads must be provided")
+	}
+	if cfg.IstiodSelector == "" {
+		cfg.IstiodSelector = "app=istiod"
+	}
+	workDir, err := ctx.CreateTmpDirectory("carotation")
+	if err != nil {
+		return nil, err
+	}
+	conf, err := ca.NewIstioConfig(cfg.SystemNamespace)
+	if err != nil {
+		return nil, err
+	}
+	h := &Harness{ctx: ctx, cfg: cfg}
+	for _, gen := range []struct {
+		name         string
+		root         *ca.Root
+		intermediate *ca.Intermediate
+	}{
+		{"old", &h.oldRoot, &h.oldIntermediate},
+		{"new", &h.newRoot, &h.newIntermediate},
+	} {
+		dir := filepath.Join(workDir, gen.name)
+		if err := os.Mkdir(dir, 0700); err != nil {
+			return nil, err
+		}
+		if *gen.root, err = ca.NewRoot(dir); err != nil {
+			return nil, fmt.Errorf("failed creating %s root CA: %v", gen.name, err)
+		}
+		if *gen.intermediate, err = ca.NewIntermediate(dir, conf, *gen.root); err != nil {
+			return nil, fmt.Errorf("failed creating %s intermediate CA: %v", gen.name, err)
+		}
+	}
+	return h, nil
+}
+
+// NewOrFail calls New and fails the test if it returns an error.
+func NewOrFail(t test.Failer, ctx resource.Context, cfg Config) *Harness {
+	t.Helper()
+	h, err := New(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// Roots returns the roots trusted in the phase.
+func (h *Harness) Roots(p Phase) []ca.Root {
+	switch p {
+	case Initial:
+		return []ca.Root{h.oldRoot}
+	case NewRoot:
+		return []ca.Root{h.newRoot}
+	}
+	return []ca.Root{h.oldRoot, h.newRoot}
+}
+
+// Intermediate returns the intermediate issuing workload certificates in the phase.
+func (h *Harness) Intermediate(p Phase) ca.Intermediate {
+	if p == Initial || p == MultiRoot {
+		return h.oldIntermediate
+	}
+	return h.newIntermediate
+}
+
+// Run performs each of the phases in order, verifying the trust bundles and workload certificates after each.
+func (h *Harness) Run(opts ...retry.Option) error {
+	for _, p := range Phases {
+		if err := h.Advance(p, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunOrFail calls Run and fails the test if it returns an error.
+func (h *Harness) RunOrFail(t test.Failer, opts ...retry.Option) {
+	t.Helper()
+	if err := h.Run(opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Advance configures the CA for the phase, restarts istiod and the workloads to load it, and verifies the result.
+// Traffic is sent throughout.
+func (h *Harness) Advance(p Phase, opts ...retry.Option) error {
+	scopes.Framework.Infof("Advancing CA rotation to phase %s", p)
+	stop := h.startTraffic()
+	err := h.advance(p, opts...)
+	if trafficErr := stop(); trafficErr != nil {
+		err = multierror.Append(err, fmt.Errorf("traffic failed: %v", trafficErr))
+	}
+	if err != nil {
+		return fmt.Errorf("CA rotation phase %s failed: %v", p, err)
+	}
+	return nil
+}
+
+// AdvanceOrFail calls Advance and fails the test if it returns an error.
+func (h *Harness) AdvanceOrFail(t test.Failer, p Phase, opts ...retry.Option) {
+	t.Helper()
+	if err := h.Advance(p, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (h *Harness) advance(p Phase, opts ...retry.Option) error {
+	secret, err := h.caSecret(p)
+	if err != nil {
+		return err
+	}
+	for _, c := range h.ctx.Clusters() {
+		secrets := c.CoreV1().Secrets(h.cfg.SystemNamespace)
+		if _, err := secrets.Update(context.TODO(), secret, kubeApiMeta.UpdateOptions{}); err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed updating CA secret in %s: %v", c.Name(), err)
+			}
+			if _, err := secrets.Create(context.TODO(), secret, kubeApiMeta.CreateOptions{}); err != nil {
+				return fmt.Errorf("failed creating CA secret in %s: %v", c.Name(), err)
+			}
+		}
+		if err := testKube.RestartDeployments(c, h.cfg.SystemNamespace, h.cfg.IstiodSelector, opts...); err != nil {
+			return fmt.Errorf("failed restarting istiod in %s: %v", c.Name(), err)
+		}
+	}
+	for _, i := range h.cfg.Workloads {
+		c := i.Config().Cluster
+		if err := testKube.RestartDeployments(c, i.Config().Namespace.Name(), "app="+i.Config().Service,
+			opts...); err != nil {
+			return fmt.Errorf("failed restarting %s in %s: %v", i.Config().Service, c.Name(), err)
+		}
+		// The workloads of the instance were replaced, so they are checked and called through their new pods.
+		if err := i.Refresh(); err != nil {
+			return err
+		}
+	}
+	retryOpts := append([]retry.Option{retry.Timeout(2 * time.Minute), retry.Delay(time.Second)}, opts...)
+	return retry.UntilSuccess(func() error {
+		return h.Check(p)
+	}, retryOpts...)
+}
+
+// caSecret returns the cacerts secret for the phase. The root-cert.pem holds the trust bundle, which may contain
+// multiple roots.
+func (h *Harness) caSecret(p Phase) (*kubeApiCore.Secret, error) {
+	intermediate := h.Intermediate(p)
+	caCert, err := file.AsString(intermediate.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	caKey, err := file.AsString(intermediate.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	issuingRoot, err := file.AsString(intermediate.Root.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	bundle := ""
+	for _, r := range h.Roots(p) {
+		root, err := file.AsString(r.CertFile)
+		if err != nil {
+			return nil, err
+		}
+		bundle += root
+	}
+	return &kubeApiCore.Secret{
+		ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:      caSecretName,
+			Namespace: h.cfg.SystemNamespace,
+		},
+		Data: map[string][]byte{
+			"ca-cert.pem":    []byte(caCert),
+			"ca-key.pem":     []byte(caKey),
+			"cert-chain.pem": []byte(caCert + issuingRoot),
+			"root-cert.pem":  []byte(bundle),
+		},
+	}, nil
+}
+
+// startTraffic calls the configured traffic function until the returned function is called, which returns the first
+// error encountered.
+func (h *Harness) startTraffic() func() error {
+	if h.cfg.Traffic == nil {
+		return func() error { return nil }
+	}
+	done := make(chan struct{})
+	var (
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := h.cfg.Traffic(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	return func() error {
+		close(done)
+		wg.Wait()
+		return firstErr
+	}
+}
+
+// Check verifies that, for the phase, the trust bundle distributed to each workload namespace and loaded by each
+// sidecar contains exactly the expected roots, and that the workload certificates are issued by the expected
+// intermediate.
+func (h *Harness) Check(p Phase) error {
+	expected, err := h.rootCerts(p)
+	if err != nil {
+		return err
+	}
+	intermediate, err := readCert(h.Intermediate(p).CertFile)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	checkedNamespaces := map[string]bool{}
+	for _, i := range h.cfg.Workloads {
+		c := i.Config().Cluster
+		ns := i.Config().Namespace.Name()
+		if key := c.Name() + "/" + ns; !checkedNamespaces[key] {
+			checkedNamespaces[key] = true
+			cm, err := c.CoreV1().ConfigMaps(ns).Get(context.TODO(), rootConfigMap, kubeApiMeta.GetOptions{})
+			if err != nil {
+				errs = multierror.Append(errs, err)
+			} else if err := checkBundle(fmt.Sprintf("configmap %s/%s in %s", ns, rootConfigMap, c.Name()),
+				[]byte(cm.Data[rootConfigKey]), expected); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+
+		workloads, err := i.Workloads()
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		for _, w := range workloads {
+			if err := checkSidecar(w, expected, intermediate); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("%s/%s: %v", i.Config().Service, w.Address(), err))
+			}
+		}
+	}
+	return errs
+}
+
+// CheckOrFail calls Check and fails the test if it returns an error.
+func (h *Harness) CheckOrFail(t test.Failer, p Phase) {
+	t.Helper()
+	if err := h.Check(p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (h *Harness) rootCerts(p Phase) ([]*x509.Certificate, error) {
+	var out []*x509.Certificate
+	for _, r := range h.Roots(p) {
+		c, err := readCert(r.CertFile)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func readCert(path string) (*x509.Certificate, error) {
+	data, err := file.AsBytes(path)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := parseCerts(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate in %s", path)
+	}
+	return certs[0], nil
+}
+
+func parseCerts(data []byte) ([]*x509.Certificate, error) {
+	var out []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return out, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+}
+
+// checkBundle verifies that the PEM encoded bundle contains exactly the expected roots, in any order.
+func checkBundle(name string, bundle []byte, expected []*x509.Certificate) error {
+	actual, err := parseCerts(bundle)
+	if err != nil {
+		return fmt.Errorf("failed parsing trust bundle of %s: %v", name, err)
+	}
+	matched := 0
+	for _, e := range expected {
+		for _, a := range actual {
+			if a.Equal(e) {
+				matched++
+				break
+			}
+		}
+	}
+	if matched != len(expected) || len(actual) != len(expected) {
+		return fmt.Errorf("trust bundle of %s has %d roots, %d of which are among the %d expected",
+			name, len(actual), matched, len(expected))
+	}
+	return nil
+}
+
+// checkSidecar verifies the trust bundle and workload certificate loaded by the sidecar of the workload.
+func checkSidecar(w echo.Workload, roots []*x509.Certificate, intermediate *x509.Certificate) error {
+	if w.Sidecar() == nil {
+		return fmt.Errorf("workload has no sidecar")
+	}
+	dump, err := w.Sidecar().Config()
+	if err != nil {
+		return err
+	}
+	secrets := map[string]*tls.Secret{}
+	for _, c := range dump.Configs {
+		if c.TypeUrl != envoy.SecretsConfigDumpType {
+			continue
+		}
+		d := &envoyAdmin.SecretsConfigDump{}
+		if err := ptypes.UnmarshalAny(c, d); err != nil {
+			return err
+		}
+		for _, s := range d.DynamicActiveSecrets {
+			secret := &tls.Secret{}
+			if err := ptypes.UnmarshalAny(s.Secret, secret); err != nil {
+				return fmt.Errorf("failed unmarshaling secret %s: %v", s.Name, err)
+			}
+			secrets[s.Name] = secret
+		}
+	}
+
+	rootSecret, ok := secrets[rootSecretName]
+	if !ok {
+		return fmt.Errorf("sidecar has no %s secret", rootSecretName)
+	}
+	if err := checkBundle("sidecar", rootSecret.GetValidationContext().GetTrustedCa().GetInlineBytes(), roots); err != nil {
+		return err
+	}
+
+	certSecret, ok := secrets[certSecretName]
+	if !ok {
+		return fmt.Errorf("sidecar has no workload certificate")
+	}
+	chain, err := parseCerts(certSecret.GetTlsCertificate().GetCertificateChain().GetInlineBytes())
+	if err != nil || len(chain) == 0 {
+		return fmt.Errorf("failed parsing workload certificate: %v", err)
+	}
+	if !bytes.Equal(chain[0].AuthorityKeyId, intermediate.SubjectKeyId) {
+		return fmt.Errorf("workload certificate is not issued by the expected intermediate")
+	}
+	return nil
+}
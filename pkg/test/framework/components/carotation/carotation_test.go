@@ -0,0 +1,114 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package carotation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testRoot returns a self-signed root certificate and its PEM encoding.
+func testRoot(t *testing.T, name string) (*x509.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestParseCerts(t *testing.T) {
+	oldRoot, oldPEM := testRoot(t, "old")
+	newRoot, newPEM := testRoot(t, "new")
+	key := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("key")})
+
+	certs, err := parseCerts(append(append(append([]byte{}, oldPEM...), key...), newPEM...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 || !certs[0].Equal(oldRoot) || !certs[1].Equal(newRoot) {
+		t.Fatalf("expected the two roots, skipping the key, got %d certificates", len(certs))
+	}
+
+	if certs, err := parseCerts(nil); err != nil || len(certs) != 0 {
+		t.Fatalf("expected no certificates from an empty bundle, got %d, %v", len(certs), err)
+	}
+
+	invalid := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("invalid")})
+	if _, err := parseCerts(invalid); err == nil {
+		t.Fatal("expected an error for an invalid certificate")
+	}
+}
+
+func TestCheckBundle(t *testing.T) {
+	oldRoot, oldPEM := testRoot(t, "old")
+	newRoot, newPEM := testRoot(t, "new")
+	_, otherPEM := testRoot(t, "other")
+	join := func(pems ...[]byte) []byte {
+		var out []byte
+		for _, p := range pems {
+			out = append(out, p...)
+		}
+		return out
+	}
+
+	cases := []struct {
+		name     string
+		bundle   []byte
+		expected []*x509.Certificate
+		valid    bool
+	}{
+		{"single root", oldPEM, []*x509.Certificate{oldRoot}, true},
+		{"multi root", join(oldPEM, newPEM), []*x509.Certificate{oldRoot, newRoot}, true},
+		{"multi root in any order", join(newPEM, oldPEM), []*x509.Certificate{oldRoot, newRoot}, true},
+		{"missing root", oldPEM, []*x509.Certificate{oldRoot, newRoot}, false},
+		{"old root not removed", join(oldPEM, newPEM), []*x509.Certificate{newRoot}, false},
+		{"unexpected root", otherPEM, []*x509.Certificate{oldRoot}, false},
+		{"duplicate root", join(oldPEM, oldPEM), []*x509.Certificate{oldRoot, newRoot}, false},
+		{"empty", nil, []*x509.Certificate{oldRoot}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkBundle("test", tc.bundle, tc.expected)
+			if tc.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got %v", tc.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crd installs CustomResourceDefinitions, such as those of the Gateway API, of Multi-Cluster Services, or of
+// tests, for the lifetime of the context they are created in, so that suites need not shell out to kubectl.
+//
+// CRDs that already exist are checked for conflicts: an existing CRD serving every version of the one to install is
+// used as is, and left in place when the context is done. One that does not is a conflict, which fails the install
+// unless Replace is set, in which case it is replaced, and restored when the context is done. A CRD is updated in place
+// when replaced or restored, keeping the versions its objects are stored in served, unless its scope changes, in which
+// case it is deleted, along with its resources, and recreated.
+package crd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/expect"
+	"istio.io/istio/pkg/test/util/yml"
+)
+
+// Config of the CRDs to install.
+type Config struct {
+	// YAML of the CRDs, as apiextensions.k8s.io/v1 CustomResourceDefinitions.
+	YAML []string
+	// Files containing the YAML of more CRDs.
+	Files []string
+	// Clusters to install the CRDs in. Defaults to all the clusters.
+	Clusters []resource.Cluster
+	// Replace existing CRDs that conflict with those to install, rather than failing.
+	Replace bool
+}
+
+// Instance is a set of installed CRDs.
+type Instance interface {
+	resource.Resource
+
+	// Names of the CRDs.
+	Names() []string
+}
+
+// New installs the CRDs, and waits until they are established.
+func New(ctx resource.Context, cfg Config) (Instance, error) {
+	crds, err := parse(cfg)
+	if err != nil {
+		return nil, err
+	}
+	clusters := cfg.Clusters
+	if len(clusters) == 0 {
+		clusters = ctx.Clusters()
+	}
+	c := &component{crds: crds, runID: ctx.Settings().RunID.String()}
+	c.id = ctx.TrackResource(c)
+	for _, cluster := range clusters {
+		for _, crd := range crds {
+			if err := c.install(cluster, crd, cfg.Replace); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// NewOrFail calls New and fails the test if it returns an error.
+func NewOrFail(t test.Failer, ctx resource.Context, cfg Config) Instance {
+	t.Helper()
+	i, err := New(ctx, cfg)
+	if err != nil {
+		t.Fatalf("crd.NewOrFail: %v", err)
+	}
+	return i
+}
+
+// Setup is a setup function that installs the CRDs for the suite.
+func Setup(cfg Config) resource.SetupFn {
+	return func(ctx resource.Context) error {
+		_, err := New(ctx, cfg)
+		return err
+	}
+}
+
+func parse(cfg Config) ([]*apiextensions.CustomResourceDefinition, error) {
+	texts := append([]string{}, cfg.YAML...)
+	for _, f := range cfg.Files {
+		by, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, string(by))
+	}
+	var out []*apiextensions.CustomResourceDefinition
+	for _, text := range texts {
+		for _, part := range yml.SplitString(text) {
+			crd := &apiextensions.CustomResourceDefinition{}
+			if err := yml.Decode(part, crd); err != nil {
+				return nil, fmt.Errorf("failed parsing CRD: %v", err)
+			}
+			if crd.Kind == "" {
+				// Only comments.
+				continue
+			}
+			if crd.Kind != "CustomResourceDefinition" || crd.APIVersion != apiextensions.SchemeGroupVersion.String() {
+				return nil, fmt.Errorf("%s %s is not a %s CustomResourceDefinition", crd.Kind, crd.Name,
+					apiextensions.SchemeGroupVersion)
+			}
+			out = append(out, crd)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no CRDs to install")
+	}
+	return out, nil
+}
+
+var crdGVR = apiextensions.SchemeGroupVersion.WithResource("customresourcedefinitions")
+
+var (
+	_ Instance  = &component{}
+	_ io.Closer = &component{}
+)
+
+// installed is a CRD installed in a cluster, and the CRD it replaced, if any.
+type installed struct {
+	cluster  resource.Cluster
+	name     string
+	previous *apiextensions.CustomResourceDefinition
+}
+
+type component struct {
+	id        resource.ID
+	crds      []*apiextensions.CustomResourceDefinition
+	installed []installed
+	// runID labels the CRDs created, so that the janitor can delete them if the run leaks them.
+	runID string
+}
+
+func (c *component) ID() resource.ID {
+	return c.id
+}
+
+func (c *component) Names() []string {
+	out := make([]string, 0, len(c.crds))
+	for _, crd := range c.crds {
+		out = append(out, crd.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (c *component) install(cluster resource.Cluster, crd *apiextensions.CustomResourceDefinition, replace bool) error {
+	crds := cluster.Ext().ApiextensionsV1().CustomResourceDefinitions()
+	existing, err := crds.Get(context.TODO(), crd.Name, kubeApiMeta.GetOptions{})
+	switch {
+	case kubeErrors.IsNotFound(err):
+		scopes.Framework.Infof("Installing CRD %s in cluster %s", crd.Name, cluster.Name())
+		created := crd.DeepCopy()
+		if c.runID != "" {
+			if created.Labels == nil {
+				created.Labels = map[string]string{}
+			}
+			created.Labels[namespace.RunLabel] = c.runID
+		}
+		if _, err := crds.Create(context.TODO(), created, kubeApiMeta.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed installing CRD %s in cluster %s: %v", crd.Name, cluster.Name(), err)
+		}
+		c.installed = append(c.installed, installed{cluster: cluster, name: crd.Name})
+	case err != nil:
+		return err
+	default:
+		conflict := conflicts(existing, crd)
+		if conflict == nil {
+			scopes.Framework.Infof("Using existing CRD %s in cluster %s", crd.Name, cluster.Name())
+			return nil
+		}
+		if !replace {
+			return fmt.Errorf("CRD %s in cluster %s conflicts with the one to install: %v", crd.Name,
+				cluster.Name(), conflict)
+		}
+		scopes.Framework.Infof("Replacing CRD %s in cluster %s: %v", crd.Name, cluster.Name(), conflict)
+		if err := replaceCRD(cluster, existing, crd); err != nil {
+			return fmt.Errorf("failed replacing CRD %s in cluster %s: %v", crd.Name, cluster.Name(), err)
+		}
+		c.installed = append(c.installed, installed{cluster: cluster, name: crd.Name, previous: existing})
+	}
+	return waitEstablished(cluster, crd.Name)
+}
+
+// conflicts returns why the existing CRD does not define the resources of the one to install, or nil if it does.
+func conflicts(existing, want *apiextensions.CustomResourceDefinition) error {
+	if existing.Spec.Group != want.Spec.Group || existing.Spec.Names.Kind != want.Spec.Names.Kind ||
+		existing.Spec.Scope != want.Spec.Scope {
+		return fmt.Errorf("it defines %s %s of scope %s, expected %s %s of scope %s", existing.Spec.Group,
+			existing.Spec.Names.Kind, existing.Spec.Scope, want.Spec.Group, want.Spec.Names.Kind, want.Spec.Scope)
+	}
+	served := map[string]bool{}
+	for _, v := range existing.Spec.Versions {
+		served[v.Name] = v.Served
+	}
+	for _, v := range want.Spec.Versions {
+		if v.Served && !served[v.Name] {
+			return fmt.Errorf("it does not serve version %s", v.Name)
+		}
+	}
+	return nil
+}
+
+// immutableChanged returns whether the fields of the existing CRD that cannot be updated differ from the wanted ones.
+func immutableChanged(existing, want *apiextensions.CustomResourceDefinition) bool {
+	return existing.Spec.Group != want.Spec.Group || existing.Spec.Scope != want.Spec.Scope
+}
+
+// keepStoredVersions adds the versions that objects of the existing CRD are stored in, and that the updated CRD does
+// not define, to the updated CRD, served but not stored, as the API server rejects updates dropping them.
+func keepStoredVersions(existing, updated *apiextensions.CustomResourceDefinition) {
+	defined := map[string]bool{}
+	for _, v := range updated.Spec.Versions {
+		defined[v.Name] = true
+	}
+	for _, stored := range existing.Status.StoredVersions {
+		if defined[stored] {
+			continue
+		}
+		for _, v := range existing.Spec.Versions {
+			if v.Name == stored {
+				v := *v.DeepCopy()
+				v.Served = true
+				v.Storage = false
+				updated.Spec.Versions = append(updated.Spec.Versions, v)
+			}
+		}
+	}
+}
+
+// replaceCRD replaces the existing CRD with the wanted one. It is updated in place unless an immutable field, such as
+// the scope, changes, in which case it is deleted, along with its resources, and recreated.
+func replaceCRD(cluster resource.Cluster, existing, want *apiextensions.CustomResourceDefinition) error {
+	crds := cluster.Ext().ApiextensionsV1().CustomResourceDefinitions()
+	if immutableChanged(existing, want) {
+		if err := crds.Delete(context.TODO(), existing.Name, kubeApiMeta.DeleteOptions{}); err != nil &&
+			!kubeErrors.IsNotFound(err) {
+			return err
+		}
+		if err := expect.Eventually(cluster, crdGVR, "", existing.Name, expect.Deleted(),
+			expect.Options{Timeout: time.Minute}); err != nil {
+			return err
+		}
+		created := want.DeepCopy()
+		created.ResourceVersion = ""
+		created.UID = ""
+		created.Status = apiextensions.CustomResourceDefinitionStatus{}
+		_, err := crds.Create(context.TODO(), created, kubeApiMeta.CreateOptions{})
+		return err
+	}
+	updated := want.DeepCopy()
+	updated.ResourceVersion = existing.ResourceVersion
+	keepStoredVersions(existing, updated)
+	_, err := crds.Update(context.TODO(), updated, kubeApiMeta.UpdateOptions{})
+	return err
+}
+
+func waitEstablished(cluster resource.Cluster, name string) error {
+	return expect.Eventually(cluster, crdGVR, "", name,
+		expect.StatusCondition(string(apiextensions.Established), string(apiextensions.ConditionTrue)),
+		expect.Options{Timeout: time.Minute})
+}
+
+// Close removes the CRDs installed, along with their resources, and restores those replaced.
+func (c *component) Close() error {
+	var errs error
+	for i := len(c.installed) - 1; i >= 0; i-- {
+		inst := c.installed[i]
+		crds := inst.cluster.Ext().ApiextensionsV1().CustomResourceDefinitions()
+		if inst.previous == nil {
+			err := crds.Delete(context.TODO(), inst.name, kubeApiMeta.DeleteOptions{})
+			if err != nil && !kubeErrors.IsNotFound(err) {
+				errs = multierror.Append(errs, fmt.Errorf("failed removing CRD %s from cluster %s: %v", inst.name,
+					inst.cluster.Name(), err))
+			}
+			continue
+		}
+		current, err := crds.Get(context.TODO(), inst.name, kubeApiMeta.GetOptions{})
+		if kubeErrors.IsNotFound(err) {
+			restored := inst.previous.DeepCopy()
+			restored.ResourceVersion = ""
+			restored.UID = ""
+			_, err = crds.Create(context.TODO(), restored, kubeApiMeta.CreateOptions{})
+		} else if err == nil {
+			err = replaceCRD(inst.cluster, current, inst.previous)
+		}
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed restoring CRD %s in cluster %s: %v", inst.name,
+				inst.cluster.Name(), err))
+		}
+	}
+	c.installed = nil
+	return errs
+}
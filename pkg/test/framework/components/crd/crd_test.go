@@ -0,0 +1,184 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crd
+
+import (
+	"context"
+	"testing"
+
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func testCRD(scope apiextensions.ResourceScope, versions ...string) *apiextensions.CustomResourceDefinition {
+	crd := &apiextensions.CustomResourceDefinition{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "widgets.example.com"},
+		Spec: apiextensions.CustomResourceDefinitionSpec{
+			Group: "example.com",
+			Names: apiextensions.CustomResourceDefinitionNames{Kind: "Widget", Plural: "widgets"},
+			Scope: scope,
+		},
+	}
+	for i, v := range versions {
+		crd.Spec.Versions = append(crd.Spec.Versions, apiextensions.CustomResourceDefinitionVersion{
+			Name:    v,
+			Served:  true,
+			Storage: i == 0,
+		})
+	}
+	return crd
+}
+
+func TestConflicts(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing *apiextensions.CustomResourceDefinition
+		want     *apiextensions.CustomResourceDefinition
+		conflict bool
+	}{
+		{
+			name:     "same",
+			existing: testCRD(apiextensions.NamespaceScoped, "v1"),
+			want:     testCRD(apiextensions.NamespaceScoped, "v1"),
+		},
+		{
+			name:     "serves more versions",
+			existing: testCRD(apiextensions.NamespaceScoped, "v1", "v1beta1"),
+			want:     testCRD(apiextensions.NamespaceScoped, "v1"),
+		},
+		{
+			name:     "missing version",
+			existing: testCRD(apiextensions.NamespaceScoped, "v1beta1"),
+			want:     testCRD(apiextensions.NamespaceScoped, "v1", "v1beta1"),
+			conflict: true,
+		},
+		{
+			name: "version not served",
+			existing: func() *apiextensions.CustomResourceDefinition {
+				crd := testCRD(apiextensions.NamespaceScoped, "v1")
+				crd.Spec.Versions[0].Served = false
+				return crd
+			}(),
+			want:     testCRD(apiextensions.NamespaceScoped, "v1"),
+			conflict: true,
+		},
+		{
+			name:     "scope",
+			existing: testCRD(apiextensions.ClusterScoped, "v1"),
+			want:     testCRD(apiextensions.NamespaceScoped, "v1"),
+			conflict: true,
+		},
+		{
+			name: "kind",
+			existing: func() *apiextensions.CustomResourceDefinition {
+				crd := testCRD(apiextensions.NamespaceScoped, "v1")
+				crd.Spec.Names.Kind = "Gadget"
+				return crd
+			}(),
+			want:     testCRD(apiextensions.NamespaceScoped, "v1"),
+			conflict: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := conflicts(tc.existing, tc.want)
+			if tc.conflict != (err != nil) {
+				t.Fatalf("expected conflict %v, got %v", tc.conflict, err)
+			}
+		})
+	}
+}
+
+func testCluster(t *testing.T, existing *apiextensions.CustomResourceDefinition) resource.Cluster {
+	t.Helper()
+	c := resource.FakeCluster{
+		ExtendedClient: kube.NewFakeClient().(kube.ExtendedClient),
+		NameValue:      "cluster-0",
+	}
+	if _, err := c.Ext().ApiextensionsV1().CustomResourceDefinitions().Create(context.TODO(), existing,
+		kubeApiMeta.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func getCRD(t *testing.T, c resource.Cluster) *apiextensions.CustomResourceDefinition {
+	t.Helper()
+	crd, err := c.Ext().ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), "widgets.example.com",
+		kubeApiMeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crd
+}
+
+func versionNames(crd *apiextensions.CustomResourceDefinition) []string {
+	var out []string
+	for _, v := range crd.Spec.Versions {
+		out = append(out, v.Name)
+	}
+	return out
+}
+
+func TestReplaceKeepsStoredVersions(t *testing.T) {
+	existing := testCRD(apiextensions.NamespaceScoped, "v1beta1")
+	existing.Status.StoredVersions = []string{"v1beta1"}
+	c := testCluster(t, existing)
+
+	if err := replaceCRD(c, getCRD(t, c), testCRD(apiextensions.NamespaceScoped, "v1")); err != nil {
+		t.Fatal(err)
+	}
+	got := getCRD(t, c)
+	if names := versionNames(got); len(names) != 2 || names[0] != "v1" || names[1] != "v1beta1" {
+		t.Fatalf("expected v1 and the stored v1beta1, got %v", names)
+	}
+	if !got.Spec.Versions[0].Storage || got.Spec.Versions[1].Storage || !got.Spec.Versions[1].Served {
+		t.Fatalf("expected v1 stored and v1beta1 only served, got %+v", got.Spec.Versions)
+	}
+}
+
+func TestReplaceRecreatesOnScopeChange(t *testing.T) {
+	c := testCluster(t, testCRD(apiextensions.ClusterScoped, "v1"))
+
+	if err := replaceCRD(c, getCRD(t, c), testCRD(apiextensions.NamespaceScoped, "v1")); err != nil {
+		t.Fatal(err)
+	}
+	if got := getCRD(t, c); got.Spec.Scope != apiextensions.NamespaceScoped {
+		t.Fatalf("expected the CRD to be recreated with the new scope, got %s", got.Spec.Scope)
+	}
+}
+
+func TestCloseRestoresReplaced(t *testing.T) {
+	previous := testCRD(apiextensions.ClusterScoped, "v1alpha1")
+	c := testCluster(t, previous)
+	if err := replaceCRD(c, getCRD(t, c), testCRD(apiextensions.NamespaceScoped, "v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	comp := &component{installed: []installed{{cluster: c, name: previous.Name, previous: previous}}}
+	if err := comp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := getCRD(t, c)
+	if got.Spec.Scope != apiextensions.ClusterScoped {
+		t.Fatalf("expected the previous scope to be restored, got %s", got.Spec.Scope)
+	}
+	if names := versionNames(got); len(names) != 1 || names[0] != "v1alpha1" {
+		t.Fatalf("expected the previous versions to be restored, got %v", names)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crossnetwork measures the traffic that crosses networks through an east-west gateway, so that performance
+// suites can bound the concurrent connections it holds and compare multi-network routing against a same-network
+// baseline.
+package crossnetwork
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/istio/ingress"
+)
+
+const (
+	// crossNetworkListener is the suffix of the stats of the listener on which the east-west gateway accepts
+	// cross-network mTLS traffic.
+	crossNetworkListener = "_15443."
+	// outboundClusterPrefix is the prefix of the stats of the clusters to which the gateway forwards that traffic.
+	outboundClusterPrefix = "cluster.outbound_"
+
+	// DefaultSampleInterval is the interval at which Measure samples the connections of the gateway, if none is given.
+	DefaultSampleInterval = time.Second
+)
+
+// Stats of the cross-network traffic of an east-west gateway. Byte counters are cumulative since the gateway started.
+type Stats struct {
+	// ActiveConnections currently accepted on the cross-network listener.
+	ActiveConnections int
+	// RxBytes received from the destination workloads.
+	RxBytes int
+	// TxBytes sent to the destination workloads.
+	TxBytes int
+}
+
+// GatewayStats returns the cross-network stats of the east-west gateway.
+func GatewayStats(gw ingress.Instance) (Stats, error) {
+	stats, err := gw.ProxyStats()
+	if err != nil {
+		return Stats{}, err
+	}
+	var s Stats
+	for name, value := range stats {
+		switch {
+		case strings.HasPrefix(name, "listener.") && strings.HasSuffix(name, crossNetworkListener+"downstream_cx_active"):
+			s.ActiveConnections += value
+		case strings.HasPrefix(name, outboundClusterPrefix) && strings.HasSuffix(name, ".upstream_cx_rx_bytes_total"):
+			s.RxBytes += value
+		case strings.HasPrefix(name, outboundClusterPrefix) && strings.HasSuffix(name, ".upstream_cx_tx_bytes_total"):
+			s.TxBytes += value
+		}
+	}
+	return s, nil
+}
+
+// Load generates traffic, returning the number of successful requests.
+type Load func() (int, error)
+
+// CallLoad returns a load that makes the call from the source.
+func CallLoad(src echo.Instance, opts echo.CallOptions) Load {
+	return func() (int, error) {
+		resp, err := src.Call(opts)
+		if err != nil {
+			return 0, err
+		}
+		return len(resp), nil
+	}
+}
+
+// Measurement of a load.
+type Measurement struct {
+	Requests int
+	Duration time.Duration
+	// PeakConnections is the highest number of active cross-network connections sampled on the gateway while the
+	// load ran. Zero for baselines.
+	PeakConnections int
+	// Bytes sent and received through the gateway while the load ran. Zero for baselines.
+	Bytes int
+}
+
+// RequestsPerSecond returns the request rate of the load.
+func (m Measurement) RequestsPerSecond() float64 {
+	if m.Duration <= 0 {
+		return 0
+	}
+	return float64(m.Requests) / m.Duration.Seconds()
+}
+
+// Throughput returns the bytes per second through the gateway.
+func (m Measurement) Throughput() float64 {
+	if m.Duration <= 0 {
+		return 0
+	}
+	return float64(m.Bytes) / m.Duration.Seconds()
+}
+
+func (m Measurement) String() string {
+	return fmt.Sprintf("%d requests in %v (%.1f rps), %d peak connections, %.0f B/s",
+		m.Requests, m.Duration, m.RequestsPerSecond(), m.PeakConnections, m.Throughput())
+}
+
+// Baseline measures a load that does not cross networks, such as calls between workloads in the same network.
+func Baseline(load Load) (Measurement, error) {
+	start := time.Now()
+	n, err := load()
+	if err != nil {
+		return Measurement{}, err
+	}
+	return Measurement{Requests: n, Duration: time.Since(start)}, nil
+}
+
+// Measure runs a load that crosses networks through the gateway, sampling its active connections at the interval, or
+// at DefaultSampleInterval if it is not positive.
+func Measure(gw ingress.Instance, load Load, interval time.Duration) (Measurement, error) {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	before, err := GatewayStats(gw)
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	var (
+		mu   sync.Mutex
+		peak int
+		wg   sync.WaitGroup
+	)
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// Sampling errors only lower the observed peak, the load itself is still measured.
+				if s, err := GatewayStats(gw); err == nil {
+					mu.Lock()
+					if s.ActiveConnections > peak {
+						peak = s.ActiveConnections
+					}
+					mu.Unlock()
+				}
+			}
+		}
+	}()
+
+	start := time.Now()
+	n, err := load()
+	d := time.Since(start)
+	close(done)
+	wg.Wait()
+	if err != nil {
+		return Measurement{}, err
+	}
+
+	after, err := GatewayStats(gw)
+	if err != nil {
+		return Measurement{}, err
+	}
+	return Measurement{
+		Requests:        n,
+		Duration:        d,
+		PeakConnections: peak,
+		Bytes:           after.RxBytes - before.RxBytes + after.TxBytes - before.TxBytes,
+	}, nil
+}
+
+// MeasureOrFail calls Measure and fails the test if it returns an error.
+func MeasureOrFail(t test.Failer, gw ingress.Instance, load Load, interval time.Duration) Measurement {
+	t.Helper()
+	m, err := Measure(gw, load, interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// CheckConnectionQuota verifies that the load held at most max concurrent connections through the gateway.
+func CheckConnectionQuota(m Measurement, max int) error {
+	if m.PeakConnections > max {
+		return fmt.Errorf("expected at most %d concurrent cross-network connections, got %d", max, m.PeakConnections)
+	}
+	return nil
+}
+
+// CheckConnectionQuotaOrFail calls CheckConnectionQuota and fails the test if it returns an error.
+func CheckConnectionQuotaOrFail(t test.Failer, m Measurement, max int) {
+	t.Helper()
+	if err := CheckConnectionQuota(m, max); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// CheckOverhead verifies that the request rate of the cross-network load is at most maxOverhead (a fraction, e.g.
+// 0.2 for 20%) lower than that of the baseline.
+func CheckOverhead(crossNetwork, baseline Measurement, maxOverhead float64) error {
+	if crossNetwork.Bytes == 0 {
+		return fmt.Errorf("no traffic crossed the east-west gateway: %v", crossNetwork)
+	}
+	want := baseline.RequestsPerSecond() * (1 - maxOverhead)
+	if got := crossNetwork.RequestsPerSecond(); got < want {
+		return fmt.Errorf("cross-network overhead exceeds %.0f%%: %v, baseline %v", maxOverhead*100, crossNetwork, baseline)
+	}
+	return nil
+}
+
+// CheckOverheadOrFail calls CheckOverhead and fails the test if it returns an error.
+func CheckOverheadOrFail(t test.Failer, crossNetwork, baseline Measurement, maxOverhead float64) {
+	t.Helper()
+	if err := CheckOverhead(crossNetwork, baseline, maxOverhead); err != nil {
+		t.Fatal(err)
+	}
+}
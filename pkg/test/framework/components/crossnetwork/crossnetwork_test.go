@@ -0,0 +1,131 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crossnetwork
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework/components/istio/ingress"
+)
+
+// fakeGateway serves the stats it is set to.
+type fakeGateway struct {
+	ingress.Instance
+
+	mu    sync.Mutex
+	stats map[string]int
+}
+
+func (g *fakeGateway) ProxyStats() (map[string]int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stats == nil {
+		return nil, errors.New("admin port unreachable")
+	}
+	out := make(map[string]int, len(g.stats))
+	for k, v := range g.stats {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (g *fakeGateway) set(active, rx, tx int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stats = map[string]int{
+		"listener.0.0.0.0_15443.downstream_cx_active":                                active,
+		"listener.0.0.0.0_15021.downstream_cx_active":                                100,
+		"cluster.outbound_80_._.b.echo.svc.cluster.local.upstream_cx_rx_bytes_total": rx,
+		"cluster.outbound_80_._.b.echo.svc.cluster.local.upstream_cx_tx_bytes_total": tx,
+		"cluster.xds-grpc.upstream_cx_rx_bytes_total":                                1000,
+	}
+}
+
+func TestGatewayStats(t *testing.T) {
+	gw := &fakeGateway{}
+	if _, err := GatewayStats(gw); err == nil {
+		t.Fatal("expected an error when the stats are unavailable")
+	}
+	gw.set(3, 200, 100)
+	s, err := GatewayStats(gw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (Stats{ActiveConnections: 3, RxBytes: 200, TxBytes: 100}) {
+		t.Fatalf("expected only the cross-network listener and outbound clusters to be counted, got %+v", s)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	gw := &fakeGateway{}
+	gw.set(0, 200, 100)
+	load := func() (int, error) {
+		gw.set(5, 1200, 600)
+		time.Sleep(50 * time.Millisecond)
+		gw.set(2, 1200, 600)
+		return 10, nil
+	}
+	m, err := Measure(gw, load, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Requests != 10 || m.PeakConnections != 5 || m.Bytes != 1500 {
+		t.Fatalf("got %v, want 10 requests, 5 peak connections and 1500 bytes", m)
+	}
+
+	if _, err := Measure(gw, func() (int, error) { return 0, errors.New("call failed") }, 0); err == nil {
+		t.Fatal("expected the error of the load")
+	}
+}
+
+func TestCheckConnectionQuota(t *testing.T) {
+	if err := CheckConnectionQuota(Measurement{PeakConnections: 5}, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckConnectionQuota(Measurement{PeakConnections: 6}, 5); err == nil {
+		t.Fatal("expected an error above the quota")
+	}
+}
+
+func TestCheckOverhead(t *testing.T) {
+	baseline := Measurement{Requests: 100, Duration: time.Second}
+	cases := []struct {
+		name  string
+		cross Measurement
+		err   string
+	}{
+		{"within overhead", Measurement{Requests: 80, Duration: time.Second, Bytes: 1}, ""},
+		{"above overhead", Measurement{Requests: 79, Duration: time.Second, Bytes: 1}, "overhead exceeds 20%"},
+		{"no gateway traffic", Measurement{Requests: 100, Duration: time.Second}, "no traffic crossed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckOverhead(tc.cross, baseline, 0.2)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got error %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csrsigner provides a test signer that approves and signs Kubernetes CertificateSigningRequests with its
+// own CA, for testing the Kubernetes cert provider of istiod.
+package csrsigner
+
+import (
+	"time"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// DefaultSignerName is the signer name of the requests signed by default. Requests with this name are only signed
+// by the test signer, unlike those with the kubernetes.io/legacy-unknown name Kubernetes sets on requests that name no
+// signer, which kube-controller-manager also signs.
+const DefaultSignerName = "istio.io/test-csr-signer"
+
+// KubernetesCertProviderValues are ControlPlaneValues that make istiod obtain its certificate through a Kubernetes
+// CertificateSigningRequest rather than from its own CA, from the signer with the DefaultSignerName.
+const KubernetesCertProviderValues = `
+values:
+  pilot:
+    env:
+      PILOT_CERT_PROVIDER: kubernetes
+      PILOT_CERT_SIGNER: ` + DefaultSignerName + `
+`
+
+// Instance is a running signer.
+type Instance interface {
+	resource.Resource
+
+	// CACert returns the PEM encoded certificate of the CA that signs the requests.
+	CACert() []byte
+
+	// Signed returns the names of the requests that have been signed.
+	Signed() []string
+
+	// WaitForSigned waits until a request whose name starts with the prefix has been signed.
+	WaitForSigned(prefix string) error
+	WaitForSignedOrFail(t test.Failer, prefix string)
+
+	// CheckIssued verifies that the PEM encoded certificate chain was issued by the CA of the signer.
+	CheckIssued(chain []byte) error
+}
+
+// Config for the signer.
+type Config struct {
+	// Cluster in which requests are signed.
+	Cluster resource.Cluster
+
+	// SignerName of the requests that are approved and signed. Defaults to DefaultSignerName, which istiod requests
+	// when installed with KubernetesCertProviderValues.
+	SignerName string
+
+	// TTL of the issued certificates. Defaults to 24h.
+	TTL time.Duration
+}
+
+// New starts a signer, which runs until the context is done.
+func New(ctx resource.Context, c Config) (Instance, error) {
+	return newKube(ctx, c)
+}
+
+// NewOrFail starts a signer or fails the test.
+func NewOrFail(t test.Failer, ctx resource.Context, c Config) Instance {
+	t.Helper()
+	i, err := New(ctx, c)
+	if err != nil {
+		t.Fatalf("csrsigner.NewOrFail: %v", err)
+	}
+	return i
+}
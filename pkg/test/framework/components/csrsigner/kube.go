@@ -0,0 +1,251 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csrsigner
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	certificates "k8s.io/api/certificates/v1beta1"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+var _ Instance = &kubeComponent{}
+
+type kubeComponent struct {
+	id      resource.ID
+	cluster resource.Cluster
+	cfg     Config
+
+	caCertPEM []byte
+	caCert    *x509.Certificate
+	caKey     crypto.PrivateKey
+
+	cancel func()
+	done   chan struct{}
+
+	mu     sync.Mutex
+	signed []string
+}
+
+func newKube(ctx resource.Context, cfg Config) (Instance, error) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.SignerName == "" {
+		cfg.SignerName = DefaultSignerName
+	}
+	c := &kubeComponent{
+		cluster: ctx.Clusters().GetOrDefault(cfg.Cluster),
+		cfg:     cfg,
+		done:    make(chan struct{}),
+	}
+
+	certPEM, keyPEM, err := util.GenCertKeyFromOptions(util.CertOptions{
+		Org:          "Istio Test",
+		TTL:          cfg.TTL,
+		IsCA:         true,
+		IsSelfSigned: true,
+		RSAKeySize:   2048,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed generating signer CA: %v", err)
+	}
+	if c.caCert, err = util.ParsePemEncodedCertificate(certPEM); err != nil {
+		return nil, err
+	}
+	if c.caKey, err = util.ParsePemEncodedKey(keyPEM); err != nil {
+		return nil, err
+	}
+	c.caCertPEM = certPEM
+
+	client := c.cluster.CertificatesV1beta1().CertificateSigningRequests()
+	selector := fields.OneTermEqualSelector("spec.signerName", cfg.SignerName).String()
+	// The informer lists and re-establishes its watch whenever the watch closes, so that no request is missed.
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts kubeApiMeta.ListOptions) (runtime.Object, error) {
+				opts.FieldSelector = selector
+				return client.List(context.TODO(), opts)
+			},
+			WatchFunc: func(opts kubeApiMeta.ListOptions) (watch.Interface, error) {
+				opts.FieldSelector = selector
+				return client.Watch(context.TODO(), opts)
+			},
+		},
+		&certificates.CertificateSigningRequest{}, 0, cache.Indexers{},
+	)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.handle,
+		UpdateFunc: func(_, obj interface{}) {
+			c.handle(obj)
+		},
+	})
+
+	stop := make(chan struct{})
+	c.cancel = func() {
+		close(stop)
+	}
+	go func() {
+		defer close(c.done)
+		informer.Run(stop)
+	}()
+	if !cache.WaitForCacheSync(stop, informer.HasSynced) {
+		c.cancel()
+		<-c.done
+		return nil, fmt.Errorf("failed watching CertificateSigningRequests")
+	}
+	c.id = ctx.TrackResource(c)
+	return c, nil
+}
+
+func (c *kubeComponent) handle(obj interface{}) {
+	csr, ok := obj.(*certificates.CertificateSigningRequest)
+	if !ok || !c.shouldSign(csr) {
+		return
+	}
+	// Objects of the informer are shared with its cache, so sign a copy.
+	if err := c.sign(csr.DeepCopy()); err != nil {
+		scopes.Framework.Warnf("csrsigner failed signing %s: %v", csr.Name, err)
+	}
+}
+
+func (c *kubeComponent) shouldSign(csr *certificates.CertificateSigningRequest) bool {
+	if len(csr.Status.Certificate) > 0 {
+		return false
+	}
+	if csr.Spec.SignerName == nil || *csr.Spec.SignerName != c.cfg.SignerName {
+		return false
+	}
+	for _, cond := range csr.Status.Conditions {
+		if cond.Type == certificates.CertificateDenied {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *kubeComponent) sign(csr *certificates.CertificateSigningRequest) error {
+	approved := false
+	for _, cond := range csr.Status.Conditions {
+		if cond.Type == certificates.CertificateApproved {
+			approved = true
+		}
+	}
+	client := c.cluster.CertificatesV1beta1().CertificateSigningRequests()
+	if !approved {
+		csr.Status.Conditions = append(csr.Status.Conditions, certificates.CertificateSigningRequestCondition{
+			Type:    certificates.CertificateApproved,
+			Status:  kubeApiCore.ConditionTrue,
+			Reason:  "CSRSigner",
+			Message: "approved by the test signer",
+		})
+		var err error
+		if csr, err = client.UpdateApproval(context.TODO(), csr, kubeApiMeta.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed approving: %v", err)
+		}
+	}
+
+	req, err := util.ParsePemEncodedCSR(csr.Spec.Request)
+	if err != nil {
+		return err
+	}
+	var subjectIDs []string
+	subjectIDs = append(subjectIDs, req.DNSNames...)
+	for _, u := range req.URIs {
+		subjectIDs = append(subjectIDs, u.String())
+	}
+	der, err := util.GenCertFromCSR(req, c.caCert, req.PublicKey, c.caKey, subjectIDs, c.cfg.TTL, false)
+	if err != nil {
+		return err
+	}
+	csr.Status.Certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if _, err := client.UpdateStatus(context.TODO(), csr, kubeApiMeta.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed updating status: %v", err)
+	}
+
+	c.mu.Lock()
+	c.signed = append(c.signed, csr.Name)
+	c.mu.Unlock()
+	scopes.Framework.Infof("csrsigner signed %s for %v", csr.Name, subjectIDs)
+	return nil
+}
+
+func (c *kubeComponent) ID() resource.ID {
+	return c.id
+}
+
+func (c *kubeComponent) CACert() []byte {
+	return c.caCertPEM
+}
+
+func (c *kubeComponent) Signed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.signed...)
+}
+
+func (c *kubeComponent) WaitForSigned(prefix string) error {
+	return retry.UntilSuccess(func() error {
+		for _, name := range c.Signed() {
+			if strings.HasPrefix(name, prefix) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no request with prefix %q has been signed, signed: %v", prefix, c.Signed())
+	}, retry.Timeout(2*time.Minute), retry.Delay(time.Second))
+}
+
+func (c *kubeComponent) WaitForSignedOrFail(t test.Failer, prefix string) {
+	t.Helper()
+	if err := c.WaitForSigned(prefix); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *kubeComponent) CheckIssued(chain []byte) error {
+	leaf, err := util.ParsePemEncodedCertificate(chain)
+	if err != nil {
+		return err
+	}
+	if err := leaf.CheckSignatureFrom(c.caCert); err != nil {
+		return fmt.Errorf("certificate for %v was not issued by the test signer: %v", leaf.DNSNames, err)
+	}
+	return nil
+}
+
+// Close stops the signer.
+func (c *kubeComponent) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
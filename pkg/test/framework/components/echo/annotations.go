@@ -35,11 +35,16 @@ type Annotation struct {
 }
 
 var (
-	SidecarInject                = workloadAnnotation(annotation.SidecarInject.Name, "true")
-	SidecarRewriteAppHTTPProbers = workloadAnnotation(annotation.SidecarRewriteAppHTTPProbers.Name, "")
-	SidecarBootstrapOverride     = workloadAnnotation(annotation.SidecarBootstrapOverride.Name, "")
-	SidecarVolumeMount           = workloadAnnotation(annotation.SidecarUserVolumeMount.Name, "")
-	SidecarVolume                = workloadAnnotation(annotation.SidecarUserVolume.Name, "")
+	SidecarInject                 = workloadAnnotation(annotation.SidecarInject.Name, "true")
+	SidecarRewriteAppHTTPProbers  = workloadAnnotation(annotation.SidecarRewriteAppHTTPProbers.Name, "")
+	SidecarBootstrapOverride      = workloadAnnotation(annotation.SidecarBootstrapOverride.Name, "")
+	SidecarVolumeMount            = workloadAnnotation(annotation.SidecarUserVolumeMount.Name, "")
+	SidecarVolume                 = workloadAnnotation(annotation.SidecarUserVolume.Name, "")
+	SidecarStatsInclusionPrefixes = workloadAnnotation(annotation.SidecarStatsInclusionPrefixes.Name, "")
+	SidecarProxyImage             = workloadAnnotation(annotation.SidecarProxyImage.Name, "")
+	SidecarProxyConfig            = workloadAnnotation(annotation.ProxyConfig.Name, "")
+	// InjectTemplates selects the injection template of the pod, for custom templates that branch on it.
+	InjectTemplates = workloadAnnotation("inject.istio.io/templates", "")
 )
 
 type AnnotationValue struct {
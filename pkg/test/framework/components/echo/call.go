@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"time"
 
+	"istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/echo/common/scheme"
 )
 
@@ -61,13 +62,76 @@ type CallOptions struct {
 	// Timeout used for each individual request. Must be > 0, otherwise 30 seconds is used.
 	Timeout time.Duration
 
-	// Message to be sent if this is a GRPC request
+	// Message to be sent. For GRPC, TCP and WebSocket requests, this is echoed back by the target. For HTTP requests,
+	// it is sent as the request body if the method takes one, such as POST, or if a body is explicitly requested with
+	// BodySize, StreamBody or a Content-Length header.
 	Message string
 
+	// BodySize, if set, sends a generated HTTP request body of the given number of bytes in place of Message, with
+	// any method. Method defaults to POST.
+	BodySize int
+
+	// StreamBody sends the HTTP request body with chunked transfer encoding, rather than with a Content-Length.
+	StreamBody bool
+
+	// HeaderSize, if set, adds an X-Padding header with a value of the given number of bytes, for example to exceed
+	// the maximum request header size.
+	HeaderSize int
+
+	// ResponseDelay, if set, holds each HTTP request on the target for the given duration before responding, for
+	// example to exceed a request timeout.
+	ResponseDelay time.Duration
+
 	// Method to send. Defaults to HTTP. Only relevant for HTTP.
 	Method string
 
 	// Use the custom certificate to make the call. This is mostly used to make mTLS request directly
 	// (without proxy) from naked client to test certificates issued by custom CA instead of the Istio self-signed CA.
 	Cert, Key, CaCert string
+
+	// RequestID identifies the requests of the call, so that their access logs and traces can be told apart from
+	// unrelated traffic. Each request is sent with its own X-Request-Id derived from it, as by
+	// common.RequestIDForIndex, so that proxies trace and sample them independently. A random one is generated if
+	// neither this nor the header is set.
+	RequestID string
+
+	// Session, if set, makes the requests within a stateful session. Rather than being sent independently, the
+	// requests are sent one at a time, each carrying the session cookie or header returned by the previous response.
+	Session *SessionOptions
+
+	// Socket sets the options of the connections of TCP calls, such as their keepalive and linger.
+	Socket *common.SocketOptions
+
+	// Idle, if set, keeps each connection of a TCP call open and idle for up to the given duration after the response
+	// is received, recording in the response when it is closed by its peer, such as by the idle timeout of a proxy.
+	Idle time.Duration
+
+	// TLS sets the versions, cipher suites, ALPN and session resumption offered by the TLS handshakes of HTTPS, TCP
+	// and GRPC calls, with the negotiated parameters recorded in each response. TCP and GRPC calls use TLS if set.
+	TLS *common.TLSOptions
+
+	// TraceContext, if set, starts a new trace for each request of HTTP calls, sent in each of the trace context
+	// formats, such as common.B3 and common.W3C. The ID of the trace is recorded in each response, so that checks can
+	// tell whether the proxies propagated it or generated their own.
+	TraceContext []common.TraceFormat
+
+	// Fault, if set, makes the target misbehave in responding to each request of HTTP calls, such as by trickling the
+	// body or resetting the connection mid-body, to test how the proxies handle a slow or faulty upstream.
+	Fault *common.Fault
+}
+
+// SessionOptions identifies how a stateful session is carried between requests. Exactly one of Cookie or Header
+// should be set.
+type SessionOptions struct {
+	// Cookie is the name of the cookie holding the session.
+	Cookie string
+
+	// Header is the name of the header holding the session.
+	Header string
+
+	// Issue makes the target issue the session in the cookie or header of its first response, as an application
+	// managing its own sessions does. Otherwise, the session must be issued by the proxies, such as by a consistent
+	// hash load balancer on a cookie, which generates the cookie for requests that do not carry it. A zero TTL
+	// makes it a session cookie rather than none.
+	Issue bool
 }
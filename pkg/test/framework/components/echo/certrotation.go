@@ -0,0 +1,113 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"time"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/envoy"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// workloadCertName is the name of the SDS secret holding the workload certificate.
+const workloadCertName = "default"
+
+// WorkloadCertVersion returns the version of the workload certificate loaded by the sidecar of the workload. The
+// version changes each time the certificate is rotated.
+func WorkloadCertVersion(w Workload) (string, error) {
+	if w.Sidecar() == nil {
+		return "", fmt.Errorf("workload %s has no sidecar", w.Address())
+	}
+	dump, err := w.Sidecar().Config()
+	if err != nil {
+		return "", err
+	}
+	for _, c := range dump.Configs {
+		if c.TypeUrl != envoy.SecretsConfigDumpType {
+			continue
+		}
+		secrets := &envoyAdmin.SecretsConfigDump{}
+		if err := ptypes.UnmarshalAny(c, secrets); err != nil {
+			return "", fmt.Errorf("failed unmarshaling secrets of %s: %v", w.Address(), err)
+		}
+		for _, s := range secrets.DynamicActiveSecrets {
+			if s.Name == workloadCertName {
+				return s.VersionInfo, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("sidecar of %s has no workload certificate", w.Address())
+}
+
+// WaitForCertRotation waits until the sidecar of the workload loads a workload certificate other than the one it has
+// now. Each call observes a full rotation.
+func WaitForCertRotation(w Workload, opts ...retry.Option) error {
+	initial, err := WorkloadCertVersion(w)
+	if err != nil {
+		return err
+	}
+	opts = append([]retry.Option{retry.Timeout(10 * time.Minute), retry.Delay(5 * time.Second)}, opts...)
+	return retry.UntilSuccess(func() error {
+		current, err := WorkloadCertVersion(w)
+		if err != nil {
+			return err
+		}
+		if current == initial {
+			return fmt.Errorf("workload certificate of %s has not been rotated from version %s", w.Address(), initial)
+		}
+		return nil
+	}, opts...)
+}
+
+// CheckCallsAcrossCertRotation sends requests from the source continuously until the sidecar of the workload has
+// rotated its workload certificate, and fails on the first failed request. The workload would typically be the
+// destination of the requests, but may be the source.
+func CheckCallsAcrossCertRotation(src Instance, options CallOptions, w Workload, opts ...retry.Option) error {
+	rotated := make(chan error, 1)
+	go func() {
+		rotated <- WaitForCertRotation(w, opts...)
+	}()
+	for calls := 0; ; calls++ {
+		select {
+		case err := <-rotated:
+			if err != nil {
+				return err
+			}
+			if calls == 0 {
+				return fmt.Errorf("no requests were sent during the rotation")
+			}
+			return nil
+		default:
+		}
+		if _, err := src.Call(options); err != nil {
+			return fmt.Errorf("request %d failed before the workload certificate was rotated: %v", calls, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// CheckCallsAcrossCertRotationOrFail calls CheckCallsAcrossCertRotation and fails the test if it returns an error.
+func CheckCallsAcrossCertRotationOrFail(t test.Failer, src Instance, options CallOptions, w Workload,
+	opts ...retry.Option) {
+	t.Helper()
+	if err := CheckCallsAcrossCertRotation(src, options, w, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
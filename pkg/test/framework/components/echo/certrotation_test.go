@@ -0,0 +1,150 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// rotatingSidecar serves the workload certificate of version 1 for the given number of config dumps, and of version 2
+// afterwards.
+type rotatingSidecar struct {
+	Sidecar
+	t *testing.T
+
+	mu    sync.Mutex
+	dumps int
+	after int
+}
+
+func (s *rotatingSidecar) Config() (*envoyAdmin.ConfigDump, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dumps++
+	version := "1"
+	if s.dumps > s.after {
+		version = "2"
+	}
+	return &envoyAdmin.ConfigDump{Configs: []*any.Any{secretsDump(s.t, "ROOTCA", workloadCertName+"@"+version)}}, nil
+}
+
+// secretsDump returns a secrets config dump holding the given active secrets, each given as name@version.
+func secretsDump(t *testing.T, secrets ...string) *any.Any {
+	t.Helper()
+	dump := &envoyAdmin.SecretsConfigDump{}
+	for _, s := range secrets {
+		parts := strings.Split(s, "@")
+		dump.DynamicActiveSecrets = append(dump.DynamicActiveSecrets, &envoyAdmin.SecretsConfigDump_DynamicSecret{
+			Name:        parts[0],
+			VersionInfo: parts[len(parts)-1],
+		})
+	}
+	a, err := ptypes.MarshalAny(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// callingInstance counts its calls, which fail once it has made the given number of them, if set.
+type callingInstance struct {
+	FakeInstance
+
+	mu       sync.Mutex
+	calls    int
+	failFrom int
+}
+
+func (i *callingInstance) Call(CallOptions) (client.ParsedResponses, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.calls++
+	if i.failFrom > 0 && i.calls >= i.failFrom {
+		return nil, errors.New("connection reset")
+	}
+	return client.ParsedResponses{{Code: "200"}}, nil
+}
+
+func TestWorkloadCertVersion(t *testing.T) {
+	v, err := WorkloadCertVersion(fakeWorkload{address: "10.0.0.1", sidecar: &rotatingSidecar{t: t, after: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "1" {
+		t.Fatalf("got version %s, want 1", v)
+	}
+
+	cases := []struct {
+		name     string
+		workload Workload
+	}{
+		{"no sidecar", fakeWorkload{address: "10.0.0.1"}},
+		{"no secrets", fakeWorkload{address: "10.0.0.1", sidecar: versionSidecar{}}},
+		{"no workload certificate", fakeWorkload{address: "10.0.0.1", sidecar: versionSidecar{
+			configs: []*any.Any{secretsDump(t, "ROOTCA@1")},
+		}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := WorkloadCertVersion(tc.workload); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestWaitForCertRotation(t *testing.T) {
+	w := fakeWorkload{address: "10.0.0.1", sidecar: &rotatingSidecar{t: t, after: 3}}
+	if err := WaitForCertRotation(w, retry.Timeout(time.Second), retry.Delay(time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	w = fakeWorkload{address: "10.0.0.1", sidecar: &rotatingSidecar{t: t, after: 1000}}
+	err := WaitForCertRotation(w, retry.Timeout(50*time.Millisecond), retry.Delay(time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "has not been rotated from version 1") {
+		t.Fatalf("expected the certificate not to be rotated, got %v", err)
+	}
+}
+
+func TestCheckCallsAcrossCertRotation(t *testing.T) {
+	opts := []retry.Option{retry.Timeout(time.Second), retry.Delay(100 * time.Millisecond)}
+
+	src := &callingInstance{}
+	w := fakeWorkload{address: "10.0.0.1", sidecar: &rotatingSidecar{t: t, after: 3}}
+	if err := CheckCallsAcrossCertRotation(src, CallOptions{}, w, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if src.calls == 0 {
+		t.Fatal("expected calls to be made during the rotation")
+	}
+
+	src = &callingInstance{failFrom: 2}
+	w = fakeWorkload{address: "10.0.0.1", sidecar: &rotatingSidecar{t: t, after: 1000}}
+	err := CheckCallsAcrossCertRotation(src, CallOptions{}, w, opts...)
+	if err == nil || !strings.Contains(err.Error(), "request 1 failed before the workload certificate was rotated") {
+		t.Fatalf("expected the failed request to be reported, got %v", err)
+	}
+}
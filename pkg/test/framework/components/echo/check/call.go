@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check
+
+import (
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Call makes the call from the instance, retrying until the checker passes.
+func Call(from echo.Instance, opts echo.CallOptions, c Checker, retryOptions ...retry.Option) (client.ParsedResponses, error) {
+	var out client.ParsedResponses
+	err := retry.UntilSuccess(func() error {
+		resp, err := from.Call(opts)
+		out = resp
+		return c(resp, err)
+	}, retryOptions...)
+	return out, err
+}
+
+// CallOrFail calls Call and fails the test if it returns an error.
+func CallOrFail(t test.Failer, from echo.Instance, opts echo.CallOptions, c Checker,
+	retryOptions ...retry.Option) client.ParsedResponses {
+	t.Helper()
+	resp, err := Call(from, opts, c, retryOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
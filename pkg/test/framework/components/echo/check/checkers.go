@@ -0,0 +1,431 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package check provides composable checks of the responses of echo calls, so that complex expectations are
+// expressed by combining checkers rather than by loops over the responses.
+//
+//	err := check.And(
+//		check.OK(),
+//		check.ReachedClusters(clusters),
+//		check.Each(check.HeaderMatches("X-Forwarded-Client-Cert", "By=spiffe://.*")),
+//	).Check(from.Call(opts))
+package check
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/client-go/util/jsonpath"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/response"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// Checker checks the responses of a call, and the error it returned.
+type Checker func(resp client.ParsedResponses, err error) error
+
+// Check runs the checker. Its arguments match the results of echo.Instance.Call.
+func (c Checker) Check(resp client.ParsedResponses, err error) error {
+	return c(resp, err)
+}
+
+// ResponseChecker checks a single response.
+type ResponseChecker func(i int, r *client.ParsedResponse) error
+
+// And checks that all of the checkers pass, returning the error of the first failed one.
+func And(checkers ...Checker) Checker {
+	return func(resp client.ParsedResponses, err error) error {
+		for _, c := range checkers {
+			if cerr := c(resp, err); cerr != nil {
+				return cerr
+			}
+		}
+		return nil
+	}
+}
+
+// Or checks that at least one of the checkers passes, returning the errors of all of them otherwise.
+func Or(checkers ...Checker) Checker {
+	return func(resp client.ParsedResponses, err error) error {
+		var errs error
+		for _, c := range checkers {
+			cerr := c(resp, err)
+			if cerr == nil {
+				return nil
+			}
+			errs = multierror.Append(errs, cerr)
+		}
+		return fmt.Errorf("no alternative passed: %v", errs)
+	}
+}
+
+// Not checks that the checker fails.
+func Not(c Checker) Checker {
+	return func(resp client.ParsedResponses, err error) error {
+		if c(resp, err) == nil {
+			return fmt.Errorf("expected check to fail")
+		}
+		return nil
+	}
+}
+
+// NoError checks that the call did not return an error.
+func NoError() Checker {
+	return func(_ client.ParsedResponses, err error) error {
+		return err
+	}
+}
+
+// Error checks that the call returned an error, such as for a denied connection.
+func Error() Checker {
+	return func(_ client.ParsedResponses, err error) error {
+		if err == nil {
+			return fmt.Errorf("expected call to fail")
+		}
+		return nil
+	}
+}
+
+// responses wraps a check of the responses, failing if the call returned an error or no responses.
+func responses(check func(resp client.ParsedResponses) error) Checker {
+	return func(resp client.ParsedResponses, err error) error {
+		if err != nil {
+			return err
+		}
+		if resp.Len() == 0 {
+			return fmt.Errorf("no responses received")
+		}
+		return check(resp)
+	}
+}
+
+// OK checks that every response has status 200.
+func OK() Checker {
+	return responses(client.ParsedResponses.CheckOK)
+}
+
+// Code checks that every response has the status code, such as "403".
+func Code(expected string) Checker {
+	return responses(func(resp client.ParsedResponses) error {
+		return resp.CheckCode(expected)
+	})
+}
+
+// Host checks that every response was for the host.
+func Host(expected string) Checker {
+	return responses(func(resp client.ParsedResponses) error {
+		return resp.CheckHost(expected)
+	})
+}
+
+// Cluster checks that every response came from the cluster.
+func Cluster(expected string) Checker {
+	return responses(func(resp client.ParsedResponses) error {
+		return resp.CheckCluster(expected)
+	})
+}
+
+// ReachedClusters checks that every one of the clusters was reached by at least one request.
+func ReachedClusters(clusters resource.Clusters) Checker {
+	return responses(func(resp client.ParsedResponses) error {
+		return resp.CheckReachedClusters(clusters)
+	})
+}
+
+// Each checks every response, returning the errors of all responses that failed.
+func Each(checkers ...ResponseChecker) Checker {
+	return responses(func(resp client.ParsedResponses) error {
+		var errs error
+		for i, r := range resp {
+			for _, c := range checkers {
+				if err := c(i, r); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("response[%d]: %v", i, err))
+				}
+			}
+		}
+		return errs
+	})
+}
+
+// Count checks the number of responses.
+func Count(expected int) Checker {
+	return func(resp client.ParsedResponses, err error) error {
+		if err != nil {
+			return err
+		}
+		if resp.Len() != expected {
+			return fmt.Errorf("expected %d responses, got %d", expected, resp.Len())
+		}
+		return nil
+	}
+}
+
+// HeaderMatches checks that the header echoed in the response matches the regular expression.
+func HeaderMatches(name, expr string) ResponseChecker {
+	re := regexp.MustCompile(expr)
+	return func(_ int, r *client.ParsedResponse) error {
+		v, ok := r.RawResponse[name]
+		if !ok {
+			return fmt.Errorf("header %s not found", name)
+		}
+		if !re.MatchString(v) {
+			return fmt.Errorf("header %s=%q does not match %q", name, v, expr)
+		}
+		return nil
+	}
+}
+
+// NoHeader checks that the header is not echoed in the response.
+func NoHeader(name string) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		if v, ok := r.RawResponse[name]; ok {
+			return fmt.Errorf("unexpected header %s=%q", name, v)
+		}
+		return nil
+	}
+}
+
+// BodyContains checks that the body of the response contains the text.
+func BodyContains(text string) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		if !strings.Contains(r.Body, text) {
+			return fmt.Errorf("body does not contain %q", text)
+		}
+		return nil
+	}
+}
+
+// BodyJSONPath checks that the JSONPath expression, such as "{.metadata.name}" or ".items[0].id", evaluates to the
+// expected value on the body of the response, which must be JSON. This applies to calls to servers other than echo,
+// whose bodies are text.
+func BodyJSONPath(path, expected string) ResponseChecker {
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	return func(_ int, r *client.ParsedResponse) error {
+		var body interface{}
+		if err := json.Unmarshal([]byte(r.Body), &body); err != nil {
+			return fmt.Errorf("body is not JSON: %v", err)
+		}
+		jp := jsonpath.New("check")
+		if err := jp.Parse(path); err != nil {
+			return fmt.Errorf("invalid JSONPath %s: %v", path, err)
+		}
+		var out bytes.Buffer
+		if err := jp.Execute(&out, body); err != nil {
+			return fmt.Errorf("failed evaluating %s: %v", path, err)
+		}
+		if got := out.String(); got != expected {
+			return fmt.Errorf("expected %s to be %q, got %q", path, expected, got)
+		}
+		return nil
+	}
+}
+
+// ForwardedClientCert checks the last element of the X-Forwarded-Client-Cert header received by the server, which is
+// appended by the proxy closest to it. The fields set in expected must match, and its URI and DNS SANs must be
+// present, so that forwardClientCertDetails settings are asserted structurally rather than by regular expressions.
+func ForwardedClientCert(expected client.ForwardedClientCert) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		got, err := lastForwardedClientCert(r)
+		if err != nil {
+			return err
+		}
+		var errs error
+		for _, f := range []struct {
+			name, expected, got string
+		}{
+			{"By", expected.By, got.By},
+			{"Hash", expected.Hash, got.Hash},
+			{"Subject", expected.Subject, got.Subject},
+			{"Cert", strings.TrimSpace(expected.Cert), strings.TrimSpace(got.Cert)},
+			{"Chain", strings.TrimSpace(expected.Chain), strings.TrimSpace(got.Chain)},
+		} {
+			if f.expected != "" && f.expected != f.got {
+				errs = multierror.Append(errs, fmt.Errorf("X-Forwarded-Client-Cert %s %q, expected %q", f.name, f.got, f.expected))
+			}
+		}
+		for _, uri := range expected.URI {
+			if !contains(got.URI, uri) {
+				errs = multierror.Append(errs, fmt.Errorf("X-Forwarded-Client-Cert URI %v, expected to contain %q", got.URI, uri))
+			}
+		}
+		for _, dns := range expected.DNS {
+			if !contains(got.DNS, dns) {
+				errs = multierror.Append(errs, fmt.Errorf("X-Forwarded-Client-Cert DNS %v, expected to contain %q", got.DNS, dns))
+			}
+		}
+		return errs
+	}
+}
+
+// ForwardedClientCertHash checks that the last element of the X-Forwarded-Client-Cert header received by the server
+// has the hash of the PEM encoded client certificate.
+func ForwardedClientCertHash(certPEM string) ResponseChecker {
+	hash, err := client.CertHash(certPEM)
+	return func(i int, r *client.ParsedResponse) error {
+		if err != nil {
+			return err
+		}
+		return ForwardedClientCert(client.ForwardedClientCert{Hash: hash})(i, r)
+	}
+}
+
+// ForwardedClientCertChainLength checks that the last element of the X-Forwarded-Client-Cert header received by the
+// server has a chain of the number of certificates, as forwarded with forwardClientCertDetails ALWAYS_FORWARD_ONLY and
+// the Chain detail enabled.
+func ForwardedClientCertChainLength(expected int) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		got, err := lastForwardedClientCert(r)
+		if err != nil {
+			return err
+		}
+		if n := got.ChainLength(); n != expected {
+			return fmt.Errorf("X-Forwarded-Client-Cert chain of %d certificates, expected %d", n, expected)
+		}
+		return nil
+	}
+}
+
+// ForwardedClientCertHops checks the number of elements of the X-Forwarded-Client-Cert header received by the server,
+// one for each proxy that appended to it rather than replacing it.
+func ForwardedClientCertHops(expected int) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		// A malformed header fails the check even if no elements are expected, rather than passing as empty.
+		if r.ForwardedClientCertErr != nil {
+			return r.ForwardedClientCertErr
+		}
+		if _, err := lastForwardedClientCert(r); err != nil && expected > 0 {
+			return err
+		}
+		if n := len(r.ForwardedClientCert); n != expected {
+			return fmt.Errorf("X-Forwarded-Client-Cert has %d elements, expected %d", n, expected)
+		}
+		return nil
+	}
+}
+
+func lastForwardedClientCert(r *client.ParsedResponse) (client.ForwardedClientCert, error) {
+	if _, ok := r.RawResponse[string(response.ForwardedClientCertField)]; !ok {
+		return client.ForwardedClientCert{}, fmt.Errorf("header %s not found", response.ForwardedClientCertField)
+	}
+	if r.ForwardedClientCertErr != nil {
+		return client.ForwardedClientCert{}, r.ForwardedClientCertErr
+	}
+	if len(r.ForwardedClientCert) == 0 {
+		return client.ForwardedClientCert{}, fmt.Errorf("header %s is empty", response.ForwardedClientCertField)
+	}
+	return r.ForwardedClientCert[len(r.ForwardedClientCert)-1], nil
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// PeerMetadata checks the metadata of the source workload observed by the proxy of the server, exposed with
+// peermetadata.Expose for sidecars, or propagated in the baggage header in ambient mode. The fields set in expected
+// must match, and its labels must be present.
+func PeerMetadata(expected client.PeerMetadata) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		got := r.PeerMetadata
+		if got == nil {
+			return fmt.Errorf("no peer metadata observed by the server")
+		}
+		var errs error
+		for _, f := range []struct {
+			name, expected, got string
+		}{
+			{"name", expected.Name, got.Name},
+			{"namespace", expected.Namespace, got.Namespace},
+			{"workload name", expected.WorkloadName, got.WorkloadName},
+			{"service account", expected.ServiceAccount, got.ServiceAccount},
+			{"cluster", expected.ClusterID, got.ClusterID},
+		} {
+			if f.expected != "" && f.expected != f.got {
+				errs = multierror.Append(errs, fmt.Errorf("peer %s %q, expected %q", f.name, f.got, f.expected))
+			}
+		}
+		for k, v := range expected.Labels {
+			if got.Labels[k] != v {
+				errs = multierror.Append(errs, fmt.Errorf("peer label %s=%q, expected %q", k, got.Labels[k], v))
+			}
+		}
+		return errs
+	}
+}
+
+// PeerIs checks that the source workload observed by the proxy of the server is a workload of the echo instance,
+// by its namespace and app label.
+func PeerIs(from echo.Instance) ResponseChecker {
+	cfg := from.Config()
+	return PeerMetadata(client.PeerMetadata{
+		Namespace: cfg.Namespace.Name(),
+		Labels:    map[string]string{"app": cfg.Service},
+	})
+}
+
+// TracePropagated checks that the server received the trace the client started, in the trace context format. The
+// call must send trace context.
+func TracePropagated(f common.TraceFormat) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		if r.SentTraceID == "" {
+			return fmt.Errorf("call sent no trace context")
+		}
+		got := r.ReceivedTraceID(f)
+		if got == "" {
+			return fmt.Errorf("no %s trace context received, expected trace %s to be propagated", f, r.SentTraceID)
+		}
+		if got != r.SentTraceID {
+			return fmt.Errorf("%s trace %s received, expected trace %s to be propagated", f, got, r.SentTraceID)
+		}
+		return nil
+	}
+}
+
+// TraceGenerated checks that the server received a trace in the trace context format that the client did not send,
+// generated by a proxy.
+func TraceGenerated(f common.TraceFormat) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		got := r.ReceivedTraceID(f)
+		if got == "" {
+			return fmt.Errorf("no %s trace context received, expected one generated by a proxy", f)
+		}
+		if got == r.SentTraceID {
+			return fmt.Errorf("%s trace %s received was sent by the client, expected one generated by a proxy", f, got)
+		}
+		return nil
+	}
+}
+
+// NoTraceContext checks that the server received no trace context in the format.
+func NoTraceContext(f common.TraceFormat) ResponseChecker {
+	return func(_ int, r *client.ParsedResponse) error {
+		if v, ok := r.RawResponse[f.Header()]; ok {
+			return fmt.Errorf("unexpected %s trace context %s=%q", f, f.Header(), v)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// forwarded returns a response with the X-Forwarded-Client-Cert header, parsed as by the client.
+func forwarded(xfcc string) client.ParsedResponses {
+	r := &client.ParsedResponse{Code: "200", RawResponse: map[string]string{"X-Forwarded-Client-Cert": xfcc}}
+	r.ForwardedClientCert, r.ForwardedClientCertErr = client.ParseForwardedClientCert(xfcc)
+	return client.ParsedResponses{r}
+}
+
+func parsed(codes ...string) client.ParsedResponses {
+	out := make(client.ParsedResponses, 0, len(codes))
+	for i, code := range codes {
+		out = append(out, &client.ParsedResponse{
+			Code:        code,
+			Host:        "b",
+			Cluster:     "cluster-0",
+			Body:        `{"name":"b","ids":[1,2]}`,
+			RawResponse: map[string]string{"X-Request-Id": strings.Repeat("x", i+1)},
+		})
+	}
+	return out
+}
+
+var (
+	pass = Checker(func(client.ParsedResponses, error) error { return nil })
+	fail = Checker(func(client.ParsedResponses, error) error { return errors.New("failed") })
+)
+
+func TestCheckers(t *testing.T) {
+	callErr := errors.New("connection refused")
+	cases := []struct {
+		name    string
+		checker Checker
+		resp    client.ParsedResponses
+		err     error
+		// failure is a substring of the expected error, or empty if the check passes.
+		failure string
+	}{
+		{name: "and", checker: And(pass, pass)},
+		{name: "and fails", checker: And(pass, fail, pass), failure: "failed"},
+		{name: "and of none", checker: And()},
+		{name: "or", checker: Or(fail, pass)},
+		{name: "or fails", checker: Or(fail, fail), failure: "no alternative passed"},
+		{name: "not", checker: Not(fail)},
+		{name: "not fails", checker: Not(pass), failure: "expected check to fail"},
+		{name: "no error", checker: NoError(), resp: parsed("200")},
+		{name: "no error fails", checker: NoError(), err: callErr, failure: "connection refused"},
+		{name: "error", checker: Error(), err: callErr},
+		{name: "error fails", checker: Error(), resp: parsed("200"), failure: "expected call to fail"},
+		{name: "not ok on error", checker: Not(OK()), err: callErr},
+		{name: "ok", checker: OK(), resp: parsed("200", "200")},
+		{name: "ok fails", checker: OK(), resp: parsed("200", "503"), failure: "503"},
+		{name: "ok without responses", checker: OK(), failure: "no responses received"},
+		{name: "ok on error", checker: OK(), resp: parsed("200"), err: callErr, failure: "connection refused"},
+		{name: "code", checker: Code("403"), resp: parsed("403")},
+		{name: "code fails", checker: Code("403"), resp: parsed("200"), failure: "403"},
+		{name: "host", checker: Host("b"), resp: parsed("200")},
+		{name: "host fails", checker: Host("c"), resp: parsed("200"), failure: "c"},
+		{name: "cluster", checker: Cluster("cluster-0"), resp: parsed("200")},
+		{name: "cluster fails", checker: Cluster("cluster-1"), resp: parsed("200"), failure: "cluster-1"},
+		{name: "count", checker: Count(2), resp: parsed("200", "200")},
+		{name: "count fails", checker: Count(3), resp: parsed("200", "200"), failure: "expected 3 responses, got 2"},
+		{name: "each", checker: Each(HeaderMatches("X-Request-Id", "^x+$"), NoHeader("X-Other")), resp: parsed("200", "200")},
+		{
+			name:    "each fails",
+			checker: Each(HeaderMatches("X-Request-Id", "^x$")),
+			resp:    parsed("200", "200"),
+			failure: "response[1]: header X-Request-Id=\"xx\" does not match",
+		},
+		{
+			name:    "each missing header",
+			checker: Each(HeaderMatches("X-Other", ".*")),
+			resp:    parsed("200"),
+			failure: "header X-Other not found",
+		},
+		{
+			name:    "no header fails",
+			checker: Each(NoHeader("X-Request-Id")),
+			resp:    parsed("200"),
+			failure: "unexpected header X-Request-Id",
+		},
+		{name: "body contains", checker: Each(BodyContains(`"name":"b"`)), resp: parsed("200")},
+		{
+			name:    "body contains fails",
+			checker: Each(BodyContains("c")),
+			resp:    parsed("200"),
+			failure: `body does not contain "c"`,
+		},
+		{
+			name:    "body JSONPath",
+			checker: Each(BodyJSONPath(".name", "b"), BodyJSONPath("{.ids[1]}", "2")),
+			resp:    parsed("200"),
+		},
+		{
+			name:    "body JSONPath fails",
+			checker: Each(BodyJSONPath(".name", "c")),
+			resp:    parsed("200"),
+			failure: `expected {.name} to be "c", got "b"`,
+		},
+		{
+			name:    "forwarded client cert",
+			checker: Each(ForwardedClientCert(client.ForwardedClientCert{By: "spiffe://b", URI: []string{"spiffe://a"}})),
+			resp:    forwarded(`By=spiffe://c;URI=spiffe://b,By=spiffe://b;URI=spiffe://a`),
+		},
+		{
+			name:    "forwarded client cert fails",
+			checker: Each(ForwardedClientCert(client.ForwardedClientCert{By: "spiffe://c"})),
+			resp:    forwarded(`By=spiffe://b`),
+			failure: `By "spiffe://b", expected "spiffe://c"`,
+		},
+		{name: "forwarded client cert hops", checker: Each(ForwardedClientCertHops(2)), resp: forwarded("By=a,By=b")},
+		{name: "forwarded client cert no hops", checker: Each(ForwardedClientCertHops(0)), resp: forwarded("")},
+		{
+			name:    "forwarded client cert malformed",
+			checker: Each(ForwardedClientCertHops(0)),
+			resp:    forwarded("By"),
+			failure: `invalid X-Forwarded-Client-Cert pair "By"`,
+		},
+		{
+			name:    "composed",
+			checker: And(OK(), Count(2), Or(Code("403"), Each(BodyContains("b"))), Not(Host("c"))),
+			resp:    parsed("200", "200"),
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.checker.Check(c.resp, c.err)
+			if c.failure == "" {
+				if err != nil {
+					t.Fatalf("expected the check to pass, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.failure) {
+				t.Fatalf("expected the check to fail with %q, got %v", c.failure, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+const (
+	// CircuitBreakerStatsPrefix must be included in SidecarStatsInclusionPrefixes on the client for the circuit breaker
+	// stats of outbound clusters to be reported.
+	CircuitBreakerStatsPrefix = "cluster.outbound"
+
+	// Envoy stats reporting circuit breaker overflows, as exposed in prometheus format.
+	UpstreamConnectionOverflow     = "envoy_cluster_upstream_cx_overflow"
+	UpstreamPendingRequestOverflow = "envoy_cluster_upstream_rq_pending_overflow"
+	UpstreamRequestRetryOverflow   = "envoy_cluster_upstream_rq_retry_overflow"
+
+	overloadedHeader = "X-Envoy-Overloaded"
+)
+
+// ConcurrentCallOptions configures CallConcurrently.
+type ConcurrentCallOptions struct {
+	// Call options for each of the requests. Count is ignored; a single request is made by each caller.
+	Call CallOptions
+
+	// Concurrency is the number of requests in flight at the same time. Defaults to 1.
+	Concurrency int
+
+	// Hold is how long the target holds each request before responding. Holding requests makes the number of
+	// concurrent connections and pending requests at the client proxy deterministic, so that connection pool limits
+	// are reliably exceeded. Defaults to 2s.
+	Hold time.Duration
+}
+
+// ConcurrentCallResult summarizes the outcome of CallConcurrently.
+type ConcurrentCallResult struct {
+	// Responses that were received, including those rejected by the proxy.
+	Responses client.ParsedResponses
+	// Overflowed is the number of requests rejected by the client proxy due to circuit breaking.
+	Overflowed int
+	// Errors is the number of requests that failed without a response.
+	Errors int
+}
+
+// CallConcurrently makes Concurrency simultaneous requests from the source to the target, each held open by the
+// target for the Hold duration, and reports how many were rejected by circuit breaking.
+func CallConcurrently(from Instance, opts ConcurrentCallOptions) ConcurrentCallResult {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Hold <= 0 {
+		opts.Hold = 2 * time.Second
+	}
+
+	callOpts := opts.Call
+	callOpts.Count = 1
+	callOpts.ResponseDelay = opts.Hold
+	if callOpts.Timeout <= opts.Hold {
+		callOpts.Timeout = opts.Hold + 10*time.Second
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result ConcurrentCallResult
+	)
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := from.Call(callOpts)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Errors++
+				return
+			}
+			result.Responses = append(result.Responses, resp...)
+			for _, r := range resp {
+				if r.RawResponse[overloadedHeader] == "true" {
+					result.Overflowed++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return result
+}
+
+// OutboundClusterName returns the name of the Envoy cluster used by clients to reach the port of the target.
+func OutboundClusterName(target Instance, port Port) string {
+	return fmt.Sprintf("outbound|%d||%s", port.ServicePort, target.Config().FQDN())
+}
+
+// ClusterStat returns the value of the Envoy cluster stat for the given cluster, or 0 if it is not reported.
+func ClusterStat(stats map[string]*dto.MetricFamily, stat, clusterName string) float64 {
+	mf, ok := stats[stat]
+	if !ok {
+		return 0
+	}
+	total := 0.0
+	for _, m := range mf.Metric {
+		for _, l := range m.Label {
+			if l.GetName() == "cluster_name" && l.GetValue() == clusterName {
+				switch {
+				case m.Counter != nil:
+					total += m.Counter.GetValue()
+				case m.Gauge != nil:
+					total += m.Gauge.GetValue()
+				case m.Untyped != nil:
+					total += m.Untyped.GetValue()
+				}
+			}
+		}
+	}
+	return total
+}
+
+// CheckClusterStat verifies that the Envoy cluster stat reported by the sidecar has exactly the expected value.
+func CheckClusterStat(s Sidecar, stat, clusterName string, expected float64) error {
+	stats, err := s.Stats()
+	if err != nil {
+		return err
+	}
+	if got := ClusterStat(stats, stat, clusterName); got != expected {
+		return fmt.Errorf("%s for %s: expected %v, got %v", stat, clusterName, expected, got)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// poolInstance admits the first requests, up to its limit, rejects the others as overloaded, and fails the last.
+type poolInstance struct {
+	Instance
+	mu    sync.Mutex
+	calls []CallOptions
+	limit int
+	total int
+}
+
+func (p *poolInstance) Call(opts CallOptions) (client.ParsedResponses, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, opts)
+	switch n := len(p.calls); {
+	case n == p.total:
+		return nil, errors.New("timeout")
+	case n > p.limit:
+		return client.ParsedResponses{{Code: "503", RawResponse: map[string]string{overloadedHeader: "true"}}}, nil
+	}
+	return client.ParsedResponses{{Code: "200", RawResponse: map[string]string{}}}, nil
+}
+
+func TestCallConcurrently(t *testing.T) {
+	from := &poolInstance{limit: 2, total: 10}
+	result := CallConcurrently(from, ConcurrentCallOptions{Concurrency: 10, Hold: time.Second})
+	if result.Overflowed != 7 || result.Errors != 1 || len(result.Responses) != 9 {
+		t.Fatalf("got %d overflowed, %d errors and %d responses, want 7, 1 and 9", result.Overflowed, result.Errors,
+			len(result.Responses))
+	}
+	for _, opts := range from.calls {
+		if opts.Count != 1 || opts.ResponseDelay != time.Second || opts.Timeout <= time.Second {
+			t.Fatalf("expected a single request held for 1s, got %+v", opts)
+		}
+	}
+}
+
+func counter(cluster string, value float64) *dto.Metric {
+	return &dto.Metric{
+		Label:   []*dto.LabelPair{{Name: proto.String("cluster_name"), Value: proto.String(cluster)}},
+		Counter: &dto.Counter{Value: proto.Float64(value)},
+	}
+}
+
+func TestClusterStat(t *testing.T) {
+	target := directTarget(false)
+	cluster := OutboundClusterName(target, target.Config().Ports[0])
+	if cluster != "outbound|80||b.ns.svc.cluster.local" {
+		t.Fatalf("got cluster name %s", cluster)
+	}
+	stats := map[string]*dto.MetricFamily{
+		UpstreamPendingRequestOverflow: {Metric: []*dto.Metric{
+			counter(cluster, 7),
+			counter("outbound|80||c.ns.svc.cluster.local", 3),
+		}},
+	}
+	if got := ClusterStat(stats, UpstreamPendingRequestOverflow, cluster); got != 7 {
+		t.Fatalf("got %v, want 7", got)
+	}
+	if got := ClusterStat(stats, UpstreamConnectionOverflow, cluster); got != 0 {
+		t.Fatalf("got %v for a stat not reported, want 0", got)
+	}
+
+	s := fakeSidecar{stats: stats}
+	if err := CheckClusterStat(s, UpstreamPendingRequestOverflow, cluster, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckClusterStat(s, UpstreamPendingRequestOverflow, cluster, 8); err == nil {
+		t.Fatal("expected a different value to fail the check")
+	}
+}
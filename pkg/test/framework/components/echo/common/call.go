@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/test/echo/client"
@@ -32,6 +33,9 @@ import (
 	"istio.io/istio/pkg/test/framework/components/echo"
 )
 
+// PaddingHeader is the header added to pad requests to the size given by CallOptions.HeaderSize.
+const PaddingHeader = "X-Padding"
+
 func callInternal(opts *echo.CallOptions, send func(req *proto.ForwardEchoRequest) (client.ParsedResponses, error)) (client.ParsedResponses, error) {
 	if err := fillInCallOptions(opts); err != nil {
 		return nil, err
@@ -73,6 +77,10 @@ func callInternal(opts *echo.CallOptions, send func(req *proto.ForwardEchoReques
 		CaCert:        opts.CaCert,
 	}
 
+	if opts.Session != nil {
+		return callSession(opts, req, send)
+	}
+
 	resp, err := send(req)
 	if err != nil {
 		return nil, err
@@ -84,6 +92,58 @@ func callInternal(opts *echo.CallOptions, send func(req *proto.ForwardEchoReques
 	return resp, err
 }
 
+// callSession sends the requests sequentially, passing the session returned in each response on to the next request.
+func callSession(opts *echo.CallOptions, req *proto.ForwardEchoRequest,
+	send func(req *proto.ForwardEchoRequest) (client.ParsedResponses, error)) (client.ParsedResponses, error) {
+	if (opts.Session.Cookie == "") == (opts.Session.Header == "") {
+		return nil, errors.New("callOptions: exactly one of session cookie or header must be set")
+	}
+	headers := req.Headers
+	var session *proto.Header
+	out := make(client.ParsedResponses, 0, opts.Count)
+	for i := 0; i < opts.Count; i++ {
+		r := *req
+		r.Count = 1
+		r.Headers = headers
+		if session != nil {
+			r.Headers = append(append([]*proto.Header{}, headers...), session)
+		}
+		resp, err := send(&r)
+		if err != nil {
+			return nil, fmt.Errorf("request %d in session: %v", i, err)
+		}
+		if len(resp) != 1 {
+			return nil, fmt.Errorf("unexpected number of responses: expected 1, received %d", len(resp))
+		}
+		out = append(out, resp[0])
+		if s := sessionFromResponse(opts.Session, resp[0]); s != nil {
+			session = s
+		}
+	}
+	return out, nil
+}
+
+// sessionFromResponse returns the header that continues the session established by the response, if any.
+func sessionFromResponse(s *echo.SessionOptions, r *client.ParsedResponse) *proto.Header {
+	if s.Header != "" {
+		if v, ok := r.RawResponse[http.CanonicalHeaderKey(s.Header)]; ok {
+			return &proto.Header{Key: s.Header, Value: v}
+		}
+		return nil
+	}
+	setCookie, ok := r.RawResponse["Set-Cookie"]
+	if !ok {
+		return nil
+	}
+	resp := http.Response{Header: http.Header{"Set-Cookie": []string{setCookie}}}
+	for _, c := range resp.Cookies() {
+		if c.Name == s.Cookie {
+			return &proto.Header{Key: "Cookie", Value: c.Name + "=" + c.Value}
+		}
+	}
+	return nil
+}
+
 func CallEcho(opts *echo.CallOptions) (client.ParsedResponses, error) {
 	return callInternal(opts, func(req *proto.ForwardEchoRequest) (client.ParsedResponses, error) {
 		instance, err := forwarder.New(forwarder.Config{
@@ -175,6 +235,115 @@ func fillInCallOptions(opts *echo.CallOptions) error {
 		}
 	}
 
+	if opts.RequestID == "" {
+		opts.RequestID = opts.Headers.Get(echo.RequestIDHeader)
+	}
+	if opts.RequestID == "" {
+		opts.RequestID = echo.NewRequestID()
+	}
+	if opts.Headers.Get(echo.RequestIDHeader) != opts.RequestID {
+		// Avoid modifying the headers of the caller.
+		opts.Headers = opts.Headers.Clone()
+		opts.Headers.Set(echo.RequestIDHeader, opts.RequestID)
+	}
+
+	if opts.BodySize > 0 {
+		opts.Message = strings.Repeat("a", opts.BodySize)
+		if opts.Method == "" {
+			opts.Method = http.MethodPost
+		}
+	}
+
+	if opts.BodySize > 0 || opts.StreamBody || opts.HeaderSize > 0 {
+		// Avoid modifying the headers of the caller.
+		opts.Headers = opts.Headers.Clone()
+		if opts.BodySize > 0 {
+			// The body is sent even if the method does not take one.
+			opts.Headers.Set(forwarder.ContentLengthHeader, strconv.Itoa(opts.BodySize))
+		}
+		if opts.StreamBody {
+			opts.Headers.Set(forwarder.TransferEncodingHeader, "chunked")
+		}
+		if opts.HeaderSize > 0 {
+			opts.Headers.Set(PaddingHeader, strings.Repeat("a", opts.HeaderSize))
+		}
+	}
+
+	if opts.Socket != nil || opts.Idle > 0 {
+		if opts.Scheme != scheme.TCP {
+			return errors.New("callOptions: socket options and idle connections are only supported for TCP calls")
+		}
+		// Avoid modifying the headers of the caller.
+		opts.Headers = opts.Headers.Clone()
+		if opts.Socket != nil {
+			for k, v := range opts.Socket.Headers() {
+				opts.Headers[k] = v
+			}
+		}
+		if opts.Idle > 0 {
+			opts.Headers.Set(common.IdleHeader, opts.Idle.String())
+		}
+	}
+
+	if opts.TLS != nil {
+		if opts.Scheme != scheme.HTTPS && opts.Scheme != scheme.TCP && opts.Scheme != scheme.GRPC {
+			return errors.New("callOptions: TLS options are only supported for HTTPS, TCP and GRPC calls")
+		}
+		// Avoid modifying the headers of the caller.
+		opts.Headers = opts.Headers.Clone()
+		for k, v := range opts.TLS.Headers() {
+			opts.Headers[k] = v
+		}
+	}
+
+	if len(opts.TraceContext) > 0 {
+		if opts.Scheme != scheme.HTTP && opts.Scheme != scheme.HTTPS {
+			return errors.New("callOptions: trace context is only supported for HTTP calls")
+		}
+		for _, f := range opts.TraceContext {
+			if err := f.Valid(); err != nil {
+				return fmt.Errorf("callOptions: %v", err)
+			}
+		}
+		// Avoid modifying the headers of the caller.
+		opts.Headers = opts.Headers.Clone()
+		opts.Headers.Set(common.TraceContextHeader, common.TraceFormatsHeader(opts.TraceContext))
+	}
+
+	if opts.Fault != nil {
+		if opts.Scheme != scheme.HTTP && opts.Scheme != scheme.HTTPS {
+			return errors.New("callOptions: faults are only supported for HTTP calls")
+		}
+		if err := opts.Fault.Validate(); err != nil {
+			return fmt.Errorf("callOptions: %v", err)
+		}
+		// Avoid modifying the headers of the caller.
+		opts.Headers = opts.Headers.Clone()
+		for k, v := range opts.Fault.Headers() {
+			opts.Headers[k] = v
+		}
+	}
+
+	if opts.Session != nil && opts.Session.Issue {
+		session := "cookie:" + opts.Session.Cookie
+		if opts.Session.Header != "" {
+			session = "header:" + opts.Session.Header
+		}
+		sep := "?"
+		if strings.Contains(opts.Path, "?") {
+			sep = "&"
+		}
+		opts.Path = fmt.Sprintf("%s%ssession=%s", opts.Path, sep, session)
+	}
+
+	if opts.ResponseDelay > 0 {
+		sep := "?"
+		if strings.Contains(opts.Path, "?") {
+			sep = "&"
+		}
+		opts.Path = fmt.Sprintf("%s%sdelay=%s", opts.Path, sep, opts.ResponseDelay)
+	}
+
 	if opts.Timeout <= 0 {
 		opts.Timeout = common.DefaultRequestTimeout
 	}
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/proto"
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+func TestSessionFromResponse(t *testing.T) {
+	cases := []struct {
+		name    string
+		session echo.SessionOptions
+		raw     map[string]string
+		want    *proto.Header
+	}{
+		{
+			name:    "session cookie",
+			session: echo.SessionOptions{Cookie: "s"},
+			raw:     map[string]string{"Set-Cookie": "s=abc; Path=/; HttpOnly"},
+			want:    &proto.Header{Key: "Cookie", Value: "s=abc"},
+		},
+		{
+			name:    "other cookie",
+			session: echo.SessionOptions{Cookie: "s"},
+			raw:     map[string]string{"Set-Cookie": "other=abc; Path=/"},
+		},
+		{
+			name:    "no cookie",
+			session: echo.SessionOptions{Cookie: "s"},
+			raw:     map[string]string{},
+		},
+		{
+			name:    "header",
+			session: echo.SessionOptions{Header: "x-session"},
+			raw:     map[string]string{"X-Session": "abc"},
+			want:    &proto.Header{Key: "x-session", Value: "abc"},
+		},
+		{
+			name:    "no header",
+			session: echo.SessionOptions{Header: "x-session"},
+			raw:     map[string]string{"Set-Cookie": "x-session=abc"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := sessionFromResponse(&tc.session, &client.ParsedResponse{RawResponse: tc.raw})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCallSession(t *testing.T) {
+	opts := &echo.CallOptions{Count: 3, Session: &echo.SessionOptions{Cookie: "s"}}
+	var sent [][]*proto.Header
+	send := func(req *proto.ForwardEchoRequest) (client.ParsedResponses, error) {
+		sent = append(sent, req.Headers)
+		raw := map[string]string{}
+		if len(sent) == 1 {
+			raw["Set-Cookie"] = "s=abc"
+		}
+		return client.ParsedResponses{{RawResponse: raw}}, nil
+	}
+	if _, err := callSession(opts, &proto.ForwardEchoRequest{Count: 3}, send); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]*proto.Header{nil, {{Key: "Cookie", Value: "s=abc"}}, {{Key: "Cookie", Value: "s=abc"}}}
+	if !reflect.DeepEqual(sent, want) {
+		t.Fatalf("got headers %v, want %v", sent, want)
+	}
+}
@@ -64,7 +64,16 @@ func FillInDefaults(ctx resource.Context, defaultDomain string, c *echo.Config)
 
 	// Mark all user-defined ports as used, so the port generator won't assign them.
 	portGen := newPortGenerators()
+	// Restricted workloads cannot bind privileged ports, so their instance ports are unprivileged.
+	restricted := ctx.Settings().PodSecurityRestricted
+	if restricted {
+		portGen.Instance.Unprivileged()
+	}
 	for _, p := range c.Ports {
+		if restricted && p.InstancePort > 0 && p.InstancePort < MinUnprivilegedPort {
+			return fmt.Errorf("failed configuring port %s: instance port %d is privileged, which the restricted "+
+				"PodSecurity profile does not allow", p.Name, p.InstancePort)
+		}
 		if p.ServicePort > 0 {
 			if portGen.Service.IsUsed(p.ServicePort) {
 				return fmt.Errorf("failed configuring port %s: service port already used %d", p.Name, p.ServicePort)
@@ -79,6 +88,10 @@ func FillInDefaults(ctx resource.Context, defaultDomain string, c *echo.Config)
 		}
 	}
 	for _, p := range c.WorkloadOnlyPorts {
+		if restricted && p.Port > 0 && p.Port < MinUnprivilegedPort {
+			return fmt.Errorf("failed configuring workload only port %d: the port is privileged, which the "+
+				"restricted PodSecurity profile does not allow", p.Port)
+		}
 		if p.Port > 0 {
 			if portGen.Instance.IsUsed(p.Port) {
 				return fmt.Errorf("failed configuring workload only port %d: port already used", p.Port)
@@ -93,6 +106,9 @@ func FillInDefaults(ctx resource.Context, defaultDomain string, c *echo.Config)
 
 	// Second pass: try to make unassigned instance ports match service port.
 	for i, p := range c.Ports {
+		if restricted && p.ServicePort < MinUnprivilegedPort {
+			continue
+		}
 		if p.InstancePort <= 0 && p.ServicePort > 0 && !portGen.Instance.IsUsed(p.ServicePort) {
 			c.Ports[i].InstancePort = p.ServicePort
 			portGen.Instance.SetUsed(p.ServicePort)
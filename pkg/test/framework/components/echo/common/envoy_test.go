@@ -82,6 +82,14 @@ func (*testConfig) WorkloadsOrFail(t test.Failer) []echo.Workload {
 	panic("not implemented")
 }
 
+func (*testConfig) Refresh() error {
+	panic("not implemented")
+}
+
+func (*testConfig) RefreshOrFail(t test.Failer) {
+	panic("not implemented")
+}
+
 func (*testConfig) WaitUntilCallable(_ ...echo.Instance) error {
 	panic("not implemented")
 }
@@ -98,6 +106,10 @@ func (*testConfig) CallOrFail(_ test.Failer, _ echo.CallOptions) client.ParsedRe
 	panic("not implemented")
 }
 
+func (*testConfig) NodeName() string {
+	return ""
+}
+
 func (*testConfig) Sidecar() echo.Sidecar {
 	panic("not implemented")
 }
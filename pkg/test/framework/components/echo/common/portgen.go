@@ -25,6 +25,11 @@ const (
 	httpsBase = 443
 	grpcBase  = 7070
 	tcpBase   = 9090
+
+	// MinUnprivilegedPort is the lowest port that can be bound without the NET_BIND_SERVICE capability.
+	MinUnprivilegedPort = 1024
+	// unprivilegedOffset is added to the privileged ports the generator would assign otherwise.
+	unprivilegedOffset = 10000
 )
 
 // portGenerators creates a set of generators for service and instance ports.
@@ -66,6 +71,17 @@ func newPortGenerator() *portGenerator {
 	}
 }
 
+// Unprivileged makes the generator assign only ports that can be bound without the NET_BIND_SERVICE capability,
+// such as 10080 rather than 80 for HTTP.
+func (g *portGenerator) Unprivileged() *portGenerator {
+	for p, v := range g.next {
+		if v < MinUnprivilegedPort {
+			g.next[p] = v + unprivilegedOffset
+		}
+	}
+	return g
+}
+
 // SetUsed marks the given port as used, so that it will not be assigned by the
 // generator.
 func (g *portGenerator) SetUsed(port int) *portGenerator {
@@ -0,0 +1,39 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/config/protocol"
+)
+
+func TestUnprivilegedPortGenerator(t *testing.T) {
+	g := newPortGenerator().Unprivileged().SetUsed(10080)
+	for _, tt := range []struct {
+		protocol protocol.Instance
+		want     int
+	}{
+		{protocol.HTTP, 10081},
+		{protocol.HTTPS, 10443},
+		{protocol.TLS, 10444},
+		{protocol.GRPC, grpcBase},
+		{protocol.TCP, tcpBase},
+	} {
+		if got := g.Next(tt.protocol); got != tt.want {
+			t.Errorf("Next(%s): got %d, want %d", tt.protocol, got, tt.want)
+		}
+	}
+}
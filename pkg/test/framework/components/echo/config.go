@@ -47,10 +47,18 @@ type Config struct {
 	// Headless (k8s only) indicates that no ClusterIP should be specified.
 	Headless bool
 
+	// ServiceAccountAnnotations (k8s only) are annotations on the created service account. Only used if
+	// ServiceAccount is true.
+	ServiceAccountAnnotations map[string]string
+
 	// ServiceAccount (k8s only) indicates that a service account should be created
 	// for the deployment.
 	ServiceAccount bool
 
+	// ServiceAccountToken (k8s only) customizes the token the sidecar authenticates to istiod with. If unset, the
+	// sidecar uses the token injected for it.
+	ServiceAccountToken *ServiceAccountToken
+
 	// Ports for this application. Port numbers may or may not be used, depending
 	// on the implementation.
 	Ports []Port
@@ -76,6 +84,26 @@ type Config struct {
 	// TLS settings for echo server
 	TLSSettings *common.TLSSettings
 
+	// Placement (k8s only) is how the pods of each subset are placed on the nodes of the cluster. Defaults to a
+	// Deployment of a single replica.
+	Placement Placement
+
+	// HostNetwork (k8s only) runs the pods in the network namespace of their node. Sidecars are never injected into
+	// such pods, and their ports are bound on the node, so that pods listening on the same ports, such as other
+	// subsets or replicas, must be placed on different nodes, as with PlacementPerNode.
+	HostNetwork bool
+
+	// HTTPLivenessProbe (k8s only) probes the liveness of the application over HTTP, so that SetLive can make
+	// Kubernetes restart it. Defaults to probing its TCP health port, which is live as long as the application runs.
+	HTTPLivenessProbe bool
+
+	// InitCalls (k8s only) are calls made by init containers of the pods, before the application starts, such as to
+	// check whether the traffic of init containers is captured before the proxy is ready.
+	InitCalls []InitCall
+
+	// Socket (k8s only) sets the options of the connections accepted on TCP ports, such as their keepalive and linger.
+	Socket *common.SocketOptions
+
 	// If enabled, echo will be deployed as a "VM". This means it will run Envoy in the same pod as echo,
 	// disable sidecar injection, etc.
 	DeployAsVM bool
@@ -87,6 +115,48 @@ type Config struct {
 	VMEnvironment map[string]string
 }
 
+// ServiceAccountToken configures the service account token of the pods, which the sidecar authenticates to istiod
+// with instead of the token injected for it.
+type ServiceAccountToken struct {
+	// Audience of a projected token. If neither Audience nor ExpirationSeconds is set, the automounted token is used.
+	Audience string
+	// ExpirationSeconds of a projected token. Must be at least 600.
+	ExpirationSeconds int64
+	// DisableAutomount disables mounting the service account token, so that without a projected token the sidecar
+	// has no token to authenticate with.
+	DisableAutomount bool
+}
+
+// Projected returns true if a projected token is mounted rather than the automounted token.
+func (t ServiceAccountToken) Projected() bool {
+	return t.Audience != "" || t.ExpirationSeconds > 0
+}
+
+// Placement is how the pods of an echo instance are placed on the nodes of the cluster.
+type Placement string
+
+const (
+	// PlacementDeployment deploys a single replica, on a node picked by the scheduler.
+	PlacementDeployment Placement = ""
+	// PlacementDaemonSet deploys a DaemonSet, running a pod on every node and tolerating all taints, as node agents
+	// such as ztunnel do.
+	PlacementDaemonSet Placement = "DaemonSet"
+	// PlacementPerNode deploys a replica per schedulable node, with pod anti-affinity pinning a single replica to
+	// each of them, so that the pods are spread on nodes as application workloads are.
+	PlacementPerNode Placement = "PerNode"
+)
+
+// InitCall is a call made with the echo client by an init container, named init-call-<Name>. The container always
+// succeeds, so that the pod starts whatever the outcome, and reports it in its termination message as "succeeded" or
+// "failed".
+type InitCall struct {
+	Name string
+	// URL called, such as "http://b:80".
+	URL string
+	// RunAsProxyUser runs the container with the UID of the proxy, 1337, whose traffic is not captured.
+	RunAsProxyUser bool
+}
+
 // SubsetConfig is the config for a group of Subsets (e.g. Kubernetes deployment).
 type SubsetConfig struct {
 	// The version of the deployment.
@@ -0,0 +1,173 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DirectCallCase is a call made directly to a single workload of a target, bypassing the service VIP.
+type DirectCallCase struct {
+	// Name describes the case, for use as a test name.
+	Name string
+	// Options for the call.
+	Options CallOptions
+	// ExpectSuccess indicates whether the call is expected to succeed.
+	ExpectSuccess bool
+}
+
+// PodIPCallOptions returns options for calling the workload's IP directly on the named port. Since the service port
+// is not involved, the call is made to the instance port. The Target is left unset, since the port no longer
+// matches one of the target's service ports.
+func PodIPCallOptions(target Instance, w Workload, portName string) (CallOptions, error) {
+	p := target.Config().PortByName(portName)
+	if p == nil {
+		return CallOptions{}, fmt.Errorf("no port %s on %s", portName, target.Config().Service)
+	}
+	direct := *p
+	direct.ServicePort = p.InstancePort
+	return CallOptions{
+		Port:       &direct,
+		Host:       w.Address(),
+		HostHeader: target.Config().HostHeader(),
+	}, nil
+}
+
+// PodDNSName returns the per-pod DNS name of the workload for a headless service, of the form
+// <dashed-ip>.<service>.<namespace>.svc.<domain>.
+func PodDNSName(target Instance, w Workload) string {
+	ip := w.Address()
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		ip = strings.ReplaceAll(ip, ":", "-")
+	} else {
+		ip = strings.ReplaceAll(ip, ".", "-")
+	}
+	return ip + "." + target.Config().FQDN()
+}
+
+// PodDNSCallOptions returns options for calling the workload through its per-pod DNS name. The target must be
+// headless.
+func PodDNSCallOptions(target Instance, w Workload, portName string) (CallOptions, error) {
+	if !target.Config().Headless {
+		return CallOptions{}, fmt.Errorf("%s is not headless", target.Config().Service)
+	}
+	opts, err := PodIPCallOptions(target, w, portName)
+	if err != nil {
+		return CallOptions{}, err
+	}
+	opts.Host = PodDNSName(target, w)
+	return opts, nil
+}
+
+// DirectCallKind is the way a direct call addresses a workload.
+type DirectCallKind string
+
+const (
+	// PodIPCall calls the IP of the workload.
+	PodIPCall DirectCallKind = "pod ip"
+	// PodDNSCall calls the per-pod DNS name of the workload. Only headless services have these records.
+	PodDNSCall DirectCallKind = "pod dns"
+)
+
+// DirectCallMode is the combination of source, target and outbound traffic policy a direct call is made in.
+type DirectCallMode struct {
+	// Sidecar indicates the source has a sidecar intercepting its outbound traffic.
+	Sidecar bool
+	// Headless indicates the target service is headless.
+	Headless bool
+	// RegistryOnly indicates the outbound traffic policy is REGISTRY_ONLY, so the passthrough cluster is not
+	// available to the source.
+	RegistryOnly bool
+}
+
+// directCallMatrix is the expected outcome of each kind of direct call in every mode. Without a sidecar, traffic
+// is not intercepted and all calls succeed. With a sidecar, calls to a headless target match the per-endpoint
+// listeners and succeed, while calls to a pod IP of a ClusterIP target only go through the passthrough cluster,
+// so they fail when the outbound traffic policy is REGISTRY_ONLY.
+//
+// Pod DNS calls to ClusterIP targets are left out, since there is no per-pod record to resolve. Ambient sources
+// are left out as well, since there is no ambient data plane in this tree.
+var directCallMatrix = map[DirectCallMode]map[DirectCallKind]bool{
+	{Sidecar: false, Headless: false, RegistryOnly: false}: {PodIPCall: true},
+	{Sidecar: false, Headless: false, RegistryOnly: true}:  {PodIPCall: true},
+	{Sidecar: false, Headless: true, RegistryOnly: false}:  {PodIPCall: true, PodDNSCall: true},
+	{Sidecar: false, Headless: true, RegistryOnly: true}:   {PodIPCall: true, PodDNSCall: true},
+	{Sidecar: true, Headless: false, RegistryOnly: false}:  {PodIPCall: true},
+	{Sidecar: true, Headless: false, RegistryOnly: true}:   {PodIPCall: false},
+	{Sidecar: true, Headless: true, RegistryOnly: false}:   {PodIPCall: true, PodDNSCall: true},
+	{Sidecar: true, Headless: true, RegistryOnly: true}:    {PodIPCall: true, PodDNSCall: true},
+}
+
+// DirectCallExpectations returns the kinds of direct call that can be made in the mode, along with whether they are
+// expected to succeed.
+func DirectCallExpectations(mode DirectCallMode) map[DirectCallKind]bool {
+	return directCallMatrix[mode]
+}
+
+// DirectCallCases returns the direct calls that can be made from the source to each workload of the target, along
+// with whether they are expected to succeed. See DirectCallExpectations for the outcome of each mode.
+func DirectCallCases(from, to Instance, portName string, registryOnly bool) ([]DirectCallCase, error) {
+	workloads, err := to.Workloads()
+	if err != nil {
+		return nil, err
+	}
+	expect := DirectCallExpectations(DirectCallMode{
+		Sidecar:      HasSidecar(from.Config()),
+		Headless:     to.Config().Headless,
+		RegistryOnly: registryOnly,
+	})
+	var cases []DirectCallCase
+	for _, w := range workloads {
+		for _, kind := range []DirectCallKind{PodIPCall, PodDNSCall} {
+			success, ok := expect[kind]
+			if !ok {
+				continue
+			}
+			var opts CallOptions
+			if kind == PodDNSCall {
+				opts, err = PodDNSCallOptions(to, w, portName)
+			} else {
+				opts, err = PodIPCallOptions(to, w, portName)
+			}
+			if err != nil {
+				return nil, err
+			}
+			cases = append(cases, DirectCallCase{
+				Name:          fmt.Sprintf("%s->%s %s %s", from.Config().Service, to.Config().Service, kind, opts.Host),
+				Options:       opts,
+				ExpectSuccess: success,
+			})
+		}
+	}
+	return cases, nil
+}
+
+// HasSidecar returns true if any of the subsets in the config are deployed with a sidecar.
+func HasSidecar(cfg Config) bool {
+	if cfg.HostNetwork {
+		return false
+	}
+	if len(cfg.Subsets) == 0 {
+		return true
+	}
+	for _, s := range cfg.Subsets {
+		if s.Annotations.GetBool(SidecarInject) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+)
+
+type fakeWorkload struct {
+	Workload
+	address string
+	sidecar Sidecar
+}
+
+func (w fakeWorkload) Address() string {
+	return w.address
+}
+
+func (w fakeWorkload) Sidecar() Sidecar {
+	return w.sidecar
+}
+
+type fakeSidecar struct {
+	Sidecar
+	nodeID string
+	stats  map[string]*dto.MetricFamily
+}
+
+func (s fakeSidecar) Stats() (map[string]*dto.MetricFamily, error) {
+	return s.stats, nil
+}
+
+func (s fakeSidecar) NodeID() string {
+	return s.nodeID
+}
+
+func directTarget(headless bool, addresses ...string) FakeInstance {
+	target := FakeInstance{ConfigValue: Config{
+		Service:   "b",
+		Namespace: namespace.Fake{NameValue: "ns"},
+		Domain:    "cluster.local",
+		Headless:  headless,
+		Ports:     []Port{{Name: "http", Protocol: protocol.HTTP, ServicePort: 80, InstancePort: 8090}},
+	}}
+	for _, a := range addresses {
+		target.WorkloadsValue = append(target.WorkloadsValue, fakeWorkload{address: a})
+	}
+	return target
+}
+
+func TestPodIPCallOptions(t *testing.T) {
+	target := directTarget(false)
+	opts, err := PodIPCallOptions(target, fakeWorkload{address: "10.0.0.1"}, "http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Host != "10.0.0.1" || opts.Port.ServicePort != 8090 || opts.HostHeader != "b.ns.svc.cluster.local" {
+		t.Fatalf("expected a call to the instance port of the pod IP, got %+v", opts)
+	}
+	if opts.Target != nil {
+		t.Fatal("expected the target to be left unset")
+	}
+	if target.Config().PortByName("http").ServicePort != 80 {
+		t.Fatal("expected the port of the target to be left unchanged")
+	}
+	if _, err := PodIPCallOptions(target, fakeWorkload{address: "10.0.0.1"}, "grpc"); err == nil {
+		t.Fatal("expected an error for an unknown port")
+	}
+}
+
+func TestPodDNSName(t *testing.T) {
+	target := directTarget(true)
+	for address, want := range map[string]string{
+		"10.0.0.1": "10-0-0-1.b.ns.svc.cluster.local",
+		"fd00::1":  "fd00--1.b.ns.svc.cluster.local",
+	} {
+		if got := PodDNSName(target, fakeWorkload{address: address}); got != want {
+			t.Fatalf("got %s for %s, want %s", got, address, want)
+		}
+	}
+
+	opts, err := PodDNSCallOptions(target, fakeWorkload{address: "10.0.0.1"}, "http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Host != "10-0-0-1.b.ns.svc.cluster.local" {
+		t.Fatalf("expected a call to the pod DNS name, got %+v", opts)
+	}
+	if _, err := PodDNSCallOptions(directTarget(false), fakeWorkload{address: "10.0.0.1"}, "http"); err == nil {
+		t.Fatal("expected an error for a target that is not headless")
+	}
+}
+
+func TestDirectCallCases(t *testing.T) {
+	sidecar := FakeInstance{ConfigValue: Config{Service: "a"}}
+	noSidecar := FakeInstance{ConfigValue: Config{Service: "a", Subsets: []SubsetConfig{{
+		Annotations: NewAnnotations().SetBool(SidecarInject, false),
+	}}}}
+	hostNetwork := FakeInstance{ConfigValue: Config{Service: "a", HostNetwork: true}}
+	cases := []struct {
+		name         string
+		from         Instance
+		to           Instance
+		registryOnly bool
+		// want is the expected success of the cases, in order.
+		want []bool
+	}{
+		{"sidecar to ClusterIP", sidecar, directTarget(false, "10.0.0.1", "10.0.0.2"), false, []bool{true, true}},
+		{"sidecar to ClusterIP registry only", sidecar, directTarget(false, "10.0.0.1"), true, []bool{false}},
+		{"sidecar to headless registry only", sidecar, directTarget(true, "10.0.0.1"), true, []bool{true, true}},
+		{"no sidecar to ClusterIP registry only", noSidecar, directTarget(false, "10.0.0.1"), true, []bool{true}},
+		{"host network to ClusterIP registry only", hostNetwork, directTarget(false, "10.0.0.1"), true, []bool{true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DirectCallCases(tc.from, tc.to, "http", tc.registryOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d cases, want %d: %+v", len(got), len(tc.want), got)
+			}
+			for i, c := range got {
+				if c.ExpectSuccess != tc.want[i] {
+					t.Fatalf("case %s: got success %v, want %v", c.Name, c.ExpectSuccess, tc.want[i])
+				}
+			}
+		})
+	}
+}
@@ -68,11 +68,40 @@ type Instance interface {
 	Workloads() ([]Workload, error)
 	WorkloadsOrFail(t test.Failer) []Workload
 
+	// Refresh waits until the pods of the instance are ready and re-resolves its workloads, replacing those of pods
+	// that no longer exist, such as after a restart or a scale down and up. Workloads retrieved before are stale.
+	Refresh() error
+	RefreshOrFail(t test.Failer)
+
 	// Call makes a call from this Instance to a target Instance.
 	Call(options CallOptions) (client.ParsedResponses, error)
 	CallOrFail(t test.Failer, options CallOptions) client.ParsedResponses
 }
 
+var _ Instance = FakeInstance{}
+
+// FakeInstance used for testing. It serves its config, address and workloads, and panics on any other method
+// unless a test embeds it and overrides that method.
+type FakeInstance struct {
+	Instance
+
+	ConfigValue    Config
+	AddressValue   string
+	WorkloadsValue []Workload
+}
+
+func (f FakeInstance) Config() Config {
+	return f.ConfigValue
+}
+
+func (f FakeInstance) Address() string {
+	return f.AddressValue
+}
+
+func (f FakeInstance) Workloads() ([]Workload, error) {
+	return f.WorkloadsValue, nil
+}
+
 // Workload port exposed by an Echo instance
 type WorkloadPort struct {
 	// Port number
@@ -86,6 +115,10 @@ type WorkloadPort struct {
 
 	// ServerFirst determines whether the port will use server first communication, meaning the client will not send the first byte.
 	ServerFirst bool
+
+	// AppProtocol, if set, declares the protocol of the service port with the appProtocol field, such as "http",
+	// rather than with the prefix of its name.
+	AppProtocol string
 }
 
 // Port exposed by an Echo Instance
@@ -110,6 +143,10 @@ type Port struct {
 
 	// ServerFirst determines whether the port will use server first communication, meaning the client will not send the first byte.
 	ServerFirst bool
+
+	// AppProtocol, if set, declares the protocol of the service port with the appProtocol field, such as "http",
+	// rather than with the prefix of its name.
+	AppProtocol string
 }
 
 // Workload provides an interface for a single deployed echo server.
@@ -120,6 +157,9 @@ type Workload interface {
 	// Sidecar if one was specified.
 	Sidecar() Sidecar
 
+	// NodeName returns the name of the node the workload runs on, for testing node-local behavior.
+	NodeName() string
+
 	// ForwardEcho executes specific call from this workload.
 	ForwardEcho(context.Context, *proto.ForwardEchoRequest) (client.ParsedResponses, error)
 
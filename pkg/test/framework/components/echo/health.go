@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/proto"
+)
+
+// healthPort is the HTTP port of echo probed by Kubernetes.
+const healthPort = 8080
+
+// SetReady sets whether the workload reports itself ready. While not ready, its readiness probe and every request to
+// its HTTP ports fail with 503, so that it is removed from the endpoints of its services and ejected by outlier
+// detection, without restarting it.
+func SetReady(w Workload, ready bool) error {
+	return setHealth(w, common.ReadinessParam, ready)
+}
+
+// SetReadyOrFail calls SetReady and fails the test if it returns an error.
+func SetReadyOrFail(t test.Failer, w Workload, ready bool) {
+	t.Helper()
+	if err := SetReady(w, ready); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// SetLive sets whether the workload reports itself live. If the instance was configured with HTTPLivenessProbe,
+// Kubernetes restarts the application container once enough liveness probes failed, which resets it to live.
+func SetLive(w Workload, live bool) error {
+	return setHealth(w, common.LivenessParam, live)
+}
+
+// SetLiveOrFail calls SetLive and fails the test if it returns an error.
+func SetLiveOrFail(t test.Failer, w Workload, live bool) {
+	t.Helper()
+	if err := SetLive(w, live); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setHealth sends the request from the workload to itself over localhost, so that only that workload is affected and
+// the request is not intercepted by its sidecar.
+func setHealth(w Workload, param string, v bool) error {
+	_, err := w.ForwardEcho(context.TODO(), &proto.ForwardEchoRequest{
+		Url:           fmt.Sprintf("http://127.0.0.1:%d/?%s=%v", healthPort, param, v),
+		Count:         1,
+		TimeoutMicros: common.DurationToMicros(5 * time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("failed setting %s=%v on workload %s: %v", param, v, w.Address(), err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetHealth(t *testing.T) {
+	var urls []string
+	w := forwardingWorkload{fakeWorkload: fakeWorkload{address: "10.0.0.1"}, urls: &urls}
+	if err := SetReady(w, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetLive(w, true); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"http://127.0.0.1:8080/?setReady=false", "http://127.0.0.1:8080/?setLive=true"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Fatalf("got %v, want %v", urls, want)
+	}
+
+	w.err = errors.New("refused")
+	if err := SetReady(w, true); err == nil || !strings.Contains(err.Error(), "setReady=true on workload 10.0.0.1") {
+		t.Fatalf("got error %v, want the workload and the health being set", err)
+	}
+}
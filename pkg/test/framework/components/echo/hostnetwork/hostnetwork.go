@@ -0,0 +1,178 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hostnetwork covers the interactions of workloads on the host network, deployed with
+// echo.Config.HostNetwork, with those in the mesh. Sidecars are never injected into pods on the host network, nor is
+// their traffic captured, so such workloads behave as if they were outside of the mesh, which commonly surprises users
+// once STRICT mTLS is enabled.
+//
+// Run calls each pair of the workloads under each mTLS mode, as sub-tests named <mode>/<from>-><to>, and checks that
+// calls succeed, over mTLS or not, as listed in Cases. There is no ambient data plane in this tree, so the exclusion of
+// pods on the host network from ambient capture is not covered.
+package hostnetwork
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Side is the kind of workload on one side of a call.
+type Side string
+
+const (
+	// Sidecar is a workload injected with a sidecar.
+	Sidecar Side = "sidecar"
+	// HostNetwork is a workload on the host network.
+	HostNetwork Side = "hostNetwork"
+)
+
+// Mode is the mTLS mode of the PeerAuthentication of the namespace of the destination.
+type Mode string
+
+const (
+	Permissive Mode = "PERMISSIVE"
+	Strict     Mode = "STRICT"
+)
+
+// Modes are the mTLS modes the cases are run under.
+var Modes = []Mode{Permissive, Strict}
+
+// Case is the expected behavior of a call between two kinds of workloads under an mTLS mode.
+type Case struct {
+	Mode Mode
+	From Side
+	To   Side
+	// Reachable is whether the call is expected to succeed.
+	Reachable bool
+	// MTLS is whether the call is expected to be mTLS, as shown by the client certificate forwarded by the sidecar of
+	// the destination.
+	MTLS bool
+}
+
+func (c Case) String() string {
+	return fmt.Sprintf("%s/%s->%s", c.Mode, c.From, c.To)
+}
+
+// Cases are the expected behaviors of calls between workloads on the host network and those with sidecars. Without a
+// sidecar, workloads on the host network send plaintext, which is rejected by sidecars under STRICT mTLS, and accept
+// plaintext whatever the PeerAuthentication, which does not apply to them.
+var Cases = []Case{
+	{Mode: Permissive, From: Sidecar, To: Sidecar, Reachable: true, MTLS: true},
+	{Mode: Permissive, From: Sidecar, To: HostNetwork, Reachable: true},
+	{Mode: Permissive, From: HostNetwork, To: Sidecar, Reachable: true},
+	{Mode: Permissive, From: HostNetwork, To: HostNetwork, Reachable: true},
+	{Mode: Strict, From: Sidecar, To: Sidecar, Reachable: true, MTLS: true},
+	{Mode: Strict, From: Sidecar, To: HostNetwork, Reachable: true},
+	{Mode: Strict, From: HostNetwork, To: Sidecar, Reachable: false},
+	{Mode: Strict, From: HostNetwork, To: HostNetwork, Reachable: true},
+}
+
+// Check that the responses of a call of the case are as expected.
+func (c Case) Check(resp client.ParsedResponses, err error) error {
+	if !c.Reachable {
+		if err == nil && resp.CheckOK() == nil {
+			return fmt.Errorf("expected call to be rejected, but it succeeded")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := resp.CheckOK(); err != nil {
+		return err
+	}
+	for i, r := range resp {
+		mtls := false
+		for k, v := range r.RawResponse {
+			if strings.EqualFold(k, "X-Forwarded-Client-Cert") && strings.Contains(v, "By=spiffe://") {
+				mtls = true
+			}
+		}
+		if mtls != c.MTLS {
+			return fmt.Errorf("response[%d]: expected mTLS to be %v, got %v", i, c.MTLS, mtls)
+		}
+	}
+	return nil
+}
+
+// Run every case between the workloads, which must all serve HTTP on a port named "http", as sub-tests. The
+// PeerAuthentication of each mode is applied to the namespaces of the workloads in turn.
+func Run(ctx framework.TestContext, workloads map[Side]echo.Instance) {
+	for _, mode := range Modes {
+		mode := mode
+		ctx.NewSubTest(string(mode)).Run(func(ctx framework.TestContext) {
+			for _, ns := range namespaces(workloads) {
+				ns := ns
+				ctx.Config().ApplyYAMLOrFail(ctx, ns, peerAuthentication(mode))
+				ctx.WhenDone(func() error {
+					return ctx.Config().DeleteYAML(ns, peerAuthentication(mode))
+				})
+			}
+			for _, c := range Cases {
+				c := c
+				if c.Mode != mode {
+					continue
+				}
+				from, to := workloads[c.From], workloads[c.To]
+				if from == nil || to == nil {
+					continue
+				}
+				ctx.NewSubTest(fmt.Sprintf("%s->%s", c.From, c.To)).Run(func(ctx framework.TestContext) {
+					retry.UntilSuccessOrFail(ctx, func() error {
+						return c.Check(from.Call(echo.CallOptions{
+							Target:   to,
+							PortName: "http",
+							Count:    1,
+						}))
+					}, retry.Delay(time.Second), retry.Timeout(time.Minute))
+				})
+			}
+		})
+	}
+}
+
+func namespaces(workloads map[Side]echo.Instance) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, side := range []Side{Sidecar, HostNetwork} {
+		w := workloads[side]
+		if w == nil {
+			continue
+		}
+		ns := w.Config().Namespace.Name()
+		if !seen[ns] {
+			seen[ns] = true
+			out = append(out, ns)
+		}
+	}
+	return out
+}
+
+func peerAuthentication(mode Mode) string {
+	return fmt.Sprintf(`
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: hostnetwork
+spec:
+  mtls:
+    mode: %s
+`, mode)
+}
@@ -0,0 +1,110 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostnetwork
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+)
+
+func TestCases(t *testing.T) {
+	seen := map[string]bool{}
+	for _, c := range Cases {
+		if seen[c.String()] {
+			t.Fatalf("case %s is listed twice", c)
+		}
+		seen[c.String()] = true
+		if c.MTLS && (c.From != Sidecar || c.To != Sidecar) {
+			t.Fatalf("case %s expects mTLS without a sidecar on both sides", c)
+		}
+	}
+	for _, mode := range Modes {
+		for _, from := range []Side{Sidecar, HostNetwork} {
+			for _, to := range []Side{Sidecar, HostNetwork} {
+				c := Case{Mode: mode, From: from, To: to}
+				if !seen[c.String()] {
+					t.Errorf("no case for %s", c)
+				}
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	mtls := client.ParsedResponses{{Code: "200", RawResponse: map[string]string{
+		"X-Forwarded-Client-Cert": "By=spiffe://cluster.local/ns/a/sa/b;Hash=abc",
+	}}}
+	plaintext := client.ParsedResponses{{Code: "200", RawResponse: map[string]string{}}}
+	rejected := client.ParsedResponses{{Code: "503"}}
+	cases := []struct {
+		name string
+		c    Case
+		resp client.ParsedResponses
+		err  error
+		want string
+	}{
+		{"mtls", Case{Reachable: true, MTLS: true}, mtls, nil, ""},
+		{"plaintext", Case{Reachable: true}, plaintext, nil, ""},
+		{"unexpected plaintext", Case{Reachable: true, MTLS: true}, plaintext, nil, "expected mTLS to be true"},
+		{"unexpected mtls", Case{Reachable: true}, mtls, nil, "expected mTLS to be false"},
+		{"unreachable", Case{Reachable: true}, rejected, nil, "Status Code: 503"},
+		{"error", Case{Reachable: true}, nil, errors.New("connection reset"), "connection reset"},
+		{"rejected", Case{}, rejected, nil, ""},
+		{"reset", Case{}, nil, errors.New("connection reset"), ""},
+		{"not rejected", Case{}, plaintext, nil, "expected call to be rejected"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.c.Check(tc.resp, tc.err)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	in := func(ns string) echo.Instance {
+		return echo.FakeInstance{ConfigValue: echo.Config{Namespace: namespace.Fake{NameValue: ns}}}
+	}
+	cases := []struct {
+		name      string
+		workloads map[Side]echo.Instance
+		want      []string
+	}{
+		{"shared", map[Side]echo.Instance{Sidecar: in("a"), HostNetwork: in("a")}, []string{"a"}},
+		{"separate", map[Side]echo.Instance{HostNetwork: in("b"), Sidecar: in("a")}, []string{"a", "b"}},
+		{"host network only", map[Side]echo.Instance{HostNetwork: in("b")}, []string{"b"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := namespaces(tc.workloads); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"time"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// IdleOptions configures a CallIdle.
+type IdleOptions struct {
+	// Call options for the TCP connections kept idle. Count is the number of concurrent connections, defaulting to 1.
+	Call CallOptions
+
+	// Expected is when the connections are expected to be closed, such as the idle timeout of a DestinationRule or of
+	// the MeshConfig. Zero expects them to be kept open for all of Hold.
+	Expected time.Duration
+
+	// Tolerance is how early or late the connections may be closed relative to Expected. Defaults to 2s, since idle
+	// timers of Envoy are checked at an interval rather than exactly.
+	Tolerance time.Duration
+
+	// Hold is how long the connections are kept idle if they are not closed. Defaults to Expected plus twice Tolerance,
+	// and is required if Expected is zero.
+	Hold time.Duration
+}
+
+// CallIdle makes TCP calls from the source, keeping each connection idle once the response is received, and checks
+// that the connections are closed by the proxies when expected, and not before.
+func CallIdle(from Instance, opts IdleOptions) (client.ParsedResponses, error) {
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 2 * time.Second
+	}
+	if opts.Hold <= 0 {
+		if opts.Expected <= 0 {
+			return nil, fmt.Errorf("hold must be set if connections are expected to be kept open")
+		}
+		opts.Hold = opts.Expected + 2*opts.Tolerance
+	}
+	callOpts := opts.Call
+	callOpts.Idle = opts.Hold
+	resp, err := from.Call(callOpts)
+	if err != nil {
+		return resp, err
+	}
+	if opts.Expected <= 0 {
+		return resp, resp.CheckNotClosed()
+	}
+	min := opts.Expected - opts.Tolerance
+	if min < 0 {
+		min = 0
+	}
+	return resp, resp.CheckClosedAfter(min, opts.Expected+opts.Tolerance)
+}
+
+// CallIdleOrFail calls CallIdle and fails the test if it returns an error.
+func CallIdleOrFail(t test.Failer, from Instance, opts IdleOptions) client.ParsedResponses {
+	t.Helper()
+	resp, err := CallIdle(from, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
@@ -16,10 +16,12 @@ package kube
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
+	kubeCore "k8s.io/api/core/v1"
 
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/framework/components/echo"
@@ -59,7 +61,7 @@ func (b *builder) Build() (echo.Instances, error) {
 	if err := b.initializeInstances(instances); err != nil {
 		return nil, fmt.Errorf("initialize instances: %v", err)
 	}
-	scopes.Framework.Debugf("initialized echo deployments in %v", time.Since(t0))
+	scopes.Echo.Debugf("initialized echo deployments in %v", time.Since(t0))
 
 	// Success... update the caller's references.
 	for i, inst := range instances {
@@ -100,7 +102,6 @@ func (b *builder) initializeInstances(instances []echo.Instance) error {
 		wg.Add(1)
 
 		inst := inst
-		serviceName := inst.Config().Service
 		serviceNamespace := inst.Config().Namespace.Name()
 		timeout := inst.Config().ReadinessTimeout
 		cluster := inst.(*instance).cluster
@@ -108,14 +109,14 @@ func (b *builder) initializeInstances(instances []echo.Instance) error {
 		// Run the waits in parallel.
 		go func() {
 			defer wg.Done()
-			selector := "app"
-			if inst.Config().DeployAsVM {
-				selector = "istio.io/test-vm"
-			}
 			// Wait until all the pods are ready for this service
-			fetch := kube.NewPodMustFetch(cluster, serviceNamespace, fmt.Sprintf("%s=%s", selector, serviceName))
-			pods, err := kube.WaitUntilPodsAreReady(fetch, retry.Timeout(timeout))
+			pods, err := kube.WaitUntilPodsAreReady(inst.(*instance).podFetch(), retry.Timeout(timeout))
 			if err != nil {
+				// Pods rejected by PodSecurity admission are never created, so report why.
+				if violations, _ := kube.PodSecurityViolations(cluster, serviceNamespace); len(violations) > 0 {
+					err = fmt.Errorf("%v; pods were rejected by PodSecurity admission: %s", err,
+						strings.Join(violations, "; "))
+				}
 				aggregateErrMux.Lock()
 				aggregateErr = multierror.Append(aggregateErr, err)
 				aggregateErrMux.Unlock()
@@ -137,3 +138,23 @@ func (b *builder) initializeInstances(instances []echo.Instance) error {
 
 	return nil
 }
+
+// placedPodFetch wraps fetch to also wait until all the pods of a placement, such as one per node, are created, as
+// those that are ready may only be some of them.
+func placedPodFetch(fetch kube.PodFetchFunc, cluster resource.Cluster, cfg echo.Config) kube.PodFetchFunc {
+	return func() ([]kubeCore.Pod, error) {
+		pods, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		want, err := expectedPods(cluster, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if len(pods) < want {
+			return nil, fmt.Errorf("%d of %d pods of %s placement created for %s", len(pods), want, cfg.Placement,
+				cfg.Service)
+		}
+		return pods, nil
+	}
+}
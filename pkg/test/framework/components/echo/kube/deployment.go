@@ -16,17 +16,22 @@ package kube
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
 	"text/template"
 
 	"github.com/Masterminds/sprig/v3"
+	kubeCore "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"istio.io/istio/pkg/test/framework/components/echo"
+	kubeEnv "istio.io/istio/pkg/test/framework/components/environment/kube"
 	"istio.io/istio/pkg/test/framework/components/istio"
 	"istio.io/istio/pkg/test/framework/image"
 	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
 	"istio.io/istio/pkg/test/util/tmpl"
 )
 
@@ -37,6 +42,12 @@ apiVersion: v1
 kind: ServiceAccount
 metadata:
   name: {{ .Service }}
+{{- if .ServiceAccountAnnotations }}
+  annotations:
+{{- range $name, $value := .ServiceAccountAnnotations }}
+    {{ $name }}: {{ printf "%q" $value }}
+{{- end }}
+{{- end }}
 ---
 {{- end }}
 apiVersion: v1
@@ -60,6 +71,9 @@ spec:
   - name: {{ $p.Name }}
     port: {{ $p.ServicePort }}
     targetPort: {{ $p.InstancePort }}
+{{- if $p.AppProtocol }}
+    appProtocol: {{ $p.AppProtocol }}
+{{- end }}
 {{- end }}
   selector:
     app: {{ .Service }}
@@ -70,11 +84,17 @@ spec:
 {{- $cluster := .Cluster }}
 {{- range $i, $subset := $subsets }}
 apiVersion: apps/v1
+{{- if eq $.Placement "DaemonSet" }}
+kind: DaemonSet
+{{- else }}
 kind: Deployment
+{{- end }}
 metadata:
   name: {{ $.Service }}-{{ $subset.Version }}
 spec:
-  replicas: 1
+{{- if ne $.Placement "DaemonSet" }}
+  replicas: {{ $.Replicas }}
+{{- end }}
   selector:
     matchLabels:
       app: {{ $.Service }}
@@ -89,6 +109,9 @@ spec:
         version: {{ $subset.Version }}
 {{- if ne $.Locality "" }}
         istio-locality: {{ $.Locality }}
+{{- end }}
+{{- if ne $.Network "" }}
+        topology.istio.io/network: {{ $.Network }}
 {{- end }}
       annotations:
         prometheus.io/scrape: "true"
@@ -99,6 +122,49 @@ spec:
     spec:
 {{- if $.ServiceAccount }}
       serviceAccountName: {{ $.Service }}
+{{- end }}
+{{- with $.ServiceAccountToken }}
+{{- if .DisableAutomount }}
+      automountServiceAccountToken: false
+{{- end }}
+{{- end }}
+{{- if $.HostNetwork }}
+      hostNetwork: true
+      dnsPolicy: ClusterFirstWithHostNet
+{{- end }}
+{{- if eq $.Placement "DaemonSet" }}
+      tolerations:
+      - operator: Exists
+{{- else if eq $.Placement "PerNode" }}
+      affinity:
+        podAntiAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+          - topologyKey: kubernetes.io/hostname
+            labelSelector:
+              matchLabels:
+                app: {{ $.Service }}
+                version: {{ $subset.Version }}
+{{- end }}
+{{- if $.InitCalls }}
+      initContainers:
+{{- range $call := $.InitCalls }}
+      - name: init-call-{{ $call.Name }}
+        image: {{ $.Hub }}/app:{{ $.Tag }}
+        imagePullPolicy: {{ $.PullPolicy }}
+{{- if $call.RunAsProxyUser }}
+        securityContext:
+          runAsUser: 1337
+{{- end }}
+        command:
+        - /bin/sh
+        - -c
+        - |
+          if /usr/local/bin/client --count 1 --timeout 5s "{{ $call.URL }}" >/dev/null 2>&1; then
+            echo succeeded >/dev/termination-log
+          else
+            echo failed >/dev/termination-log
+          fi
+{{- end }}
 {{- end }}
       containers:
       - name: app
@@ -108,6 +174,10 @@ spec:
           - --metrics=15014
           - --cluster
           - "{{ $cluster }}"
+{{- if ne $.Locality "" }}
+          - --locality
+          - "{{ $.Locality }}"
+{{- end }}
 {{- range $i, $p := $.ContainerPorts }}
 {{- if eq .Protocol "GRPC" }}
           - --grpc
@@ -143,6 +213,10 @@ spec:
 {{- if $.TLSSettings }}
           - --crt=/etc/certs/custom/cert-chain.pem
           - --key=/etc/certs/custom/key.pem
+{{- end }}
+{{- if $.Socket }}
+          - --tcp-keepalive={{ $.Socket.KeepAlive }}
+          - --tcp-linger={{ $.Socket.Linger }}
 {{- end }}
         ports:
 {{- range $i, $p := $.ContainerPorts }}
@@ -159,8 +233,14 @@ spec:
           periodSeconds: 2
           failureThreshold: 10
         livenessProbe:
+{{- if $.HTTPLivenessProbe }}
+          httpGet:
+            path: /livez
+            port: 8080
+{{- else }}
           tcpSocket:
             port: tcp-health-port
+{{- end }}
           initialDelaySeconds: 10
           periodSeconds: 10
           failureThreshold: 10
@@ -173,11 +253,30 @@ spec:
         volumeMounts:
         - mountPath: /etc/certs/custom
           name: custom-certs
+{{- end }}
+{{- if or $.TLSSettings $.ProjectedToken }}
       volumes:
+{{- end }}
+{{- if $.TLSSettings }}
       - configMap:
           name: {{ $.Service }}-certs
         name: custom-certs
 {{- end}}
+{{- with $.ServiceAccountToken }}
+{{- if .Projected }}
+      - name: echo-token
+        projected:
+          sources:
+          - serviceAccountToken:
+              path: token
+{{- if .Audience }}
+              audience: {{ .Audience }}
+{{- end }}
+{{- if .ExpirationSeconds }}
+              expirationSeconds: {{ .ExpirationSeconds }}
+{{- end }}
+{{- end }}
+{{- end }}
 ---
 {{- end}}
 {{- if .TLSSettings }}
@@ -375,6 +474,34 @@ func generateYAML(ctx resource.Context, cfg echo.Config, cluster resource.Cluste
 
 const DefaultVMImage = "app_sidecar_ubuntu_bionic"
 
+// withTokenAnnotations returns a copy of the subsets with the annotations that make the sidecar authenticate with the
+// token at the Kubernetes service account path, mounting the projected token there if there is one.
+func withTokenAnnotations(subsets []echo.SubsetConfig, token echo.ServiceAccountToken) ([]echo.SubsetConfig, error) {
+	tokenAnnotations := echo.NewAnnotations().
+		Set(echo.SidecarProxyConfig, `{"proxyMetadata":{"JWT_POLICY":"first-party-jwt"}}`)
+	if token.Projected() {
+		tokenAnnotations.Set(echo.SidecarVolumeMount,
+			`{"echo-token":{"mountPath":"/var/run/secrets/kubernetes.io/serviceaccount","readOnly":true}}`)
+	}
+	out := make([]echo.SubsetConfig, 0, len(subsets))
+	for _, subset := range subsets {
+		annotations := echo.NewAnnotations()
+		for k, v := range subset.Annotations {
+			annotations[k] = v
+		}
+		for k, v := range tokenAnnotations {
+			if _, f := annotations[k]; f {
+				return nil, fmt.Errorf("annotation %s of subset %s conflicts with the service account token",
+					k.Name, subset.Version)
+			}
+			annotations[k] = v
+		}
+		subset.Annotations = annotations
+		out = append(out, subset)
+	}
+	return out, nil
+}
+
 func generateYAMLWithSettings(
 	ctx resource.Context, cfg echo.Config,
 	settings *image.Settings, cluster resource.Cluster) (serviceYAML string, deploymentYAML string, err error) {
@@ -392,6 +519,11 @@ func generateYAMLWithSettings(
 			cfg.Subsets[i].Version = "v1"
 		}
 	}
+	if cfg.ServiceAccountToken != nil {
+		if cfg.Subsets, err = withTokenAnnotations(cfg.Subsets, *cfg.ServiceAccountToken); err != nil {
+			return "", "", fmt.Errorf("%s: %v", cfg.Service, err)
+		}
+	}
 
 	var vmImage, istiodIP, istiodPort string
 	if cfg.DeployAsVM {
@@ -414,33 +546,83 @@ func generateYAMLWithSettings(
 			vmImage = cfg.VMImage
 		}
 	}
+	saAnnotations := map[string]string{}
+	for k, v := range cfg.ServiceAccountAnnotations {
+		saAnnotations[k] = v
+	}
+	if ctx != nil {
+		if env, err := kubeEnv.EnvironmentFrom(ctx); err == nil && env.Settings().EKSRoleARN != "" {
+			if _, f := saAnnotations[kube.EKSRoleARNAnnotation]; !f {
+				saAnnotations[kube.EKSRoleARNAnnotation] = env.Settings().EKSRoleARN
+			}
+		}
+	}
+	restricted := ctx != nil && ctx.Settings().PodSecurityRestricted
+	if restricted && cfg.DeployAsVM {
+		return "", "", fmt.Errorf("%s: VMs need the NET_ADMIN capability, which the restricted PodSecurity profile "+
+			"does not allow", cfg.Service)
+	}
+	if restricted && cfg.HostNetwork {
+		return "", "", fmt.Errorf("%s: the restricted PodSecurity profile does not allow the host network", cfg.Service)
+	}
+	if cfg.DeployAsVM && len(cfg.InitCalls) > 0 {
+		return "", "", fmt.Errorf("%s: init calls are not supported for VMs", cfg.Service)
+	}
+	if cfg.DeployAsVM && cfg.HostNetwork {
+		return "", "", fmt.Errorf("%s: the host network is not supported for VMs", cfg.Service)
+	}
+	if cfg.DeployAsVM && cfg.Placement != echo.PlacementDeployment {
+		return "", "", fmt.Errorf("%s: placement %s is not supported for VMs", cfg.Service, cfg.Placement)
+	}
+	replicas := 1
+	if cfg.Placement == echo.PlacementPerNode {
+		if replicas, err = schedulableNodes(cluster); err != nil {
+			return "", "", err
+		}
+	}
 	namespace := ""
 	if cfg.Namespace != nil {
 		namespace = cfg.Namespace.Name()
 	}
+	// Virtual clusters share the API server, so every control plane discovers the workloads of all of them as its own.
+	// The label attributes the workloads to the network of their virtual cluster.
+	network := ""
+	if kc, ok := cfg.Cluster.(kubeEnv.Cluster); ok && kc.IsVirtual() {
+		network = kc.NetworkName()
+	}
 	params := map[string]interface{}{
-		"Hub":                settings.Hub,
-		"Tag":                settings.Tag,
-		"PullPolicy":         settings.PullPolicy,
-		"Service":            cfg.Service,
-		"Version":            cfg.Version,
-		"Headless":           cfg.Headless,
-		"Locality":           cfg.Locality,
-		"ServiceAccount":     cfg.ServiceAccount,
-		"Ports":              cfg.Ports,
-		"WorkloadOnlyPorts":  cfg.WorkloadOnlyPorts,
-		"ContainerPorts":     getContainerPorts(cfg.Ports),
-		"ServiceAnnotations": cfg.ServiceAnnotations,
-		"Subsets":            cfg.Subsets,
-		"TLSSettings":        cfg.TLSSettings,
-		"Cluster":            cfg.Cluster.Name(),
-		"Namespace":          namespace,
+		"Hub":                       settings.Hub,
+		"Tag":                       settings.Tag,
+		"PullPolicy":                settings.PullPolicy,
+		"Service":                   cfg.Service,
+		"Version":                   cfg.Version,
+		"Headless":                  cfg.Headless,
+		"Locality":                  cfg.Locality,
+		"ServiceAccount":            cfg.ServiceAccount,
+		"ServiceAccountAnnotations": saAnnotations,
+		"Ports":                     cfg.Ports,
+		"WorkloadOnlyPorts":         cfg.WorkloadOnlyPorts,
+		"ContainerPorts":            getContainerPorts(cfg.Ports),
+		"ServiceAnnotations":        cfg.ServiceAnnotations,
+		"Subsets":                   cfg.Subsets,
+		"TLSSettings":               cfg.TLSSettings,
+		"Socket":                    cfg.Socket,
+		"Placement":                 cfg.Placement,
+		"HostNetwork":               cfg.HostNetwork,
+		"InitCalls":                 cfg.InitCalls,
+		"Replicas":                  replicas,
+		"Cluster":                   cfg.Cluster.Name(),
+		"Namespace":                 namespace,
+		"Network":                   network,
 		"VM": map[string]interface{}{
 			"Image":      vmImage,
 			"IstiodIP":   istiodIP,
 			"IstiodPort": istiodPort,
 		},
-		"Environment": cfg.VMEnvironment,
+		"Environment":         cfg.VMEnvironment,
+		"ServiceAccountToken": cfg.ServiceAccountToken,
+		"ProjectedToken":      cfg.ServiceAccountToken != nil && cfg.ServiceAccountToken.Projected(),
+		"HTTPLivenessProbe":   cfg.HTTPLivenessProbe,
 	}
 
 	serviceYAML, err = tmpl.Execute(serviceTemplate, params)
@@ -458,6 +640,68 @@ func generateYAMLWithSettings(
 	return
 }
 
+// schedulableNodes returns the number of nodes of the cluster that application pods can be scheduled on, that is
+// that are neither cordoned nor tainted to repel pods, such as control plane nodes.
+func schedulableNodes(cluster resource.Cluster) (int, error) {
+	nodes, err := cluster.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed listing nodes of cluster %s: %v", cluster.Name(), err)
+	}
+	n := 0
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable || repelsPods(node) {
+			continue
+		}
+		n++
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("no schedulable nodes in cluster %s", cluster.Name())
+	}
+	return n, nil
+}
+
+func repelsPods(node kubeCore.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == kubeCore.TaintEffectNoSchedule || taint.Effect == kubeCore.TaintEffectNoExecute {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedPods returns the number of pods the deployment of cfg is expected to have once scheduled, or zero if
+// it only needs at least one.
+func expectedPods(cluster resource.Cluster, cfg echo.Config) (int, error) {
+	subsets := len(cfg.Subsets)
+	if subsets == 0 {
+		subsets = 1
+	}
+	switch cfg.Placement {
+	case echo.PlacementPerNode:
+		n, err := schedulableNodes(cluster)
+		if err != nil {
+			return 0, err
+		}
+		return n * subsets, nil
+	case echo.PlacementDaemonSet:
+		n := 0
+		for _, subset := range cfg.Subsets {
+			version := subset.Version
+			if version == "" {
+				version = "v1"
+			}
+			ds, err := cluster.AppsV1().DaemonSets(cfg.Namespace.Name()).Get(context.TODO(),
+				fmt.Sprintf("%s-%s", cfg.Service, version), metav1.GetOptions{})
+			if err != nil {
+				return 0, err
+			}
+			n += int(ds.Status.DesiredNumberScheduled)
+		}
+		return n, nil
+	}
+	return 0, nil
+}
+
 func lines(input string) []string {
 	out := []string{}
 	scanner := bufio.NewScanner(strings.NewReader(input))
@@ -55,6 +55,23 @@ func TestDeploymentYAML(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:         "locality",
+			wantFilePath: "testdata/locality.yaml",
+			config: echo.Config{
+				Service:  "foo",
+				Version:  "bar",
+				Locality: "region.zone.subzone",
+				Ports: []echo.Port{
+					{
+						Name:         "http",
+						Protocol:     protocol.HTTP,
+						InstancePort: 8090,
+						ServicePort:  8090,
+					},
+				},
+			},
+		},
 		{
 			name:         "two-workloads-one-nosidecar",
 			wantFilePath: "testdata/two-workloads-one-nosidecar.yaml",
@@ -157,3 +174,38 @@ func TestDeploymentYAML(t *testing.T) {
 		})
 	}
 }
+
+func TestWithTokenAnnotations(t *testing.T) {
+	subsets := []echo.SubsetConfig{
+		{Version: "v1"},
+		{Version: "v2", Annotations: echo.NewAnnotations().SetBool(echo.SidecarInject, false)},
+	}
+	got, err := withTokenAnnotations(subsets, echo.ServiceAccountToken{Audience: "istio-ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, subset := range got {
+		if subset.Annotations.Get(echo.SidecarProxyConfig) == "" || subset.Annotations.Get(echo.SidecarVolumeMount) == "" {
+			t.Fatalf("expected the token annotations on subset %s, got %v", subset.Version, subset.Annotations)
+		}
+	}
+	if got[1].Annotations.GetBool(echo.SidecarInject) {
+		t.Fatal("expected the annotations of the subset to be kept")
+	}
+	if len(subsets[1].Annotations) != 1 {
+		t.Fatal("expected the annotations of the config to be left unchanged")
+	}
+
+	got, err = withTokenAnnotations(subsets[:1], echo.ServiceAccountToken{DisableAutomount: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Annotations.Get(echo.SidecarVolumeMount) != "" {
+		t.Fatal("expected no volume mount without a projected token")
+	}
+
+	conflicting := []echo.SubsetConfig{{Version: "v1", Annotations: echo.NewAnnotations().Set(echo.SidecarProxyConfig, "{}")}}
+	if _, err := withTokenAnnotations(conflicting, echo.ServiceAccountToken{}); err == nil {
+		t.Fatal("expected the proxy config of the subset to conflict with the token")
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/go-multierror"
 	authenticationv1 "k8s.io/api/authentication/v1"
@@ -35,7 +36,10 @@ import (
 	echoCommon "istio.io/istio/pkg/test/echo/common"
 	"istio.io/istio/pkg/test/framework/components/echo"
 	"istio.io/istio/pkg/test/framework/components/echo/common"
+	"istio.io/istio/pkg/test/framework/components/echo/record"
+	kubeEnv "istio.io/istio/pkg/test/framework/components/environment/kube"
 	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
 	"istio.io/istio/pkg/test/scopes"
 	"istio.io/istio/pkg/test/util/retry"
 )
@@ -55,6 +59,8 @@ type instance struct {
 	id        resource.ID
 	cfg       echo.Config
 	clusterIP string
+	// mu guards workloads, which are replaced by Refresh while calls may be in flight.
+	mu        sync.RWMutex
 	workloads []*workload
 	grpcPort  uint16
 	ctx       resource.Context
@@ -86,6 +92,13 @@ func newInstance(ctx resource.Context, cfg echo.Config) (out *instance, err erro
 		c.tls = cfg.TLSSettings
 	}
 
+	// Virtual clusters share namespaces, in which only one of them may deploy workloads.
+	if kc, ok := c.cluster.(kubeEnv.Cluster); ok {
+		if err := kc.ClaimNamespace(cfg.Namespace.Name()); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate the service and deployment YAML.
 	serviceYAML, deploymentYAML, err := generateYAML(ctx, cfg, c.cluster)
 	if err != nil {
@@ -202,7 +215,7 @@ spec:
 }
 
 func createServiceAccountToken(client kubernetes.Interface, ns string, serviceAccount string) (string, error) {
-	scopes.Framework.Debugf("Creating service account token for: %s/%s", ns, serviceAccount)
+	scopes.Echo.Debugf("Creating service account token for: %s/%s", ns, serviceAccount)
 
 	token, err := client.CoreV1().ServiceAccounts(ns).CreateToken(context.TODO(), serviceAccount,
 		&authenticationv1.TokenRequest{
@@ -275,6 +288,8 @@ func (c *instance) Address() string {
 }
 
 func (c *instance) Workloads() ([]echo.Workload, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	out := make([]echo.Workload, 0, len(c.workloads))
 	for _, w := range c.workloads {
 		out = append(out, w)
@@ -291,8 +306,90 @@ func (c *instance) WorkloadsOrFail(t test.Failer) []echo.Workload {
 	return out
 }
 
+func (c *instance) Refresh() error {
+	// Pods being deleted may still be ready, but are about to go away.
+	fetch := c.podFetch()
+	pods, err := kube.WaitUntilPodsAreReady(func() ([]kubeCore.Pod, error) {
+		fetched, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		running := make([]kubeCore.Pod, 0, len(fetched))
+		for _, p := range fetched {
+			if p.DeletionTimestamp == nil {
+				running = append(running, p)
+			}
+		}
+		if len(running) == 0 {
+			return nil, fmt.Errorf("no running pods found for %s", c.cfg.Service)
+		}
+		return running, nil
+	}, retry.Timeout(c.cfg.ReadinessTimeout))
+	if err != nil {
+		return fmt.Errorf("refresh %s: %v", c.cfg.Service, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := make(map[string]*workload, len(c.workloads))
+	for _, w := range c.workloads {
+		current[w.pod.Name] = w
+	}
+	workloads := make([]*workload, 0, len(pods))
+	var created []*workload
+	for _, pod := range pods {
+		if w, ok := current[pod.Name]; ok {
+			workloads = append(workloads, w)
+			delete(current, pod.Name)
+			continue
+		}
+		w, err := newWorkload(pod, workloadHasSidecar(c.cfg, pod.Name), c.grpcPort, c.cluster, c.tls, c.ctx)
+		if err != nil {
+			for _, w := range created {
+				_ = w.Close()
+			}
+			return fmt.Errorf("refresh %s: %v", c.cfg.Service, err)
+		}
+		created = append(created, w)
+		workloads = append(workloads, w)
+	}
+	c.workloads = workloads
+
+	// The remaining workloads are those of pods that no longer exist.
+	var errs error
+	for _, w := range current {
+		errs = multierror.Append(errs, w.Close()).ErrorOrNil()
+	}
+	return errs
+}
+
+func (c *instance) RefreshOrFail(t test.Failer) {
+	t.Helper()
+	if err := c.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// podFetch returns a fetch of the pods of the instance, which must all be created for placements other than one
+// deployment.
+func (c *instance) podFetch() kube.PodFetchFunc {
+	selector := "app"
+	if c.cfg.DeployAsVM {
+		selector = "istio.io/test-vm"
+	}
+	fetch := kube.NewPodMustFetch(c.cluster, c.cfg.Namespace.Name(), fmt.Sprintf("%s=%s", selector, c.cfg.Service))
+	if c.cfg.Placement != echo.PlacementDeployment {
+		fetch = placedPodFetch(fetch, c.cluster, c.cfg)
+	}
+	return fetch
+}
+
 // WorkloadHasSidecar returns true if the input endpoint is deployed with sidecar injected based on the config.
 func workloadHasSidecar(cfg echo.Config, podName string) bool {
+	// Sidecars are not injected into pods on the host network.
+	if cfg.HostNetwork {
+		return false
+	}
 	// Match workload first.
 	for _, w := range cfg.Subsets {
 		if strings.HasPrefix(podName, fmt.Sprintf("%v-%v", cfg.Service, w.Version)) {
@@ -326,6 +423,8 @@ func (c *instance) initialize(pods []kubeCore.Pod) error {
 }
 
 func (c *instance) Close() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	for _, w := range c.workloads {
 		err = multierror.Append(err, w.Close()).ErrorOrNil()
 	}
@@ -338,7 +437,12 @@ func (c *instance) Config() echo.Config {
 }
 
 func (c *instance) Call(opts echo.CallOptions) (appEcho.ParsedResponses, error) {
-	out, err := common.ForwardEcho(c.workloads[0].Instance, &opts)
+	recorded := opts
+	c.mu.RLock()
+	w := c.workloads[0]
+	c.mu.RUnlock()
+	out, err := common.ForwardEcho(w.Instance, &opts)
+	record.Add(c.ctx.Settings().EchoRecordFile, c, recorded, out, err)
 	if err != nil {
 		if opts.Port != nil {
 			err = fmt.Errorf("failed calling %s->'%s://%s:%d/%s': %v",
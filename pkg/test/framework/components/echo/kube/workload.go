@@ -125,6 +125,10 @@ func (w *workload) Sidecar() echo.Sidecar {
 	return w.sidecar
 }
 
+func (w *workload) NodeName() string {
+	return w.pod.Spec.NodeName
+}
+
 func (w *workload) Logs() (string, error) {
 	return w.cluster.PodLogs(context.TODO(), w.pod.Name, w.pod.Namespace, appContainerName, false)
 }
@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// LocalitySpillOptions configures CheckLocalitySpill. Localities are assigned to echo deployments through
+// Config.Locality, so no zone labels need to be configured on the cluster nodes.
+type LocalitySpillOptions struct {
+	// Call options for the requests made in each phase.
+	Call CallOptions
+
+	// Local is the locality that traffic is expected to stay in while its endpoints are healthy.
+	Local string
+
+	// Failover is the locality that traffic is expected to spill to when the local endpoints are unhealthy.
+	Failover string
+
+	// Degrade makes the local endpoints unhealthy, for example by scaling their deployment to zero with
+	// kube.ScaleDeployment. Required.
+	Degrade func() error
+
+	// Restore makes the local endpoints healthy again. If set, traffic is expected to return to the local
+	// locality afterwards.
+	Restore func() error
+}
+
+// CheckLocalitySpill verifies that traffic from the source stays within the local locality, spills over to the
+// failover locality once the local endpoints are degraded, and returns when they are restored.
+func CheckLocalitySpill(from Instance, opts LocalitySpillOptions, retryOpts ...retry.Option) error {
+	if opts.Degrade == nil {
+		return fmt.Errorf("degrade function must be provided")
+	}
+	expect := func(phase, locality string) error {
+		return retry.UntilSuccess(func() error {
+			resp, err := from.Call(opts.Call)
+			if err != nil {
+				return err
+			}
+			if err := resp.CheckLocality(locality); err != nil {
+				return fmt.Errorf("%s: %v (distribution %v)", phase, err, resp.LocalityDistribution())
+			}
+			return nil
+		}, retryOpts...)
+	}
+
+	if err := expect("before degrading", opts.Local); err != nil {
+		return err
+	}
+	if err := opts.Degrade(); err != nil {
+		return fmt.Errorf("failed degrading locality %s: %v", opts.Local, err)
+	}
+	if err := expect("after degrading", opts.Failover); err != nil {
+		return err
+	}
+	if opts.Restore == nil {
+		return nil
+	}
+	if err := opts.Restore(); err != nil {
+		return fmt.Errorf("failed restoring locality %s: %v", opts.Local, err)
+	}
+	return expect("after restoring", opts.Local)
+}
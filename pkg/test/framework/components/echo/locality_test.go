@@ -0,0 +1,90 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// localityCaller receives its responses from a single locality.
+type localityCaller struct {
+	Instance
+	locality string
+}
+
+func (c *localityCaller) Call(CallOptions) (client.ParsedResponses, error) {
+	return client.ParsedResponses{{Locality: c.locality}}, nil
+}
+
+func TestCheckLocalitySpill(t *testing.T) {
+	moveTo := func(from *localityCaller, locality string) func() error {
+		return func() error {
+			from.locality = locality
+			return nil
+		}
+	}
+	cases := []struct {
+		name    string
+		degrade string
+		restore string
+		err     error
+		errMsg  string
+	}{
+		{name: "spilled and returned", degrade: "failover", restore: "local"},
+		{name: "not spilled", degrade: "local", restore: "local", errMsg: "expected failover, received local"},
+		{name: "not returned", degrade: "failover", restore: "failover", errMsg: "after restoring"},
+		{name: "degrade failed", err: errors.New("forbidden"), errMsg: "failed degrading locality local: forbidden"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			from := &localityCaller{locality: "local"}
+			degrade := moveTo(from, tc.degrade)
+			if tc.err != nil {
+				degrade = func() error { return tc.err }
+			}
+			err := CheckLocalitySpill(from, LocalitySpillOptions{
+				Local:    "local",
+				Failover: "failover",
+				Degrade:  degrade,
+				Restore:  moveTo(from, tc.restore),
+			}, retry.Timeout(100*time.Millisecond), retry.Delay(10*time.Millisecond))
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Fatalf("expected error %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+
+	// Without a restore function, the check ends once traffic has spilled.
+	from := &localityCaller{locality: "local"}
+	if err := CheckLocalitySpill(from, LocalitySpillOptions{Local: "local", Failover: "failover",
+		Degrade: moveTo(from, "failover")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckLocalitySpill(from, LocalitySpillOptions{Local: "local", Failover: "failover"}); err == nil {
+		t.Fatal("expected an error without a function to degrade the locality")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+
+	"istio.io/istio/pilot/cmd/pilot-agent/status"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/proto"
+)
+
+const (
+	// agentStatusPort is the port of the agent that rewritten probes are sent to.
+	agentStatusPort = 15020
+	proxyContainer  = "istio-proxy"
+)
+
+// SetGRPCHealth sets the status reported by the gRPC health service of every gRPC port of the target, such as
+// "SERVING" or "NOT_SERVING". Each workload is called directly, as by SetReady, so that every one of them is set.
+func SetGRPCHealth(target Instance, healthStatus string) error {
+	var port *Port
+	for i, p := range target.Config().Ports {
+		if p.Protocol == protocol.HTTP {
+			port = &target.Config().Ports[i]
+			break
+		}
+	}
+	if port == nil {
+		return fmt.Errorf("%s has no HTTP port to set its gRPC health with", target.Config().Service)
+	}
+	workloads, err := target.Workloads()
+	if err != nil {
+		return err
+	}
+	for _, w := range workloads {
+		_, err := w.ForwardEcho(context.TODO(), &proto.ForwardEchoRequest{
+			Url:           fmt.Sprintf("http://127.0.0.1:%d/?%s=%s", port.InstancePort, common.GRPCHealthParam, healthStatus),
+			Count:         1,
+			TimeoutMicros: common.DurationToMicros(5 * time.Second),
+		})
+		if err != nil {
+			return fmt.Errorf("failed setting the gRPC health of workload %s to %s: %v", w.Address(), healthStatus, err)
+		}
+	}
+	return nil
+}
+
+// CheckProbesRewritten checks that the HTTP probes of the containers of the instance are rewritten to be sent through
+// the agent, which forwards them to the application as described by the app probers of the proxy. TCP probes, such as
+// the liveness probe of echo by default, are not rewritten.
+func CheckProbesRewritten(i Instance) error {
+	return checkProbes(i, true)
+}
+
+// CheckProbesNotRewritten checks that the HTTP probes of the containers of the instance are sent to the application,
+// such as when the instance is annotated with SidecarRewriteAppHTTPProbers set to false.
+func CheckProbesNotRewritten(i Instance) error {
+	return checkProbes(i, false)
+}
+
+func checkProbes(i Instance, rewritten bool) error {
+	cfg := i.Config()
+	pods, err := cfg.Cluster.PodsForSelector(context.TODO(), cfg.Namespace.Name(), "app="+cfg.Service)
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods found for %s", cfg.Service)
+	}
+	for _, pod := range pods.Items {
+		probers := appProbers(pod)
+		for _, c := range pod.Spec.Containers {
+			if c.Name == proxyContainer {
+				continue
+			}
+			readyz, livez, startupz := status.FormatProberURL(c.Name)
+			for path, p := range map[string]*kubeApiCore.Probe{
+				readyz:   c.ReadinessProbe,
+				livez:    c.LivenessProbe,
+				startupz: c.StartupProbe,
+			} {
+				if p == nil || p.HTTPGet == nil {
+					continue
+				}
+				toAgent := p.HTTPGet.Port.IntValue() == agentStatusPort && p.HTTPGet.Path == path
+				if rewritten && !toAgent {
+					return fmt.Errorf("probe %s of %s/%s is not rewritten: sent to port %s path %s", path, pod.Name,
+						c.Name, p.HTTPGet.Port.String(), p.HTTPGet.Path)
+				}
+				if rewritten && !strings.Contains(probers, path) {
+					return fmt.Errorf("probe %s of %s/%s is not in the app probers of the proxy: %s",
+						path, pod.Name, c.Name, probers)
+				}
+				if !rewritten && toAgent {
+					return fmt.Errorf("probe %s of %s/%s is unexpectedly rewritten", path, pod.Name, c.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// appProbers returns the probes of the application passed to the agent by the injector.
+func appProbers(pod kubeApiCore.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != proxyContainer {
+			continue
+		}
+		for _, e := range c.Env {
+			if e.Name == status.KubeAppProberEnvName {
+				return e.Value
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"istio.io/istio/pilot/cmd/pilot-agent/status"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/proto"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// forwardingWorkload records the URLs of the requests it forwards.
+type forwardingWorkload struct {
+	fakeWorkload
+	urls *[]string
+	err  error
+}
+
+func (w forwardingWorkload) ForwardEcho(_ context.Context, req *proto.ForwardEchoRequest) (client.ParsedResponses, error) {
+	*w.urls = append(*w.urls, req.Url)
+	return nil, w.err
+}
+
+func TestSetGRPCHealth(t *testing.T) {
+	var urls []string
+	target := FakeInstance{
+		ConfigValue: Config{Service: "a", Ports: []Port{
+			{Name: "grpc", Protocol: protocol.GRPC, InstancePort: 17070},
+			{Name: "http", Protocol: protocol.HTTP, InstancePort: 18080},
+		}},
+		WorkloadsValue: []Workload{
+			forwardingWorkload{fakeWorkload: fakeWorkload{address: "10.0.0.1"}, urls: &urls},
+			forwardingWorkload{fakeWorkload: fakeWorkload{address: "10.0.0.2"}, urls: &urls},
+		},
+	}
+	if err := SetGRPCHealth(target, "NOT_SERVING"); err != nil {
+		t.Fatal(err)
+	}
+	want := "http://127.0.0.1:18080/?grpcHealth=NOT_SERVING"
+	if len(urls) != 2 || urls[0] != want || urls[1] != want {
+		t.Fatalf("got %v, want each workload to be sent %s", urls, want)
+	}
+
+	target.WorkloadsValue = []Workload{
+		forwardingWorkload{fakeWorkload: fakeWorkload{address: "10.0.0.1"}, urls: &urls, err: errors.New("refused")},
+	}
+	if err := SetGRPCHealth(target, "SERVING"); err == nil || !strings.Contains(err.Error(), "10.0.0.1") {
+		t.Fatalf("got error %v, want the workload that failed", err)
+	}
+
+	target.ConfigValue.Ports = target.ConfigValue.Ports[:1]
+	if err := SetGRPCHealth(target, "SERVING"); err == nil {
+		t.Fatal("expected an error for an instance without an HTTP port")
+	}
+}
+
+// probedPod returns a pod of the service whose app container is probed on the port and path, with the given app
+// probers passed to its proxy.
+func probedPod(name string, port int, path, probers string) *kubeApiCore.Pod {
+	return &kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{"app": "a"}},
+		Spec: kubeApiCore.PodSpec{Containers: []kubeApiCore.Container{
+			{
+				Name: "app",
+				ReadinessProbe: &kubeApiCore.Probe{Handler: kubeApiCore.Handler{
+					HTTPGet: &kubeApiCore.HTTPGetAction{Port: intstr.FromInt(port), Path: path},
+				}},
+				LivenessProbe: &kubeApiCore.Probe{Handler: kubeApiCore.Handler{
+					TCPSocket: &kubeApiCore.TCPSocketAction{Port: intstr.FromInt(8080)},
+				}},
+			},
+			{
+				Name: proxyContainer,
+				Env:  []kubeApiCore.EnvVar{{Name: status.KubeAppProberEnvName, Value: probers}},
+			},
+		}},
+	}
+}
+
+func TestCheckProbes(t *testing.T) {
+	readyz, _, _ := status.FormatProberURL("app")
+	probers := `{"` + readyz + `":{"httpGet":{"path":"/","port":8080}}}`
+	cases := []struct {
+		name         string
+		pod          *kubeApiCore.Pod
+		rewritten    bool
+		notRewritten bool
+	}{
+		{"rewritten", probedPod("a-1", agentStatusPort, readyz, probers), true, false},
+		{"not rewritten", probedPod("a-1", 8080, "/", ""), false, true},
+		{"not in probers", probedPod("a-1", agentStatusPort, readyz, "{}"), false, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			i := FakeInstance{ConfigValue: Config{
+				Service:   "a",
+				Namespace: namespace.Fake{NameValue: "ns"},
+				Cluster:   resource.FakeCluster{ExtendedClient: kube.NewFakeClient(tc.pod).(kube.ExtendedClient), NameValue: "cluster-0"},
+			}}
+			if err := CheckProbesRewritten(i); (err == nil) != tc.rewritten {
+				t.Errorf("CheckProbesRewritten: got error %v, want rewritten %v", err, tc.rewritten)
+			}
+			if err := CheckProbesNotRewritten(i); (err == nil) != tc.notRewritten {
+				t.Errorf("CheckProbesNotRewritten: got error %v, want not rewritten %v", err, tc.notRewritten)
+			}
+		})
+	}
+
+	none := FakeInstance{ConfigValue: Config{
+		Service:   "a",
+		Namespace: namespace.Fake{NameValue: "ns"},
+		Cluster:   resource.FakeCluster{ExtendedClient: kube.NewFakeClient().(kube.ExtendedClient), NameValue: "cluster-0"},
+	}}
+	if err := CheckProbesRewritten(none); err == nil || !strings.Contains(err.Error(), "no pods found") {
+		t.Fatalf("got error %v, want no pods to be found", err)
+	}
+}
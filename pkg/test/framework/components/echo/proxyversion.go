@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/envoy"
+)
+
+// ProxyImage returns the proxy image with the given hub and tag.
+func ProxyImage(hub, tag string) string {
+	return fmt.Sprintf("%s/proxyv2:%s", hub, tag)
+}
+
+// WithProxyImage returns a copy of the config in which every subset is injected with the given proxy image, rather
+// than the image of the control plane. This allows testing version skew between the control plane and data plane.
+func WithProxyImage(cfg Config, image string) Config {
+	if len(cfg.Subsets) == 0 {
+		cfg.Subsets = []SubsetConfig{{Version: cfg.Version}}
+	}
+	subsets := make([]SubsetConfig, 0, len(cfg.Subsets))
+	for _, s := range cfg.Subsets {
+		annotations := NewAnnotations()
+		for k, v := range s.Annotations {
+			annotations[k] = v
+		}
+		s.Annotations = annotations.Set(SidecarProxyImage, image)
+		subsets = append(subsets, s)
+	}
+	cfg.Subsets = subsets
+	return cfg
+}
+
+// ProxyVersion returns the Istio version reported by the sidecar of the workload.
+func ProxyVersion(w Workload) (string, error) {
+	if w.Sidecar() == nil {
+		return "", fmt.Errorf("workload %s has no sidecar", w.Address())
+	}
+	dump, err := w.Sidecar().Config()
+	if err != nil {
+		return "", err
+	}
+	for _, c := range dump.Configs {
+		if c.TypeUrl != envoy.BootstrapConfigDumpType {
+			continue
+		}
+		b := &envoyAdmin.BootstrapConfigDump{}
+		if err := ptypes.UnmarshalAny(c, b); err != nil {
+			return "", fmt.Errorf("failed unmarshaling bootstrap of %s: %v", w.Address(), err)
+		}
+		v := b.GetBootstrap().GetNode().GetMetadata().GetFields()["ISTIO_VERSION"].GetStringValue()
+		if v == "" {
+			return "", fmt.Errorf("sidecar of %s does not report a version", w.Address())
+		}
+		return v, nil
+	}
+	return "", fmt.Errorf("config dump of %s has no bootstrap", w.Address())
+}
+
+// CheckProxyVersion verifies that the sidecars of all workloads of the instance report the given Istio version.
+func CheckProxyVersion(i Instance, version string) error {
+	workloads, err := i.Workloads()
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, w := range workloads {
+		v, err := ProxyVersion(w)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if v != version {
+			errs = multierror.Append(errs, fmt.Errorf("sidecar of %s/%s reports version %s, expected %s",
+				i.Config().Service, w.Address(), v, version))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,121 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"strings"
+	"testing"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	pstruct "github.com/golang/protobuf/ptypes/struct"
+)
+
+// versionSidecar serves a config dump whose bootstrap reports the version.
+type versionSidecar struct {
+	Sidecar
+	configs []*any.Any
+}
+
+func (s versionSidecar) Config() (*envoyAdmin.ConfigDump, error) {
+	return &envoyAdmin.ConfigDump{Configs: s.configs}, nil
+}
+
+func versionWorkload(t *testing.T, address, version string) Workload {
+	t.Helper()
+	b, err := ptypes.MarshalAny(&envoyAdmin.BootstrapConfigDump{Bootstrap: &bootstrap.Bootstrap{Node: &core.Node{
+		Metadata: &pstruct.Struct{Fields: map[string]*pstruct.Value{
+			"ISTIO_VERSION": {Kind: &pstruct.Value_StringValue{StringValue: version}},
+		}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fakeWorkload{address: address, sidecar: versionSidecar{configs: []*any.Any{b}}}
+}
+
+func TestWithProxyImage(t *testing.T) {
+	image := ProxyImage("gcr.io/istio-release", "1.8.0")
+	if image != "gcr.io/istio-release/proxyv2:1.8.0" {
+		t.Fatalf("got image %s", image)
+	}
+
+	cfg := WithProxyImage(Config{Version: "v1"}, image)
+	if len(cfg.Subsets) != 1 || cfg.Subsets[0].Version != "v1" || cfg.Subsets[0].Annotations.Get(SidecarProxyImage) != image {
+		t.Fatalf("expected a subset of the version with the image, got %+v", cfg.Subsets)
+	}
+
+	orig := Config{Subsets: []SubsetConfig{
+		{Version: "v1", Annotations: NewAnnotations().Set(SidecarInject, "false")},
+		{Version: "v2"},
+	}}
+	cfg = WithProxyImage(orig, image)
+	for _, s := range cfg.Subsets {
+		if s.Annotations.Get(SidecarProxyImage) != image {
+			t.Fatalf("expected subset %s to use the image, got %v", s.Version, s.Annotations)
+		}
+	}
+	if cfg.Subsets[0].Annotations.Get(SidecarInject) != "false" {
+		t.Fatal("expected the other annotations of the subset to be kept")
+	}
+	if orig.Subsets[0].Annotations.Get(SidecarProxyImage) != "" || orig.Subsets[1].Annotations != nil {
+		t.Fatal("expected the original config to be left unchanged")
+	}
+}
+
+func TestProxyVersion(t *testing.T) {
+	v, err := ProxyVersion(versionWorkload(t, "10.0.0.1", "1.8.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "1.8.0" {
+		t.Fatalf("got version %s, want 1.8.0", v)
+	}
+
+	cases := []struct {
+		name     string
+		workload Workload
+		err      string
+	}{
+		{"no sidecar", fakeWorkload{address: "10.0.0.1"}, "has no sidecar"},
+		{"no bootstrap", fakeWorkload{address: "10.0.0.1", sidecar: versionSidecar{}}, "has no bootstrap"},
+		{"no version", versionWorkload(t, "10.0.0.1", ""), "does not report a version"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ProxyVersion(tc.workload)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got error %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestCheckProxyVersion(t *testing.T) {
+	i := FakeInstance{
+		ConfigValue:    Config{Service: "a"},
+		WorkloadsValue: []Workload{versionWorkload(t, "10.0.0.1", "1.8.0"), versionWorkload(t, "10.0.0.2", "1.7.0")},
+	}
+	err := CheckProxyVersion(i, "1.8.0")
+	if err == nil || !strings.Contains(err.Error(), "a/10.0.0.2 reports version 1.7.0") {
+		t.Fatalf("expected the skewed workload to be reported, got %v", err)
+	}
+	if strings.Contains(err.Error(), "10.0.0.1") {
+		t.Fatalf("expected only the skewed workload to be reported, got %v", err)
+	}
+}
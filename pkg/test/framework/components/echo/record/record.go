@@ -0,0 +1,233 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package record records the echo calls of a run, with a summary of their results, and replays them against another
+// environment, such as one running another version or topology, reporting the calls whose results differ.
+//
+// Calls are recorded to the file given by --istio.test.echo.record, as a JSON object per line. Instances are
+// identified by service and namespace, with the random suffix of generated namespaces removed, so that the instances
+// of the environment a recording is replayed against are matched by the names they were deployed with, whatever the
+// clusters they are deployed to.
+package record
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+var mu sync.Mutex
+
+// Endpoint identifies an echo instance across environments. The cluster of the instance is left out, so that a
+// recording is replayed against another topology.
+type Endpoint struct {
+	Service   string `json:"service"`
+	Namespace string `json:"namespace"`
+}
+
+// generatedSuffix is the suffix added to the prefix of generated namespaces.
+var generatedSuffix = regexp.MustCompile(`-\d+-\d+$`)
+
+// EndpointOf returns the endpoint identifying the instance.
+func EndpointOf(i echo.Instance) Endpoint {
+	cfg := i.Config()
+	e := Endpoint{Service: cfg.Service}
+	if cfg.Namespace != nil {
+		e.Namespace = generatedSuffix.ReplaceAllString(cfg.Namespace.Name(), "")
+	}
+	return e
+}
+
+// Options are the recorded call options, which are all of echo.CallOptions. Options derived from the target, such as
+// the host, are left empty if the call had a target, so that they are derived from the matching target on replay.
+type Options struct {
+	Target        *Endpoint             `json:"target,omitempty"`
+	PortName      string                `json:"portName,omitempty"`
+	Scheme        string                `json:"scheme,omitempty"`
+	HTTP2         bool                  `json:"http2,omitempty"`
+	Host          string                `json:"host,omitempty"`
+	HostHeader    string                `json:"hostHeader,omitempty"`
+	Path          string                `json:"path,omitempty"`
+	Count         int                   `json:"count,omitempty"`
+	Headers       map[string][]string   `json:"headers,omitempty"`
+	Timeout       time.Duration         `json:"timeout,omitempty"`
+	Message       string                `json:"message,omitempty"`
+	BodySize      int                   `json:"bodySize,omitempty"`
+	StreamBody    bool                  `json:"streamBody,omitempty"`
+	HeaderSize    int                   `json:"headerSize,omitempty"`
+	ResponseDelay time.Duration         `json:"responseDelay,omitempty"`
+	Method        string                `json:"method,omitempty"`
+	Cert          string                `json:"cert,omitempty"`
+	Key           string                `json:"key,omitempty"`
+	CaCert        string                `json:"caCert,omitempty"`
+	RequestID     string                `json:"requestID,omitempty"`
+	Session       *echo.SessionOptions  `json:"session,omitempty"`
+	Socket        *common.SocketOptions `json:"socket,omitempty"`
+	Idle          time.Duration         `json:"idle,omitempty"`
+	TLS           *common.TLSOptions    `json:"tls,omitempty"`
+	TraceContext  []common.TraceFormat  `json:"traceContext,omitempty"`
+	Fault         *common.Fault         `json:"fault,omitempty"`
+}
+
+// Result summarizes the responses of a call, leaving out the details that differ between environments, such as the
+// names of pods and the text of errors, which holds the names of namespaces.
+type Result struct {
+	Failed   bool           `json:"failed,omitempty"`
+	Codes    map[string]int `json:"codes,omitempty"`
+	Clusters []string       `json:"clusters,omitempty"`
+	Versions []string       `json:"versions,omitempty"`
+}
+
+// Call is a recorded call.
+type Call struct {
+	From    Endpoint `json:"from"`
+	Options Options  `json:"options"`
+	Result  Result   `json:"result"`
+}
+
+// Add appends the call from the instance to the file, unless the file is empty, which disables recording. The options
+// must be those passed to Call, rather than those filled in by it.
+func Add(file string, from echo.Instance, opts echo.CallOptions, resp client.ParsedResponses, err error) {
+	if file == "" {
+		return
+	}
+	c := Call{
+		From:    EndpointOf(from),
+		Options: optionsOf(opts),
+		Result:  Summarize(resp, err),
+	}
+	out, merr := json.Marshal(c)
+	if merr != nil {
+		scopes.Framework.Warnf("failed recording echo call: %v", merr)
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	f, ferr := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if ferr != nil {
+		scopes.Framework.Warnf("failed recording echo call: %v", ferr)
+		return
+	}
+	defer f.Close()
+	if _, werr := f.Write(append(out, '\n')); werr != nil {
+		scopes.Framework.Warnf("failed recording echo call: %v", werr)
+	}
+}
+
+func optionsOf(opts echo.CallOptions) Options {
+	o := Options{
+		PortName:      opts.PortName,
+		Scheme:        string(opts.Scheme),
+		HTTP2:         opts.HTTP2,
+		HostHeader:    opts.HostHeader,
+		Path:          opts.Path,
+		Count:         opts.Count,
+		Headers:       opts.Headers,
+		Timeout:       opts.Timeout,
+		Message:       opts.Message,
+		BodySize:      opts.BodySize,
+		StreamBody:    opts.StreamBody,
+		HeaderSize:    opts.HeaderSize,
+		ResponseDelay: opts.ResponseDelay,
+		Method:        opts.Method,
+		Cert:          opts.Cert,
+		Key:           opts.Key,
+		CaCert:        opts.CaCert,
+		RequestID:     opts.RequestID,
+		Session:       opts.Session,
+		Socket:        opts.Socket,
+		Idle:          opts.Idle,
+		TLS:           opts.TLS,
+		TraceContext:  opts.TraceContext,
+		Fault:         opts.Fault,
+	}
+	if opts.Port != nil && o.PortName == "" {
+		o.PortName = opts.Port.Name
+	}
+	if opts.Target != nil {
+		t := EndpointOf(opts.Target)
+		o.Target = &t
+	} else {
+		o.Host = opts.Host
+	}
+	return o
+}
+
+// callOptions returns the options of the call, without its target.
+func (o Options) callOptions() echo.CallOptions {
+	return echo.CallOptions{
+		PortName:      o.PortName,
+		Scheme:        scheme.Instance(o.Scheme),
+		HTTP2:         o.HTTP2,
+		Host:          o.Host,
+		HostHeader:    o.HostHeader,
+		Path:          o.Path,
+		Count:         o.Count,
+		Headers:       http.Header(o.Headers),
+		Timeout:       o.Timeout,
+		Message:       o.Message,
+		BodySize:      o.BodySize,
+		StreamBody:    o.StreamBody,
+		HeaderSize:    o.HeaderSize,
+		ResponseDelay: o.ResponseDelay,
+		Method:        o.Method,
+		Cert:          o.Cert,
+		Key:           o.Key,
+		CaCert:        o.CaCert,
+		RequestID:     o.RequestID,
+		Session:       o.Session,
+		Socket:        o.Socket,
+		Idle:          o.Idle,
+		TLS:           o.TLS,
+		TraceContext:  o.TraceContext,
+		Fault:         o.Fault,
+	}
+}
+
+// Summarize the responses of a call.
+func Summarize(resp client.ParsedResponses, err error) Result {
+	if err != nil {
+		return Result{Failed: true}
+	}
+	r := Result{Codes: map[string]int{}}
+	clusters := map[string]struct{}{}
+	versions := map[string]struct{}{}
+	for _, p := range resp {
+		r.Codes[p.Code]++
+		clusters[p.Cluster] = struct{}{}
+		versions[p.Version] = struct{}{}
+	}
+	r.Clusters = sortedKeys(clusters)
+	r.Versions = sortedKeys(versions)
+	return r
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
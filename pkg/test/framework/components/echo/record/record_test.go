@@ -0,0 +1,235 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// fakeInstance records its calls, which each succeed in its cluster.
+type fakeInstance struct {
+	echo.FakeInstance
+	calls []echo.CallOptions
+}
+
+func (i *fakeInstance) Call(opts echo.CallOptions) (client.ParsedResponses, error) {
+	i.calls = append(i.calls, opts)
+	return client.ParsedResponses{{Code: "200", Cluster: i.ConfigValue.Cluster.Name()}}, nil
+}
+
+func newInstance(service, ns, cluster string) *fakeInstance {
+	return &fakeInstance{FakeInstance: echo.FakeInstance{ConfigValue: echo.Config{
+		Service:   service,
+		Namespace: namespace.Fake{NameValue: ns},
+		Cluster:   resource.FakeCluster{NameValue: cluster},
+	}}}
+}
+
+func TestEndpointOf(t *testing.T) {
+	cases := []struct {
+		name     string
+		instance *fakeInstance
+		want     Endpoint
+	}{
+		{"generated namespace", newInstance("a", "echo-1-12345", "cluster-0"), Endpoint{Service: "a", Namespace: "echo"}},
+		{"named namespace", newInstance("a", "echo", "cluster-1"), Endpoint{Service: "a", Namespace: "echo"}},
+		{"no namespace", &fakeInstance{FakeInstance: echo.FakeInstance{ConfigValue: echo.Config{Service: "a"}}}, Endpoint{Service: "a"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := EndpointOf(tc.instance); got != tc.want {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	cases := []struct {
+		name string
+		resp client.ParsedResponses
+		err  error
+		want Result
+	}{
+		{
+			name: "failed",
+			resp: client.ParsedResponses{{Code: "200"}},
+			err:  errors.New("call failed"),
+			want: Result{Failed: true},
+		},
+		{
+			name: "responses",
+			resp: client.ParsedResponses{
+				{Code: "200", Cluster: "b", Version: "v1", Hostname: "pod-1"},
+				{Code: "503", Cluster: "a", Version: "v2", Hostname: "pod-2"},
+				{Code: "200", Cluster: "a", Version: "v1", Hostname: "pod-3"},
+			},
+			want: Result{
+				Codes:    map[string]int{"200": 2, "503": 1},
+				Clusters: []string{"a", "b"},
+				Versions: []string{"v1", "v2"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, Summarize(tc.resp, tc.err)); diff != "" {
+				t.Fatal(diff)
+			}
+		})
+	}
+}
+
+func TestOptionsRoundTrip(t *testing.T) {
+	opts := echo.CallOptions{
+		PortName:      "http",
+		Scheme:        scheme.HTTPS,
+		HTTP2:         true,
+		Host:          "b.example.com",
+		HostHeader:    "b",
+		Path:          "/path",
+		Count:         3,
+		Headers:       map[string][]string{"X-Test": {"1"}},
+		Timeout:       time.Second,
+		Message:       "hello",
+		BodySize:      10,
+		StreamBody:    true,
+		HeaderSize:    20,
+		ResponseDelay: 2 * time.Second,
+		Method:        "PUT",
+		Cert:          "cert",
+		Key:           "key",
+		CaCert:        "ca",
+		RequestID:     "request",
+		Session:       &echo.SessionOptions{Header: "x-session", Issue: true},
+		Socket:        &common.SocketOptions{KeepAlive: time.Second, Linger: 0},
+		Idle:          3 * time.Second,
+		TLS:           &common.TLSOptions{MinVersion: "TLSV1_2", ALPN: []string{"h2"}, Resumption: true},
+		TraceContext:  []common.TraceFormat{common.B3, common.W3C},
+		Fault:         &common.Fault{ChunkSize: 1, Close: common.Truncate, CloseAfter: 2},
+	}
+	// Every option other than those derived from the target must be set, so that options added later are recorded.
+	v := reflect.ValueOf(opts)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		if name == "Target" || name == "Port" {
+			continue
+		}
+		if v.Field(i).IsZero() {
+			t.Fatalf("option %s is not set by the test", name)
+		}
+	}
+
+	out, err := json.Marshal(optionsOf(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var o Options
+	if err := json.Unmarshal(out, &o); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(opts, o.callOptions()); diff != "" {
+		t.Fatalf("replayed options differ from the recorded ones: %s", diff)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "calls.json")
+
+	from := newInstance("a", "echo-1-12345", "cluster-0")
+	target := newInstance("b", "echo-1-12345", "cluster-0")
+	Add(file, from, echo.CallOptions{Target: target, PortName: "http", Count: 2}, client.ParsedResponses{{Code: "200"}}, nil)
+	Add(file, from, echo.CallOptions{Host: "example.com", PortName: "http"}, nil, errors.New("call failed"))
+	// Without a file, calls are not recorded.
+	Add("", from, echo.CallOptions{Host: "example.com", PortName: "http"}, nil, nil)
+
+	calls, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Call{
+		{
+			From:    Endpoint{Service: "a", Namespace: "echo"},
+			Options: Options{Target: &Endpoint{Service: "b", Namespace: "echo"}, PortName: "http", Count: 2},
+			Result:  Result{Codes: map[string]int{"200": 1}, Clusters: []string{""}, Versions: []string{""}},
+		},
+		{
+			From:    Endpoint{Service: "a", Namespace: "echo"},
+			Options: Options{Host: "example.com", PortName: "http"},
+			Result:  Result{Failed: true},
+		},
+	}
+	if diff := cmp.Diff(want, calls); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("{\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(file); err == nil {
+		t.Fatal("expected an error loading an invalid recording")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	// The recording was made on a single cluster and is replayed on two.
+	calls := []Call{{
+		From:    Endpoint{Service: "a", Namespace: "echo"},
+		Options: Options{Target: &Endpoint{Service: "b", Namespace: "echo"}, PortName: "http"},
+		Result:  Result{Codes: map[string]int{"200": 1}, Clusters: []string{"cluster-0"}},
+	}}
+	a0, a1 := newInstance("a", "echo-2-1", "cluster-0"), newInstance("a", "echo-2-1", "cluster-1")
+	b := newInstance("b", "echo-2-1", "cluster-1")
+
+	diffs, err := Replay(calls, echo.Instances{a0, a1, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a0.calls) != 1 || len(a1.calls) != 0 {
+		t.Fatalf("got %d and %d calls from the instances, want the first instance to replay the call",
+			len(a0.calls), len(a1.calls))
+	}
+	if a0.calls[0].Target != b || a0.calls[0].PortName != "http" {
+		t.Fatalf("replayed call has options %+v", a0.calls[0])
+	}
+	if len(diffs) != 1 || !reflect.DeepEqual(diffs[0].Replayed.Clusters, []string{"cluster-1"}) {
+		t.Fatalf("got diffs %v, want one for the responses from cluster-1", diffs)
+	}
+
+	if _, err := Replay(calls, echo.Instances{b}); err == nil {
+		t.Fatal("expected an error replaying from a missing instance")
+	}
+}
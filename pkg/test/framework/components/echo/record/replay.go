@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+// Load the calls recorded to the file.
+func Load(path string) ([]Call, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var calls []Call
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; s.Scan(); n++ {
+		c := Call{}
+		if err := json.Unmarshal(s.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("invalid call on line %d of %s: %v", n, path, err)
+		}
+		calls = append(calls, c)
+	}
+	return calls, s.Err()
+}
+
+// Diff is a replayed call whose result differs from the recorded one.
+type Diff struct {
+	Call     Call
+	Replayed Result
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("%s/%s -> %+v: %s", d.Call.From.Namespace, d.Call.From.Service, d.Call.Options,
+		cmp.Diff(d.Call.Result, d.Replayed, cmpopts.EquateEmpty()))
+}
+
+// Replay the calls in order, from and to the matching instances, returning the calls whose results differ. Calls
+// whose instances have no match are an error, since the environments are expected to deploy the same instances.
+// Where an endpoint is matched by instances in several clusters, calls are made from the first of them.
+func Replay(calls []Call, instances echo.Instances) ([]Diff, error) {
+	byEndpoint := map[Endpoint]echo.Instance{}
+	for _, i := range instances {
+		if _, ok := byEndpoint[EndpointOf(i)]; !ok {
+			byEndpoint[EndpointOf(i)] = i
+		}
+	}
+	var diffs []Diff
+	for n, c := range calls {
+		from, ok := byEndpoint[c.From]
+		if !ok {
+			return nil, fmt.Errorf("call %d: no instance matches source %+v", n, c.From)
+		}
+		opts := c.Options.callOptions()
+		if c.Options.Target != nil {
+			target, ok := byEndpoint[*c.Options.Target]
+			if !ok {
+				return nil, fmt.Errorf("call %d: no instance matches target %+v", n, *c.Options.Target)
+			}
+			opts.Target = target
+		}
+		replayed := Summarize(from.Call(opts))
+		if !cmp.Equal(c.Result, replayed, cmpopts.EquateEmpty()) {
+			diffs = append(diffs, Diff{Call: c, Replayed: replayed})
+		}
+	}
+	return diffs, nil
+}
+
+// ReplayOrFail calls Replay, failing the test if it returns an error. Differences are returned rather than failing
+// the test, since they are the expected output of comparing environments.
+func ReplayOrFail(t test.Failer, calls []Call, instances echo.Instances) []Diff {
+	t.Helper()
+	diffs, err := Replay(calls, instances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return diffs
+}
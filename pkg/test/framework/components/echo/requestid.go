@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common"
+)
+
+// RequestIDHeader carries the ID of the requests of a call. Envoy preserves it, logs it in the default access log
+// format, and tags the spans of the request with it as guid:x-request-id. Each request of a call is sent with its own
+// ID, derived from that of the call by common.RequestIDForIndex.
+const RequestIDHeader = common.RequestIDHeader
+
+// requestIDTraceReason is the index of the character of a UUID request ID that Envoy overwrites with the reason the
+// request is traced.
+const requestIDTraceReason = 14
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// requestIDPattern matches the IDs of the requests of the call with the ID, whatever the trace reason packed into them
+// by proxies on the path.
+func requestIDPattern(id string) *regexp.Regexp {
+	if !common.IsUUID(id) {
+		return regexp.MustCompile(regexp.QuoteMeta(id) + `(-\d+)?`)
+	}
+	// The leading 32 bits differ for each request of the call.
+	return regexp.MustCompile(`[0-9a-fA-F]{8}` + regexp.QuoteMeta(id[8:requestIDTraceReason]) + "." +
+		regexp.QuoteMeta(id[requestIDTraceReason+1:]))
+}
+
+// SameRequestID returns true if the request ID seen by a proxy or server is that of a request of the call with the ID.
+func SameRequestID(sent, seen string) bool {
+	loc := requestIDPattern(sent).FindStringIndex(seen)
+	return loc != nil && loc[0] == 0 && loc[1] == len(seen)
+}
+
+// ResponsesForRequest returns the responses to the requests of the call with the request ID.
+func ResponsesForRequest(resp client.ParsedResponses, id string) client.ParsedResponses {
+	return resp.Match(func(r *client.ParsedResponse) bool {
+		return SameRequestID(id, r.ID)
+	})
+}
+
+// AccessLogsForRequest returns the lines of the sidecar logs of the workloads holding the ID of a request of the call
+// with the request ID, leaving out the access logs of unrelated traffic. Metrics aggregate requests, so they cannot be
+// told apart by ID; metrics assertions should be scoped by the workloads of the test instead.
+func AccessLogsForRequest(workloads []Workload, id string) ([]string, error) {
+	re := requestIDPattern(id)
+	var out []string
+	var errs error
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			continue
+		}
+		logs, err := w.Sidecar().Logs()
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		for _, line := range strings.Split(logs, "\n") {
+			if re.MatchString(line) {
+				out = append(out, line)
+			}
+		}
+	}
+	return out, errs
+}
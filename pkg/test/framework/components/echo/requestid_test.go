@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common"
+)
+
+type loggingSidecar struct {
+	Sidecar
+	logs string
+	err  error
+}
+
+func (s loggingSidecar) Logs() (string, error) {
+	return s.logs, s.err
+}
+
+func TestSameRequestID(t *testing.T) {
+	const id = "0000000a-bbbb-4ccc-8ddd-eeeeeeeeeeee"
+	cases := []struct {
+		name string
+		sent string
+		seen string
+		want bool
+	}{
+		{"same uuid", id, id, true},
+		{"later request", id, common.RequestIDForIndex(id, 3), true},
+		{"trace reason", id, "0000000a-bbbb-9ccc-8ddd-eeeeeeeeeeee", true},
+		{"other uuid", id, "0000000a-bbbb-4ccc-8ddd-eeeeeeeeeee0", false},
+		{"prefix", id, id + "0", false},
+		{"same other", "req", "req", true},
+		{"later other", "req", "req-2", true},
+		{"other other", "req", "request", false},
+		{"quoted", "a.b", "axb", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SameRequestID(tc.sent, tc.seen); got != tc.want {
+				t.Fatalf("SameRequestID(%q, %q) = %v, want %v", tc.sent, tc.seen, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResponsesForRequest(t *testing.T) {
+	id := NewRequestID()
+	if !common.IsUUID(id) {
+		t.Fatalf("request ID %q is not a UUID", id)
+	}
+	resp := client.ParsedResponses{
+		{ID: id, Code: "200"},
+		{ID: NewRequestID(), Code: "503"},
+		{ID: common.RequestIDForIndex(id, 1), Code: "201"},
+	}
+	got := ResponsesForRequest(resp, id)
+	if len(got) != 2 || got[0].Code != "200" || got[1].Code != "201" {
+		t.Fatalf("got responses %v, want those of the call", got)
+	}
+}
+
+func TestAccessLogsForRequest(t *testing.T) {
+	logs := "[2021-01-01] \"GET / HTTP/1.1\" 200 \"req\"\n" +
+		"[2021-01-01] \"GET / HTTP/1.1\" 503 \"other\"\n" +
+		"[2021-01-01] \"GET / HTTP/1.1\" 200 \"req-1\""
+	workloads := []Workload{
+		fakeWorkload{address: "10.0.0.1", sidecar: loggingSidecar{logs: logs}},
+		fakeWorkload{address: "10.0.0.2"},
+		fakeWorkload{address: "10.0.0.3", sidecar: loggingSidecar{err: errors.New("pod not found")}},
+	}
+	got, err := AccessLogsForRequest(workloads, "req")
+	want := []string{
+		"[2021-01-01] \"GET / HTTP/1.1\" 200 \"req\"",
+		"[2021-01-01] \"GET / HTTP/1.1\" 200 \"req-1\"",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got logs %q, want %q", got, want)
+	}
+	if err == nil {
+		t.Error("expected the error of the sidecar that failed to return its logs")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"istio.io/istio/pkg/test/envoy"
+)
+
+// SanitizedConfig returns the xDS config of the first workload of the instance, as sanitized JSON suitable for
+// comparing against other workloads or golden files. The bootstrap and secrets are excluded, since they differ between
+// builds and pods. Names specific to the workload are replaced with placeholders, as are references to the istiod of
+// the given revision, if set, and the addresses of the peers, the instances whose services are in the config.
+func SanitizedConfig(i Instance, revision string, peers ...Instance) (string, error) {
+	workloads, err := i.Workloads()
+	if err != nil {
+		return "", err
+	}
+	if len(workloads) == 0 {
+		return "", fmt.Errorf("no workloads for %s", i.Config().Service)
+	}
+	w := workloads[0]
+	if w.Sidecar() == nil {
+		return "", fmt.Errorf("%s has no sidecar", i.Config().Service)
+	}
+	dump, err := w.Sidecar().Config()
+	if err != nil {
+		return "", fmt.Errorf("failed fetching config for %s: %v", i.Config().Service, err)
+	}
+	dump = envoy.FilterConfigDump(dump, envoy.BootstrapConfigDumpType, envoy.SecretsConfigDumpType)
+	r, err := PeerReplacements(peers)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range WorkloadReplacements(w, i.Config().Namespace.Name(), revision) {
+		r[k] = v
+	}
+	return envoy.SanitizeConfigDump(dump, r)
+}
+
+// PeerReplacements returns replacements of the service and workload addresses of the instances with placeholders
+// named after their services, for use with envoy.SanitizeConfigDump. Cluster IPs are assigned to each run, and appear
+// in the names of outbound listeners, as do the workload addresses of headless services.
+func PeerReplacements(instances []Instance) (map[string]string, error) {
+	r := map[string]string{}
+	for _, p := range instances {
+		svc := p.Config().Service
+		if p.Address() != "" {
+			r[p.Address()] = "{{.ServiceIP." + svc + "}}"
+		}
+		workloads, err := p.Workloads()
+		if err != nil {
+			return nil, err
+		}
+		addresses := make([]string, 0, len(workloads))
+		for _, w := range workloads {
+			addresses = append(addresses, w.Address())
+		}
+		sort.Strings(addresses)
+		for n, a := range addresses {
+			r[a] = fmt.Sprintf("{{.WorkloadIP.%s.%d}}", svc, n)
+		}
+	}
+	return r, nil
+}
+
+// WorkloadReplacements returns replacements of the names specific to a workload with placeholders, for use with
+// envoy.SanitizeConfigDump.
+func WorkloadReplacements(w Workload, ns, revision string) map[string]string {
+	r := map[string]string{
+		ns:          "{{.Namespace}}",
+		w.Address(): "{{.IP}}",
+	}
+	if revision != "" {
+		r["istiod-"+revision] = "istiod-{{.Revision}}"
+	}
+	// The node ID has the form <type>~<ip>~<pod>.<namespace>~<domain>.
+	if w.Sidecar() != nil {
+		if parts := strings.Split(w.Sidecar().NodeID(), "~"); len(parts) == 4 {
+			if pod := strings.TrimSuffix(parts[2], "."+ns); pod != "" && pod != parts[2] {
+				r[pod] = "{{.Pod}}"
+			}
+		}
+	}
+	return r
+}
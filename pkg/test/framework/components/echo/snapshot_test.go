@@ -0,0 +1,64 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPeerReplacements(t *testing.T) {
+	clusterIP := directTarget(false, "10.0.0.2", "10.0.0.1")
+	clusterIP.AddressValue = "10.96.0.1"
+	headless := directTarget(true, "10.0.0.3")
+	headless.ConfigValue.Service = "headless"
+
+	got, err := PeerReplacements([]Instance{clusterIP, headless})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"10.96.0.1": "{{.ServiceIP.b}}",
+		"10.0.0.1":  "{{.WorkloadIP.b.0}}",
+		"10.0.0.2":  "{{.WorkloadIP.b.1}}",
+		"10.0.0.3":  "{{.WorkloadIP.headless.0}}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestWorkloadReplacements(t *testing.T) {
+	w := fakeWorkload{
+		address: "10.0.0.1",
+		sidecar: fakeSidecar{nodeID: "sidecar~10.0.0.1~b-v1-7d4f8c-x2k9p.ns~ns.svc.cluster.local"},
+	}
+	got := WorkloadReplacements(w, "ns", "canary")
+	want := map[string]string{
+		"ns":                "{{.Namespace}}",
+		"10.0.0.1":          "{{.IP}}",
+		"istiod-canary":     "istiod-{{.Revision}}",
+		"b-v1-7d4f8c-x2k9p": "{{.Pod}}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	got = WorkloadReplacements(fakeWorkload{address: "10.0.0.1"}, "ns", "")
+	want = map[string]string{"ns": "{{.Namespace}}", "10.0.0.1": "{{.IP}}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v for a workload without a sidecar, want %v", got, want)
+	}
+}
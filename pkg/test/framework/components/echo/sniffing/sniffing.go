@@ -0,0 +1,182 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sniffing covers protocol detection, by crossing the ways the protocol of a service port is declared with the
+// protocols spoken on the wire, and checking that each combination is handled as expected.
+//
+// The echo instance under test is deployed with Ports, which has a port per combination. Run calls each of them from
+// the sources, as a sub-test named <declaration>/<wire>.
+package sniffing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Declaration is the way the protocol of a service port is declared.
+type Declaration string
+
+const (
+	// Named declares the protocol with the prefix of the name of the port, such as "http-web".
+	Named Declaration = "named"
+	// AppProtocol declares the protocol with the appProtocol field of the port.
+	AppProtocol Declaration = "appProtocol"
+	// Unnamed does not declare the protocol, which is detected from the first bytes sent by the client.
+	Unnamed Declaration = "unnamed"
+)
+
+// Declarations are all the ways the protocol of a port is declared.
+var Declarations = []Declaration{Named, AppProtocol, Unnamed}
+
+// Wire is a protocol spoken on the wire.
+type Wire struct {
+	Name string
+	// Protocol declared for the port, and served by echo.
+	Protocol protocol.Instance
+	Scheme   scheme.Instance
+	HTTP2    bool
+	// TLS is served with the certificates of echo.Config.TLSSettings, and passed through by the proxies.
+	TLS bool
+	// HTTP is whether the proxies are expected to process the traffic as HTTP.
+	HTTP bool
+}
+
+var (
+	HTTP1 = Wire{Name: "http1", Protocol: protocol.HTTP, Scheme: scheme.HTTP, HTTP: true}
+	HTTP2 = Wire{Name: "http2", Protocol: protocol.HTTP2, Scheme: scheme.HTTP, HTTP2: true, HTTP: true}
+	GRPC  = Wire{Name: "grpc", Protocol: protocol.GRPC, Scheme: scheme.GRPC, HTTP: true}
+	TLS   = Wire{Name: "tls", Protocol: protocol.HTTPS, Scheme: scheme.HTTPS, TLS: true}
+	TCP   = Wire{Name: "tcp", Protocol: protocol.TCP, Scheme: scheme.TCP}
+)
+
+// Wires are the protocols spoken on the wire, other than TLS, which requires certificates.
+var Wires = []Wire{HTTP1, HTTP2, GRPC, TCP}
+
+// basePort is the first port of the combinations. Ports are numbered in the order of Cases.
+const basePort = 19000
+
+// Case is a combination of a declaration and a wire protocol, served on its own port.
+type Case struct {
+	Declaration Declaration
+	Wire        Wire
+	Port        echo.Port
+}
+
+func (c Case) String() string {
+	return string(c.Declaration) + "/" + c.Wire.Name
+}
+
+// Cases returns every combination of the declarations and wire protocols. TLS is included if withTLS is set, in which
+// case the instance under test must be deployed with TLS settings.
+func Cases(withTLS bool) []Case {
+	wires := Wires
+	if withTLS {
+		wires = append(append([]Wire{}, Wires...), TLS)
+	}
+	var out []Case
+	for _, d := range Declarations {
+		for _, w := range wires {
+			n := basePort + len(out)
+			out = append(out, Case{Declaration: d, Wire: w, Port: port(d, w, n)})
+		}
+	}
+	return out
+}
+
+func port(d Declaration, w Wire, n int) echo.Port {
+	declared := strings.ToLower(string(w.Protocol))
+	if w.TLS {
+		// Passed through rather than terminated, so declared as opaque TLS.
+		declared = strings.ToLower(string(protocol.TLS))
+	}
+	p := echo.Port{
+		Protocol:     w.Protocol,
+		ServicePort:  n,
+		InstancePort: n,
+		TLS:          w.TLS,
+	}
+	switch d {
+	case Named:
+		p.Name = fmt.Sprintf("%s-sniff-%s", declared, w.Name)
+	case AppProtocol:
+		p.Name = fmt.Sprintf("sniff-app-%s", w.Name)
+		p.AppProtocol = declared
+	case Unnamed:
+		p.Name = fmt.Sprintf("sniff-%s", w.Name)
+	}
+	return p
+}
+
+// Ports returns the ports of the cases, for the configuration of the instance under test.
+func Ports(withTLS bool) []echo.Port {
+	cases := Cases(withTLS)
+	out := make([]echo.Port, 0, len(cases))
+	for _, c := range cases {
+		out = append(out, c.Port)
+	}
+	return out
+}
+
+// Check that the responses of a call of the case were handled as expected: every call succeeds, and traffic is
+// processed as HTTP, as shown by the headers added by the proxies, only if it is HTTP on the wire.
+func (c Case) Check(resp client.ParsedResponses, err error) error {
+	if err != nil {
+		return err
+	}
+	if err := resp.CheckOK(); err != nil {
+		return err
+	}
+	for i, r := range resp {
+		processed := false
+		for k := range r.RawResponse {
+			if strings.EqualFold(k, "X-Forwarded-Proto") {
+				processed = true
+			}
+		}
+		if processed != c.Wire.HTTP {
+			return fmt.Errorf("response[%d]: expected traffic processed as HTTP to be %v, got %v", i, c.Wire.HTTP, processed)
+		}
+	}
+	return nil
+}
+
+// Run calls every case of the instance under test from each of the sources, as sub-tests.
+func Run(ctx framework.TestContext, sources echo.Instances, to echo.Instance, withTLS bool) {
+	for _, c := range Cases(withTLS) {
+		c := c
+		ctx.NewSubTest(c.String()).Run(func(ctx framework.TestContext) {
+			for _, from := range sources {
+				from := from
+				port := c.Port
+				retry.UntilSuccessOrFail(ctx, func() error {
+					return c.Check(from.Call(echo.CallOptions{
+						Target: to,
+						Port:   &port,
+						Scheme: c.Wire.Scheme,
+						HTTP2:  c.Wire.HTTP2,
+						Count:  1,
+					}))
+				}, retry.Delay(100*time.Millisecond), retry.Timeout(30*time.Second))
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sniffing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+func TestCases(t *testing.T) {
+	for _, withTLS := range []bool{false, true} {
+		cases := Cases(withTLS)
+		want := len(Declarations) * len(Wires)
+		if withTLS {
+			want += len(Declarations)
+		}
+		if len(cases) != want {
+			t.Fatalf("got %d cases with TLS %v, want %d", len(cases), withTLS, want)
+		}
+		names := map[string]bool{}
+		numbers := map[int]bool{}
+		for _, c := range cases {
+			p := c.Port
+			if names[p.Name] || numbers[p.ServicePort] {
+				t.Fatalf("case %s reuses the port %s/%d", c, p.Name, p.ServicePort)
+			}
+			names[p.Name] = true
+			numbers[p.ServicePort] = true
+			if p.InstancePort != p.ServicePort || p.Protocol != c.Wire.Protocol || p.TLS != c.Wire.TLS {
+				t.Fatalf("case %s got port %+v", c, p)
+			}
+		}
+		if len(Ports(withTLS)) != len(cases) {
+			t.Fatalf("got %d ports, want one per case", len(Ports(withTLS)))
+		}
+	}
+}
+
+func TestPort(t *testing.T) {
+	cases := []struct {
+		declaration Declaration
+		wire        Wire
+		name        string
+		appProtocol string
+	}{
+		{Named, HTTP1, "http-sniff-http1", ""},
+		{Named, GRPC, "grpc-sniff-grpc", ""},
+		{Named, TLS, "tls-sniff-tls", ""},
+		{AppProtocol, HTTP2, "sniff-app-http2", "http2"},
+		{AppProtocol, TLS, "sniff-app-tls", "tls"},
+		{Unnamed, TCP, "sniff-tcp", ""},
+	}
+	for _, tc := range cases {
+		c := Case{Declaration: tc.declaration, Wire: tc.wire}
+		t.Run(c.String(), func(t *testing.T) {
+			p := port(tc.declaration, tc.wire, basePort)
+			if p.Name != tc.name || p.AppProtocol != tc.appProtocol {
+				t.Fatalf("got port %s with appProtocol %q, want %s with %q", p.Name, p.AppProtocol, tc.name, tc.appProtocol)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	processed := client.ParsedResponses{{Code: "200", RawResponse: map[string]string{"X-Forwarded-Proto": "http"}}}
+	passedThrough := client.ParsedResponses{{Code: "200", RawResponse: map[string]string{}}}
+	cases := []struct {
+		name string
+		wire Wire
+		resp client.ParsedResponses
+		err  error
+		want string
+	}{
+		{"http processed", HTTP1, processed, nil, ""},
+		{"http passed through", HTTP1, passedThrough, nil, "expected traffic processed as HTTP to be true"},
+		{"tcp passed through", TCP, passedThrough, nil, ""},
+		{"tcp processed", TCP, processed, nil, "expected traffic processed as HTTP to be false"},
+		{"failed", HTTP1, client.ParsedResponses{{Code: "503"}}, nil, "Status Code: 503"},
+		{"error", HTTP1, nil, errors.New("connection reset"), "connection reset"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Case{Declaration: Unnamed, Wire: tc.wire}.Check(tc.resp, tc.err)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("got error %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test"
+)
+
+// TerminationOptions configures a CallDuringTermination.
+type TerminationOptions struct {
+	// Call options for each of the in-flight requests. Count is ignored; a single request is made per connection.
+	Call CallOptions
+
+	// Connections is the number of concurrent in-flight requests. Defaults to 1.
+	Connections int
+
+	// Hold is how long the target holds each request before responding. Requests still being held when
+	// the target is terminated exercise the drain behavior of the proxy. Defaults to 10s.
+	Hold time.Duration
+
+	// Settle is how long to wait after starting the requests before terminating the target, so
+	// that the requests are in flight. Defaults to 1s.
+	Settle time.Duration
+
+	// Terminate begins termination of the target, typically by deleting or evicting its pod. Required.
+	Terminate func() error
+}
+
+// InFlightResult is the outcome of a single request that was in flight during termination.
+type InFlightResult struct {
+	// Elapsed is the time between termination and the completion (or failure) of the request. It is negative if the
+	// request completed before the target was terminated.
+	Elapsed time.Duration
+	// Err is set if the request did not complete successfully, such as when the connection was reset.
+	Err error
+}
+
+// TerminationResults are the results of each request made by CallDuringTermination.
+type TerminationResults []InFlightResult
+
+// CallDuringTermination opens long-lived requests from the source to the target, terminates the target while the
+// requests are in flight, and reports when each request completes or fails relative to the termination.
+func CallDuringTermination(from Instance, opts TerminationOptions) (TerminationResults, error) {
+	if opts.Terminate == nil {
+		return nil, fmt.Errorf("terminate function must be provided")
+	}
+	if opts.Connections <= 0 {
+		opts.Connections = 1
+	}
+	if opts.Hold <= 0 {
+		opts.Hold = 10 * time.Second
+	}
+	if opts.Settle <= 0 {
+		opts.Settle = time.Second
+	}
+
+	callOpts := opts.Call
+	callOpts.Count = 1
+	callOpts.ResponseDelay = opts.Hold
+	if callOpts.Timeout <= opts.Hold {
+		callOpts.Timeout = opts.Hold + 30*time.Second
+	}
+
+	var wg sync.WaitGroup
+	// Each request only records when it completed, as a request may complete before the target is terminated.
+	done := make([]time.Time, opts.Connections)
+	results := make(TerminationResults, opts.Connections)
+	for i := 0; i < opts.Connections; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := from.Call(callOpts)
+			done[i] = time.Now()
+			results[i].Err = err
+		}()
+	}
+
+	time.Sleep(opts.Settle)
+	terminated := time.Now()
+	if err := opts.Terminate(); err != nil {
+		wg.Wait()
+		return nil, fmt.Errorf("failed terminating target: %v", err)
+	}
+	wg.Wait()
+	for i := range results {
+		results[i].Elapsed = done[i].Sub(terminated)
+	}
+	return results, nil
+}
+
+// CallDuringTerminationOrFail calls CallDuringTermination and fails the test if it returns an error.
+func CallDuringTerminationOrFail(t test.Failer, from Instance, opts TerminationOptions) TerminationResults {
+	t.Helper()
+	r, err := CallDuringTermination(from, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// CheckCompleted verifies that every in-flight request completed successfully, as expected when the target
+// drains for longer than the requests are held.
+func (r TerminationResults) CheckCompleted() error {
+	var errs error
+	for i, res := range r {
+		if res.Elapsed < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("request %d completed %v before termination, expected in flight",
+				i, -res.Elapsed))
+			continue
+		}
+		if res.Err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("request %d failed %v after termination: %v", i, res.Elapsed, res.Err))
+		}
+	}
+	return errs
+}
+
+// CheckReset verifies that every in-flight request failed, and that it did so no earlier than minDrain and no
+// later than maxDrain after termination. This is expected when requests are held longer than the drain duration.
+func (r TerminationResults) CheckReset(minDrain, maxDrain time.Duration) error {
+	var errs error
+	for i, res := range r {
+		if res.Err == nil {
+			errs = multierror.Append(errs, fmt.Errorf("request %d completed %v after termination, expected reset",
+				i, res.Elapsed))
+			continue
+		}
+		if res.Elapsed < minDrain || res.Elapsed > maxDrain {
+			errs = multierror.Append(errs, fmt.Errorf("request %d reset %v after termination, expected between %v and %v",
+				i, res.Elapsed, minDrain, maxDrain))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+var errReset = errors.New("connection reset by peer")
+
+// heldInstance holds each request for ResponseDelay, except the first, which completes at once.
+type heldInstance struct {
+	Instance
+	calls chan struct{}
+}
+
+func (h heldInstance) Call(options CallOptions) (client.ParsedResponses, error) {
+	select {
+	case h.calls <- struct{}{}:
+		return client.ParsedResponses{}, nil
+	default:
+	}
+	time.Sleep(options.ResponseDelay)
+	return client.ParsedResponses{}, nil
+}
+
+func TestCallDuringTermination(t *testing.T) {
+	from := heldInstance{calls: make(chan struct{}, 1)}
+	results, err := CallDuringTermination(from, TerminationOptions{
+		Connections: 2,
+		Hold:        500 * time.Millisecond,
+		Settle:      100 * time.Millisecond,
+		Terminate:   func() error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var early, held int
+	for _, res := range results {
+		switch {
+		case res.Elapsed < 0:
+			early++
+		case res.Elapsed < time.Second:
+			held++
+		default:
+			t.Fatalf("got elapsed %v, want it relative to termination", res.Elapsed)
+		}
+	}
+	if early != 1 || held != 1 {
+		t.Fatalf("got %v, want one request completed before termination and one after", results)
+	}
+	err = results.CheckCompleted()
+	if err == nil || !strings.Contains(err.Error(), "before termination") {
+		t.Fatalf("expected the request completed before termination to be reported, got %v", err)
+	}
+}
+
+func TestCheckReset(t *testing.T) {
+	reset := TerminationResults{{Elapsed: 5 * time.Second, Err: errReset}}
+	if err := reset.CheckReset(time.Second, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []TerminationResults{
+		{{Elapsed: 5 * time.Second}},
+		{{Elapsed: -time.Second, Err: errReset}},
+		{{Elapsed: 20 * time.Second, Err: errReset}},
+	} {
+		if err := r.CheckReset(time.Second, 10*time.Second); err == nil {
+			t.Fatalf("expected %v to fail the check", r)
+		}
+	}
+}
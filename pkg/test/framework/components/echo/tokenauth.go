@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	"github.com/hashicorp/go-multierror"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+)
+
+// xdsAuthFailure is logged by the sidecar when istiod rejects its token.
+const xdsAuthFailure = "Unauthenticated"
+
+// CheckXDSAuthenticated verifies that the sidecars of all workloads of the instance authenticated to istiod with their
+// service account token, and received their config.
+func CheckXDSAuthenticated(i Instance) error {
+	workloads, err := i.Workloads()
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			errs = multierror.Append(errs, fmt.Errorf("workload %s has no sidecar", w.Address()))
+			continue
+		}
+		if err := w.Sidecar().WaitForConfig(func(*envoyAdmin.ConfigDump) (bool, error) {
+			return true, nil
+		}); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("sidecar of %s received no config: %v", w.Address(), err))
+		}
+	}
+	return errs
+}
+
+// CheckXDSAuthenticatedOrFail calls CheckXDSAuthenticated and fails the test if it returns an error.
+func CheckXDSAuthenticatedOrFail(t test.Failer, i Instance) {
+	t.Helper()
+	if err := CheckXDSAuthenticated(i); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// CheckXDSAuthenticationFailed verifies that the sidecars of the pods deployed for the config were rejected by
+// istiod. Such pods never become ready, so the instance is expected to fail to build, and the pods are looked up by
+// the config instead.
+func CheckXDSAuthenticationFailed(cfg Config) error {
+	ns := cfg.Namespace.Name()
+	pods, err := cfg.Cluster.CoreV1().Pods(ns).List(context.TODO(), kubeApiMeta.ListOptions{
+		LabelSelector: "app=" + cfg.Service,
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods found for %s/%s", ns, cfg.Service)
+	}
+	var errs error
+	for _, p := range pods.Items {
+		logs, err := cfg.Cluster.PodLogs(context.TODO(), p.Name, ns, "istio-proxy", false)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if !strings.Contains(logs, xdsAuthFailure) {
+			errs = multierror.Append(errs, fmt.Errorf("sidecar of %s/%s did not fail to authenticate", ns, p.Name))
+		}
+	}
+	return errs
+}
+
+// CheckXDSAuthenticationFailedOrFail calls CheckXDSAuthenticationFailed and fails the test if it returns an error.
+func CheckXDSAuthenticationFailedOrFail(t test.Failer, cfg Config) {
+	t.Helper()
+	if err := CheckXDSAuthenticationFailed(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
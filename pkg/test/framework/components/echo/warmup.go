@@ -0,0 +1,270 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WarmupOptions configures SampleWarmup.
+type WarmupOptions struct {
+	// Call options for the requests in each sample. Count is the number of requests per sample, defaulting to 20.
+	// Target is required.
+	Call CallOptions
+
+	// AddEndpoint adds a new endpoint to the target, for example by scaling up its deployment. It must not return
+	// until the endpoint is ready. Required.
+	AddEndpoint func() error
+
+	// Samples is the number of samples taken after the endpoint is added. Defaults to 10.
+	Samples int
+
+	// Interval between the start of each sample. Defaults to 5s.
+	Interval time.Duration
+}
+
+// EndpointShareSample is the share of request attempts received by the new endpoint(s) at a point in time.
+type EndpointShareSample struct {
+	// Elapsed is the time since the new endpoint was added.
+	Elapsed time.Duration
+	// Share is the fraction of the request attempts in the sample that were sent to a new endpoint.
+	Share float64
+	// Endpoints is the total number of endpoints that request attempts were sent to in the sample.
+	Endpoints int
+	// Requests is the number of requests in the sample.
+	Requests int
+	// Attempts are the request attempts of the sample sent to each endpoint, keyed by address. Requests that were
+	// retried have an attempt for each endpoint they were sent to.
+	Attempts map[string]int
+	// New are the addresses of the new endpoints.
+	New map[string]bool
+}
+
+// Retries returns the number of request attempts in the sample that were retries of failed ones.
+func (s EndpointShareSample) Retries() int {
+	attempts := 0
+	for _, n := range s.Attempts {
+		attempts += n
+	}
+	return attempts - s.Requests
+}
+
+// WarmupSamples is the series of samples taken by SampleWarmup.
+type WarmupSamples []EndpointShareSample
+
+// SampleWarmup keeps the target loaded while a new endpoint is added, and samples the share of request attempts
+// sent to the new endpoint over time. The responses only identify the endpoint of the last attempt of each request,
+// so the attempts are counted from the upstream host stats of the sidecars of the caller instead, attributing the
+// attempts that were retried, such as those failing on an endpoint that is not yet warm, to the endpoint they were
+// sent to.
+func SampleWarmup(from Instance, opts WarmupOptions) (WarmupSamples, error) {
+	if opts.AddEndpoint == nil || opts.Call.Target == nil {
+		return nil, fmt.Errorf("add endpoint function and call target must be provided")
+	}
+	if opts.Call.Count <= 0 {
+		opts.Call.Count = 20
+	}
+	if opts.Samples <= 0 {
+		opts.Samples = 10
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	target := opts.Call.Target
+
+	// Record the existing endpoints, so that the new ones can be identified.
+	existing, err := endpointAddresses(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.AddEndpoint(); err != nil {
+		return nil, fmt.Errorf("failed adding endpoint: %v", err)
+	}
+	added := time.Now()
+	if err := target.Refresh(); err != nil {
+		return nil, fmt.Errorf("failed refreshing target after adding endpoint: %v", err)
+	}
+	all, err := endpointAddresses(target)
+	if err != nil {
+		return nil, err
+	}
+	fresh := map[string]bool{}
+	for addr := range all {
+		if !existing[addr] {
+			fresh[addr] = true
+		}
+	}
+	if len(fresh) == 0 {
+		return nil, fmt.Errorf("no new endpoint of %s found after adding one", target.Config().Service)
+	}
+
+	samples := make(WarmupSamples, 0, opts.Samples)
+	for i := 0; i < opts.Samples; i++ {
+		start := time.Now()
+		before, err := attempts(from, target, all)
+		if err != nil {
+			return samples, err
+		}
+		if _, err := from.Call(opts.Call); err != nil {
+			return samples, fmt.Errorf("failed calling target in sample %d: %v", i, err)
+		}
+		after, err := attempts(from, target, all)
+		if err != nil {
+			return samples, err
+		}
+		samples = append(samples, newEndpointShareSample(before, after, fresh, opts.Call.Count, start.Sub(added)))
+		if wait := opts.Interval - time.Since(start); wait > 0 && i < opts.Samples-1 {
+			time.Sleep(wait)
+		}
+	}
+	return samples, nil
+}
+
+func endpointAddresses(i Instance) (map[string]bool, error) {
+	workloads, err := i.Workloads()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting workloads of %s: %v", i.Config().Service, err)
+	}
+	out := map[string]bool{}
+	for _, w := range workloads {
+		out[w.Address()] = true
+	}
+	return out, nil
+}
+
+// attempts returns the total request attempts the sidecars of the instance sent to each of the addresses of the
+// target, from the rq_total stat of the upstream hosts of the clusters of the target.
+func attempts(from, target Instance, addresses map[string]bool) (map[string]int, error) {
+	workloads, err := from.Workloads()
+	if err != nil {
+		return nil, fmt.Errorf("failed getting workloads of %s: %v", from.Config().Service, err)
+	}
+	out := map[string]int{}
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			return nil, fmt.Errorf("workload %s of %s has no sidecar to count request attempts", w.Address(),
+				from.Config().Service)
+		}
+		clusters, err := w.Sidecar().Clusters()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range clusters.GetClusterStatuses() {
+			if !strings.HasSuffix(c.GetName(), "|"+target.Config().FQDN()) {
+				continue
+			}
+			for _, h := range c.GetHostStatuses() {
+				addr := h.GetAddress().GetSocketAddress().GetAddress()
+				if !addresses[addr] {
+					continue
+				}
+				for _, stat := range h.GetStats() {
+					if stat.GetName() == "rq_total" {
+						out[addr] += int(stat.GetValue())
+					}
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func newEndpointShareSample(before, after map[string]int, fresh map[string]bool, requests int,
+	elapsed time.Duration) EndpointShareSample {
+	sample := EndpointShareSample{
+		Elapsed:  elapsed,
+		Requests: requests,
+		Attempts: map[string]int{},
+		New:      fresh,
+	}
+	total, toFresh := 0, 0
+	for addr, n := range after {
+		if n -= before[addr]; n > 0 {
+			sample.Attempts[addr] = n
+			total += n
+			if fresh[addr] {
+				toFresh += n
+			}
+		}
+	}
+	sample.Endpoints = len(sample.Attempts)
+	if total > 0 {
+		sample.Share = float64(toFresh) / float64(total)
+	}
+	return sample
+}
+
+// CheckSlowStart verifies that the new endpoint's share of request attempts ramped up over the warmup window, rather
+// than receiving its full share immediately. Samples within the first half of the window must be below the steady
+// share (the fraction a single endpoint receives with round robin, 1/endpoints), and samples after the window must
+// reach at least the steady share less the tolerance. Every request must have been attempted at least once, so that
+// the attempts account for all the requests of the samples.
+func (s WarmupSamples) CheckSlowStart(window time.Duration, endpoints int, tolerance float64) error {
+	if len(s) == 0 {
+		return fmt.Errorf("no samples")
+	}
+	steady := 1 / float64(endpoints)
+	sawEarly, sawSteady := false, false
+	for _, sample := range s {
+		if sample.Retries() < 0 {
+			return fmt.Errorf("only %d attempts were counted for the %d requests after %v", sample.Requests+sample.Retries(),
+				sample.Requests, sample.Elapsed)
+		}
+		switch {
+		case sample.Elapsed < window/2:
+			sawEarly = true
+			if sample.Share >= steady {
+				return fmt.Errorf("new endpoint received its full share %.2f after %v, expected less than %.2f during warmup",
+					sample.Share, sample.Elapsed, steady)
+			}
+		case sample.Elapsed > window:
+			sawSteady = true
+			if sample.Share < steady-tolerance {
+				return fmt.Errorf("new endpoint received share %.2f after %v, expected at least %.2f once warm",
+					sample.Share, sample.Elapsed, steady-tolerance)
+			}
+		}
+	}
+	if !sawEarly || !sawSteady {
+		return fmt.Errorf("samples %v do not cover both the start and end of the %v warmup window", s, window)
+	}
+	return nil
+}
+
+// CheckRetriesToOtherEndpoints verifies that the requests that were retried in the samples had their retries sent to
+// an endpoint other than the new one, as a retry policy with a host predicate to skip previously attempted hosts
+// requires. Retried requests are attributed by the samples to the endpoints of all their attempts, so the
+// retries of a sample must be matched by attempts to endpoints other than the new ones.
+func (s WarmupSamples) CheckRetriesToOtherEndpoints() error {
+	for _, sample := range s {
+		retries := sample.Retries()
+		if retries <= 0 {
+			continue
+		}
+		other := 0
+		for addr, n := range sample.Attempts {
+			if !sample.New[addr] {
+				other += n
+			}
+		}
+		if other < retries {
+			return fmt.Errorf("%d requests were retried after %v, but only %d attempts went to endpoints other than "+
+				"the new ones: %v", retries, sample.Elapsed, other, sample.Attempts)
+		}
+	}
+	return nil
+}
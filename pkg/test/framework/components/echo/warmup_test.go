@@ -0,0 +1,225 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echo
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/istio/pkg/test/echo/client"
+)
+
+// scalingTarget is a target whose endpoints are added by the test.
+type scalingTarget struct {
+	FakeInstance
+}
+
+func (t *scalingTarget) Refresh() error {
+	return nil
+}
+
+// hostStatsSidecar reports the request attempts to each host of the cluster of the target.
+type hostStatsSidecar struct {
+	Sidecar
+	cluster string
+	rq      map[string]int
+}
+
+func hostStatus(addr string, rq int) *envoyAdmin.HostStatus {
+	return &envoyAdmin.HostStatus{
+		Address: &core.Address{Address: &core.Address_SocketAddress{SocketAddress: &core.SocketAddress{Address: addr}}},
+		Stats:   []*envoyAdmin.SimpleMetric{{Name: "rq_total", Value: uint64(rq)}},
+	}
+}
+
+func (s *hostStatsSidecar) Clusters() (*envoyAdmin.Clusters, error) {
+	target := &envoyAdmin.ClusterStatus{Name: s.cluster}
+	for addr, n := range s.rq {
+		target.HostStatuses = append(target.HostStatuses, hostStatus(addr, n))
+	}
+	// Attempts to the same address through the cluster of another service are not counted.
+	other := &envoyAdmin.ClusterStatus{
+		Name:         "outbound|80||c.ns.svc.cluster.local",
+		HostStatuses: []*envoyAdmin.HostStatus{hostStatus("10.0.0.1", 100)},
+	}
+	return &envoyAdmin.Clusters{ClusterStatuses: []*envoyAdmin.ClusterStatus{target, other}}, nil
+}
+
+// attemptsCaller adds the attempts of each of its calls, in order, to the host stats of its sidecar.
+type attemptsCaller struct {
+	Instance
+	sidecar  *hostStatsSidecar
+	attempts []map[string]int
+	calls    int
+}
+
+func (c *attemptsCaller) Config() Config {
+	return Config{Service: "a"}
+}
+
+func (c *attemptsCaller) Workloads() ([]Workload, error) {
+	return []Workload{fakeWorkload{address: "10.0.1.1", sidecar: c.sidecar}}, nil
+}
+
+func (c *attemptsCaller) Call(CallOptions) (client.ParsedResponses, error) {
+	for addr, n := range c.attempts[c.calls] {
+		c.sidecar.rq[addr] += n
+	}
+	c.calls++
+	return nil, nil
+}
+
+func TestSampleWarmup(t *testing.T) {
+	target := &scalingTarget{directTarget(false, "10.0.0.1")}
+	from := &attemptsCaller{
+		sidecar: &hostStatsSidecar{cluster: "outbound|80||b.ns.svc.cluster.local", rq: map[string]int{"10.0.0.1": 50}},
+		attempts: []map[string]int{
+			{"10.0.0.1": 9, "10.0.0.2": 1},
+			{"10.0.0.1": 5, "10.0.0.2": 5},
+			// A request failed on the new endpoint and was retried on the existing one.
+			{"10.0.0.1": 6, "10.0.0.2": 5},
+		},
+	}
+	addEndpoint := func() error {
+		target.WorkloadsValue = append(target.WorkloadsValue, fakeWorkload{address: "10.0.0.2"})
+		return nil
+	}
+	samples, err := SampleWarmup(from, WarmupOptions{
+		Call:        CallOptions{Target: target, Count: 10},
+		AddEndpoint: addEndpoint,
+		Samples:     3,
+		Interval:    time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(samples))
+	}
+	for i, want := range []struct {
+		share   float64
+		retries int
+	}{{0.1, 0}, {0.5, 0}, {5.0 / 11, 1}} {
+		s := samples[i]
+		if s.Share != want.share || s.Retries() != want.retries || s.Endpoints != 2 || s.Requests != 10 {
+			t.Fatalf("sample %d: got %+v with %d retries, want share %v and %d retries", i, s, s.Retries(),
+				want.share, want.retries)
+		}
+		if !reflect.DeepEqual(s.New, map[string]bool{"10.0.0.2": true}) {
+			t.Fatalf("sample %d: got new endpoints %v, want 10.0.0.2", i, s.New)
+		}
+		if i > 0 && s.Elapsed <= samples[i-1].Elapsed {
+			t.Fatalf("expected samples to be taken in order, got %v", samples)
+		}
+	}
+
+	if _, err := SampleWarmup(from, WarmupOptions{Call: CallOptions{Target: target}}); err == nil {
+		t.Fatal("expected an error without a function to add the endpoint")
+	}
+	_, err = SampleWarmup(from, WarmupOptions{
+		Call:        CallOptions{Target: target},
+		AddEndpoint: func() error { return nil },
+	})
+	if err == nil || !strings.Contains(err.Error(), "no new endpoint of b found") {
+		t.Fatalf("expected an error when no endpoint is added, got %v", err)
+	}
+	_, err = SampleWarmup(from, WarmupOptions{
+		Call:        CallOptions{Target: target},
+		AddEndpoint: func() error { return errors.New("forbidden") },
+	})
+	if err == nil || !strings.Contains(err.Error(), "failed adding endpoint: forbidden") {
+		t.Fatalf("expected the failure to add the endpoint to be reported, got %v", err)
+	}
+}
+
+// sample returns a sample of requests sent to an existing endpoint and a new one, without retries.
+func sample(elapsed time.Duration, existing, fresh int) EndpointShareSample {
+	return newEndpointShareSample(
+		map[string]int{},
+		map[string]int{"10.0.0.1": existing, "10.0.0.2": fresh},
+		map[string]bool{"10.0.0.2": true},
+		existing+fresh,
+		elapsed)
+}
+
+func TestCheckSlowStart(t *testing.T) {
+	missing := sample(time.Second, 1, 1)
+	missing.Requests = 10
+	cases := []struct {
+		name    string
+		samples WarmupSamples
+		errMsg  string
+	}{
+		{name: "ramped up", samples: WarmupSamples{sample(time.Second, 9, 1), sample(11*time.Second, 5, 5)}},
+		{name: "within tolerance", samples: WarmupSamples{sample(time.Second, 9, 1), sample(11*time.Second, 11, 9)}},
+		{
+			name:    "full share during warmup",
+			samples: WarmupSamples{sample(time.Second, 5, 5), sample(11*time.Second, 5, 5)},
+			errMsg:  "new endpoint received its full share 0.50 after 1s",
+		},
+		{
+			name:    "not warm after the window",
+			samples: WarmupSamples{sample(time.Second, 9, 1), sample(11*time.Second, 8, 2)},
+			errMsg:  "new endpoint received share 0.20 after 11s, expected at least 0.40",
+		},
+		{
+			name:    "end of the window not sampled",
+			samples: WarmupSamples{sample(time.Second, 9, 1), sample(7*time.Second, 7, 3)},
+			errMsg:  "do not cover both the start and end of the 10s warmup window",
+		},
+		{
+			name:    "attempts missing",
+			samples: WarmupSamples{missing, sample(11*time.Second, 5, 5)},
+			errMsg:  "only 2 attempts were counted for the 10 requests after 1s",
+		},
+		{name: "no samples", errMsg: "no samples"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.samples.CheckSlowStart(10*time.Second, 2, 0.1)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Fatalf("expected error %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestCheckRetriesToOtherEndpoints(t *testing.T) {
+	retried := sample(time.Second, 2, 8)
+	retried.Requests = 9
+	if err := (WarmupSamples{sample(0, 5, 5), retried}).CheckRetriesToOtherEndpoints(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both attempts of the retried request went to the new endpoint.
+	sameHost := sample(time.Second, 0, 10)
+	sameHost.Requests = 8
+	err := (WarmupSamples{sameHost}).CheckRetriesToOtherEndpoints()
+	if err == nil || !strings.Contains(err.Error(), "2 requests were retried after 1s, but only 0 attempts") {
+		t.Fatalf("expected the retries to the new endpoint to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,357 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package echojob runs the echo client as a Kubernetes Job or CronJob, which calls an echo instance and runs to
+// completion, to verify that run-to-completion workloads complete cleanly with a sidecar, and that istio-proxy exits
+// once the application is done.
+package echojob
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiBatch "k8s.io/api/batch/v1"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/image"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/test/util/tmpl"
+)
+
+const (
+	proxyContainer = "istio-proxy"
+	appContainer   = "app"
+	jobLabel       = "echo-job"
+)
+
+// Config of a Job.
+type Config struct {
+	// Name of the Job, or CronJob if Schedule is set.
+	Name      string
+	Namespace namespace.Instance
+	// Cluster to run the Job in. Defaults to the default cluster.
+	Cluster resource.Cluster
+	// Target is called by the Job, on the port named PortName.
+	Target   echo.Instance
+	PortName string
+	// Count of requests made to the target. Defaults to 1.
+	Count int
+	// Sidecar injects istio-proxy into the pods of the Job. If unset, the pods run without a proxy.
+	Sidecar bool
+	// QuitProxy makes the application ask istio-proxy to exit once it is done, through the /quitquitquit endpoint of
+	// the agent. Without it, the proxy keeps running, and the Job never completes.
+	QuitProxy bool
+	// Annotations of the pods of the Job.
+	Annotations map[string]string
+	// Schedule in cron format. If set, a CronJob is created rather than a Job.
+	Schedule string
+}
+
+func (c *Config) fillDefaults(ctx resource.Context) {
+	c.Cluster = ctx.Clusters().GetOrDefault(c.Cluster)
+	if c.Count == 0 {
+		c.Count = 1
+	}
+}
+
+// Instance of a Job or CronJob.
+type Instance struct {
+	cfg  Config
+	ctx  resource.Context
+	yaml string
+}
+
+const podTemplate = `
+    metadata:
+      labels:
+        {{ .Label }}: {{ .Name }}
+      annotations:
+        sidecar.istio.io/inject: "{{ .Sidecar }}"
+{{- range $name, $value := .Annotations }}
+        {{ $name }}: {{ printf "%q" $value }}
+{{- end }}
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: app
+        image: {{ .Hub }}/app:{{ .Tag }}
+        imagePullPolicy: {{ .PullPolicy }}
+        command:
+        - /bin/sh
+        - -c
+        - |
+{{- if .Sidecar }}
+          until curl -fsS http://127.0.0.1:15020/healthz/ready; do sleep 1; done
+{{- end }}
+          /usr/local/bin/client {{ .URL }} --count {{ .Count }}
+          rc=$?
+{{- if .QuitProxy }}
+          curl -fsS -XPOST http://127.0.0.1:15020/quitquitquit
+{{- end }}
+          exit $rc
+`
+
+// jobParams are the parameters of the templates of Jobs and CronJobs.
+type jobParams struct {
+	Name              string `tmpl:"required"`
+	Label             string `tmpl:"required"`
+	Sidecar           bool
+	QuitProxy         bool
+	Annotations       map[string]string
+	Hub               string `tmpl:"required"`
+	Tag               string `tmpl:"required"`
+	PullPolicy        string `tmpl:"required"`
+	URL               string `tmpl:"required"`
+	Count             int    `tmpl:"required"`
+	Schedule          string
+	CronJobAPIVersion string
+}
+
+var jobTemplate = tmpl.MustNewTyped(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Name }}
+spec:
+  backoffLimit: 0
+  template:` + podTemplate)
+
+var cronJobTemplate = tmpl.MustNewTyped(`apiVersion: {{ .CronJobAPIVersion }}
+kind: CronJob
+metadata:
+  name: {{ .Name }}
+spec:
+  schedule: "{{ .Schedule }}"
+  concurrencyPolicy: Forbid
+  jobTemplate:
+    metadata:
+      labels:
+        {{ .Label }}: {{ .Name }}
+    spec:
+      backoffLimit: 0
+      template:` + indent(podTemplate, "    "))
+
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" && !strings.HasPrefix(l, "{{") {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// New creates the Job, or the CronJob if a schedule is set.
+func New(ctx resource.Context, cfg Config) (*Instance, error) {
+	cfg.fillDefaults(ctx)
+	port := cfg.Target.Config().PortByName(cfg.PortName)
+	if port == nil {
+		return nil, fmt.Errorf("port %s not found in %s", cfg.PortName, cfg.Target.Config().Service)
+	}
+	settings, err := image.SettingsFromCommandLine()
+	if err != nil {
+		return nil, err
+	}
+	pullPolicy := settings.PullPolicy
+	if pullPolicy == "" {
+		pullPolicy = string(kubeApiCore.PullIfNotPresent)
+	}
+	cronJobAPIVersion := "batch/v1"
+	if cfg.Schedule != "" {
+		// CronJob graduated to batch/v1 in Kubernetes 1.21, and batch/v1beta1 was removed in 1.25.
+		ver, err := cfg.Cluster.GetKubernetesVersion()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Kubernetes version: %v", err)
+		}
+		minor, err := strconv.Atoi(strings.TrimSuffix(ver.Minor, "+"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Kubernetes minor version %q: %v", ver.Minor, err)
+		}
+		if ver.Major == "1" && minor < 21 {
+			cronJobAPIVersion = "batch/v1beta1"
+		}
+	}
+
+	t := jobTemplate
+	if cfg.Schedule != "" {
+		t = cronJobTemplate
+	}
+	yaml, err := t.Evaluate(jobParams{
+		Name:              cfg.Name,
+		Label:             jobLabel,
+		Sidecar:           cfg.Sidecar,
+		QuitProxy:         cfg.Sidecar && cfg.QuitProxy,
+		Annotations:       cfg.Annotations,
+		Hub:               settings.Hub,
+		Tag:               settings.Tag,
+		PullPolicy:        pullPolicy,
+		URL:               fmt.Sprintf("http://%s:%d/", cfg.Target.Config().FQDN(), port.ServicePort),
+		Count:             cfg.Count,
+		Schedule:          cfg.Schedule,
+		CronJobAPIVersion: cronJobAPIVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Config(cfg.Cluster).ApplyYAML(cfg.Namespace.Name(), yaml); err != nil {
+		return nil, fmt.Errorf("failed creating job %s: %v", cfg.Name, err)
+	}
+	return &Instance{cfg: cfg, ctx: ctx, yaml: yaml}, nil
+}
+
+// NewOrFail calls New and fails the test if it returns an error.
+func NewOrFail(t test.Failer, ctx resource.Context, cfg Config) *Instance {
+	t.Helper()
+	i, err := New(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// Delete the Job or CronJob.
+func (i *Instance) Delete() error {
+	return i.ctx.Config(i.cfg.Cluster).DeleteYAML(i.cfg.Namespace.Name(), i.yaml)
+}
+
+// Jobs returns the Jobs created for the instance, which are more than one for a CronJob that ran several times.
+func (i *Instance) Jobs() ([]kubeApiBatch.Job, error) {
+	if i.cfg.Schedule == "" {
+		j, err := i.cfg.Cluster.BatchV1().Jobs(i.cfg.Namespace.Name()).Get(context.TODO(), i.cfg.Name,
+			kubeApiMeta.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []kubeApiBatch.Job{*j}, nil
+	}
+	jobs, err := i.cfg.Cluster.BatchV1().Jobs(i.cfg.Namespace.Name()).List(context.TODO(), kubeApiMeta.ListOptions{
+		LabelSelector: jobLabel + "=" + i.cfg.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs.Items, nil
+}
+
+// WaitForCompletion waits until a Job of the instance has finished, and checks that it succeeded, and that the
+// containers of its pods exited cleanly.
+func (i *Instance) WaitForCompletion(opts ...retry.Option) error {
+	var finished *kubeApiBatch.Job
+	err := retry.UntilSuccess(func() error {
+		jobs, err := i.Jobs()
+		if err != nil {
+			return err
+		}
+		for j := range jobs {
+			if jobs[j].Status.Succeeded > 0 || jobs[j].Status.Failed > 0 {
+				finished = &jobs[j]
+				return nil
+			}
+		}
+		return fmt.Errorf("job %s has not finished: %s", i.cfg.Name, i.describeRunning())
+	}, append([]retry.Option{retry.Timeout(5 * time.Minute), retry.Delay(2 * time.Second)}, opts...)...)
+	if err != nil {
+		return err
+	}
+	scopes.Framework.Debugf("job %s/%s finished: %+v", finished.Namespace, finished.Name, finished.Status)
+	if err := i.checkContainers(finished.Name); err != nil {
+		return err
+	}
+	if finished.Status.Failed > 0 {
+		return fmt.Errorf("job %s/%s failed: %s", finished.Namespace, finished.Name, i.logs(finished.Name))
+	}
+	return nil
+}
+
+// WaitForCompletionOrFail calls WaitForCompletion and fails the test if it returns an error.
+func (i *Instance) WaitForCompletionOrFail(t test.Failer, opts ...retry.Option) {
+	t.Helper()
+	if err := i.WaitForCompletion(opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (i *Instance) pods(job string) ([]kubeApiCore.Pod, error) {
+	pods, err := i.cfg.Cluster.PodsForSelector(context.TODO(), i.cfg.Namespace.Name(), "job-name="+job)
+	if err != nil {
+		return nil, err
+	}
+	return pods.Items, nil
+}
+
+// checkContainers checks that every container of the pods of the job terminated, istio-proxy with a zero exit code.
+func (i *Instance) checkContainers(job string) error {
+	pods, err := i.pods(job)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, p := range pods {
+		for _, s := range p.Status.ContainerStatuses {
+			t := s.State.Terminated
+			if t == nil {
+				errs = multierror.Append(errs, fmt.Errorf("container %s of pod %s/%s did not exit", s.Name, p.Namespace, p.Name))
+				continue
+			}
+			if s.Name == proxyContainer && t.ExitCode != 0 {
+				errs = multierror.Append(errs, fmt.Errorf("%s of pod %s/%s exited with code %d (%s)",
+					proxyContainer, p.Namespace, p.Name, t.ExitCode, t.Reason))
+			}
+		}
+	}
+	return errs
+}
+
+// describeRunning reports the containers of the pods of the instance that are still running, such as istio-proxy
+// after the application exited.
+func (i *Instance) describeRunning() string {
+	pods, err := i.cfg.Cluster.PodsForSelector(context.TODO(), i.cfg.Namespace.Name(), jobLabel+"="+i.cfg.Name)
+	if err != nil {
+		return err.Error()
+	}
+	var running []string
+	for _, p := range pods.Items {
+		for _, s := range p.Status.ContainerStatuses {
+			if s.State.Terminated == nil {
+				running = append(running, fmt.Sprintf("%s/%s", p.Name, s.Name))
+			}
+		}
+	}
+	return "running containers: " + strings.Join(running, ", ")
+}
+
+func (i *Instance) logs(job string) string {
+	pods, err := i.pods(job)
+	if err != nil {
+		return err.Error()
+	}
+	var out []string
+	for _, p := range pods {
+		l, err := i.cfg.Cluster.PodLogs(context.TODO(), p.Name, p.Namespace, appContainer, false)
+		if err != nil {
+			l = err.Error()
+		}
+		out = append(out, fmt.Sprintf("pod %s:\n%s", p.Name, l))
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,189 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echojob
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	kubeApiBatch "k8s.io/api/batch/v1"
+	kubeApiBatchV1beta1 "k8s.io/api/batch/v1beta1"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func params(sidecar, quit bool) jobParams {
+	return jobParams{
+		Name:              "job",
+		Label:             jobLabel,
+		Sidecar:           sidecar,
+		QuitProxy:         quit,
+		Annotations:       map[string]string{"proxy.istio.io/config": "holdApplicationUntilProxyStarts: true"},
+		Hub:               "hub",
+		Tag:               "tag",
+		PullPolicy:        "Always",
+		URL:               "http://b.ns.svc.cluster.local:80/",
+		Count:             3,
+		Schedule:          "*/1 * * * *",
+		CronJobAPIVersion: "batch/v1beta1",
+	}
+}
+
+func TestJobTemplate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sidecar bool
+		quit    bool
+	}{
+		{"no sidecar", false, false},
+		{"sidecar", true, false},
+		{"quit proxy", true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := jobTemplate.Evaluate(params(tc.sidecar, tc.quit))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var job kubeApiBatch.Job
+			if err := yaml.UnmarshalStrict([]byte(out), &job); err != nil {
+				t.Fatalf("invalid Job: %v\n%s", err, out)
+			}
+			pod := job.Spec.Template
+			if got := pod.Labels[jobLabel]; got != "job" {
+				t.Errorf("got label %q, want job", got)
+			}
+			if got, want := pod.Annotations["sidecar.istio.io/inject"], map[bool]string{true: "true", false: "false"}[tc.sidecar]; got != want {
+				t.Errorf("got inject annotation %q, want %q", got, want)
+			}
+			if got := pod.Annotations["proxy.istio.io/config"]; got != "holdApplicationUntilProxyStarts: true" {
+				t.Errorf("got config annotation %q", got)
+			}
+			if pod.Spec.RestartPolicy != kubeApiCore.RestartPolicyNever {
+				t.Errorf("got restart policy %q, want Never", pod.Spec.RestartPolicy)
+			}
+			c := pod.Spec.Containers[0]
+			if c.Image != "hub/app:tag" {
+				t.Errorf("got image %q, want hub/app:tag", c.Image)
+			}
+			script := c.Command[2]
+			if !strings.Contains(script, "client http://b.ns.svc.cluster.local:80/ --count 3") {
+				t.Errorf("script does not call the target:\n%s", script)
+			}
+			if got := strings.Contains(script, "healthz/ready"); got != tc.sidecar {
+				t.Errorf("waits for the proxy: %v, want %v", got, tc.sidecar)
+			}
+			if got := strings.Contains(script, "quitquitquit"); got != tc.quit {
+				t.Errorf("quits the proxy: %v, want %v", got, tc.quit)
+			}
+		})
+	}
+}
+
+func TestCronJobTemplate(t *testing.T) {
+	out, err := cronJobTemplate.Evaluate(params(true, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj kubeApiBatchV1beta1.CronJob
+	if err := yaml.UnmarshalStrict([]byte(out), &cj); err != nil {
+		t.Fatalf("invalid CronJob: %v\n%s", err, out)
+	}
+	if cj.APIVersion != "batch/v1beta1" || cj.Kind != "CronJob" {
+		t.Errorf("got %s %s, want batch/v1beta1 CronJob", cj.APIVersion, cj.Kind)
+	}
+	if cj.Spec.Schedule != "*/1 * * * *" {
+		t.Errorf("got schedule %q", cj.Spec.Schedule)
+	}
+	if got := cj.Spec.JobTemplate.Labels[jobLabel]; got != "job" {
+		t.Errorf("got job label %q, want job", got)
+	}
+	pod := cj.Spec.JobTemplate.Spec.Template
+	if got := pod.Annotations["sidecar.istio.io/inject"]; got != "true" {
+		t.Errorf("got inject annotation %q, want true", got)
+	}
+	if len(pod.Spec.Containers) != 1 || !strings.Contains(pod.Spec.Containers[0].Command[2], "quitquitquit") {
+		t.Errorf("got containers %+v", pod.Spec.Containers)
+	}
+}
+
+func finishedJob(name string, succeeded, failed int32) *kubeApiBatch.Job {
+	return &kubeApiBatch.Job{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Namespace: "ns", Labels: map[string]string{jobLabel: "job"}},
+		Status:     kubeApiBatch.JobStatus{Succeeded: succeeded, Failed: failed},
+	}
+}
+
+func jobPod(job string, proxy *kubeApiCore.ContainerStateTerminated) *kubeApiCore.Pod {
+	return &kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:      job + "-pod",
+			Namespace: "ns",
+			Labels:    map[string]string{"job-name": job, jobLabel: "job"},
+		},
+		Status: kubeApiCore.PodStatus{ContainerStatuses: []kubeApiCore.ContainerStatus{
+			{Name: appContainer, State: kubeApiCore.ContainerState{Terminated: &kubeApiCore.ContainerStateTerminated{}}},
+			{Name: proxyContainer, State: kubeApiCore.ContainerState{Terminated: proxy}},
+		}},
+	}
+}
+
+func newInstance(schedule string, objs ...runtime.Object) *Instance {
+	return &Instance{cfg: Config{
+		Name:      "job",
+		Namespace: namespace.Fake{NameValue: "ns"},
+		Cluster:   resource.FakeCluster{ExtendedClient: kube.NewFakeClient(objs...).(kube.ExtendedClient), NameValue: "cluster-0"},
+		Schedule:  schedule,
+	}}
+}
+
+func TestWaitForCompletion(t *testing.T) {
+	exited := &kubeApiCore.ContainerStateTerminated{}
+	killed := &kubeApiCore.ContainerStateTerminated{ExitCode: 137, Reason: "Error"}
+	for _, tc := range []struct {
+		name     string
+		schedule string
+		objs     []runtime.Object
+		err      string
+	}{
+		{"succeeded", "", []runtime.Object{finishedJob("job", 1, 0), jobPod("job", exited)}, ""},
+		{"failed", "", []runtime.Object{finishedJob("job", 0, 1), jobPod("job", exited)}, "job ns/job failed"},
+		{"proxy killed", "", []runtime.Object{finishedJob("job", 1, 0), jobPod("job", killed)}, "exited with code 137"},
+		{"proxy running", "", []runtime.Object{finishedJob("job", 1, 0), jobPod("job", nil)}, "istio-proxy of pod ns/job-pod did not exit"},
+		{"not finished", "", []runtime.Object{finishedJob("job", 0, 0)}, "has not finished"},
+		{"not created", "", nil, "not found"},
+		{"cron job", "*/1 * * * *", []runtime.Object{finishedJob("job-1", 0, 0), finishedJob("job-2", 1, 0), jobPod("job-2", exited)}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newInstance(tc.schedule, tc.objs...).WaitForCompletion(retry.Timeout(100*time.Millisecond), retry.Delay(10*time.Millisecond))
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got error %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -16,20 +16,30 @@ package kube
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeRetry "k8s.io/client-go/util/retry"
+
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/test/framework/resource"
 )
 
 var _ resource.Cluster = Cluster{}
 
+// VirtualClusterLabel is set on the namespaces holding the workloads of a virtual cluster, to its name.
+const VirtualClusterLabel = "istio.io/test-virtual-cluster"
+
 // Cluster for a Kubernetes cluster. Provides access via a kube.Client.
 type Cluster struct {
 	kube.ExtendedClient
 	filename    string
 	networkName string
 	index       resource.ClusterIndex
+	k3s         bool
+	// virtual is set when the cluster is one of the logical clusters sharing a physical cluster.
+	virtual bool
 }
 
 func (c Cluster) String() string {
@@ -61,3 +71,60 @@ func (c Cluster) Name() string {
 func (c Cluster) Index() resource.ClusterIndex {
 	return c.index
 }
+
+// IsK3s returns true if the cluster is running k3s or k3d.
+func (c Cluster) IsK3s() bool {
+	return c.k3s
+}
+
+// IsVirtual returns true if the cluster is one of several logical clusters sharing a single physical cluster.
+func (c Cluster) IsVirtual() bool {
+	return c.virtual
+}
+
+// Revision of the control plane of the cluster. Virtual clusters other than the first run their own control plane as
+// a revision named after the cluster, so that they do not inject each other's workloads.
+func (c Cluster) Revision() string {
+	if !c.virtual || c.index == 0 {
+		return ""
+	}
+	return c.Name()
+}
+
+// SystemNamespace returns the namespace of the control plane of the cluster, given the one configured for Istio.
+// Virtual clusters other than the first each have their own.
+func (c Cluster) SystemNamespace(configured string) string {
+	if !c.virtual || c.index == 0 {
+		return configured
+	}
+	return configured + "-" + c.Name()
+}
+
+// ClaimNamespace marks the namespace as holding the workloads of the virtual cluster. Namespaces are shared by all the
+// virtual clusters of a physical cluster, so workloads of the same name deployed to the same namespace through two
+// virtual clusters would replace each other; an error is returned if the namespace was claimed by another virtual
+// cluster. Namespaces of clusters that are not virtual are not claimed.
+func (c Cluster) ClaimNamespace(ns string) error {
+	if !c.virtual {
+		return nil
+	}
+	return kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		n, err := c.CoreV1().Namespaces().Get(context.TODO(), ns, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if owner, ok := n.Labels[VirtualClusterLabel]; ok {
+			if owner != c.Name() {
+				return fmt.Errorf("namespace %s holds the workloads of virtual cluster %s, so it cannot be used by %s; "+
+					"deploy the workloads of each virtual cluster to its own namespace", ns, owner, c.Name())
+			}
+			return nil
+		}
+		if n.Labels == nil {
+			n.Labels = map[string]string{}
+		}
+		n.Labels[VirtualClusterLabel] = c.Name()
+		_, err = c.CoreV1().Namespaces().Update(context.TODO(), n, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+}
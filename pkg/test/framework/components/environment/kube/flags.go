@@ -40,9 +40,13 @@ var (
 	settingsFromCommandLine = &Settings{
 		KubeConfig:            kubeConfigsFromEnv,
 		LoadBalancerSupported: true,
+		QPS:                   DefaultQPS,
+		Burst:                 DefaultBurst,
 	}
 	// hold kubeconfigs from command line to split later
 	kubeConfigs string
+	// hold qps from command line, as there is no float32 flag
+	qps float64
 	// hold controlPlaneTopology from command line to parse later
 	controlPlaneTopology string
 	// hold networkTopology from command line to parse later
@@ -59,6 +63,7 @@ func NewSettingsFromCommandLine() (*Settings, error) {
 	}
 
 	s := settingsFromCommandLine.clone()
+	s.QPS = float32(qps)
 
 	var err error
 	s.KubeConfig, err = parseKubeConfigs(kubeConfigs, ",")
@@ -69,6 +74,32 @@ func NewSettingsFromCommandLine() (*Settings, error) {
 		s.KubeConfig = kubeConfigsFromEnv
 	}
 
+	if s.QPS <= 0 || s.Burst <= 0 {
+		return nil, fmt.Errorf("istio.test.kube.qps and istio.test.kube.burst must be positive")
+	}
+
+	if s.ServiceAccount != "" {
+		if parts := strings.Split(s.ServiceAccount, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("istio.test.kube.serviceAccount must be namespace/name, got %q", s.ServiceAccount)
+		}
+	}
+
+	if s.VClusters > 0 {
+		if len(s.KubeConfig) != 1 || s.VirtualClusters > 1 {
+			return nil, fmt.Errorf("vclusters require exactly one host kubeconfig, and no virtual clusters")
+		}
+		s.vclusterHost = s.KubeConfig[0]
+		// The kubeconfigs of the vclusters are written to the work directory of the environment once provisioned.
+		s.KubeConfig = make([]string, s.VClusters)
+	}
+
+	if s.VirtualClusters > 1 {
+		if err := s.virtualize(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
 	s.ControlPlaneTopology, err = newControlPlaneTopology(s.KubeConfig)
 	if err != nil {
 		return nil, err
@@ -87,6 +118,29 @@ func NewSettingsFromCommandLine() (*Settings, error) {
 	return s, nil
 }
 
+// virtualize presents the single configured cluster as VirtualClusters primary clusters, each on its own network.
+func (s *Settings) virtualize() error {
+	if len(s.KubeConfig) != 1 {
+		return fmt.Errorf("virtual clusters require exactly one kubeconfig, got %d", len(s.KubeConfig))
+	}
+	if controlPlaneTopology != "" || networkTopology != "" || configTopology != "" {
+		return fmt.Errorf("topologies cannot be set with virtual clusters, each of which is a primary on its own network")
+	}
+	kubeConfig := s.KubeConfig[0]
+	s.KubeConfig = nil
+	s.ControlPlaneTopology = make(clusterTopology)
+	s.ConfigTopology = make(clusterTopology)
+	s.networkTopology = make(map[resource.ClusterIndex]string)
+	for i := 0; i < s.VirtualClusters; i++ {
+		index := resource.ClusterIndex(i)
+		s.KubeConfig = append(s.KubeConfig, kubeConfig)
+		s.ControlPlaneTopology[index] = index
+		s.ConfigTopology[index] = index
+		s.networkTopology[index] = fmt.Sprintf("network-%d", i)
+	}
+	return nil
+}
+
 func getKubeConfigsFromEnvironmentOrDefault() []string {
 	// Normalize KUBECONFIG so that it is separated by the OS path list separator.
 	// The framework currently supports comma as a separator, but that violates the
@@ -304,4 +358,22 @@ func init() {
 		"", "Specifies the mapping for each cluster to the cluster hosting its config. The value is a "+
 			"comma-separated list of the form <clusterIndex>:<configClusterIndex>, where the indexes refer to the order in which "+
 			"a given cluster appears in the 'istio.test.kube.config' flag. If not specified, the default is every cluster maps to itself(e.g. 0:0,1:1,...).")
+	flag.BoolVar(&settingsFromCommandLine.Autopilot, "istio.test.kube.autopilot", settingsFromCommandLine.Autopilot,
+		"Indicates that the clusters are GKE Autopilot clusters. Istio is installed with the CNI plugin rather than "+
+			"privileged init containers, and tests that require node access are skipped.")
+	flag.IntVar(&settingsFromCommandLine.VirtualClusters, "istio.test.kube.virtualClusters", settingsFromCommandLine.VirtualClusters,
+		"If greater than one, the single cluster of the kubeconfig is split into this many logical clusters, each on its "+
+			"own network with its own control plane revision, system namespace and east-west gateway.")
+	flag.IntVar(&settingsFromCommandLine.VClusters, "istio.test.kube.vclusters", settingsFromCommandLine.VClusters,
+		"If set, this many vcluster virtual clusters are created in the single cluster of the kubeconfig and used as "+
+			"the clusters of the environment. Topologies index the virtual clusters. Requires the vcluster CLI.")
+	flag.Float64Var(&qps, "istio.test.kube.qps", float64(settingsFromCommandLine.QPS),
+		"Maximum queries per second of the framework to the API server of each cluster, shared by all its clients.")
+	flag.IntVar(&settingsFromCommandLine.Burst, "istio.test.kube.burst", settingsFromCommandLine.Burst,
+		"Maximum burst of queries of the framework to the API server of each cluster, above istio.test.kube.qps.")
+	flag.StringVar(&settingsFromCommandLine.ServiceAccount, "istio.test.kube.serviceAccount", settingsFromCommandLine.ServiceAccount,
+		"If set as namespace/name, the framework impersonates the service account, to run suites without cluster-admin. "+
+			"The RBAC of the service account can be generated with pkg/test/framework/tools/rbacgen.")
+	flag.StringVar(&settingsFromCommandLine.EKSRoleARN, "istio.test.kube.eks.roleArn", settingsFromCommandLine.EKSRoleARN,
+		"The IAM role to associate with echo service accounts using EKS IAM Roles for Service Accounts. Only valid on EKS clusters.")
 }
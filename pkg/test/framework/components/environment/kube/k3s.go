@@ -0,0 +1,92 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"strings"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pkg/test/scopes"
+)
+
+const (
+	// K3sCNIConfDir is the directory k3s reads CNI configuration from, rather than /etc/cni/net.d.
+	K3sCNIConfDir = "/var/lib/rancher/k3s/agent/etc/cni/net.d"
+	// K3sCNIBinDir is the directory k3s reads CNI plugin binaries from, rather than /opt/cni/bin.
+	K3sCNIBinDir = "/var/lib/rancher/k3s/data/current/bin"
+
+	// k3sVersionMarker is included in the kubelet version of k3s nodes (e.g. v1.19.3+k3s1).
+	k3sVersionMarker = "+k3s"
+)
+
+// isK3s returns true if the nodes of the cluster are running k3s (including k3d, which runs k3s in docker).
+func isK3s(c kubernetes.Interface) (bool, error) {
+	nodes, err := c.CoreV1().Nodes().List(context.TODO(), kubeApiMeta.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, n := range nodes.Items {
+		if strings.Contains(n.Status.NodeInfo.KubeletVersion, k3sVersionMarker) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasTraefikConflict returns true if the Traefik ingress bundled with k3s is exposed through a LoadBalancer
+// Service on port 80 or 443. The k3s service load balancer (Klipper) binds these ports on the node, which
+// prevents the Istio ingress gateway from ever obtaining an address.
+func hasTraefikConflict(c kubernetes.Interface) (bool, error) {
+	svc, err := c.CoreV1().Services("kube-system").Get(context.TODO(), "traefik", kubeApiMeta.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if svc.Spec.Type != kubeApiCore.ServiceTypeLoadBalancer {
+		return false, nil
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Port == 80 || p.Port == 443 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// applyK3sSettings adjusts the settings for quirks of a k3s cluster.
+func applyK3sSettings(s *Settings, c kubernetes.Interface, index int) {
+	scopes.Framework.Infof("Cluster %d is running k3s", index)
+	if !s.LoadBalancerSupported {
+		return
+	}
+	conflict, err := hasTraefikConflict(c)
+	if err != nil {
+		scopes.Framework.Warnf("failed checking for Traefik in k3s cluster %d: %v", index, err)
+		return
+	}
+	if conflict {
+		// Rather than removing the user's Traefik, address the ingress gateway through its NodePort.
+		scopes.Framework.Warnf("Traefik is bound to the load balancer ports of k3s cluster %d; falling back to "+
+			"NodePort for ingress. Create the cluster with --disable=traefik to use the load balancer.", index)
+		s.LoadBalancerSupported = false
+	}
+}
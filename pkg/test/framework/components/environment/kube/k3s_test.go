@@ -0,0 +1,107 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func node(kubeletVersion string) *kubeApiCore.Node {
+	return &kubeApiCore.Node{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: kubeletVersion},
+		Status:     kubeApiCore.NodeStatus{NodeInfo: kubeApiCore.NodeSystemInfo{KubeletVersion: kubeletVersion}},
+	}
+}
+
+func traefik(serviceType kubeApiCore.ServiceType, ports ...int32) *kubeApiCore.Service {
+	svc := &kubeApiCore.Service{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "traefik", Namespace: "kube-system"},
+		Spec:       kubeApiCore.ServiceSpec{Type: serviceType},
+	}
+	for _, p := range ports {
+		svc.Spec.Ports = append(svc.Spec.Ports, kubeApiCore.ServicePort{Port: p})
+	}
+	return svc
+}
+
+func TestIsK3s(t *testing.T) {
+	cases := []struct {
+		name  string
+		nodes []runtime.Object
+		want  bool
+	}{
+		{"k3s", []runtime.Object{node("v1.19.3+k3s1")}, true},
+		{"mixed", []runtime.Object{node("v1.19.3"), node("v1.19.3+k3s1")}, true},
+		{"kind", []runtime.Object{node("v1.19.1")}, false},
+		{"no nodes", nil, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := isK3s(fake.NewSimpleClientset(tc.nodes...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHasTraefikConflict(t *testing.T) {
+	cases := []struct {
+		name    string
+		objects []runtime.Object
+		want    bool
+	}{
+		{"no traefik", nil, false},
+		{"load balancer on 80", []runtime.Object{traefik(kubeApiCore.ServiceTypeLoadBalancer, 80)}, true},
+		{"load balancer on 443", []runtime.Object{traefik(kubeApiCore.ServiceTypeLoadBalancer, 8080, 443)}, true},
+		{"load balancer on other ports", []runtime.Object{traefik(kubeApiCore.ServiceTypeLoadBalancer, 8080)}, false},
+		{"cluster IP", []runtime.Object{traefik(kubeApiCore.ServiceTypeClusterIP, 80, 443)}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := hasTraefikConflict(fake.NewSimpleClientset(tc.objects...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyK3sSettings(t *testing.T) {
+	conflict := fake.NewSimpleClientset(traefik(kubeApiCore.ServiceTypeLoadBalancer, 80, 443))
+
+	s := &Settings{LoadBalancerSupported: true}
+	applyK3sSettings(s, conflict, 0)
+	if s.LoadBalancerSupported {
+		t.Fatal("expected ingress to fall back to NodePort when Traefik holds the load balancer ports")
+	}
+
+	s = &Settings{LoadBalancerSupported: true}
+	applyK3sSettings(s, fake.NewSimpleClientset(), 0)
+	if !s.LoadBalancerSupported {
+		t.Fatal("expected the load balancer to be kept without Traefik")
+	}
+}
@@ -42,6 +42,12 @@ func New(ctx resource.Context, s *Settings) (resource.Environment, error) {
 	}
 	e.id = ctx.TrackResource(e)
 
+	if s.vclusterHost != "" {
+		if err := provisionVClusters(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+
 	clients, err := s.NewClients()
 	if err != nil {
 		return nil, err
@@ -51,17 +57,61 @@ func New(ctx resource.Context, s *Settings) (resource.Environment, error) {
 	for i := range clients {
 		client := clients[i]
 		clusterIndex := resource.ClusterIndex(i)
+		k3s, err := isK3s(client)
+		if err != nil {
+			scopes.Framework.Warnf("failed detecting k3s for cluster %d: %v", i, err)
+		}
 		e.KubeClusters = append(e.KubeClusters, Cluster{
 			networkName:    s.networkTopology[clusterIndex],
 			filename:       s.KubeConfig[i],
 			index:          clusterIndex,
+			k3s:            k3s,
+			virtual:        s.VirtualClusters > 1,
 			ExtendedClient: client,
 		})
+		if k3s {
+			applyK3sSettings(s, client, i)
+		}
 	}
 
 	return e, nil
 }
 
+// EnvironmentFrom returns the Kubernetes environment the tests of the context run in, or an error if they run in
+// another environment selected with --istio.test.env.
+func EnvironmentFrom(ctx resource.Context) (*Environment, error) {
+	e, ok := ctx.Environment().(*Environment)
+	if !ok {
+		return nil, fmt.Errorf("requires the %s environment, but tests run in the %s environment",
+			resource.DefaultEnvironment, ctx.Environment().EnvironmentName())
+	}
+	return e, nil
+}
+
+// Close deletes the vclusters provisioned for the environment, if any.
+func (e *Environment) Close() error {
+	if e.s.vclusterHost == "" || e.ctx.Settings().NoCleanup {
+		return nil
+	}
+	return deleteVClusters(e.s.vclusterHost, len(e.s.KubeConfig))
+}
+
+var (
+	_ resource.LoadBalancerEnvironment = &Environment{}
+	_ resource.NodeAccessEnvironment   = &Environment{}
+)
+
+// LoadBalancerSupported returns whether the clusters provision services of type LoadBalancer, which k3s clusters are
+// detected not to on creation.
+func (e *Environment) LoadBalancerSupported() bool {
+	return e.s.LoadBalancerSupported
+}
+
+// NodeAccessSupported returns whether tests may access the nodes, which Autopilot clusters do not allow.
+func (e *Environment) NodeAccessSupported() bool {
+	return !e.s.Autopilot
+}
+
 func (e *Environment) EnvironmentName() string {
 	return "Kube"
 }
@@ -0,0 +1,44 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"flag"
+	"testing"
+
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func TestAutopilot(t *testing.T) {
+	f := flag.Lookup("istio.test.kube.autopilot")
+	if f == nil {
+		t.Fatal("expected the autopilot flag to be registered")
+	}
+	prev := settingsFromCommandLine.Autopilot
+	defer func() { settingsFromCommandLine.Autopilot = prev }()
+	if err := f.Value.Set("true"); err != nil {
+		t.Fatal(err)
+	}
+	if !settingsFromCommandLine.Autopilot {
+		t.Fatal("expected the flag to enable Autopilot")
+	}
+
+	if resource.NodeAccessSupported(&Environment{s: &Settings{Autopilot: true}}) {
+		t.Fatal("expected node access to be unavailable on Autopilot clusters")
+	}
+	if !resource.NodeAccessSupported(&Environment{s: &Settings{}}) {
+		t.Fatal("expected node access to be available on other clusters")
+	}
+}
@@ -17,10 +17,14 @@ package kube
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
 
 	istioKube "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/apiaudit"
 	"istio.io/istio/pkg/test/framework/resource"
 	"istio.io/istio/pkg/test/scopes"
 )
@@ -61,6 +65,46 @@ type Settings struct {
 	// If the cluster runs its own config, the cluster will map to itself (e.g. 0->0)
 	// By default, we use the ControlPlaneTopology as the config topology.
 	ConfigTopology clusterTopology
+
+	// Autopilot indicates that the clusters are GKE Autopilot clusters. Autopilot does not allow privileged
+	// containers or access to nodes, and mutates the resource requests of pods.
+	Autopilot bool
+
+	// EKSRoleARN is the IAM role to associate with test workloads through EKS IAM Roles for Service Accounts (IRSA).
+	// When set, echo service accounts are annotated with the role, and the Istio agent's STS server is enabled so
+	// that the projected token exchange can be tested.
+	EKSRoleARN string
+
+	// VirtualClusters, if greater than one, splits the single configured cluster into this many logical clusters,
+	// each on its own network with its own control plane and east-west gateway, so that multi-network topologies can
+	// be tested without multiple physical clusters. The control planes of virtual clusters other than the first are
+	// revisions in their own system namespaces (see Cluster.Revision and Cluster.SystemNamespace). Namespaces are
+	// shared by all virtual clusters, so the workloads of a virtual cluster are deployed to namespaces injected by its
+	// revision, rather than to the same namespace in every cluster. Echo deployments into a namespace holding the
+	// workloads of another virtual cluster are rejected (see Cluster.ClaimNamespace). Echo workloads are labeled with
+	// the network of their virtual cluster, and each control plane reaches the others through remote secrets, so that
+	// traffic between virtual clusters crosses their east-west gateways.
+	VirtualClusters int
+
+	// VClusters, if set, is the number of vcluster virtual clusters created in the single configured cluster, and
+	// used as the clusters of the environment. The vcluster CLI must be on the path, and the host cluster must
+	// support LoadBalancer services, through which the virtual clusters are reached.
+	VClusters int
+
+	// vclusterHost is the kubeconfig of the cluster hosting the vclusters.
+	vclusterHost string
+
+	// QPS and Burst limit the requests of the framework to the API server of each cluster. All the clients of a
+	// cluster with the same ServiceAccount, including those of the virtual clusters it hosts, share the limit.
+	QPS   float32
+	Burst int
+
+	// ServiceAccount, if set as namespace/name, is impersonated by the clients of the framework, so that suites run
+	// with the permissions of the service account rather than those of the kubeconfig, such as those generated from
+	// the API calls of the suites by the rbacgen tool. The user of the kubeconfig must be allowed to impersonate it.
+	// Commands run by the framework, such as istioctl, are not affected: they run with the kubeconfig, and their calls
+	// are not audited.
+	ServiceAccount string
 }
 
 type SetupSettingsFunc func(s *Settings, ctx resource.Context)
@@ -93,7 +137,7 @@ func (s *Settings) GetControlPlaneClusters() map[resource.ClusterIndex]bool {
 func (s *Settings) NewClients() ([]istioKube.ExtendedClient, error) {
 	newClientsFn := s.ClientFactoryFunc
 	if newClientsFn == nil {
-		newClientsFn = newClients
+		newClientsFn = s.newClients
 	}
 
 	clients, err := newClientsFn(s.KubeConfig)
@@ -115,26 +159,81 @@ func (s *Settings) String() string {
 	result += fmt.Sprintf("ControlPlaneTopology: %v\n", s.ControlPlaneTopology)
 	result += fmt.Sprintf("NetworkTopology:      %v\n", s.networkTopology)
 	result += fmt.Sprintf("ConfigTopology:      %v\n", s.ConfigTopology)
+	result += fmt.Sprintf("Autopilot:            %v\n", s.Autopilot)
+	result += fmt.Sprintf("EKSRoleARN:           %s\n", s.EKSRoleARN)
+	result += fmt.Sprintf("VirtualClusters:      %d\n", s.VirtualClusters)
+	result += fmt.Sprintf("VClusters:            %d\n", s.VClusters)
+	result += fmt.Sprintf("QPS:                  %v\n", s.QPS)
+	result += fmt.Sprintf("Burst:                %d\n", s.Burst)
+	result += fmt.Sprintf("ServiceAccount:       %s\n", s.ServiceAccount)
 	return result
 }
 
-func newClients(kubeConfigs []string) ([]istioKube.ExtendedClient, error) {
+const (
+	// DefaultQPS and DefaultBurst are the limits of the requests to each cluster if the settings set none.
+	DefaultQPS   = 200
+	DefaultBurst = 400
+)
+
+// clientKey identifies the clients that are shared: those of a kubeconfig, created by settings configuring them alike.
+// Recording calls is enabled for the process as a whole, so it is not part of the key.
+type clientKey struct {
+	kubeConfig     string
+	serviceAccount string
+	qps            float32
+	burst          int
+}
+
+var (
+	sharedClientsMu sync.Mutex
+	// sharedClients are the clients created for each key, shared by all the clusters of the kubeconfig. Each has a rate
+	// limiter of its own.
+	sharedClients = map[clientKey]istioKube.ExtendedClient{}
+)
+
+// restConfigHook records the calls of the clients of the framework, and makes them as the service account of the
+// settings, if any.
+func (s *Settings) restConfigHook(config *rest.Config) {
+	config.Wrap(apiaudit.Transport(config.Host))
+	if s.ServiceAccount != "" {
+		config.Impersonate.UserName = "system:serviceaccount:" + strings.Replace(s.ServiceAccount, "/", ":", 1)
+	}
+}
+
+// newClients returns the clients of the kubeconfigs, creating those not created yet. All the clientsets of a client
+// share a rate limiter, so that the framework stays within the QPS and burst of the settings for each identity.
+func (s *Settings) newClients(kubeConfigs []string) ([]istioKube.ExtendedClient, error) {
+	qps, burst := s.QPS, s.Burst
+	if qps <= 0 || burst <= 0 {
+		qps, burst = DefaultQPS, DefaultBurst
+	}
+	sharedClientsMu.Lock()
+	defer sharedClientsMu.Unlock()
 	out := make([]istioKube.ExtendedClient, 0, len(kubeConfigs))
 	for _, cfg := range kubeConfigs {
-		if len(cfg) > 0 {
-			rc, err := istioKube.DefaultRestConfig(cfg, "", func(config *rest.Config) {
-				config.QPS = 200
-				config.Burst = 400
-			})
-			if err != nil {
-				return nil, err
-			}
-			a, err := istioKube.NewExtendedClient(istioKube.NewClientConfigForRestConfig(rc), "")
-			if err != nil {
-				return nil, fmt.Errorf("client setup: %v", err)
-			}
+		if len(cfg) == 0 {
+			continue
+		}
+		key := clientKey{kubeConfig: cfg, serviceAccount: s.ServiceAccount, qps: qps, burst: burst}
+		if a, ok := sharedClients[key]; ok {
 			out = append(out, a)
+			continue
+		}
+		rc, err := istioKube.DefaultRestConfig(cfg, "", func(config *rest.Config) {
+			config.QPS = qps
+			config.Burst = burst
+			config.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+			s.restConfigHook(config)
+		})
+		if err != nil {
+			return nil, err
+		}
+		a, err := istioKube.NewExtendedClient(istioKube.NewClientConfigForRestConfig(rc), "")
+		if err != nil {
+			return nil, fmt.Errorf("client setup: %v", err)
 		}
+		sharedClients[key] = a
+		out = append(out, a)
 	}
 	return out, nil
 }
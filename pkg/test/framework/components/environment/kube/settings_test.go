@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeConfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: test
+  user:
+    token: token
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`
+
+func TestSharedClients(t *testing.T) {
+	kubeConfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := ioutil.WriteFile(kubeConfig, []byte(testKubeConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := func(s *Settings) interface{} {
+		t.Helper()
+		clients, err := s.newClients([]string{kubeConfig})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return clients[0]
+	}
+
+	a := client(&Settings{ServiceAccount: "ns/a"})
+	if client(&Settings{ServiceAccount: "ns/a"}) != a {
+		t.Fatal("expected settings impersonating the same service account to share a client")
+	}
+	b := client(&Settings{ServiceAccount: "ns/b"})
+	if b == a {
+		t.Fatal("expected settings impersonating another service account to get a client of their own")
+	}
+	if client(&Settings{}) == a {
+		t.Fatal("expected settings without impersonation to get a client of their own")
+	}
+	if client(&Settings{ServiceAccount: "ns/a", QPS: 10, Burst: 20}) == a {
+		t.Fatal("expected settings with other limits to get a client of their own")
+	}
+
+	// Each client makes its calls as the service account of the settings it was created for, with a limiter of its own.
+	clients, err := (&Settings{ServiceAccount: "ns/a"}).newClients([]string{kubeConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := (&Settings{ServiceAccount: "ns/b"}).newClients([]string{kubeConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, cb := clients[0].RESTConfig(), other[0].RESTConfig()
+	if ca.Impersonate.UserName != "system:serviceaccount:ns:a" || cb.Impersonate.UserName != "system:serviceaccount:ns:b" {
+		t.Fatalf("got impersonated users %q and %q, want the service accounts a and b", ca.Impersonate.UserName, cb.Impersonate.UserName)
+	}
+	if ca.RateLimiter == cb.RateLimiter {
+		t.Fatal("expected the clients to have rate limiters of their own")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// vclusterBinary is the vcluster CLI, which must be on the path.
+	vclusterBinary = "vcluster"
+	// vclusterConfigKey is the key of the kubeconfig in the secret vcluster creates in the host namespace.
+	vclusterConfigKey = "config"
+	vclusterTimeout   = 5 * time.Minute
+)
+
+// vclusterName is the name of the virtual cluster, and of the host namespace it runs in.
+func vclusterName(index int) string {
+	return fmt.Sprintf("istio-test-vcluster-%d", index)
+}
+
+// provisionVClusters creates the virtual clusters in the host cluster, and writes their kubeconfigs to a temporary
+// directory of the context, setting them as the kubeconfigs of the settings. Each virtual cluster is exposed through a
+// LoadBalancer service of the host cluster. If one cannot be created, those created so far are deleted, unless
+// cleanup is disabled.
+func provisionVClusters(ctx resource.Context, s *Settings) error {
+	host, err := s.newClients([]string{s.vclusterHost})
+	if err != nil {
+		return fmt.Errorf("failed creating client for vcluster host: %v", err)
+	}
+	dir, err := ctx.CreateTmpDirectory("vclusters")
+	if err != nil {
+		return err
+	}
+	for i := range s.KubeConfig {
+		if err := provisionVCluster(host[0], s, dir, i); err != nil {
+			if ctx.Settings().NoCleanup {
+				return err
+			}
+			// The failed virtual cluster may have been partly created, so it is deleted as well.
+			if derr := deleteVClusters(s.vclusterHost, i+1); derr != nil {
+				return multierror.Append(err, derr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// provisionVCluster creates the virtual cluster of the index, and sets the kubeconfig written for it in the directory
+// as the kubeconfig of the settings at the index.
+func provisionVCluster(host kubernetes.Interface, s *Settings, dir string, index int) error {
+	name := vclusterName(index)
+	kubeConfig := filepath.Join(dir, name+".kubeconfig")
+	scopes.Framework.Infof("creating vcluster %s in host cluster %s", name, s.vclusterHost)
+	if out, err := vclusterCommand(s.vclusterHost, "create", name, "-n", name, "--expose").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed creating vcluster %s: %v: %s", name, err, out)
+	}
+	config, err := vclusterKubeConfig(host, name)
+	if err != nil {
+		return fmt.Errorf("failed getting kubeconfig of vcluster %s: %v", name, err)
+	}
+	if err := ioutil.WriteFile(kubeConfig, config, 0600); err != nil {
+		return err
+	}
+	s.KubeConfig[index] = kubeConfig
+	return nil
+}
+
+func vclusterCommand(hostKubeConfig string, args ...string) *exec.Cmd {
+	cmd := exec.Command(vclusterBinary, args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+hostKubeConfig)
+	return cmd
+}
+
+// vclusterKubeConfig returns the kubeconfig of the virtual cluster, pointed at the address of its LoadBalancer
+// service rather than at the local port forward vcluster connects through by default.
+func vclusterKubeConfig(host kubernetes.Interface, name string) ([]byte, error) {
+	var address string
+	var raw []byte
+	err := retry.UntilSuccess(func() error {
+		svc, err := host.CoreV1().Services(name).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		ingress := svc.Status.LoadBalancer.Ingress
+		if len(ingress) == 0 {
+			return fmt.Errorf("service %s/%s has no load balancer address", name, name)
+		}
+		address = ingress[0].IP
+		if address == "" {
+			address = ingress[0].Hostname
+		}
+		secret, err := host.CoreV1().Secrets(name).Get(context.TODO(), "vc-"+name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		raw = secret.Data[vclusterConfigKey]
+		if len(raw) == 0 {
+			return fmt.Errorf("secret %s/vc-%s has no kubeconfig", name, name)
+		}
+		return nil
+	}, retry.Timeout(vclusterTimeout), retry.Delay(2*time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := clientcmd.Load(raw)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range config.Clusters {
+		c.Server = "https://" + address
+	}
+	return clientcmd.Write(*config)
+}
+
+// deleteVClusters deletes the first n virtual clusters of the host cluster, along with their host namespaces.
+func deleteVClusters(hostKubeConfig string, n int) error {
+	var errs error
+	for i := 0; i < n; i++ {
+		name := vclusterName(i)
+		if out, err := vclusterCommand(hostKubeConfig, "delete", name, "-n", name).CombinedOutput(); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed deleting vcluster %s: %v: %s", name, err, out))
+		}
+	}
+	return errs
+}
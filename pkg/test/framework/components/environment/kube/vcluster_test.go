@@ -0,0 +1,77 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestVClusterKubeConfig(t *testing.T) {
+	name := vclusterName(1)
+	if name != "istio-test-vcluster-1" {
+		t.Fatalf("got vcluster name %s", name)
+	}
+	secret := &kubeApiCore.Secret{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "vc-" + name, Namespace: name},
+		Data:       map[string][]byte{vclusterConfigKey: []byte(testKubeConfig)},
+	}
+	cases := []struct {
+		name    string
+		ingress kubeApiCore.LoadBalancerIngress
+		server  string
+	}{
+		{"ip", kubeApiCore.LoadBalancerIngress{IP: "10.0.0.1"}, "https://10.0.0.1"},
+		{"hostname", kubeApiCore.LoadBalancerIngress{Hostname: "vcluster.example.com"}, "https://vcluster.example.com"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &kubeApiCore.Service{
+				ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Namespace: name},
+				Status: kubeApiCore.ServiceStatus{LoadBalancer: kubeApiCore.LoadBalancerStatus{
+					Ingress: []kubeApiCore.LoadBalancerIngress{tc.ingress},
+				}},
+			}
+			raw, err := vclusterKubeConfig(fake.NewSimpleClientset(svc, secret), name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config, err := clientcmd.Load(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.CurrentContext != "test" || config.AuthInfos["test"].Token != "token" {
+				t.Fatalf("expected the credentials of the vcluster to be kept, got %+v", config)
+			}
+			if got := config.Clusters["test"].Server; got != tc.server {
+				t.Fatalf("got server %s, want %s", got, tc.server)
+			}
+		})
+	}
+}
+
+func TestVClusterCommand(t *testing.T) {
+	cmd := vclusterCommand("/host/kubeconfig", "delete", "vc", "-n", "vc")
+	if cmd.Args[0] != vclusterBinary || len(cmd.Args) != 5 || cmd.Args[1] != "delete" {
+		t.Fatalf("got args %v", cmd.Args)
+	}
+	if got := cmd.Env[len(cmd.Env)-1]; got != "KUBECONFIG=/host/kubeconfig" {
+		t.Fatalf("expected the command to run against the host cluster, got %s", got)
+	}
+}
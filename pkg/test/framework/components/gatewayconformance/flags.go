@@ -0,0 +1,39 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewayconformance
+
+import (
+	"flag"
+)
+
+var (
+	dirFromCommandline               string
+	profilesFromCommandline          string
+	supportedFeaturesFromCommandline string
+	exemptFeaturesFromCommandline    string
+)
+
+// init registers the command-line flags that we can exposed for "go test".
+func init() {
+	flag.StringVar(&dirFromCommandline, "istio.test.gatewayConformance.dir", "",
+		"Path to a checkout of the Gateway API repository whose conformance tests are run. The conformance tests are "+
+			"skipped if not set.")
+	flag.StringVar(&profilesFromCommandline, "istio.test.gatewayConformance.profiles", "",
+		"Comma separated list of conformance profiles to run (e.g. 'GATEWAY-HTTP,MESH-HTTP').")
+	flag.StringVar(&supportedFeaturesFromCommandline, "istio.test.gatewayConformance.supportedFeatures", "",
+		"Comma separated list of extended features supported by Istio, in addition to the core features.")
+	flag.StringVar(&exemptFeaturesFromCommandline, "istio.test.gatewayConformance.exemptFeatures", "",
+		"Comma separated list of features exempted from the conformance run.")
+}
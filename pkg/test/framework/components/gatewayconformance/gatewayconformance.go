@@ -0,0 +1,229 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gatewayconformance runs the upstream Gateway API conformance tests against the Istio installed by the
+// framework. The tests are run from a checkout of the Gateway API repository, which must match the Gateway API
+// version supported by the installed Istio.
+//
+// The runs take the flags of the conformance suite of Gateway API v1.0.0 and later, which introduced conformance
+// profiles and reports. Earlier APIs, such as the networking.x-k8s.io/v1alpha1 service APIs handled by the Istio of this
+// tree, have no such suite, so Run refuses to run against them and Supported reports why.
+package gatewayconformance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	goversion "github.com/hashicorp/go-version"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+const (
+	// DefaultGatewayClass is the GatewayClass created for the run, handled by the Istio gateway controller.
+	DefaultGatewayClass = "istio"
+
+	gatewayController = "istio.io/gateway-controller"
+	reportFile        = "conformance-report.yaml"
+	outputFile        = "conformance-output.txt"
+
+	gatewayClassCRD     = "gatewayclasses.gateway.networking.k8s.io"
+	bundleVersion       = "gateway.networking.k8s.io/bundle-version"
+	minConformance      = "v1.0.0"
+	gatewayClassVersion = "gateway.networking.k8s.io/v1"
+)
+
+// Config for a conformance run. Unset fields default to the values of the istio.test.gatewayConformance flags.
+type Config struct {
+	// Dir of a checkout of the Gateway API repository.
+	Dir string
+	// Cluster to run the tests against. Defaults to the default cluster.
+	Cluster resource.Cluster
+	// GatewayClass used by the conformance tests. Defaults to DefaultGatewayClass.
+	GatewayClass string
+	// Profiles of the conformance run, such as GATEWAY-HTTP.
+	Profiles []string
+	// SupportedFeatures declares the extended features supported by Istio.
+	SupportedFeatures []string
+	// ExemptFeatures are excluded from the run.
+	ExemptFeatures []string
+	// Version of Istio recorded in the report.
+	Version string
+}
+
+// Enabled returns whether a checkout of the conformance tests was provided on the command line.
+func Enabled() bool {
+	return dirFromCommandline != ""
+}
+
+func (c *Config) fillDefaults(ctx resource.Context) error {
+	if c.Dir == "" {
+		c.Dir = dirFromCommandline
+	}
+	if c.Dir == "" {
+		return fmt.Errorf("no Gateway API checkout provided, set --istio.test.gatewayConformance.dir")
+	}
+	c.Cluster = ctx.Clusters().GetOrDefault(c.Cluster)
+	if c.GatewayClass == "" {
+		c.GatewayClass = DefaultGatewayClass
+	}
+	if c.Profiles == nil {
+		c.Profiles = splitList(profilesFromCommandline)
+	}
+	if c.SupportedFeatures == nil {
+		c.SupportedFeatures = splitList(supportedFeaturesFromCommandline)
+	}
+	if c.ExemptFeatures == nil {
+		c.ExemptFeatures = splitList(exemptFeaturesFromCommandline)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// args returns the arguments of the conformance test binary, writing the report to the given path.
+func (c Config) args(report string) []string {
+	args := []string{
+		"--gateway-class=" + c.GatewayClass,
+		"--report-output=" + report,
+		"--organization=istio",
+		"--project=istio",
+		"--url=https://github.com/istio/istio",
+		"--contact=@istio/maintainers",
+		"--cleanup-base-resources=true",
+	}
+	if c.Version != "" {
+		args = append(args, "--version="+c.Version)
+	}
+	if len(c.Profiles) > 0 {
+		args = append(args, "--conformance-profiles="+strings.Join(c.Profiles, ","))
+	}
+	if len(c.SupportedFeatures) > 0 {
+		args = append(args, "--supported-features="+strings.Join(c.SupportedFeatures, ","))
+	}
+	if len(c.ExemptFeatures) > 0 {
+		args = append(args, "--exempt-features="+strings.Join(c.ExemptFeatures, ","))
+	}
+	return args
+}
+
+const gatewayClass = `
+apiVersion: %s
+kind: GatewayClass
+metadata:
+  name: %s
+spec:
+  controllerName: %s
+`
+
+// Supported returns whether the Gateway API installed in the cluster has a conformance suite taking the flags of the
+// runs, or the reason it does not.
+func Supported(c resource.Cluster) (bool, string, error) {
+	crd, err := c.Ext().ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), gatewayClassCRD,
+		kubeApiMeta.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return false, fmt.Sprintf("%s is not installed in cluster %s; the service APIs of networking.x-k8s.io/v1alpha1 "+
+			"have no conformance suite", gatewayClassCRD, c.Name()), nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("failed getting %s of cluster %s: %v", gatewayClassCRD, c.Name(), err)
+	}
+	installed := crd.Annotations[bundleVersion]
+	v, err := goversion.NewVersion(installed)
+	if err != nil {
+		return false, fmt.Sprintf("Gateway API version %q of cluster %s is unknown", installed, c.Name()), nil
+	}
+	if v.LessThan(goversion.Must(goversion.NewVersion(minConformance))) {
+		return false, fmt.Sprintf("Gateway API %s of cluster %s predates the conformance suite of %s", installed,
+			c.Name(), minConformance), nil
+	}
+	return true, "", nil
+}
+
+// Run creates the GatewayClass and runs the conformance tests, returning the path of the conformance report. The
+// output of the tests is written next to the report.
+func Run(ctx resource.Context, cfg Config) (string, error) {
+	if err := cfg.fillDefaults(ctx); err != nil {
+		return "", err
+	}
+	kubeconfig, ok := cfg.Cluster.(interface{ Filename() string })
+	if !ok {
+		return "", fmt.Errorf("cluster %s has no kubeconfig", cfg.Cluster.Name())
+	}
+	supported, reason, err := Supported(cfg.Cluster)
+	if err != nil {
+		return "", err
+	}
+	if !supported {
+		return "", fmt.Errorf("cannot run the Gateway API conformance tests: %s", reason)
+	}
+
+	class := fmt.Sprintf(gatewayClass, gatewayClassVersion, cfg.GatewayClass, gatewayController)
+	if err := ctx.Config(cfg.Cluster).ApplyYAML("", class); err != nil {
+		return "", err
+	}
+	defer func() { _ = ctx.Config(cfg.Cluster).DeleteYAML("", class) }()
+
+	dir, err := ctx.CreateTmpDirectory("gateway-conformance")
+	if err != nil {
+		return "", err
+	}
+	report := filepath.Join(dir, reportFile)
+	output, err := os.Create(filepath.Join(dir, outputFile))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = output.Close() }()
+
+	args := append([]string{"test", "./conformance", "-run", "TestConformance", "-count=1", "-timeout=60m", "-v",
+		"-args"}, cfg.args(report)...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+kubeconfig.Filename())
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	scopes.Framework.Infof("Running Gateway API conformance tests from %s: go %s", cfg.Dir, strings.Join(args, " "))
+	if err := cmd.Run(); err != nil {
+		return report, fmt.Errorf("gateway API conformance tests failed, see %s and %s: %v", output.Name(), report, err)
+	}
+	scopes.Framework.Infof("Gateway API conformance report written to %s", report)
+	return report, nil
+}
+
+// RunOrFail calls Run and fails the test if it returns an error.
+func RunOrFail(t test.Failer, ctx resource.Context, cfg Config) string {
+	t.Helper()
+	report, err := Run(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
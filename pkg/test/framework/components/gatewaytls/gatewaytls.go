@@ -0,0 +1,434 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gatewaytls verifies the ingress gateway TLS server modes against a matrix of SNIs and client certificates,
+// each with an expected outcome.
+package gatewaytls
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/istio/ingress"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Mode is the TLS mode of a gateway server.
+type Mode string
+
+const (
+	// Simple terminates TLS at the gateway.
+	Simple Mode = "SIMPLE"
+	// Mutual terminates TLS at the gateway, requiring a client certificate signed by the configured CA.
+	Mutual Mode = "MUTUAL"
+	// Passthrough forwards TLS to the backend, routed by SNI.
+	Passthrough Mode = "PASSTHROUGH"
+	// AutoPassthrough forwards TLS to the mesh service encoded in the SNI. The backend sidecar requires Istio mTLS, so
+	// the handshakes of clients outside the mesh are always rejected by it.
+	AutoPassthrough Mode = "AUTO_PASSTHROUGH"
+)
+
+// SNI sent by the client.
+type SNI int
+
+const (
+	// MatchingSNI matches the hosts of the gateway server.
+	MatchingSNI SNI = iota
+	// MismatchedSNI does not match any gateway server.
+	MismatchedSNI
+)
+
+func (s SNI) String() string {
+	if s == MatchingSNI {
+		return "matching-sni"
+	}
+	return "mismatched-sni"
+}
+
+// ClientCert presented by the client.
+type ClientCert int
+
+const (
+	// NoClientCert presents no client certificate.
+	NoClientCert ClientCert = iota
+	// TrustedClientCert presents a client certificate signed by the CA of the gateway credential.
+	TrustedClientCert
+	// UntrustedClientCert presents a client certificate signed by another CA.
+	UntrustedClientCert
+)
+
+func (c ClientCert) String() string {
+	switch c {
+	case TrustedClientCert:
+		return "trusted-cert"
+	case UntrustedClientCert:
+		return "untrusted-cert"
+	}
+	return "no-cert"
+}
+
+// Outcome is the result of a request.
+type Outcome int
+
+const (
+	// Success requests reach the backend.
+	Success Outcome = iota
+	// Rejected requests fail the TLS handshake, or are closed or reset during it, such as by the gateway when no server
+	// matches the SNI. Any other failure, such as a response with an error status, is not an outcome of the matrix and
+	// fails the case.
+	Rejected
+)
+
+// rejections are the errors of requests whose TLS handshake failed or was cut short.
+var rejections = []string{
+	// Alerts sent by the peer, such as "remote error: tls: certificate required", and local handshake failures.
+	"tls: ",
+	// The connection being closed or reset during the handshake.
+	"EOF",
+	"connection reset by peer",
+}
+
+// outcome returns the outcome of a request, or an error if it is neither a success nor a rejection.
+func outcome(code int, err error) (Outcome, error) {
+	if err != nil {
+		for _, r := range rejections {
+			if strings.Contains(err.Error(), r) {
+				return Rejected, nil
+			}
+		}
+		return Rejected, fmt.Errorf("request failed other than by a TLS rejection: %v", err)
+	}
+	if code != 200 {
+		return Rejected, fmt.Errorf("request was answered with status %d rather than rejected", code)
+	}
+	return Success, nil
+}
+
+func (o Outcome) String() string {
+	if o == Success {
+		return "success"
+	}
+	return "rejected"
+}
+
+// Case is a single combination of the matrix.
+type Case struct {
+	Mode       Mode
+	SNI        SNI
+	ClientCert ClientCert
+}
+
+// Name of the case, suitable for a sub-test.
+func (c Case) Name() string {
+	return fmt.Sprintf("%s/%s/%s", strings.ToLower(string(c.Mode)), c.SNI, c.ClientCert)
+}
+
+// Expect returns the outcome of the case. Servers only match their hosts, so a mismatched SNI is always rejected.
+func (c Case) Expect() Outcome {
+	if c.SNI == MismatchedSNI {
+		return Rejected
+	}
+	switch c.Mode {
+	case Mutual:
+		if c.ClientCert != TrustedClientCert {
+			return Rejected
+		}
+	case AutoPassthrough:
+		return Rejected
+	}
+	return Success
+}
+
+// Matrix returns every combination of the modes with each SNI and client certificate. With no modes, all modes are
+// included.
+func Matrix(modes ...Mode) []Case {
+	if len(modes) == 0 {
+		modes = []Mode{Simple, Mutual, Passthrough, AutoPassthrough}
+	}
+	var out []Case
+	for _, m := range modes {
+		for _, sni := range []SNI{MatchingSNI, MismatchedSNI} {
+			for _, cert := range []ClientCert{NoClientCert, TrustedClientCert, UntrustedClientCert} {
+				out = append(out, Case{Mode: m, SNI: sni, ClientCert: cert})
+			}
+		}
+	}
+	return out
+}
+
+// Credentials are inline PEM encoded certificates and keys.
+type Credentials struct {
+	// CaCert signs the server and trusted client certificates.
+	CaCert     string
+	ServerCert string
+	ServerKey  string
+	ClientCert string
+	ClientKey  string
+	// UntrustedClientCert and UntrustedClientKey are signed by a different CA.
+	UntrustedClientCert string
+	UntrustedClientKey  string
+}
+
+// Config for Run.
+type Config struct {
+	// Ingress to send the requests through. Required.
+	Ingress ingress.Instance
+	// IngressNamespace is the namespace of the ingress gateway, in which the credential is created. Required.
+	IngressNamespace string
+	// Namespace of the backends, in which the routing config is applied. Required.
+	Namespace string
+
+	// Backend is the service that SIMPLE and MUTUAL servers route to, on port 80. Required.
+	Backend string
+	// PassthroughBackend is the service that PASSTHROUGH and AUTO_PASSTHROUGH servers route to. It must terminate TLS
+	// on PassthroughPort. Defaults to Backend.
+	PassthroughBackend string
+	// PassthroughPort is the service port of PassthroughBackend. Defaults to 443.
+	PassthroughPort int
+	// PassthroughCaCert verifies the certificate of PassthroughBackend. If unset, the certificate is not verified.
+	PassthroughCaCert string
+
+	// Credentials of the gateway and clients. Required.
+	Credentials Credentials
+
+	// Cases to verify. Defaults to the full Matrix.
+	Cases []Case
+}
+
+const credentialName = "gatewaytls"
+
+func (c *Config) fillDefaults() error {
+	if c.Ingress == nil || c.IngressNamespace == "" || c.Namespace == "" || c.Backend == "" {
+		return fmt.Errorf("ingress, ingress namespace, namespace and backend must be provided")
+	}
+	if c.PassthroughBackend == "" {
+		c.PassthroughBackend = c.Backend
+	}
+	if c.PassthroughPort == 0 {
+		c.PassthroughPort = 443
+	}
+	if len(c.Cases) == 0 {
+		c.Cases = Matrix()
+	}
+	return nil
+}
+
+// Host returns the host of the gateway server of the mode, which is sent as the SNI of matching requests. The hosts
+// of the terminated servers are covered by a server certificate for *.example.com.
+func (c Config) Host(m Mode) string {
+	if m == AutoPassthrough {
+		return fmt.Sprintf("outbound_.%d_._.%s.%s.svc.cluster.local", c.PassthroughPort, c.PassthroughBackend, c.Namespace)
+	}
+	return fmt.Sprintf("gatewaytls-%s.example.com", strings.ToLower(strings.ReplaceAll(string(m), "_", "-")))
+}
+
+// YAML returns the Gateway and VirtualService config with a server for each mode. The servers share port 443 and
+// are distinguished by their hosts.
+func (c Config) YAML() string {
+	var servers, routes strings.Builder
+	for _, m := range []Mode{Simple, Mutual, Passthrough, AutoPassthrough} {
+		host := c.Host(m)
+		if m == AutoPassthrough {
+			// The filter chain of an AUTO_PASSTHROUGH server matches the service hosts rather than the SNI itself.
+			host = "*.local"
+		}
+		// Terminated servers route HTTP, passthrough servers route by SNI.
+		proto := "TLS"
+		if m == Simple || m == Mutual {
+			proto = "HTTPS"
+		}
+		fmt.Fprintf(&servers, `  - port:
+      number: 443
+      name: %s-%s
+      protocol: %s
+    hosts:
+    - "%s"
+    tls:
+      mode: %s
+`, strings.ToLower(proto), strings.ToLower(strings.ReplaceAll(string(m), "_", "-")), proto, host, m)
+		switch m {
+		case Simple, Mutual:
+			fmt.Fprintf(&servers, "      credentialName: %s\n", credentialName)
+			fmt.Fprintf(&routes, `---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: gatewaytls-%s
+spec:
+  hosts:
+  - "%s"
+  gateways:
+  - gatewaytls
+  http:
+  - route:
+    - destination:
+        host: %s
+        port:
+          number: 80
+`, strings.ToLower(string(m)), c.Host(m), c.Backend)
+		case Passthrough:
+			fmt.Fprintf(&routes, `---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: gatewaytls-passthrough
+spec:
+  hosts:
+  - "%s"
+  gateways:
+  - gatewaytls
+  tls:
+  - match:
+    - sniHosts:
+      - "%s"
+    route:
+    - destination:
+        host: %s
+        port:
+          number: %d
+`, c.Host(m), c.Host(m), c.PassthroughBackend, c.PassthroughPort)
+		}
+	}
+	return fmt.Sprintf(`apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: gatewaytls
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+%s%s`, servers.String(), routes.String())
+}
+
+// Run creates the gateway credential and config, and verifies each of the cases. The credential and config are
+// removed once all cases are verified. All failing cases are reported.
+func Run(ctx resource.Context, cfg Config, opts ...retry.Option) error {
+	if err := cfg.fillDefaults(); err != nil {
+		return err
+	}
+	cleanup, err := setup(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var errs error
+	for _, c := range cfg.Cases {
+		if err := Verify(cfg, c, opts...); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %v", c.Name(), err))
+		}
+	}
+	return errs
+}
+
+// RunOrFail calls Run and fails the test if it returns an error.
+func RunOrFail(t test.Failer, ctx resource.Context, cfg Config, opts ...retry.Option) {
+	t.Helper()
+	if err := Run(ctx, cfg, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setup(ctx resource.Context, cfg Config) (func(), error) {
+	cluster := ctx.Clusters().Default()
+	secret := &kubeApiCore.Secret{
+		ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:      credentialName,
+			Namespace: cfg.IngressNamespace,
+		},
+		Data: map[string][]byte{
+			"tls.crt": []byte(cfg.Credentials.ServerCert),
+			"tls.key": []byte(cfg.Credentials.ServerKey),
+			"ca.crt":  []byte(cfg.Credentials.CaCert),
+		},
+	}
+	secrets := cluster.CoreV1().Secrets(cfg.IngressNamespace)
+	if _, err := secrets.Create(context.TODO(), secret, kubeApiMeta.CreateOptions{}); err != nil {
+		if !errors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("failed creating gateway credential: %v", err)
+		}
+		if _, err := secrets.Update(context.TODO(), secret, kubeApiMeta.UpdateOptions{}); err != nil {
+			return nil, fmt.Errorf("failed updating gateway credential: %v", err)
+		}
+	}
+	yml := cfg.YAML()
+	if err := ctx.Config().ApplyYAML(cfg.Namespace, yml); err != nil {
+		_ = secrets.Delete(context.TODO(), credentialName, kubeApiMeta.DeleteOptions{})
+		return nil, fmt.Errorf("failed applying gateway config: %v", err)
+	}
+	return func() {
+		_ = ctx.Config().DeleteYAML(cfg.Namespace, yml)
+		_ = secrets.Delete(context.TODO(), credentialName, kubeApiMeta.DeleteOptions{})
+	}, nil
+}
+
+// Verify sends a request for the case, retrying until the expected outcome is observed. Requests that neither succeed
+// nor are rejected by TLS, such as those answered with a 503, never match the expected outcome. The gateway config
+// must already be applied, as done by Run.
+func Verify(cfg Config, c Case, opts ...retry.Option) error {
+	if err := cfg.fillDefaults(); err != nil {
+		return err
+	}
+	opts = append([]retry.Option{retry.Timeout(2 * time.Minute), retry.Delay(time.Second)}, opts...)
+	expected := c.Expect()
+	return retry.UntilSuccess(func() error {
+		// Close clients to ensure TLS connections are not reused across cases.
+		cfg.Ingress.CloseClients()
+		actual, err := call(cfg, c)
+		if err != nil {
+			return fmt.Errorf("expected %v: %v", expected, err)
+		}
+		if actual != expected {
+			return fmt.Errorf("expected %v, got %v", expected, actual)
+		}
+		return nil
+	}, opts...)
+}
+
+func call(cfg Config, c Case) (Outcome, error) {
+	host := cfg.Host(c.Mode)
+	if c.SNI == MismatchedSNI {
+		host = "gatewaytls-mismatched.example.com"
+	}
+	opts := ingress.CallOptions{
+		// The SNI is set to the Host of the request.
+		Host:     host,
+		Path:     "/",
+		CaCert:   cfg.Credentials.CaCert,
+		Address:  cfg.Ingress.HTTPSAddress(),
+		CallType: ingress.Mtls,
+	}
+	if c.Mode == Passthrough || c.Mode == AutoPassthrough {
+		opts.CaCert = cfg.PassthroughCaCert
+	}
+	switch c.ClientCert {
+	case TrustedClientCert:
+		opts.Cert, opts.PrivateKey = cfg.Credentials.ClientCert, cfg.Credentials.ClientKey
+	case UntrustedClientCert:
+		opts.Cert, opts.PrivateKey = cfg.Credentials.UntrustedClientCert, cfg.Credentials.UntrustedClientKey
+	default:
+		opts.CallType = ingress.TLS
+	}
+	resp, err := cfg.Ingress.Call(opts)
+	return outcome(resp.Code, err)
+}
@@ -0,0 +1,180 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewaytls
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework/components/istio/ingress"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// fakeIngress answers calls with the response of the case the options are for.
+type fakeIngress struct {
+	ingress.Instance
+	respond func(ingress.CallOptions) (ingress.CallResponse, error)
+	calls   []ingress.CallOptions
+}
+
+func (f *fakeIngress) Call(opts ingress.CallOptions) (ingress.CallResponse, error) {
+	f.calls = append(f.calls, opts)
+	return f.respond(opts)
+}
+
+func (f *fakeIngress) HTTPSAddress() net.TCPAddr {
+	return net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}
+}
+
+func (f *fakeIngress) CloseClients() {}
+
+func testConfig(ing ingress.Instance) Config {
+	return Config{
+		Ingress:          ing,
+		IngressNamespace: "istio-system",
+		Namespace:        "ns",
+		Backend:          "server",
+		Credentials: Credentials{
+			CaCert:              "ca",
+			ClientCert:          "client",
+			ClientKey:           "client-key",
+			UntrustedClientCert: "untrusted",
+			UntrustedClientKey:  "untrusted-key",
+		},
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	if got := len(Matrix()); got != 4*2*3 {
+		t.Fatalf("got %d cases, want one per mode, SNI and client certificate", got)
+	}
+	cases := Matrix(Mutual)
+	if len(cases) != 6 {
+		t.Fatalf("got %d cases, want 6 for a single mode", len(cases))
+	}
+	if got := cases[1].Name(); got != "mutual/matching-sni/trusted-cert" {
+		t.Fatalf("got name %s", got)
+	}
+}
+
+func TestExpect(t *testing.T) {
+	cases := []struct {
+		c    Case
+		want Outcome
+	}{
+		{Case{Simple, MatchingSNI, NoClientCert}, Success},
+		{Case{Simple, MatchingSNI, UntrustedClientCert}, Success},
+		{Case{Simple, MismatchedSNI, NoClientCert}, Rejected},
+		{Case{Mutual, MatchingSNI, TrustedClientCert}, Success},
+		{Case{Mutual, MatchingSNI, NoClientCert}, Rejected},
+		{Case{Mutual, MatchingSNI, UntrustedClientCert}, Rejected},
+		{Case{Mutual, MismatchedSNI, TrustedClientCert}, Rejected},
+		{Case{Passthrough, MatchingSNI, NoClientCert}, Success},
+		{Case{AutoPassthrough, MatchingSNI, TrustedClientCert}, Rejected},
+	}
+	for _, tc := range cases {
+		if got := tc.c.Expect(); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.c.Name(), got, tc.want)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	cases := []struct {
+		name    string
+		code    int
+		err     error
+		want    Outcome
+		wantErr bool
+	}{
+		{name: "ok", code: 200, want: Success},
+		{name: "alert", err: errors.New("remote error: tls: certificate required"), want: Rejected},
+		{name: "closed", err: errors.New("EOF"), want: Rejected},
+		{name: "reset", err: errors.New("read: connection reset by peer"), want: Rejected},
+		{name: "timeout", err: errors.New("i/o timeout"), want: Rejected, wantErr: true},
+		{name: "unavailable", code: 503, want: Rejected, wantErr: true},
+	}
+	for _, tc := range cases {
+		got, err := outcome(tc.code, tc.err)
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("%s: got (%v, %v), want %v with error %v", tc.name, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestYAML(t *testing.T) {
+	cfg := testConfig(nil)
+	if err := cfg.fillDefaults(); err != nil {
+		t.Fatal(err)
+	}
+	yml := cfg.YAML()
+	for _, want := range []string{
+		`- "gatewaytls-simple.example.com"`,
+		`- "gatewaytls-mutual.example.com"`,
+		"mode: MUTUAL\n      credentialName: gatewaytls",
+		"sniHosts:\n      - \"gatewaytls-passthrough.example.com\"",
+		"number: 443",
+		`- "*.local"`,
+	} {
+		if !strings.Contains(yml, want) {
+			t.Fatalf("expected %q in the config, got:\n%s", want, yml)
+		}
+	}
+	if got := strings.Count(yml, "kind: VirtualService"); got != 3 {
+		t.Fatalf("got %d VirtualServices, want one for each mode but AUTO_PASSTHROUGH", got)
+	}
+	if got := cfg.Host(AutoPassthrough); got != "outbound_.443_._.server.ns.svc.cluster.local" {
+		t.Fatalf("got AUTO_PASSTHROUGH host %s", got)
+	}
+	if err := (&Config{}).fillDefaults(); err == nil {
+		t.Fatal("expected an error for a config without an ingress")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// The gateway requires the trusted client certificate, and rejects unknown SNIs.
+	ing := &fakeIngress{respond: func(opts ingress.CallOptions) (ingress.CallResponse, error) {
+		if opts.Host == "gatewaytls-mismatched.example.com" || opts.Cert != "client" {
+			return ingress.CallResponse{}, errors.New("remote error: tls: bad certificate")
+		}
+		return ingress.CallResponse{Code: 200}, nil
+	}}
+	cfg := testConfig(ing)
+	opts := []retry.Option{retry.Timeout(100 * time.Millisecond), retry.Delay(10 * time.Millisecond)}
+	for _, c := range Matrix(Mutual) {
+		if err := Verify(cfg, c, opts...); err != nil {
+			t.Fatalf("%s: %v", c.Name(), err)
+		}
+	}
+	for _, call := range ing.calls {
+		if call.Address.Port != 443 || call.CaCert != "ca" {
+			t.Fatalf("unexpected call %+v", call)
+		}
+		if call.Cert == "" && call.CallType != ingress.TLS {
+			t.Fatalf("expected calls without a client certificate to use TLS, got %+v", call)
+		}
+	}
+
+	// A gateway that accepts every request fails the cases expected to be rejected.
+	ing.respond = func(ingress.CallOptions) (ingress.CallResponse, error) {
+		return ingress.CallResponse{Code: 200}, nil
+	}
+	if err := Verify(cfg, Case{Mutual, MatchingSNI, NoClientCert}, opts...); err == nil {
+		t.Fatal("expected the accepted request to fail the case")
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hpa
+
+import (
+	"flag"
+)
+
+var metricsServerFromCommandline string
+
+// init registers the command-line flags that we can exposed for "go test".
+func init() {
+	flag.StringVar(&metricsServerFromCommandline, "istio.test.hpa.metricsServer", "",
+		"Path to a metrics-server manifest, installed if the cluster does not serve the resource metrics API. On "+
+			"clusters with self-signed kubelet certificates, such as KinD, it must run with --kubelet-insecure-tls.")
+}
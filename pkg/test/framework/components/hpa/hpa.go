@@ -0,0 +1,409 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hpa configures HorizontalPodAutoscalers on echo workloads and gateways, drives load to them, and asserts
+// that they scale, and that the mesh keeps serving requests while they do.
+package hpa
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/test/util/tmpl"
+)
+
+const (
+	metricsGroupVersion = "metrics.k8s.io/v1beta1"
+	metricsNamespace    = "kube-system"
+)
+
+// MetricsAvailable returns whether the cluster serves the resource metrics API that HPAs scale on.
+func MetricsAvailable(c resource.Cluster) bool {
+	_, err := c.Discovery().ServerResourcesForGroupVersion(metricsGroupVersion)
+	return err == nil
+}
+
+// EnsureMetricsServer installs the metrics-server manifest given by --istio.test.hpa.metricsServer, unless the cluster
+// already serves the resource metrics API, and waits until pod metrics are served.
+func EnsureMetricsServer(ctx resource.Context, c resource.Cluster) error {
+	c = ctx.Clusters().GetOrDefault(c)
+	if MetricsAvailable(c) {
+		return nil
+	}
+	if metricsServerFromCommandline == "" {
+		return fmt.Errorf("cluster %s does not serve %s, and --istio.test.hpa.metricsServer is not set",
+			c.Name(), metricsGroupVersion)
+	}
+	manifest, err := ioutil.ReadFile(metricsServerFromCommandline)
+	if err != nil {
+		return fmt.Errorf("failed reading metrics-server manifest: %v", err)
+	}
+	if err := ctx.Config(c).ApplyYAML(metricsNamespace, string(manifest)); err != nil {
+		return fmt.Errorf("failed installing metrics-server: %v", err)
+	}
+	return retry.UntilSuccess(func() error {
+		if !MetricsAvailable(c) {
+			return fmt.Errorf("%s is not served yet", metricsGroupVersion)
+		}
+		// The API is registered before metrics-server has scraped the kubelets.
+		return c.REST().Get().AbsPath("/apis", metricsGroupVersion, "pods").Do(context.TODO()).Error()
+	}, retry.Timeout(5*time.Minute), retry.Delay(5*time.Second))
+}
+
+// EnsureMetricsServerOrFail calls EnsureMetricsServer and fails the test if it returns an error.
+func EnsureMetricsServerOrFail(t test.Failer, ctx resource.Context, c resource.Cluster) {
+	t.Helper()
+	if err := EnsureMetricsServer(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Target is a deployment scaled by an HPA.
+type Target struct {
+	Cluster    resource.Cluster
+	Namespace  string
+	Deployment string
+}
+
+// EchoTargets returns the deployments of the instance, one per subset.
+func EchoTargets(i echo.Instance) []Target {
+	var out []Target
+	for _, s := range i.Config().Subsets {
+		out = append(out, Target{
+			Cluster:    i.Config().Cluster,
+			Namespace:  i.Config().Namespace.Name(),
+			Deployment: fmt.Sprintf("%s-%s", i.Config().Service, s.Version),
+		})
+	}
+	return out
+}
+
+// GatewayTarget returns the deployment of a gateway, such as istio-ingressgateway in istio-system.
+func GatewayTarget(c resource.Cluster, ns, name string) Target {
+	return Target{Cluster: c, Namespace: ns, Deployment: name}
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s/%s in %s", t.Namespace, t.Deployment, t.Cluster.Name())
+}
+
+// Config of an HPA.
+type Config struct {
+	MinReplicas int32
+	MaxReplicas int32
+	// CPUAverageValue is the average CPU usage per pod the HPA scales to, such as "50m". An average value rather
+	// than a utilization is used, as echo containers do not request CPU. Defaults to 50m.
+	CPUAverageValue string
+}
+
+func (c *Config) fillDefaults() {
+	if c.MinReplicas == 0 {
+		c.MinReplicas = 1
+	}
+	if c.MaxReplicas == 0 {
+		c.MaxReplicas = c.MinReplicas + 2
+	}
+	if c.CPUAverageValue == "" {
+		c.CPUAverageValue = "50m"
+	}
+}
+
+// hpaParams are the parameters of hpaTemplate.
+type hpaParams struct {
+	APIVersion      string `tmpl:"required"`
+	Name            string `tmpl:"required"`
+	MinReplicas     int32  `tmpl:"required"`
+	MaxReplicas     int32  `tmpl:"required"`
+	CPUAverageValue string `tmpl:"required"`
+}
+
+var hpaTemplate = tmpl.MustNewTyped(`apiVersion: {{ .APIVersion }}
+kind: HorizontalPodAutoscaler
+metadata:
+  name: {{ .Name }}
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: {{ .Name }}
+  minReplicas: {{ .MinReplicas }}
+  maxReplicas: {{ .MaxReplicas }}
+  metrics:
+  - type: Resource
+    resource:
+      name: cpu
+      target:
+        type: AverageValue
+        averageValue: {{ .CPUAverageValue }}
+`)
+
+// apiVersion returns the newest HPA API with the metrics field served by the cluster. autoscaling/v2 is served from
+// Kubernetes 1.23, and autoscaling/v2beta2 was removed in 1.26.
+func apiVersion(c resource.Cluster) (string, error) {
+	ver, err := c.GetKubernetesVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to get Kubernetes version: %v", err)
+	}
+	minor, err := strconv.Atoi(strings.TrimSuffix(ver.Minor, "+"))
+	if err != nil {
+		return "", fmt.Errorf("invalid Kubernetes minor version %q: %v", ver.Minor, err)
+	}
+	if ver.Major == "1" && minor < 23 {
+		return "autoscaling/v2beta2", nil
+	}
+	return "autoscaling/v2", nil
+}
+
+// preserve returns a function that puts the HPA of the target back in its current state: the HPA the target has, such
+// as the one a gateway is installed with, is restored, and an HPA the target did not have is removed.
+func preserve(t Target, version string) (func() error, error) {
+	gv, err := schema.ParseGroupVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	hpas := t.Cluster.Dynamic().Resource(gv.WithResource("horizontalpodautoscalers")).Namespace(t.Namespace)
+	original, err := hpas.Get(context.TODO(), t.Deployment, kubeApiMeta.GetOptions{})
+	if kubeErrors.IsNotFound(err) {
+		return func() error {
+			err := hpas.Delete(context.TODO(), t.Deployment, kubeApiMeta.DeleteOptions{})
+			if err != nil && !kubeErrors.IsNotFound(err) {
+				return fmt.Errorf("failed removing HPA of %v: %v", t, err)
+			}
+			return nil
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading HPA of %v: %v", t, err)
+	}
+	return func() error {
+		restored := original.DeepCopy()
+		current, err := hpas.Get(context.TODO(), t.Deployment, kubeApiMeta.GetOptions{})
+		switch {
+		case kubeErrors.IsNotFound(err):
+			restored.SetResourceVersion("")
+			_, err = hpas.Create(context.TODO(), restored, kubeApiMeta.CreateOptions{})
+		case err == nil:
+			restored.SetResourceVersion(current.GetResourceVersion())
+			_, err = hpas.Update(context.TODO(), restored, kubeApiMeta.UpdateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("failed restoring HPA of %v: %v", t, err)
+		}
+		return nil
+	}, nil
+}
+
+// Configure an HPA, named after the deployment, on the target. An HPA the target already has is replaced. The
+// returned function restores it, or removes the configured HPA if the target had none.
+func Configure(ctx resource.Context, t Target, cfg Config) (func() error, error) {
+	cfg.fillDefaults()
+	version, err := apiVersion(t.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	yaml, err := hpaTemplate.Evaluate(hpaParams{
+		APIVersion:      version,
+		Name:            t.Deployment,
+		MinReplicas:     cfg.MinReplicas,
+		MaxReplicas:     cfg.MaxReplicas,
+		CPUAverageValue: cfg.CPUAverageValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	restore, err := preserve(t, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Config(t.Cluster).ApplyYAML(t.Namespace, yaml); err != nil {
+		return nil, fmt.Errorf("failed configuring HPA on %v: %v", t, err)
+	}
+	return restore, nil
+}
+
+// ConfigureOrFail calls Configure and fails the test if it returns an error. When the test completes, the HPA the
+// target had is restored, or the configured HPA is removed, leaving the deployment at its current scale.
+func ConfigureOrFail(tst test.Failer, ctx resource.Context, t Target, cfg Config) {
+	tst.Helper()
+	restore, err := Configure(ctx, t, cfg)
+	if err != nil {
+		tst.Fatal(err)
+	}
+	tst.Cleanup(func() {
+		if err := restore(); err != nil {
+			tst.Fatal(err)
+		}
+	})
+}
+
+// ReadyReplicas returns the number of ready replicas of the target.
+func ReadyReplicas(t Target) (int32, error) {
+	d, err := t.Cluster.AppsV1().Deployments(t.Namespace).Get(context.TODO(), t.Deployment, kubeApiMeta.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return d.Status.ReadyReplicas, nil
+}
+
+// ScalingEvents returns the messages of the rescale events of the HPA of the target.
+func ScalingEvents(t Target) ([]string, error) {
+	events, err := t.Cluster.CoreV1().Events(t.Namespace).List(context.TODO(), kubeApiMeta.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=HorizontalPodAutoscaler,involvedObject.name=%s", t.Deployment),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range events.Items {
+		if e.Reason == "SuccessfulRescale" {
+			out = append(out, e.Message)
+		}
+	}
+	return out, nil
+}
+
+// LoadOptions configures a CheckStableDuringScaling.
+type LoadOptions struct {
+	// Call made by each load generator.
+	Call echo.CallOptions
+	// Concurrency is the number of concurrent load generators. Defaults to 4.
+	Concurrency int
+	// Replicas the target must reach, as ready replicas.
+	Replicas int32
+	// Timeout after which the load stops if the target did not scale. Defaults to 5m.
+	Timeout time.Duration
+	// MaxFailureRate is the fraction of requests allowed to fail while the target scales. Defaults to none.
+	MaxFailureRate float64
+}
+
+func (o *LoadOptions) fillDefaults() {
+	if o.Concurrency == 0 {
+		o.Concurrency = 4
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Minute
+	}
+}
+
+// ScaleResult of a CheckStableDuringScaling.
+type ScaleResult struct {
+	Requests int
+	Failures []error
+	Duration time.Duration
+	// Events are the rescale events of the HPA.
+	Events []string
+}
+
+// add records a call. A call that returned a non-OK response, such as a 503 from a replica that is not ready yet,
+// failed as well.
+func (r *ScaleResult) add(resp client.ParsedResponses, err error) {
+	r.Requests++
+	if err == nil {
+		err = resp.CheckOK()
+	}
+	if err != nil {
+		r.Failures = append(r.Failures, err)
+	}
+}
+
+func (r ScaleResult) String() string {
+	return fmt.Sprintf("%d requests, %d failed, in %v; rescales: %s", r.Requests, len(r.Failures), r.Duration,
+		strings.Join(r.Events, "; "))
+}
+
+// CheckStableDuringScaling drives load from the source until the target has scaled to the expected replicas, and
+// checks that requests kept succeeding while new replicas joined the mesh.
+func CheckStableDuringScaling(src echo.Instance, t Target, opts LoadOptions) (ScaleResult, error) {
+	opts.fillDefaults()
+	var (
+		mu     sync.Mutex
+		result ScaleResult
+		wg     sync.WaitGroup
+	)
+	start := time.Now()
+	done := make(chan struct{})
+	for n := 0; n < opts.Concurrency; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				resp, err := src.Call(opts.Call)
+				mu.Lock()
+				result.add(resp, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	scaleErr := retry.UntilSuccess(func() error {
+		ready, err := ReadyReplicas(t)
+		if err != nil {
+			return err
+		}
+		if ready < opts.Replicas {
+			return fmt.Errorf("%v has %d ready replicas, expected %d", t, ready, opts.Replicas)
+		}
+		return nil
+	}, retry.Timeout(opts.Timeout), retry.Delay(5*time.Second))
+	close(done)
+	wg.Wait()
+	result.Duration = time.Since(start)
+	events, err := ScalingEvents(t)
+	if err != nil {
+		return result, err
+	}
+	result.Events = events
+	scopes.Framework.Infof("scaling %v: %v", t, result)
+
+	if scaleErr != nil {
+		return result, fmt.Errorf("target did not scale under load (%v): %v", result, scaleErr)
+	}
+	if result.Requests == 0 {
+		return result, fmt.Errorf("no requests were sent while %v scaled", t)
+	}
+	if rate := float64(len(result.Failures)) / float64(result.Requests); rate > opts.MaxFailureRate {
+		return result, fmt.Errorf("%.2f%% of requests failed while %v scaled, expected at most %.2f%%: %v",
+			rate*100, t, opts.MaxFailureRate*100, result.Failures[0])
+	}
+	return result, nil
+}
+
+// CheckStableDuringScalingOrFail calls CheckStableDuringScaling and fails the test if it returns an error.
+func CheckStableDuringScalingOrFail(tst test.Failer, src echo.Instance, t Target, opts LoadOptions) ScaleResult {
+	tst.Helper()
+	r, err := CheckStableDuringScaling(src, t, opts)
+	if err != nil {
+		tst.Fatal(err)
+	}
+	return r
+}
@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hpa
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+var hpaGVR = schema.GroupVersionResource{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"}
+
+// testClient is a fake client whose dynamic client holds the HPAs of the cluster.
+type testClient struct {
+	kube.ExtendedClient
+	dynamic dynamic.Interface
+}
+
+func (c testClient) Dynamic() dynamic.Interface {
+	return c.dynamic
+}
+
+func hpa(name string, maxReplicas int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v2",
+		"kind":       "HorizontalPodAutoscaler",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "istio-system",
+		},
+		"spec": map[string]interface{}{
+			"maxReplicas": maxReplicas,
+		},
+	}}
+}
+
+func testTarget(objects ...runtime.Object) (Target, dynamic.ResourceInterface) {
+	d := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+	c := resource.FakeCluster{
+		ExtendedClient: testClient{
+			ExtendedClient: kube.NewFakeClient().(kube.ExtendedClient),
+			dynamic:        d,
+		},
+		NameValue: "cluster-0",
+	}
+	return GatewayTarget(c, "istio-system", "istio-ingressgateway"), d.Resource(hpaGVR).Namespace("istio-system")
+}
+
+// configure stands in for applying the HPA of Configure.
+func configure(t *testing.T, hpas dynamic.ResourceInterface) {
+	t.Helper()
+	obj := hpa("istio-ingressgateway", 10)
+	current, err := hpas.Get(context.TODO(), obj.GetName(), kubeApiMeta.GetOptions{})
+	if err == nil {
+		obj.SetResourceVersion(current.GetResourceVersion())
+		_, err = hpas.Update(context.TODO(), obj, kubeApiMeta.UpdateOptions{})
+	} else {
+		_, err = hpas.Create(context.TODO(), obj, kubeApiMeta.CreateOptions{})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func maxReplicas(t *testing.T, hpas dynamic.ResourceInterface) int64 {
+	t.Helper()
+	obj, err := hpas.Get(context.TODO(), "istio-ingressgateway", kubeApiMeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	max, _, _ := unstructured.NestedInt64(obj.Object, "spec", "maxReplicas")
+	return max
+}
+
+func TestPreserveRestoresOriginal(t *testing.T) {
+	target, hpas := testTarget(hpa("istio-ingressgateway", 5))
+	restore, err := preserve(target, "autoscaling/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configure(t, hpas)
+	if got := maxReplicas(t, hpas); got != 10 {
+		t.Fatalf("expected the configured HPA, got maxReplicas %d", got)
+	}
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+	if got := maxReplicas(t, hpas); got != 5 {
+		t.Fatalf("expected the original HPA to be restored, got maxReplicas %d", got)
+	}
+}
+
+func TestPreserveRecreatesDeletedOriginal(t *testing.T) {
+	target, hpas := testTarget(hpa("istio-ingressgateway", 5))
+	restore, err := preserve(target, "autoscaling/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hpas.Delete(context.TODO(), "istio-ingressgateway", kubeApiMeta.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+	if got := maxReplicas(t, hpas); got != 5 {
+		t.Fatalf("expected the original HPA to be recreated, got maxReplicas %d", got)
+	}
+}
+
+func TestPreserveRemovesConfigured(t *testing.T) {
+	target, hpas := testTarget()
+	restore, err := preserve(target, "autoscaling/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configure(t, hpas)
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hpas.Get(context.TODO(), "istio-ingressgateway", kubeApiMeta.GetOptions{}); !kubeErrors.IsNotFound(err) {
+		t.Fatalf("expected the configured HPA to be removed, got %v", err)
+	}
+	// Restoring again is a no-op.
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScaleResultAdd(t *testing.T) {
+	var r ScaleResult
+	r.add(client.ParsedResponses{{Code: "200"}}, nil)
+	r.add(client.ParsedResponses{{Code: "503"}}, nil)
+	r.add(nil, errors.New("connection refused"))
+	if r.Requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", r.Requests)
+	}
+	if len(r.Failures) != 2 {
+		t.Fatalf("expected the 503 and the failed call to be failures, got %v", r.Failures)
+	}
+}
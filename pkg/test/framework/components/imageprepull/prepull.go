@@ -0,0 +1,236 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package imageprepull pulls the images used by tests onto every node of every cluster before the tests start, so
+// that image pulls neither slow down the first workloads of each test nor make them time out.
+package imageprepull
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test/framework/image"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/test/util/tmpl"
+)
+
+const (
+	defaultNamespace = "istio-image-prepull"
+	defaultTimeout   = 10 * time.Minute
+	appLabel         = "istio-image-prepull"
+)
+
+// Config of a Prepull.
+type Config struct {
+	// Images to pull. Defaults to the images of istiod, the proxy and the echo application.
+	Images []string
+	// Namespace in which the pulling DaemonSet runs. Once the images are pulled, it is deleted if the prepull created
+	// it, and only the DaemonSet is deleted otherwise.
+	Namespace string
+	// Timeout for pulling the images onto all nodes. Defaults to 10m.
+	Timeout time.Duration
+}
+
+// DefaultImages returns the images used by most tests, from the hub and tag of the command line.
+func DefaultImages() ([]string, error) {
+	s, err := image.SettingsFromCommandLine()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, name := range []string{"pilot", "proxyv2", "app"} {
+		out = append(out, fmt.Sprintf("%s/%s:%s", s.Hub, name, s.Tag))
+	}
+	return out, nil
+}
+
+// Every image is run as a container of the DaemonSet, so that they are pulled in parallel. The containers are not
+// expected to work: a container has pulled its image once it reports an image ID, even if it crashes afterwards.
+var daemonSetTemplate = tmpl.MustNewTyped(`
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: {{ .Name }}
+spec:
+  selector:
+    matchLabels:
+      app: {{ .Name }}
+  template:
+    metadata:
+      labels:
+        app: {{ .Name }}
+      annotations:
+        sidecar.istio.io/inject: "false"
+    spec:
+      tolerations:
+      - operator: Exists
+      terminationGracePeriodSeconds: 0
+      containers:
+{{- range $i, $image := .Images }}
+      - name: image-{{ $i }}
+        image: {{ $image }}
+        imagePullPolicy: {{ $.PullPolicy }}
+        resources:
+          requests:
+            cpu: 1m
+            memory: 1Mi
+{{- end }}
+`)
+
+// daemonSetParams are the parameters of daemonSetTemplate.
+type daemonSetParams struct {
+	Name       string   `tmpl:"required"`
+	Images     []string `tmpl:"required"`
+	PullPolicy string
+}
+
+// Prepull pulls the images onto every node of every cluster.
+func Prepull(ctx resource.Context, cfg Config) error {
+	if cfg.Namespace == "" {
+		cfg.Namespace = defaultNamespace
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if len(cfg.Images) == 0 {
+		images, err := DefaultImages()
+		if err != nil {
+			return err
+		}
+		cfg.Images = images
+	}
+	s, err := image.SettingsFromCommandLine()
+	if err != nil {
+		return err
+	}
+	ds, err := daemonSetTemplate.Evaluate(daemonSetParams{
+		Name:       appLabel,
+		Images:     cfg.Images,
+		PullPolicy: s.PullPolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	var mu sync.Mutex
+	var errs error
+	wg := sync.WaitGroup{}
+	for _, c := range ctx.Clusters() {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := prepullCluster(ctx, c, cfg, ds); err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, fmt.Errorf("failed pulling images in cluster %s: %v", c.Name(), err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if errs == nil {
+		scopes.Framework.Infof("pulled %d images onto all nodes in %v", len(cfg.Images), time.Since(start))
+	}
+	return errs
+}
+
+// Setup returns a suite setup function pulling the images of the config.
+func Setup(cfg Config) resource.SetupFn {
+	return func(ctx resource.Context) error {
+		return Prepull(ctx, cfg)
+	}
+}
+
+// ensureNamespace creates the namespace unless it exists, and returns a function removing what the prepull leaves in
+// it: the namespace if it was created, and only the DaemonSet otherwise, so that a namespace the prepull shares, such
+// as one given by the test, is kept.
+func ensureNamespace(c resource.Cluster, ns string) (func(), error) {
+	_, err := c.CoreV1().Namespaces().Create(context.TODO(), &kubeApiCore.Namespace{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: ns},
+	}, kubeApiMeta.CreateOptions{})
+	if err != nil && !kubeErrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	if err == nil {
+		return func() {
+			if err := c.CoreV1().Namespaces().Delete(context.TODO(), ns, kube.DeleteOptionsForeground()); err != nil {
+				scopes.Framework.Warnf("failed deleting namespace %s in cluster %s: %v", ns, c.Name(), err)
+			}
+		}, nil
+	}
+	return func() {
+		err := c.AppsV1().DaemonSets(ns).Delete(context.TODO(), appLabel, kube.DeleteOptionsForeground())
+		if err != nil && !kubeErrors.IsNotFound(err) {
+			scopes.Framework.Warnf("failed deleting DaemonSet %s/%s in cluster %s: %v", ns, appLabel, c.Name(), err)
+		}
+	}, nil
+}
+
+func prepullCluster(ctx resource.Context, c resource.Cluster, cfg Config, ds string) error {
+	cleanup, err := ensureNamespace(c, cfg.Namespace)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := ctx.Config(c).ApplyYAML(cfg.Namespace, ds); err != nil {
+		return err
+	}
+
+	return retry.UntilSuccess(func() error {
+		d, err := c.AppsV1().DaemonSets(cfg.Namespace).Get(context.TODO(), appLabel, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		pods, err := c.CoreV1().Pods(cfg.Namespace).List(context.TODO(), kubeApiMeta.ListOptions{
+			LabelSelector: "app=" + appLabel,
+		})
+		if err != nil {
+			return err
+		}
+		pulled := 0
+		for _, pod := range pods.Items {
+			if podPulled(pod, len(cfg.Images)) {
+				pulled++
+			}
+		}
+		if desired := int(d.Status.DesiredNumberScheduled); desired == 0 || pulled < desired {
+			return fmt.Errorf("images pulled onto %d of %d nodes", pulled, desired)
+		}
+		return nil
+	}, retry.Timeout(cfg.Timeout), retry.Delay(2*time.Second))
+}
+
+// podPulled returns true if every container of the pod has pulled its image.
+func podPulled(pod kubeApiCore.Pod, containers int) bool {
+	if len(pod.Status.ContainerStatuses) < containers {
+		return false
+	}
+	for _, s := range pod.Status.ContainerStatuses {
+		if s.ImageID == "" {
+			return false
+		}
+	}
+	return true
+}
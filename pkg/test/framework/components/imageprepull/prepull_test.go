@@ -0,0 +1,79 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imageprepull
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func testCluster(objects ...runtime.Object) resource.Cluster {
+	return resource.FakeCluster{
+		ExtendedClient: kube.NewFakeClient(objects...).(kube.ExtendedClient),
+		NameValue:      "cluster-0",
+	}
+}
+
+func daemonSet(ns string) *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{ObjectMeta: kubeApiMeta.ObjectMeta{Name: appLabel, Namespace: ns}}
+}
+
+func TestEnsureNamespaceDeletesCreatedNamespace(t *testing.T) {
+	c := testCluster()
+	cleanup, err := ensureNamespace(c, defaultNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CoreV1().Namespaces().Get(context.TODO(), defaultNamespace, kubeApiMeta.GetOptions{}); err != nil {
+		t.Fatalf("expected the namespace to be created: %v", err)
+	}
+	cleanup()
+	_, err = c.CoreV1().Namespaces().Get(context.TODO(), defaultNamespace, kubeApiMeta.GetOptions{})
+	if !kubeErrors.IsNotFound(err) {
+		t.Fatalf("expected the created namespace to be deleted, got %v", err)
+	}
+}
+
+func TestEnsureNamespaceKeepsExistingNamespace(t *testing.T) {
+	c := testCluster(
+		&kubeApiCore.Namespace{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "shared"}},
+		&kubeApiCore.Pod{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "other", Namespace: "shared"}},
+		daemonSet("shared"),
+	)
+	cleanup, err := ensureNamespace(c, "shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+	if _, err := c.CoreV1().Namespaces().Get(context.TODO(), "shared", kubeApiMeta.GetOptions{}); err != nil {
+		t.Fatalf("expected the existing namespace to be kept: %v", err)
+	}
+	if _, err := c.CoreV1().Pods("shared").Get(context.TODO(), "other", kubeApiMeta.GetOptions{}); err != nil {
+		t.Fatalf("expected the workloads of the existing namespace to be kept: %v", err)
+	}
+	_, err = c.AppsV1().DaemonSets("shared").Get(context.TODO(), appLabel, kubeApiMeta.GetOptions{})
+	if !kubeErrors.IsNotFound(err) {
+		t.Fatalf("expected the DaemonSet to be deleted, got %v", err)
+	}
+}
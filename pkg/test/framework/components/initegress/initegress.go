@@ -0,0 +1,163 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package initegress checks whether the egress traffic of application init containers, made with echo.InitCall,
+// leaves the pod. Whether it does depends on when the traffic of the pod starts being redirected to the proxy, which
+// only starts with the application, so calls made once redirection is in place are blocked unless they are made as
+// the proxy user:
+//
+//   - Without a sidecar, traffic is not redirected.
+//   - With the CNI plugin, traffic is redirected before any init container runs. The istio-validation init container
+//     only checks that the plugin did so.
+//   - Otherwise, the istio-init init container installs the iptables rules. The injector appends it after the init
+//     containers of the application, so only the init containers following it are redirected.
+//
+// The expected outcome is derived from the containers of each pod, so checks hold for any of these.
+package initegress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiCore "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/kube/inject"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+)
+
+const (
+	// initContainer installs the iptables rules redirecting the traffic of the pod, unless the CNI plugin does.
+	initContainer = "istio-init"
+	// proxyUID is the user of the proxy, whose traffic is not redirected.
+	proxyUID = 1337
+)
+
+// Expected returns the outcome expected for the init call of the given name in the pod.
+func Expected(p kubeApiCore.Pod, call string) (Outcome, error) {
+	container := containerPrefix + call
+	index := -1
+	redirectedFrom := -1
+	sidecar := false
+	for i, c := range p.Spec.InitContainers {
+		switch c.Name {
+		case container:
+			index = i
+			if c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil && *c.SecurityContext.RunAsUser == proxyUID {
+				return Succeeded, nil
+			}
+		case inject.ValidationContainerName:
+			// The CNI plugin redirects traffic before any container runs.
+			redirectedFrom = 0
+			sidecar = true
+		case initContainer:
+			if redirectedFrom < 0 {
+				redirectedFrom = i
+			}
+			sidecar = true
+		}
+	}
+	if index < 0 {
+		return "", fmt.Errorf("no init container %s found in pod %s", container, p.Name)
+	}
+	if !sidecar || index < redirectedFrom {
+		return Succeeded, nil
+	}
+	return Failed, nil
+}
+
+const containerPrefix = "init-call-"
+
+// Outcome of an init call.
+type Outcome string
+
+const (
+	Succeeded Outcome = "succeeded"
+	Failed    Outcome = "failed"
+)
+
+// Results returns the outcome of the init call of the given name in each pod of the instance, keyed by pod name.
+func Results(i echo.Instance, call string) (map[string]Outcome, error) {
+	cfg := i.Config()
+	pods, err := cfg.Cluster.PodsForSelector(context.TODO(), cfg.Namespace.Name(), "app="+cfg.Service)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pods found for %s", cfg.Service)
+	}
+	out := map[string]Outcome{}
+	for _, p := range pods.Items {
+		o, err := outcome(p, containerPrefix+call)
+		if err != nil {
+			return nil, err
+		}
+		out[p.Name] = o
+	}
+	return out, nil
+}
+
+func outcome(p kubeApiCore.Pod, container string) (Outcome, error) {
+	for _, s := range p.Status.InitContainerStatuses {
+		if s.Name != container {
+			continue
+		}
+		if s.State.Terminated == nil {
+			return "", fmt.Errorf("init container %s of pod %s has not terminated", container, p.Name)
+		}
+		return Outcome(strings.TrimSpace(s.State.Terminated.Message)), nil
+	}
+	return "", fmt.Errorf("no init container %s found in pod %s", container, p.Name)
+}
+
+// Check that the init call of the given name had the outcome expected from the containers of each pod of the
+// instance (see Expected).
+func Check(i echo.Instance, call string) error {
+	cfg := i.Config()
+	pods, err := cfg.Cluster.PodsForSelector(context.TODO(), cfg.Namespace.Name(), "app="+cfg.Service)
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods found for %s", cfg.Service)
+	}
+	var errs error
+	for _, p := range pods.Items {
+		want, err := Expected(p, call)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		got, err := outcome(p, containerPrefix+call)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		if got != want {
+			errs = multierror.Append(errs, fmt.Errorf("expected init call %s of pod %s to have %s, got %q",
+				call, p.Name, want, got))
+		}
+	}
+	return errs
+}
+
+// CheckOrFail calls Check and fails the test if it returns an error.
+func CheckOrFail(t test.Failer, i echo.Instance, call string) {
+	t.Helper()
+	if err := Check(i, call); err != nil {
+		t.Fatal(err)
+	}
+}
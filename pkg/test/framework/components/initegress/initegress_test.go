@@ -0,0 +1,66 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package initegress
+
+import (
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+)
+
+func pod(containers ...kubeApiCore.Container) kubeApiCore.Pod {
+	return kubeApiCore.Pod{Spec: kubeApiCore.PodSpec{InitContainers: containers}}
+}
+
+func container(name string) kubeApiCore.Container {
+	return kubeApiCore.Container{Name: name}
+}
+
+func proxyUserContainer(name string) kubeApiCore.Container {
+	uid := int64(proxyUID)
+	c := container(name)
+	c.SecurityContext = &kubeApiCore.SecurityContext{RunAsUser: &uid}
+	return c
+}
+
+func TestExpected(t *testing.T) {
+	cases := []struct {
+		name string
+		pod  kubeApiCore.Pod
+		want Outcome
+	}{
+		{"no sidecar", pod(container("init-call-a")), Succeeded},
+		// The injector appends istio-init after the init containers of the application.
+		{"before istio-init", pod(container("init-call-a"), container("istio-init")), Succeeded},
+		{"after istio-init", pod(container("istio-init"), container("init-call-a")), Failed},
+		{"cni", pod(container("istio-validation"), container("init-call-a")), Failed},
+		{"proxy user after istio-init", pod(container("istio-init"), proxyUserContainer("init-call-a")), Succeeded},
+		{"proxy user with cni", pod(container("istio-validation"), proxyUserContainer("init-call-a")), Succeeded},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expected(tt.pod, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := Expected(pod(container("istio-init")), "a"); err == nil {
+		t.Fatal("expected an error for a pod without the init call")
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package injectiontemplate overrides the sidecar injection template of istiod, and asserts on the pod specs it
+// renders. istiod renders a single template, so per-pod template selection is provided by composing the templates
+// into one that branches on the inject.istio.io/templates annotation of the pod.
+package injectiontemplate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/livereload"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// DefaultTemplate is the name under which the template active before the override is selectable.
+	DefaultTemplate = "sidecar"
+
+	istiodSelector = "app=istiod"
+	monitoringPort = 15014
+)
+
+// Config of a template override.
+type Config struct {
+	// Cluster whose istiod is configured. Defaults to the default cluster.
+	Cluster resource.Cluster
+	// SystemNamespace of istiod. Defaults to "istio-system".
+	SystemNamespace string
+	// Revision of istiod. Defaults to the default revision.
+	Revision string
+	// Templates by name, selected by the inject.istio.io/templates annotation of pods. Pods without the annotation,
+	// or selecting an unknown template, are injected with DefaultTemplate, which is the active template unless
+	// overridden here. Templates can extend the default one by rendering it with {{ template "sidecar" . }}.
+	Templates map[string]string
+}
+
+func (c *Config) fillDefaults(ctx resource.Context) {
+	c.Cluster = ctx.Clusters().GetOrDefault(c.Cluster)
+	if c.SystemNamespace == "" {
+		c.SystemNamespace = "istio-system"
+	}
+}
+
+func (c Config) configMap() string {
+	if c.Revision == "" {
+		return "istio-sidecar-injector"
+	}
+	return "istio-sidecar-injector-" + c.Revision
+}
+
+// Compose returns a template rendering the template selected by the inject.istio.io/templates annotation of the pod,
+// or the default template if none matches. The default template is defined as DefaultTemplate, so that the others
+// can render it.
+func Compose(defaultTemplate string, templates map[string]string) string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		if name != DefaultTemplate {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		if t, ok := templates[DefaultTemplate]; ok {
+			return t
+		}
+		return defaultTemplate
+	}
+	sort.Strings(names)
+	if t, ok := templates[DefaultTemplate]; ok {
+		defaultTemplate = t
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "{{- define `%s` }}\n%s\n{{- end }}\n", DefaultTemplate, strings.TrimRight(defaultTemplate, "\n"))
+	fmt.Fprintf(&b, "{{- $template := annotation .ObjectMeta `%s` `%s` }}\n", echo.InjectTemplates.Name, DefaultTemplate)
+	for i, name := range names {
+		keyword := "if"
+		if i > 0 {
+			keyword = "else if"
+		}
+		fmt.Fprintf(&b, "{{- %s eq $template `%s` }}\n%s\n", keyword, name, strings.TrimRight(templates[name], "\n"))
+	}
+	fmt.Fprintf(&b, "{{- else }}\n{{- template `%s` . }}\n{{- end }}\n", DefaultTemplate)
+	return b.String()
+}
+
+// Override replaces the injection template of istiod with the composition of the templates, and waits until istiod
+// serves it. The returned function restores the original template.
+func Override(ctx resource.Context, cfg Config) (func() error, error) {
+	cfg.fillDefaults(ctx)
+	obs := &templateObserver{cfg: cfg}
+	return livereload.ReloadConfigMap(cfg.Cluster, cfg.SystemNamespace, cfg.configMap(),
+		func(data map[string]string) error {
+			injection := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(data["config"]), &injection); err != nil {
+				return fmt.Errorf("failed parsing injection config: %v", err)
+			}
+			active, _ := injection["template"].(string)
+			injection["template"] = Compose(active, cfg.Templates)
+			out, err := yaml.Marshal(injection)
+			if err != nil {
+				return err
+			}
+			data["config"] = string(out)
+			return nil
+		}, obs)
+}
+
+// OverrideOrFail calls Override and fails the test if it returns an error. The original template is restored when
+// the test completes.
+func OverrideOrFail(t test.Failer, ctx resource.Context, cfg Config) {
+	t.Helper()
+	restore, err := Override(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := restore(); err != nil {
+			t.Fatalf("failed restoring injection template: %v", err)
+		}
+	})
+}
+
+// Setup returns a suite setup function overriding the injection template for the whole suite, before workloads are
+// deployed.
+func Setup(cfg Config) resource.SetupFn {
+	return func(ctx resource.Context) error {
+		_, err := Override(ctx, cfg)
+		return err
+	}
+}
+
+// templateObserver observes istiod serving the template of the injection ConfigMap.
+type templateObserver struct {
+	cfg Config
+}
+
+var _ livereload.Observer = &templateObserver{}
+
+func (o *templateObserver) Mark() error {
+	return nil
+}
+
+func (o *templateObserver) Observed() error {
+	cm, err := o.cfg.Cluster.CoreV1().ConfigMaps(o.cfg.SystemNamespace).Get(context.TODO(), o.cfg.configMap(),
+		kubeApiMeta.GetOptions{})
+	if err != nil {
+		return err
+	}
+	injection := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(cm.Data["config"]), &injection); err != nil {
+		return err
+	}
+	expected, _ := injection["template"].(string)
+
+	selector := istiodSelector
+	if o.cfg.Revision != "" {
+		selector += ",istio.io/rev=" + o.cfg.Revision
+	}
+	pods, err := o.cfg.Cluster.PodsForSelector(context.TODO(), o.cfg.SystemNamespace, selector)
+	if err != nil {
+		return err
+	}
+	for _, p := range pods.Items {
+		active, err := activeTemplate(o.cfg.Cluster, p.Name, p.Namespace)
+		if err != nil {
+			return err
+		}
+		if active != expected {
+			return fmt.Errorf("istiod pod %s/%s does not serve the injection template yet", p.Namespace, p.Name)
+		}
+	}
+	return nil
+}
+
+// activeTemplate returns the injection template served by the istiod pod.
+func activeTemplate(c resource.Cluster, pod, ns string) (string, error) {
+	fw, err := c.NewPortForwarder(pod, ns, "", 0, monitoringPort)
+	if err != nil {
+		return "", err
+	}
+	if err := fw.Start(); err != nil {
+		return "", err
+	}
+	defer fw.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/debug/inject", fw.Address()))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d from /debug/inject: %s", resp.StatusCode, body)
+	}
+	return string(body), nil
+}
+
+// PodCheck asserts on the spec of an injected pod.
+type PodCheck func(pod kubeApiCore.Pod) error
+
+// HasContainer checks that the pod has the container.
+func HasContainer(name string) PodCheck {
+	return func(pod kubeApiCore.Pod) error {
+		if findContainer(pod.Spec.Containers, name) == nil {
+			return fmt.Errorf("container %s not found", name)
+		}
+		return nil
+	}
+}
+
+// NoContainer checks that the pod does not have the container, such as istio-proxy for templates that do not
+// inject a sidecar.
+func NoContainer(name string) PodCheck {
+	return func(pod kubeApiCore.Pod) error {
+		if findContainer(pod.Spec.Containers, name) != nil {
+			return fmt.Errorf("unexpected container %s", name)
+		}
+		return nil
+	}
+}
+
+// HasInitContainer checks that the pod has the init container.
+func HasInitContainer(name string) PodCheck {
+	return func(pod kubeApiCore.Pod) error {
+		if findContainer(pod.Spec.InitContainers, name) == nil {
+			return fmt.Errorf("init container %s not found", name)
+		}
+		return nil
+	}
+}
+
+// ContainerArg checks that the container is run with the argument.
+func ContainerArg(container, arg string) PodCheck {
+	return func(pod kubeApiCore.Pod) error {
+		c := findContainer(pod.Spec.Containers, container)
+		if c == nil {
+			return fmt.Errorf("container %s not found", container)
+		}
+		for _, a := range c.Args {
+			if a == arg {
+				return nil
+			}
+		}
+		return fmt.Errorf("container %s does not have argument %q: %v", container, arg, c.Args)
+	}
+}
+
+// ContainerEnv checks that the container has the environment variable with the value.
+func ContainerEnv(container, name, value string) PodCheck {
+	return func(pod kubeApiCore.Pod) error {
+		c := findContainer(pod.Spec.Containers, container)
+		if c == nil {
+			return fmt.Errorf("container %s not found", container)
+		}
+		for _, e := range c.Env {
+			if e.Name == name {
+				if e.Value != value {
+					return fmt.Errorf("expected %s=%q in container %s, got %q", name, value, container, e.Value)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("variable %s not found in container %s", name, container)
+	}
+}
+
+// HasAnnotation checks that the pod has the annotation with the value.
+func HasAnnotation(name, value string) PodCheck {
+	return func(pod kubeApiCore.Pod) error {
+		if got, ok := pod.Annotations[name]; !ok || got != value {
+			return fmt.Errorf("expected annotation %s=%q, got %q", name, value, got)
+		}
+		return nil
+	}
+}
+
+func findContainer(containers []kubeApiCore.Container, name string) *kubeApiCore.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// CheckPods runs the checks on the pods of the instance. Checks are retried, as pods of a rollout may still be
+// injected with a previous template.
+func CheckPods(i echo.Instance, checks []PodCheck, opts ...retry.Option) error {
+	c := i.Config().Cluster
+	ns := i.Config().Namespace.Name()
+	return retry.UntilSuccess(func() error {
+		pods, err := c.PodsForSelector(context.TODO(), ns, "app="+i.Config().Service)
+		if err != nil {
+			return err
+		}
+		if len(pods.Items) == 0 {
+			return fmt.Errorf("no pods found for %s/%s", ns, i.Config().Service)
+		}
+		var errs error
+		for _, p := range pods.Items {
+			for _, check := range checks {
+				if err := check(p); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("pod %s/%s: %v", ns, p.Name, err))
+				}
+			}
+		}
+		return errs
+	}, opts...)
+}
+
+// CheckPodsOrFail calls CheckPods and fails the test if it returns an error.
+func CheckPodsOrFail(t test.Failer, i echo.Instance, checks []PodCheck, opts ...retry.Option) {
+	t.Helper()
+	if err := CheckPods(i, checks, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
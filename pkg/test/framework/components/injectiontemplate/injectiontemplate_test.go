@@ -0,0 +1,235 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package injectiontemplate
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/kube/inject"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	sidecarTemplate = `
+containers:
+- name: istio-proxy
+  image: proxyv2
+  args:
+  - proxy
+  - sidecar
+`
+	debugTemplate = `
+containers:
+- name: istio-proxy
+  image: proxyv2
+  args:
+  - proxy
+  - sidecar
+  - --proxyLogLevel=debug
+  env:
+  - name: TEMPLATE
+    value: debug
+`
+	gatewayTemplate = `
+initContainers:
+- name: istio-validation
+  image: proxyv2
+`
+	// extendedTemplate renders the default template, with a DNS config.
+	extendedTemplate = `{{ template "sidecar" . }}
+dnsConfig:
+  options:
+  - name: ndots
+    value: "3"
+`
+)
+
+// injectPod injects the pod with the template, as istiod does.
+func injectPod(t *testing.T, template, selected string) kubeApiCore.Pod {
+	t.Helper()
+	pod := &kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "a", Namespace: "default"},
+		Spec:       kubeApiCore.PodSpec{Containers: []kubeApiCore.Container{{Name: "app", Image: "app"}}},
+	}
+	if selected != "" {
+		pod.Annotations = map[string]string{echo.InjectTemplates.Name: selected}
+	}
+	mc := mesh.DefaultMeshConfig()
+	out, err := inject.IntoObject(template, "{}", "", &mc, pod, func(string) {})
+	if err != nil {
+		t.Fatalf("failed injecting pod: %v\n%s", err, template)
+	}
+	return *out.(*kubeApiCore.Pod)
+}
+
+func check(t *testing.T, pod kubeApiCore.Pod, checks ...PodCheck) {
+	t.Helper()
+	for _, c := range checks {
+		if err := c(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	if got := Compose(sidecarTemplate, nil); got != sidecarTemplate {
+		t.Fatalf("expected the default template without others, got %q", got)
+	}
+	if got := Compose(sidecarTemplate, map[string]string{DefaultTemplate: debugTemplate}); got != debugTemplate {
+		t.Fatalf("expected the overridden default template, got %q", got)
+	}
+
+	composed := Compose(sidecarTemplate, map[string]string{
+		"debug":    debugTemplate,
+		"gateway":  gatewayTemplate,
+		"extended": extendedTemplate,
+	})
+	for _, selected := range []string{"", DefaultTemplate, "unknown"} {
+		t.Run("default/"+selected, func(t *testing.T) {
+			pod := injectPod(t, composed, selected)
+			check(t, pod, HasContainer("app"), HasContainer("istio-proxy"), ContainerArg("istio-proxy", "sidecar"))
+			if err := ContainerArg("istio-proxy", "--proxyLogLevel=debug")(pod); err == nil {
+				t.Fatal("expected the default template to be rendered")
+			}
+		})
+	}
+	t.Run("debug", func(t *testing.T) {
+		check(t, injectPod(t, composed, "debug"),
+			ContainerArg("istio-proxy", "--proxyLogLevel=debug"), ContainerEnv("istio-proxy", "TEMPLATE", "debug"))
+	})
+	t.Run("gateway", func(t *testing.T) {
+		check(t, injectPod(t, composed, "gateway"), NoContainer("istio-proxy"), HasInitContainer("istio-validation"))
+	})
+	t.Run("extended", func(t *testing.T) {
+		pod := injectPod(t, composed, "extended")
+		check(t, pod, ContainerArg("istio-proxy", "sidecar"))
+		if pod.Spec.DNSConfig == nil || len(pod.Spec.DNSConfig.Options) != 1 {
+			t.Fatalf("expected the DNS config of the extended template, got %+v", pod.Spec.DNSConfig)
+		}
+	})
+
+	// Unannotated pods are injected with the overridden default template.
+	overridden := Compose(sidecarTemplate, map[string]string{DefaultTemplate: debugTemplate, "gateway": gatewayTemplate})
+	check(t, injectPod(t, overridden, ""), ContainerArg("istio-proxy", "--proxyLogLevel=debug"))
+}
+
+func TestPodChecks(t *testing.T) {
+	pod := kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Annotations: map[string]string{"a": "1"}},
+		Spec: kubeApiCore.PodSpec{
+			InitContainers: []kubeApiCore.Container{{Name: "istio-init"}},
+			Containers: []kubeApiCore.Container{{
+				Name: "istio-proxy",
+				Args: []string{"proxy", "sidecar"},
+				Env:  []kubeApiCore.EnvVar{{Name: "A", Value: "1"}},
+			}},
+		},
+	}
+	cases := []struct {
+		name   string
+		check  PodCheck
+		errMsg string
+	}{
+		{name: "container", check: HasContainer("istio-proxy")},
+		{name: "container missing", check: HasContainer("app"), errMsg: "container app not found"},
+		{name: "no container", check: NoContainer("app")},
+		{name: "unexpected container", check: NoContainer("istio-proxy"), errMsg: "unexpected container istio-proxy"},
+		{name: "init container", check: HasInitContainer("istio-init")},
+		{name: "init container missing", check: HasInitContainer("istio-proxy"), errMsg: "init container istio-proxy not found"},
+		{name: "arg", check: ContainerArg("istio-proxy", "sidecar")},
+		{name: "arg missing", check: ContainerArg("istio-proxy", "router"), errMsg: `does not have argument "router"`},
+		{name: "env", check: ContainerEnv("istio-proxy", "A", "1")},
+		{name: "env differs", check: ContainerEnv("istio-proxy", "A", "2"), errMsg: `expected A="2" in container istio-proxy, got "1"`},
+		{name: "env missing", check: ContainerEnv("istio-proxy", "B", "1"), errMsg: "variable B not found"},
+		{name: "annotation", check: HasAnnotation("a", "1")},
+		{name: "annotation differs", check: HasAnnotation("a", "2"), errMsg: `expected annotation a="2", got "1"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.check(pod)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Fatalf("expected error %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+// fakeClient serves the pods of the app label of its selector.
+type fakeClient struct {
+	kube.ExtendedClient
+	pods []kubeApiCore.Pod
+}
+
+func (c fakeClient) PodsForSelector(_ context.Context, ns string, selectors ...string) (*kubeApiCore.PodList, error) {
+	out := &kubeApiCore.PodList{}
+	for _, p := range c.pods {
+		if p.Namespace == ns && "app="+p.Labels["app"] == strings.Join(selectors, ",") {
+			out.Items = append(out.Items, p)
+		}
+	}
+	return out, nil
+}
+
+func TestCheckPods(t *testing.T) {
+	pod := func(name string, containers ...string) kubeApiCore.Pod {
+		p := kubeApiCore.Pod{ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:      name,
+			Namespace: "ns",
+			Labels:    map[string]string{"app": "a"},
+		}}
+		for _, c := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, kubeApiCore.Container{Name: c})
+		}
+		return p
+	}
+	instance := func(pods ...kubeApiCore.Pod) echo.Instance {
+		return echo.FakeInstance{ConfigValue: echo.Config{
+			Service:   "a",
+			Namespace: namespace.Fake{NameValue: "ns"},
+			Cluster:   resource.FakeCluster{ExtendedClient: fakeClient{pods: pods}},
+		}}
+	}
+	opts := []retry.Option{retry.Timeout(100 * time.Millisecond), retry.Delay(10 * time.Millisecond)}
+	checks := []PodCheck{HasContainer("istio-proxy")}
+
+	if err := CheckPods(instance(pod("a-1", "app", "istio-proxy")), checks, opts...); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckPods(instance(pod("a-1", "app", "istio-proxy"), pod("a-2", "app")), checks, opts...)
+	if err == nil || !strings.Contains(err.Error(), "pod ns/a-2: container istio-proxy not found") {
+		t.Fatalf("expected the pod without the container to be reported, got %v", err)
+	}
+	err = CheckPods(instance(), checks, opts...)
+	if err == nil || !strings.Contains(err.Error(), "no pods found for ns/a") {
+		t.Fatalf("expected an error without pods, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"fmt"
+	"time"
+)
+
+// ShortLivedCertValues returns ControlPlaneValues that issue workload certificates with the given TTL, so that
+// rotation can be observed within a test. Certificates are rotated once half of the TTL has elapsed, and the agent
+// checks for rotation at a tenth of the TTL, rather than every 5 minutes.
+func ShortLivedCertValues(ttl time.Duration) string {
+	return fmt.Sprintf(`
+meshConfig:
+  defaultConfig:
+    proxyMetadata:
+      SECRET_TTL: %q
+      SECRET_GRACE_PERIOD_RATIO: "0.5"
+      SECRET_ROTATION_CHECK_INTERVAL: %q
+values:
+  pilot:
+    env:
+      DEFAULT_WORKLOAD_CERT_TTL: %q
+`, ttl, ttl/10, ttl)
+}
@@ -0,0 +1,49 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestShortLivedCertValues(t *testing.T) {
+	var got map[string]interface{}
+	if err := yaml.Unmarshal([]byte(ShortLivedCertValues(10*time.Minute)), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"meshConfig": map[string]interface{}{
+			"defaultConfig": map[string]interface{}{
+				"proxyMetadata": map[string]interface{}{
+					"SECRET_TTL":                     "10m0s",
+					"SECRET_GRACE_PERIOD_RATIO":      "0.5",
+					"SECRET_ROTATION_CHECK_INTERVAL": "1m0s",
+				},
+			},
+		},
+		"values": map[string]interface{}{
+			"pilot": map[string]interface{}{
+				"env": map[string]interface{}{"DEFAULT_WORKLOAD_CERT_TTL": "10m0s"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -56,6 +56,8 @@ const (
 var (
 	helmValues string
 
+	debugDeployments string
+
 	settingsFromCommandline = &Config{
 		SystemNamespace:    DefaultSystemNamespace,
 		IstioNamespace:     DefaultSystemNamespace,
@@ -94,6 +96,9 @@ type Config struct {
 	// The namespace in which istio egressgateway is deployed
 	EgressNamespace string
 
+	// Revision the control plane is installed as, if set. Defaults to the tenant of the run, if any.
+	Revision string
+
 	// DeployTimeout the timeout for deploying Istio.
 	DeployTimeout time.Duration
 
@@ -129,6 +134,13 @@ type Config struct {
 	// Do not wait for the validation webhook before completing the deployment. This is useful for
 	// doing deployments without Galley.
 	SkipWaitForValidationWebhook bool
+
+	// Deployments of the system namespace, such as "istiod" or "istio-ingressgateway", run under delve so that a
+	// debugger can be attached to them. A local port is forwarded to delve in each of their pods.
+	Debug []string
+
+	// Image providing the dlv binary, required by Debug.
+	DebugImage string
 }
 
 func (c *Config) IstioOperatorConfigYAML(iopYaml string) string {
@@ -182,6 +194,19 @@ func DefaultConfig(ctx resource.Context) (Config, error) {
 		scopes.Framework.Warnf("Default IOPFile missing: %v", err)
 	}
 
+	if tenant := ctx.Settings().Tenant; tenant != "" {
+		// Each tenant runs its own control plane, in its own namespaces, injecting only its own namespaces.
+		if s.Revision == "" {
+			s.Revision = tenant
+		}
+		for _, ns := range []*string{&s.SystemNamespace, &s.IstioNamespace, &s.ConfigNamespace, &s.TelemetryNamespace,
+			&s.PolicyNamespace, &s.IngressNamespace, &s.EgressNamespace} {
+			if *ns == DefaultSystemNamespace {
+				*ns = ctx.Settings().TenantPrefix() + DefaultSystemNamespace
+			}
+		}
+	}
+
 	deps, err := image.SettingsFromCommandLine()
 	if err != nil {
 		return Config{}, err
@@ -191,6 +216,12 @@ func DefaultConfig(ctx resource.Context) (Config, error) {
 		return Config{}, err
 	}
 
+	for _, d := range strings.Split(debugDeployments, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			s.Debug = append(s.Debug, d)
+		}
+	}
+
 	if ctx.Settings().CIMode {
 		s.DeployTimeout = DefaultCIDeployTimeout
 		s.UndeployTimeout = DefaultCIUndeployTimeout
@@ -279,12 +310,14 @@ func (c *Config) String() string {
 	result += fmt.Sprintf("PolicyNamespace:                %s\n", c.PolicyNamespace)
 	result += fmt.Sprintf("IngressNamespace:               %s\n", c.IngressNamespace)
 	result += fmt.Sprintf("EgressNamespace:                %s\n", c.EgressNamespace)
+	result += fmt.Sprintf("Revision:                       %s\n", c.Revision)
 	result += fmt.Sprintf("DeployIstio:                    %v\n", c.DeployIstio)
 	result += fmt.Sprintf("DeployTimeout:                  %s\n", c.DeployTimeout.String())
 	result += fmt.Sprintf("UndeployTimeout:                %s\n", c.UndeployTimeout.String())
 	result += fmt.Sprintf("Values:                         %v\n", c.Values)
 	result += fmt.Sprintf("IOPFile:                        %s\n", c.IOPFile)
 	result += fmt.Sprintf("SkipWaitForValidationWebhook:   %v\n", c.SkipWaitForValidationWebhook)
+	result += fmt.Sprintf("Debug:                          %v\n", c.Debug)
 	return result
 }
 
@@ -0,0 +1,147 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test/framework/resource"
+	kube2 "istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+const (
+	// delvePort is the port delve listens on in the debugged container.
+	delvePort = 40000
+	// delveDir is where the init container copies delve to, shared with the debugged container.
+	delveDir = "/var/run/dlv"
+)
+
+// debugBinaries are the binaries run by the entrypoints of the containers of Istio deployments, by container name.
+var debugBinaries = map[string]string{
+	"discovery":   "/usr/local/bin/pilot-discovery",
+	"istio-proxy": "/usr/local/bin/pilot-agent",
+}
+
+// enableDebug restarts each of the deployments of Config.Debug in the system namespace of the cluster under delve,
+// and forwards a local port to it, so that a debugger can be attached while tests drive traffic. Delve is copied from
+// Config.DebugImage, which must have a statically linked dlv on its path. Binaries built with DEBUG=1 keep the
+// symbols and disable the optimizations delve needs to step through the code.
+func (i *operatorComponent) enableDebug(cluster resource.Cluster) error {
+	ns := systemNamespaceFor(i.settings, cluster)
+	for _, name := range i.settings.Debug {
+		d, err := cluster.AppsV1().Deployments(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed getting deployment %s/%s to debug: %v", ns, name, err)
+		}
+		if err := debugPodSpec(&d.Spec.Template.Spec, i.settings.DebugImage); err != nil {
+			return fmt.Errorf("failed enabling debugging of %s/%s: %v", ns, name, err)
+		}
+		if _, err := cluster.AppsV1().Deployments(ns).Update(context.TODO(), d, kubeApiMeta.UpdateOptions{}); err != nil {
+			return err
+		}
+		if err := kube2.WaitForDeploymentRollout(cluster, ns, name); err != nil {
+			return err
+		}
+
+		pods, err := cluster.PodsForSelector(context.TODO(), ns, kubeApiMeta.FormatLabelSelector(d.Spec.Selector))
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			fw, err := cluster.NewPortForwarder(pod.Name, ns, "127.0.0.1", 0, delvePort)
+			if err != nil {
+				return err
+			}
+			if err := fw.Start(); err != nil {
+				return fmt.Errorf("failed forwarding to delve in %s/%s: %v", ns, pod.Name, err)
+			}
+			i.mu.Lock()
+			i.debugForwarders = append(i.debugForwarders, fw)
+			i.mu.Unlock()
+			scopes.Framework.Infof("delve of %s/%s in cluster %s is listening; attach with: dlv connect %s",
+				ns, pod.Name, cluster.Name(), fw.Address())
+		}
+	}
+	return nil
+}
+
+// debugPodSpec runs the Istio binaries of the pod under delve, copied in by an init container.
+func debugPodSpec(spec *kubeApiCore.PodSpec, debugImage string) error {
+	if debugImage == "" {
+		return fmt.Errorf("a debug image providing dlv is required")
+	}
+	spec.Volumes = append(spec.Volumes, kubeApiCore.Volume{
+		Name:         "dlv",
+		VolumeSource: kubeApiCore.VolumeSource{EmptyDir: &kubeApiCore.EmptyDirVolumeSource{}},
+	})
+	mount := kubeApiCore.VolumeMount{Name: "dlv", MountPath: delveDir}
+	spec.InitContainers = append(spec.InitContainers, kubeApiCore.Container{
+		Name:         "install-dlv",
+		Image:        debugImage,
+		Command:      []string{"sh", "-c", "cp \"$(command -v dlv)\" " + delveDir + "/dlv"},
+		VolumeMounts: []kubeApiCore.VolumeMount{mount},
+	})
+
+	debugged := 0
+	for n := range spec.Containers {
+		c := &spec.Containers[n]
+		binary, ok := debugBinaries[c.Name]
+		if !ok {
+			continue
+		}
+		c.Command = []string{delveDir + "/dlv", "exec", binary,
+			fmt.Sprintf("--listen=:%d", delvePort), "--headless", "--api-version=2", "--accept-multiclient", "--continue",
+			"--"}
+		c.VolumeMounts = append(c.VolumeMounts, mount)
+		c.Ports = append(c.Ports, kubeApiCore.ContainerPort{Name: "dlv", ContainerPort: delvePort})
+		// The process is not restarted while stopped at a breakpoint.
+		c.LivenessProbe = nil
+		if c.SecurityContext == nil {
+			c.SecurityContext = &kubeApiCore.SecurityContext{}
+		}
+		if c.SecurityContext.Capabilities == nil {
+			c.SecurityContext.Capabilities = &kubeApiCore.Capabilities{}
+		}
+		c.SecurityContext.Capabilities.Add = append(c.SecurityContext.Capabilities.Add, "SYS_PTRACE")
+		debugged++
+	}
+	if debugged == 0 {
+		names := make([]string, 0, len(debugBinaries))
+		for name := range debugBinaries {
+			names = append(names, name)
+		}
+		return fmt.Errorf("no container named one of %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// closeDebugForwarders stops forwarding to the debugged pods.
+func (i *operatorComponent) closeDebugForwarders() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, fw := range i.debugForwarders {
+		fw.Close()
+	}
+	i.debugForwarders = nil
+}
@@ -0,0 +1,107 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+)
+
+func TestDebugPodSpec(t *testing.T) {
+	spec := kubeApiCore.PodSpec{Containers: []kubeApiCore.Container{
+		{
+			Name:          "discovery",
+			Args:          []string{"discovery", "--monitoringAddr=:15014"},
+			LivenessProbe: &kubeApiCore.Probe{},
+			SecurityContext: &kubeApiCore.SecurityContext{
+				Capabilities: &kubeApiCore.Capabilities{Add: []kubeApiCore.Capability{"NET_ADMIN"}},
+			},
+		},
+		{Name: "sidecar", Args: []string{"run"}},
+	}}
+	if err := debugPodSpec(&spec, "debug:latest"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spec.InitContainers) != 1 || spec.InitContainers[0].Image != "debug:latest" {
+		t.Fatalf("expected an init container copying delve from the debug image, got %+v", spec.InitContainers)
+	}
+	if len(spec.Volumes) != 1 || spec.Volumes[0].EmptyDir == nil {
+		t.Fatalf("expected a volume sharing delve, got %+v", spec.Volumes)
+	}
+
+	c := spec.Containers[0]
+	wantCommand := []string{delveDir + "/dlv", "exec", "/usr/local/bin/pilot-discovery",
+		"--listen=:40000", "--headless", "--api-version=2", "--accept-multiclient", "--continue", "--"}
+	if !reflect.DeepEqual(c.Command, wantCommand) {
+		t.Errorf("got command %v, want %v", c.Command, wantCommand)
+	}
+	if !reflect.DeepEqual(c.Args, []string{"discovery", "--monitoringAddr=:15014"}) {
+		t.Errorf("expected the arguments to be passed on to the binary, got %v", c.Args)
+	}
+	if c.LivenessProbe != nil {
+		t.Error("expected the liveness probe to be removed")
+	}
+	if got := c.SecurityContext.Capabilities.Add; !reflect.DeepEqual(got, []kubeApiCore.Capability{"NET_ADMIN", "SYS_PTRACE"}) {
+		t.Errorf("got capabilities %v", got)
+	}
+	if len(c.Ports) != 1 || c.Ports[0].ContainerPort != delvePort {
+		t.Errorf("got ports %+v, want the delve port", c.Ports)
+	}
+	if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].MountPath != delveDir {
+		t.Errorf("got volume mounts %+v", c.VolumeMounts)
+	}
+
+	if other := spec.Containers[1]; other.Command != nil || other.SecurityContext != nil || len(other.VolumeMounts) != 0 {
+		t.Errorf("expected containers without an Istio binary to be left alone, got %+v", other)
+	}
+}
+
+func TestDebugPodSpecProxy(t *testing.T) {
+	spec := kubeApiCore.PodSpec{Containers: []kubeApiCore.Container{{Name: "istio-proxy"}}}
+	if err := debugPodSpec(&spec, "debug:latest"); err != nil {
+		t.Fatal(err)
+	}
+	c := spec.Containers[0]
+	if c.Command[2] != "/usr/local/bin/pilot-agent" {
+		t.Errorf("got command %v, want pilot-agent under delve", c.Command)
+	}
+	if got := c.SecurityContext.Capabilities.Add; !reflect.DeepEqual(got, []kubeApiCore.Capability{"SYS_PTRACE"}) {
+		t.Errorf("got capabilities %v", got)
+	}
+}
+
+func TestDebugPodSpecInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		image string
+		err   string
+	}{
+		{"no image", "", "debug image"},
+		{"no istio container", "debug:latest", "no container named one of"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := kubeApiCore.PodSpec{Containers: []kubeApiCore.Container{{Name: "app"}}}
+			err := debugPodSpec(&spec, tc.image)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("got error %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
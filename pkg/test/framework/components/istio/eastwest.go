@@ -46,12 +46,16 @@ func (i *operatorComponent) deployEastWestGateway(cluster resource.Cluster) erro
 		return err
 	}
 
+	systemNamespace := systemNamespaceFor(i.settings, cluster)
 	generateSettings := []string{
-		"--istioNamespace", i.settings.SystemNamespace,
+		"--istioNamespace", systemNamespace,
 		"--manifests", filepath.Join(env.IstioSrc, "manifests"),
 		"--set", "hub=" + imgSettings.Hub,
 		"--set", "tag=" + imgSettings.Tag,
 	}
+	if rev := revisionFor(i.settings, cluster); rev != "" {
+		generateSettings = append(generateSettings, "--set", "revision="+rev)
+	}
 	// generate k8s resources for the gateway
 	cmd := exec.Command(genGatewayScript, generateSettings...)
 	cmd.Env = os.Environ()
@@ -73,12 +77,16 @@ func (i *operatorComponent) deployEastWestGateway(cluster resource.Cluster) erro
 	}
 	i.saveManifestForCleanup(cluster.Name(), string(gwYaml))
 	// push the deployment to the cluster
-	if err := i.ctx.Config(cluster).ApplyYAML(i.settings.IngressNamespace, string(gwYaml)); err != nil {
+	gwNamespace := i.settings.IngressNamespace
+	if systemNamespace != i.settings.SystemNamespace {
+		gwNamespace = systemNamespace
+	}
+	if err := i.ctx.Config(cluster).ApplyYAML(gwNamespace, string(gwYaml)); err != nil {
 		return fmt.Errorf("failed applying eastwestgateway deployment to %s: %v", cluster.Name(), err)
 	}
 	// wait for a ready pod
 	if err := retry.UntilSuccess(func() error {
-		pods, err := cluster.CoreV1().Pods(i.settings.SystemNamespace).List(context.TODO(), v1.ListOptions{
+		pods, err := cluster.CoreV1().Pods(systemNamespace).List(context.TODO(), v1.ListOptions{
 			LabelSelector: "istio=" + eastWestIngressIstioLabel,
 		})
 		if err != nil {
@@ -99,10 +107,10 @@ func (i *operatorComponent) deployEastWestGateway(cluster resource.Cluster) erro
 
 func (i *operatorComponent) applyCrossNetworkGateway(cluster resource.Cluster) error {
 	scopes.Framework.Infof("Exposing services via eastwestgateway in ", cluster.Name())
-	return cluster.ApplyYAMLFiles(i.settings.SystemNamespace, exposeServicesGateway)
+	return cluster.ApplyYAMLFiles(systemNamespaceFor(i.settings, cluster), exposeServicesGateway)
 }
 
 func (i *operatorComponent) applyIstiodGateway(cluster resource.Cluster) error {
 	scopes.Framework.Infof("Exposing istiod via eastwestgateway in ", cluster.Name())
-	return cluster.ApplyYAMLFiles(i.settings.SystemNamespace, exposeIstiodGateway)
+	return cluster.ApplyYAMLFiles(systemNamespaceFor(i.settings, cluster), exposeIstiodGateway)
 }
@@ -0,0 +1,370 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package featureflags runs tests against istiod configured with sets of pilot environment variables, so that
+// experimental feature flags get integration coverage. A suite runs against istiod restarted with a set with Setup,
+// and tests run once per set, as a sub-test named after it, with Run.
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	istiodSelector     = "app=istiod"
+	discoveryContainer = "discovery"
+	proxyContainer     = "istio-proxy"
+)
+
+// Set of pilot environment variables.
+type Set struct {
+	// Name of the set, which names the sub-tests run with it.
+	Name string
+	Env  map[string]string
+}
+
+func (s Set) String() string {
+	pairs := make([]string, 0, len(s.Env))
+	for k, v := range s.Env {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return s.Name + ":" + strings.Join(pairs, ",")
+}
+
+// Parse sets from name:KEY=VALUE,KEY=VALUE separated by semicolons.
+func Parse(spec string) ([]Set, error) {
+	var sets []Set
+	for _, s := range strings.Split(spec, ";") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		parts := strings.SplitN(s, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid feature flag set %q, expected name:KEY=VALUE,...", s)
+		}
+		set := Set{Name: parts[0], Env: map[string]string{}}
+		for _, kv := range strings.Split(parts[1], ",") {
+			if kv == "" {
+				continue
+			}
+			p := strings.SplitN(kv, "=", 2)
+			if len(p) != 2 || p[0] == "" {
+				return nil, fmt.Errorf("invalid variable %q in feature flag set %s", kv, set.Name)
+			}
+			set.Env[p[0]] = p[1]
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+// FromCommandLine returns the sets given by --istio.test.featureFlags.
+func FromCommandLine() ([]Set, error) {
+	return Parse(setsFromCommandline)
+}
+
+// istiodDeployments returns the istiod deployments of the system namespace in each cluster. Remote clusters without
+// a control plane are skipped.
+func istiodDeployments(ctx resource.Context) (map[resource.Cluster][]string, string, error) {
+	i, err := istio.Get(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	ns := i.Settings().SystemNamespace
+	out := map[resource.Cluster][]string{}
+	for _, c := range ctx.Clusters() {
+		deployments, err := c.AppsV1().Deployments(ns).List(context.TODO(), kubeApiMeta.ListOptions{
+			LabelSelector: istiodSelector,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		for _, d := range deployments.Items {
+			out[c] = append(out[c], d.Name)
+		}
+	}
+	return out, ns, nil
+}
+
+// Apply restarts istiod in every cluster with the variables of the set, on top of those it was deployed with, and
+// waits for the rollouts and for the proxies to be synced with the restarted istiod. The returned function restores
+// the variables istiod was deployed with, in the same way.
+func Apply(ctx resource.Context, set Set) (func() error, error) {
+	deployments, ns, err := istiodDeployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var restores []func() error
+	restore := func() error {
+		for _, r := range restores {
+			if err := r(); err != nil {
+				return err
+			}
+		}
+		return waitForProxies(ctx, deployments, ns)
+	}
+	for c, names := range deployments {
+		for _, name := range names {
+			original, err := setEnv(c, ns, name, func(env []kubeApiCore.EnvVar) []kubeApiCore.EnvVar {
+				return merge(env, set.Env)
+			})
+			if err != nil {
+				_ = restore()
+				return nil, fmt.Errorf("failed applying feature flags %v to %s/%s in %s: %v", set, ns, name, c.Name(), err)
+			}
+			c, name := c, name
+			restores = append(restores, func() error {
+				_, err := setEnv(c, ns, name, func([]kubeApiCore.EnvVar) []kubeApiCore.EnvVar {
+					return original
+				})
+				return err
+			})
+		}
+	}
+	if err := waitForProxies(ctx, deployments, ns); err != nil {
+		_ = restore()
+		return nil, fmt.Errorf("proxies not synced after applying feature flags %v: %v", set, err)
+	}
+	scopes.Framework.Infof("istiod restarted with feature flags %v", set)
+	return restore, nil
+}
+
+// waitForProxies waits until every proxy of the clusters is connected to a restarted istiod, and has acknowledged the
+// config last pushed to it, so that tests do not run against proxies still reconnecting or with the config of the
+// previous istiod.
+func waitForProxies(ctx resource.Context, deployments map[resource.Cluster][]string, ns string) error {
+	return retry.UntilSuccess(func() error {
+		statuses := map[string][]byte{}
+		for c := range deployments {
+			s, err := c.AllDiscoveryDo(context.TODO(), ns, "/debug/syncz")
+			if err != nil {
+				return err
+			}
+			for istiod, body := range s {
+				statuses[istiod] = body
+			}
+		}
+		synced, err := syncedProxies(statuses)
+		if err != nil {
+			return err
+		}
+		for _, c := range ctx.Clusters() {
+			pods, err := c.CoreV1().Pods("").List(context.TODO(), kubeApiMeta.ListOptions{})
+			if err != nil {
+				return err
+			}
+			if err := checkSynced(pods.Items, synced); err != nil {
+				return fmt.Errorf("%v in %s", err, c.Name())
+			}
+		}
+		return nil
+	}, retry.Timeout(2*time.Minute), retry.Delay(time.Second))
+}
+
+// syncedProxies parses the /debug/syncz status of each istiod, returning whether each connected proxy has
+// acknowledged the config last pushed to it.
+func syncedProxies(statuses map[string][]byte) (map[string]bool, error) {
+	synced := map[string]bool{}
+	for istiod, body := range statuses {
+		var proxies []xds.SyncStatus
+		if err := json.Unmarshal(body, &proxies); err != nil {
+			return nil, fmt.Errorf("failed parsing the sync status of %s: %v", istiod, err)
+		}
+		for _, p := range proxies {
+			synced[p.ProxyID] = p.ClusterSent == p.ClusterAcked && p.ListenerSent == p.ListenerAcked &&
+				p.RouteSent == p.RouteAcked && p.EndpointSent == p.EndpointAcked
+		}
+	}
+	return synced, nil
+}
+
+// checkSynced checks that the running pods with a proxy are synced.
+func checkSynced(pods []kubeApiCore.Pod, synced map[string]bool) error {
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != kubeApiCore.PodRunning || !hasProxy(pod) {
+			continue
+		}
+		id := pod.Name + "." + pod.Namespace
+		if s, ok := synced[id]; !ok {
+			return fmt.Errorf("proxy %s is not connected to the control plane", id)
+		} else if !s {
+			return fmt.Errorf("proxy %s has not acknowledged the config last pushed to it", id)
+		}
+	}
+	return nil
+}
+
+func hasProxy(pod kubeApiCore.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == proxyContainer {
+			return true
+		}
+	}
+	return false
+}
+
+// setEnv updates the environment of the discovery container of the deployment, waits for the rollout, and returns
+// the previous environment.
+func setEnv(c resource.Cluster, ns, name string, update func([]kubeApiCore.EnvVar) []kubeApiCore.EnvVar) (
+	[]kubeApiCore.EnvVar, error) {
+	d, err := c.AppsV1().Deployments(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var original []kubeApiCore.EnvVar
+	found := false
+	for i := range d.Spec.Template.Spec.Containers {
+		container := &d.Spec.Template.Spec.Containers[i]
+		if container.Name != discoveryContainer {
+			continue
+		}
+		found = true
+		original = container.Env
+		container.Env = update(append([]kubeApiCore.EnvVar{}, container.Env...))
+	}
+	if !found {
+		return nil, fmt.Errorf("container %s not found", discoveryContainer)
+	}
+	if _, err := c.AppsV1().Deployments(ns).Update(context.TODO(), d, kubeApiMeta.UpdateOptions{}); err != nil {
+		return nil, err
+	}
+	return original, kube.WaitForDeploymentRollout(c, ns, name)
+}
+
+// merge sets the variables in env, overriding those already set.
+func merge(env []kubeApiCore.EnvVar, vars map[string]string) []kubeApiCore.EnvVar {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		replaced := false
+		for i := range env {
+			if env[i].Name == k {
+				env[i] = kubeApiCore.EnvVar{Name: k, Value: vars[k]}
+				replaced = true
+			}
+		}
+		if !replaced {
+			env = append(env, kubeApiCore.EnvVar{Name: k, Value: vars[k]})
+		}
+	}
+	return env
+}
+
+// Run runs fn once per set, as a sub-test named after the set, with istiod restarted with its variables. istiod is
+// restored to the variables it was deployed with after each set. Without sets, fn runs once against istiod as
+// deployed.
+func Run(ctx framework.TestContext, sets []Set, fn func(ctx framework.TestContext)) {
+	if len(sets) == 0 {
+		fn(ctx)
+		return
+	}
+	for _, set := range sets {
+		set := set
+		ctx.NewSubTest("flags=" + set.Name).Run(func(ctx framework.TestContext) {
+			restore, err := Apply(ctx, set)
+			if err != nil {
+				ctx.Fatal(err)
+			}
+			ctx.Cleanup(func() {
+				if err := restore(); err != nil {
+					ctx.Fatalf("failed restoring istiod after feature flags %v: %v", set, err)
+				}
+			})
+			fn(ctx)
+		})
+	}
+}
+
+// Setup returns a suite setup function running the whole suite against istiod restarted with the variables of the
+// set, which are restored once the suite is done. It should run once Istio is installed. Each set is therefore a
+// separate run of the suite.
+func Setup(set Set) resource.SetupFn {
+	return func(ctx resource.Context) error {
+		restore, err := Apply(ctx, set)
+		if err != nil {
+			return err
+		}
+		a := &applied{restore: restore}
+		a.id = ctx.TrackResource(a)
+		return nil
+	}
+}
+
+// SetupFromCommandLine is a suite setup function applying the set given by --istio.test.featureFlags, if any. A suite
+// runs with a single set: tests are run with each of several sets with RunFromCommandLine.
+func SetupFromCommandLine(ctx resource.Context) error {
+	sets, err := FromCommandLine()
+	if err != nil {
+		return err
+	}
+	switch len(sets) {
+	case 0:
+		return nil
+	case 1:
+		return Setup(sets[0])(ctx)
+	default:
+		return fmt.Errorf("a suite runs with a single feature flag set, got %d: %v", len(sets), sets)
+	}
+}
+
+// applied restores istiod once the suite that applied a set is done.
+type applied struct {
+	id      resource.ID
+	restore func() error
+}
+
+var (
+	_ resource.Resource = &applied{}
+	_ io.Closer         = &applied{}
+)
+
+func (a *applied) ID() resource.ID {
+	return a.id
+}
+
+// Close implements io.Closer.
+func (a *applied) Close() error {
+	return a.restore()
+}
+
+// RunFromCommandLine calls Run with the sets given by --istio.test.featureFlags.
+func RunFromCommandLine(ctx framework.TestContext, fn func(ctx framework.TestContext)) {
+	sets, err := FromCommandLine()
+	if err != nil {
+		ctx.Fatal(err)
+	}
+	Run(ctx, sets, fn)
+}
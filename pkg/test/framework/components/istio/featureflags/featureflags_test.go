@@ -0,0 +1,156 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featureflags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		spec string
+		want []Set
+		err  string
+	}{
+		{spec: "", want: nil},
+		{
+			spec: "slices:PILOT_USE_ENDPOINT_SLICE=true",
+			want: []Set{{Name: "slices", Env: map[string]string{"PILOT_USE_ENDPOINT_SLICE": "true"}}},
+		},
+		{
+			spec: " a:X=1,Y=; b:Z=a=b ;",
+			want: []Set{
+				{Name: "a", Env: map[string]string{"X": "1", "Y": ""}},
+				{Name: "b", Env: map[string]string{"Z": "a=b"}},
+			},
+		},
+		{spec: "default:", want: []Set{{Name: "default", Env: map[string]string{}}}},
+		{spec: "X=1", err: "invalid feature flag set"},
+		{spec: ":X=1", err: "invalid feature flag set"},
+		{spec: "a:X", err: `invalid variable "X" in feature flag set a`},
+		{spec: "a:=1", err: `invalid variable "=1" in feature flag set a`},
+	}
+	for _, c := range cases {
+		t.Run(c.spec, func(t *testing.T) {
+			got, err := Parse(c.spec)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected error %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSetString(t *testing.T) {
+	s := Set{Name: "a", Env: map[string]string{"Y": "2", "X": "1"}}
+	if got, want := s.String(), "a:X=1,Y=2"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	env := []kubeApiCore.EnvVar{
+		{Name: "A", Value: "1"},
+		{Name: "B", ValueFrom: &kubeApiCore.EnvVarSource{FieldRef: &kubeApiCore.ObjectFieldSelector{FieldPath: "x"}}},
+	}
+	got := merge(env, map[string]string{"D": "4", "B": "2", "C": "3"})
+	want := []kubeApiCore.EnvVar{
+		{Name: "A", Value: "1"},
+		{Name: "B", Value: "2"},
+		{Name: "C", Value: "3"},
+		{Name: "D", Value: "4"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if got := merge(nil, nil); len(got) != 0 {
+		t.Fatalf("expected no variables, got %v", got)
+	}
+}
+
+func pod(name string, phase kubeApiCore.PodPhase, containers ...string) kubeApiCore.Pod {
+	p := kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Namespace: "ns"},
+		Status:     kubeApiCore.PodStatus{Phase: phase},
+	}
+	for _, c := range containers {
+		p.Spec.Containers = append(p.Spec.Containers, kubeApiCore.Container{Name: c})
+	}
+	return p
+}
+
+func TestSynced(t *testing.T) {
+	synced, err := syncedProxies(map[string][]byte{
+		"istiod-1": []byte(`[{"proxy":"a.ns","cluster_sent":"1","cluster_acked":"1",` +
+			`"listener_sent":"2","listener_acked":"2"}]`),
+		"istiod-2": []byte(`[{"proxy":"b.ns","cluster_sent":"2","cluster_acked":"1"}]`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"a.ns": true, "b.ns": false}; !reflect.DeepEqual(synced, want) {
+		t.Fatalf("expected %v, got %v", want, synced)
+	}
+	if _, err := syncedProxies(map[string][]byte{"istiod": []byte("not json")}); err == nil {
+		t.Fatal("expected an error parsing the sync status")
+	}
+
+	cases := []struct {
+		name string
+		pods []kubeApiCore.Pod
+		err  string
+	}{
+		{name: "synced", pods: []kubeApiCore.Pod{pod("a", kubeApiCore.PodRunning, "app", "istio-proxy")}},
+		{name: "without proxy", pods: []kubeApiCore.Pod{pod("c", kubeApiCore.PodRunning, "app")}},
+		{name: "not running", pods: []kubeApiCore.Pod{pod("c", kubeApiCore.PodPending, "istio-proxy")}},
+		{
+			name: "not acknowledged",
+			pods: []kubeApiCore.Pod{pod("b", kubeApiCore.PodRunning, "istio-proxy")},
+			err:  "proxy b.ns has not acknowledged the config last pushed to it",
+		},
+		{
+			name: "not connected",
+			pods: []kubeApiCore.Pod{pod("c", kubeApiCore.PodRunning, "istio-proxy")},
+			err:  "proxy c.ns is not connected to the control plane",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkSynced(c.pods, synced)
+			if c.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != c.err {
+				t.Fatalf("expected error %q, got %v", c.err, err)
+			}
+		})
+	}
+}
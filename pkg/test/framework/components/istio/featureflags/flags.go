@@ -0,0 +1,28 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featureflags
+
+import (
+	"flag"
+)
+
+var setsFromCommandline string
+
+// init registers the command-line flags that we can exposed for "go test".
+func init() {
+	flag.StringVar(&setsFromCommandline, "istio.test.featureFlags", "",
+		"Sets of pilot environment variables that tests run istiod with, as name:KEY=VALUE,KEY=VALUE separated by "+
+			"semicolons (e.g. 'slices:PILOT_USE_ENDPOINT_SLICE=true;nocache:PILOT_ENABLE_XDS_CACHE=false').")
+}
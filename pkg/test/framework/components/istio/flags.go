@@ -44,4 +44,9 @@ func init() {
 		"IstioOperator spec file. This can be an absolute path or relative to repository root.")
 	flag.StringVar(&helmValues, "istio.test.kube.helm.values", helmValues,
 		"Manual overrides for Helm values file. Only valid when deploying Istio.")
+	flag.StringVar(&debugDeployments, "istio.test.kube.debug", debugDeployments,
+		"Comma-separated deployments of the system namespace, such as istiod, to run under delve. The port forwarded "+
+			"to each is logged; attach with dlv connect. Requires istio.test.kube.debugImage and binaries built with DEBUG=1.")
+	flag.StringVar(&settingsFromCommandline.DebugImage, "istio.test.kube.debugImage", settingsFromCommandline.DebugImage,
+		"Image providing a statically linked dlv binary on its path, used by istio.test.kube.debug.")
 }
@@ -21,9 +21,15 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeRetry "k8s.io/client-go/util/retry"
+
+	"istio.io/istio/pilot/pkg/xds"
 	"istio.io/istio/pkg/config/protocol"
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/echo/client"
@@ -32,6 +38,7 @@ import (
 	"istio.io/istio/pkg/test/framework/components/environment/kube"
 	"istio.io/istio/pkg/test/framework/components/istio/ingress"
 	"istio.io/istio/pkg/test/framework/resource"
+	testKube "istio.io/istio/pkg/test/kube"
 	"istio.io/istio/pkg/test/scopes"
 	"istio.io/istio/pkg/test/util/retry"
 )
@@ -62,6 +69,8 @@ type ingressConfig struct {
 	ServiceName string
 	// Namespace the ingress can be found in
 	Namespace string
+	// SystemNamespace is the namespace of the control plane configured for Istio.
+	SystemNamespace string
 	// IstioLabel is the value for the "istio" label on the ingress kubernetes objects
 	IstioLabel string
 
@@ -81,7 +90,8 @@ func newIngress(ctx resource.Context, cfg ingressConfig) (i ingress.Instance) {
 		serviceName: cfg.ServiceName,
 		istioLabel:  cfg.IstioLabel,
 		namespace:   cfg.Namespace,
-		env:         ctx.Environment().(*kube.Environment),
+		systemNs:    cfg.SystemNamespace,
+		ctx:         ctx,
 		cluster:     ctx.Clusters().GetOrDefault(cfg.Cluster),
 	}
 	return c
@@ -91,19 +101,31 @@ type ingressImpl struct {
 	serviceName string
 	istioLabel  string
 	namespace   string
+	systemNs    string
 
-	env     *kube.Environment
+	ctx     resource.Context
 	cluster resource.Cluster
 
 	mu      sync.Mutex
 	clients map[clientKey]*http.Client
+
+	// originalService holds the Service before it was modified by ConfigureLoadBalancer.
+	originalService *kubeApiCore.Service
+	// originalProxyImage holds the proxy image before it was modified by SetProxyImage.
+	originalProxyImage string
+	// originalProxyArgs holds the proxy args before they were modified by SetConcurrency.
+	originalProxyArgs []string
 }
 
 // getAddressInner returns the external address for the given port. When we don't have support for LoadBalancer,
 // the returned net.Addr will have the externally reachable NodePort address and port.
 func (c *ingressImpl) getAddressInner(port int) (net.TCPAddr, error) {
+	env, err := kube.EnvironmentFrom(c.ctx)
+	if err != nil {
+		return net.TCPAddr{}, err
+	}
 	addr, err := retry.Do(func() (result interface{}, completed bool, err error) {
-		return getRemoteServiceAddress(c.env.Settings(), c.cluster, c.namespace, c.istioLabel, c.serviceName, port)
+		return getRemoteServiceAddress(env.Settings(), c.cluster, c.namespace, c.istioLabel, c.serviceName, port)
 	}, getAddressTimeout, getAddressDelay)
 	if addr != nil {
 		return addr.(net.TCPAddr), err
@@ -253,6 +275,57 @@ func (c *ingressImpl) CallOrFail(t test.Failer, options ingress.CallOptions) ing
 	return resp
 }
 
+func (c *ingressImpl) WaitForSync(opts ...retry.Option) error {
+	env, err := kube.EnvironmentFrom(c.ctx)
+	if err != nil {
+		return err
+	}
+	controlPlane := c.cluster
+	if !env.IsControlPlaneCluster(c.cluster) {
+		if controlPlane, err = env.GetControlPlaneCluster(c.cluster); err != nil {
+			return err
+		}
+	}
+	systemNs := c.systemNs
+	if kc, ok := controlPlane.(kube.Cluster); ok {
+		systemNs = kc.SystemNamespace(systemNs)
+	}
+	opts = append([]retry.Option{retry.Timeout(time.Minute), retry.Delay(time.Second)}, opts...)
+	return retry.UntilSuccess(func() error {
+		pods, err := c.cluster.PodsForSelector(context.TODO(), c.namespace, "istio="+c.istioLabel)
+		if err != nil {
+			return err
+		}
+		statuses, err := controlPlane.AllDiscoveryDo(context.TODO(), systemNs, "/debug/syncz")
+		if err != nil {
+			return err
+		}
+		synced := map[string]bool{}
+		for istiod, body := range statuses {
+			var proxies []xds.SyncStatus
+			if err := json.Unmarshal(body, &proxies); err != nil {
+				return fmt.Errorf("failed parsing the sync status of %s: %v", istiod, err)
+			}
+			for _, p := range proxies {
+				synced[p.ProxyID] = p.ClusterSent == p.ClusterAcked && p.ListenerSent == p.ListenerAcked &&
+					p.RouteSent == p.RouteAcked && p.EndpointSent == p.EndpointAcked
+			}
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			id := pod.Name + "." + pod.Namespace
+			if s, ok := synced[id]; !ok {
+				return fmt.Errorf("proxy %s is not connected to the control plane", id)
+			} else if !s {
+				return fmt.Errorf("proxy %s has not acknowledged the config last pushed to it", id)
+			}
+		}
+		return nil
+	}, opts...)
+}
+
 func (c *ingressImpl) ProxyStats() (map[string]int, error) {
 	var stats map[string]int
 	statsJSON, err := c.adminRequest("stats?format=json")
@@ -272,7 +345,11 @@ func (c *ingressImpl) CloseClients() {
 }
 
 func (c *ingressImpl) PodID(i int) (string, error) {
-	pods, err := c.env.KubeClusters[0].PodsForSelector(context.TODO(), c.namespace, "istio=ingressgateway")
+	env, err := kube.EnvironmentFrom(c.ctx)
+	if err != nil {
+		return "", err
+	}
+	pods, err := env.KubeClusters[0].PodsForSelector(context.TODO(), c.namespace, "istio=ingressgateway")
 	if err != nil {
 		return "", fmt.Errorf("unable to get ingressImpl gateway stats: %v", err)
 	}
@@ -284,14 +361,17 @@ func (c *ingressImpl) PodID(i int) (string, error) {
 
 // adminRequest makes a call to admin port at ingress gateway proxy and returns error on request failure.
 func (c *ingressImpl) adminRequest(path string) (string, error) {
-	pods, err := c.env.KubeClusters[0].PodsForSelector(context.TODO(), c.namespace, "istio=ingressgateway")
+	pods, err := c.cluster.PodsForSelector(context.TODO(), c.namespace, "istio="+c.istioLabel)
 	if err != nil {
 		return "", fmt.Errorf("unable to get ingressImpl gateway stats: %v", err)
 	}
+	if len(pods.Items) == 0 {
+		return "", fmt.Errorf("no pods found for gateway %s/%s", c.namespace, c.serviceName)
+	}
 	podNs, podName := pods.Items[0].Namespace, pods.Items[0].Name
 	// Exec onto the pod and make a curl request to the admin port
 	command := fmt.Sprintf("curl http://127.0.0.1:%d/%s", proxyAdminPort, path)
-	stdout, stderr, err := c.env.KubeClusters[0].PodExec(podName, podNs, proxyContainerName, command)
+	stdout, stderr, err := c.cluster.PodExec(podName, podNs, proxyContainerName, command)
 	return stdout + stderr, err
 }
 
@@ -323,3 +403,208 @@ func (c *ingressImpl) unmarshalStats(statsJSON string) (map[string]int, error) {
 	}
 	return statsMap, nil
 }
+
+func (c *ingressImpl) ConfigureLoadBalancer(options ingress.LoadBalancerOptions) error {
+	scopes.Framework.Infof("Configuring load balancer %s for %s/%s", options.Name, c.namespace, c.serviceName)
+	services := c.cluster.CoreV1().Services(c.namespace)
+	var (
+		previous  kubeApiCore.LoadBalancerStatus
+		unchanged bool
+	)
+	err := kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		svc, err := services.Get(context.TODO(), c.serviceName, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		previous = *svc.Status.LoadBalancer.DeepCopy()
+		before := svc.DeepCopy()
+		c.mu.Lock()
+		if c.originalService == nil {
+			c.originalService = svc.DeepCopy()
+		}
+		c.mu.Unlock()
+		if svc.Annotations == nil {
+			svc.Annotations = map[string]string{}
+		}
+		for k, v := range options.Annotations {
+			svc.Annotations[k] = v
+		}
+		svc.Spec.LoadBalancerIP = options.LoadBalancerIP
+		// The load balancer is only reprovisioned if the Service changes.
+		unchanged = len(before.Annotations) == len(svc.Annotations) && before.Spec.LoadBalancerIP == svc.Spec.LoadBalancerIP
+		for k, v := range options.Annotations {
+			if before.Annotations[k] != v {
+				unchanged = false
+			}
+		}
+		if unchanged {
+			return nil
+		}
+		_, err = services.Update(context.TODO(), svc, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed configuring load balancer %s: %v", options.Name, err)
+	}
+
+	return retry.UntilSuccess(func() error {
+		svc, err := services.Get(context.TODO(), c.serviceName, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if err := options.CheckStatus(previous, svc.Status.LoadBalancer, unchanged); err != nil {
+			return err
+		}
+		if options.Internal {
+			// The address is only reachable from within the VPC, which the test may not run in.
+			return nil
+		}
+		if len(svc.Spec.Ports) == 0 {
+			return fmt.Errorf("service %s/%s has no ports", c.namespace, c.serviceName)
+		}
+		return ingress.CheckReachable(svc.Status.LoadBalancer, svc.Spec.Ports[0].Port)
+	}, getAddressTimeout, getAddressDelay)
+}
+
+func (c *ingressImpl) ConfigureLoadBalancerOrFail(t test.Failer, options ingress.LoadBalancerOptions) {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := c.ResetLoadBalancer(); err != nil {
+			scopes.Framework.Errorf("failed resetting load balancer for %s/%s: %v", c.namespace, c.serviceName, err)
+		}
+	})
+	if err := c.ConfigureLoadBalancer(options); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *ingressImpl) ResetLoadBalancer() error {
+	c.mu.Lock()
+	original := c.originalService
+	c.originalService = nil
+	c.mu.Unlock()
+	if original == nil {
+		return nil
+	}
+
+	services := c.cluster.CoreV1().Services(c.namespace)
+	return kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		svc, err := services.Get(context.TODO(), c.serviceName, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		svc.Annotations = original.Annotations
+		svc.Spec.LoadBalancerIP = original.Spec.LoadBalancerIP
+		_, err = services.Update(context.TODO(), svc, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+}
+
+func (c *ingressImpl) SetProxyImage(image string) error {
+	c.mu.Lock()
+	original := c.originalProxyImage
+	c.mu.Unlock()
+	if image == "" {
+		if original == "" {
+			return nil
+		}
+		image = original
+	}
+
+	// The gateway Deployment shares the name of its Service.
+	scopes.Framework.Infof("Setting proxy image of %s/%s to %s", c.namespace, c.serviceName, image)
+	previous, err := testKube.SetContainerImage(c.cluster, c.namespace, c.serviceName, proxyContainerName, image)
+	if err != nil {
+		return fmt.Errorf("failed setting proxy image of %s/%s: %v", c.namespace, c.serviceName, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if image == original {
+		c.originalProxyImage = ""
+	} else if c.originalProxyImage == "" {
+		c.originalProxyImage = previous
+	}
+	return nil
+}
+
+func (c *ingressImpl) SetProxyImageOrFail(t test.Failer, image string) {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := c.SetProxyImage(""); err != nil {
+			scopes.Framework.Errorf("failed restoring proxy image of %s/%s: %v", c.namespace, c.serviceName, err)
+		}
+	})
+	if err := c.SetProxyImage(image); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *ingressImpl) SetConcurrency(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid concurrency %d", n)
+	}
+	c.mu.Lock()
+	original := c.originalProxyArgs
+	c.mu.Unlock()
+	if n == 0 && original == nil {
+		return nil
+	}
+
+	var args []string
+	if n == 0 {
+		args = original
+	} else {
+		d, err := c.cluster.AppsV1().Deployments(c.namespace).Get(context.TODO(), c.serviceName, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, container := range d.Spec.Template.Spec.Containers {
+			if container.Name == proxyContainerName {
+				args = withConcurrency(container.Args, n)
+			}
+		}
+	}
+
+	scopes.Framework.Infof("Setting proxy concurrency of %s/%s to %d", c.namespace, c.serviceName, n)
+	previous, err := testKube.SetContainerArgs(c.cluster, c.namespace, c.serviceName, proxyContainerName, args)
+	if err != nil {
+		return fmt.Errorf("failed setting proxy concurrency of %s/%s: %v", c.namespace, c.serviceName, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n == 0 {
+		c.originalProxyArgs = nil
+	} else if c.originalProxyArgs == nil {
+		// Distinguish the original args from unset, even if there were none.
+		c.originalProxyArgs = append([]string{}, previous...)
+	}
+	return nil
+}
+
+func (c *ingressImpl) SetConcurrencyOrFail(t test.Failer, n int) {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := c.SetConcurrency(0); err != nil {
+			scopes.Framework.Errorf("failed restoring proxy concurrency of %s/%s: %v", c.namespace, c.serviceName, err)
+		}
+	})
+	if err := c.SetConcurrency(n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// withConcurrency returns the pilot-agent args with the --concurrency flag set to n.
+func withConcurrency(args []string, n int) []string {
+	out := make([]string, 0, len(args)+1)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--concurrency":
+			// Skip the value too.
+			i++
+		case strings.HasPrefix(args[i], "--concurrency="):
+		default:
+			out = append(out, args[i])
+		}
+	}
+	return append(out, "--concurrency="+strconv.Itoa(n))
+}
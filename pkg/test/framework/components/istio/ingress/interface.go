@@ -23,6 +23,7 @@ import (
 	"istio.io/istio/pkg/test"
 	"istio.io/istio/pkg/test/echo/client"
 	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
 )
 
 // CallType defines ingress gateway type
@@ -95,6 +96,10 @@ type Instance interface {
 	// and the other method will likely be removed in the future.
 	CallEcho(options echo.CallOptions) (client.ParsedResponses, error)
 
+	// WaitForSync waits until every proxy of the ingress gateway has acknowledged the config last pushed to it by the
+	// control plane, as reported by istioctl proxy-status.
+	WaitForSync(opts ...retry.Option) error
+
 	// ProxyStats returns proxy stats, or error if failure happens.
 	ProxyStats() (map[string]int, error)
 
@@ -104,6 +109,30 @@ type Instance interface {
 	// PodID returns the name of the ingress gateway pod of index i. Returns error if failed to get the pod
 	// or the index is out of boundary.
 	PodID(i int) (string, error)
+
+	// ConfigureLoadBalancer applies the options to the ingress gateway Service and waits until the provisioned load
+	// balancer satisfies them and, unless it is internal, is reachable. The Service can be returned to its original
+	// state with ResetLoadBalancer.
+	ConfigureLoadBalancer(options LoadBalancerOptions) error
+	// ConfigureLoadBalancerOrFail calls ConfigureLoadBalancer and fails the test if it returns an error. The Service
+	// is reset when the test completes.
+	ConfigureLoadBalancerOrFail(t test.Failer, options LoadBalancerOptions)
+	// ResetLoadBalancer restores the ingress gateway Service to its state before ConfigureLoadBalancer was called.
+	ResetLoadBalancer() error
+
+	// SetProxyImage runs the ingress gateway with the given proxy image, independent of the version of the control
+	// plane, and waits for the rollout to complete. An empty image restores the original image.
+	SetProxyImage(image string) error
+	// SetProxyImageOrFail calls SetProxyImage and fails the test if it returns an error. The original image is
+	// restored when the test completes.
+	SetProxyImageOrFail(t test.Failer, image string)
+
+	// SetConcurrency runs the ingress gateway proxy with the given number of worker threads, and waits for the
+	// rollout to complete. Zero restores the original concurrency.
+	SetConcurrency(n int) error
+	// SetConcurrencyOrFail calls SetConcurrency and fails the test if it returns an error. The original concurrency
+	// is restored when the test completes.
+	SetConcurrencyOrFail(t test.Failer, n int)
 }
 
 // CallResponse is the result of a call made through Istio Instance.
@@ -0,0 +1,172 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+)
+
+// LoadBalancerOptions configures the cloud load balancer provisioned for the ingress gateway Service.
+type LoadBalancerOptions struct {
+	// Name of the configuration, for use as a test name.
+	Name string
+
+	// Annotations to add to the Service. These are typically provider-specific.
+	Annotations map[string]string
+
+	// LoadBalancerIP requests a static IP for the load balancer. The IP must be reserved with the provider.
+	LoadBalancerIP string
+
+	// Internal indicates that the annotations request a load balancer that is only reachable within the VPC. The
+	// resulting address is expected to be private, and is not checked to be reachable, as tests may run outside of
+	// the VPC; tests using it must run within the VPC to reach it.
+	Internal bool
+}
+
+var (
+	// AWSNLB requests an AWS Network Load Balancer, rather than a Classic Load Balancer.
+	AWSNLB = LoadBalancerOptions{
+		Name: "aws-nlb",
+		Annotations: map[string]string{
+			"service.beta.kubernetes.io/aws-load-balancer-type": "nlb",
+		},
+	}
+
+	// AWSInternal requests an internal AWS load balancer.
+	AWSInternal = LoadBalancerOptions{
+		Name: "aws-internal",
+		Annotations: map[string]string{
+			"service.beta.kubernetes.io/aws-load-balancer-internal": "true",
+		},
+		Internal: true,
+	}
+
+	// GCPInternal requests an internal GCP TCP/UDP load balancer.
+	GCPInternal = LoadBalancerOptions{
+		Name: "gcp-internal",
+		Annotations: map[string]string{
+			"networking.gke.io/load-balancer-type": "Internal",
+		},
+		Internal: true,
+	}
+
+	// AzureInternal requests an internal Azure load balancer.
+	AzureInternal = LoadBalancerOptions{
+		Name: "azure-internal",
+		Annotations: map[string]string{
+			"service.beta.kubernetes.io/azure-load-balancer-internal": "true",
+		},
+		Internal: true,
+	}
+
+	// LoadBalancerMatrix contains the common load balancer configurations for each provider.
+	LoadBalancerMatrix = map[string][]LoadBalancerOptions{
+		"aws":   {AWSNLB, AWSInternal},
+		"gcp":   {GCPInternal},
+		"azure": {AzureInternal},
+	}
+)
+
+// StaticIP returns options requesting the given static IP for the load balancer.
+func StaticIP(ip string) LoadBalancerOptions {
+	return LoadBalancerOptions{
+		Name:           "static-ip",
+		LoadBalancerIP: ip,
+	}
+}
+
+// CheckStatus verifies that the load balancer status of the Service is consistent with the options. Unless the
+// load balancer is unchanged, its addresses must have been assigned since the previous status, so that the status of
+// the load balancer being replaced is not mistaken for that of the new one.
+func (o LoadBalancerOptions) CheckStatus(previous, status kubeApiCore.LoadBalancerStatus, unchanged bool) error {
+	if len(status.Ingress) == 0 {
+		return fmt.Errorf("load balancer %s has not been provisioned", o.Name)
+	}
+	if !unchanged {
+		old := map[kubeApiCore.LoadBalancerIngress]bool{}
+		for _, ing := range previous.Ingress {
+			old[ing] = true
+		}
+		for _, ing := range status.Ingress {
+			if old[ing] {
+				return fmt.Errorf("load balancer %s still has the previous address %s", o.Name, address(ing))
+			}
+		}
+	}
+	if o.LoadBalancerIP != "" {
+		for _, ing := range status.Ingress {
+			if ing.IP == o.LoadBalancerIP {
+				return nil
+			}
+		}
+		return fmt.Errorf("load balancer %s was not assigned the requested IP %s: %v", o.Name, o.LoadBalancerIP, status.Ingress)
+	}
+	if o.Internal {
+		for _, ing := range status.Ingress {
+			// Some providers (e.g. AWS) only report a hostname, which cannot be checked here.
+			if ing.IP != "" && !isPrivateIP(net.ParseIP(ing.IP)) {
+				return fmt.Errorf("internal load balancer %s was assigned public IP %s", o.Name, ing.IP)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckReachable verifies that a TCP connection can be made to each address of the load balancer on the port.
+func CheckReachable(status kubeApiCore.LoadBalancerStatus, port int32) error {
+	for _, ing := range status.Ingress {
+		addr := net.JoinHostPort(address(ing), strconv.Itoa(int(port)))
+		conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+		if err != nil {
+			return fmt.Errorf("load balancer address %s is not reachable: %v", addr, err)
+		}
+		_ = conn.Close()
+	}
+	return nil
+}
+
+// address returns the IP of the load balancer ingress, or its hostname for providers only reporting one.
+func address(ing kubeApiCore.LoadBalancerIngress) string {
+	if ing.IP != "" {
+		return ing.IP
+	}
+	return ing.Hostname
+}
+
+var privateNetworks = func() []*net.IPNet {
+	var out []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		out = append(out, n)
+	}
+	return out
+}()
+
+func isPrivateIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range privateNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingress
+
+import (
+	"net"
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+)
+
+func status(ips ...string) kubeApiCore.LoadBalancerStatus {
+	var out kubeApiCore.LoadBalancerStatus
+	for _, ip := range ips {
+		out.Ingress = append(out.Ingress, kubeApiCore.LoadBalancerIngress{IP: ip})
+	}
+	return out
+}
+
+func TestCheckStatus(t *testing.T) {
+	cases := []struct {
+		name      string
+		options   LoadBalancerOptions
+		previous  kubeApiCore.LoadBalancerStatus
+		status    kubeApiCore.LoadBalancerStatus
+		unchanged bool
+		wantErr   bool
+	}{
+		{"not provisioned", AWSNLB, status("1.2.3.4"), status(), false, true},
+		{"previous address", AWSNLB, status("1.2.3.4"), status("1.2.3.4"), false, true},
+		{"new address", AWSNLB, status("1.2.3.4"), status("5.6.7.8"), false, false},
+		{"unchanged", AWSNLB, status("1.2.3.4"), status("1.2.3.4"), true, false},
+		{"internal public", GCPInternal, status("1.2.3.4"), status("5.6.7.8"), false, true},
+		{"internal private", GCPInternal, status("1.2.3.4"), status("10.0.0.1"), false, false},
+		{"static ip", StaticIP("5.6.7.8"), status("1.2.3.4"), status("5.6.7.8"), false, false},
+		{"other ip", StaticIP("5.6.7.8"), status("1.2.3.4"), status("9.9.9.9"), false, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.options.CheckStatus(c.previous, c.status, c.unchanged)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got err %v, want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckReachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := int32(l.Addr().(*net.TCPAddr).Port)
+	if err := CheckReachable(status("127.0.0.1"), port); err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+	if err := CheckReachable(status("127.0.0.1"), port); err == nil {
+		t.Fatal("expected closed port to be unreachable")
+	}
+}
@@ -34,12 +34,26 @@ type Instance interface {
 	// CustomIngressFor returns an ingress with a specific service name and "istio" label used for reaching workloads
 	// in the given cluster.
 	CustomIngressFor(cluster resource.Cluster, serviceName, istioLabel string) ingress.Instance
+	// EastWestGatewayFor returns the east-west gateway through which workloads in other networks reach the given
+	// cluster.
+	EastWestGatewayFor(cluster resource.Cluster) ingress.Instance
 
 	// RemoteDiscoveryAddressFor returns the external address of the discovery server that controls
 	// the given cluster. This allows access to the discovery server from
 	// outside its cluster.
 	RemoteDiscoveryAddressFor(cluster resource.Cluster) (net.TCPAddr, error)
 
+	// CreateRemoteSecret creates the remote secret for the source cluster and applies it to each of the
+	// targets, allowing the control planes in those clusters to discover endpoints in source. If no targets
+	// are given, the secret is applied to all control plane clusters other than source.
+	CreateRemoteSecret(source resource.Cluster, targets ...resource.Cluster) error
+	// DeleteRemoteSecret deletes the remote secret for the source cluster from each of the targets.
+	DeleteRemoteSecret(source resource.Cluster, targets ...resource.Cluster) error
+	// RotateRemoteCredentials issues new credentials for accessing the source cluster's API server, replaces
+	// the remote secret in each of the targets with one using the new credentials, and then revokes the
+	// previous credentials. This simulates an operator rotating expiring multicluster credentials.
+	RotateRemoteCredentials(source resource.Cluster, targets ...resource.Cluster) error
+
 	Settings() Config
 }
 
@@ -118,6 +132,10 @@ func Deploy(ctx resource.Context, cfg *Config) (i Instance, err error) {
 		}
 	}()
 
-	i, err = deploy(ctx, ctx.Environment().(*kube.Environment), *cfg)
+	env, err := kube.EnvironmentFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i, err = deploy(ctx, env, *cfg)
 	return
 }
@@ -38,6 +38,7 @@ import (
 	"istio.io/istio/istioctl/pkg/multicluster"
 	pkgAPI "istio.io/istio/operator/pkg/apis/istio/v1alpha1"
 	"istio.io/istio/pilot/pkg/leaderelection"
+	istioKube "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/test/cert/ca"
 	testenv "istio.io/istio/pkg/test/env"
 	"istio.io/istio/pkg/test/framework/components/environment/kube"
@@ -76,6 +77,11 @@ type operatorComponent struct {
 	// The key is the cluster name
 	installManifest map[string][]string
 	ingress         map[resource.ClusterIndex]map[string]ingress.Instance
+	// remoteReaders holds the service account currently used by remote secrets, keyed by cluster name.
+	// Clusters that are not present use multicluster.DefaultServiceAccountName.
+	remoteReaders map[string]string
+	// debugForwarders forward local ports to delve in the deployments debugged with Config.Debug.
+	debugForwarders []istioKube.PortForwarder
 }
 
 var _ io.Closer = &operatorComponent{}
@@ -125,6 +131,10 @@ func (i *operatorComponent) IngressFor(cluster resource.Cluster) ingress.Instanc
 	return i.CustomIngressFor(cluster, defaultIngressServiceName, defaultIngressIstioLabel)
 }
 
+func (i *operatorComponent) EastWestGatewayFor(cluster resource.Cluster) ingress.Instance {
+	return i.CustomIngressFor(cluster, eastWestIngressServiceName, eastWestIngressIstioLabel)
+}
+
 func (i *operatorComponent) CustomIngressFor(cluster resource.Cluster, serviceName, istioLabel string) ingress.Instance {
 	i.mu.Lock()
 	defer i.mu.Unlock()
@@ -132,11 +142,18 @@ func (i *operatorComponent) CustomIngressFor(cluster resource.Cluster, serviceNa
 		i.ingress[cluster.Index()] = map[string]ingress.Instance{}
 	}
 	if _, ok := i.ingress[cluster.Index()][istioLabel]; !ok {
+		// The gateways of virtual clusters other than the first are deployed to their own system namespace.
+		ns := i.settings.IngressNamespace
+		systemNamespace := systemNamespaceFor(i.settings, cluster)
+		if systemNamespace != i.settings.SystemNamespace {
+			ns = systemNamespace
+		}
 		i.ingress[cluster.Index()][istioLabel] = newIngress(i.ctx, ingressConfig{
-			Namespace:   i.settings.IngressNamespace,
-			Cluster:     cluster,
-			ServiceName: serviceName,
-			IstioLabel:  istioLabel,
+			Namespace:       ns,
+			SystemNamespace: systemNamespace,
+			Cluster:         cluster,
+			ServiceName:     serviceName,
+			IstioLabel:      istioLabel,
 		})
 	}
 	return i.ingress[cluster.Index()][istioLabel]
@@ -145,6 +162,7 @@ func (i *operatorComponent) CustomIngressFor(cluster resource.Cluster, serviceNa
 func (i *operatorComponent) Close() (err error) {
 	scopes.Framework.Infof("=== BEGIN: Cleanup Istio [Suite=%s] ===", i.ctx.Settings().TestID)
 	defer scopes.Framework.Infof("=== DONE: Cleanup Istio [Suite=%s] ===", i.ctx.Settings().TestID)
+	i.closeDebugForwarders()
 	if i.settings.DeployIstio {
 		for _, cluster := range i.environment.KubeClusters {
 			for _, manifest := range i.installManifest[cluster.Name()] {
@@ -212,6 +230,7 @@ func deploy(ctx resource.Context, env *kube.Environment, cfg Config) (Instance,
 		ctx:             ctx,
 		installManifest: map[string][]string{},
 		ingress:         map[resource.ClusterIndex]map[string]ingress.Instance{},
+		remoteReaders:   map[string]string{},
 	}
 	i.id = ctx.TrackResource(i)
 
@@ -298,6 +317,16 @@ func deploy(ctx resource.Context, env *kube.Environment, cfg Config) (Instance,
 		}
 	}
 
+	if len(cfg.Debug) > 0 {
+		for _, cluster := range env.KubeClusters {
+			if env.IsControlPlaneCluster(cluster) {
+				if err := i.enableDebug(cluster); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
 	return i, nil
 }
 
@@ -582,12 +611,37 @@ func (i *operatorComponent) generateCommonInstallSettings(cfg Config, cluster re
 		"--manifests", filepath.Join(testenv.IstioSrc, "manifests"),
 	}
 
+	if rev := revisionFor(cfg, cluster); rev != "" {
+		installSettings = append(installSettings, "--set", "revision="+rev)
+	}
+	if ns := systemNamespaceFor(cfg, cluster); ns != DefaultSystemNamespace {
+		installSettings = append(installSettings,
+			"--set", "namespace="+ns,
+			"--set", "values.global.istioNamespace="+ns)
+	}
+
 	if i.environment.IsMultinetwork() && cluster.NetworkName() != "" {
 		installSettings = append(installSettings,
 			"--set", "values.global.meshID="+meshID,
 			"--set", "values.global.network="+cluster.NetworkName())
 	}
 
+	if i.environment.Settings().Autopilot {
+		installSettings = append(installSettings, autopilotInstallSettings...)
+	}
+
+	if i.environment.Settings().EKSRoleARN != "" {
+		// Serve the token exchange API from the agent so workloads can exchange their projected IRSA tokens.
+		installSettings = append(installSettings, "--set", fmt.Sprintf("values.global.sts.servicePort=%d", stsServicePort))
+	}
+
+	if c, ok := cluster.(kube.Cluster); ok && c.IsK3s() {
+		// k3s keeps its CNI configuration and binaries under its own data directory.
+		installSettings = append(installSettings,
+			"--set", "values.cni.cniConfDir="+kube.K3sCNIConfDir,
+			"--set", "values.cni.cniBinDir="+kube.K3sCNIBinDir)
+	}
+
 	// Include all user-specified values.
 	for k, v := range cfg.Values {
 		installSettings = append(installSettings, "--set", fmt.Sprintf("values.%s=%s", k, v))
@@ -595,6 +649,42 @@ func (i *operatorComponent) generateCommonInstallSettings(cfg Config, cluster re
 	return installSettings, nil
 }
 
+// revisionFor returns the revision of the control plane of the cluster. Virtual clusters sharing a physical cluster
+// each run their own revision.
+func revisionFor(cfg Config, cluster resource.Cluster) string {
+	kc, ok := cluster.(kube.Cluster)
+	if !ok || kc.Revision() == "" {
+		return cfg.Revision
+	}
+	if cfg.Revision != "" {
+		return cfg.Revision + "-" + kc.Revision()
+	}
+	return kc.Revision()
+}
+
+// systemNamespaceFor returns the namespace of the control plane of the cluster. Virtual clusters sharing a physical
+// cluster each have their own.
+func systemNamespaceFor(cfg Config, cluster resource.Cluster) string {
+	if kc, ok := cluster.(kube.Cluster); ok {
+		return kc.SystemNamespace(cfg.SystemNamespace)
+	}
+	return cfg.SystemNamespace
+}
+
+// autopilotInstallSettings are the install settings required by GKE Autopilot. Privileged istio-init containers are
+// not allowed, so traffic redirection is set up by the CNI plugin, which must run in kube-system and use the GKE
+// CNI binary directory. Proxy resource limits match the requests, since Autopilot rewrites them to be equal anyway.
+var autopilotInstallSettings = []string{
+	"--set", "components.cni.enabled=true",
+	"--set", "components.cni.namespace=kube-system",
+	"--set", "values.cni.cniBinDir=/home/kubernetes/bin",
+	"--set", "values.global.proxy.resources.limits.cpu=100m",
+	"--set", "values.global.proxy.resources.limits.memory=128Mi",
+}
+
+// stsServicePort is the port on which the Istio agent serves the Security Token Service (STS) API.
+const stsServicePort = 15463
+
 func isCentralIstio(env *kube.Environment, cfg Config) bool {
 	if env.IsMulticluster() && cfg.Values["global.centralIstiod"] == "true" {
 		return true
@@ -642,6 +732,18 @@ func meshNetworkSettings(cfg Config, environment *kube.Environment) *meshAPI.Mes
 			Endpoints: make([]*meshAPI.Network_NetworkEndpoints, len(clusters)),
 			Gateways:  defaultGateways,
 		}
+		// Each virtual cluster is alone on its network, with its east-west gateway in its own system namespace. The
+		// network of its endpoints cannot be told from their addresses, since the virtual clusters share the pod
+		// CIDRs of the physical cluster, so its workloads are labeled with their network instead.
+		if c := clusters[0]; len(clusters) == 1 && c.IsVirtual() && systemNamespaceFor(cfg, *c) != cfg.SystemNamespace {
+			ns := systemNamespaceFor(cfg, *c)
+			network.Gateways = []*meshAPI.Network_IstioNetworkGateway{{
+				Gw: &meshAPI.Network_IstioNetworkGateway_RegistryServiceName{
+					RegistryServiceName: eastWestIngressServiceName + "." + ns + ".svc.cluster.local",
+				},
+				Port: 15443,
+			}}
+		}
 		for i, cluster := range clusters {
 			network.Endpoints[i] = &meshAPI.Network_NetworkEndpoints{
 				Ne: &meshAPI.Network_NetworkEndpoints_FromRegistry{
@@ -687,8 +789,19 @@ func (i *operatorComponent) configureDirectAPIServiceAccessForCluster(ctx resour
 	if err != nil {
 		return fmt.Errorf("failed creating remote secret for cluster %s: %v", cluster.Name(), err)
 	}
-	if err := ctx.Config(env.ControlPlaneClusters(cluster)...).ApplyYAML(cfg.SystemNamespace, secret); err != nil {
-		return fmt.Errorf("failed applying remote secret to clusters: %v", err)
+	for _, cp := range env.ControlPlaneClusters(cluster) {
+		// Virtual clusters share the API server, but each of their control planes only reads the remote secrets of
+		// its own system namespace. Through them, endpoints are attributed to the virtual cluster they belong to.
+		ns := systemNamespaceFor(cfg, cp)
+		cpSecret := secret
+		if ns != cfg.SystemNamespace {
+			if cpSecret, err = yml.ApplyNamespace(secret, ns); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Config(cp).ApplyYAML(ns, cpSecret); err != nil {
+			return fmt.Errorf("failed applying remote secret to cluster %s: %v", cp.Name(), err)
+		}
 	}
 	return nil
 }
@@ -717,7 +830,9 @@ func (i *operatorComponent) createServiceAccount(ctx resource.Context, cluster r
 	}, istioCtl, cluster.Name())
 }
 
-func createRemoteSecret(ctx resource.Context, cluster resource.Cluster, cfg Config) (string, error) {
+// createRemoteSecret generates the remote secret for the given cluster using istioctl. Any additional
+// args are passed through to the create-remote-secret command.
+func createRemoteSecret(ctx resource.Context, cluster resource.Cluster, cfg Config, args ...string) (string, error) {
 	istioCtl, err := istioctl.New(ctx, istioctl.Config{
 		Cluster: cluster,
 	})
@@ -729,6 +844,7 @@ func createRemoteSecret(ctx resource.Context, cluster resource.Cluster, cfg Conf
 		"--name", cluster.Name(),
 		"--namespace", cfg.SystemNamespace,
 	}
+	cmd = append(cmd, args...)
 
 	scopes.Framework.Infof("Creating remote secret for cluster cluster %s %v", cluster.Name(), cmd)
 	out, _, err := istioCtl.Invoke(cmd)
@@ -776,17 +892,18 @@ func deployCACerts(workDir string, env *kube.Environment, cfg Config) error {
 		}
 
 		// Create the system namespace.
+		systemNamespace := systemNamespaceFor(cfg, cluster)
 		if _, err := cluster.CoreV1().Namespaces().Create(context.TODO(), &kubeApiCore.Namespace{
 			ObjectMeta: kubeApiMeta.ObjectMeta{
-				Name: cfg.SystemNamespace,
+				Name: systemNamespace,
 			},
 		}, kubeApiMeta.CreateOptions{}); err != nil {
 			scopes.Framework.Infof("failed creating namespace %s on cluster %s. This can happen when deploying "+
-				"multiple control planes. Error: %v", cfg.SystemNamespace, cluster.Name(), err)
+				"multiple control planes. Error: %v", systemNamespace, cluster.Name(), err)
 		}
 
 		// Create the secret for the cacerts.
-		if _, err := cluster.CoreV1().Secrets(cfg.SystemNamespace).Create(context.TODO(), secret,
+		if _, err := cluster.CoreV1().Secrets(systemNamespace).Create(context.TODO(), secret,
 			kubeApiMeta.CreateOptions{}); err != nil {
 			scopes.Framework.Infof("failed to create CA secrets on cluster %s. This can happen when deploying "+
 				"multiple control planes. Error: %v", cluster.Name(), err)
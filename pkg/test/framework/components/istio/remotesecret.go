@@ -0,0 +1,171 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/multicluster"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	remoteSecretPrefix = "istio-remote-secret-"
+
+	remoteReaderTemplate = `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: %[1]s-%[2]s
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: istio-reader-%[2]s
+subjects:
+- kind: ServiceAccount
+  name: %[1]s
+  namespace: %[2]s
+`
+)
+
+// RemoteSecretName returns the name of the remote secret generated for the given cluster.
+func RemoteSecretName(cluster resource.Cluster) string {
+	return remoteSecretPrefix + cluster.Name()
+}
+
+func (i *operatorComponent) CreateRemoteSecret(source resource.Cluster, targets ...resource.Cluster) error {
+	return i.applyRemoteSecret(source, targets, i.remoteReaderArgs(source)...)
+}
+
+func (i *operatorComponent) DeleteRemoteSecret(source resource.Cluster, targets ...resource.Cluster) error {
+	var err error
+	for _, target := range i.remoteSecretTargets(source, targets) {
+		scopes.Framework.Infof("Deleting remote secret for cluster %s from cluster %s", source.Name(), target.Name())
+		e := target.CoreV1().Secrets(i.settings.SystemNamespace).Delete(context.TODO(), RemoteSecretName(source),
+			kubeApiMeta.DeleteOptions{})
+		if e != nil && !errors.IsNotFound(e) {
+			err = multierror.Append(err, fmt.Errorf("failed deleting remote secret for %s from %s: %v",
+				source.Name(), target.Name(), e))
+		}
+	}
+	return err
+}
+
+func (i *operatorComponent) RotateRemoteCredentials(source resource.Cluster, targets ...resource.Cluster) error {
+	i.mu.Lock()
+	previous, ok := i.remoteReaders[source.Name()]
+	if !ok {
+		previous = multicluster.DefaultServiceAccountName
+	}
+	// Each rotation saves a manifest for cleanup, so the manifest count yields a unique name.
+	next := fmt.Sprintf("%s-%d", multicluster.DefaultServiceAccountName, len(i.installManifest[source.Name()]))
+	i.mu.Unlock()
+
+	// Create a new reader service account with the same permissions as the default one.
+	readerYAML := fmt.Sprintf(remoteReaderTemplate, next, i.settings.SystemNamespace)
+	i.saveManifestForCleanup(source.Name(), readerYAML)
+	if err := i.ctx.Config(source).ApplyYAML(i.settings.SystemNamespace, readerYAML); err != nil {
+		return fmt.Errorf("failed creating service account %s in %s: %v", next, source.Name(), err)
+	}
+	// create-remote-secret requires the token for the service account to have been generated.
+	if err := retry.UntilSuccess(func() error {
+		sa, err := source.CoreV1().ServiceAccounts(i.settings.SystemNamespace).Get(context.TODO(), next,
+			kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if len(sa.Secrets) == 0 {
+			return fmt.Errorf("no token generated for service account %s", next)
+		}
+		return nil
+	}, componentDeployTimeout, componentDeployDelay); err != nil {
+		return err
+	}
+
+	scopes.Framework.Infof("Rotating credentials for cluster %s: %s -> %s", source.Name(), previous, next)
+	if err := i.applyRemoteSecret(source, targets, "--service-account", next); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	i.remoteReaders[source.Name()] = next
+	i.mu.Unlock()
+
+	return i.revokeServiceAccountTokens(source, previous)
+}
+
+// revokeServiceAccountTokens deletes the token secrets of the given service account, invalidating any
+// credentials that were issued from them. Kubernetes will generate a new token for the service account.
+func (i *operatorComponent) revokeServiceAccountTokens(cluster resource.Cluster, serviceAccount string) error {
+	sa, err := cluster.CoreV1().ServiceAccounts(i.settings.SystemNamespace).Get(context.TODO(), serviceAccount,
+		kubeApiMeta.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed revoking credentials for %s in %s: %v", serviceAccount, cluster.Name(), err)
+	}
+	for _, s := range sa.Secrets {
+		scopes.Framework.Infof("Revoking token %s for %s in %s", s.Name, serviceAccount, cluster.Name())
+		if err := cluster.CoreV1().Secrets(i.settings.SystemNamespace).Delete(context.TODO(), s.Name,
+			kubeApiMeta.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed revoking token %s in %s: %v", s.Name, cluster.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (i *operatorComponent) applyRemoteSecret(source resource.Cluster, targets []resource.Cluster, args ...string) error {
+	secret, err := createRemoteSecret(i.ctx, source, i.settings, args...)
+	if err != nil {
+		return err
+	}
+	targets = i.remoteSecretTargets(source, targets)
+	if len(targets) == 0 {
+		return fmt.Errorf("no target clusters for remote secret of cluster %s", source.Name())
+	}
+	if err := i.ctx.Config(targets...).ApplyYAML(i.settings.SystemNamespace, secret); err != nil {
+		return fmt.Errorf("failed applying remote secret for %s: %v", source.Name(), err)
+	}
+	return nil
+}
+
+// remoteReaderArgs returns the create-remote-secret args needed to use the current reader service account for
+// the cluster, if its credentials have been rotated.
+func (i *operatorComponent) remoteReaderArgs(cluster resource.Cluster) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if sa, ok := i.remoteReaders[cluster.Name()]; ok {
+		return []string{"--service-account", sa}
+	}
+	return nil
+}
+
+// remoteSecretTargets defaults to all control plane clusters other than source if no targets are given.
+func (i *operatorComponent) remoteSecretTargets(source resource.Cluster, targets []resource.Cluster) []resource.Cluster {
+	if len(targets) > 0 {
+		return targets
+	}
+	return i.environment.ControlPlaneClusters(source)
+}
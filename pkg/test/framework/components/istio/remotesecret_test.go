@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istio
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func fakeCluster(name string, objects ...*kubeApiCore.Secret) resource.Cluster {
+	client := kube.NewFakeClient()
+	for _, o := range objects {
+		if _, err := client.CoreV1().Secrets(o.Namespace).Create(context.TODO(), o, kubeApiMeta.CreateOptions{}); err != nil {
+			panic(err)
+		}
+	}
+	return resource.FakeCluster{ExtendedClient: client.(kube.ExtendedClient), NameValue: name}
+}
+
+func secret(name string) *kubeApiCore.Secret {
+	return &kubeApiCore.Secret{ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Namespace: "istio-system"}}
+}
+
+func TestDeleteRemoteSecret(t *testing.T) {
+	source := fakeCluster("source")
+	withSecret := fakeCluster("with-secret", secret(RemoteSecretName(source)), secret("other"))
+	withoutSecret := fakeCluster("without-secret")
+	i := &operatorComponent{settings: Config{SystemNamespace: "istio-system"}}
+
+	// A target without the secret is not an error.
+	if err := i.DeleteRemoteSecret(source, withSecret, withoutSecret); err != nil {
+		t.Fatal(err)
+	}
+	_, err := withSecret.CoreV1().Secrets("istio-system").Get(context.TODO(), "istio-remote-secret-source", kubeApiMeta.GetOptions{})
+	if !errors.IsNotFound(err) {
+		t.Fatalf("expected the remote secret to be deleted, got %v", err)
+	}
+	if _, err := withSecret.CoreV1().Secrets("istio-system").Get(context.TODO(), "other", kubeApiMeta.GetOptions{}); err != nil {
+		t.Fatalf("expected other secrets to be kept: %v", err)
+	}
+}
+
+func TestRevokeServiceAccountTokens(t *testing.T) {
+	cluster := fakeCluster("cluster", secret("reader-token-1"), secret("reader-token-2"), secret("other"))
+	sa := &kubeApiCore.ServiceAccount{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "reader", Namespace: "istio-system"},
+		Secrets:    []kubeApiCore.ObjectReference{{Name: "reader-token-1"}, {Name: "reader-token-2"}, {Name: "missing"}},
+	}
+	if _, err := cluster.CoreV1().ServiceAccounts("istio-system").Create(context.TODO(), sa, kubeApiMeta.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	i := &operatorComponent{settings: Config{SystemNamespace: "istio-system"}}
+
+	if err := i.revokeServiceAccountTokens(cluster, "reader"); err != nil {
+		t.Fatal(err)
+	}
+	secrets, err := cluster.CoreV1().Secrets("istio-system").List(context.TODO(), kubeApiMeta.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) != 1 || secrets.Items[0].Name != "other" {
+		t.Fatalf("expected only the tokens of the service account to be deleted, got %v", secrets.Items)
+	}
+
+	if err := i.revokeServiceAccountTokens(cluster, "unknown"); err == nil {
+		t.Fatal("expected an error for an unknown service account")
+	}
+}
+
+func TestRemoteReaderArgs(t *testing.T) {
+	a, b := fakeCluster("a"), fakeCluster("b")
+	i := &operatorComponent{remoteReaders: map[string]string{"a": "istio-reader-service-account-2"}}
+	if got, want := i.remoteReaderArgs(a), []string{"--service-account", "istio-reader-service-account-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := i.remoteReaderArgs(b); got != nil {
+		t.Fatalf("expected the default reader for a cluster that was not rotated, got %v", got)
+	}
+}
+
+func TestRemoteSecretTargets(t *testing.T) {
+	source, target := fakeCluster("source"), fakeCluster("target")
+	i := &operatorComponent{}
+	got := i.remoteSecretTargets(source, []resource.Cluster{target})
+	if len(got) != 1 || got[0].Name() != "target" {
+		t.Fatalf("expected the given target, got %d clusters", len(got))
+	}
+}
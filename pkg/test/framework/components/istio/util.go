@@ -93,7 +93,7 @@ func (i *operatorComponent) RemoteDiscoveryAddressFor(cluster resource.Cluster)
 		}
 		addr = address.(net.TCPAddr)
 	} else {
-		addr = i.CustomIngressFor(cp, eastWestIngressServiceName, eastWestIngressIstioLabel).DiscoveryAddress()
+		addr = i.EastWestGatewayFor(cp).DiscoveryAddress()
 	}
 	if addr.IP.String() == "<nil>" {
 		return net.TCPAddr{}, fmt.Errorf("failed to get ingress IP for %s", cp.Name())
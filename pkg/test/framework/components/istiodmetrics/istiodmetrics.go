@@ -0,0 +1,320 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package istiodmetrics snapshots the metrics of istiod at test boundaries, and asserts on their deltas, so that
+// tests double as control plane health regression checks.
+package istiodmetrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+const (
+	istiodSelector = "app=istiod"
+	metricsPort    = 15014
+)
+
+// Metrics checked by this package.
+const (
+	XDSPushes            = "pilot_xds_pushes"
+	XDSRejects           = "pilot_total_xds_rejects"
+	XDSInternalErrors    = "pilot_total_xds_internal_errors"
+	XDSWriteTimeouts     = "pilot_xds_write_timeout"
+	PushContextErrors    = "pilot_xds_push_context_errors"
+	ProxyConvergenceTime = "pilot_proxy_convergence_time"
+)
+
+type sample struct {
+	pod    string
+	name   string
+	labels map[string]string
+	value  float64
+	// counter is set for series that only increase until the process restarts, such as those of histograms.
+	counter bool
+}
+
+// Snapshot of the metrics of all istiod pods in a cluster. Histograms are recorded as their _sum, _count and
+// _bucket series, as in the Prometheus text format. Series are kept per pod, so that the reset of the counters of a
+// restarted pod can be told apart from those of other pods.
+type Snapshot struct {
+	Time    time.Time
+	samples map[string]sample
+}
+
+// Take a snapshot of the metrics of the istiod pods in the system namespace of the cluster.
+func Take(c resource.Cluster, systemNamespace string) (Snapshot, error) {
+	pods, err := c.PodsForSelector(context.TODO(), systemNamespace, istiodSelector)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if len(pods.Items) == 0 {
+		return Snapshot{}, fmt.Errorf("no istiod pods found in %s", systemNamespace)
+	}
+	s := Snapshot{Time: time.Now(), samples: map[string]sample{}}
+	for _, p := range pods.Items {
+		families, err := scrape(c, p.Name, p.Namespace)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed scraping metrics of %s/%s: %v", p.Namespace, p.Name, err)
+		}
+		for _, f := range families {
+			for _, m := range f.Metric {
+				s.add(p.Name, f, m)
+			}
+		}
+	}
+	return s, nil
+}
+
+// TakeOrFail calls Take and fails the test if it returns an error.
+func TakeOrFail(t test.Failer, c resource.Cluster, systemNamespace string) Snapshot {
+	t.Helper()
+	s, err := Take(c, systemNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func scrape(c resource.Cluster, pod, ns string) (map[string]*dto.MetricFamily, error) {
+	fw, err := c.NewPortForwarder(pod, ns, "", 0, metricsPort)
+	if err != nil {
+		return nil, err
+	}
+	if err := fw.Start(); err != nil {
+		return nil, err
+	}
+	defer fw.Close()
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/metrics", fw.Address()))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var parser expfmt.TextParser
+	return parser.TextToMetricFamilies(resp.Body)
+}
+
+func (s Snapshot) add(pod string, f *dto.MetricFamily, m *dto.Metric) {
+	labels := map[string]string{}
+	for _, l := range m.Label {
+		labels[l.GetName()] = l.GetValue()
+	}
+	name := f.GetName()
+	switch f.GetType() {
+	case dto.MetricType_COUNTER:
+		s.addSample(sample{pod: pod, name: name, labels: labels, value: m.GetCounter().GetValue(), counter: true})
+	case dto.MetricType_GAUGE:
+		s.addSample(sample{pod: pod, name: name, labels: labels, value: m.GetGauge().GetValue()})
+	case dto.MetricType_UNTYPED:
+		s.addSample(sample{pod: pod, name: name, labels: labels, value: m.GetUntyped().GetValue()})
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		s.addSample(sample{pod: pod, name: name + "_sum", labels: labels, value: h.GetSampleSum(), counter: true})
+		s.addSample(sample{pod: pod, name: name + "_count", labels: labels, value: float64(h.GetSampleCount()),
+			counter: true})
+		for _, b := range h.Bucket {
+			bl := map[string]string{"le": strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)}
+			for k, v := range labels {
+				bl[k] = v
+			}
+			s.addSample(sample{pod: pod, name: name + "_bucket", labels: bl, value: float64(b.GetCumulativeCount()),
+				counter: true})
+		}
+	}
+}
+
+func (s Snapshot) addSample(smp sample) {
+	k := key(smp.pod, smp.name, smp.labels)
+	smp.value += s.samples[k].value
+	s.samples[k] = smp
+}
+
+func key(pod, name string, labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(pairs)
+	return pod + "/" + name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Get returns the sum of the series of the metric that have all of the given labels, over all pods.
+func (s Snapshot) Get(name string, labels map[string]string) float64 {
+	total := 0.0
+	for _, smp := range s.samples {
+		if smp.name == name && hasLabels(smp.labels, labels) {
+			total += smp.value
+		}
+	}
+	return total
+}
+
+func hasLabels(actual, expected map[string]string) bool {
+	for k, v := range expected {
+		if actual[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Delta returns the change of every series from before to after. Series that only exist in after, such as those of
+// the first occurrence of a push error or of a pod that replaced another, are included with their full value, as are
+// counters that decreased, which were reset by a restart of istiod since before. Deltas of gauges are of little use.
+func Delta(before, after Snapshot) Snapshot {
+	d := Snapshot{Time: after.Time, samples: map[string]sample{}}
+	for k, smp := range after.samples {
+		smp.value = delta(before.samples[k].value, smp.value, smp.counter)
+		d.samples[k] = smp
+	}
+	return d
+}
+
+// delta returns the change of a series from previous to current. A counter that is lower than before was reset,
+// and has counted current since.
+func delta(previous, current float64, counter bool) float64 {
+	if counter && current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// Check asserts on the delta of the metrics between two snapshots.
+type Check func(delta Snapshot) error
+
+// NoPushErrors checks that istiod had no errors building, sending or initiating pushes.
+func NoPushErrors() Check {
+	return func(delta Snapshot) error {
+		var errs error
+		for _, smp := range delta.samples {
+			if smp.name == XDSPushes && (strings.HasSuffix(smp.labels["type"], "_senderr") ||
+				strings.HasSuffix(smp.labels["type"], "_builderr")) && smp.value > 0 {
+				errs = multierror.Append(errs, fmt.Errorf("%v %s pushes", smp.value, smp.labels["type"]))
+			}
+		}
+		for _, name := range []string{XDSInternalErrors, XDSWriteTimeouts, PushContextErrors} {
+			if v := delta.Get(name, nil); v > 0 {
+				errs = multierror.Append(errs, fmt.Errorf("%v increased by %v", name, v))
+			}
+		}
+		return errs
+	}
+}
+
+// NoRejects checks that no proxy rejected the config pushed by istiod.
+func NoRejects() Check {
+	return func(delta Snapshot) error {
+		if v := delta.Get(XDSRejects, nil); v > 0 {
+			return fmt.Errorf("proxies rejected config %v times", v)
+		}
+		return nil
+	}
+}
+
+// MaxFullPushes checks that istiod made at most max full pushes, counted as pushes of clusters, which are only sent
+// on full pushes, summed over all proxies.
+func MaxFullPushes(max int) Check {
+	return func(delta Snapshot) error {
+		if v := delta.Get(XDSPushes, map[string]string{"type": "cds"}); v > float64(max) {
+			return fmt.Errorf("expected at most %d full pushes, got %v", max, v)
+		}
+		return nil
+	}
+}
+
+// MaxConvergence checks that at least the given fraction (e.g. 0.99) of config changes reached proxies within the
+// latency. The latency is rounded up to the next bucket bound of the convergence time histogram.
+func MaxConvergence(latency time.Duration, fraction float64) Check {
+	return func(delta Snapshot) error {
+		total := delta.Get(ProxyConvergenceTime+"_count", nil)
+		if total == 0 {
+			return nil
+		}
+		bound := math.Inf(1)
+		for _, smp := range delta.samples {
+			if smp.name != ProxyConvergenceTime+"_bucket" {
+				continue
+			}
+			le, err := strconv.ParseFloat(smp.labels["le"], 64)
+			if err == nil && le >= latency.Seconds() && le < bound {
+				bound = le
+			}
+		}
+		within := delta.Get(ProxyConvergenceTime+"_bucket", map[string]string{
+			"le": strconv.FormatFloat(bound, 'g', -1, 64),
+		})
+		if got := within / total; got < fraction {
+			return fmt.Errorf("expected %.1f%% of proxies to converge within %vs, got %.1f%% of %v",
+				fraction*100, bound, got*100, total)
+		}
+		return nil
+	}
+}
+
+// Compare runs the checks on the delta between the snapshots.
+func Compare(before, after Snapshot, checks ...Check) error {
+	delta := Delta(before, after)
+	var errs error
+	for _, c := range checks {
+		if err := c(delta); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	if errs != nil {
+		return fmt.Errorf("istiod metrics regressed between %v and %v: %v",
+			before.Time.Format(time.RFC3339), after.Time.Format(time.RFC3339), errs)
+	}
+	return nil
+}
+
+// CompareOrFail calls Compare and fails the test if it returns an error.
+func CompareOrFail(t test.Failer, before, after Snapshot, checks ...Check) {
+	t.Helper()
+	if err := Compare(before, after, checks...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// CheckOnCleanup takes a snapshot now, and another when the test completes, failing the test if the checks do not
+// pass on the delta between them.
+func CheckOnCleanup(t test.Failer, c resource.Cluster, systemNamespace string, checks ...Check) {
+	t.Helper()
+	before := TakeOrFail(t, c, systemNamespace)
+	t.Cleanup(func() {
+		after, err := Take(c, systemNamespace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Compare(before, after, checks...); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
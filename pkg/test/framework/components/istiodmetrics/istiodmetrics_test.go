@@ -0,0 +1,164 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package istiodmetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func counter(name, pushType string, value float64) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name: proto.String(name),
+		Type: dto.MetricType_COUNTER.Enum(),
+		Metric: []*dto.Metric{{
+			Label:   []*dto.LabelPair{{Name: proto.String("type"), Value: proto.String(pushType)}},
+			Counter: &dto.Counter{Value: proto.Float64(value)},
+		}},
+	}
+}
+
+func gauge(name string, value float64) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name:   proto.String(name),
+		Type:   dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(value)}}},
+	}
+}
+
+// snapshot builds a snapshot from the metric families scraped from each pod.
+func snapshot(pods map[string][]*dto.MetricFamily) Snapshot {
+	s := Snapshot{Time: time.Now(), samples: map[string]sample{}}
+	for pod, families := range pods {
+		for _, f := range families {
+			for _, m := range f.Metric {
+				s.add(pod, f, m)
+			}
+		}
+	}
+	return s
+}
+
+func TestDelta(t *testing.T) {
+	before := snapshot(map[string][]*dto.MetricFamily{
+		"istiod-a": {counter(XDSPushes, "cds", 10), gauge("pilot_xds", 5)},
+		"istiod-b": {counter(XDSPushes, "cds", 20)},
+	})
+	cases := []struct {
+		name  string
+		after map[string][]*dto.MetricFamily
+		// cds is the expected delta of full pushes, and gauge that of the gauge.
+		cds   float64
+		gauge float64
+	}{
+		{
+			name: "increase",
+			after: map[string][]*dto.MetricFamily{
+				"istiod-a": {counter(XDSPushes, "cds", 12), gauge("pilot_xds", 5)},
+				"istiod-b": {counter(XDSPushes, "cds", 23)},
+			},
+			cds: 5,
+		},
+		{
+			// istiod-a restarted, and its counter counted 3 since.
+			name: "reset",
+			after: map[string][]*dto.MetricFamily{
+				"istiod-a": {counter(XDSPushes, "cds", 3), gauge("pilot_xds", 2)},
+				"istiod-b": {counter(XDSPushes, "cds", 20)},
+			},
+			cds:   3,
+			gauge: -3,
+		},
+		{
+			// istiod-b was replaced by istiod-c, whose counter is new.
+			name: "replaced pod",
+			after: map[string][]*dto.MetricFamily{
+				"istiod-a": {counter(XDSPushes, "cds", 10), gauge("pilot_xds", 5)},
+				"istiod-c": {counter(XDSPushes, "cds", 4)},
+			},
+			cds: 4,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Delta(before, snapshot(tt.after))
+			if got := d.Get(XDSPushes, map[string]string{"type": "cds"}); got != tt.cds {
+				t.Errorf("expected a delta of %v full pushes, got %v", tt.cds, got)
+			}
+			if got := d.Get("pilot_xds", nil); got != tt.gauge {
+				t.Errorf("expected a gauge delta of %v, got %v", tt.gauge, got)
+			}
+		})
+	}
+}
+
+func TestChecks(t *testing.T) {
+	before := snapshot(map[string][]*dto.MetricFamily{
+		"istiod": {counter(XDSPushes, "cds", 10), counter(XDSPushes, "lds_senderr", 3)},
+	})
+	cases := []struct {
+		name    string
+		after   []*dto.MetricFamily
+		check   Check
+		wantErr bool
+	}{
+		{
+			name:  "no push errors",
+			after: []*dto.MetricFamily{counter(XDSPushes, "cds", 15), counter(XDSPushes, "lds_senderr", 3)},
+			check: NoPushErrors(),
+		},
+		{
+			name:    "push errors",
+			after:   []*dto.MetricFamily{counter(XDSPushes, "cds", 15), counter(XDSPushes, "lds_senderr", 4)},
+			check:   NoPushErrors(),
+			wantErr: true,
+		},
+		{
+			// The restart reset the error counter to a value below the previous one, but it still counted an error.
+			name:    "push errors after a reset",
+			after:   []*dto.MetricFamily{counter(XDSPushes, "cds", 1), counter(XDSPushes, "lds_senderr", 1)},
+			check:   NoPushErrors(),
+			wantErr: true,
+		},
+		{
+			name:  "full pushes within max",
+			after: []*dto.MetricFamily{counter(XDSPushes, "cds", 15)},
+			check: MaxFullPushes(5),
+		},
+		{
+			name:    "full pushes over max",
+			after:   []*dto.MetricFamily{counter(XDSPushes, "cds", 16)},
+			check:   MaxFullPushes(5),
+			wantErr: true,
+		},
+		{
+			name:    "full pushes after a reset",
+			after:   []*dto.MetricFamily{counter(XDSPushes, "cds", 8)},
+			check:   MaxFullPushes(5),
+			wantErr: true,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check(Delta(before, snapshot(map[string][]*dto.MetricFamily{"istiod": tt.after})))
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
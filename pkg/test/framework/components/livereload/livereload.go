@@ -0,0 +1,423 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package livereload mutates ConfigMaps and Secrets mounted by istiod and gateways, and waits for the consuming
+// component to observe the change, covering the reload paths of mesh config, CA certificates and gateway credentials.
+package livereload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeRetry "k8s.io/client-go/util/retry"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+	testKube "istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+const (
+	istiodSelector = "app=istiod"
+	proxyContainer = "istio-proxy"
+	proxyAdminPort = 15000
+)
+
+// Observer checks whether the consumer of a ConfigMap or Secret has observed a change to it.
+type Observer interface {
+	// Mark records the state of the consumer before the change.
+	Mark() error
+	// Observed returns nil once the consumer has observed the change. It is retried until it succeeds.
+	Observed() error
+}
+
+// LogObserver observes a change through a message the consumer logs each time it reloads the resource.
+type LogObserver struct {
+	Cluster   resource.Cluster
+	Namespace string
+	// Selector of the consuming pods.
+	Selector  string
+	Container string
+	Message   string
+
+	counts map[string]int
+}
+
+var _ Observer = &LogObserver{}
+
+func (o *LogObserver) Mark() error {
+	counts, err := o.count()
+	if err != nil {
+		return err
+	}
+	o.counts = counts
+	return nil
+}
+
+func (o *LogObserver) Observed() error {
+	counts, err := o.count()
+	if err != nil {
+		return err
+	}
+	if len(counts) == 0 {
+		return fmt.Errorf("no pods found for %s in %s", o.Selector, o.Namespace)
+	}
+	for pod, n := range counts {
+		// Pods started after Mark have read the changed resource on startup.
+		if marked, ok := o.counts[pod]; ok && n <= marked {
+			return fmt.Errorf("pod %s/%s has not logged %q since the change", o.Namespace, pod, o.Message)
+		}
+	}
+	return nil
+}
+
+func (o *LogObserver) count() (map[string]int, error) {
+	pods, err := o.Cluster.PodsForSelector(context.TODO(), o.Namespace, o.Selector)
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, p := range pods.Items {
+		logs, err := o.Cluster.PodLogs(context.TODO(), p.Name, o.Namespace, o.Container, false)
+		if err != nil {
+			return nil, err
+		}
+		counts[p.Name] = strings.Count(logs, o.Message)
+	}
+	return counts, nil
+}
+
+// RestartObserver restarts the deployments consuming a resource they only read on startup, such as istiod with the
+// cacerts Secret, and observes the change once the rollout is complete.
+type RestartObserver struct {
+	Cluster   resource.Cluster
+	Namespace string
+	// Selector of the consuming deployments.
+	Selector string
+
+	restarted []string
+}
+
+var _ Observer = &RestartObserver{}
+
+func (o *RestartObserver) Mark() error {
+	o.restarted = nil
+	return nil
+}
+
+func (o *RestartObserver) Observed() error {
+	if o.restarted == nil {
+		names, err := testKube.RolloutRestart(o.Cluster, o.Namespace, o.Selector)
+		if err != nil {
+			return err
+		}
+		o.restarted = names
+	}
+	for _, name := range o.restarted {
+		if err := testKube.WaitForDeploymentRollout(o.Cluster, o.Namespace, name, retry.Timeout(time.Second)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecretObserver observes a change to a credential through the version of the SDS secret served to gateway proxies.
+type SecretObserver struct {
+	Cluster   resource.Cluster
+	Namespace string
+	// Selector of the gateway pods.
+	Selector string
+	// SecretName is the credentialName of the gateway server.
+	SecretName string
+
+	versions map[string]string
+}
+
+var _ Observer = &SecretObserver{}
+
+func (o *SecretObserver) Mark() error {
+	versions, err := o.versionsByPod()
+	if err != nil {
+		return err
+	}
+	o.versions = versions
+	return nil
+}
+
+func (o *SecretObserver) Observed() error {
+	versions, err := o.versionsByPod()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no pods found for %s in %s", o.Selector, o.Namespace)
+	}
+	for pod, v := range versions {
+		if v == "" || v == o.versions[pod] {
+			return fmt.Errorf("pod %s/%s has not received a new version of secret %s", o.Namespace, pod, o.SecretName)
+		}
+	}
+	return nil
+}
+
+// secretsDump holds the parts of the Envoy config dump listing the active SDS secrets.
+type secretsDump struct {
+	Configs []struct {
+		DynamicActiveSecrets []struct {
+			Name        string `json:"name"`
+			VersionInfo string `json:"version_info"`
+		} `json:"dynamic_active_secrets"`
+	} `json:"configs"`
+}
+
+func (o *SecretObserver) versionsByPod() (map[string]string, error) {
+	pods, err := o.Cluster.PodsForSelector(context.TODO(), o.Namespace, o.Selector)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]string{}
+	for _, p := range pods.Items {
+		command := fmt.Sprintf("curl -s http://127.0.0.1:%d/config_dump", proxyAdminPort)
+		stdout, stderr, err := o.Cluster.PodExec(p.Name, o.Namespace, proxyContainer, command)
+		if err != nil {
+			return nil, fmt.Errorf("failed getting config dump of %s/%s: %v: %s", o.Namespace, p.Name, err, stderr)
+		}
+		var dump secretsDump
+		if err := json.Unmarshal([]byte(stdout), &dump); err != nil {
+			return nil, fmt.Errorf("failed parsing config dump of %s/%s: %v", o.Namespace, p.Name, err)
+		}
+		versions[p.Name] = ""
+		for _, c := range dump.Configs {
+			for _, s := range c.DynamicActiveSecrets {
+				if s.Name == o.SecretName || strings.HasSuffix(s.Name, "://"+o.SecretName) {
+					versions[p.Name] = s.VersionInfo
+				}
+			}
+		}
+	}
+	return versions, nil
+}
+
+func observe(mutate func() error, obs Observer, opts ...retry.Option) error {
+	if err := obs.Mark(); err != nil {
+		return err
+	}
+	if err := mutate(); err != nil {
+		return err
+	}
+	opts = append([]retry.Option{retry.Timeout(2 * time.Minute), retry.Delay(2 * time.Second)}, opts...)
+	return retry.UntilSuccess(obs.Observed, opts...)
+}
+
+// ReloadConfigMap mutates the data of the ConfigMap and waits until the observer has seen the change. The returned
+// function restores the original data, again waiting for the observer.
+func ReloadConfigMap(c resource.Cluster, ns, name string, mutate func(data map[string]string) error, obs Observer,
+	opts ...retry.Option) (func() error, error) {
+	var original map[string]string
+	update := func(mutate func(map[string]string) error) func() error {
+		return func() error {
+			return kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+				cm, err := c.CoreV1().ConfigMaps(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+				if err != nil {
+					return err
+				}
+				if cm.Data == nil {
+					cm.Data = map[string]string{}
+				}
+				if original == nil {
+					original = map[string]string{}
+					for k, v := range cm.Data {
+						original[k] = v
+					}
+				}
+				if err := mutate(cm.Data); err != nil {
+					return err
+				}
+				_, err = c.CoreV1().ConfigMaps(ns).Update(context.TODO(), cm, kubeApiMeta.UpdateOptions{})
+				return err
+			})
+		}
+	}
+
+	scopes.Framework.Infof("Reloading ConfigMap %s/%s in %s", ns, name, c.Name())
+	if err := observe(update(mutate), obs, opts...); err != nil {
+		return nil, fmt.Errorf("failed reloading ConfigMap %s/%s: %v", ns, name, err)
+	}
+	return func() error {
+		return observe(update(func(data map[string]string) error {
+			for k := range data {
+				delete(data, k)
+			}
+			for k, v := range original {
+				data[k] = v
+			}
+			return nil
+		}), obs, opts...)
+	}, nil
+}
+
+// ReloadSecret mutates the data of the Secret and waits until the observer has seen the change. The returned function
+// restores the original data, again waiting for the observer.
+func ReloadSecret(c resource.Cluster, ns, name string, mutate func(data map[string][]byte) error, obs Observer,
+	opts ...retry.Option) (func() error, error) {
+	var original map[string][]byte
+	update := func(mutate func(map[string][]byte) error) func() error {
+		return func() error {
+			return kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+				s, err := c.CoreV1().Secrets(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+				if err != nil {
+					return err
+				}
+				if s.Data == nil {
+					s.Data = map[string][]byte{}
+				}
+				if original == nil {
+					original = map[string][]byte{}
+					for k, v := range s.Data {
+						original[k] = v
+					}
+				}
+				if err := mutate(s.Data); err != nil {
+					return err
+				}
+				_, err = c.CoreV1().Secrets(ns).Update(context.TODO(), s, kubeApiMeta.UpdateOptions{})
+				return err
+			})
+		}
+	}
+
+	scopes.Framework.Infof("Reloading Secret %s/%s in %s", ns, name, c.Name())
+	if err := observe(update(mutate), obs, opts...); err != nil {
+		return nil, fmt.Errorf("failed reloading Secret %s/%s: %v", ns, name, err)
+	}
+	return func() error {
+		return observe(update(func(data map[string][]byte) error {
+			for k := range data {
+				delete(data, k)
+			}
+			for k, v := range original {
+				data[k] = v
+			}
+			return nil
+		}), obs, opts...)
+	}, nil
+}
+
+// MeshConfigObserver observes istiod reloading its mesh config.
+func MeshConfigObserver(c resource.Cluster, systemNamespace string) Observer {
+	return &LogObserver{
+		Cluster:   c,
+		Namespace: systemNamespace,
+		Selector:  istiodSelector,
+		Container: "discovery",
+		Message:   "mesh configuration updated to",
+	}
+}
+
+// ReloadMeshConfig mutates the mesh config in the istio ConfigMap of the revision, and waits until istiod has
+// reloaded it. Only the fields set in the ConfigMap are passed to mutate, not the defaults applied by istiod.
+func ReloadMeshConfig(c resource.Cluster, systemNamespace, revision string, mutate func(*meshconfig.MeshConfig),
+	opts ...retry.Option) (func() error, error) {
+	name := "istio"
+	if revision != "" {
+		name += "-" + revision
+	}
+	return ReloadConfigMap(c, systemNamespace, name, func(data map[string]string) error {
+		m := &meshconfig.MeshConfig{}
+		if err := gogoprotomarshal.ApplyYAML(data["mesh"], m); err != nil {
+			return fmt.Errorf("failed parsing mesh config: %v", err)
+		}
+		mutate(m)
+		out, err := gogoprotomarshal.ToYAML(m)
+		if err != nil {
+			return err
+		}
+		data["mesh"] = out
+		return nil
+	}, MeshConfigObserver(c, systemNamespace), opts...)
+}
+
+// ReloadCACerts mutates the cacerts Secret holding the CA certificate and key of istiod, and restarts istiod, which
+// only reads it on startup.
+func ReloadCACerts(c resource.Cluster, systemNamespace string, mutate func(data map[string][]byte) error,
+	opts ...retry.Option) (func() error, error) {
+	return ReloadSecret(c, systemNamespace, "cacerts", mutate, &RestartObserver{
+		Cluster:   c,
+		Namespace: systemNamespace,
+		Selector:  istiodSelector,
+	}, opts...)
+}
+
+// ReloadGatewayCredential mutates the Secret referenced by the credentialName of a gateway server, and waits until
+// the gateway pods selected by gatewaySelector (e.g. "istio=ingressgateway") serve the new credential.
+func ReloadGatewayCredential(c resource.Cluster, ns, name, gatewaySelector string,
+	mutate func(data map[string][]byte) error, opts ...retry.Option) (func() error, error) {
+	return ReloadSecret(c, ns, name, mutate, &SecretObserver{
+		Cluster:    c,
+		Namespace:  ns,
+		Selector:   gatewaySelector,
+		SecretName: name,
+	}, opts...)
+}
+
+// ReloadMeshConfigOrFail calls ReloadMeshConfig and fails the test if it returns an error. The original mesh config
+// is restored when the test completes.
+func ReloadMeshConfigOrFail(t test.Failer, c resource.Cluster, systemNamespace, revision string,
+	mutate func(*meshconfig.MeshConfig), opts ...retry.Option) {
+	t.Helper()
+	restore, err := ReloadMeshConfig(c, systemNamespace, revision, mutate, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreOnCleanup(t, restore)
+}
+
+// ReloadCACertsOrFail calls ReloadCACerts and fails the test if it returns an error. The original certificates are
+// restored when the test completes.
+func ReloadCACertsOrFail(t test.Failer, c resource.Cluster, systemNamespace string,
+	mutate func(data map[string][]byte) error, opts ...retry.Option) {
+	t.Helper()
+	restore, err := ReloadCACerts(c, systemNamespace, mutate, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreOnCleanup(t, restore)
+}
+
+// ReloadGatewayCredentialOrFail calls ReloadGatewayCredential and fails the test if it returns an error. The original
+// credential is restored when the test completes.
+func ReloadGatewayCredentialOrFail(t test.Failer, c resource.Cluster, ns, name, gatewaySelector string,
+	mutate func(data map[string][]byte) error, opts ...retry.Option) {
+	t.Helper()
+	restore, err := ReloadGatewayCredential(c, ns, name, gatewaySelector, mutate, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreOnCleanup(t, restore)
+}
+
+func restoreOnCleanup(t test.Failer, restore func() error) {
+	t.Cleanup(func() {
+		if err := restore(); err != nil {
+			scopes.Framework.Errorf("failed restoring reloaded resource: %v", err)
+		}
+	})
+}
@@ -0,0 +1,224 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package livereload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// fakeClient serves its pods, with the logs and config dumps set for them, and the objects of a fake clientset.
+type fakeClient struct {
+	kube.ExtendedClient
+	pods  []string
+	logs  map[string]string
+	dumps map[string]string
+}
+
+func (c *fakeClient) PodsForSelector(context.Context, string, ...string) (*kubeApiCore.PodList, error) {
+	out := &kubeApiCore.PodList{}
+	for _, p := range c.pods {
+		out.Items = append(out.Items, kubeApiCore.Pod{ObjectMeta: kubeApiMeta.ObjectMeta{Name: p}})
+	}
+	return out, nil
+}
+
+func (c *fakeClient) PodLogs(_ context.Context, pod, _, _ string, _ bool) (string, error) {
+	return c.logs[pod], nil
+}
+
+func (c *fakeClient) PodExec(pod, _, _, _ string) (string, string, error) {
+	dump, ok := c.dumps[pod]
+	if !ok {
+		return "", "connection refused", errors.New("exit status 7")
+	}
+	return dump, "", nil
+}
+
+func fakeCluster(c *fakeClient) resource.Cluster {
+	if c.ExtendedClient == nil {
+		c.ExtendedClient = kube.NewFakeClient().(kube.ExtendedClient)
+	}
+	return resource.FakeCluster{ExtendedClient: c, NameValue: "cluster-0"}
+}
+
+// countingObserver observes the change on the given attempt after each Mark.
+type countingObserver struct {
+	marks    int
+	attempts int
+	after    int
+}
+
+func (o *countingObserver) Mark() error {
+	o.marks++
+	o.attempts = 0
+	return nil
+}
+
+func (o *countingObserver) Observed() error {
+	o.attempts++
+	if o.attempts < o.after {
+		return fmt.Errorf("not observed after %d attempts", o.attempts)
+	}
+	return nil
+}
+
+var fastRetry = []retry.Option{retry.Timeout(time.Second), retry.Delay(time.Millisecond)}
+
+func TestReloadConfigMap(t *testing.T) {
+	c := fakeCluster(&fakeClient{ExtendedClient: kube.NewFakeClient(&kubeApiCore.ConfigMap{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "istio", Namespace: "istio-system"},
+		Data:       map[string]string{"mesh": "a", "other": "b"},
+	}).(kube.ExtendedClient)})
+	data := func() map[string]string {
+		cm, err := c.CoreV1().ConfigMaps("istio-system").Get(context.TODO(), "istio", kubeApiMeta.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cm.Data
+	}
+	obs := &countingObserver{after: 3}
+
+	restore, err := ReloadConfigMap(c, "istio-system", "istio", func(data map[string]string) error {
+		data["mesh"] = "changed"
+		delete(data, "other")
+		return nil
+	}, obs, fastRetry...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := data(); !reflect.DeepEqual(got, map[string]string{"mesh": "changed"}) {
+		t.Fatalf("got data %v after the change", got)
+	}
+	if obs.marks != 1 || obs.attempts != 3 {
+		t.Fatalf("expected the change to be observed once marked, got %d marks and %d attempts", obs.marks, obs.attempts)
+	}
+
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+	if got := data(); !reflect.DeepEqual(got, map[string]string{"mesh": "a", "other": "b"}) {
+		t.Fatalf("got data %v after restoring", got)
+	}
+	if obs.marks != 2 {
+		t.Fatalf("expected the restore to be observed, got %d marks", obs.marks)
+	}
+
+	_, err = ReloadConfigMap(c, "istio-system", "istio", func(map[string]string) error { return nil },
+		&countingObserver{after: 1000}, retry.Timeout(50*time.Millisecond), retry.Delay(time.Millisecond))
+	if err == nil {
+		t.Fatal("expected an error when the change is not observed")
+	}
+}
+
+func TestReloadSecret(t *testing.T) {
+	c := fakeCluster(&fakeClient{ExtendedClient: kube.NewFakeClient(&kubeApiCore.Secret{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "cacerts", Namespace: "istio-system"},
+		Data:       map[string][]byte{"ca-cert.pem": []byte("old")},
+	}).(kube.ExtendedClient)})
+	restore, err := ReloadSecret(c, "istio-system", "cacerts", func(data map[string][]byte) error {
+		data["ca-cert.pem"] = []byte("new")
+		return nil
+	}, &countingObserver{after: 1}, fastRetry...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.CoreV1().Secrets("istio-system").Get(context.TODO(), "cacerts", kubeApiMeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(s.Data["ca-cert.pem"]) != "new" {
+		t.Fatalf("got %s after the change", s.Data["ca-cert.pem"])
+	}
+	if err := restore(); err != nil {
+		t.Fatal(err)
+	}
+	s, err = c.CoreV1().Secrets("istio-system").Get(context.TODO(), "cacerts", kubeApiMeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(s.Data["ca-cert.pem"]) != "old" {
+		t.Fatalf("got %s after restoring", s.Data["ca-cert.pem"])
+	}
+
+	if _, err := ReloadSecret(c, "istio-system", "missing", func(map[string][]byte) error { return nil },
+		&countingObserver{after: 1}, fastRetry...); err == nil {
+		t.Fatal("expected an error for a missing secret")
+	}
+}
+
+func TestLogObserver(t *testing.T) {
+	client := &fakeClient{pods: []string{"istiod-1"}, logs: map[string]string{"istiod-1": "mesh configuration updated to"}}
+	o := &LogObserver{Cluster: fakeCluster(client), Namespace: "istio-system", Message: "mesh configuration updated to"}
+	if err := o.Mark(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Observed(); err == nil {
+		t.Fatal("expected the change not to be observed before the pod logs the message again")
+	}
+	client.logs["istiod-1"] += "\nmesh configuration updated to"
+	// Pods started after the change read it on startup.
+	client.pods = append(client.pods, "istiod-2")
+	if err := o.Observed(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.pods = nil
+	if err := o.Observed(); err == nil {
+		t.Fatal("expected an error without pods")
+	}
+}
+
+func TestSecretObserver(t *testing.T) {
+	dump := func(name, version string) string {
+		return fmt.Sprintf(`{"configs": [{"dynamic_active_secrets": [{"name": %q, "version_info": %q}]}]}`, name, version)
+	}
+	client := &fakeClient{pods: []string{"gateway-1"}, dumps: map[string]string{"gateway-1": dump("kubernetes://cred", "1")}}
+	o := &SecretObserver{Cluster: fakeCluster(client), Namespace: "istio-system", SecretName: "cred"}
+	if err := o.Mark(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Observed(); err == nil {
+		t.Fatal("expected the change not to be observed before the version changes")
+	}
+	client.dumps["gateway-1"] = dump("kubernetes://other", "2")
+	if err := o.Observed(); err == nil {
+		t.Fatal("expected a version of another secret not to be observed")
+	}
+	client.dumps["gateway-1"] = dump("kubernetes://cred", "2")
+	if err := o.Observed(); err != nil {
+		t.Fatal(err)
+	}
+
+	client.dumps["gateway-1"] = "{"
+	if err := o.Observed(); err == nil {
+		t.Fatal("expected an error for an invalid config dump")
+	}
+	delete(client.dumps, "gateway-1")
+	if err := o.Observed(); err == nil {
+		t.Fatal("expected an error when the config dump cannot be read")
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const crdYAML = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: serviceexports.multicluster.x-k8s.io
+spec:
+  group: multicluster.x-k8s.io
+  scope: Namespaced
+  names:
+    plural: serviceexports
+    singular: serviceexport
+    kind: ServiceExport
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: true
+    subresources:
+      status: {}
+    schema:
+      openAPIV3Schema:
+        type: object
+        x-kubernetes-preserve-unknown-fields: true
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: serviceimports.multicluster.x-k8s.io
+spec:
+  group: multicluster.x-k8s.io
+  scope: Namespaced
+  names:
+    plural: serviceimports
+    singular: serviceimport
+    kind: ServiceImport
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: true
+    subresources:
+      status: {}
+    schema:
+      openAPIV3Schema:
+        type: object
+        x-kubernetes-preserve-unknown-fields: true
+`
+
+var (
+	_ Instance  = &kubeComponent{}
+	_ io.Closer = &kubeComponent{}
+)
+
+type kubeComponent struct {
+	id          resource.ID
+	ctx         resource.Context
+	installCRDs bool
+}
+
+func newKube(ctx resource.Context, cfg Config) (Instance, error) {
+	c := &kubeComponent{
+		ctx:         ctx,
+		installCRDs: !cfg.SkipCRDs,
+	}
+	c.id = ctx.TrackResource(c)
+
+	if c.installCRDs {
+		if err := ctx.Config().ApplyYAML("", crdYAML); err != nil {
+			return nil, fmt.Errorf("failed installing MCS CRDs: %v", err)
+		}
+	}
+	return c, nil
+}
+
+func (c *kubeComponent) ID() resource.ID {
+	return c.id
+}
+
+func (c *kubeComponent) Export(ns, name string, clusters ...resource.Cluster) error {
+	export := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": ServiceExportGVR.GroupVersion().String(),
+		"kind":       "ServiceExport",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": ns,
+		},
+	}}
+	for _, cluster := range c.clustersOrAll(clusters) {
+		scopes.Framework.Infof("Exporting service %s/%s from %s", ns, name, cluster.Name())
+		_, err := cluster.Dynamic().Resource(ServiceExportGVR).Namespace(ns).Create(context.TODO(), export,
+			kubeApiMeta.CreateOptions{})
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed exporting service %s/%s from %s: %v", ns, name, cluster.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c *kubeComponent) ExportOrFail(t test.Failer, ns, name string, clusters ...resource.Cluster) {
+	t.Helper()
+	if err := c.Export(ns, name, clusters...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *kubeComponent) Unexport(ns, name string, clusters ...resource.Cluster) error {
+	var errs error
+	for _, cluster := range c.clustersOrAll(clusters) {
+		scopes.Framework.Infof("Unexporting service %s/%s from %s", ns, name, cluster.Name())
+		err := cluster.Dynamic().Resource(ServiceExportGVR).Namespace(ns).Delete(context.TODO(), name,
+			kubeApiMeta.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			errs = multierror.Append(errs, fmt.Errorf("failed unexporting service %s/%s from %s: %v",
+				ns, name, cluster.Name(), err))
+		}
+	}
+	return errs
+}
+
+func (c *kubeComponent) WaitForImport(cluster resource.Cluster, ns, name string, opts ...retry.Option) error {
+	return retry.UntilSuccess(func() error {
+		_, err := cluster.Dynamic().Resource(ServiceImportGVR).Namespace(ns).Get(context.TODO(), name,
+			kubeApiMeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("service import %s/%s not found in %s: %v", ns, name, cluster.Name(), err)
+		}
+		return nil
+	}, opts...)
+}
+
+func (c *kubeComponent) WaitForImportOrFail(t test.Failer, cluster resource.Cluster, ns, name string, opts ...retry.Option) {
+	t.Helper()
+	if err := c.WaitForImport(cluster, ns, name, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *kubeComponent) WaitForResolution(source, target echo.Instance, opts ...retry.Option) error {
+	cluster := source.Config().Cluster
+	ns := source.Config().Namespace.Name()
+	host := ClusterSetHost(target)
+	return retry.UntilSuccess(func() error {
+		imp, err := cluster.Dynamic().Resource(ServiceImportGVR).Namespace(target.Config().Namespace.Name()).Get(
+			context.TODO(), target.Config().Service, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("service import of %s not found in %s: %v", host, cluster.Name(), err)
+		}
+		want, _, err := unstructured.NestedStringSlice(imp.Object, "spec", "ips")
+		if err != nil {
+			return fmt.Errorf("invalid service import of %s in %s: %v", host, cluster.Name(), err)
+		}
+		pods, err := cluster.PodsForSelector(context.TODO(), ns, "app="+source.Config().Service)
+		if err != nil {
+			return err
+		}
+		if len(pods.Items) == 0 {
+			return fmt.Errorf("no pods of %s found in %s", source.Config().Service, cluster.Name())
+		}
+		for _, pod := range pods.Items {
+			stdout, stderr, err := cluster.PodExec(pod.Name, ns, "app", "getent hosts "+host)
+			if err != nil {
+				return fmt.Errorf("%s does not resolve from %s/%s: %v %s", host, ns, pod.Name, err, stderr)
+			}
+			got := resolvedIPs(stdout)
+			if len(got) == 0 {
+				return fmt.Errorf("%s does not resolve from %s/%s", host, ns, pod.Name)
+			}
+			if len(want) > 0 && !sameIPs(got, want) {
+				return fmt.Errorf("%s resolves from %s/%s to %v, rather than the clusterset IPs %v", host, ns, pod.Name,
+					got, want)
+			}
+		}
+		return nil
+	}, opts...)
+}
+
+func (c *kubeComponent) WaitForResolutionOrFail(t test.Failer, source, target echo.Instance, opts ...retry.Option) {
+	t.Helper()
+	if err := c.WaitForResolution(source, target, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resolvedIPs parses the output of getent hosts, a line of an IP followed by its names for each address.
+func resolvedIPs(out string) []string {
+	var ips []string
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			ips = append(ips, fields[0])
+		}
+	}
+	return ips
+}
+
+func sameIPs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *kubeComponent) clustersOrAll(clusters []resource.Cluster) []resource.Cluster {
+	if len(clusters) > 0 {
+		return clusters
+	}
+	return c.ctx.Clusters()
+}
+
+// Close implements io.Closer
+func (c *kubeComponent) Close() error {
+	if !c.installCRDs {
+		return nil
+	}
+	// Removing the CRDs also removes any remaining exports and imports.
+	return c.ctx.Config().DeleteYAML("", crdYAML)
+}
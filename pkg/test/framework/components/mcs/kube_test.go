@@ -0,0 +1,39 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolvedIPs(t *testing.T) {
+	out := "10.96.0.10      a.echo.svc.clusterset.local\nfd00::10        a.echo.svc.clusterset.local\n"
+	if got, want := resolvedIPs(out), []string{"10.96.0.10", "fd00::10"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := resolvedIPs(""); len(got) != 0 {
+		t.Fatalf("got %v for no output", got)
+	}
+}
+
+func TestSameIPs(t *testing.T) {
+	if !sameIPs([]string{"b", "a"}, []string{"a", "b"}) {
+		t.Fatal("expected IPs in another order to be the same")
+	}
+	if sameIPs([]string{"a"}, []string{"a", "b"}) {
+		t.Fatal("expected missing IP to differ")
+	}
+}
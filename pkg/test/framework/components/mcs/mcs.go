@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mcs
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// ClusterSetDomain is the domain used for services imported from the clusterset.
+	ClusterSetDomain = "clusterset.local"
+)
+
+var (
+	// ServiceExportGVR is the resource for the Multi-Cluster Services ServiceExport.
+	ServiceExportGVR = schema.GroupVersionResource{
+		Group:    "multicluster.x-k8s.io",
+		Version:  "v1alpha1",
+		Resource: "serviceexports",
+	}
+
+	// ServiceImportGVR is the resource for the Multi-Cluster Services ServiceImport.
+	ServiceImportGVR = schema.GroupVersionResource{
+		Group:    "multicluster.x-k8s.io",
+		Version:  "v1alpha1",
+		Resource: "serviceimports",
+	}
+)
+
+// Instance provides access to the Multi-Cluster Services (MCS) API in all clusters.
+type Instance interface {
+	resource.Resource
+
+	// Export creates a ServiceExport for the service in each of the given clusters (or all clusters, if none
+	// are provided).
+	Export(ns, name string, clusters ...resource.Cluster) error
+	ExportOrFail(t test.Failer, ns, name string, clusters ...resource.Cluster)
+
+	// Unexport deletes the ServiceExport for the service from each of the given clusters (or all clusters,
+	// if none are provided).
+	Unexport(ns, name string, clusters ...resource.Cluster) error
+
+	// WaitForImport waits until a ServiceImport for the service exists in the cluster.
+	WaitForImport(cluster resource.Cluster, ns, name string, opts ...retry.Option) error
+	WaitForImportOrFail(t test.Failer, cluster resource.Cluster, ns, name string, opts ...retry.Option)
+
+	// WaitForResolution waits until the clusterset DNS name of the target resolves from each pod of the source. If
+	// the ServiceImport of the target in the cluster of the source has clusterset IPs, the name must resolve to them.
+	WaitForResolution(source, target echo.Instance, opts ...retry.Option) error
+	WaitForResolutionOrFail(t test.Failer, source, target echo.Instance, opts ...retry.Option)
+}
+
+// Config for the MCS component.
+type Config struct {
+	// SkipCRDs indicates that the MCS CRDs are already installed in the clusters, so they should be neither
+	// installed nor removed.
+	SkipCRDs bool
+}
+
+// New installs the MCS CRDs in all clusters, if needed, and returns an Instance for managing exports.
+func New(ctx resource.Context, c Config) (Instance, error) {
+	return newKube(ctx, c)
+}
+
+// NewOrFail calls New and fails the test if it returns an error.
+func NewOrFail(t test.Failer, ctx resource.Context, c Config) Instance {
+	t.Helper()
+	i, err := New(ctx, c)
+	if err != nil {
+		t.Fatalf("mcs.NewOrFail: %v", err)
+	}
+	return i
+}
+
+// ClusterSetHost returns the clusterset DNS name of the echo service, for example
+// a.echo-1-1234.svc.clusterset.local.
+func ClusterSetHost(i echo.Instance) string {
+	cfg := i.Config()
+	return cfg.Service + "." + cfg.Namespace.Name() + ".svc." + ClusterSetDomain
+}
+
+// ClusterSetCallOptions returns call options that reach the target through its clusterset DNS name.
+func ClusterSetCallOptions(target echo.Instance, portName string) echo.CallOptions {
+	host := ClusterSetHost(target)
+	return echo.CallOptions{
+		Target:     target,
+		PortName:   portName,
+		Host:       host,
+		HostHeader: host,
+	}
+}
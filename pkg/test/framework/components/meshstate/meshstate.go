@@ -0,0 +1,363 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package meshstate exports the Istio config and the Kubernetes resources of the meshed namespaces of a live
+// environment into a bundle, and imports bundles into a fresh environment, so that the state of a user-reported mesh
+// can be reproduced by a test.
+//
+// A bundle is a directory holding a directory per cluster, each with a YAML file per resource type. Files are named
+// with a prefix ordering them for import, so that namespaces are created before the resources they hold.
+package meshstate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+// kubeResources are the Kubernetes resources exported from the meshed namespaces, in import order.
+var kubeResources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "serviceaccounts"},
+	{Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Resource: "secrets"},
+	{Version: "v1", Resource: "services"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "statefulsets"},
+}
+
+var namespacesGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// Resources created by Kubernetes or Istio in every namespace, which are not exported.
+var generated = map[string]bool{
+	"configmaps/kube-root-ca.crt":   true,
+	"configmaps/istio-ca-root-cert": true,
+	"serviceaccounts/default":       true,
+	"services/kubernetes":           true,
+}
+
+// ExportOptions configures an Export.
+type ExportOptions struct {
+	// Namespaces whose Kubernetes resources are exported, in addition to those labeled for injection. Istio config
+	// is exported from all namespaces, and so are the namespaces holding it.
+	Namespaces []string
+	// Secrets are exported if set. Service account tokens and Istio generated secrets are never exported.
+	Secrets bool
+}
+
+// Bundle of exported state.
+type Bundle struct {
+	Dir string
+	// Clusters whose state is held by the bundle, in the order of the environment they were exported from.
+	Clusters []string
+}
+
+// Export the state of every cluster of the environment into a bundle in the directory.
+func Export(ctx resource.Context, dir string, opts ExportOptions) (Bundle, error) {
+	b := Bundle{Dir: dir}
+	for i, c := range ctx.Clusters() {
+		name := fmt.Sprintf("%02d-%s", i, c.Name())
+		if err := exportCluster(c, filepath.Join(dir, name), opts); err != nil {
+			return Bundle{}, fmt.Errorf("failed exporting cluster %s: %v", c.Name(), err)
+		}
+		b.Clusters = append(b.Clusters, name)
+	}
+	scopes.Framework.Infof("exported mesh state of %d clusters to %s", len(b.Clusters), dir)
+	return b, nil
+}
+
+// ExportOrFail calls Export and fails the test if it returns an error.
+func ExportOrFail(t test.Failer, ctx resource.Context, dir string, opts ExportOptions) Bundle {
+	t.Helper()
+	b, err := Export(ctx, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func exportCluster(c resource.Cluster, dir string, opts ExportOptions) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	namespaces, err := meshedNamespaces(c, opts.Namespaces)
+	if err != nil {
+		return err
+	}
+	// Namespaces referenced by any exported object, which must be created before it on import.
+	referenced := map[string]struct{}{}
+	write := func(file string, objects []unstructured.Unstructured) error {
+		for _, obj := range objects {
+			if ns := obj.GetNamespace(); ns != "" {
+				referenced[ns] = struct{}{}
+			}
+		}
+		return writeObjects(filepath.Join(dir, file), objects)
+	}
+
+	for i, gvr := range kubeResources {
+		if gvr.Resource == "secrets" && !opts.Secrets {
+			continue
+		}
+		var objects []unstructured.Unstructured
+		for _, ns := range namespaces {
+			list, err := c.Dynamic().Resource(gvr).Namespace(ns).List(context.TODO(), kubeApiMeta.ListOptions{})
+			if err != nil {
+				return err
+			}
+			for _, obj := range list.Items {
+				if exportable(gvr, obj) {
+					objects = append(objects, obj)
+				}
+			}
+		}
+		if err := write(fmt.Sprintf("%02d-%s.yaml", i+1, gvr.Resource), objects); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range collections.PilotServiceApi.All() {
+		gvr := s.Resource().GroupVersionResource()
+		list, err := c.Dynamic().Resource(gvr).List(context.TODO(), kubeApiMeta.ListOptions{})
+		if kubeErrors.IsNotFound(err) {
+			// The CRD is not installed.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := write(fmt.Sprintf("%02d-%s.%s.yaml", len(kubeResources)+1, gvr.Resource, gvr.Group), list.Items); err != nil {
+			return err
+		}
+	}
+
+	for _, ns := range namespaces {
+		referenced[ns] = struct{}{}
+	}
+	all := make([]string, 0, len(referenced))
+	for ns := range referenced {
+		all = append(all, ns)
+	}
+	sort.Strings(all)
+	var nsObjects []unstructured.Unstructured
+	for _, ns := range all {
+		obj, err := c.Dynamic().Resource(namespacesGVR).Get(context.TODO(), ns, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		nsObjects = append(nsObjects, *obj)
+	}
+	return writeObjects(filepath.Join(dir, "00-namespaces.yaml"), nsObjects)
+}
+
+// meshedNamespaces returns the namespaces labeled for injection, and the extra namespaces.
+func meshedNamespaces(c resource.Cluster, extra []string) ([]string, error) {
+	set := map[string]struct{}{}
+	for _, ns := range extra {
+		set[ns] = struct{}{}
+	}
+	for _, selector := range []string{"istio-injection=enabled", "istio.io/rev"} {
+		list, err := c.CoreV1().Namespaces().List(context.TODO(), kubeApiMeta.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range list.Items {
+			set[ns.Name] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(set))
+	for ns := range set {
+		out = append(out, ns)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func exportable(gvr schema.GroupVersionResource, obj unstructured.Unstructured) bool {
+	if generated[gvr.Resource+"/"+obj.GetName()] {
+		return false
+	}
+	if len(obj.GetOwnerReferences()) > 0 {
+		// Created by a controller, which recreates it on import.
+		return false
+	}
+	if gvr.Resource == "secrets" {
+		t, _, _ := unstructured.NestedString(obj.Object, "type")
+		if t == "kubernetes.io/service-account-token" || t == "istio.io/key-and-cert" {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitize removes the fields set by the API server, which would conflict on import.
+func sanitize(obj unstructured.Unstructured) map[string]interface{} {
+	o := obj.DeepCopy().Object
+	for _, f := range []string{"uid", "resourceVersion", "creationTimestamp", "generation", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(o, "metadata", f)
+	}
+	unstructured.RemoveNestedField(o, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	unstructured.RemoveNestedField(o, "metadata", "annotations", "deployment.kubernetes.io/revision")
+	unstructured.RemoveNestedField(o, "status")
+	if obj.GetKind() == "Service" {
+		if ip, _, _ := unstructured.NestedString(o, "spec", "clusterIP"); ip != "None" {
+			unstructured.RemoveNestedField(o, "spec", "clusterIP")
+			unstructured.RemoveNestedField(o, "spec", "clusterIPs")
+		}
+		unstructured.RemoveNestedField(o, "spec", "healthCheckNodePort")
+		ports, _, _ := unstructured.NestedSlice(o, "spec", "ports")
+		for _, p := range ports {
+			if m, ok := p.(map[string]interface{}); ok {
+				delete(m, "nodePort")
+			}
+		}
+		_ = unstructured.SetNestedSlice(o, ports, "spec", "ports")
+	}
+	return o
+}
+
+func writeObjects(file string, objects []unstructured.Unstructured) error {
+	if len(objects) == 0 {
+		return nil
+	}
+	docs := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		out, err := yaml.Marshal(sanitize(obj))
+		if err != nil {
+			return err
+		}
+		docs = append(docs, string(out))
+	}
+	return ioutil.WriteFile(file, []byte(strings.Join(docs, "---\n")), os.ModePerm)
+}
+
+// Load a bundle previously exported to the directory.
+func Load(dir string) (Bundle, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return Bundle{}, err
+	}
+	b := Bundle{Dir: dir}
+	for _, e := range entries {
+		if e.IsDir() {
+			b.Clusters = append(b.Clusters, e.Name())
+		}
+	}
+	sort.Strings(b.Clusters)
+	if len(b.Clusters) == 0 {
+		return Bundle{}, fmt.Errorf("no clusters found in bundle %s", dir)
+	}
+	return b, nil
+}
+
+// Files returns the files of the cluster of the bundle, in import order.
+func (b Bundle) Files(cluster string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(b.Dir, cluster, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Document is the content of a file of a bundle.
+type Document struct {
+	File    string
+	Content string
+}
+
+// Documents returns the content of the files of the cluster of the bundle, in import order.
+func (b Bundle) Documents(cluster string) ([]Document, error) {
+	files, err := b.Files(cluster)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Document, 0, len(files))
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Document{File: f, Content: string(content)})
+	}
+	return out, nil
+}
+
+// ImportOptions configures an Import.
+type ImportOptions struct {
+	// Clusters maps the clusters of the bundle, by name, to the clusters of the environment they are imported into.
+	// Clusters of the bundle that are not mapped are imported into the cluster of the environment at the same index.
+	Clusters map[string]resource.Cluster
+}
+
+func (b Bundle) target(ctx resource.Context, i int, opts ImportOptions) (resource.Cluster, error) {
+	name := b.Clusters[i]
+	if c, ok := opts.Clusters[name]; ok {
+		return c, nil
+	}
+	// Strip the index prefix of the exported directory.
+	if parts := strings.SplitN(name, "-", 2); len(parts) == 2 {
+		if c, ok := opts.Clusters[parts[1]]; ok {
+			return c, nil
+		}
+	}
+	if i >= len(ctx.Clusters()) {
+		return nil, fmt.Errorf("bundle holds %d clusters, but the environment has %d", len(b.Clusters),
+			len(ctx.Clusters()))
+	}
+	return ctx.Clusters()[i], nil
+}
+
+// Import the bundle into the environment.
+func (b Bundle) Import(ctx resource.Context, opts ImportOptions) error {
+	for i, name := range b.Clusters {
+		c, err := b.target(ctx, i, opts)
+		if err != nil {
+			return err
+		}
+		docs, err := b.Documents(name)
+		if err != nil {
+			return err
+		}
+		for _, d := range docs {
+			if err := ctx.Config(c).ApplyYAML("", d.Content); err != nil {
+				return fmt.Errorf("failed importing %s into cluster %s: %v", d.File, c.Name(), err)
+			}
+		}
+		scopes.Framework.Infof("imported %d files of %s into cluster %s", len(docs), name, c.Name())
+	}
+	return nil
+}
+
+// ImportOrFail calls Import and fails the test if it returns an error.
+func (b Bundle) ImportOrFail(t test.Failer, ctx resource.Context, opts ImportOptions) {
+	t.Helper()
+	if err := b.Import(ctx, opts); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package meshstate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// testClient is a fake client whose dynamic client holds the objects of the cluster.
+type testClient struct {
+	kube.ExtendedClient
+	dynamic dynamic.Interface
+}
+
+func (c testClient) Dynamic() dynamic.Interface {
+	return c.dynamic
+}
+
+func object(apiVersion, kind, ns, name string, fields map[string]interface{}) runtime.Object {
+	obj := map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"uid":             "uid-" + name,
+			"resourceVersion": "1",
+		},
+	}
+	if ns != "" {
+		obj["metadata"].(map[string]interface{})["namespace"] = ns
+	}
+	for k, v := range fields {
+		obj[k] = v
+	}
+	return &unstructured.Unstructured{Object: obj}
+}
+
+func testCluster() resource.Cluster {
+	namespaces := []runtime.Object{
+		&kubeApiCore.Namespace{ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:   "app",
+			Labels: map[string]string{"istio-injection": "enabled"},
+		}},
+		&kubeApiCore.Namespace{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "extra"}},
+		&kubeApiCore.Namespace{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "other"}},
+	}
+	objects := []runtime.Object{
+		object("v1", "Namespace", "", "app", map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":   "app",
+				"uid":    "uid-app",
+				"labels": map[string]interface{}{"istio-injection": "enabled"},
+			},
+		}),
+		object("v1", "Namespace", "", "extra", nil),
+		object("v1", "Namespace", "", "other", nil),
+		object("v1", "ServiceAccount", "app", "default", nil),
+		object("v1", "ServiceAccount", "app", "web", nil),
+		object("v1", "ConfigMap", "app", "istio-ca-root-cert", nil),
+		object("v1", "ConfigMap", "app", "settings", nil),
+		object("v1", "ConfigMap", "extra", "settings", nil),
+		object("v1", "ConfigMap", "other", "settings", nil),
+		object("v1", "Secret", "app", "tls", map[string]interface{}{"type": "Opaque"}),
+		object("v1", "Secret", "app", "web-token", map[string]interface{}{"type": "kubernetes.io/service-account-token"}),
+		object("v1", "Service", "app", "web", map[string]interface{}{
+			"spec": map[string]interface{}{
+				"type":      "NodePort",
+				"clusterIP": "10.0.0.5",
+				"ports":     []interface{}{map[string]interface{}{"port": int64(80), "nodePort": int64(30080)}},
+			},
+			"status": map[string]interface{}{"loadBalancer": map[string]interface{}{}},
+		}),
+		object("v1", "Service", "app", "headless", map[string]interface{}{
+			"spec": map[string]interface{}{"clusterIP": "None"},
+		}),
+		object("apps/v1", "Deployment", "app", "web", nil),
+		object("apps/v1", "Deployment", "app", "owned", map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":            "owned",
+				"namespace":       "app",
+				"ownerReferences": []interface{}{map[string]interface{}{"kind": "Operator", "name": "owner"}},
+			},
+		}),
+		object("networking.istio.io/v1alpha3", "VirtualService", "other", "web", map[string]interface{}{
+			"spec": map[string]interface{}{"hosts": []interface{}{"web"}},
+		}),
+	}
+	return resource.FakeCluster{
+		ExtendedClient: testClient{
+			ExtendedClient: kube.NewFakeClient(namespaces...).(kube.ExtendedClient),
+			dynamic:        dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...),
+		},
+		NameValue: "cluster-0",
+	}
+}
+
+// roundTrip exports the test cluster into a bundle, and loads the objects of each file of the bundle back.
+func roundTrip(t *testing.T, opts ExportOptions) (files []string, objects map[string]map[string]interface{}) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "meshstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	if err := exportCluster(testCluster(), filepath.Join(dir, "00-cluster-0"), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Clusters) != 1 || b.Clusters[0] != "00-cluster-0" {
+		t.Fatalf("unexpected clusters %v", b.Clusters)
+	}
+	docs, err := b.Documents(b.Clusters[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects = map[string]map[string]interface{}{}
+	for _, d := range docs {
+		files = append(files, filepath.Base(d.File))
+		for _, doc := range strings.Split(d.Content, "---\n") {
+			obj := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				t.Fatal(err)
+			}
+			u := unstructured.Unstructured{Object: obj}
+			objects[u.GetKind()+"/"+u.GetNamespace()+"/"+u.GetName()] = obj
+		}
+	}
+	return files, objects
+}
+
+func keys(objects map[string]map[string]interface{}) []string {
+	out := make([]string, 0, len(objects))
+	for k := range objects {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	files, objects := roundTrip(t, ExportOptions{Namespaces: []string{"extra"}})
+
+	// Namespaces are imported first, and Istio config last.
+	wantFiles := []string{
+		"00-namespaces.yaml",
+		"01-serviceaccounts.yaml",
+		"02-configmaps.yaml",
+		"04-services.yaml",
+		"05-deployments.yaml",
+		"07-virtualservices.networking.istio.io.yaml",
+	}
+	if strings.Join(files, ",") != strings.Join(wantFiles, ",") {
+		t.Fatalf("expected files %v, got %v", wantFiles, files)
+	}
+
+	// Generated, owned and unmeshed resources are not exported, but Istio config of every namespace is, with its
+	// namespace.
+	want := []string{
+		"ConfigMap/app/settings",
+		"ConfigMap/extra/settings",
+		"Deployment/app/web",
+		"Namespace//app",
+		"Namespace//extra",
+		// Holds exported Istio config, so must be created on import.
+		"Namespace//other",
+		"Service/app/headless",
+		"Service/app/web",
+		"ServiceAccount/app/web",
+		"VirtualService/other/web",
+	}
+	if got := keys(objects); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected objects %v, got %v", want, got)
+	}
+
+	// Fields set by the API server are removed, so that the objects can be applied to another cluster.
+	web := objects["Service/app/web"]
+	for _, path := range [][]string{
+		{"metadata", "uid"},
+		{"metadata", "resourceVersion"},
+		{"spec", "clusterIP"},
+		{"status"},
+	} {
+		if _, found, _ := unstructured.NestedFieldNoCopy(web, path...); found {
+			t.Errorf("expected %v to be removed from the service, got %v", path, web)
+		}
+	}
+	ports, _, _ := unstructured.NestedSlice(web, "spec", "ports")
+	if len(ports) != 1 || ports[0].(map[string]interface{})["nodePort"] != nil {
+		t.Errorf("expected the node port to be removed, got %v", ports)
+	}
+	if ip, _, _ := unstructured.NestedString(objects["Service/app/headless"], "spec", "clusterIP"); ip != "None" {
+		t.Errorf("expected the headless service to keep its cluster IP, got %q", ip)
+	}
+}
+
+func TestExportSecrets(t *testing.T) {
+	_, objects := roundTrip(t, ExportOptions{Secrets: true})
+	if _, ok := objects["Secret/app/tls"]; !ok {
+		t.Errorf("expected the secret to be exported, got %v", keys(objects))
+	}
+	if _, ok := objects["Secret/app/web-token"]; ok {
+		t.Errorf("expected the service account token not to be exported, got %v", keys(objects))
+	}
+}
+
+// importContext imports into the clusters with applier.
+type importContext struct {
+	resource.Context
+	clusters resource.Clusters
+}
+
+func (c importContext) Clusters() resource.Clusters {
+	return c.clusters
+}
+
+func (c importContext) Config(clusters ...resource.Cluster) resource.ConfigManager {
+	return applier{cluster: clusters[0]}
+}
+
+// applier creates the objects of the YAML with the dynamic client of the cluster, and rejects namespaced objects
+// whose namespace does not exist, as the API server does.
+type applier struct {
+	resource.ConfigManager
+	cluster resource.Cluster
+}
+
+func (a applier) ApplyYAML(_ string, yamlText ...string) error {
+	for _, text := range yamlText {
+		for _, doc := range strings.Split(text, "---\n") {
+			obj := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				return err
+			}
+			u := &unstructured.Unstructured{Object: obj}
+			gvk := u.GroupVersionKind()
+			gvr := schema.GroupVersionResource{
+				Group:    gvk.Group,
+				Version:  gvk.Version,
+				Resource: strings.ToLower(gvk.Kind) + "s",
+			}
+			client := a.cluster.Dynamic().Resource(gvr)
+			if ns := u.GetNamespace(); ns != "" {
+				_, err := a.cluster.Dynamic().Resource(namespacesGVR).Get(context.TODO(), ns, kubeApiMeta.GetOptions{})
+				if kubeErrors.IsNotFound(err) {
+					return fmt.Errorf("namespace %q not found", ns)
+				}
+				if err != nil {
+					return err
+				}
+				if _, err := client.Namespace(ns).Create(context.TODO(), u, kubeApiMeta.CreateOptions{}); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := client.Create(context.TODO(), u, kubeApiMeta.CreateOptions{}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "meshstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	if err := exportCluster(testCluster(), filepath.Join(dir, "00-cluster-0"), ExportOptions{Secrets: true}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, exported := roundTrip(t, ExportOptions{Secrets: true})
+
+	target := resource.FakeCluster{
+		ExtendedClient: testClient{
+			ExtendedClient: kube.NewFakeClient().(kube.ExtendedClient),
+			dynamic:        dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		},
+		NameValue: "target",
+	}
+	if err := b.Import(importContext{clusters: resource.Clusters{target}}, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every exported object, including Istio config outside of the meshed namespaces, is imported.
+	for key, obj := range exported {
+		u := unstructured.Unstructured{Object: obj}
+		gvk := u.GroupVersionKind()
+		gvr := schema.GroupVersionResource{Group: gvk.Group, Version: gvk.Version, Resource: strings.ToLower(gvk.Kind) + "s"}
+		if _, err := target.Dynamic().Resource(gvr).Namespace(u.GetNamespace()).Get(context.TODO(), u.GetName(),
+			kubeApiMeta.GetOptions{}); err != nil {
+			t.Errorf("expected %s to be imported: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay feeds a cluster of a mesh state bundle to an in-process istiod, and snapshots the xDS it generates
+// for selected proxies. Snapshots written by one version of the code can be compared against those of another, to
+// find how a change affects the config of a user-reported mesh.
+//
+// Pods and Endpoints are not part of bundles, so a pod is synthesized for each deployment of a selected proxy, with
+// the labels, annotations and service account of its template, and the Endpoints of each service select the
+// synthesized pods it matches. Endpoints of other workloads are not generated.
+package replay
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/go-cmp/cmp"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/xds"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/meshstate"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+// Proxy selects a proxy whose xDS is generated, by the deployment it runs in.
+type Proxy struct {
+	Namespace  string
+	Deployment string
+	// Router generates the config of a gateway rather than of a sidecar.
+	Router bool
+}
+
+// ParseProxy parses a proxy from "namespace/deployment", prefixed with "router~" for gateways.
+func ParseProxy(s string) (Proxy, error) {
+	p := Proxy{}
+	if strings.HasPrefix(s, "router~") {
+		p.Router = true
+		s = strings.TrimPrefix(s, "router~")
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return Proxy{}, fmt.Errorf("invalid proxy %q, expected [router~]namespace/deployment", s)
+	}
+	p.Namespace, p.Deployment = parts[0], parts[1]
+	return p, nil
+}
+
+func (p Proxy) String() string {
+	return p.Namespace + "." + p.Deployment
+}
+
+// Snapshot of the xDS generated for each proxy, as JSON by proxy and type.
+type Snapshot map[string]map[string]string
+
+// state of a cluster of a bundle, split into the inputs of the in-process istiod.
+type state struct {
+	objects []runtime.Object
+	config  []string
+}
+
+func load(b meshstate.Bundle, cluster string) (state, error) {
+	files, err := b.Files(cluster)
+	if err != nil {
+		return state{}, err
+	}
+	s := state{}
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	for _, f := range files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			return state{}, err
+		}
+		for _, doc := range strings.Split(string(content), "---\n") {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			meta := struct {
+				APIVersion string `json:"apiVersion"`
+			}{}
+			if err := yaml.Unmarshal([]byte(doc), &meta); err != nil {
+				return state{}, fmt.Errorf("invalid object in %s: %v", f, err)
+			}
+			if strings.Contains(meta.APIVersion, "istio.io/") || strings.Contains(meta.APIVersion, "x-k8s.io/") {
+				s.config = append(s.config, doc)
+				continue
+			}
+			obj, _, err := decode([]byte(doc), nil, nil)
+			if err != nil {
+				return state{}, fmt.Errorf("failed decoding object in %s: %v", f, err)
+			}
+			s.objects = append(s.objects, obj)
+		}
+	}
+	s.assignClusterIPs()
+	return s, nil
+}
+
+// assignClusterIPs assigns deterministic addresses to the services, whose addresses are not exported.
+func (s *state) assignClusterIPs() {
+	var services []*corev1.Service
+	for _, o := range s.objects {
+		if svc, ok := o.(*corev1.Service); ok && svc.Spec.ClusterIP == "" {
+			services = append(services, svc)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Namespace+"/"+services[i].Name < services[j].Namespace+"/"+services[j].Name
+	})
+	for i, svc := range services {
+		svc.Spec.ClusterIP = fmt.Sprintf("10.96.%d.%d", (i+1)/256, (i+1)%256)
+	}
+}
+
+// pod synthesizes the pod of a proxy from its deployment, and returns it with its address.
+func (s *state) pod(p Proxy, n int) (*corev1.Pod, error) {
+	for _, o := range s.objects {
+		d, ok := o.(*appsv1.Deployment)
+		if !ok || d.Namespace != p.Namespace || d.Name != p.Deployment {
+			continue
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: d.Spec.Template.ObjectMeta,
+			Spec:       d.Spec.Template.Spec,
+		}
+		pod.Name = d.Name + "-replay"
+		pod.Namespace = d.Namespace
+		pod.Status = corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: fmt.Sprintf("10.244.%d.%d", (n+1)/256, (n+1)%256),
+			Conditions: []corev1.PodCondition{{
+				Type:   corev1.PodReady,
+				Status: corev1.ConditionTrue,
+			}},
+		}
+		return pod, nil
+	}
+	return nil, fmt.Errorf("deployment %s/%s not found in bundle", p.Namespace, p.Deployment)
+}
+
+// endpoints synthesizes the Endpoints of the services of the bundle, with an address for each of the pods their
+// selector matches.
+func (s *state) endpoints(pods []*corev1.Pod) []*corev1.Endpoints {
+	var out []*corev1.Endpoints
+	for _, o := range s.objects {
+		svc, ok := o.(*corev1.Service)
+		if !ok || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		ep := &corev1.Endpoints{}
+		ep.Name = svc.Name
+		ep.Namespace = svc.Namespace
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		for _, pod := range pods {
+			if pod.Namespace != svc.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			subset := corev1.EndpointSubset{
+				Addresses: []corev1.EndpointAddress{{
+					IP: pod.Status.PodIP,
+					TargetRef: &corev1.ObjectReference{
+						Kind:      "Pod",
+						Namespace: pod.Namespace,
+						Name:      pod.Name,
+					},
+				}},
+			}
+			for _, p := range svc.Spec.Ports {
+				subset.Ports = append(subset.Ports, corev1.EndpointPort{
+					Name:     p.Name,
+					Port:     targetPort(p, pod),
+					Protocol: p.Protocol,
+				})
+			}
+			ep.Subsets = append(ep.Subsets, subset)
+		}
+		out = append(out, ep)
+	}
+	return out
+}
+
+// targetPort resolves the port of the pod that the service port targets, which may name a container port.
+func targetPort(p corev1.ServicePort, pod *corev1.Pod) int32 {
+	switch {
+	case p.TargetPort.Type == intstr.String:
+		for _, c := range pod.Spec.Containers {
+			for _, cp := range c.Ports {
+				if cp.Name == p.TargetPort.StrVal {
+					return cp.ContainerPort
+				}
+			}
+		}
+	case p.TargetPort.IntVal != 0:
+		return p.TargetPort.IntVal
+	}
+	return p.Port
+}
+
+// Replay the cluster of the bundle into an in-process istiod, and snapshot the xDS generated for the proxies.
+func Replay(t test.Failer, b meshstate.Bundle, cluster string, proxies []Proxy) (Snapshot, error) {
+	s, err := load(b, cluster)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*corev1.Pod, 0, len(proxies))
+	for n, p := range proxies {
+		pod, err := s.pod(p, n)
+		if err != nil {
+			return nil, err
+		}
+		pods = append(pods, pod)
+		s.objects = append(s.objects, pod)
+	}
+	for _, ep := range s.endpoints(pods) {
+		s.objects = append(s.objects, ep)
+	}
+
+	istiod := xds.NewFakeDiscoveryServer(t, xds.FakeOptions{
+		KubernetesObjects: s.objects,
+		ConfigString:      strings.Join(s.config, "---\n"),
+	})
+
+	snapshot := Snapshot{}
+	for n, p := range proxies {
+		pod := pods[n]
+		proxyType := model.SidecarProxy
+		if p.Router {
+			proxyType = model.Router
+		}
+		proxy := istiod.SetupProxy(&model.Proxy{
+			Type:            proxyType,
+			ID:              pod.Name + "." + pod.Namespace,
+			ConfigNamespace: pod.Namespace,
+			IPAddresses:     []string{pod.Status.PodIP},
+			Metadata: &model.NodeMetadata{
+				Namespace:      pod.Namespace,
+				Labels:         pod.Labels,
+				ServiceAccount: pod.Spec.ServiceAccountName,
+			},
+		})
+		out := map[string]string{}
+		resources := map[string][]proto.Message{}
+		for _, l := range istiod.Listeners(proxy) {
+			resources["listeners"] = append(resources["listeners"], l)
+		}
+		for _, c := range istiod.Clusters(proxy) {
+			resources["clusters"] = append(resources["clusters"], c)
+		}
+		for _, r := range istiod.Routes(proxy) {
+			resources["routes"] = append(resources["routes"], r)
+		}
+		for _, e := range istiod.Endpoints(proxy) {
+			resources["endpoints"] = append(resources["endpoints"], e)
+		}
+		for typ, resources := range resources {
+			js, err := toJSON(resources)
+			if err != nil {
+				return nil, fmt.Errorf("failed marshaling %s of %v: %v", typ, p, err)
+			}
+			out[typ] = js
+		}
+		snapshot[p.String()] = out
+	}
+	return snapshot, nil
+}
+
+// toJSON marshals the resources, sorted by the JSON of each, so that snapshots are stable across runs.
+func toJSON(resources []proto.Message) (string, error) {
+	docs := make([]string, 0, len(resources))
+	for _, r := range resources {
+		js, err := protomarshal.ToJSONWithIndent(r, "  ")
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, js)
+	}
+	sort.Strings(docs)
+	return "[\n" + strings.Join(docs, ",\n") + "\n]\n", nil
+}
+
+// Write the snapshot to the directory, as a file per proxy and type.
+func (s Snapshot) Write(dir string) error {
+	for proxy, types := range s {
+		if err := os.MkdirAll(filepath.Join(dir, proxy), os.ModePerm); err != nil {
+			return err
+		}
+		for typ, js := range types {
+			if err := ioutil.WriteFile(filepath.Join(dir, proxy, typ+".json"), []byte(js), os.ModePerm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readSnapshot reads a snapshot written to the directory.
+func readSnapshot(dir string) (Snapshot, error) {
+	proxies, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := Snapshot{}
+	for _, proxy := range proxies {
+		if !proxy.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(dir, proxy.Name()))
+		if err != nil {
+			return nil, err
+		}
+		types := map[string]string{}
+		for _, f := range files {
+			if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+				continue
+			}
+			js, err := ioutil.ReadFile(filepath.Join(dir, proxy.Name(), f.Name()))
+			if err != nil {
+				return nil, err
+			}
+			types[strings.TrimSuffix(f.Name(), ".json")] = string(js)
+		}
+		s[proxy.Name()] = types
+	}
+	return s, nil
+}
+
+// Compare the snapshot against one written to the directory by another version of the code, returning the
+// differences by proxy and type. Proxies and types in only one of the snapshots, such as a type for which no
+// resources are generated anymore, are reported as missing from the other.
+func (s Snapshot) Compare(dir string) (map[string]string, error) {
+	baseline, err := readSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+	diffs := map[string]string{}
+	for proxy, types := range s {
+		for typ, js := range types {
+			b, ok := baseline[proxy][typ]
+			if !ok {
+				diffs[proxy+"/"+typ] = "missing from the baseline"
+				continue
+			}
+			if d := cmp.Diff(strings.Split(b, "\n"), strings.Split(js, "\n")); d != "" {
+				diffs[proxy+"/"+typ] = d
+			}
+		}
+	}
+	for proxy, types := range baseline {
+		for typ := range types {
+			if _, ok := s[proxy][typ]; !ok {
+				diffs[proxy+"/"+typ] = "missing from the current snapshot"
+			}
+		}
+	}
+	return diffs, nil
+}
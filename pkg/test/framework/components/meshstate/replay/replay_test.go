@@ -0,0 +1,231 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"istio.io/istio/pkg/test/framework/components/meshstate"
+)
+
+const (
+	testBundle  = "testdata/bundle"
+	testProxies = "demo/client,demo/server"
+)
+
+var (
+	bundle   = flag.String("bundle", testBundle, "Directory of the mesh state bundle to replay.")
+	cluster  = flag.String("cluster", "", "Cluster of the bundle to replay. Defaults to the first cluster.")
+	proxies  = flag.String("proxies", testProxies, "Comma separated list of proxies, as [router~]namespace/deployment.")
+	out      = flag.String("out", "", "Directory the xDS snapshot is written to.")
+	baseline = flag.String("baseline", "", "Directory of a snapshot written by another version, to compare against.")
+)
+
+// TestReplay replays a bundle into an in-process istiod, by default the bundle of testdata. It is also used for A/B
+// comparisons of the xDS generated for other bundles, e.g.:
+//
+//	git checkout A && go test ./pkg/test/framework/components/meshstate/replay -run TestReplay \
+//	  -args -bundle=/tmp/bundle -proxies=default/productpage-v1 -out=/tmp/a
+//	git checkout B && go test ./pkg/test/framework/components/meshstate/replay -run TestReplay \
+//	  -args -bundle=/tmp/bundle -proxies=default/productpage-v1 -baseline=/tmp/a
+func TestReplay(t *testing.T) {
+	b, err := meshstate.Load(*bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := *cluster
+	if c == "" {
+		c = b.Clusters[0]
+	}
+	var selected []Proxy
+	for _, s := range strings.Split(*proxies, ",") {
+		p, err := ParseProxy(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selected = append(selected, p)
+	}
+
+	snapshot, err := Replay(t, b, c, selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *bundle == testBundle && *proxies == testProxies {
+		checkTestBundle(t, snapshot)
+	}
+	if *out != "" {
+		if err := snapshot.Write(*out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *baseline != "" {
+		diffs, err := snapshot.Compare(*baseline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, d := range diffs {
+			t.Errorf("%s differs from the baseline (-baseline +current):\n%s", name, d)
+		}
+	}
+}
+
+// checkTestBundle checks the xDS generated for the client of the bundle of testdata, which routes to the server with
+// a timeout, through the Endpoints synthesized for the pod of the server.
+func checkTestBundle(t *testing.T, snapshot Snapshot) {
+	t.Helper()
+	client, ok := snapshot["demo.client"]
+	if !ok {
+		t.Fatalf("no snapshot of demo.client, got %v", snapshot)
+	}
+	for _, typ := range []string{"listeners", "clusters", "routes", "endpoints"} {
+		if client[typ] == "" {
+			t.Errorf("no %s generated for demo.client", typ)
+		}
+	}
+	if !strings.Contains(client["clusters"], "outbound|80||server.demo.svc.cluster.local") {
+		t.Errorf("no cluster of the server in:\n%s", client["clusters"])
+	}
+	if !strings.Contains(client["routes"], `"5s"`) {
+		t.Errorf("no route with the timeout of the VirtualService in:\n%s", client["routes"])
+	}
+	// The server is the second proxy, and its service targets the container port named http.
+	if !strings.Contains(client["endpoints"], `"10.244.0.2"`) || !strings.Contains(client["endpoints"], "9080") {
+		t.Errorf("no endpoint of the server pod in:\n%s", client["endpoints"])
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    Proxy
+		wantErr bool
+	}{
+		{in: "default/productpage", want: Proxy{Namespace: "default", Deployment: "productpage"}},
+		{
+			in:   "router~istio-system/istio-ingressgateway",
+			want: Proxy{Namespace: "istio-system", Deployment: "istio-ingressgateway", Router: true},
+		},
+		{in: "productpage", wantErr: true},
+		{in: "default/", wantErr: true},
+		{in: "a/b/c", wantErr: true},
+	}
+	for _, tt := range cases {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseProxy(tt.in)
+			if gotErr := err != nil; gotErr != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAssignClusterIPs(t *testing.T) {
+	service := func(ns, name, ip string) *corev1.Service {
+		svc := &corev1.Service{}
+		svc.Namespace, svc.Name, svc.Spec.ClusterIP = ns, name, ip
+		return svc
+	}
+	b, a, headless := service("ns", "b", ""), service("ns", "a", ""), service("ns", "c", "None")
+	s := &state{objects: []runtime.Object{b, a, headless}}
+	s.assignClusterIPs()
+	// Addresses are assigned in the order of the names of the services, so that they are stable across runs.
+	if a.Spec.ClusterIP != "10.96.0.1" || b.Spec.ClusterIP != "10.96.0.2" || headless.Spec.ClusterIP != "None" {
+		t.Fatalf("unexpected addresses: a=%s b=%s c=%s", a.Spec.ClusterIP, b.Spec.ClusterIP, headless.Spec.ClusterIP)
+	}
+}
+
+func TestTargetPort(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 9080}},
+	}}}}
+	cases := []struct {
+		name   string
+		target intstr.IntOrString
+		want   int32
+	}{
+		{"number", intstr.FromInt(8080), 8080},
+		{"name", intstr.FromString("http"), 9080},
+		{"unknown name", intstr.FromString("grpc"), 80},
+		{"unset", intstr.IntOrString{}, 80},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := targetPort(corev1.ServicePort{Port: 80, TargetPort: tt.target}, pod); got != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	baseline := Snapshot{
+		"demo.client": {"clusters": "[\n  a\n]\n", "routes": "[\n  r\n]\n"},
+		"demo.server": {"clusters": "[\n  a\n]\n"},
+	}
+	if err := baseline.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	current := Snapshot{
+		// The routes are no longer generated, and the clusters differ.
+		"demo.client":  {"clusters": "[\n  b\n]\n", "listeners": "[\n  l\n]\n"},
+		"demo.gateway": {"clusters": "[\n  a\n]\n"},
+	}
+	diffs, err := current.Compare(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for name := range diffs {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	want := []string{
+		"demo.client/clusters", "demo.client/listeners", "demo.client/routes", "demo.gateway/clusters",
+		"demo.server/clusters",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got differences in %v, want %v", got, want)
+	}
+	if diffs["demo.client/routes"] != "missing from the current snapshot" ||
+		diffs["demo.client/listeners"] != "missing from the baseline" {
+		t.Fatalf("unexpected differences: %v", diffs)
+	}
+
+	if diffs, err := baseline.Compare(dir); err != nil || len(diffs) != 0 {
+		t.Fatalf("expected no differences comparing a snapshot against itself, got %v, %v", diffs, err)
+	}
+	if _, err := baseline.Compare(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected an error comparing against a missing baseline")
+	}
+}
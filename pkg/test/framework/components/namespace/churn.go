@@ -0,0 +1,237 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package namespace
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+const (
+	defaultChurnInterval      = 5 * time.Second
+	defaultChurnMaxNamespaces = 5
+)
+
+// ChurnConfig configures the namespaces created and deleted by a Churner.
+type ChurnConfig struct {
+	// Namespace is the configuration for each of the generated namespaces. If Prefix is unset, "churn" is used.
+	Namespace Config
+	// Interval between namespace creations. Defaults to 5s.
+	Interval time.Duration
+	// Lifetime of each namespace before it is deleted. If unset, namespaces are only deleted to stay within
+	// MaxNamespaces.
+	Lifetime time.Duration
+	// MaxNamespaces is the maximum number of generated namespaces that exist at once. When exceeded, the
+	// oldest namespace is deleted. Defaults to 5.
+	MaxNamespaces int
+	// Workload is optional yaml applied to each namespace after it is created, such as a Deployment and
+	// Service, so that the namespace has endpoints and proxies.
+	Workload string
+}
+
+// ChurnStats reports the activity of a Churner.
+type ChurnStats struct {
+	Created int
+	Deleted int
+}
+
+// Churner continuously creates and deletes namespaces in the background to exercise the namespace
+// handling paths of the control plane (injection, discovery selectors, telemetry) under churn.
+type Churner struct {
+	id  resource.ID
+	cfg ChurnConfig
+	// create creates each namespace, and apply applies the workload to it.
+	create func() (Instance, error)
+	apply  func(ns string) error
+
+	mu    sync.Mutex
+	live  []churnedNamespace
+	stats ChurnStats
+	errs  error
+
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+type churnedNamespace struct {
+	Instance
+	created time.Time
+}
+
+var _ io.Closer = &Churner{}
+var _ resource.Resource = &Churner{}
+
+// Churn starts creating and deleting namespaces according to the config. The churn continues until Stop is
+// called or the context is closed.
+func Churn(ctx resource.Context, cfg ChurnConfig) (*Churner, error) {
+	if ctx.Settings().StableNamespaces {
+		return nil, fmt.Errorf("namespace churn is not supported with stable namespaces")
+	}
+	c := newChurner(cfg, func(nsConfig *Config) (Instance, error) {
+		return newKube(ctx, nsConfig)
+	}, ctx.Config().ApplyYAML)
+	c.id = ctx.TrackResource(c)
+	go c.run()
+	return c, nil
+}
+
+func newChurner(cfg ChurnConfig, create func(*Config) (Instance, error),
+	apply func(ns string, yamlText ...string) error) *Churner {
+	if cfg.Namespace.Prefix == "" {
+		cfg.Namespace.Prefix = "churn"
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultChurnInterval
+	}
+	if cfg.MaxNamespaces <= 0 {
+		cfg.MaxNamespaces = defaultChurnMaxNamespaces
+	}
+	c := &Churner{
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	c.create = func() (Instance, error) {
+		return create(&c.cfg.Namespace)
+	}
+	c.apply = func(ns string) error {
+		return apply(ns, c.cfg.Workload)
+	}
+	return c
+}
+
+// ChurnOrFail calls Churn and fails the test if it returns an error.
+func ChurnOrFail(t test.Failer, ctx resource.Context, cfg ChurnConfig) *Churner {
+	t.Helper()
+	c, err := Churn(ctx, cfg)
+	if err != nil {
+		t.Fatalf("namespace.ChurnOrFail: %v", err)
+	}
+	return c
+}
+
+func (c *Churner) ID() resource.ID {
+	return c.id
+}
+
+func (c *Churner) run() {
+	defer close(c.stopped)
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		c.step()
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// step deletes expired namespaces and creates a new one.
+func (c *Churner) step() {
+	if c.cfg.Lifetime > 0 {
+		for len(c.live) > 0 && time.Since(c.live[0].created) >= c.cfg.Lifetime {
+			c.deleteOldest()
+		}
+	}
+	for len(c.live) >= c.cfg.MaxNamespaces {
+		c.deleteOldest()
+	}
+
+	ns, err := c.create()
+	if err != nil {
+		c.recordError(fmt.Errorf("failed creating namespace: %v", err))
+		return
+	}
+	c.live = append(c.live, churnedNamespace{Instance: ns, created: time.Now()})
+	c.mu.Lock()
+	c.stats.Created++
+	c.mu.Unlock()
+	scopes.Framework.Debugf("%s created namespace %s", c.id, ns.Name())
+
+	if c.cfg.Workload != "" {
+		if err := c.apply(ns.Name()); err != nil {
+			c.recordError(fmt.Errorf("failed applying workload to namespace %s: %v", ns.Name(), err))
+		}
+	}
+}
+
+func (c *Churner) deleteOldest() {
+	ns := c.live[0]
+	c.live = c.live[1:]
+	name := ns.Name()
+	if err := ns.Instance.(io.Closer).Close(); err != nil {
+		c.recordError(fmt.Errorf("failed deleting namespace %s: %v", name, err))
+		return
+	}
+	c.mu.Lock()
+	c.stats.Deleted++
+	c.mu.Unlock()
+	scopes.Framework.Debugf("%s deleted namespace %s", c.id, name)
+}
+
+func (c *Churner) recordError(err error) {
+	scopes.Framework.Warnf("%s: %v", c.id, err)
+	c.mu.Lock()
+	c.errs = multierror.Append(c.errs, err)
+	c.mu.Unlock()
+}
+
+// Stats returns the number of namespaces created and deleted so far.
+func (c *Churner) Stats() ChurnStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Stop halts the churn and deletes the remaining generated namespaces. Any errors encountered while
+// churning are returned.
+func (c *Churner) Stop() error {
+	c.once.Do(func() {
+		close(c.stop)
+		<-c.stopped
+		for len(c.live) > 0 {
+			c.deleteOldest()
+		}
+		scopes.Framework.Infof("%s stopped after creating %d and deleting %d namespaces",
+			c.id, c.stats.Created, c.stats.Deleted)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.errs
+}
+
+// StopOrFail calls Stop and fails the test if it returns an error.
+func (c *Churner) StopOrFail(t test.Failer) {
+	t.Helper()
+	if err := c.Stop(); err != nil {
+		t.Fatalf("namespace churn failed: %v", err)
+	}
+}
+
+// Close implements io.Closer
+func (c *Churner) Close() error {
+	return c.Stop()
+}
@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNamespaces records the namespaces created and deleted by a Churner.
+type fakeNamespaces struct {
+	mu      sync.Mutex
+	created []string
+	applied []string
+	live    map[string]bool
+}
+
+func (f *fakeNamespaces) create(cfg *Config) (Instance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := fmt.Sprintf("%s-%d", cfg.Prefix, len(f.created))
+	f.created = append(f.created, name)
+	f.live[name] = true
+	return Fake{NameValue: name, CloseFunc: func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.live, name)
+		return nil
+	}}, nil
+}
+
+func (f *fakeNamespaces) apply(ns string, yamlText ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.applied = append(f.applied, ns)
+	return nil
+}
+
+func (f *fakeNamespaces) liveCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.live)
+}
+
+func TestChurnMaxNamespaces(t *testing.T) {
+	f := &fakeNamespaces{live: map[string]bool{}}
+	c := newChurner(ChurnConfig{MaxNamespaces: 2, Workload: "kind: Service"}, f.create, f.apply)
+	for i := 0; i < 5; i++ {
+		c.step()
+	}
+	if got := f.liveCount(); got != 2 {
+		t.Fatalf("got %d live namespaces, want 2", got)
+	}
+	if !f.live["churn-3"] || !f.live["churn-4"] {
+		t.Fatalf("expected the newest namespaces to be kept, got %v", f.live)
+	}
+	if stats := c.Stats(); stats != (ChurnStats{Created: 5, Deleted: 3}) {
+		t.Fatalf("got %+v, want 5 created and 3 deleted", stats)
+	}
+	if len(f.applied) != 5 {
+		t.Fatalf("expected the workload to be applied to each namespace, got %v", f.applied)
+	}
+}
+
+func TestChurnLifetime(t *testing.T) {
+	f := &fakeNamespaces{live: map[string]bool{}}
+	c := newChurner(ChurnConfig{Lifetime: time.Hour, MaxNamespaces: 10}, f.create, f.apply)
+	c.step()
+	c.step()
+	c.live[0].created = time.Now().Add(-2 * time.Hour)
+	c.step()
+	if f.live["churn-0"] || !f.live["churn-1"] || !f.live["churn-2"] {
+		t.Fatalf("expected only the expired namespace to be deleted, got %v", f.live)
+	}
+	if len(f.applied) != 0 {
+		t.Fatalf("expected no workload to be applied, got %v", f.applied)
+	}
+}
+
+func TestChurnStop(t *testing.T) {
+	f := &fakeNamespaces{live: map[string]bool{}}
+	c := newChurner(ChurnConfig{Interval: time.Millisecond, MaxNamespaces: 2}, f.create, f.apply)
+	go c.run()
+	for c.Stats().Created < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.liveCount(); got != 0 {
+		t.Fatalf("expected every namespace to be deleted once stopped, got %d", got)
+	}
+	if stats := c.Stats(); stats.Created != stats.Deleted {
+		t.Fatalf("got %+v, want as many namespaces deleted as created", stats)
+	}
+}
+
+func TestChurnErrors(t *testing.T) {
+	c := newChurner(ChurnConfig{}, func(*Config) (Instance, error) {
+		return nil, errors.New("forbidden")
+	}, nil)
+	c.step()
+	close(c.stopped)
+	if err := c.Stop(); err == nil {
+		t.Fatal("expected the failed creation to be reported")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	kubeApiCore "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"istio.io/api/label"
@@ -87,13 +88,16 @@ func (n *kubeNamespace) Close() (err error) {
 }
 
 func claimKube(ctx resource.Context, name string, injectSidecar bool) (Instance, error) {
-	env := ctx.Environment().(*kube.Environment)
+	env, err := kube.EnvironmentFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, cluster := range env.KubeClusters {
 		if !kube2.NamespaceExists(cluster, name) {
-			nsConfig := Config{
+			nsConfig := forTenant(ctx.Settings(), Config{
 				Inject: injectSidecar,
-			}
+			})
 
 			if _, err := cluster.CoreV1().Namespaces().Create(context.TODO(), &kubeApiCore.Namespace{
 				ObjectMeta: kubeApiMeta.ObjectMeta{
@@ -116,7 +120,9 @@ func newKube(ctx resource.Context, nsConfig *Config) (Instance, error) {
 	r := rnd.Intn(99999)
 	mu.Unlock()
 
-	ns := fmt.Sprintf("%s-%d-%d", nsConfig.Prefix, nsid, r)
+	cfg := forTenant(ctx.Settings(), *nsConfig)
+	nsConfig = &cfg
+	ns := fmt.Sprintf("%s%s-%d-%d", ctx.Settings().TenantPrefix(), nsConfig.Prefix, nsid, r)
 	n := &kubeNamespace{
 		name: ns,
 		ctx:  ctx,
@@ -124,13 +130,23 @@ func newKube(ctx resource.Context, nsConfig *Config) (Instance, error) {
 	id := ctx.TrackResource(n)
 	n.id = id
 
+	labels := createNamespaceLabels(nsConfig)
+	labels[RunLabel] = ctx.Settings().RunID.String()
+	if ctx.Settings().PodSecurityRestricted {
+		// Only namespaces created for tests are restricted, rather than claimed ones such as the system namespace.
+		labels[PodSecurityEnforceLabel] = PodSecurityRestricted
+	}
 	for _, cluster := range n.ctx.Clusters() {
 		if _, err := cluster.CoreV1().Namespaces().Create(context.TODO(), &kubeApiCore.Namespace{
 			ObjectMeta: kubeApiMeta.ObjectMeta{
 				Name:   ns,
-				Labels: createNamespaceLabels(nsConfig),
+				Labels: labels,
 			},
 		}, kubeApiMeta.CreateOptions{}); err != nil {
+			if kc, ok := cluster.(kube.Cluster); ok && kc.IsVirtual() && kerrors.IsAlreadyExists(err) {
+				// Created through another virtual cluster sharing the physical cluster.
+				continue
+			}
 			return nil, err
 		}
 	}
@@ -138,6 +154,23 @@ func newKube(ctx resource.Context, nsConfig *Config) (Instance, error) {
 	return n, nil
 }
 
+// forTenant returns the config of a namespace of the tenant of the run, if any. The namespace is labeled with the
+// tenant, and injected by the revision of the tenant unless another is set.
+func forTenant(s *resource.Settings, cfg Config) Config {
+	if s.Tenant == "" {
+		return cfg
+	}
+	if cfg.Inject && cfg.Revision == "" {
+		cfg.Revision = s.Tenant
+	}
+	labels := map[string]string{TenantLabel: s.Tenant}
+	for k, v := range cfg.Labels {
+		labels[k] = v
+	}
+	cfg.Labels = labels
+	return cfg
+}
+
 // createNamespaceLabels will take a namespace config and generate the proper k8s labels
 func createNamespaceLabels(cfg *Config) map[string]string {
 	l := make(map[string]string)
@@ -0,0 +1,63 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func TestForTenant(t *testing.T) {
+	cases := []struct {
+		name   string
+		tenant string
+		in     Config
+		want   Config
+	}{
+		{
+			name: "no tenant",
+			in:   Config{Prefix: "a", Inject: true, Labels: map[string]string{"app": "a"}},
+			want: Config{Prefix: "a", Inject: true, Labels: map[string]string{"app": "a"}},
+		},
+		{
+			name:   "injected",
+			tenant: "t1",
+			in:     Config{Prefix: "a", Inject: true},
+			want:   Config{Prefix: "a", Inject: true, Revision: "t1", Labels: map[string]string{TenantLabel: "t1"}},
+		},
+		{
+			name:   "revision",
+			tenant: "t1",
+			in:     Config{Prefix: "a", Inject: true, Revision: "canary"},
+			want: Config{Prefix: "a", Inject: true, Revision: "canary",
+				Labels: map[string]string{TenantLabel: "t1"}},
+		},
+		{
+			name:   "not injected",
+			tenant: "t1",
+			in:     Config{Prefix: "a", Labels: map[string]string{"app": "a"}},
+			want:   Config{Prefix: "a", Labels: map[string]string{TenantLabel: "t1", "app": "a"}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(forTenant(&resource.Settings{Tenant: tt.tenant}, tt.in)).To(Equal(tt.want))
+		})
+	}
+}
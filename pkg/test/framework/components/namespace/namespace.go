@@ -19,6 +19,21 @@ import (
 	"istio.io/istio/pkg/test/framework/resource"
 )
 
+const (
+	// PodSecurityEnforceLabel is the namespace label selecting the PodSecurity profile enforced on its pods.
+	PodSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+	// PodSecurityRestricted is the most restrictive PodSecurity profile.
+	PodSecurityRestricted = "restricted"
+	// TenantLabel is the label holding the tenant of the run that created the namespace.
+	TenantLabel = "istio.io/test-tenant"
+	// RunLabel is the label holding the ID of the run that created the namespace, or another resource that is not
+	// deleted along with the namespaces of the run, such as a CRD.
+	RunLabel = "istio.io/test-run"
+	// AbortedAnnotation is set on the namespaces of a run that was interrupted, to the time it was, so that they can
+	// be found and deleted if the run could not clean them up. It is not set if cleanup is disabled.
+	AbortedAnnotation = "istio.io/test-aborted"
+)
+
 // Config contains configuration information about the namespace instance
 type Config struct {
 	// Prefix to use for autogenerated namespace name
@@ -54,7 +69,7 @@ func ClaimOrFail(t test.Failer, ctx resource.Context, name string) Instance {
 // New creates a new Namespace in all clusters.
 func New(ctx resource.Context, nsConfig Config) (i Instance, err error) {
 	if ctx.Settings().StableNamespaces {
-		return Claim(ctx, nsConfig.Prefix, nsConfig.Inject)
+		return Claim(ctx, ctx.Settings().TenantPrefix()+nsConfig.Prefix, nsConfig.Inject)
 	}
 	return newKube(ctx, &nsConfig)
 }
@@ -68,3 +83,23 @@ func NewOrFail(t test.Failer, ctx resource.Context, nsConfig Config) Instance {
 	}
 	return i
 }
+
+var _ Instance = Fake{}
+
+// Fake used for testing.
+type Fake struct {
+	NameValue string
+	// CloseFunc is called when the namespace is closed, if set.
+	CloseFunc func() error
+}
+
+func (n Fake) Name() string {
+	return n.NameValue
+}
+
+func (n Fake) Close() error {
+	if n.CloseFunc == nil {
+		return nil
+	}
+	return n.CloseFunc()
+}
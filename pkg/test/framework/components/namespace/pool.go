@@ -0,0 +1,213 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	defaultPoolSize = 5
+	// rootCertConfigMap is distributed by istiod to every namespace, and is mounted by injected proxies.
+	rootCertConfigMap = "istio-ca-root-cert"
+)
+
+// PoolConfig configures the namespaces of a Pool.
+type PoolConfig struct {
+	// Namespace is the configuration for each of the pooled namespaces. If Prefix is unset, "pool" is used.
+	Namespace Config
+	// Size is the number of namespaces kept ready. Defaults to 5.
+	Size int
+}
+
+// Pool keeps namespaces created ahead of the tests that use them, so that tests do not wait for namespace creation
+// and for the namespace to be ready for pods: its default service account, and the root certificate of the mesh if
+// injected. Each namespace handed out is replaced in the background.
+type Pool struct {
+	id  resource.ID
+	cfg PoolConfig
+	// create creates each namespace, and waitForReady waits until it is ready for pods.
+	create       func() (Instance, error)
+	waitForReady func(ns Instance) error
+
+	ready   chan pooledNamespace
+	stop    chan struct{}
+	once    sync.Once
+	fillers sync.WaitGroup
+}
+
+type pooledNamespace struct {
+	ns  Instance
+	err error
+}
+
+var _ io.Closer = &Pool{}
+var _ resource.Resource = &Pool{}
+
+// NewPool starts creating the namespaces of the pool. Pooled namespaces that are not handed out are deleted when the
+// context is closed.
+func NewPool(ctx resource.Context, cfg PoolConfig) (*Pool, error) {
+	if ctx.Settings().StableNamespaces {
+		return nil, fmt.Errorf("namespace pools are not supported with stable namespaces")
+	}
+	p := newPool(cfg, func(nsConfig *Config) (Instance, error) {
+		return newKube(ctx, nsConfig)
+	}, func(ns Instance, inject bool) error {
+		return waitForReady(ctx.Clusters(), ns, inject)
+	})
+	p.id = ctx.TrackResource(p)
+	p.fill()
+	return p, nil
+}
+
+func newPool(cfg PoolConfig, create func(*Config) (Instance, error),
+	ready func(ns Instance, inject bool) error) *Pool {
+	if cfg.Namespace.Prefix == "" {
+		cfg.Namespace.Prefix = "pool"
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = defaultPoolSize
+	}
+	p := &Pool{
+		cfg:   cfg,
+		ready: make(chan pooledNamespace, cfg.Size),
+		stop:  make(chan struct{}),
+	}
+	p.create = func() (Instance, error) {
+		nsConfig := p.cfg.Namespace
+		return create(&nsConfig)
+	}
+	p.waitForReady = func(ns Instance) error {
+		return ready(ns, p.cfg.Namespace.Inject)
+	}
+	return p
+}
+
+// NewPoolOrFail calls NewPool and fails the test if it returns an error.
+func NewPoolOrFail(t test.Failer, ctx resource.Context, cfg PoolConfig) *Pool {
+	t.Helper()
+	p, err := NewPool(ctx, cfg)
+	if err != nil {
+		t.Fatalf("namespace.NewPoolOrFail: %v", err)
+	}
+	return p
+}
+
+// SetupPool returns a suite setup function creating a pool, assigned to p.
+func SetupPool(p **Pool, cfg PoolConfig) resource.SetupFn {
+	return func(ctx resource.Context) (err error) {
+		*p, err = NewPool(ctx, cfg)
+		return
+	}
+}
+
+func (p *Pool) ID() resource.ID {
+	return p.id
+}
+
+// fill creates the namespaces of the pool in the background.
+func (p *Pool) fill() {
+	for i := 0; i < p.cfg.Size; i++ {
+		p.refill()
+	}
+}
+
+// refill creates a namespace in the background.
+func (p *Pool) refill() {
+	p.fillers.Add(1)
+	go func() {
+		defer p.fillers.Done()
+		ns, err := p.create()
+		if err == nil {
+			err = p.waitForReady(ns)
+		}
+		select {
+		case p.ready <- pooledNamespace{ns: ns, err: err}:
+		case <-p.stop:
+		}
+	}()
+}
+
+// waitForReady waits until pods can be created in the namespace without waiting on its setup, in each of the clusters.
+func waitForReady(clusters resource.Clusters, ns Instance, inject bool, opts ...retry.Option) error {
+	for _, c := range clusters {
+		err := retry.UntilSuccess(func() error {
+			if _, err := c.CoreV1().ServiceAccounts(ns.Name()).Get(context.TODO(), "default",
+				kubeApiMeta.GetOptions{}); err != nil {
+				return err
+			}
+			if inject {
+				if _, err := c.CoreV1().ConfigMaps(ns.Name()).Get(context.TODO(), rootCertConfigMap,
+					kubeApiMeta.GetOptions{}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, opts...)
+		if err != nil {
+			return fmt.Errorf("namespace %s not ready in cluster %s: %v", ns.Name(), c.Name(), err)
+		}
+	}
+	return nil
+}
+
+// Get hands out a namespace of the pool, waiting for one to be ready if none is. The namespace is deleted when the
+// context is closed, such as at the end of the test.
+func (p *Pool) Get(ctx resource.Context) (Instance, error) {
+	select {
+	case r := <-p.ready:
+		p.refill()
+		if r.err != nil {
+			if r.ns != nil {
+				ctx.TrackResource(r.ns)
+			}
+			return nil, r.err
+		}
+		ctx.TrackResource(r.ns)
+		scopes.Framework.Debugf("handing out pooled namespace %s", r.ns.Name())
+		return r.ns, nil
+	case <-p.stop:
+		return nil, fmt.Errorf("namespace pool is closed")
+	}
+}
+
+// GetOrFail calls Get and fails the test if it returns an error.
+func (p *Pool) GetOrFail(t test.Failer, ctx resource.Context) Instance {
+	t.Helper()
+	ns, err := p.Get(ctx)
+	if err != nil {
+		t.Fatalf("namespace.Pool.GetOrFail: %v", err)
+	}
+	return ns
+}
+
+// Close stops refilling the pool. Namespaces that were not handed out are deleted along with the context of the pool.
+func (p *Pool) Close() error {
+	p.once.Do(func() {
+		close(p.stop)
+		p.fillers.Wait()
+	})
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package namespace
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// trackingContext records the resources tracked by the test.
+type trackingContext struct {
+	resource.Context
+	mu      sync.Mutex
+	tracked []resource.Resource
+}
+
+func (c *trackingContext) TrackResource(r resource.Resource) resource.ID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tracked = append(c.tracked, r)
+	return resource.FakeID("tracked")
+}
+
+func ready(Instance, bool) error {
+	return nil
+}
+
+func (f *fakeNamespaces) createdCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.created)
+}
+
+func TestPoolGet(t *testing.T) {
+	f := &fakeNamespaces{live: map[string]bool{}}
+	p := newPool(PoolConfig{Size: 2}, f.create, ready)
+	p.fill()
+	defer p.Close()
+
+	ctx := &trackingContext{}
+	ns := p.GetOrFail(t, ctx)
+	if !strings.HasPrefix(ns.Name(), "pool-") {
+		t.Fatalf("got namespace %s, want the default prefix", ns.Name())
+	}
+	if len(ctx.tracked) != 1 || ctx.tracked[0].(Instance).Name() != ns.Name() {
+		t.Fatalf("expected the namespace to be tracked by the context of the test, got %v", ctx.tracked)
+	}
+	retry.UntilSuccessOrFail(t, func() error {
+		if got := f.createdCount(); got != 3 {
+			return errors.New("the namespace handed out was not replaced")
+		}
+		return nil
+	}, retry.Timeout(time.Second))
+
+	if other := p.GetOrFail(t, ctx); other.Name() == ns.Name() {
+		t.Fatalf("namespace %s was handed out twice", ns.Name())
+	}
+}
+
+func TestPoolGetErrors(t *testing.T) {
+	t.Run("create", func(t *testing.T) {
+		p := newPool(PoolConfig{Size: 1}, func(*Config) (Instance, error) {
+			return nil, errors.New("forbidden")
+		}, ready)
+		p.fill()
+		defer p.Close()
+
+		ctx := &trackingContext{}
+		if _, err := p.Get(ctx); err == nil || err.Error() != "forbidden" {
+			t.Fatalf("got error %v, want forbidden", err)
+		}
+		if len(ctx.tracked) != 0 {
+			t.Fatalf("got tracked resources %v, want none", ctx.tracked)
+		}
+	})
+	t.Run("not ready", func(t *testing.T) {
+		f := &fakeNamespaces{live: map[string]bool{}}
+		p := newPool(PoolConfig{Size: 1}, f.create, func(Instance, bool) error {
+			return errors.New("no service account")
+		})
+		p.fill()
+		defer p.Close()
+
+		ctx := &trackingContext{}
+		if _, err := p.Get(ctx); err == nil {
+			t.Fatal("expected an error for a namespace that is not ready")
+		}
+		if len(ctx.tracked) != 1 {
+			t.Fatalf("expected the namespace that is not ready to be tracked so that it is deleted, got %v", ctx.tracked)
+		}
+	})
+}
+
+func TestPoolConfig(t *testing.T) {
+	var mu sync.Mutex
+	var prefixes []string
+	var injected []bool
+	cfg := PoolConfig{Namespace: Config{Prefix: "warm", Inject: true}, Size: 3}
+	p := newPool(cfg, func(cfg *Config) (Instance, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		prefixes = append(prefixes, cfg.Prefix)
+		// Changes to the config of one namespace do not leak into the next.
+		cfg.Prefix = "changed"
+		return Fake{NameValue: "warm"}, nil
+	}, func(_ Instance, inject bool) error {
+		mu.Lock()
+		defer mu.Unlock()
+		injected = append(injected, inject)
+		return nil
+	})
+	p.fill()
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(prefixes) != 3 || len(injected) != 3 {
+		t.Fatalf("got %d namespaces created and %d checked, want 3", len(prefixes), len(injected))
+	}
+	for i := range prefixes {
+		if prefixes[i] != "warm" || !injected[i] {
+			t.Fatalf("got prefix %s and inject %v, want those of the config", prefixes[i], injected[i])
+		}
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	block := make(chan struct{})
+	p := newPool(PoolConfig{Size: 1}, func(*Config) (Instance, error) {
+		<-block
+		return nil, errors.New("closed")
+	}, ready)
+	p.fill()
+	close(block)
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing again is a no-op.
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForReady(t *testing.T) {
+	sa := &kubeApiCore.ServiceAccount{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "default", Namespace: "ns"}}
+	rootCert := &kubeApiCore.ConfigMap{ObjectMeta: kubeApiMeta.ObjectMeta{Name: rootCertConfigMap, Namespace: "ns"}}
+	cases := []struct {
+		name   string
+		objs   []runtime.Object
+		inject bool
+		ready  bool
+	}{
+		{"empty", nil, false, false},
+		{"service account", []runtime.Object{sa}, false, true},
+		{"injected without root cert", []runtime.Object{sa}, true, false},
+		{"injected", []runtime.Object{sa, rootCert}, true, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			clusters := resource.Clusters{
+				resource.FakeCluster{ExtendedClient: kube.NewFakeClient(sa, rootCert).(kube.ExtendedClient), NameValue: "cluster-0"},
+				resource.FakeCluster{ExtendedClient: kube.NewFakeClient(tc.objs...).(kube.ExtendedClient), NameValue: "cluster-1"},
+			}
+			err := waitForReady(clusters, Fake{NameValue: "ns"}, tc.inject, retry.Timeout(50*time.Millisecond))
+			if tc.ready {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "namespace ns not ready in cluster cluster-1") {
+				t.Fatalf("got error %v, want the namespace not to be ready", err)
+			}
+		})
+	}
+}
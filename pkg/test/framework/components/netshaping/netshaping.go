@@ -0,0 +1,412 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netshaping degrades the network between clusters, adding latency, jitter, packet loss and bandwidth limits
+// to the traffic of one cluster to another, so that multicluster failover, retries and timeouts can be tested under
+// WAN conditions. A privileged DaemonSet on the host network of every node of the source cluster shapes the traffic
+// leaving the node for the addresses of the destination cluster with a tc netem qdisc of its own, which is removed
+// with it.
+package netshaping
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/image"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+	"istio.io/istio/pkg/test/util/tmpl"
+)
+
+const (
+	namespace    = "istio-netshaping"
+	appLabel     = "istio-netshaping"
+	readyTimeout = 2 * time.Minute
+	maxLinks     = 13
+	// device is the ifb device the shaped traffic is redirected to.
+	device = "ifb-netshaping"
+	// filterPref is the priority of the filters redirecting the shaped traffic, by which they are removed.
+	filterPref = 49152
+)
+
+// Conditions of the network. Latency and jitter are added to each packet of a direction, so links both ways between
+// two clusters add twice the latency to each round trip.
+type Conditions struct {
+	// Latency added to each packet.
+	Latency time.Duration
+	// Jitter varies the latency of each packet by up to the given duration.
+	Jitter time.Duration
+	// Loss is the percentage of packets dropped.
+	Loss float64
+	// Bandwidth limits the rate of the traffic, in bits per second.
+	Bandwidth int64
+}
+
+// netem returns the arguments of a netem qdisc applying the conditions.
+func (c Conditions) netem() string {
+	var args []string
+	if c.Latency > 0 || c.Jitter > 0 {
+		args = append(args, fmt.Sprintf("delay %dus", c.Latency.Microseconds()))
+		if c.Jitter > 0 {
+			args = append(args, fmt.Sprintf("%dus", c.Jitter.Microseconds()))
+		}
+	}
+	if c.Loss > 0 {
+		args = append(args, fmt.Sprintf("loss %g%%", c.Loss))
+	}
+	if c.Bandwidth > 0 {
+		args = append(args, fmt.Sprintf("rate %dbit", c.Bandwidth))
+	}
+	return strings.Join(args, " ")
+}
+
+func (c Conditions) validate() error {
+	if c.Latency < 0 || c.Jitter < 0 || c.Bandwidth < 0 || c.Loss < 0 || c.Loss > 100 {
+		return fmt.Errorf("invalid network conditions %+v", c)
+	}
+	if c.netem() == "" {
+		return fmt.Errorf("network conditions are empty")
+	}
+	return nil
+}
+
+// Link is the traffic from one cluster to another.
+type Link struct {
+	From       resource.Cluster
+	To         resource.Cluster
+	Conditions Conditions
+}
+
+// Between returns the links both ways between every cluster of network a and every cluster of network b.
+func Between(clusters resource.Clusters, a, b string, c Conditions) []Link {
+	byNetwork := clusters.ByNetwork()
+	var out []Link
+	for _, from := range byNetwork[a] {
+		for _, to := range byNetwork[b] {
+			out = append(out, Link{From: from, To: to, Conditions: c}, Link{From: to, To: from, Conditions: c})
+		}
+	}
+	return out
+}
+
+// The script leaves the qdiscs of the interface of the default route in place, so that shaping set up by others on the
+// node is kept. Traffic to the destinations of the links is redirected by filters of its own priority on the egress
+// of the interface to an ifb device of its own, whose root prio qdisc has a band for each link, leaving the default
+// bands for all other traffic. On termination only the filters and the ifb device are removed.
+var daemonSetTemplate = tmpl.MustNewTyped(`
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: {{ .Name }}
+spec:
+  selector:
+    matchLabels:
+      app: {{ .Name }}
+  template:
+    metadata:
+      labels:
+        app: {{ .Name }}
+      annotations:
+        sidecar.istio.io/inject: "false"
+    spec:
+      hostNetwork: true
+      tolerations:
+      - operator: Exists
+      terminationGracePeriodSeconds: 10
+      containers:
+      - name: shape
+        image: {{ .Image }}
+        imagePullPolicy: {{ .PullPolicy }}
+        securityContext:
+          privileged: true
+        command:
+        - /bin/sh
+        - -c
+        - |
+          set -e
+          DEV=$(ip route show default | awk '{print $5; exit}')
+          IFB={{ .Device }}
+          cleanup() {
+            tc filter del dev "$DEV" egress pref {{ .FilterPref }} 2>/dev/null || true
+            ip link del "$IFB" 2>/dev/null || true
+          }
+          # An earlier instance may have been killed before cleaning up.
+          cleanup
+          trap 'cleanup; exit 0' TERM INT
+          ip link add "$IFB" type ifb
+          ip link set "$IFB" up
+          tc qdisc add dev "$IFB" root handle 1: prio bands {{ .Bands }} priomap 1 2 2 2 1 2 0 0 1 1 1 1 1 1 1 1
+{{- range $i, $l := .Links }}
+          tc qdisc add dev "$IFB" parent 1:{{ add $i 4 }} handle {{ add $i 4 }}0: netem {{ $l.Netem }}
+{{- range $l.CIDRs }}
+          tc filter add dev "$IFB" parent 1:0 protocol ip prio 1 u32 match ip dst {{ . }} flowid 1:{{ add $i 4 }}
+{{- end }}
+{{- end }}
+          # The clsact qdisc is shared with others, such as CNI plugins, so it is added if missing but never removed.
+          tc qdisc add dev "$DEV" clsact 2>/dev/null || true
+{{- range .Links }}
+{{- range .CIDRs }}
+          tc filter add dev "$DEV" egress pref {{ $.FilterPref }} protocol ip u32 match ip dst {{ . }} action mirred egress redirect dev "$IFB"
+{{- end }}
+{{- end }}
+          touch /tmp/shaped
+          sleep infinity &
+          wait
+        readinessProbe:
+          exec:
+            command: ["test", "-f", "/tmp/shaped"]
+          periodSeconds: 1
+`)
+
+// daemonSetParams are the parameters of daemonSetTemplate.
+type daemonSetParams struct {
+	Name       string `tmpl:"required"`
+	Image      string `tmpl:"required"`
+	PullPolicy string
+	Device     string `tmpl:"required"`
+	FilterPref int    `tmpl:"required"`
+	Bands      int    `tmpl:"required"`
+	Links      []linkConfig
+}
+
+type linkConfig struct {
+	Netem string
+	CIDRs []string
+}
+
+// Shape the links, returning a function restoring the network.
+func Shape(ctx resource.Context, links ...Link) (func(), error) {
+	byCluster := map[string][]linkConfig{}
+	clusters := map[string]resource.Cluster{}
+	for _, l := range links {
+		if err := l.Conditions.validate(); err != nil {
+			return nil, err
+		}
+		cidrs, err := addresses(l.To)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing the addresses of cluster %s: %v", l.To.Name(), err)
+		}
+		byCluster[l.From.Name()] = append(byCluster[l.From.Name()], linkConfig{Netem: l.Conditions.netem(), CIDRs: cidrs})
+		clusters[l.From.Name()] = l.From
+	}
+	for name, cfgs := range byCluster {
+		// A prio qdisc has at most 16 bands, 3 of which are left for other traffic.
+		if len(cfgs) > maxLinks {
+			return nil, fmt.Errorf("cluster %s has %d links, more than the %d supported", name, len(cfgs), maxLinks)
+		}
+	}
+	s, err := image.SettingsFromCommandLine()
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var errs error
+	var shaped []resource.Cluster
+	wg := sync.WaitGroup{}
+	for name, c := range clusters {
+		c, cfgs := c, byCluster[name]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := shapeCluster(ctx, c, cfgs, s)
+			mu.Lock()
+			defer mu.Unlock()
+			shaped = append(shaped, c)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("failed shaping the network of cluster %s: %v", c.Name(), err))
+			}
+		}()
+	}
+	wg.Wait()
+	restore := func() {
+		for _, c := range shaped {
+			if err := unshapeCluster(c); err != nil {
+				scopes.Framework.Warnf("failed restoring the network of cluster %s: %v", c.Name(), err)
+			}
+		}
+	}
+	if errs != nil {
+		restore()
+		return nil, errs
+	}
+	return restore, nil
+}
+
+// ShapeOrFail calls Shape, failing the test if it returns an error, and restores the network when the test is done.
+func ShapeOrFail(t test.Failer, ctx resource.Context, links ...Link) {
+	t.Helper()
+	restore, err := Shape(ctx, links...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restore)
+}
+
+// Setup returns a suite setup function shaping the links for the whole suite. As the load balancers of the
+// destination clusters are shaped too, it should run once Istio is installed.
+func Setup(links func(ctx resource.Context) []Link) resource.SetupFn {
+	return func(ctx resource.Context) error {
+		restore, err := Shape(ctx, links(ctx)...)
+		if err != nil {
+			return err
+		}
+		s := &shaping{restore: restore}
+		s.id = ctx.TrackResource(s)
+		return nil
+	}
+}
+
+var (
+	_ resource.Resource = &shaping{}
+	_ io.Closer         = &shaping{}
+)
+
+// shaping restores the network once the scope that shaped it is done.
+type shaping struct {
+	id      resource.ID
+	restore func()
+}
+
+func (s *shaping) ID() resource.ID {
+	return s.id
+}
+
+// Close implements io.Closer.
+func (s *shaping) Close() error {
+	s.restore()
+	return nil
+}
+
+func shapeCluster(ctx resource.Context, c resource.Cluster, links []linkConfig, s *image.Settings) error {
+	ds, err := daemonSetTemplate.Evaluate(daemonSetParams{
+		Name:       appLabel,
+		Image:      fmt.Sprintf("%s/proxyv2:%s", s.Hub, s.Tag),
+		PullPolicy: s.PullPolicy,
+		Device:     device,
+		FilterPref: filterPref,
+		Bands:      3 + len(links),
+		Links:      links,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := c.CoreV1().Namespaces().Create(context.TODO(), &kubeApiCore.Namespace{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: namespace},
+	}, kubeApiMeta.CreateOptions{}); err != nil && !kubeErrors.IsAlreadyExists(err) {
+		return err
+	}
+	if err := ctx.Config(c).ApplyYAML(namespace, ds); err != nil {
+		return err
+	}
+	return retry.UntilSuccess(func() error {
+		d, err := c.AppsV1().DaemonSets(namespace).Get(context.TODO(), appLabel, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if desired := d.Status.DesiredNumberScheduled; desired == 0 || d.Status.NumberReady < desired {
+			return fmt.Errorf("network shaped on %d of %d nodes", d.Status.NumberReady, desired)
+		}
+		return nil
+	}, retry.Timeout(readyTimeout), retry.Delay(2*time.Second))
+}
+
+// unshapeCluster deletes the namespace of the DaemonSet, waiting until it is gone so that the network can be shaped
+// again.
+func unshapeCluster(c resource.Cluster) error {
+	if err := c.CoreV1().Namespaces().Delete(context.TODO(), namespace,
+		kube.DeleteOptionsForeground()); err != nil && !kubeErrors.IsNotFound(err) {
+		return err
+	}
+	return retry.UntilSuccess(func() error {
+		_, err := c.CoreV1().Namespaces().Get(context.TODO(), namespace, kubeApiMeta.GetOptions{})
+		if kubeErrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("namespace %s not yet deleted: %v", namespace, err)
+	}, retry.Timeout(readyTimeout), retry.Delay(2*time.Second))
+}
+
+// addresses returns the IPv4 CIDRs of the cluster, those of its nodes, its pods and its load balancers. Pod IPs are
+// used rather than the pod CIDRs of nodes, which are not set by every network plugin, so pods created once the network
+// is shaped are not shaped.
+func addresses(c kubernetes.Interface) ([]string, error) {
+	cidrs := map[string]struct{}{}
+	addIP := func(ip string) {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			cidrs[ip+"/32"] = struct{}{}
+		}
+	}
+	nodes, err := c.CoreV1().Nodes().List(context.TODO(), kubeApiMeta.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes.Items {
+		for _, a := range n.Status.Addresses {
+			if a.Type == kubeApiCore.NodeInternalIP || a.Type == kubeApiCore.NodeExternalIP {
+				addIP(a.Address)
+			}
+		}
+	}
+	pods, err := c.CoreV1().Pods(kubeApiMeta.NamespaceAll).List(context.TODO(), kubeApiMeta.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pods.Items {
+		// Pods on the host network have the address of their node.
+		if p.Spec.HostNetwork {
+			continue
+		}
+		for _, ip := range p.Status.PodIPs {
+			addIP(ip.IP)
+		}
+		addIP(p.Status.PodIP)
+	}
+	services, err := c.CoreV1().Services(kubeApiMeta.NamespaceAll).List(context.TODO(), kubeApiMeta.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services.Items {
+		if svc.Spec.Type != kubeApiCore.ServiceTypeLoadBalancer {
+			continue
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			addIP(ingress.IP)
+		}
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("no IPv4 addresses found")
+	}
+	var out []string
+	for cidr := range cidrs {
+		out = append(out, cidr)
+	}
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netshaping
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAddresses(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&kubeApiCore.Node{
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: "node"},
+			Status: kubeApiCore.NodeStatus{Addresses: []kubeApiCore.NodeAddress{
+				{Type: kubeApiCore.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: kubeApiCore.NodeHostName, Address: "node"},
+			}},
+		},
+		&kubeApiCore.Pod{
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: "a", Namespace: "default"},
+			Status: kubeApiCore.PodStatus{
+				PodIP:  "10.1.0.1",
+				PodIPs: []kubeApiCore.PodIP{{IP: "10.1.0.1"}, {IP: "fd00::1"}},
+			},
+		},
+		&kubeApiCore.Pod{
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: "host", Namespace: "default"},
+			Spec:       kubeApiCore.PodSpec{HostNetwork: true},
+			Status:     kubeApiCore.PodStatus{PodIP: "10.0.0.2"},
+		},
+		&kubeApiCore.Service{
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: "gateway", Namespace: "default"},
+			Spec:       kubeApiCore.ServiceSpec{Type: kubeApiCore.ServiceTypeLoadBalancer},
+			Status: kubeApiCore.ServiceStatus{LoadBalancer: kubeApiCore.LoadBalancerStatus{
+				Ingress: []kubeApiCore.LoadBalancerIngress{{IP: "192.168.0.1"}},
+			}},
+		},
+	)
+	got, err := addresses(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.1/32", "10.1.0.1/32", "192.168.0.1/32"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if _, err := addresses(fake.NewSimpleClientset()); err == nil {
+		t.Fatal("expected an error for a cluster without addresses")
+	}
+}
+
+func TestDaemonSet(t *testing.T) {
+	ds, err := daemonSetTemplate.Evaluate(daemonSetParams{
+		Name:       appLabel,
+		Image:      "proxyv2",
+		Device:     device,
+		FilterPref: filterPref,
+		Bands:      4,
+		Links:      []linkConfig{{Netem: Conditions{Latency: 50 * time.Millisecond}.netem(), CIDRs: []string{"10.0.0.1/32"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`tc qdisc add dev "$IFB" parent 1:4 handle 40: netem delay 50000us`,
+		`tc filter add dev "$IFB" parent 1:0 protocol ip prio 1 u32 match ip dst 10.0.0.1/32 flowid 1:4`,
+		`tc filter add dev "$DEV" egress pref 49152 protocol ip u32 match ip dst 10.0.0.1/32 action mirred egress redirect dev "$IFB"`,
+	} {
+		if !strings.Contains(ds, want) {
+			t.Fatalf("expected the DaemonSet to contain %q, got:\n%s", want, ds)
+		}
+	}
+	// The qdiscs of the interface of the node are left in place.
+	if strings.Contains(ds, `dev "$DEV" root`) {
+		t.Fatalf("expected the root qdisc of the node to be kept, got:\n%s", ds)
+	}
+}
@@ -0,0 +1,451 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package networkpolicy applies common Kubernetes NetworkPolicy patterns around echo workloads, and verifies that
+// traffic between them matches the outcome expected from the policies, in each dataplane mode.
+//
+// NetworkPolicies are enforced by the network plugin of the cluster on pod IPs and container ports, outside of the
+// network namespace of the pods where the traffic of sidecars is redirected, and the Istio CNI plugin only replaces
+// the init container setting up that redirection. The outcome expected from the policies is therefore the same in
+// every mode, which Verify checks by running the cases of ModeCases between workloads deployed in each mode.
+package networkpolicy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/common/response"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Mode is the dataplane mode of a workload.
+type Mode string
+
+const (
+	// NoSidecar workloads are not injected.
+	NoSidecar Mode = "no-sidecar"
+	// Sidecar workloads have their traffic redirected to the sidecar by the istio-init container.
+	Sidecar Mode = "sidecar"
+	// SidecarCNI workloads have their traffic redirected to the sidecar by the Istio CNI plugin.
+	SidecarCNI Mode = "sidecar-cni"
+)
+
+// cniSelector selects the pods of the Istio CNI plugin DaemonSet.
+const cniSelector = "k8s-app=istio-cni-node"
+
+// sidecarMode returns the mode of injected workloads in the cluster, depending on whether the Istio CNI plugin is
+// installed.
+func sidecarMode(c resource.Cluster) (Mode, error) {
+	pods, err := c.CoreV1().Pods("").List(context.TODO(), kubeApiMeta.ListOptions{LabelSelector: cniSelector})
+	if err != nil {
+		return "", err
+	}
+	if len(pods.Items) > 0 {
+		return SidecarCNI, nil
+	}
+	return Sidecar, nil
+}
+
+// DeployModes deploys the echo workload once per dataplane mode of its cluster: without a sidecar, and with a sidecar
+// redirected by the Istio CNI plugin if it is installed, or by the init container otherwise. The service of each
+// instance is suffixed with its mode.
+func DeployModes(ctx resource.Context, cfg echo.Config) (map[Mode]echo.Instance, error) {
+	c := cfg.Cluster
+	if c == nil {
+		c = ctx.Clusters().Default()
+	}
+	injected, err := sidecarMode(c)
+	if err != nil {
+		return nil, err
+	}
+	modes := []Mode{NoSidecar, injected}
+	instances := make([]echo.Instance, len(modes))
+	builder := echoboot.NewBuilder(ctx)
+	for i, m := range modes {
+		mc := cfg
+		mc.Service = fmt.Sprintf("%s-%s", cfg.Service, m)
+		mc.Subsets = []echo.SubsetConfig{{
+			Annotations: echo.NewAnnotations().SetBool(echo.SidecarInject, m != NoSidecar),
+		}}
+		builder = builder.With(&instances[i], mc)
+	}
+	if _, err := builder.Build(); err != nil {
+		return nil, err
+	}
+	out := map[Mode]echo.Instance{}
+	for i, m := range modes {
+		out[m] = instances[i]
+	}
+	return out, nil
+}
+
+// DeployModesOrFail calls DeployModes and fails the test if it returns an error.
+func DeployModesOrFail(t test.Failer, ctx resource.Context, cfg echo.Config) map[Mode]echo.Instance {
+	t.Helper()
+	out, err := DeployModes(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// ModeCases returns a case for each pair of the modes of the sources and targets, such as those deployed by
+// DeployModes, named <name>/<source mode>-><target mode>. The policies of each case are built for its source and
+// target, since their services differ between modes.
+func ModeCases(name string, sources, targets map[Mode]echo.Instance,
+	policies func(src, dst echo.Instance) []Policy) []Case {
+	var out []Case
+	for _, sm := range sortedModes(sources) {
+		for _, tm := range sortedModes(targets) {
+			src, dst := sources[sm], targets[tm]
+			out = append(out, Case{
+				Name:     fmt.Sprintf("%s/%s->%s", name, sm, tm),
+				Policies: policies(src, dst),
+				Source:   src,
+				Target:   dst,
+			})
+		}
+	}
+	return out
+}
+
+func sortedModes(instances map[Mode]echo.Instance) []Mode {
+	out := make([]Mode, 0, len(instances))
+	for m := range instances {
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Direction of the traffic a policy applies to.
+type Direction string
+
+const (
+	Ingress Direction = "Ingress"
+	Egress  Direction = "Egress"
+)
+
+// Port is a port allowed by a rule.
+type Port struct {
+	Number int
+	// Protocol of the port. Defaults to TCP.
+	Protocol string
+}
+
+// Rule allows traffic from or to the namespaces, on the ports. Empty fields match everything.
+type Rule struct {
+	Namespaces []string
+	Ports      []Port
+}
+
+// Policy is a NetworkPolicy.
+type Policy struct {
+	Name      string
+	Namespace string
+	// App selects the pods labeled app=<App>. If empty, all pods in the namespace are selected.
+	App       string
+	Direction Direction
+	// Rule allowing traffic, or nil to deny all traffic in the direction.
+	Rule *Rule
+}
+
+// DefaultDeny denies all ingress and egress traffic of the pods in the namespace.
+func DefaultDeny(ns string) []Policy {
+	return []Policy{
+		{Name: "default-deny-ingress", Namespace: ns, Direction: Ingress},
+		{Name: "default-deny-egress", Namespace: ns, Direction: Egress},
+	}
+}
+
+// AllowFromNamespaces allows ingress traffic to the app from the namespaces.
+func AllowFromNamespaces(ns, app string, namespaces ...string) Policy {
+	return Policy{
+		Name:      fmt.Sprintf("allow-from-%s", strings.Join(namespaces, "-")),
+		Namespace: ns,
+		App:       app,
+		Direction: Ingress,
+		Rule:      &Rule{Namespaces: namespaces},
+	}
+}
+
+// AllowToNamespaces allows egress traffic from the app to the namespaces.
+func AllowToNamespaces(ns, app string, namespaces ...string) Policy {
+	return Policy{
+		Name:      fmt.Sprintf("allow-to-%s", strings.Join(namespaces, "-")),
+		Namespace: ns,
+		App:       app,
+		Direction: Egress,
+		Rule:      &Rule{Namespaces: namespaces},
+	}
+}
+
+// AllowIstioSystem allows the pods in the namespace to reach istiod in the system namespace, which sidecars need to
+// receive their config and certificates.
+func AllowIstioSystem(ns, systemNamespace string) Policy {
+	p := AllowToNamespaces(ns, "", systemNamespace)
+	p.Name = "allow-istio-system"
+	return p
+}
+
+// AllowDNS allows the pods in the namespace to resolve names with any DNS server.
+func AllowDNS(ns string) Policy {
+	return Policy{
+		Name:      "allow-dns",
+		Namespace: ns,
+		Direction: Egress,
+		Rule:      &Rule{Ports: []Port{{Number: 53, Protocol: "UDP"}, {Number: 53}}},
+	}
+}
+
+// AllowPorts allows ingress traffic to the app on the ports from anywhere. With a sidecar, the ports are those of the
+// application container, since traffic reaches the pod on the original destination port.
+func AllowPorts(ns, app string, ports ...int) Policy {
+	rule := &Rule{}
+	names := make([]string, 0, len(ports))
+	for _, p := range ports {
+		rule.Ports = append(rule.Ports, Port{Number: p})
+		names = append(names, fmt.Sprint(p))
+	}
+	return Policy{
+		Name:      fmt.Sprintf("allow-ports-%s", strings.Join(names, "-")),
+		Namespace: ns,
+		App:       app,
+		Direction: Ingress,
+		Rule:      rule,
+	}
+}
+
+// YAML returns the NetworkPolicy resource.
+func (p Policy) YAML() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: %s
+  namespace: %s
+spec:
+`, p.Name, p.Namespace)
+	if p.App == "" {
+		b.WriteString("  podSelector: {}\n")
+	} else {
+		fmt.Fprintf(&b, "  podSelector:\n    matchLabels:\n      app: %s\n", p.App)
+	}
+	fmt.Fprintf(&b, "  policyTypes:\n  - %s\n", p.Direction)
+	if p.Rule == nil {
+		return b.String()
+	}
+	peers := "from"
+	if p.Direction == Egress {
+		peers = "to"
+	}
+	fmt.Fprintf(&b, "  %s:\n  - ", strings.ToLower(string(p.Direction)))
+	indent := ""
+	if len(p.Rule.Namespaces) > 0 {
+		fmt.Fprintf(&b, "%s:\n", peers)
+		for _, ns := range p.Rule.Namespaces {
+			fmt.Fprintf(&b, "    - namespaceSelector:\n        matchLabels:\n          kubernetes.io/metadata.name: %s\n", ns)
+		}
+		indent = "    "
+	}
+	if len(p.Rule.Ports) > 0 {
+		fmt.Fprintf(&b, "%sports:\n", indent)
+		for _, port := range p.Rule.Ports {
+			fmt.Fprintf(&b, "    - port: %d\n      protocol: %s\n", port.Number, port.protocol())
+		}
+	} else if indent == "" {
+		// A rule without peers or ports allows all traffic.
+		b.WriteString("{}\n")
+	}
+	return b.String()
+}
+
+func (p Port) protocol() string {
+	if p.Protocol == "" {
+		return "TCP"
+	}
+	return p.Protocol
+}
+
+// Endpoint is one end of a connection.
+type Endpoint struct {
+	Namespace string
+	App       string
+	Port      Port
+}
+
+// Allows returns whether the policies allow a connection from the source to the destination, in any dataplane mode.
+// Pods are isolated in a direction if any policy selects them in it, and are then only allowed the traffic matched by
+// one of the rules. Sources isolated for egress must also be allowed to resolve the destination through DNS.
+func Allows(policies []Policy, src, dst Endpoint) bool {
+	if !allowed(policies, Ingress, dst, src) {
+		return false
+	}
+	dns := Endpoint{Namespace: "kube-system", App: "kube-dns", Port: Port{Number: 53, Protocol: "UDP"}}
+	return allowed(policies, Egress, src, dst) && allowed(policies, Egress, src, dns)
+}
+
+func allowed(policies []Policy, d Direction, self, peer Endpoint) bool {
+	isolated := false
+	port := peer.Port
+	if d == Ingress {
+		port = self.Port
+	}
+	for _, p := range policies {
+		if p.Direction != d || p.Namespace != self.Namespace || (p.App != "" && p.App != self.App) {
+			continue
+		}
+		isolated = true
+		if p.Rule != nil && p.Rule.matches(peer.Namespace, port) {
+			return true
+		}
+	}
+	return !isolated
+}
+
+func (r Rule) matches(ns string, port Port) bool {
+	if len(r.Namespaces) > 0 && !contains(r.Namespaces, ns) {
+		return false
+	}
+	if len(r.Ports) == 0 {
+		return true
+	}
+	for _, p := range r.Ports {
+		if p.Number == port.Number && p.protocol() == port.protocol() {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply applies the policies, returning a function that removes them.
+func Apply(ctx resource.Context, policies ...Policy) (func() error, error) {
+	yml := make([]string, 0, len(policies))
+	for _, p := range policies {
+		yml = append(yml, p.YAML())
+	}
+	if err := ctx.Config().ApplyYAML("", yml...); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return ctx.Config().DeleteYAML("", yml...)
+	}, nil
+}
+
+// ApplyOrFail calls Apply and fails the test if it returns an error. The policies are removed when the test
+// completes.
+func ApplyOrFail(t test.Failer, ctx resource.Context, policies ...Policy) {
+	t.Helper()
+	cleanup, err := Apply(ctx, policies...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cleanup()
+	})
+}
+
+// Case is a request between echo workloads under a set of policies.
+type Case struct {
+	Name     string
+	Policies []Policy
+	Source   echo.Instance
+	Target   echo.Instance
+	// PortName of the target to call. Defaults to "http".
+	PortName string
+}
+
+// Expect returns whether the request of the case is expected to succeed.
+func (c Case) Expect() bool {
+	return Allows(c.Policies, endpoint(c.Source, ""), endpoint(c.Target, c.portName()))
+}
+
+func (c Case) portName() string {
+	if c.PortName == "" {
+		return "http"
+	}
+	return c.PortName
+}
+
+func endpoint(i echo.Instance, portName string) Endpoint {
+	e := Endpoint{Namespace: i.Config().Namespace.Name(), App: i.Config().Service}
+	for _, p := range i.Config().Ports {
+		if p.Name == portName {
+			e.Port = Port{Number: p.InstancePort}
+		}
+	}
+	return e
+}
+
+// Verify applies the policies of each case in turn, and checks that the request succeeds only if expected. Requests
+// are retried until the outcome matches, since CNI plugins apply NetworkPolicies asynchronously.
+func Verify(ctx resource.Context, cases []Case, opts ...retry.Option) error {
+	opts = append([]retry.Option{retry.Timeout(time.Minute), retry.Delay(time.Second)}, opts...)
+	var errs error
+	for _, c := range cases {
+		if err := verify(ctx, c, opts...); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %v", c.Name, err))
+		}
+	}
+	return errs
+}
+
+// VerifyOrFail calls Verify and fails the test if it returns an error.
+func VerifyOrFail(t test.Failer, ctx resource.Context, cases []Case, opts ...retry.Option) {
+	t.Helper()
+	if err := Verify(ctx, cases, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verify(ctx resource.Context, c Case, opts ...retry.Option) error {
+	cleanup, err := Apply(ctx, c.Policies...)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = cleanup() }()
+
+	expected := c.Expect()
+	return retry.UntilSuccess(func() error {
+		resp, err := c.Source.Call(echo.CallOptions{
+			Target:   c.Target,
+			PortName: c.portName(),
+			Timeout:  5 * time.Second,
+		})
+		actual := err == nil && len(resp) > 0 && resp[0].Code == response.StatusCodeOK
+		if actual != expected {
+			return fmt.Errorf("expected allowed=%v, got allowed=%v (err=%v)", expected, actual, err)
+		}
+		return nil
+	}, opts...)
+}
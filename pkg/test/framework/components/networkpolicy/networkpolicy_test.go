@@ -0,0 +1,248 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package networkpolicy
+
+import (
+	"reflect"
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func instance(ns, service string) echo.Instance {
+	return echo.FakeInstance{ConfigValue: echo.Config{
+		Service:   service,
+		Namespace: namespace.Fake{NameValue: ns},
+		Ports:     []echo.Port{{Name: "http", ServicePort: 80, InstancePort: 8090}},
+	}}
+}
+
+func parse(t *testing.T, p Policy) networkingv1.NetworkPolicySpec {
+	t.Helper()
+	var np networkingv1.NetworkPolicy
+	if err := yaml.UnmarshalStrict([]byte(p.YAML()), &np); err != nil {
+		t.Fatalf("invalid NetworkPolicy: %v\n%s", err, p.YAML())
+	}
+	if np.Name != p.Name || np.Namespace != p.Namespace {
+		t.Fatalf("got NetworkPolicy %s/%s, want %s/%s", np.Namespace, np.Name, p.Namespace, p.Name)
+	}
+	return np.Spec
+}
+
+func TestYAML(t *testing.T) {
+	tcp, udp := kubeApiCore.ProtocolTCP, kubeApiCore.ProtocolUDP
+	port := func(n int, protocol *kubeApiCore.Protocol) networkingv1.NetworkPolicyPort {
+		p := intstr.FromInt(n)
+		return networkingv1.NetworkPolicyPort{Port: &p, Protocol: protocol}
+	}
+	namespaces := func(names ...string) []networkingv1.NetworkPolicyPeer {
+		var out []networkingv1.NetworkPolicyPeer
+		for _, ns := range names {
+			out = append(out, networkingv1.NetworkPolicyPeer{NamespaceSelector: &kubeApiMeta.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": ns},
+			}})
+		}
+		return out
+	}
+	app := kubeApiMeta.LabelSelector{MatchLabels: map[string]string{"app": "b"}}
+	cases := []struct {
+		name   string
+		policy Policy
+		want   networkingv1.NetworkPolicySpec
+	}{
+		{
+			name:   "deny ingress",
+			policy: DefaultDeny("ns")[0],
+			want:   networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}},
+		},
+		{
+			name:   "deny egress",
+			policy: DefaultDeny("ns")[1],
+			want:   networkingv1.NetworkPolicySpec{PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}},
+		},
+		{
+			name:   "from namespaces",
+			policy: AllowFromNamespaces("ns", "b", "a", "c"),
+			want: networkingv1.NetworkPolicySpec{
+				PodSelector: app,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{From: namespaces("a", "c")}},
+			},
+		},
+		{
+			name:   "to namespaces",
+			policy: AllowIstioSystem("ns", "istio-system"),
+			want: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress:      []networkingv1.NetworkPolicyEgressRule{{To: namespaces("istio-system")}},
+			},
+		},
+		{
+			name:   "dns",
+			policy: AllowDNS("ns"),
+			want: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress:      []networkingv1.NetworkPolicyEgressRule{{Ports: []networkingv1.NetworkPolicyPort{port(53, &udp), port(53, &tcp)}}},
+			},
+		},
+		{
+			name:   "ports",
+			policy: AllowPorts("ns", "b", 8090, 9090),
+			want: networkingv1.NetworkPolicySpec{
+				PodSelector: app,
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{Ports: []networkingv1.NetworkPolicyPort{port(8090, &tcp), port(9090, &tcp)}}},
+			},
+		},
+		{
+			name: "namespaces and ports",
+			policy: Policy{Name: "p", Namespace: "ns", Direction: Ingress, Rule: &Rule{
+				Namespaces: []string{"a"},
+				Ports:      []Port{{Number: 8090}},
+			}},
+			want: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From:  namespaces("a"),
+					Ports: []networkingv1.NetworkPolicyPort{port(8090, &tcp)},
+				}},
+			},
+		},
+		{
+			name:   "allow all",
+			policy: Policy{Name: "p", Namespace: "ns", Direction: Ingress, Rule: &Rule{}},
+			want: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{}},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parse(t, tc.policy); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAllows(t *testing.T) {
+	a := Endpoint{Namespace: "a", App: "a"}
+	b := Endpoint{Namespace: "b", App: "b", Port: Port{Number: 8090}}
+	egress := append(DefaultDeny("a")[1:], AllowToNamespaces("a", "a", "b"))
+	cases := []struct {
+		name     string
+		policies []Policy
+		want     bool
+	}{
+		{"no policies", nil, true},
+		{"default deny", DefaultDeny("b"), false},
+		{"default deny of another namespace", DefaultDeny("c"), true},
+		{"allowed namespace", append(DefaultDeny("b"), AllowFromNamespaces("b", "b", "a")), true},
+		{"other namespace", append(DefaultDeny("b"), AllowFromNamespaces("b", "b", "c")), false},
+		{"other app", append(DefaultDeny("b"), AllowFromNamespaces("b", "c", "a")), false},
+		{"allowed port", append(DefaultDeny("b"), AllowPorts("b", "b", 8090)), true},
+		{"other port", append(DefaultDeny("b"), AllowPorts("b", "b", 9090)), false},
+		{"egress without dns", egress, false},
+		{"egress with dns", append(egress, AllowDNS("a")), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Allows(tc.policies, a, b); got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestModeCases(t *testing.T) {
+	sources := map[Mode]echo.Instance{Sidecar: instance("a", "a-sidecar"), NoSidecar: instance("a", "a-no-sidecar")}
+	targets := map[Mode]echo.Instance{Sidecar: instance("b", "b-sidecar"), NoSidecar: instance("b", "b-no-sidecar")}
+	cases := ModeCases("allow-port", sources, targets, func(src, dst echo.Instance) []Policy {
+		return append(DefaultDeny("b"), AllowPorts("b", dst.Config().Service, 8090))
+	})
+	var names []string
+	for _, c := range cases {
+		names = append(names, c.Name)
+		// The policies are built for the target of each case, and allow the instance port of its http port.
+		if !c.Expect() {
+			t.Fatalf("%s: expected the request to be allowed", c.Name)
+		}
+	}
+	want := []string{
+		"allow-port/no-sidecar->no-sidecar",
+		"allow-port/no-sidecar->sidecar",
+		"allow-port/sidecar->no-sidecar",
+		"allow-port/sidecar->sidecar",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got cases %v, want %v", names, want)
+	}
+
+	c := Case{Policies: append(DefaultDeny("b"), AllowPorts("b", "b-sidecar", 80)),
+		Source: sources[Sidecar], Target: targets[Sidecar]}
+	if c.Expect() {
+		t.Fatal("expected the service port not to be matched by a NetworkPolicy")
+	}
+}
+
+// fakeClient serves core resources from a fake clientset.
+type fakeClient struct {
+	kube.ExtendedClient
+	client kubernetes.Interface
+}
+
+func (c fakeClient) CoreV1() corev1.CoreV1Interface {
+	return c.client.CoreV1()
+}
+
+func TestSidecarMode(t *testing.T) {
+	cni := &kubeApiCore.Pod{ObjectMeta: kubeApiMeta.ObjectMeta{
+		Name:      "istio-cni-node-1",
+		Namespace: "kube-system",
+		Labels:    map[string]string{"k8s-app": "istio-cni-node"},
+	}}
+	for _, tc := range []struct {
+		pods []*kubeApiCore.Pod
+		want Mode
+	}{
+		{nil, Sidecar},
+		{[]*kubeApiCore.Pod{cni}, SidecarCNI},
+	} {
+		client := fake.NewSimpleClientset()
+		for _, p := range tc.pods {
+			_ = client.Tracker().Add(p)
+		}
+		got, err := sidecarMode(resource.FakeCluster{ExtendedClient: fakeClient{client: client}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("got mode %s, want %s", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package originaldst exposes the address clients connected to in the responses of echo servers with sidecars. The
+// inbound proxy connects to the application over loopback, whatever the address family the client picked for a
+// dual-stack destination, so the server cannot see the original destination itself; Expose makes the sidecar send it
+// in the response.OriginalDstHeader.
+package originaldst
+
+import (
+	"fmt"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/common/response"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+const filterName = "expose-original-dst"
+
+// filter sets the header to the local address of the downstream connection on inbound requests.
+var filter = fmt.Sprintf(`apiVersion: networking.istio.io/v1alpha3
+kind: EnvoyFilter
+metadata:
+  name: %s
+spec:
+  configPatches:
+  - applyTo: HTTP_FILTER
+    match:
+      context: SIDECAR_INBOUND
+      listener:
+        filterChain:
+          filter:
+            name: envoy.http_connection_manager
+    patch:
+      operation: INSERT_FIRST
+      value:
+        name: envoy.filters.http.lua
+        typed_config:
+          "@type": type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua
+          inlineCode: |
+            function envoy_on_request(handle)
+              handle:headers():replace("%s", handle:streamInfo():downstreamLocalAddress())
+            end
+`, filterName, response.OriginalDstHeader)
+
+// Expose the original destination of the requests received by the sidecars of the namespace in the responses of its
+// echo servers, returning a function removing the filter doing so.
+func Expose(ctx resource.Context, ns string) (func(), error) {
+	if err := ctx.Config().ApplyYAML(ns, filter); err != nil {
+		return nil, fmt.Errorf("failed applying %s: %v", filterName, err)
+	}
+	return func() {
+		_ = ctx.Config().DeleteYAML(ns, filter)
+	}, nil
+}
+
+// ExposeOrFail calls Expose, failing the test if it returns an error, and removes the filter when the test is done.
+func ExposeOrFail(t test.Failer, ctx resource.Context, ns string) {
+	t.Helper()
+	cleanup, err := Expose(ctx, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+}
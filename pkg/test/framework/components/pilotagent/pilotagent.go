@@ -0,0 +1,226 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pilotagent calls the lifecycle endpoints of the pilot-agent of a proxy, so that readiness gating, draining
+// and exiting can be exercised directly. Some endpoints only accept requests from localhost, so the requests are made
+// from within the proxy container.
+package pilotagent
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	proxyContainerName = "istio-proxy"
+	statusPort         = 15020
+	adminPort          = 15000
+
+	readyPath = "/healthz/ready"
+	quitPath  = "/quitquitquit"
+	statsPath = "/stats/prometheus"
+)
+
+// Client of the pilot-agent of a pod.
+type Client struct {
+	cluster   resource.Cluster
+	namespace string
+	pod       string
+}
+
+// New returns a client of the pilot-agent of the pod.
+func New(cluster resource.Cluster, namespace, pod string) *Client {
+	return &Client{cluster: cluster, namespace: namespace, pod: pod}
+}
+
+// ForPods returns clients of the pilot-agents of the pods matching the selector, such as "istio=ingressgateway" for
+// the ingress gateway.
+func ForPods(cluster resource.Cluster, namespace, selector string) ([]*Client, error) {
+	pods, err := cluster.PodsForSelector(context.TODO(), namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pods found in %s for %q", namespace, selector)
+	}
+	out := make([]*Client, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		out = append(out, New(cluster, pod.Namespace, pod.Name))
+	}
+	return out, nil
+}
+
+// ForInstance returns clients of the pilot-agents of the sidecars of the workloads of the echo instance.
+func ForInstance(i echo.Instance) ([]*Client, error) {
+	workloads, err := i.Workloads()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Client, 0, len(workloads))
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			return nil, fmt.Errorf("workload %s has no sidecar", w.Address())
+		}
+		// The node ID of a sidecar is of the form sidecar~<ip>~<pod>.<namespace>~<domain>.
+		parts := strings.Split(w.Sidecar().NodeID(), "~")
+		if len(parts) != 4 || !strings.Contains(parts[2], ".") {
+			return nil, fmt.Errorf("unexpected node ID %q of workload %s", w.Sidecar().NodeID(), w.Address())
+		}
+		sep := strings.LastIndex(parts[2], ".")
+		out = append(out, New(i.Config().Cluster, parts[2][sep+1:], parts[2][:sep]))
+	}
+	return out, nil
+}
+
+// ForInstanceOrFail calls ForInstance, failing the test if it returns an error.
+func ForInstanceOrFail(t test.Failer, i echo.Instance) []*Client {
+	t.Helper()
+	clients, err := ForInstance(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clients
+}
+
+func (c *Client) String() string {
+	return fmt.Sprintf("%s/%s", c.namespace, c.pod)
+}
+
+// request makes the request to the port from within the proxy container, returning the body and status code.
+func (c *Client) request(method string, port int, path string) (string, int, error) {
+	// The status code is written on the last line, after the body.
+	command := fmt.Sprintf(`curl -s -w \n%%{http_code} -X %s http://127.0.0.1:%d%s`, method, port, path)
+	stdout, stderr, err := c.cluster.PodExec(c.pod, c.namespace, proxyContainerName, command)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed %s %s on %s: %v\n%s", method, path, c, err, stderr)
+	}
+	sep := strings.LastIndex(stdout, "\n")
+	code, err := strconv.Atoi(strings.TrimSpace(stdout[sep+1:]))
+	if sep < 0 || err != nil {
+		return "", 0, fmt.Errorf("failed %s %s on %s: no status code in %q", method, path, c, stdout)
+	}
+	return stdout[:sep], code, nil
+}
+
+// Ready returns nil if pilot-agent reports the proxy ready to serve traffic, as used by its readiness probe, or an
+// error with the reason otherwise.
+func (c *Client) Ready() error {
+	body, code, err := c.request("GET", statusPort, readyPath)
+	if err != nil {
+		return err
+	}
+	if code != 200 {
+		return fmt.Errorf("proxy %s is not ready (%d): %s", c, code, strings.TrimSpace(body))
+	}
+	return nil
+}
+
+// WaitReady waits until pilot-agent reports the proxy ready.
+func (c *Client) WaitReady(options ...retry.Option) error {
+	return retry.UntilSuccess(c.Ready, append([]retry.Option{retry.Timeout(time.Minute)}, options...)...)
+}
+
+// WaitReadyOrFail calls WaitReady, failing the test if it returns an error.
+func (c *Client) WaitReadyOrFail(t test.Failer, options ...retry.Option) {
+	t.Helper()
+	if err := c.WaitReady(options...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Quit asks pilot-agent to exit, along with the proxy, as done by applications of Jobs once they complete. The proxy
+// container is then restarted, unless the pod has completed.
+func (c *Client) Quit() error {
+	body, code, err := c.request("POST", statusPort, quitPath)
+	if err != nil {
+		return err
+	}
+	if code != 200 {
+		return fmt.Errorf("failed quitting proxy %s (%d): %s", c, code, strings.TrimSpace(body))
+	}
+	return nil
+}
+
+// QuitOrFail calls Quit, failing the test if it returns an error.
+func (c *Client) QuitOrFail(t test.Failer) {
+	t.Helper()
+	if err := c.Quit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Drain gracefully drains the listeners of the proxy, or only its inbound listeners, as pilot-agent does when the pod
+// terminates. The listeners stop accepting connections once the drain duration of ProxyConfig has passed. pilot-agent
+// has no drain endpoint of its own, so the Envoy admin endpoint it uses is called.
+func (c *Client) Drain(inboundOnly bool) error {
+	path := "/drain_listeners?graceful"
+	if inboundOnly {
+		path += "&inboundonly"
+	}
+	body, code, err := c.request("POST", adminPort, path)
+	if err != nil {
+		return err
+	}
+	if code != 200 {
+		return fmt.Errorf("failed draining proxy %s (%d): %s", c, code, strings.TrimSpace(body))
+	}
+	return nil
+}
+
+// DrainOrFail calls Drain, failing the test if it returns an error.
+func (c *Client) DrainOrFail(t test.Failer, inboundOnly bool) {
+	t.Helper()
+	if err := c.Drain(inboundOnly); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Stats returns the stats served by pilot-agent for scraping, merging those of pilot-agent, the proxy and the
+// application.
+func (c *Client) Stats() (map[string]*dto.MetricFamily, error) {
+	body, code, err := c.request("GET", statusPort, statsPath)
+	if err != nil {
+		return nil, err
+	}
+	if code != 200 {
+		return nil, fmt.Errorf("failed getting stats of %s (%d): %s", c, code, strings.TrimSpace(body))
+	}
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing stats of %s: %v", c, err)
+	}
+	return families, nil
+}
+
+// StatsOrFail calls Stats, failing the test if it returns an error.
+func (c *Client) StatsOrFail(t test.Failer) map[string]*dto.MetricFamily {
+	t.Helper()
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
@@ -0,0 +1,201 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policyfuzz applies randomized combinations of AuthorizationPolicy and PeerAuthentication to a target
+// workload, and verifies that live traffic matches the outcome computed by a model of policy evaluation.
+package policyfuzz
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/common/response"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Config for Run.
+type Config struct {
+	// Sources of the requests. Sources without a sidecar send plaintext requests.
+	Sources []echo.Instance
+	// Target of the requests, to which the policies are applied. Required.
+	Target echo.Instance
+	// PortName of the HTTP port on the target to call. Defaults to "http".
+	PortName string
+
+	// Paths and Methods of the requests, which the generated policies also refer to. Default to "/a" and "/b", and
+	// GET and POST.
+	Paths   []string
+	Methods []string
+
+	// Seed of the first scenario. Each iteration increments the seed. Defaults to the current time. A failing
+	// scenario can be reproduced by running a single iteration with the reported seed.
+	Seed int64
+	// Iterations is the number of scenarios to verify. Defaults to 10.
+	Iterations int
+	// MaxPolicies is the maximum number of AuthorizationPolicies in a scenario. Defaults to 3.
+	MaxPolicies int
+
+	// TrustDomain of the mesh. Defaults to "cluster.local".
+	TrustDomain string
+}
+
+func (c *Config) fillDefaults() error {
+	if c.Target == nil {
+		return fmt.Errorf("target must be provided")
+	}
+	if !echo.HasSidecar(c.Target.Config()) {
+		return fmt.Errorf("target %s must have a sidecar to enforce policies", c.Target.Config().Service)
+	}
+	if len(c.Sources) == 0 {
+		return fmt.Errorf("at least one source must be provided")
+	}
+	if c.PortName == "" {
+		c.PortName = "http"
+	}
+	if len(c.Paths) == 0 {
+		c.Paths = []string{"/a", "/b"}
+	}
+	if len(c.Methods) == 0 {
+		c.Methods = []string{"GET", "POST"}
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	if c.Iterations <= 0 {
+		c.Iterations = 10
+	}
+	if c.MaxPolicies <= 0 {
+		c.MaxPolicies = 3
+	}
+	if c.TrustDomain == "" {
+		c.TrustDomain = "cluster.local"
+	}
+	return nil
+}
+
+// Principal returns the principal of the workloads of the instance, as used in AuthorizationPolicy.
+func Principal(trustDomain string, i echo.Instance) string {
+	sa := "default"
+	if i.Config().ServiceAccount {
+		sa = i.Config().Service
+	}
+	return fmt.Sprintf("%s/ns/%s/sa/%s", trustDomain, i.Config().Namespace.Name(), sa)
+}
+
+func (c Config) universe() Universe {
+	u := Universe{
+		Paths:    c.Paths,
+		Methods:  c.Methods,
+		Policies: c.MaxPolicies,
+	}
+	seen := map[string]bool{}
+	for _, src := range c.Sources {
+		u.Principals = append(u.Principals, Principal(c.TrustDomain, src))
+		if ns := src.Config().Namespace.Name(); !seen[ns] {
+			seen[ns] = true
+			u.Namespaces = append(u.Namespaces, ns)
+		}
+	}
+	return u
+}
+
+// Run verifies the configured number of generated scenarios in turn. Each scenario is applied to the namespace of the
+// target, and removed once verified. On failure, the seed and config of the scenario are reported.
+func Run(ctx resource.Context, cfg Config, opts ...retry.Option) error {
+	if err := cfg.fillDefaults(); err != nil {
+		return err
+	}
+	scopes.Framework.Infof("Fuzzing policies for %s starting with seed %d", cfg.Target.Config().Service, cfg.Seed)
+	u := cfg.universe()
+	for i := 0; i < cfg.Iterations; i++ {
+		s := Generate(u, cfg.Seed+int64(i))
+		if err := verify(ctx, cfg, s, opts...); err != nil {
+			return fmt.Errorf("scenario with seed %d failed: %v\n%s", s.Seed, err, s.YAML(cfg.Target.Config().Service))
+		}
+	}
+	return nil
+}
+
+// RunOrFail calls Run and fails the test if it returns an error.
+func RunOrFail(t test.Failer, ctx resource.Context, cfg Config, opts ...retry.Option) {
+	t.Helper()
+	if err := Run(ctx, cfg, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verify(ctx resource.Context, cfg Config, s Scenario, opts ...retry.Option) error {
+	ns := cfg.Target.Config().Namespace.Name()
+	yml := s.YAML(cfg.Target.Config().Service)
+	if yml != "" {
+		if err := ctx.Config().ApplyYAML(ns, yml); err != nil {
+			return err
+		}
+		defer func() {
+			if err := ctx.Config().DeleteYAML(ns, yml); err != nil {
+				scopes.Framework.Warnf("failed deleting policies of scenario %d: %v", s.Seed, err)
+			}
+		}()
+	}
+
+	opts = append([]retry.Option{retry.Timeout(time.Minute), retry.Delay(time.Second)}, opts...)
+	return retry.UntilSuccess(func() error {
+		var errs error
+		for _, src := range cfg.Sources {
+			mtls := echo.HasSidecar(src.Config())
+			for _, path := range cfg.Paths {
+				for _, method := range cfg.Methods {
+					expected := s.Expect(Request{
+						MTLS:      mtls,
+						Principal: Principal(cfg.TrustDomain, src),
+						Namespace: src.Config().Namespace.Name(),
+						Path:      path,
+						Method:    method,
+					})
+					if actual := call(src, cfg, path, method); actual != expected {
+						errs = multierror.Append(errs, fmt.Errorf("%s %s from %s: expected %v, got %v",
+							method, path, src.Config().Service, expected, actual))
+					}
+				}
+			}
+		}
+		return errs
+	}, opts...)
+}
+
+func call(src echo.Instance, cfg Config, path, method string) Outcome {
+	resp, err := src.Call(echo.CallOptions{
+		Target:   cfg.Target,
+		PortName: cfg.PortName,
+		Path:     path,
+		Method:   method,
+	})
+	if err != nil || len(resp) == 0 {
+		return Rejected
+	}
+	switch resp[0].Code {
+	case response.StatusCodeOK:
+		return Allowed
+	case response.StatusCodeForbidden:
+		return Denied
+	default:
+		return Rejected
+	}
+}
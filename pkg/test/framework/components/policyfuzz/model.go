@@ -0,0 +1,270 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyfuzz
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Outcome is the expected result of a request.
+type Outcome int
+
+const (
+	// Allowed requests reach the target.
+	Allowed Outcome = iota
+	// Denied requests are rejected by an AuthorizationPolicy with a 403.
+	Denied
+	// Rejected requests fail at the connection level, due to the PeerAuthentication.
+	Rejected
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Allowed:
+		return "allowed"
+	case Denied:
+		return "denied"
+	case Rejected:
+		return "rejected"
+	}
+	return fmt.Sprintf("outcome(%d)", int(o))
+}
+
+// Peer authentication modes generated by the fuzzer. DISABLE is not generated, since whether a client sidecar sends
+// mTLS to it depends on the auto mTLS implementation rather than the policy.
+const (
+	ModeUnset      = ""
+	ModePermissive = "PERMISSIVE"
+	ModeStrict     = "STRICT"
+)
+
+// Rule is an AuthorizationPolicy rule. Each field matches if any of its values match, and the rule matches if all of
+// its non-empty fields match.
+type Rule struct {
+	Principals []string
+	Namespaces []string
+	Paths      []string
+	Methods    []string
+}
+
+// Policy is an AuthorizationPolicy selecting the target.
+type Policy struct {
+	Name   string
+	Action string
+	Rules  []Rule
+}
+
+// Scenario is a generated combination of policies applied to the target.
+type Scenario struct {
+	Seed               int64
+	PeerAuthentication string
+	Policies           []Policy
+}
+
+// Request is a request to the target, as seen by its sidecar.
+type Request struct {
+	// MTLS is set if the request is sent over mTLS, in which case the principal and namespace of the source are known.
+	MTLS      bool
+	Principal string
+	Namespace string
+	Path      string
+	Method    string
+}
+
+// Universe is the set of values that generated policies can refer to.
+type Universe struct {
+	Principals []string
+	Namespaces []string
+	Paths      []string
+	Methods    []string
+	// Policies is the maximum number of AuthorizationPolicies in a scenario.
+	Policies int
+}
+
+// Generate returns a random scenario over the universe. The same seed always generates the same scenario.
+func Generate(u Universe, seed int64) Scenario {
+	r := rand.New(rand.NewSource(seed))
+	s := Scenario{
+		Seed:               seed,
+		PeerAuthentication: []string{ModeUnset, ModePermissive, ModeStrict}[r.Intn(3)],
+	}
+	policies := r.Intn(u.Policies + 1)
+	for i := 0; i < policies; i++ {
+		p := Policy{
+			Name:   fmt.Sprintf("fuzz-%d", i),
+			Action: []string{"ALLOW", "DENY"}[r.Intn(2)],
+		}
+		for j := 0; j <= r.Intn(2); j++ {
+			p.Rules = append(p.Rules, generateRule(r, u))
+		}
+		s.Policies = append(s.Policies, p)
+	}
+	return s
+}
+
+func generateRule(r *rand.Rand, u Universe) Rule {
+	for {
+		var rule Rule
+		switch r.Intn(3) {
+		case 1:
+			rule.Principals = subset(r, u.Principals)
+		case 2:
+			rule.Namespaces = subset(r, u.Namespaces)
+		}
+		switch r.Intn(3) {
+		case 1:
+			rule.Paths = subset(r, u.Paths)
+		case 2:
+			rule.Methods = subset(r, u.Methods)
+		}
+		if len(rule.Principals)+len(rule.Namespaces)+len(rule.Paths)+len(rule.Methods) > 0 {
+			return rule
+		}
+	}
+}
+
+// subset returns a random non-empty subset of values, or nil if there are none.
+func subset(r *rand.Rand, values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	var out []string
+	for len(out) == 0 {
+		for _, v := range values {
+			if r.Intn(2) == 0 {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+// Expect returns the outcome of the request under the scenario. DENY policies are evaluated before ALLOW policies,
+// and if any ALLOW policy exists, the request must match one of them.
+func (s Scenario) Expect(req Request) Outcome {
+	if s.PeerAuthentication == ModeStrict && !req.MTLS {
+		return Rejected
+	}
+	hasAllow, allowed := false, false
+	for _, p := range s.Policies {
+		matched := false
+		for _, rule := range p.Rules {
+			if rule.matches(req) {
+				matched = true
+				break
+			}
+		}
+		switch p.Action {
+		case "DENY":
+			if matched {
+				return Denied
+			}
+		case "ALLOW":
+			hasAllow = true
+			allowed = allowed || matched
+		}
+	}
+	if hasAllow && !allowed {
+		return Denied
+	}
+	return Allowed
+}
+
+func (r Rule) matches(req Request) bool {
+	// The principal and namespace of the source are only known with mTLS.
+	if len(r.Principals) > 0 && (!req.MTLS || !contains(r.Principals, req.Principal)) {
+		return false
+	}
+	if len(r.Namespaces) > 0 && (!req.MTLS || !contains(r.Namespaces, req.Namespace)) {
+		return false
+	}
+	if len(r.Paths) > 0 && !contains(r.Paths, req.Path) {
+		return false
+	}
+	if len(r.Methods) > 0 && !contains(r.Methods, req.Method) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// YAML returns the config for the scenario, with policies selecting workloads labeled with app=<app>.
+func (s Scenario) YAML(app string) string {
+	var docs []string
+	if s.PeerAuthentication != ModeUnset {
+		docs = append(docs, fmt.Sprintf(`apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: fuzz
+spec:
+  selector:
+    matchLabels:
+      app: %s
+  mtls:
+    mode: %s
+`, app, s.PeerAuthentication))
+	}
+	for _, p := range s.Policies {
+		var b strings.Builder
+		fmt.Fprintf(&b, `apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: %s
+spec:
+  selector:
+    matchLabels:
+      app: %s
+  action: %s
+  rules:
+`, p.Name, app, p.Action)
+		for _, r := range p.Rules {
+			// The first field of each rule starts the list item.
+			prefix := "  - "
+			if len(r.Principals)+len(r.Namespaces) > 0 {
+				b.WriteString(prefix + "from:\n    - source:\n")
+				writeList(&b, "principals", r.Principals)
+				writeList(&b, "namespaces", r.Namespaces)
+				prefix = "    "
+			}
+			if len(r.Paths)+len(r.Methods) > 0 {
+				b.WriteString(prefix + "to:\n    - operation:\n")
+				writeList(&b, "paths", r.Paths)
+				writeList(&b, "methods", r.Methods)
+			}
+		}
+		docs = append(docs, b.String())
+	}
+	return strings.Join(docs, "---\n")
+}
+
+func writeList(b *strings.Builder, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "        %s:\n", name)
+	for _, v := range values {
+		fmt.Fprintf(b, "        - %q\n", v)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyfuzz
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+
+	securityv1beta1 "istio.io/api/security/v1beta1"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+var universe = Universe{
+	Principals: []string{"cluster.local/ns/a/sa/a", "cluster.local/ns/b/sa/b"},
+	Namespaces: []string{"a", "b"},
+	Paths:      []string{"/a", "/b"},
+	Methods:    []string{"GET", "POST"},
+	Policies:   3,
+}
+
+func TestGenerate(t *testing.T) {
+	for seed := int64(0); seed < 200; seed++ {
+		s := Generate(universe, seed)
+		if !reflect.DeepEqual(s, Generate(universe, seed)) {
+			t.Fatalf("seed %d generated different scenarios", seed)
+		}
+		if len(s.Policies) > universe.Policies {
+			t.Fatalf("seed %d generated %d policies, more than %d", seed, len(s.Policies), universe.Policies)
+		}
+		for _, p := range s.Policies {
+			if len(p.Rules) == 0 {
+				t.Fatalf("seed %d generated policy %s without rules", seed, p.Name)
+			}
+			for _, r := range p.Rules {
+				if len(r.Principals)+len(r.Namespaces)+len(r.Paths)+len(r.Methods) == 0 {
+					t.Fatalf("seed %d generated an empty rule in policy %s", seed, p.Name)
+				}
+				for _, v := range r.Principals {
+					if !contains(universe.Principals, v) {
+						t.Fatalf("seed %d generated principal %q outside of the universe", seed, v)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestExpect(t *testing.T) {
+	fromA := Request{MTLS: true, Principal: "cluster.local/ns/a/sa/a", Namespace: "a", Path: "/a", Method: "GET"}
+	plaintext := Request{Path: "/a", Method: "GET"}
+	allowA := Policy{Name: "allow", Action: "ALLOW", Rules: []Rule{{Namespaces: []string{"a"}}}}
+	denyPost := Policy{Name: "deny", Action: "DENY", Rules: []Rule{{Methods: []string{"POST"}}}}
+	cases := []struct {
+		name     string
+		scenario Scenario
+		req      Request
+		want     Outcome
+	}{
+		{"no policies", Scenario{}, plaintext, Allowed},
+		{"strict plaintext", Scenario{PeerAuthentication: ModeStrict}, plaintext, Rejected},
+		{"strict mTLS", Scenario{PeerAuthentication: ModeStrict}, fromA, Allowed},
+		{"permissive plaintext", Scenario{PeerAuthentication: ModePermissive}, plaintext, Allowed},
+		{"allow matched", Scenario{Policies: []Policy{allowA}}, fromA, Allowed},
+		{"allow not matched", Scenario{Policies: []Policy{allowA}}, Request{MTLS: true, Namespace: "b"}, Denied},
+		// The namespace of the source is unknown without mTLS, so source rules never match.
+		{"allow plaintext", Scenario{Policies: []Policy{allowA}}, plaintext, Denied},
+		{"deny matched", Scenario{Policies: []Policy{denyPost}}, Request{Method: "POST"}, Denied},
+		{"deny not matched", Scenario{Policies: []Policy{denyPost}}, fromA, Allowed},
+		{
+			"deny before allow",
+			Scenario{Policies: []Policy{allowA, denyPost}},
+			Request{MTLS: true, Namespace: "a", Method: "POST"},
+			Denied,
+		},
+		{
+			"rule fields are and-ed",
+			Scenario{Policies: []Policy{{Action: "ALLOW", Rules: []Rule{{Namespaces: []string{"a"}, Paths: []string{"/b"}}}}}},
+			fromA,
+			Denied,
+		},
+		{
+			"rules are or-ed",
+			Scenario{Policies: []Policy{{Action: "ALLOW", Rules: []Rule{{Paths: []string{"/b"}}, {Methods: []string{"GET"}}}}}},
+			fromA,
+			Allowed,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.scenario.Expect(c.req); got != c.want {
+				t.Fatalf("expected %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+// TestYAML checks that the config of generated scenarios is valid, and applies the policies of the model.
+func TestYAML(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		s := Generate(universe, seed)
+		var policies []Policy
+		mode := ModeUnset
+		for _, doc := range strings.Split(s.YAML("b"), "---\n") {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			var obj struct {
+				Kind     string
+				Metadata struct{ Name string }
+				Spec     map[string]interface{}
+			}
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				t.Fatalf("seed %d: %v\n%s", seed, err, doc)
+			}
+			spec, err := json.Marshal(obj.Spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch obj.Kind {
+			case "PeerAuthentication":
+				pa := &securityv1beta1.PeerAuthentication{}
+				if err := gogoprotomarshal.ApplyJSONStrict(string(spec), pa); err != nil {
+					t.Fatalf("seed %d: invalid PeerAuthentication: %v\n%s", seed, err, doc)
+				}
+				mode = pa.GetMtls().GetMode().String()
+			case "AuthorizationPolicy":
+				ap := &securityv1beta1.AuthorizationPolicy{}
+				if err := gogoprotomarshal.ApplyJSONStrict(string(spec), ap); err != nil {
+					t.Fatalf("seed %d: invalid AuthorizationPolicy: %v\n%s", seed, err, doc)
+				}
+				if ap.Selector.MatchLabels["app"] != "b" {
+					t.Fatalf("seed %d: unexpected selector %v", seed, ap.Selector)
+				}
+				policies = append(policies, policyFrom(obj.Metadata.Name, ap))
+			default:
+				t.Fatalf("seed %d: unexpected kind %q", seed, obj.Kind)
+			}
+		}
+		if mode != s.PeerAuthentication {
+			t.Fatalf("seed %d: expected PeerAuthentication mode %q, got %q", seed, s.PeerAuthentication, mode)
+		}
+		if !reflect.DeepEqual(policies, s.Policies) {
+			t.Fatalf("seed %d: expected policies %+v, got %+v", seed, s.Policies, policies)
+		}
+	}
+}
+
+// policyFrom converts an AuthorizationPolicy back to the model.
+func policyFrom(name string, ap *securityv1beta1.AuthorizationPolicy) Policy {
+	p := Policy{Name: name, Action: ap.Action.String()}
+	for _, r := range ap.Rules {
+		var rule Rule
+		for _, f := range r.From {
+			rule.Principals = append(rule.Principals, f.Source.Principals...)
+			rule.Namespaces = append(rule.Namespaces, f.Source.Namespaces...)
+		}
+		for _, to := range r.To {
+			rule.Paths = append(rule.Paths, to.Operation.Paths...)
+			rule.Methods = append(rule.Methods, to.Operation.Methods...)
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	return p
+}
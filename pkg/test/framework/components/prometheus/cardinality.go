@@ -0,0 +1,192 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	prom "github.com/prometheus/common/model"
+
+	"istio.io/istio/pkg/test"
+)
+
+// CardinalityBudget bounds the series of the metrics matched by a selector, to catch regressions adding labels or
+// unbounded label values to telemetry. Zero limits are unbounded.
+type CardinalityBudget struct {
+	// Selector of the series inventoried, such as `{__name__=~"istio_.*",destination_workload_namespace="echo"}`.
+	Selector string
+	// MaxSeries is the number of series of all metrics.
+	MaxSeries int
+	// MaxSeriesPerMetric is the number of series of each metric.
+	MaxSeriesPerMetric int
+	// MaxLabelValues is the number of distinct values of each label of a metric.
+	MaxLabelValues int
+	// LabelValues overrides MaxLabelValues for the labels by name, such as "le" for the buckets of histograms.
+	LabelValues map[string]int
+}
+
+// MetricCardinality is the inventory of the series of a metric.
+type MetricCardinality struct {
+	// Series is the number of series of the metric.
+	Series int
+	// LabelValues is the number of distinct values of each label of the metric.
+	LabelValues map[string]int
+}
+
+// Cardinality is the inventory of the series of the metrics matched by a selector.
+type Cardinality struct {
+	// Series is the number of series of all metrics.
+	Series int
+	// Metrics are the inventories of each metric, by name.
+	Metrics map[string]MetricCardinality
+}
+
+// newCardinality inventories the series.
+func newCardinality(series []prom.LabelSet) Cardinality {
+	values := map[string]map[prom.LabelName]map[prom.LabelValue]struct{}{}
+	c := Cardinality{Series: len(series), Metrics: map[string]MetricCardinality{}}
+	for _, s := range series {
+		name := string(s[prom.MetricNameLabel])
+		m := c.Metrics[name]
+		m.Series++
+		c.Metrics[name] = m
+		if values[name] == nil {
+			values[name] = map[prom.LabelName]map[prom.LabelValue]struct{}{}
+		}
+		for l, v := range s {
+			if l == prom.MetricNameLabel {
+				continue
+			}
+			if values[name][l] == nil {
+				values[name][l] = map[prom.LabelValue]struct{}{}
+			}
+			values[name][l][v] = struct{}{}
+		}
+	}
+	for name, labels := range values {
+		m := c.Metrics[name]
+		m.LabelValues = map[string]int{}
+		for l, vs := range labels {
+			m.LabelValues[string(l)] = len(vs)
+		}
+		c.Metrics[name] = m
+	}
+	return c
+}
+
+// Check returns an error listing every limit of the budget the inventory exceeds.
+func (c Cardinality) Check(b CardinalityBudget) error {
+	var errs error
+	if b.MaxSeries > 0 && c.Series > b.MaxSeries {
+		errs = multierror.Append(errs, fmt.Errorf("%d series, budget is %d", c.Series, b.MaxSeries))
+	}
+	for _, name := range c.metricNames() {
+		m := c.Metrics[name]
+		if b.MaxSeriesPerMetric > 0 && m.Series > b.MaxSeriesPerMetric {
+			errs = multierror.Append(errs, fmt.Errorf("%s has %d series, budget is %d", name, m.Series,
+				b.MaxSeriesPerMetric))
+		}
+		labels := make([]string, 0, len(m.LabelValues))
+		for l := range m.LabelValues {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			max := b.MaxLabelValues
+			if v, ok := b.LabelValues[l]; ok {
+				max = v
+			}
+			if max > 0 && m.LabelValues[l] > max {
+				errs = multierror.Append(errs, fmt.Errorf("%s label %s has %d values, budget is %d", name, l,
+					m.LabelValues[l], max))
+			}
+		}
+	}
+	return errs
+}
+
+// String formats the inventory as a table of the metrics, by decreasing number of series, with their label of the
+// most values.
+func (c Cardinality) String() string {
+	names := c.metricNames()
+	sort.SliceStable(names, func(i, j int) bool {
+		return c.Metrics[names[i]].Series > c.Metrics[names[j]].Series
+	})
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "METRIC\tSERIES\tTOP LABEL\tVALUES\n")
+	for _, name := range names {
+		m := c.Metrics[name]
+		top, topValues := "", 0
+		for l, v := range m.LabelValues {
+			if v > topValues || (v == topValues && l < top) {
+				top, topValues = l, v
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", name, m.Series, top, topValues)
+	}
+	_, _ = fmt.Fprintf(w, "TOTAL\t%d\t\t\n", c.Series)
+	_ = w.Flush()
+	return sb.String()
+}
+
+func (c Cardinality) metricNames() []string {
+	names := make([]string, 0, len(c.Metrics))
+	for name := range c.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InventoryCardinality inventories the series matched by the selector that were active since the given time, such
+// as the start of the traffic of a test.
+func InventoryCardinality(api v1.API, selector string, since time.Time) (Cardinality, error) {
+	series, _, err := api.Series(context.Background(), []string{selector}, since, time.Now())
+	if err != nil {
+		return Cardinality{}, fmt.Errorf("error querying Prometheus series %s: %v", selector, err)
+	}
+	return newCardinality(series), nil
+}
+
+// CheckCardinality inventories the series of the budget active since the given time, and returns an error if they
+// exceed it.
+func CheckCardinality(p Instance, b CardinalityBudget, since time.Time) (Cardinality, error) {
+	c, err := InventoryCardinality(p.API(), b.Selector, since)
+	if err != nil {
+		return c, err
+	}
+	if err := c.Check(b); err != nil {
+		return c, fmt.Errorf("series of %s exceed the cardinality budget: %v\n%s", b.Selector, err, c)
+	}
+	return c, nil
+}
+
+// CheckCardinalityOrFail calls CheckCardinality and fails the test if it returns an error.
+func CheckCardinalityOrFail(t test.Failer, p Instance, b CardinalityBudget, since time.Time) Cardinality {
+	t.Helper()
+	c, err := CheckCardinality(p, b, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,264 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxyannotations deploys echo workloads with the common proxy annotations of the sidecar injector, and
+// verifies the behavior expected from each of them.
+package proxyannotations
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Proxy annotations covered by the matrix.
+const (
+	IncludeOutboundIPRanges = "traffic.sidecar.istio.io/includeOutboundIPRanges"
+	ExcludeOutboundIPRanges = "traffic.sidecar.istio.io/excludeOutboundIPRanges"
+	IncludeInboundPorts     = "traffic.sidecar.istio.io/includeInboundPorts"
+	ExcludeInboundPorts     = "traffic.sidecar.istio.io/excludeInboundPorts"
+	InterceptionMode        = "sidecar.istio.io/interceptionMode"
+)
+
+const (
+	clientService = "pa-client"
+	serverService = "pa-server"
+	portName      = "http"
+	// port of the echo workloads. Above 1024, so that the application does not require root.
+	port = 8090
+
+	proxyContainer = "istio-proxy"
+	xfccHeader     = "X-Forwarded-Client-Cert"
+)
+
+// Expectation of a call between the workloads of a case.
+type Expectation struct {
+	// Success of the request. Requests fail when the client sidecar originates mTLS to a port the server sidecar
+	// does not intercept.
+	Success bool
+	// MTLS is whether the request went through both sidecars, which authenticate it with mTLS.
+	MTLS bool
+	// SourceIPPreserved is whether the server application sees the IP of the client pod.
+	SourceIPPreserved bool
+	// ProxyStartsFirst is whether istio-proxy is the first container, and holds the others until it is ready.
+	ProxyStartsFirst bool
+}
+
+// Case applies annotations to the client or the server of a call.
+type Case struct {
+	Name        string
+	Annotations map[string]string
+	// OnServer applies the annotations to the server rather than the client.
+	OnServer bool
+	Expect   Expectation
+}
+
+func (c Case) service() string {
+	side := "c"
+	if c.OnServer {
+		side = "s"
+	}
+	return fmt.Sprintf("pa-%s-%s", side, c.Name)
+}
+
+// Cases returns the annotation matrix. holdApplicationUntilProxyStarts is an install option in this version
+// (values.global.proxy.holdApplicationUntilProxyStarts) rather than an annotation, and is only expected of the default
+// case if the installed Istio enables it.
+func Cases(holdApplicationUntilProxyStarts bool) []Case {
+	captured := Expectation{Success: true, MTLS: true, ProxyStartsFirst: holdApplicationUntilProxyStarts}
+	bypassed := Expectation{Success: true, ProxyStartsFirst: holdApplicationUntilProxyStarts}
+	failed := Expectation{ProxyStartsFirst: holdApplicationUntilProxyStarts}
+	tproxy := captured
+	tproxy.SourceIPPreserved = true
+	return []Case{
+		{Name: "default", Expect: captured},
+		{Name: "include-outbound-none", Annotations: map[string]string{IncludeOutboundIPRanges: ""}, Expect: bypassed},
+		{Name: "exclude-outbound-all", Annotations: map[string]string{
+			IncludeOutboundIPRanges: "*",
+			ExcludeOutboundIPRanges: "0.0.0.0/0",
+		}, Expect: bypassed},
+		{Name: "include-inbound-none", OnServer: true, Annotations: map[string]string{IncludeInboundPorts: ""},
+			Expect: failed},
+		{Name: "exclude-inbound-port", OnServer: true, Annotations: map[string]string{
+			ExcludeInboundPorts: fmt.Sprint(port),
+		}, Expect: failed},
+		{Name: "tproxy", OnServer: true, Annotations: map[string]string{InterceptionMode: "TPROXY"}, Expect: tproxy},
+	}
+}
+
+// Deployments of the matrix.
+type Deployments struct {
+	// Client and Server without annotations, the peers of the annotated workloads.
+	Client echo.Instance
+	Server echo.Instance
+	// ByCase holds the annotated workload of each case.
+	ByCase map[string]echo.Instance
+}
+
+func config(ns namespace.Instance, service string, annotations map[string]string) echo.Config {
+	a := echo.NewAnnotations()
+	for k, v := range annotations {
+		a.Set(echo.Annotation{Name: k, Type: echo.WorkloadAnnotation}, v)
+	}
+	return echo.Config{
+		Service:   service,
+		Namespace: ns,
+		Ports: []echo.Port{{
+			Name:         portName,
+			Protocol:     protocol.HTTP,
+			InstancePort: port,
+		}},
+		Subsets: []echo.SubsetConfig{{Annotations: a}},
+	}
+}
+
+// Deploy the unannotated client and server, and an annotated workload per case.
+func Deploy(ctx resource.Context, ns namespace.Instance, cases []Case) (Deployments, error) {
+	d := Deployments{ByCase: map[string]echo.Instance{}}
+	instances := make([]echo.Instance, len(cases))
+	builder := echoboot.NewBuilder(ctx).
+		With(&d.Client, config(ns, clientService, nil)).
+		With(&d.Server, config(ns, serverService, nil))
+	for i, c := range cases {
+		builder = builder.With(&instances[i], config(ns, c.service(), c.Annotations))
+	}
+	if _, err := builder.Build(); err != nil {
+		return Deployments{}, err
+	}
+	for i, c := range cases {
+		d.ByCase[c.Name] = instances[i]
+	}
+	return d, nil
+}
+
+// DeployOrFail calls Deploy and fails the test if it returns an error.
+func DeployOrFail(t test.Failer, ctx resource.Context, ns namespace.Instance, cases []Case) Deployments {
+	t.Helper()
+	d, err := Deploy(ctx, ns, cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// Verify calls the server from the client of the case, one of which is annotated, and checks the expectations.
+func Verify(d Deployments, c Case, opts ...retry.Option) error {
+	annotated, ok := d.ByCase[c.Name]
+	if !ok {
+		return fmt.Errorf("case %s was not deployed", c.Name)
+	}
+	src, dst := annotated, d.Server
+	if c.OnServer {
+		src, dst = d.Client, annotated
+	}
+	if err := checkProxyStartsFirst(annotated, c.Expect.ProxyStartsFirst); err != nil {
+		return err
+	}
+	return retry.UntilSuccess(func() error {
+		resp, err := src.Call(echo.CallOptions{Target: dst, PortName: portName})
+		if !c.Expect.Success {
+			if err == nil && resp.CheckOK() == nil {
+				return fmt.Errorf("expected request to fail")
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := resp.CheckOK(); err != nil {
+			return err
+		}
+		r := resp[0]
+		if mtls := r.RawResponse[xfccHeader] != ""; mtls != c.Expect.MTLS {
+			return fmt.Errorf("expected mTLS between sidecars=%v, got %v", c.Expect.MTLS, mtls)
+		}
+		remote, _, err := net.SplitHostPort(r.RawResponse["RemoteAddr"])
+		if err != nil {
+			return fmt.Errorf("invalid RemoteAddr %q: %v", r.RawResponse["RemoteAddr"], err)
+		}
+		workloads, err := src.Workloads()
+		if err != nil {
+			return err
+		}
+		preserved := false
+		for _, w := range workloads {
+			if w.Address() == remote {
+				preserved = true
+			}
+		}
+		if preserved != c.Expect.SourceIPPreserved {
+			return fmt.Errorf("expected source IP preserved=%v, server saw %s", c.Expect.SourceIPPreserved, remote)
+		}
+		return nil
+	}, opts...)
+}
+
+func checkProxyStartsFirst(i echo.Instance, expected bool) error {
+	pods, err := i.Config().Cluster.PodsForSelector(context.TODO(), i.Config().Namespace.Name(),
+		"app="+i.Config().Service)
+	if err != nil {
+		return err
+	}
+	for _, p := range pods.Items {
+		containers := p.Spec.Containers
+		first := len(containers) > 0 && containers[0].Name == proxyContainer &&
+			containers[0].Lifecycle != nil && containers[0].Lifecycle.PostStart != nil
+		if first != expected {
+			return fmt.Errorf("expected proxy to start first=%v in pod %s/%s", expected, p.Namespace, p.Name)
+		}
+	}
+	return nil
+}
+
+// VerifyAll verifies every case, returning the errors of all failed cases.
+func VerifyAll(d Deployments, cases []Case, opts ...retry.Option) error {
+	var errs error
+	for _, c := range cases {
+		if err := Verify(d, c, opts...); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s (%s): %v", c.Name, describe(c), err))
+		}
+	}
+	return errs
+}
+
+// VerifyAllOrFail calls VerifyAll and fails the test if it returns an error.
+func VerifyAllOrFail(t test.Failer, d Deployments, cases []Case, opts ...retry.Option) {
+	t.Helper()
+	if err := VerifyAll(d, cases, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func describe(c Case) string {
+	side := "client"
+	if c.OnServer {
+		side = "server"
+	}
+	pairs := make([]string, 0, len(c.Annotations))
+	for k, v := range c.Annotations {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", k, v))
+	}
+	return side + " " + strings.Join(pairs, ",")
+}
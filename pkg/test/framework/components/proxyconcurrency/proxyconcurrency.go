@@ -0,0 +1,282 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxyconcurrency sets the number of worker threads of proxies, the concurrency of ProxyConfig, and verifies
+// its effects: the number of workers each proxy runs, the distribution of connections across them, and the
+// throughput of the proxy. The per-worker stats of listeners are excluded by default, so proxies must be configured
+// to record them, with WithConcurrency for echo instances and GatewayValues for the ingress gateway.
+package proxyconcurrency
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	dto "github.com/prometheus/client_model/go"
+
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/istio/ingress"
+)
+
+const (
+	// statsInclusionPrefix makes proxies record the stats of their listeners, including those of each worker.
+	statsInclusionPrefix = "listener."
+
+	serverConcurrencyStat   = "server.concurrency"
+	serverConcurrencyFamily = "envoy_server_concurrency"
+)
+
+// GatewayValues are the control plane values making the ingress gateway record the stats of its workers.
+const GatewayValues = `
+values:
+  gateways:
+    istio-ingressgateway:
+      podAnnotations:
+        sidecar.istio.io/statsInclusionPrefixes: ` + statsInclusionPrefix + `
+`
+
+var (
+	// workerStat matches the connections accepted by a worker on a listener, such as
+	// listener.0.0.0.0_8080.worker_0.downstream_cx_total.
+	workerStat = regexp.MustCompile(`^listener\..+\.worker_(\d+)\.downstream_cx_total$`)
+	// workerFamily matches the same stat in Prometheus format, with the address of the listener extracted as a label.
+	workerFamily = regexp.MustCompile(`^envoy_listener_worker_(\d+)_downstream_cx_total$`)
+)
+
+// WithConcurrency returns a copy of the config in which the sidecar of every subset runs n worker threads and records
+// the stats of its workers. Any other ProxyConfig set by annotation is replaced.
+func WithConcurrency(cfg echo.Config, n int) echo.Config {
+	if len(cfg.Subsets) == 0 {
+		cfg.Subsets = []echo.SubsetConfig{{Version: cfg.Version}}
+	}
+	subsets := make([]echo.SubsetConfig, 0, len(cfg.Subsets))
+	for _, s := range cfg.Subsets {
+		annotations := echo.NewAnnotations()
+		for k, v := range s.Annotations {
+			annotations[k] = v
+		}
+		prefixes := statsInclusionPrefix
+		if existing := annotations.Get(echo.SidecarStatsInclusionPrefixes); existing != "" {
+			prefixes = existing + "," + prefixes
+		}
+		s.Annotations = annotations.
+			Set(echo.SidecarProxyConfig, fmt.Sprintf("concurrency: %d", n)).
+			Set(echo.SidecarStatsInclusionPrefixes, prefixes)
+		subsets = append(subsets, s)
+	}
+	cfg.Subsets = subsets
+	return cfg
+}
+
+// Workers of a proxy.
+type Workers struct {
+	// Concurrency is the number of worker threads the proxy runs.
+	Concurrency int
+	// Connections accepted by each worker over all listeners, by the index of the worker. Cumulative since the proxy
+	// started, unless subtracted.
+	Connections map[int]int
+}
+
+// Sub returns the connections accepted since the earlier stats.
+func (w Workers) Sub(before Workers) Workers {
+	out := Workers{Concurrency: w.Concurrency, Connections: map[int]int{}}
+	for i, n := range w.Connections {
+		out.Connections[i] = n - before.Connections[i]
+	}
+	return out
+}
+
+// Active returns the number of workers that accepted connections.
+func (w Workers) Active() int {
+	active := 0
+	for _, n := range w.Connections {
+		if n > 0 {
+			active++
+		}
+	}
+	return active
+}
+
+// Check verifies that the proxy runs the given number of workers, and that only those accepted connections.
+func (w Workers) Check(concurrency int) error {
+	if w.Concurrency != concurrency {
+		return fmt.Errorf("proxy runs %d workers, expected %d", w.Concurrency, concurrency)
+	}
+	for i, n := range w.Connections {
+		if i >= concurrency && n > 0 {
+			return fmt.Errorf("worker %d of a proxy running %d workers accepted %d connections", i, concurrency, n)
+		}
+	}
+	return nil
+}
+
+// CheckDistribution verifies that the connections were spread across at least min workers. The proxies leave the
+// balancing of connections to the kernel, so an even distribution is not expected.
+func (w Workers) CheckDistribution(min int) error {
+	if active := w.Active(); active < min {
+		return fmt.Errorf("connections were accepted by %d workers, expected at least %d: %v", active, min, w)
+	}
+	return nil
+}
+
+func (w Workers) String() string {
+	var indexes []int
+	for i := range w.Connections {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	var parts []string
+	for _, i := range indexes {
+		parts = append(parts, fmt.Sprintf("worker_%d=%d", i, w.Connections[i]))
+	}
+	return fmt.Sprintf("concurrency=%d [%s]", w.Concurrency, strings.Join(parts, " "))
+}
+
+// SidecarWorkers returns the workers of the sidecar of the workload.
+func SidecarWorkers(w echo.Workload) (Workers, error) {
+	if w.Sidecar() == nil {
+		return Workers{}, fmt.Errorf("workload %s has no sidecar", w.Address())
+	}
+	stats, err := w.Sidecar().Stats()
+	if err != nil {
+		return Workers{}, err
+	}
+	return workersFromFamilies(stats)
+}
+
+// CheckSidecarConcurrency verifies that the sidecars of all workloads of the instance run the given number of
+// workers.
+func CheckSidecarConcurrency(i echo.Instance, concurrency int) error {
+	workloads, err := i.Workloads()
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, w := range workloads {
+		workers, err := SidecarWorkers(w)
+		if err == nil {
+			err = workers.Check(concurrency)
+		}
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %v", w.Address(), err))
+		}
+	}
+	return errs
+}
+
+// GatewayWorkers returns the workers of the gateway.
+func GatewayWorkers(gw ingress.Instance) (Workers, error) {
+	stats, err := gw.ProxyStats()
+	if err != nil {
+		return Workers{}, err
+	}
+	return workersFromStats(stats)
+}
+
+func workersFromFamilies(families map[string]*dto.MetricFamily) (Workers, error) {
+	w := Workers{Connections: map[int]int{}}
+	concurrency, ok := families[serverConcurrencyFamily]
+	if !ok || len(concurrency.GetMetric()) == 0 {
+		return w, fmt.Errorf("proxy does not report %s", serverConcurrencyFamily)
+	}
+	w.Concurrency = int(concurrency.GetMetric()[0].GetGauge().GetValue())
+	for name, f := range families {
+		m := workerFamily.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		i, _ := strconv.Atoi(m[1])
+		for _, metric := range f.GetMetric() {
+			w.Connections[i] += int(metric.GetCounter().GetValue())
+		}
+	}
+	if len(w.Connections) == 0 {
+		return w, fmt.Errorf("proxy does not report the stats of its workers; are %q stats included?", statsInclusionPrefix)
+	}
+	return w, nil
+}
+
+func workersFromStats(stats map[string]int) (Workers, error) {
+	w := Workers{Connections: map[int]int{}}
+	concurrency, ok := stats[serverConcurrencyStat]
+	if !ok {
+		return w, fmt.Errorf("proxy does not report %s", serverConcurrencyStat)
+	}
+	w.Concurrency = concurrency
+	for name, n := range stats {
+		m := workerStat.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		i, _ := strconv.Atoi(m[1])
+		w.Connections[i] += n
+	}
+	if len(w.Connections) == 0 {
+		return w, fmt.Errorf("proxy does not report the stats of its workers; are %q stats included?", statsInclusionPrefix)
+	}
+	return w, nil
+}
+
+// Load generates traffic, returning the number of successful requests.
+type Load func() (int, error)
+
+// CallLoad returns a load that makes the call from the source.
+func CallLoad(src echo.Instance, opts echo.CallOptions) Load {
+	return func() (int, error) {
+		resp, err := src.Call(opts)
+		if err != nil {
+			return 0, err
+		}
+		return len(resp), nil
+	}
+}
+
+// GatewayLoad returns a load that makes the call through the gateway.
+func GatewayLoad(gw ingress.Instance, opts echo.CallOptions) Load {
+	return func() (int, error) {
+		resp, err := gw.CallEcho(opts)
+		if err != nil {
+			return 0, err
+		}
+		return len(resp), nil
+	}
+}
+
+// Throughput runs the load repeatedly for at least the duration, returning the successful requests per second.
+func Throughput(load Load, d time.Duration) (float64, error) {
+	start := time.Now()
+	requests := 0
+	for time.Since(start) < d {
+		n, err := load()
+		if err != nil {
+			return 0, err
+		}
+		requests += n
+	}
+	return float64(requests) / time.Since(start).Seconds(), nil
+}
+
+// CheckThroughput verifies that the throughput of a proxy after changing its concurrency is at least the ratio of its
+// throughput before. Whether more workers raise the throughput depends on the CPUs of the node and the load, so a
+// ratio below 1 guards against regressions rather than requiring a gain.
+func CheckThroughput(before, after, ratio float64) error {
+	if after < before*ratio {
+		return fmt.Errorf("throughput dropped from %.1f to %.1f requests per second, below %.0f%%", before, after, ratio*100)
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxyusage samples the CPU and memory used by the proxies of sidecars and gateways while a test runs, and
+// asserts on them against budgets, so that changes regressing the overhead of the data plane in common scenarios are
+// caught.
+//
+// Usage is read from the resource metrics API if the cluster serves it, or else from the cgroup statistics of the
+// proxy container, read with exec. The metrics API only refreshes its samples every scrape of metrics-server, so tests
+// should run long enough for a few of them.
+package proxyusage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiResource "k8s.io/apimachinery/pkg/api/resource"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+const (
+	proxyContainer      = "istio-proxy"
+	metricsGroupVersion = "metrics.k8s.io/v1beta1"
+)
+
+// Target is the proxy containers of the pods matching a selector.
+type Target struct {
+	Cluster   resource.Cluster
+	Namespace string
+	Selector  string
+	Container string
+}
+
+// Sidecars returns the target of the sidecars of the instance.
+func Sidecars(i echo.Instance) Target {
+	return Target{
+		Cluster:   i.Config().Cluster,
+		Namespace: i.Config().Namespace.Name(),
+		Selector:  "app=" + i.Config().Service,
+		Container: proxyContainer,
+	}
+}
+
+// Gateway returns the target of the proxies of a gateway, such as the pods labeled istio=ingressgateway in
+// istio-system.
+func Gateway(c resource.Cluster, ns, selector string) Target {
+	return Target{Cluster: c, Namespace: ns, Selector: selector, Container: proxyContainer}
+}
+
+func (t Target) String() string {
+	return fmt.Sprintf("%s of %s/%s in %s", t.Container, t.Namespace, t.Selector, t.Cluster.Name())
+}
+
+// Usage of a container.
+type Usage struct {
+	CPUMillicores int64
+	MemoryBytes   int64
+}
+
+func (u Usage) String() string {
+	return fmt.Sprintf("cpu=%dm memory=%dMi", u.CPUMillicores, u.MemoryBytes/(1<<20))
+}
+
+// Budget is the maximum usage of any of the containers of a target. Zero values are not checked.
+type Budget struct {
+	CPUMillicores int64
+	MemoryBytes   int64
+}
+
+// Report of the usage sampled for a target.
+type Report struct {
+	Target  Target
+	Samples int
+	// Max is the maximum usage of any container at any sample.
+	Max Usage
+	// Mean is the mean usage of the containers over all samples.
+	Mean Usage
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%v: %d samples, max %v, mean %v", r.Target, r.Samples, r.Max, r.Mean)
+}
+
+// Check the maximum usage of the report against the budget.
+func (r Report) Check(b Budget) error {
+	if r.Samples == 0 {
+		return fmt.Errorf("no usage sampled for %v", r.Target)
+	}
+	var errs error
+	if b.CPUMillicores > 0 && r.Max.CPUMillicores > b.CPUMillicores {
+		errs = multierror.Append(errs, fmt.Errorf("%v used %dm CPU, over the budget of %dm",
+			r.Target, r.Max.CPUMillicores, b.CPUMillicores))
+	}
+	if b.MemoryBytes > 0 && r.Max.MemoryBytes > b.MemoryBytes {
+		errs = multierror.Append(errs, fmt.Errorf("%v used %d bytes of memory, over the budget of %d bytes",
+			r.Target, r.Max.MemoryBytes, b.MemoryBytes))
+	}
+	return errs
+}
+
+// CheckOrFail calls Check and fails the test if it returns an error.
+func (r Report) CheckOrFail(t test.Failer, b Budget) {
+	t.Helper()
+	if err := r.Check(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Sampler samples the usage of a target at an interval, until stopped.
+type Sampler struct {
+	target   Target
+	metrics  bool
+	stop     chan struct{}
+	done     chan struct{}
+	mu       sync.Mutex
+	samples  []Usage
+	cgroupAt map[string]cgroupSample
+}
+
+// Start sampling the usage of the target every interval, defaulting to 5s, until Stop is called. It fails if no pods
+// match the target.
+func Start(t Target, interval time.Duration) (*Sampler, error) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	pods, err := t.Cluster.PodsForSelector(context.TODO(), t.Namespace, t.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pods found for %v", t)
+	}
+	_, err = t.Cluster.Discovery().ServerResourcesForGroupVersion(metricsGroupVersion)
+	s := &Sampler{
+		target:   t,
+		metrics:  err == nil,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		cgroupAt: map[string]cgroupSample{},
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.sample()
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s, nil
+}
+
+// StartOrFail calls Start and fails the test if it returns an error.
+func StartOrFail(t test.Failer, target Target, interval time.Duration) *Sampler {
+	t.Helper()
+	s, err := Start(target, interval)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// Stop sampling, and return the report of the samples taken. It may be called more than once.
+func (s *Sampler) Stop() Report {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := Report{Target: s.target, Samples: len(s.samples)}
+	if len(s.samples) == 0 {
+		return r
+	}
+	var cpu, mem int64
+	for _, u := range s.samples {
+		if u.CPUMillicores > r.Max.CPUMillicores {
+			r.Max.CPUMillicores = u.CPUMillicores
+		}
+		if u.MemoryBytes > r.Max.MemoryBytes {
+			r.Max.MemoryBytes = u.MemoryBytes
+		}
+		cpu += u.CPUMillicores
+		mem += u.MemoryBytes
+	}
+	r.Mean = Usage{CPUMillicores: cpu / int64(len(s.samples)), MemoryBytes: mem / int64(len(s.samples))}
+	return r
+}
+
+func (s *Sampler) sample() {
+	pods, err := s.target.Cluster.PodsForSelector(context.TODO(), s.target.Namespace, s.target.Selector)
+	if err != nil {
+		scopes.Framework.Warnf("failed listing pods of %v: %v", s.target, err)
+		return
+	}
+	for _, p := range pods.Items {
+		var u Usage
+		var ok bool
+		if s.metrics {
+			u, err = s.fromMetrics(p.Name)
+			ok = err == nil
+		} else {
+			u, ok, err = s.fromCgroup(p.Name)
+		}
+		if err != nil {
+			scopes.Framework.Warnf("failed sampling usage of %s/%s: %v", s.target.Namespace, p.Name, err)
+			continue
+		}
+		if ok {
+			s.mu.Lock()
+			s.samples = append(s.samples, u)
+			s.mu.Unlock()
+		}
+	}
+}
+
+type podMetrics struct {
+	Containers []struct {
+		Name  string            `json:"name"`
+		Usage map[string]string `json:"usage"`
+	} `json:"containers"`
+}
+
+func (s *Sampler) fromMetrics(pod string) (Usage, error) {
+	body, err := s.target.Cluster.REST().Get().
+		AbsPath("/apis", metricsGroupVersion, "namespaces", s.target.Namespace, "pods", pod).
+		DoRaw(context.TODO())
+	if err != nil {
+		return Usage{}, err
+	}
+	var m podMetrics
+	if err := json.Unmarshal(body, &m); err != nil {
+		return Usage{}, err
+	}
+	for _, c := range m.Containers {
+		if c.Name != s.target.Container {
+			continue
+		}
+		cpu, err := kubeApiResource.ParseQuantity(c.Usage["cpu"])
+		if err != nil {
+			return Usage{}, fmt.Errorf("invalid cpu usage %q: %v", c.Usage["cpu"], err)
+		}
+		mem, err := kubeApiResource.ParseQuantity(c.Usage["memory"])
+		if err != nil {
+			return Usage{}, fmt.Errorf("invalid memory usage %q: %v", c.Usage["memory"], err)
+		}
+		return Usage{CPUMillicores: cpu.MilliValue(), MemoryBytes: mem.Value()}, nil
+	}
+	return Usage{}, fmt.Errorf("no metrics for container %s of pod %s", s.target.Container, pod)
+}
+
+// cgroupSample is the cumulative CPU time of a container, to compute its usage between samples.
+type cgroupSample struct {
+	at       time.Time
+	cpuNanos int64
+}
+
+// Paths of the memory usage and cumulative CPU time of a container, for cgroup v2 and v1.
+const (
+	cgroupV2 = "cat /sys/fs/cgroup/memory.current /sys/fs/cgroup/cpu.stat"
+	cgroupV1 = "cat /sys/fs/cgroup/memory/memory.usage_in_bytes /sys/fs/cgroup/cpuacct/cpuacct.usage"
+)
+
+// fromCgroup reads the usage of the container from its cgroup. The CPU usage is the rate between samples, so the
+// first sample of each pod only records the CPU time, and is not reported.
+func (s *Sampler) fromCgroup(pod string) (Usage, bool, error) {
+	now := time.Now()
+	var mem, cpuNanos int64
+	out, _, err := s.target.Cluster.PodExec(pod, s.target.Namespace, s.target.Container, cgroupV2)
+	if err == nil {
+		mem, cpuNanos, err = parseCgroupV2(out)
+	} else {
+		out, _, err = s.target.Cluster.PodExec(pod, s.target.Namespace, s.target.Container, cgroupV1)
+		if err == nil {
+			mem, cpuNanos, err = parseCgroupV1(out)
+		}
+	}
+	if err != nil {
+		return Usage{}, false, err
+	}
+	s.mu.Lock()
+	prev, ok := s.cgroupAt[pod]
+	s.cgroupAt[pod] = cgroupSample{at: now, cpuNanos: cpuNanos}
+	s.mu.Unlock()
+	if !ok {
+		return Usage{}, false, nil
+	}
+	elapsed := now.Sub(prev.at).Nanoseconds()
+	if elapsed <= 0 {
+		return Usage{}, false, nil
+	}
+	return Usage{CPUMillicores: (cpuNanos - prev.cpuNanos) * 1000 / elapsed, MemoryBytes: mem}, true, nil
+}
+
+// parseCgroupV2 parses memory.current followed by cpu.stat, whose usage_usec is the CPU time in microseconds.
+func parseCgroupV2(out string) (int64, int64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 2 {
+		return 0, 0, fmt.Errorf("unexpected cgroup statistics %q", out)
+	}
+	mem, err := strconv.ParseInt(strings.TrimSpace(lines[0]), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid memory.current %q: %v", lines[0], err)
+	}
+	for _, l := range lines[1:] {
+		f := strings.Fields(l)
+		if len(f) == 2 && f[0] == "usage_usec" {
+			usec, err := strconv.ParseInt(f[1], 10, 64)
+			if err != nil {
+				return 0, 0, fmt.Errorf("invalid usage_usec %q: %v", f[1], err)
+			}
+			return mem, usec * 1000, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("no usage_usec in cpu.stat %q", out)
+}
+
+// parseCgroupV1 parses memory.usage_in_bytes followed by cpuacct.usage, the CPU time in nanoseconds.
+func parseCgroupV1(out string) (int64, int64, error) {
+	f := strings.Fields(out)
+	if len(f) != 2 {
+		return 0, 0, fmt.Errorf("unexpected cgroup statistics %q", out)
+	}
+	mem, err := strconv.ParseInt(f[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid memory.usage_in_bytes %q: %v", f[0], err)
+	}
+	cpu, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cpuacct.usage %q: %v", f[1], err)
+	}
+	return mem, cpu, nil
+}
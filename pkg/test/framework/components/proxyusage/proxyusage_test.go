@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxyusage
+
+import (
+	"strings"
+	"testing"
+)
+
+// cgroupV2Stats is the output of cgroupV2 for a sidecar.
+const cgroupV2Stats = `52183040
+usage_usec 1834512
+user_usec 1230000
+system_usec 604512
+nr_periods 0
+nr_throttled 0
+throttled_usec 0
+`
+
+// cgroupV1Stats is the output of cgroupV1 for a sidecar.
+const cgroupV1Stats = `52183040
+1834512345
+`
+
+type cgroupCase struct {
+	name    string
+	out     string
+	mem     int64
+	cpu     int64
+	wantErr string
+}
+
+func runCgroupCases(t *testing.T, parse func(string) (int64, int64, error), cases []cgroupCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mem, cpu, err := parse(tc.out)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mem != tc.mem || cpu != tc.cpu {
+				t.Fatalf("got memory %d and CPU %dns, want %d and %dns", mem, cpu, tc.mem, tc.cpu)
+			}
+		})
+	}
+}
+
+func TestParseCgroupV2(t *testing.T) {
+	runCgroupCases(t, parseCgroupV2, []cgroupCase{
+		{name: "stats", out: cgroupV2Stats, mem: 52183040, cpu: 1834512000},
+		{name: "no cpu.stat", out: "52183040\n", wantErr: "unexpected cgroup statistics"},
+		{name: "invalid memory", out: "max\nusage_usec 1\n", wantErr: "invalid memory.current"},
+		{name: "invalid usage", out: "52183040\nusage_usec x\n", wantErr: "invalid usage_usec"},
+		{name: "no usage", out: "52183040\nuser_usec 1\n", wantErr: "no usage_usec"},
+		// cgroup v1 files are not readable on cgroup v2 hosts, and the other way around.
+		{name: "v1 stats", out: cgroupV1Stats, wantErr: "no usage_usec"},
+	})
+}
+
+func TestParseCgroupV1(t *testing.T) {
+	runCgroupCases(t, parseCgroupV1, []cgroupCase{
+		{name: "stats", out: cgroupV1Stats, mem: 52183040, cpu: 1834512345},
+		{name: "missing cpuacct.usage", out: "52183040\n", wantErr: "unexpected cgroup statistics"},
+		{name: "invalid memory", out: "x\n1\n", wantErr: "invalid memory.usage_in_bytes"},
+		{name: "invalid usage", out: "52183040\nx\n", wantErr: "invalid cpuacct.usage"},
+		{name: "v2 stats", out: cgroupV2Stats, wantErr: "unexpected cgroup statistics"},
+	})
+}
@@ -0,0 +1,222 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remotebootstrap tests the bootstrap of proxies in a remote cluster without istiod, which obtain their
+// config and certificates from an external istiod through the east-west gateway of its cluster. Faults can be
+// injected on the gateway path to verify that proxies retry until it is restored.
+package remotebootstrap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+	testKube "istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	eastWestGatewayName  = "istio-eastwestgateway"
+	eastWestGatewayLabel = "eastwestgateway"
+)
+
+// Fault on the path from remote proxies to istiod.
+type Fault int
+
+const (
+	// GatewayDown scales the east-west gateway to zero replicas.
+	GatewayDown Fault = iota
+	// DiscoveryDenied keeps the east-west gateway running, but denies connections to the istiod port through it.
+	DiscoveryDenied
+)
+
+func (f Fault) String() string {
+	switch f {
+	case GatewayDown:
+		return "gateway-down"
+	case DiscoveryDenied:
+		return "discovery-denied"
+	}
+	return fmt.Sprintf("fault(%d)", int(f))
+}
+
+// Config for the remote bootstrap checks.
+type Config struct {
+	// RemoteCluster whose proxies are bootstrapped by the external istiod. Required.
+	RemoteCluster resource.Cluster
+	// SystemNamespace in which istiod and the east-west gateway run. Defaults to "istio-system".
+	SystemNamespace string
+}
+
+func (c *Config) fillDefaults(ctx resource.Context) (resource.Cluster, error) {
+	if c.RemoteCluster == nil {
+		return nil, fmt.Errorf("remote cluster must be provided")
+	}
+	if c.SystemNamespace == "" {
+		c.SystemNamespace = "istio-system"
+	}
+	return ControlPlaneCluster(ctx, c.RemoteCluster)
+}
+
+// ControlPlaneCluster returns the cluster running the istiod of the remote cluster, whose east-west gateway the
+// remote proxies connect through.
+func ControlPlaneCluster(ctx resource.Context, remote resource.Cluster) (resource.Cluster, error) {
+	env, err := kube.EnvironmentFrom(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := env.GetControlPlaneCluster(remote)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Name() == remote.Name() {
+		return nil, fmt.Errorf("cluster %s runs its own control plane", remote.Name())
+	}
+	return cp, nil
+}
+
+const denyDiscoveryPolicy = `
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: remotebootstrap-deny-discovery
+spec:
+  selector:
+    matchLabels:
+      istio: %s
+  action: DENY
+  rules:
+  - to:
+    - operation:
+        ports: ["15012"]
+`
+
+// InjectFault injects the fault on the east-west gateway in front of the istiod of the remote cluster. The returned
+// function removes the fault.
+func InjectFault(ctx resource.Context, cfg Config, f Fault) (func() error, error) {
+	cp, err := cfg.fillDefaults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scopes.Framework.Infof("Injecting fault %s on the east-west gateway in %s", f, cp.Name())
+	switch f {
+	case GatewayDown:
+		scale, err := cp.AppsV1().Deployments(cfg.SystemNamespace).GetScale(context.TODO(), eastWestGatewayName,
+			kubeApiMeta.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		replicas := scale.Spec.Replicas
+		if err := testKube.ScaleDeployment(cp, cfg.SystemNamespace, eastWestGatewayName, 0); err != nil {
+			return nil, err
+		}
+		return func() error {
+			return testKube.ScaleDeployment(cp, cfg.SystemNamespace, eastWestGatewayName, replicas)
+		}, nil
+	case DiscoveryDenied:
+		policy := fmt.Sprintf(denyDiscoveryPolicy, eastWestGatewayLabel)
+		if err := ctx.Config(cp).ApplyYAML(cfg.SystemNamespace, policy); err != nil {
+			return nil, err
+		}
+		return func() error {
+			return ctx.Config(cp).DeleteYAML(cfg.SystemNamespace, policy)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown fault %v", f)
+}
+
+// InjectFaultOrFail calls InjectFault and fails the test if it returns an error. The returned function removes the
+// fault, failing the test if it cannot.
+func InjectFaultOrFail(t test.Failer, ctx resource.Context, cfg Config, f Fault) func() {
+	t.Helper()
+	restore, err := InjectFault(ctx, cfg, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if err := restore(); err != nil {
+			t.Fatalf("failed removing fault %s: %v", f, err)
+		}
+	}
+}
+
+// CheckBootstrap restarts the workloads of the instance, which must be in the remote cluster, and waits until they
+// are ready. Sidecars only become ready once they have received their certificates and config from istiod. The
+// workloads of the instance are then refreshed.
+func CheckBootstrap(i echo.Instance, opts ...retry.Option) error {
+	c := i.Config().Cluster
+	if err := testKube.RestartDeployments(c, i.Config().Namespace.Name(), "app="+i.Config().Service,
+		opts...); err != nil {
+		return err
+	}
+	return i.Refresh()
+}
+
+// CheckRecovery verifies that the workloads of the instance bootstrap once a fault on the gateway path is removed.
+// The workloads are restarted while the fault is injected, and must not become ready during the outage. The fault
+// is then removed, and the workloads must become ready by retrying, without being restarted again. The workloads of
+// the instance are then refreshed.
+func CheckRecovery(ctx resource.Context, cfg Config, f Fault, i echo.Instance, outage time.Duration,
+	opts ...retry.Option) error {
+	restore, err := InjectFault(ctx, cfg, f)
+	if err != nil {
+		return err
+	}
+	restored := false
+	defer func() {
+		if !restored {
+			_ = restore()
+		}
+	}()
+
+	c := i.Config().Cluster
+	ns := i.Config().Namespace.Name()
+	names, err := testKube.RolloutRestart(c, ns, "app="+i.Config().Service)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := testKube.WaitForDeploymentRollout(c, ns, name, retry.Timeout(outage)); err == nil {
+			return fmt.Errorf("deployment %s/%s became ready during fault %s, so the fault was not effective",
+				ns, name, f)
+		}
+	}
+
+	restored = true
+	if err := restore(); err != nil {
+		return fmt.Errorf("failed removing fault %s: %v", f, err)
+	}
+	for _, name := range names {
+		if err := testKube.WaitForDeploymentRollout(c, ns, name, opts...); err != nil {
+			return fmt.Errorf("workloads did not recover after fault %s was removed: %v", f, err)
+		}
+	}
+	return i.Refresh()
+}
+
+// CheckRecoveryOrFail calls CheckRecovery and fails the test if it returns an error.
+func CheckRecoveryOrFail(t test.Failer, ctx resource.Context, cfg Config, f Fault, i echo.Instance,
+	outage time.Duration, opts ...retry.Option) {
+	t.Helper()
+	if err := CheckRecovery(ctx, cfg, f, i, outage, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
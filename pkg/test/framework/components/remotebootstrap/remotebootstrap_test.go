@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotebootstrap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// refreshingInstance counts the refreshes of its workloads.
+type refreshingInstance struct {
+	echo.FakeInstance
+	refreshes int
+}
+
+func (i *refreshingInstance) Refresh() error {
+	i.refreshes++
+	return nil
+}
+
+func deployment(name, app string) *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Namespace: "echo", Labels: map[string]string{"app": app}},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: 1},
+	}
+}
+
+func TestCheckBootstrap(t *testing.T) {
+	cluster := resource.FakeCluster{
+		ExtendedClient: kube.NewFakeClient(deployment("a-v1", "a"), deployment("b-v1", "b")).(kube.ExtendedClient),
+		NameValue:      "remote",
+	}
+	i := &refreshingInstance{FakeInstance: echo.FakeInstance{ConfigValue: echo.Config{
+		Service:   "a",
+		Namespace: namespace.Fake{NameValue: "echo"},
+		Cluster:   cluster,
+	}}}
+	if err := CheckBootstrap(i, retry.Timeout(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if i.refreshes != 1 {
+		t.Fatalf("expected the workloads to be refreshed once restarted, got %d refreshes", i.refreshes)
+	}
+
+	restarted := func(name string) bool {
+		d, err := cluster.AppsV1().Deployments("echo").Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, ok := d.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"]
+		return ok
+	}
+	if !restarted("a-v1") || restarted("b-v1") {
+		t.Fatal("expected only the deployments of the instance to be restarted")
+	}
+}
+
+func TestInjectFaultRequiresRemoteCluster(t *testing.T) {
+	if _, err := InjectFault(nil, Config{}, GatewayDown); err == nil {
+		t.Fatal("expected an error without a remote cluster")
+	}
+}
+
+func TestFaultString(t *testing.T) {
+	for f, want := range map[Fault]string{GatewayDown: "gateway-down", DiscoveryDenied: "discovery-denied", Fault(5): "fault(5)"} {
+		if got := f.String(); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revisiontag points revision tags at control plane revisions, and walks namespaces through relabeling to a
+// tag one at a time, restarting their workloads and gating on their health between steps, so that upgrade suites can
+// stop the migration at any step and test the partial-migration states deliberately.
+//
+// This tree predates revision tags in istioctl, so a tag is created as istioctl does: the injection webhook of the
+// revision is cloned into istio-revision-tag-<tag>, matching namespaces labeled istio.io/rev=<tag>.
+package revisiontag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	kubeApiAdmission "k8s.io/api/admissionregistration/v1"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeRetry "k8s.io/client-go/util/retry"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	revisionLabel  = "istio.io/rev"
+	tagLabel       = "istio.io/tag"
+	injectionLabel = "istio-injection"
+	proxyContainer = "istio-proxy"
+)
+
+func webhookName(tag string) string {
+	return "istio-revision-tag-" + tag
+}
+
+func injectorName(revision string) string {
+	if revision == "" || revision == "default" {
+		return "istio-sidecar-injector"
+	}
+	return "istio-sidecar-injector-" + revision
+}
+
+// Set points the tag at the revision, creating it if needed.
+func Set(c resource.Cluster, tag, revision string) error {
+	webhooks := c.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	injector, err := webhooks.Get(context.TODO(), injectorName(revision), kubeApiMeta.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed getting the injection webhook of revision %q in cluster %s: %v", revision,
+			c.Name(), err)
+	}
+	wh := &kubeApiAdmission.MutatingWebhookConfiguration{
+		ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name: webhookName(tag),
+			Labels: map[string]string{
+				revisionLabel: revisionOrDefault(revision),
+				tagLabel:      tag,
+			},
+		},
+	}
+	for _, w := range injector.Webhooks {
+		w.NamespaceSelector = &kubeApiMeta.LabelSelector{
+			MatchExpressions: []kubeApiMeta.LabelSelectorRequirement{
+				{Key: injectionLabel, Operator: kubeApiMeta.LabelSelectorOpDoesNotExist},
+				{Key: revisionLabel, Operator: kubeApiMeta.LabelSelectorOpIn, Values: []string{tag}},
+			},
+		}
+		w.ObjectSelector = nil
+		wh.Webhooks = append(wh.Webhooks, w)
+	}
+	return kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		existing, err := webhooks.Get(context.TODO(), wh.Name, kubeApiMeta.GetOptions{})
+		if kubeErrors.IsNotFound(err) {
+			_, err = webhooks.Create(context.TODO(), wh, kubeApiMeta.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		wh.ResourceVersion = existing.ResourceVersion
+		_, err = webhooks.Update(context.TODO(), wh, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+}
+
+// SetOrFail calls Set and fails the test if it returns an error.
+func SetOrFail(t test.Failer, c resource.Cluster, tag, revision string) {
+	t.Helper()
+	if err := Set(c, tag, revision); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Remove the tag. Pods injected through it keep their revision.
+func Remove(c resource.Cluster, tag string) error {
+	err := c.AdmissionregistrationV1().MutatingWebhookConfigurations().Delete(context.TODO(), webhookName(tag),
+		kubeApiMeta.DeleteOptions{})
+	if kubeErrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func revisionOrDefault(revision string) string {
+	if revision == "" {
+		return "default"
+	}
+	return revision
+}
+
+// podRevision returns the revision of the control plane the proxy of the pod was injected for, read from the address
+// of its CA, or an error if the pod was not injected.
+func podRevision(pod kubeApiCore.Pod) (string, error) {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != proxyContainer {
+			continue
+		}
+		for _, e := range c.Env {
+			if e.Name != "CA_ADDR" {
+				continue
+			}
+			host := strings.SplitN(e.Value, ".", 2)[0]
+			if host == "istiod" {
+				return "default", nil
+			}
+			return strings.TrimPrefix(host, "istiod-"), nil
+		}
+		return "", fmt.Errorf("no CA_ADDR set for the proxy of pod %s/%s", pod.Namespace, pod.Name)
+	}
+	return "", fmt.Errorf("pod %s/%s was not injected", pod.Namespace, pod.Name)
+}
+
+// Gate checks the health of a namespace after it was migrated, before the next one is.
+type Gate func(c resource.Cluster, ns string) error
+
+// Migration of namespaces to a tag.
+type Migration struct {
+	Cluster resource.Cluster
+	// Namespaces migrated, in order.
+	Namespaces []string
+	Tag        string
+	// Revision the tag points at, that the proxies of migrated namespaces are checked to run. Defaults to "default".
+	Revision string
+	// Gates run after the default one, which checks that the pods of the namespace are ready and injected for
+	// Revision.
+	Gates []Gate
+	// Timeout of the restart and of the gates of each namespace. Defaults to 5m.
+	Timeout time.Duration
+}
+
+// Step migrates the next namespace to the tag: its injection labels are replaced by the tag, its deployments are
+// restarted, and the gates are checked. It returns the migrated namespace, or false once all were migrated.
+func (m *Migration) Step() (string, bool, error) {
+	migrated, err := m.Migrated()
+	if err != nil {
+		return "", false, err
+	}
+	if len(migrated) == len(m.Namespaces) {
+		return "", false, nil
+	}
+	ns := m.Namespaces[len(migrated)]
+	if err := m.migrate(ns); err != nil {
+		return ns, true, fmt.Errorf("failed migrating namespace %s to tag %s: %v", ns, m.Tag, err)
+	}
+	return ns, true, nil
+}
+
+// StepOrFail calls Step and fails the test if it returns an error.
+func (m *Migration) StepOrFail(t test.Failer) (string, bool) {
+	t.Helper()
+	ns, ok, err := m.Step()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ns, ok
+}
+
+// Run the remaining steps of the migration, or up to n of them if n is positive, leaving the remaining namespaces on
+// their current revision.
+func (m *Migration) Run(n int) error {
+	for i := 0; n <= 0 || i < n; i++ {
+		_, ok, err := m.Step()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// RunOrFail calls Run and fails the test if it returns an error.
+func (m *Migration) RunOrFail(t test.Failer, n int) {
+	t.Helper()
+	if err := m.Run(n); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Migrated returns the leading namespaces already labeled with the tag.
+func (m *Migration) Migrated() ([]string, error) {
+	var out []string
+	for _, ns := range m.Namespaces {
+		n, err := m.Cluster.CoreV1().Namespaces().Get(context.TODO(), ns, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if n.Labels[revisionLabel] != m.Tag {
+			break
+		}
+		out = append(out, ns)
+	}
+	return out, nil
+}
+
+func (m *Migration) timeout() time.Duration {
+	if m.Timeout == 0 {
+		return 5 * time.Minute
+	}
+	return m.Timeout
+}
+
+func (m *Migration) migrate(ns string) error {
+	err := kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		n, err := m.Cluster.CoreV1().Namespaces().Get(context.TODO(), ns, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if n.Labels == nil {
+			n.Labels = map[string]string{}
+		}
+		delete(n.Labels, injectionLabel)
+		n.Labels[revisionLabel] = m.Tag
+		_, err = m.Cluster.CoreV1().Namespaces().Update(context.TODO(), n, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := kube.RestartDeployments(m.Cluster, ns, "", retry.Timeout(m.timeout())); err != nil {
+		return err
+	}
+	gates := append([]Gate{m.injected}, m.Gates...)
+	for _, g := range gates {
+		if err := retry.UntilSuccess(func() error {
+			return g(m.Cluster, ns)
+		}, retry.Timeout(m.timeout()), retry.Delay(time.Second)); err != nil {
+			return err
+		}
+	}
+	scopes.Framework.Infof("migrated namespace %s to tag %s", ns, m.Tag)
+	return nil
+}
+
+// injected checks that the pods of the namespace are ready and injected for the revision of the tag.
+func (m *Migration) injected(c resource.Cluster, ns string) error {
+	pods, err := kube.CheckPodsAreReady(kube.NewPodFetch(c, ns))
+	if err != nil {
+		return err
+	}
+	want := revisionOrDefault(m.Revision)
+	for _, p := range pods {
+		if p.DeletionTimestamp != nil {
+			continue
+		}
+		got, err := podRevision(p)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("pod %s/%s runs revision %s, expected %s", ns, p.Name, got, want)
+		}
+	}
+	return nil
+}
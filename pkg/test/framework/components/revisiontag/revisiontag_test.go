@@ -0,0 +1,70 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revisiontag
+
+import (
+	"testing"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func injectedPod(containers ...kubeApiCore.Container) kubeApiCore.Pod {
+	return kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: kubeApiCore.PodSpec{
+			Containers: append([]kubeApiCore.Container{{Name: "app"}}, containers...),
+		},
+	}
+}
+
+func proxy(caAddr string) kubeApiCore.Container {
+	return kubeApiCore.Container{
+		Name: proxyContainer,
+		Env:  []kubeApiCore.EnvVar{{Name: "PROXY_CONFIG", Value: "{}"}, {Name: "CA_ADDR", Value: caAddr}},
+	}
+}
+
+func TestPodRevision(t *testing.T) {
+	cases := []struct {
+		name    string
+		pod     kubeApiCore.Pod
+		want    string
+		wantErr bool
+	}{
+		{name: "default", pod: injectedPod(proxy("istiod.istio-system.svc:15012")), want: "default"},
+		{name: "revision", pod: injectedPod(proxy("istiod-canary.istio-system.svc:15012")), want: "canary"},
+		{name: "dashed revision", pod: injectedPod(proxy("istiod-1-8-0.istio-system.svc:15012")), want: "1-8-0"},
+		{name: "no CA address", pod: injectedPod(kubeApiCore.Container{Name: proxyContainer}), wantErr: true},
+		{name: "not injected", pod: injectedPod(), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := podRevision(tc.pod)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got revision %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("got revision %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
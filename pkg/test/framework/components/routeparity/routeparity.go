@@ -0,0 +1,195 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package routeparity checks that a VirtualService and the equivalent Gateway API HTTPRoute result in the same
+// routing behavior through the ingress gateway.
+package routeparity
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/istio/ingress"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Probe is a request made through the ingress gateway to observe routing.
+type Probe struct {
+	// Name of the probe, used when reporting differences.
+	Name string
+	// Host header of the request.
+	Host string
+	// Path of the request.
+	Path string
+	// Headers of the request.
+	Headers http.Header
+	// Count of requests to make. Defaults to 10, so that weighted destinations are likely to all be observed.
+	Count int
+}
+
+// Observation is the routing behavior observed for a probe.
+type Observation struct {
+	// Codes are the distinct response codes.
+	Codes []string
+	// Backends are the distinct deployments, in the form <service>-<version>, that served the requests.
+	Backends []string
+}
+
+// Observations are keyed by probe name.
+type Observations map[string]Observation
+
+// Config for Check.
+type Config struct {
+	// Ingress to send the probes through. Required.
+	Ingress ingress.Instance
+	// Namespace to apply the config in. Required.
+	Namespace string
+
+	// VirtualService config, including the Istio Gateway it binds to.
+	VirtualService string
+	// HTTPRoute config, including the GatewayClass and Gateway it binds to.
+	HTTPRoute string
+
+	// Probes to observe the routing behavior with.
+	Probes []Probe
+}
+
+// Check applies the VirtualService config and observes the routing behavior once it is stable. The config is then
+// replaced with the HTTPRoute config, and the routing behavior must converge to the same observations. Each config is
+// only observed once the ingress gateway proxies have acknowledged the config pushed to them, so that the routing of
+// one config is never compared with that of a partially applied other; Check fails if they do not.
+func Check(ctx resource.Context, cfg Config, opts ...retry.Option) error {
+	if cfg.Ingress == nil || cfg.Namespace == "" {
+		return fmt.Errorf("ingress and namespace must be provided")
+	}
+	opts = append([]retry.Option{retry.Timeout(2 * time.Minute), retry.Delay(time.Second)}, opts...)
+
+	if err := ctx.Config().ApplyYAML(cfg.Namespace, cfg.VirtualService); err != nil {
+		return fmt.Errorf("failed applying VirtualService config: %v", err)
+	}
+	expected, err := observeSynced(cfg, opts...)
+	_ = ctx.Config().DeleteYAML(cfg.Namespace, cfg.VirtualService)
+	if err != nil {
+		return fmt.Errorf("failed observing VirtualService routing: %v", err)
+	}
+
+	if err := ctx.Config().ApplyYAML(cfg.Namespace, cfg.HTTPRoute); err != nil {
+		return fmt.Errorf("failed applying HTTPRoute config: %v", err)
+	}
+	defer func() { _ = ctx.Config().DeleteYAML(cfg.Namespace, cfg.HTTPRoute) }()
+	return retry.UntilSuccess(func() error {
+		if err := cfg.Ingress.WaitForSync(); err != nil {
+			return fmt.Errorf("ingress gateway not synced with the HTTPRoute config: %v", err)
+		}
+		actual, err := Observe(cfg.Ingress, cfg.Probes)
+		if err != nil {
+			return err
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			return fmt.Errorf("HTTPRoute routing differs from VirtualService (-VirtualService +HTTPRoute):\n%s", diff)
+		}
+		return nil
+	}, opts...)
+}
+
+// CheckOrFail calls Check and fails the test if it returns an error.
+func CheckOrFail(t test.Failer, ctx resource.Context, cfg Config, opts ...retry.Option) {
+	t.Helper()
+	if err := Check(ctx, cfg, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// observeSynced observes the probes once the ingress gateway proxies are synced, until the same observations are made
+// several times in a row, so that the config is known to have propagated.
+func observeSynced(cfg Config, opts ...retry.Option) (Observations, error) {
+	var last Observations
+	opts = append(opts, retry.Converge(3))
+	err := retry.UntilSuccess(func() error {
+		if err := cfg.Ingress.WaitForSync(); err != nil {
+			last = nil
+			return fmt.Errorf("ingress gateway not synced with the VirtualService config: %v", err)
+		}
+		obs, err := Observe(cfg.Ingress, cfg.Probes)
+		if err != nil {
+			last = nil
+			return err
+		}
+		previous := last
+		last = obs
+		if !cmp.Equal(previous, obs) {
+			return fmt.Errorf("observations have not stabilized")
+		}
+		return nil
+	}, opts...)
+	return last, err
+}
+
+// Observe makes each of the probes through the ingress gateway and returns the observed routing behavior.
+func Observe(ing ingress.Instance, probes []Probe) (Observations, error) {
+	out := Observations{}
+	for _, p := range probes {
+		count := p.Count
+		if count <= 0 {
+			count = 10
+		}
+		resp, err := ing.CallEcho(echo.CallOptions{
+			Port:    &echo.Port{Protocol: protocol.HTTP},
+			Host:    p.Host,
+			Path:    p.Path,
+			Headers: p.Headers.Clone(),
+			Count:   count,
+		})
+		codes, backends := map[string]struct{}{}, map[string]struct{}{}
+		if err != nil {
+			// Responses with any status code are parsed, so errors indicate a connection failure.
+			codes["error"] = struct{}{}
+		}
+		for _, r := range resp {
+			codes[r.Code] = struct{}{}
+			if r.Hostname != "" {
+				backends[deploymentName(r.Hostname)] = struct{}{}
+			}
+		}
+		out[p.Name] = Observation{Codes: sortedKeys(codes), Backends: sortedKeys(backends)}
+	}
+	return out, nil
+}
+
+// deploymentName strips the ReplicaSet hash and pod suffix from the pod name.
+func deploymentName(pod string) string {
+	parts := strings.Split(pod, "-")
+	if len(parts) <= 2 {
+		return pod
+	}
+	return strings.Join(parts[:len(parts)-2], "-")
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,125 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routeparity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/istio/ingress"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// fakeIngress responds to the calls for each host with the responses of the host, after failing to sync the given
+// number of times.
+type fakeIngress struct {
+	ingress.Instance
+	responses    map[string]client.ParsedResponses
+	syncFailures int
+	calls        []echo.CallOptions
+}
+
+func (i *fakeIngress) CallEcho(opts echo.CallOptions) (client.ParsedResponses, error) {
+	i.calls = append(i.calls, opts)
+	resp, ok := i.responses[opts.Host]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	return resp, nil
+}
+
+func (i *fakeIngress) WaitForSync(...retry.Option) error {
+	if i.syncFailures > 0 {
+		i.syncFailures--
+		return errors.New("not synced")
+	}
+	return nil
+}
+
+func TestObserve(t *testing.T) {
+	ing := &fakeIngress{responses: map[string]client.ParsedResponses{
+		"a.example.com": {
+			{Code: "200", Hostname: "b-v1-7d4f8c-x2k9p"},
+			{Code: "200", Hostname: "b-v2-5b6c7d-k8m2n"},
+			{Code: "200", Hostname: "b-v1-7d4f8c-q4w8e"},
+		},
+		"missing.example.com": {{Code: "404"}},
+	}}
+	got, err := Observe(ing, []Probe{
+		{Name: "split", Host: "a.example.com", Count: 3},
+		{Name: "missing", Host: "missing.example.com"},
+		{Name: "refused", Host: "refused.example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Observations{
+		"split":   {Codes: []string{"200"}, Backends: []string{"b-v1", "b-v2"}},
+		"missing": {Codes: []string{"404"}, Backends: []string{}},
+		"refused": {Codes: []string{"error"}, Backends: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if ing.calls[0].Count != 3 || ing.calls[1].Count != 10 {
+		t.Fatalf("expected the count of the probe, defaulting to 10, got %d and %d", ing.calls[0].Count, ing.calls[1].Count)
+	}
+}
+
+func TestObserveSynced(t *testing.T) {
+	ing := &fakeIngress{
+		responses:    map[string]client.ParsedResponses{"a.example.com": {{Code: "200", Hostname: "b-v1-7d4f8c-x2k9p"}}},
+		syncFailures: 2,
+	}
+	cfg := Config{Ingress: ing, Probes: []Probe{{Name: "a", Host: "a.example.com", Count: 1}}}
+	got, err := observeSynced(cfg, retry.Timeout(time.Second), retry.Delay(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Observations{"a": {Codes: []string{"200"}, Backends: []string{"b-v1"}}}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	// The first observation only serves as the baseline of the next ones, which must converge.
+	if len(ing.calls) != 4 {
+		t.Fatalf("expected the probes to be made once synced until the observations converge, got %d calls", len(ing.calls))
+	}
+}
+
+func TestDeploymentName(t *testing.T) {
+	cases := map[string]string{
+		"b-v1-7d4f8c-x2k9p": "b-v1",
+		"b-7d4f8c-x2k9p":    "b",
+		"b-x2k9p":           "b-x2k9p",
+		"b":                 "b",
+	}
+	for pod, want := range cases {
+		if got := deploymentName(pod); got != want {
+			t.Errorf("deploymentName(%q) = %q, want %q", pod, got, want)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	if err := Check(nil, Config{Namespace: "ns"}); err == nil {
+		t.Fatal("expected an error without an ingress")
+	}
+	if err := Check(nil, Config{Ingress: &fakeIngress{}}); err == nil {
+		t.Fatal("expected an error without a namespace")
+	}
+}
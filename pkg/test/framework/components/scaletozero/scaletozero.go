@@ -0,0 +1,380 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scaletozero scales echo workloads to and from zero replicas, as serverless platforms do, and asserts on the
+// behavior of requests made through the mesh while the workload cold starts.
+//
+// Deployments are scaled directly rather than by a HorizontalPodAutoscaler, which cannot scale to zero without the
+// HPAScaleToZero feature gate; hpa covers scaling between non-zero replicas. The client proxies retry requests that
+// reach a workload that is not ready to serve them, as configured by a RetryPolicy. They cannot retry or queue
+// requests while the target has no endpoints at all, which they answer with no healthy upstream, so the caller
+// re-issues those, standing in for the activator or queue of a serverless platform.
+package scaletozero
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common/response"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// noHealthyUpstream is the body of the response of the client proxy while the target has no endpoints.
+	noHealthyUpstream = "no healthy upstream"
+
+	// attemptCountHeader is the header in which the client proxy passes the number of the attempt of a request.
+	attemptCountHeader = "X-Envoy-Attempt-Count"
+)
+
+// deployments returns the names of the deployments of the instance, one per subset.
+func deployments(i echo.Instance) []string {
+	var out []string
+	for _, s := range i.Config().Subsets {
+		out = append(out, fmt.Sprintf("%s-%s", i.Config().Service, s.Version))
+	}
+	return out
+}
+
+// Scale the deployments of the instance to the number of replicas, waiting until they are ready. Unless scaled to
+// zero, the workloads of the instance are then refreshed, as its pods were replaced.
+func Scale(i echo.Instance, replicas int32, opts ...retry.Option) error {
+	for _, d := range deployments(i) {
+		if err := kube.ScaleDeployment(i.Config().Cluster, i.Config().Namespace.Name(), d, replicas, opts...); err != nil {
+			return fmt.Errorf("failed scaling %s to %d replicas: %v", d, replicas, err)
+		}
+	}
+	if replicas == 0 {
+		return nil
+	}
+	return i.Refresh()
+}
+
+// ScaleOrFail calls Scale and fails the test if it returns an error.
+func ScaleOrFail(t test.Failer, i echo.Instance, replicas int32, opts ...retry.Option) {
+	t.Helper()
+	if err := Scale(i, replicas, opts...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ScaleToZero scales the deployments of the instance to zero replicas, and waits until the service has no endpoints
+// left, so that the proxies of callers no longer have a healthy upstream. The instance cannot make calls until it is
+// scaled up again.
+func ScaleToZero(i echo.Instance, opts ...retry.Option) error {
+	if err := Scale(i, 0, opts...); err != nil {
+		return err
+	}
+	c := i.Config().Cluster
+	ns := i.Config().Namespace.Name()
+	return retry.UntilSuccess(func() error {
+		ep, err := c.CoreV1().Endpoints(ns).Get(context.TODO(), i.Config().Service, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, s := range ep.Subsets {
+			if len(s.Addresses) > 0 || len(s.NotReadyAddresses) > 0 {
+				return fmt.Errorf("service %s/%s still has endpoints", ns, i.Config().Service)
+			}
+		}
+		return nil
+	}, opts...)
+}
+
+// ScaleToZeroOrFail calls ScaleToZero and fails the test if it returns an error. The instance is scaled back to one
+// replica when the test completes.
+func ScaleToZeroOrFail(t test.Failer, i echo.Instance, opts ...retry.Option) {
+	t.Helper()
+	if err := ScaleToZero(i, opts...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// Failing the test from its cleanup hides the failures of the test itself, so the error is only logged.
+		if err := Scale(i, 1, opts...); err != nil {
+			scopes.Framework.Errorf("failed scaling %s back up: %v", i.Config().Service, err)
+		}
+	})
+}
+
+// RetryPolicy of the client proxies for the requests to the target of a CallDuringColdStart.
+type RetryPolicy struct {
+	// Attempts is the number of times a request is retried. Defaults to 5.
+	Attempts int
+	// PerTryTimeout of each attempt. Defaults to 2s.
+	PerTryTimeout time.Duration
+	// RetryOn are the conditions under which a request is retried, as in a VirtualService. Defaults to
+	// "connect-failure,refused-stream,reset,503".
+	RetryOn string
+}
+
+func (p *RetryPolicy) fillDefaults() {
+	if p.Attempts == 0 {
+		p.Attempts = 5
+	}
+	if p.PerTryTimeout == 0 {
+		p.PerTryTimeout = 2 * time.Second
+	}
+	if p.RetryOn == "" {
+		p.RetryOn = "connect-failure,refused-stream,reset,503"
+	}
+}
+
+// virtualService configures the retry policy for the requests to the instance.
+func (p RetryPolicy) virtualService(i echo.Instance) string {
+	return fmt.Sprintf(`apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: %s-cold-start
+spec:
+  hosts:
+  - %s
+  http:
+  - route:
+    - destination:
+        host: %s
+    retries:
+      attempts: %d
+      perTryTimeout: %s
+      retryOn: %s
+`, i.Config().Service, i.Config().FQDN(), i.Config().FQDN(), p.Attempts, p.PerTryTimeout, p.RetryOn)
+}
+
+// ColdStartOptions configures a CallDuringColdStart.
+type ColdStartOptions struct {
+	// Call made to the scaled to zero target. Target is overridden, and Count is ignored.
+	Call echo.CallOptions
+	// Requests is the number of concurrent requests queued by the caller while the target cold starts. Defaults to 1.
+	Requests int
+	// Delay between the first request and the scale up of the target, emulating the reaction time of an autoscaler.
+	Delay time.Duration
+	// Replicas the target is scaled up to. Defaults to 1.
+	Replicas int32
+	// Retry is the retry policy of the client proxies for the requests to the target.
+	Retry RetryPolicy
+	// RetryInterval between the requests re-issued by the caller while the target has no endpoints. Defaults to 200ms.
+	RetryInterval time.Duration
+	// Timeout after which requests that did not succeed are abandoned. Defaults to 2m.
+	Timeout time.Duration
+}
+
+func (o *ColdStartOptions) fillDefaults() {
+	if o.Requests == 0 {
+		o.Requests = 1
+	}
+	if o.Replicas == 0 {
+		o.Replicas = 1
+	}
+	o.Retry.fillDefaults()
+	if o.RetryInterval == 0 {
+		o.RetryInterval = 200 * time.Millisecond
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 2 * time.Minute
+	}
+}
+
+// ColdStartRequest is the outcome of a request queued while the target cold started.
+type ColdStartRequest struct {
+	// Latency from the first attempt to the success of the request.
+	Latency time.Duration
+	// Queued is the number of times the request was answered with no healthy upstream by the client proxy, and
+	// re-issued by the caller, while the target had no endpoints.
+	Queued int
+	// MeshAttempts is the number of attempts the client proxy made for the request once the target had endpoints,
+	// as reported to the target. More than one means the proxy retried the request.
+	MeshAttempts int
+	// Err is set if the request did not succeed, either failing once the target had endpoints or not reaching it
+	// before the timeout.
+	Err error
+}
+
+// ColdStartResults of each request made by CallDuringColdStart.
+type ColdStartResults []ColdStartRequest
+
+// CallDuringColdStart scales the target to zero, queues requests to it from the source, and scales it back up while
+// the requests are pending, reporting how long each request took to succeed and how it was retried. The retry policy
+// of the options is configured for the requests to the target until the call completes.
+func CallDuringColdStart(ctx resource.Context, from, to echo.Instance, opts ColdStartOptions) (ColdStartResults, error) {
+	opts.fillDefaults()
+	ns := to.Config().Namespace.Name()
+	vs := opts.Retry.virtualService(to)
+	if err := ctx.Config().ApplyYAML(ns, vs); err != nil {
+		return nil, fmt.Errorf("failed configuring the retry policy of %s: %v", to.Config().Service, err)
+	}
+	defer func() {
+		if err := ctx.Config().DeleteYAML(ns, vs); err != nil {
+			scopes.Framework.Warnf("failed removing the retry policy of %s: %v", to.Config().Service, err)
+		}
+	}()
+	if err := ScaleToZero(to); err != nil {
+		return nil, err
+	}
+	call := opts.Call
+	call.Target = to
+	call.Count = 1
+
+	results := make(ColdStartResults, opts.Requests)
+	wg := sync.WaitGroup{}
+	for n := range results {
+		n := n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[n] = request(from, call, opts)
+		}()
+	}
+	time.Sleep(opts.Delay)
+	scaleErr := Scale(to, opts.Replicas, retry.Timeout(opts.Timeout))
+	wg.Wait()
+	if scaleErr != nil {
+		return results, scaleErr
+	}
+	return results, nil
+}
+
+// request sends the call until the target has endpoints, after which its outcome is final: the client proxy is
+// expected to have retried it as configured.
+func request(from echo.Instance, call echo.CallOptions, opts ColdStartOptions) ColdStartRequest {
+	r := ColdStartRequest{}
+	start := time.Now()
+	deadline := start.Add(opts.Timeout)
+	for {
+		resp, err := from.Call(call)
+		if len(resp) > 0 && resp[0].Code == "503" && strings.Contains(resp[0].Body, noHealthyUpstream) {
+			r.Queued++
+			if time.Now().After(deadline) {
+				r.Err = fmt.Errorf("target had no endpoints within %v, after %d attempts", opts.Timeout, r.Queued)
+				return r
+			}
+			time.Sleep(opts.RetryInterval)
+			continue
+		}
+		if len(resp) > 0 {
+			r.MeshAttempts = meshAttempts(resp[0])
+		}
+		switch {
+		case err != nil:
+			r.Err = fmt.Errorf("failed after %d attempts of the mesh: %v", r.MeshAttempts, err)
+		case resp[0].Code != response.StatusCodeOK:
+			r.Err = fmt.Errorf("failed with %s after %d attempts of the mesh", resp[0].Code, r.MeshAttempts)
+		default:
+			r.Latency = time.Since(start)
+		}
+		return r
+	}
+}
+
+// meshAttempts returns the number of the attempt of the client proxy that the target responded to, or 0 if the
+// target did not receive one.
+func meshAttempts(r *client.ParsedResponse) int {
+	n, err := strconv.Atoi(r.RawResponse[attemptCountHeader])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// CallDuringColdStartOrFail calls CallDuringColdStart and fails the test if it returns an error.
+func CallDuringColdStartOrFail(t test.Failer, ctx resource.Context, from, to echo.Instance,
+	opts ColdStartOptions) ColdStartResults {
+	t.Helper()
+	r, err := CallDuringColdStart(ctx, from, to, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// MaxLatency returns the latency of the slowest request.
+func (r ColdStartResults) MaxLatency() time.Duration {
+	max := time.Duration(0)
+	for _, req := range r {
+		if req.Latency > max {
+			max = req.Latency
+		}
+	}
+	return max
+}
+
+// CheckSucceeded checks that every queued request eventually succeeded.
+func (r ColdStartResults) CheckSucceeded() error {
+	var errs error
+	for n, req := range r {
+		if req.Err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("request %d: %v", n, req.Err))
+		}
+	}
+	return errs
+}
+
+// CheckRetryPolicy checks that every request reaching the target was sent by the client proxy within the attempts of
+// the retry policy, so that failures of the starting workload were retried by the mesh rather than by the caller.
+func (r ColdStartResults) CheckRetryPolicy(p RetryPolicy) error {
+	p.fillDefaults()
+	var errs error
+	for n, req := range r {
+		if req.MeshAttempts == 0 && req.Err == nil {
+			errs = multierror.Append(errs, fmt.Errorf("request %d: the target received no attempt count from the "+
+				"client proxy", n))
+		} else if req.MeshAttempts > p.Attempts+1 {
+			errs = multierror.Append(errs, fmt.Errorf("request %d: %d attempts of the mesh, more than the %d "+
+				"retries of the policy", n, req.MeshAttempts, p.Attempts))
+		}
+	}
+	return errs
+}
+
+// MeshRetries returns the number of requests the client proxies retried.
+func (r ColdStartResults) MeshRetries() int {
+	n := 0
+	for _, req := range r {
+		if req.MeshAttempts > 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// CheckMaxLatency checks that every request succeeded within the latency of the cold start.
+func (r ColdStartResults) CheckMaxLatency(max time.Duration) error {
+	if err := r.CheckSucceeded(); err != nil {
+		return err
+	}
+	if got := r.MaxLatency(); got > max {
+		return fmt.Errorf("expected requests to succeed within %v of a cold start, slowest took %v", max, got)
+	}
+	return nil
+}
+
+func (r ColdStartResults) String() string {
+	queued := 0
+	for _, req := range r {
+		queued += req.Queued
+	}
+	return fmt.Sprintf("%d requests, slowest %v, %d attempts without endpoints, %d retried by the mesh", len(r),
+		r.MaxLatency(), queued, r.MeshRetries())
+}
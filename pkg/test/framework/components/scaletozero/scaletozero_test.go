@@ -0,0 +1,186 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaletozero
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+)
+
+// fakeInstance responds to each call with the next of its responses.
+type fakeInstance struct {
+	echo.FakeInstance
+	responses []*client.ParsedResponse
+	calls     int
+}
+
+func newInstance(responses ...*client.ParsedResponse) *fakeInstance {
+	return &fakeInstance{
+		FakeInstance: echo.FakeInstance{ConfigValue: echo.Config{Service: "b", Namespace: namespace.Fake{NameValue: "echo"}}},
+		responses:    responses,
+	}
+}
+
+func (i *fakeInstance) Call(echo.CallOptions) (client.ParsedResponses, error) {
+	r := i.responses[i.calls]
+	i.calls++
+	if r == nil {
+		return nil, errors.New("call failed")
+	}
+	return client.ParsedResponses{r}, nil
+}
+
+var noEndpoints = &client.ParsedResponse{Code: "503", Body: "no healthy upstream"}
+
+func reached(code string, attempt string) *client.ParsedResponse {
+	return &client.ParsedResponse{Code: code, RawResponse: map[string]string{attemptCountHeader: attempt}}
+}
+
+func TestRequest(t *testing.T) {
+	opts := ColdStartOptions{RetryInterval: time.Millisecond, Timeout: time.Minute}
+	opts.fillDefaults()
+	cases := []struct {
+		name      string
+		responses []*client.ParsedResponse
+		want      ColdStartRequest
+		wantErr   string
+	}{
+		{
+			name:      "ready",
+			responses: []*client.ParsedResponse{reached("200", "1")},
+			want:      ColdStartRequest{MeshAttempts: 1},
+		},
+		{
+			name:      "queued then retried by the mesh",
+			responses: []*client.ParsedResponse{noEndpoints, noEndpoints, reached("200", "3")},
+			want:      ColdStartRequest{Queued: 2, MeshAttempts: 3},
+		},
+		{
+			name:      "failed once reached",
+			responses: []*client.ParsedResponse{noEndpoints, reached("503", "6")},
+			want:      ColdStartRequest{Queued: 1, MeshAttempts: 6},
+			wantErr:   "failed with 503 after 6 attempts of the mesh",
+		},
+		{
+			name:      "call failed",
+			responses: []*client.ParsedResponse{nil},
+			wantErr:   "call failed",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			from := newInstance(tc.responses...)
+			got := request(from, echo.CallOptions{}, opts)
+			if from.calls != len(tc.responses) {
+				t.Fatalf("made %d calls, want %d", from.calls, len(tc.responses))
+			}
+			if got.Queued != tc.want.Queued || got.MeshAttempts != tc.want.MeshAttempts {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			if tc.wantErr == "" {
+				if got.Err != nil || got.Latency <= 0 {
+					t.Fatalf("expected the request to succeed, got %+v", got)
+				}
+			} else if got.Err == nil || !strings.Contains(got.Err.Error(), tc.wantErr) {
+				t.Fatalf("got error %v, want %q", got.Err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	opts := ColdStartOptions{RetryInterval: time.Millisecond, Timeout: time.Nanosecond}
+	opts.fillDefaults()
+	from := newInstance(noEndpoints)
+	got := request(from, echo.CallOptions{}, opts)
+	if got.Err == nil || got.Queued != 1 {
+		t.Fatalf("expected the request to time out after one attempt, got %+v", got)
+	}
+}
+
+func TestVirtualService(t *testing.T) {
+	p := RetryPolicy{Attempts: 3}
+	p.fillDefaults()
+	vs := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(p.virtualService(newInstance())), &vs); err != nil {
+		t.Fatal(err)
+	}
+	http := vs["spec"].(map[string]interface{})["http"].([]interface{})
+	retries := http[0].(map[string]interface{})["retries"].(map[string]interface{})
+	if retries["attempts"] != float64(3) || retries["perTryTimeout"] != "2s" ||
+		retries["retryOn"] != "connect-failure,refused-stream,reset,503" {
+		t.Fatalf("unexpected retries: %v", retries)
+	}
+	if hosts := vs["spec"].(map[string]interface{})["hosts"].([]interface{}); hosts[0] != "b.echo.svc" {
+		t.Fatalf("unexpected hosts: %v", hosts)
+	}
+}
+
+func TestCheckRetryPolicy(t *testing.T) {
+	p := RetryPolicy{Attempts: 2}
+	cases := []struct {
+		name    string
+		results ColdStartResults
+		wantErr bool
+	}{
+		{"within the policy", ColdStartResults{{MeshAttempts: 1}, {MeshAttempts: 3}}, false},
+		{"more attempts than the policy", ColdStartResults{{MeshAttempts: 4}}, true},
+		{"not sent by a proxy", ColdStartResults{{MeshAttempts: 0}}, true},
+		{"failed before reaching the target", ColdStartResults{{Err: errors.New("timeout")}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.results.CheckRetryPolicy(p); (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestResults(t *testing.T) {
+	r := ColdStartResults{
+		{Latency: time.Second, Queued: 2, MeshAttempts: 1},
+		{Latency: 3 * time.Second, Queued: 1, MeshAttempts: 2},
+	}
+	if r.MaxLatency() != 3*time.Second {
+		t.Fatalf("got max latency %v", r.MaxLatency())
+	}
+	if r.MeshRetries() != 1 {
+		t.Fatalf("got %d requests retried by the mesh, want 1", r.MeshRetries())
+	}
+	if err := r.CheckMaxLatency(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CheckMaxLatency(2 * time.Second); err == nil {
+		t.Fatal("expected the slowest request to exceed the latency")
+	}
+	want := "2 requests, slowest 3s, 3 attempts without endpoints, 1 retried by the mesh"
+	if r.String() != want {
+		t.Fatalf("got %q, want %q", r.String(), want)
+	}
+
+	r = append(r, ColdStartRequest{Err: errors.New("timeout")})
+	if err := r.CheckSucceeded(); err == nil {
+		t.Fatal("expected the failed request to be reported")
+	}
+}
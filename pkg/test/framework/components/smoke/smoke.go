@@ -0,0 +1,315 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package smoke assembles a happy-path smoke suite from framework primitives, checking that a mesh is installed, that
+// workloads reach each other over mTLS, that traffic enters through the ingress gateway, and that it is reported in
+// Prometheus. It is meant for release qualification and for validating user environments, so it runs against an
+// existing mesh as well as one it installs:
+//
+//	go test -tags=integ ./tests/integration/smoke/... --istio.test.kube.deploy=false \
+//	    --istio.test.kube.config=$KUBECONFIG --istio.test.smoke.existingPrometheus
+//
+// Checks that do not apply to an environment, such as ingress without a gateway, are skipped with
+// --istio.test.smoke.skip.
+package smoke
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/check"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/prometheus"
+	"istio.io/istio/pkg/test/framework/resource"
+	kube2 "istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Check is a check of the smoke suite.
+type Check string
+
+const (
+	Install   Check = "install"
+	Traffic   Check = "traffic"
+	MTLS      Check = "mtls"
+	Ingress   Check = "ingress"
+	Telemetry Check = "telemetry"
+)
+
+// Checks are all the checks of the smoke suite, in the order they are run.
+var Checks = []Check{Install, Traffic, MTLS, Ingress, Telemetry}
+
+var (
+	skip               string
+	existingPrometheus bool
+)
+
+func init() {
+	flag.StringVar(&skip, "istio.test.smoke.skip", skip,
+		fmt.Sprintf("Comma separated list of smoke checks to skip, of %v.", Checks))
+	flag.BoolVar(&existingPrometheus, "istio.test.smoke.existingPrometheus", existingPrometheus,
+		"Query the Prometheus already installed in the cluster rather than installing one.")
+}
+
+// skipped returns whether the check was skipped with --istio.test.smoke.skip.
+func skipped(c Check) bool {
+	for _, s := range strings.Split(skip, ",") {
+		if strings.TrimSpace(s) == string(c) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	httpPort = "http"
+	tcpPort  = "tcp"
+
+	ingressHost = "smoke.example.com"
+)
+
+// Instance is the workloads the smoke checks run against.
+type Instance struct {
+	istio      istio.Instance
+	ns         namespace.Instance
+	client     echo.Instance
+	server     echo.Instance
+	prometheus prometheus.Instance
+}
+
+// Setup returns a setup function that deploys the workloads of the smoke checks into the mesh of i, which must have
+// been set up first.
+func Setup(s *Instance, i *istio.Instance) resource.SetupFn {
+	return func(ctx resource.Context) (err error) {
+		for _, c := range strings.Split(skip, ",") {
+			if c = strings.TrimSpace(c); c != "" && !known(Check(c)) {
+				return fmt.Errorf("unknown smoke check %q, expected one of %v", c, Checks)
+			}
+		}
+		s.istio = *i
+		s.ns, err = namespace.New(ctx, namespace.Config{
+			Prefix:   "smoke",
+			Inject:   true,
+			Revision: s.istio.Settings().Revision,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := echoboot.NewBuilder(ctx).
+			With(&s.client, echo.Config{
+				Service:   "client",
+				Namespace: s.ns,
+				Subsets:   []echo.SubsetConfig{{}},
+			}).
+			With(&s.server, echo.Config{
+				Service:   "server",
+				Namespace: s.ns,
+				Subsets:   []echo.SubsetConfig{{}},
+				Ports: []echo.Port{
+					{Name: httpPort, Protocol: protocol.HTTP, InstancePort: 8090},
+					{Name: tcpPort, Protocol: protocol.TCP, InstancePort: 9000},
+				},
+			}).
+			Build(); err != nil {
+			return err
+		}
+		if !skipped(Telemetry) {
+			s.prometheus, err = prometheus.New(ctx, prometheus.Config{SkipDeploy: existingPrometheus})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func known(c Check) bool {
+	for _, k := range Checks {
+		if c == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Run each of the smoke checks not skipped, as sub-tests named by the check.
+func (s *Instance) Run(ctx framework.TestContext) {
+	checks := map[Check]func(framework.TestContext){
+		Install:   s.install,
+		Traffic:   s.traffic,
+		MTLS:      s.mtls,
+		Ingress:   s.ingress,
+		Telemetry: s.telemetry,
+	}
+	for _, c := range Checks {
+		c := c
+		ctx.NewSubTest(string(c)).Run(func(ctx framework.TestContext) {
+			if skipped(c) {
+				ctx.Skipf("smoke check %s skipped with --istio.test.smoke.skip", c)
+			}
+			checks[c](ctx)
+		})
+	}
+}
+
+// install checks that the control plane of each cluster, and the ingress gateway, are running, and that the
+// workloads were injected.
+func (s *Instance) install(ctx framework.TestContext) {
+	cfg := s.istio.Settings()
+	env, err := kube.EnvironmentFrom(ctx)
+	if err != nil {
+		ctx.Fatal(err)
+	}
+	for _, c := range env.ControlPlaneClusters() {
+		if _, err := kube2.CheckPodsAreReady(kube2.NewPodFetch(c, cfg.SystemNamespace, "app=istiod")); err != nil {
+			ctx.Fatalf("istiod is not ready in cluster %s: %v", c.Name(), err)
+		}
+	}
+	if !skipped(Ingress) {
+		c := ctx.Clusters().Default()
+		if _, err := kube2.CheckPodsAreReady(kube2.NewPodFetch(c, cfg.IngressNamespace, "istio=ingressgateway")); err != nil {
+			ctx.Fatalf("ingress gateway is not ready in cluster %s: %v", c.Name(), err)
+		}
+	}
+	for _, i := range []echo.Instance{s.client, s.server} {
+		cfg := i.Config()
+		pods, err := cfg.Cluster.PodsForSelector(context.TODO(), cfg.Namespace.Name(), "app="+cfg.Service)
+		if err != nil {
+			ctx.Fatal(err)
+		}
+		for _, pod := range pods.Items {
+			if !injected(pod) {
+				ctx.Fatalf("pod %s of %s was not injected with a sidecar", pod.Name, cfg.Service)
+			}
+		}
+	}
+}
+
+func injected(pod kubeApiCore.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == "istio-proxy" {
+			return true
+		}
+	}
+	return false
+}
+
+// traffic checks that the client reaches the server over HTTP and TCP.
+func (s *Instance) traffic(ctx framework.TestContext) {
+	for _, port := range []string{httpPort, tcpPort} {
+		check.CallOrFail(ctx, s.client, echo.CallOptions{
+			Target:   s.server,
+			PortName: port,
+			Count:    5,
+		}, check.OK())
+	}
+}
+
+// mtls checks that traffic between the workloads is mTLS when it is required.
+func (s *Instance) mtls(ctx framework.TestContext) {
+	ctx.Config().ApplyYAMLOrFail(ctx, s.ns.Name(), `
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: smoke-strict
+spec:
+  mtls:
+    mode: STRICT
+`)
+	ctx.WhenDone(func() error {
+		return ctx.Config().DeleteYAML(s.ns.Name(), `
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: smoke-strict
+`)
+	})
+	check.CallOrFail(ctx, s.client, echo.CallOptions{
+		Target:   s.server,
+		PortName: httpPort,
+		Count:    5,
+	}, check.And(
+		check.OK(),
+		check.Each(check.HeaderMatches("X-Forwarded-Client-Cert", "By=spiffe://.*")),
+	))
+}
+
+// ingress checks that the server is reached through the ingress gateway of each cluster.
+func (s *Instance) ingress(ctx framework.TestContext) {
+	ctx.Config().ApplyYAMLOrFail(ctx, s.ns.Name(), fmt.Sprintf(`
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: smoke
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - %[1]s
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: smoke
+spec:
+  hosts:
+  - %[1]s
+  gateways:
+  - smoke
+  http:
+  - route:
+    - destination:
+        host: server
+        port:
+          number: 80
+`, ingressHost))
+	// The workloads are deployed to the default cluster, so its gateway is called.
+	ing := s.istio.IngressFor(ctx.Clusters().Default())
+	retry.UntilSuccessOrFail(ctx, func() error {
+		return check.OK().Check(ing.CallEcho(echo.CallOptions{
+			Port:  &echo.Port{Protocol: protocol.HTTP},
+			Host:  ingressHost,
+			Count: 1,
+		}))
+	}, retry.Delay(time.Second), retry.Timeout(time.Minute))
+}
+
+// telemetry checks that the traffic between the workloads is reported to Prometheus.
+func (s *Instance) telemetry(ctx framework.TestContext) {
+	query := fmt.Sprintf(`istio_requests_total{reporter="destination",destination_service_name="server",`+
+		`destination_service_namespace=%q,response_code="200"}`, s.ns.Name())
+	retry.UntilSuccessOrFail(ctx, func() error {
+		if _, err := s.client.Call(echo.CallOptions{Target: s.server, PortName: httpPort, Count: 5}); err != nil {
+			return err
+		}
+		_, err := s.prometheus.WaitForOneOrMore(query)
+		return err
+	}, retry.Delay(3*time.Second), retry.Timeout(2*time.Minute))
+}
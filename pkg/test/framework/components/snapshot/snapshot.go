@@ -0,0 +1,170 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot compares the Envoy config generated for a canonical set of workloads in a live cluster against
+// checked-in golden files. Run with REFRESH_GOLDEN=true to update the golden files.
+package snapshot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pilot/test/util"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// sidecarScope limits the config of the workloads to their own namespace, so that it is not affected by other tests
+// running in the cluster.
+const sidecarScope = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Sidecar
+metadata:
+  name: default
+spec:
+  egress:
+  - hosts:
+    - "./*"
+`
+
+var ports = []echo.Port{
+	{
+		Name:         "http",
+		Protocol:     protocol.HTTP,
+		ServicePort:  80,
+		InstancePort: 18080,
+	},
+	{
+		Name:         "grpc",
+		Protocol:     protocol.GRPC,
+		ServicePort:  7070,
+		InstancePort: 17070,
+	},
+	{
+		Name:         "tcp",
+		Protocol:     protocol.TCP,
+		ServicePort:  9090,
+		InstancePort: 19090,
+	},
+}
+
+// CanonicalConfigs returns the canonical workload set deployed by Deploy.
+func CanonicalConfigs(ns namespace.Instance) []echo.Config {
+	return []echo.Config{
+		{
+			Service:   "a",
+			Namespace: ns,
+			Ports:     ports,
+		},
+		{
+			Service:   "headless",
+			Namespace: ns,
+			Headless:  true,
+			Ports:     ports,
+		},
+		{
+			Service:   "multiversion",
+			Namespace: ns,
+			Ports:     ports,
+			Subsets: []echo.SubsetConfig{
+				{Version: "v1"},
+				{Version: "v2"},
+			},
+		},
+	}
+}
+
+// Deploy deploys the canonical workload set into the namespace, which must be injected and dedicated to the
+// snapshot. The config of the workloads is scoped to the namespace.
+func Deploy(ctx resource.Context, ns namespace.Instance) (echo.Instances, error) {
+	if err := ctx.Config().ApplyYAML(ns.Name(), sidecarScope); err != nil {
+		return nil, fmt.Errorf("failed applying sidecar scope: %v", err)
+	}
+	builder := echoboot.NewBuilder(ctx)
+	for _, cfg := range CanonicalConfigs(ns) {
+		builder = builder.With(nil, cfg)
+	}
+	return builder.Build()
+}
+
+// DeployOrFail calls Deploy and fails the test if it returns an error.
+func DeployOrFail(t test.Failer, ctx resource.Context, ns namespace.Instance) echo.Instances {
+	t.Helper()
+	instances, err := Deploy(ctx, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return instances
+}
+
+// GoldenFile returns the path of the golden file for the instance within dir.
+func GoldenFile(dir string, i echo.Instance) string {
+	return filepath.Join(dir, i.Config().Service+".golden.json")
+}
+
+// Check compares the sanitized config of each of the instances against its golden file in dir. The addresses of the
+// instances are replaced with placeholders in the config of each of them, so that the golden files do not depend on
+// the addresses assigned to a run. If REFRESH_GOLDEN is set, the golden files are written instead.
+func Check(dir string, instances ...echo.Instance) error {
+	var errs error
+	for _, i := range instances {
+		if err := check(GoldenFile(dir, i), i, instances); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+// CheckOrFail calls Check and fails the test if it returns an error.
+func CheckOrFail(t test.Failer, dir string, instances ...echo.Instance) {
+	t.Helper()
+	if err := Check(dir, instances...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func check(goldenFile string, i echo.Instance, peers []echo.Instance) error {
+	actual, err := echo.SanitizedConfig(i, "", peers...)
+	if err != nil {
+		return err
+	}
+	return compareGolden(goldenFile, i.Config().Service, actual)
+}
+
+// compareGolden compares the config of the service against the golden file, or writes it if REFRESH_GOLDEN is set.
+func compareGolden(goldenFile, service, actual string) error {
+	if util.Refresh() {
+		if err := os.MkdirAll(filepath.Dir(goldenFile), os.ModePerm); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(goldenFile, []byte(actual+"\n"), 0644)
+	}
+	expected, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		return fmt.Errorf("failed reading golden file for %s (run with REFRESH_GOLDEN=true to create it): %v",
+			service, err)
+	}
+	if err := util.Compare([]byte(actual), expected); err != nil {
+		return fmt.Errorf("config for %s does not match golden file %s:\n%v", service, goldenFile, err)
+	}
+	return nil
+}
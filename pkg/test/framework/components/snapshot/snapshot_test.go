@@ -0,0 +1,52 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	golden := filepath.Join(dir, "nested", "a.golden.json")
+
+	if err := compareGolden(golden, "a", `{"listeners":[]}`); err == nil ||
+		!strings.Contains(err.Error(), "REFRESH_GOLDEN=true") {
+		t.Fatalf("expected a missing golden file to be reported, got %v", err)
+	}
+
+	os.Setenv("REFRESH_GOLDEN", "true")
+	err = compareGolden(golden, "a", `{"listeners":[]}`)
+	os.Unsetenv("REFRESH_GOLDEN")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := compareGolden(golden, "a", `{"listeners":[]}`); err != nil {
+		t.Fatalf("expected the refreshed golden file to match: %v", err)
+	}
+	if err := compareGolden(golden, "a", `{"listeners":["a"]}`); err == nil ||
+		!strings.Contains(err.Error(), "does not match golden file") {
+		t.Fatalf("expected changed config to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package statsoverride customizes the metrics of the stats filter, adding or removing their dimensions or dropping
+// them, and verifies the shape of the resulting series in Prometheus. This version of Istio has no Telemetry API, so
+// overrides are installed as the configuration of the stats filter of sidecars, through the configOverride values
+// of telemetry v2, with the dimensions they add declared as extraStatTags of the proxies.
+package statsoverride
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	prom "github.com/prometheus/common/model"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/prometheus"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// Metrics are the standard HTTP metrics of the stats filter, without their prefix.
+var Metrics = []string{"requests_total", "request_duration_milliseconds", "request_bytes", "response_bytes"}
+
+// Override customizes a metric of the stats filter.
+type Override struct {
+	// Metric is the name of the metric without its istio_ prefix, such as "requests_total". Empty overrides all of
+	// them.
+	Metric string
+	// Dimensions added to the metric, or replacing its standard ones, by name, to the expressions of their values,
+	// such as "request.host".
+	Dimensions map[string]string
+	// TagsToRemove are the standard dimensions removed from the metric, such as "request_protocol".
+	TagsToRemove []string
+	// Drop the metric rather than reporting it.
+	Drop bool
+}
+
+// Overrides of the stats filter of sidecars, by the side of the calls they report.
+type Overrides struct {
+	// Inbound overrides the metrics reported by the sidecars of servers, with reporter="destination".
+	Inbound []Override
+	// Outbound overrides the metrics reported by the sidecars of clients, with reporter="source".
+	Outbound []Override
+}
+
+// metricConfig is an entry of the metrics of the configuration of the stats filter.
+type metricConfig struct {
+	Name         string            `json:"name,omitempty"`
+	Dimensions   map[string]string `json:"dimensions,omitempty"`
+	TagsToRemove []string          `json:"tags_to_remove,omitempty"`
+	Drop         bool              `json:"drop,omitempty"`
+}
+
+func statsConfig(overrides []Override) map[string]interface{} {
+	metrics := make([]metricConfig, 0, len(overrides))
+	for _, o := range overrides {
+		metrics = append(metrics, metricConfig{
+			Name:         o.Metric,
+			Dimensions:   o.Dimensions,
+			TagsToRemove: o.TagsToRemove,
+			Drop:         o.Drop,
+		})
+	}
+	return map[string]interface{}{
+		"debug":       "false",
+		"stat_prefix": "istio",
+		"metrics":     metrics,
+	}
+}
+
+// extraStatTags are the dimensions added by the overrides, which proxies must declare.
+func (o Overrides) extraStatTags() []string {
+	tags := map[string]struct{}{}
+	for _, ov := range append(append([]Override{}, o.Inbound...), o.Outbound...) {
+		for d := range ov.Dimensions {
+			tags[d] = struct{}{}
+		}
+	}
+	out := make([]string, 0, len(tags))
+	for t := range tags {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ControlPlaneValues returns the install values of the overrides, as set in istio.Config.ControlPlaneValues.
+func (o Overrides) ControlPlaneValues() (string, error) {
+	configOverride := map[string]interface{}{}
+	if len(o.Inbound) > 0 {
+		configOverride["inboundSidecar"] = statsConfig(o.Inbound)
+	}
+	if len(o.Outbound) > 0 {
+		configOverride["outboundSidecar"] = statsConfig(o.Outbound)
+	}
+	values := map[string]interface{}{
+		"values": map[string]interface{}{
+			"telemetry": map[string]interface{}{
+				"v2": map[string]interface{}{
+					"prometheus": map[string]interface{}{
+						"configOverride": configOverride,
+					},
+				},
+			},
+		},
+	}
+	if tags := o.extraStatTags(); len(tags) > 0 {
+		values["meshConfig"] = map[string]interface{}{
+			"defaultConfig": map[string]interface{}{
+				"extraStatTags": tags,
+			},
+		}
+	}
+	out, err := yaml.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed marshaling overrides: %v", err)
+	}
+	return string(out), nil
+}
+
+// SetupConfig returns a function installing Istio with the overrides, for istio.Setup. It replaces the
+// ControlPlaneValues of the config.
+func SetupConfig(o Overrides) func(resource.Context, *istio.Config) {
+	return func(_ resource.Context, cfg *istio.Config) {
+		if cfg == nil {
+			return
+		}
+		values, err := o.ControlPlaneValues()
+		if err != nil {
+			// The overrides are only made of strings and booleans.
+			panic(err)
+		}
+		cfg.ControlPlaneValues = values
+	}
+}
+
+// Verify checks the shape of the series of the standard HTTP metrics reported for the calls to the destination
+// namespace since the given time, against the overrides: dropped metrics have no series, and the series of the
+// others have the dimensions added and lack those removed. The calls must have been made, and reported, by then.
+// Series are selected by their reporter and destination_workload_namespace, which the overrides must keep.
+func Verify(p prometheus.Instance, o Overrides, namespace string, since time.Time) error {
+	var errs error
+	for _, side := range []struct {
+		reporter  string
+		overrides []Override
+	}{
+		{"destination", o.Inbound},
+		{"source", o.Outbound},
+	} {
+		for _, metric := range Metrics {
+			series, err := seriesOf(p, metric, side.reporter, namespace, since)
+			if err != nil {
+				return err
+			}
+			if err := verifyMetric(metric, series, side.overrides); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("reporter=%s: %v", side.reporter, err))
+			}
+		}
+	}
+	return errs
+}
+
+// VerifyOrFail calls Verify and fails the test if it returns an error.
+func VerifyOrFail(t test.Failer, p prometheus.Instance, o Overrides, namespace string, since time.Time) {
+	t.Helper()
+	if err := Verify(p, o, namespace, since); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// seriesOf returns the series of the metric, by the name of its counter or the count of its histogram.
+func seriesOf(p prometheus.Instance, metric, reporter, namespace string, since time.Time) ([]prom.LabelSet, error) {
+	name := "istio_" + metric
+	if !strings.HasSuffix(metric, "_total") {
+		name += "_count"
+	}
+	selector := fmt.Sprintf(`%s{reporter=%q,destination_workload_namespace=%q}`, name, reporter, namespace)
+	series, _, err := p.API().Series(context.Background(), []string{selector}, since, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("error querying Prometheus series %s: %v", selector, err)
+	}
+	return series, nil
+}
+
+// verifyMetric checks the series of a metric against the overrides applying to it.
+func verifyMetric(metric string, series []prom.LabelSet, overrides []Override) error {
+	drop := false
+	added := map[string]struct{}{}
+	removed := map[string]struct{}{}
+	for _, o := range overrides {
+		if o.Metric != "" && o.Metric != metric {
+			continue
+		}
+		drop = drop || o.Drop
+		for d := range o.Dimensions {
+			added[d] = struct{}{}
+			delete(removed, d)
+		}
+		for _, t := range o.TagsToRemove {
+			removed[t] = struct{}{}
+			delete(added, t)
+		}
+	}
+	if drop {
+		if len(series) > 0 {
+			return fmt.Errorf("istio_%s is dropped, but has %d series", metric, len(series))
+		}
+		return nil
+	}
+	if len(series) == 0 {
+		return fmt.Errorf("istio_%s has no series", metric)
+	}
+	var errs error
+	for _, s := range series {
+		for d := range added {
+			if s[prom.LabelName(d)] == "" {
+				errs = multierror.Append(errs, fmt.Errorf("istio_%s series %v lacks added dimension %s", metric, s, d))
+			}
+		}
+		for t := range removed {
+			if v, ok := s[prom.LabelName(t)]; ok {
+				errs = multierror.Append(errs, fmt.Errorf("istio_%s series %v has removed dimension %s=%q", metric, s,
+					t, v))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,377 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracesampling verifies the accuracy of trace sampling: it sends a number of requests between echo
+// workloads, counts those the proxies decided to sample and those reported to a tracing provider, and checks both
+// against confidence bounds of the configured sampling rate. Cases cover the ways the rate is configured.
+package tracesampling
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/envoy"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/zipkin"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// Source is a way the sampling rate is configured.
+type Source string
+
+const (
+	// MeshDefault is the sampling of meshConfig.defaultConfig.tracing, set when Istio is installed.
+	MeshDefault Source = "mesh-default"
+	// Workload is the sampling of the proxy.istio.io/config annotation of the client workload.
+	Workload Source = "workload"
+	// TelemetryAPI is the randomSamplingPercentage of a Telemetry resource of the namespace of the client. The control
+	// plane of this tree has no Telemetry API, so such cases only run against control planes serving it.
+	TelemetryAPI Source = "telemetry-api"
+)
+
+// confidenceZ is the number of standard deviations of the bounds of sampled counts, so that a correct sampler is
+// out of bounds about once in 15000 runs.
+const confidenceZ = 4.0
+
+// concurrency of the requests sent by Run.
+const concurrency = 10
+
+// Traces sampled by Envoy have the trace reason packed into the request ID, at the index used by
+// echo.SameRequestID, set to one of these values.
+const (
+	traceSampled = '9'
+	traceForced  = 'a'
+	traceClient  = 'b'
+	traceReason  = 14
+)
+
+// Case of the sampling matrix.
+type Case struct {
+	// Source of the sampling rate.
+	Source Source
+	// Rate is the sampling percentage, from 0 to 100.
+	Rate float64
+}
+
+func (c Case) String() string {
+	return fmt.Sprintf("%s-%v", c.Source, c.Rate)
+}
+
+// Matrix returns the cases of every rate configured for the workload and by the Telemetry API, and of the mesh
+// default the suite was installed with.
+func Matrix(meshDefault float64, rates ...float64) []Case {
+	cases := []Case{{Source: MeshDefault, Rate: meshDefault}}
+	for _, s := range []Source{Workload, TelemetryAPI} {
+		for _, r := range rates {
+			cases = append(cases, Case{Source: s, Rate: r})
+		}
+	}
+	return cases
+}
+
+// MeshConfigValues returns the install values setting the mesh default sampling rate, as set in
+// istio.Config.ControlPlaneValues.
+func MeshConfigValues(rate float64) string {
+	return fmt.Sprintf(`
+meshConfig:
+  enableTracing: true
+  defaultConfig:
+    tracing:
+      sampling: %v
+`, rate)
+}
+
+// WorkloadAnnotations returns the annotations of a client configuring its sampling rate.
+func WorkloadAnnotations(rate float64) echo.Annotations {
+	return echo.NewAnnotations().Set(echo.SidecarProxyConfig, fmt.Sprintf("tracing:\n  sampling: %v\n", rate))
+}
+
+// TelemetryYAML returns a Telemetry resource configuring the sampling rate of the workloads of its namespace.
+func TelemetryYAML(rate float64) string {
+	return fmt.Sprintf(`apiVersion: telemetry.istio.io/v1alpha1
+kind: Telemetry
+metadata:
+  name: sampling
+spec:
+  tracing:
+  - randomSamplingPercentage: %v
+`, rate)
+}
+
+// outboundSampling returns the random sampling percentages of the HTTP connection managers of the outbound listeners
+// in the config dump.
+func outboundSampling(dump *envoyAdmin.ConfigDump) ([]float64, error) {
+	var rates []float64
+	for _, c := range dump.Configs {
+		if c.TypeUrl != envoy.ListenersConfigDumpType {
+			continue
+		}
+		listeners := &envoyAdmin.ListenersConfigDump{}
+		if err := ptypes.UnmarshalAny(c, listeners); err != nil {
+			return nil, err
+		}
+		for _, dl := range listeners.DynamicListeners {
+			if dl.ActiveState == nil {
+				continue
+			}
+			l := &listener.Listener{}
+			if err := ptypes.UnmarshalAny(dl.ActiveState.Listener, l); err != nil {
+				return nil, err
+			}
+			if l.TrafficDirection != core.TrafficDirection_OUTBOUND {
+				continue
+			}
+			for _, fc := range l.FilterChains {
+				for _, f := range fc.Filters {
+					if f.Name != wellknown.HTTPConnectionManager || f.GetTypedConfig() == nil {
+						continue
+					}
+					h := &hcm.HttpConnectionManager{}
+					if err := ptypes.UnmarshalAny(f.GetTypedConfig(), h); err != nil {
+						return nil, err
+					}
+					rates = append(rates, h.GetTracing().GetRandomSampling().GetValue())
+				}
+			}
+		}
+	}
+	return rates, nil
+}
+
+// WaitForRate waits until the sidecars of the client sample outbound requests at the rate. A rate configured
+// separately from the client, such as by a Telemetry resource, may reach its sidecars after they start, and would
+// otherwise only apply to some of the requests of a run.
+func WaitForRate(client echo.Instance, rate float64) error {
+	workloads, err := client.Workloads()
+	if err != nil {
+		return err
+	}
+	for _, w := range workloads {
+		if w.Sidecar() == nil {
+			return fmt.Errorf("workload %s has no sidecar", w.Address())
+		}
+		if err := w.Sidecar().WaitForConfig(func(dump *envoyAdmin.ConfigDump) (bool, error) {
+			rates, err := outboundSampling(dump)
+			if err != nil {
+				return false, err
+			}
+			if len(rates) == 0 {
+				return false, fmt.Errorf("no outbound HTTP listeners")
+			}
+			for _, r := range rates {
+				if r != rate {
+					return false, fmt.Errorf("outbound listeners sample at %v%%, want %v%%", rates, rate)
+				}
+			}
+			return true, nil
+		}, retry.Timeout(time.Minute)); err != nil {
+			return fmt.Errorf("sidecar of %s: %v", w.Address(), err)
+		}
+	}
+	return nil
+}
+
+// WaitForRateOrFail calls WaitForRate and fails the test if it returns an error.
+func WaitForRateOrFail(t test.Failer, client echo.Instance, rate float64) {
+	t.Helper()
+	if err := WaitForRate(client, rate); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Bounds returns the range of the number of requests out of n expected to be sampled at the rate, using the normal
+// approximation of the binomial distribution.
+func Bounds(n int, rate float64) (min, max int) {
+	p := rate / 100
+	if p <= 0 {
+		return 0, 0
+	}
+	if p >= 1 {
+		return n, n
+	}
+	mean := float64(n) * p
+	d := confidenceZ * math.Sqrt(float64(n)*p*(1-p))
+	min = int(math.Max(0, math.Floor(mean-d)))
+	max = int(math.Min(float64(n), math.Ceil(mean+d)))
+	return min, max
+}
+
+// Provider of traces, counting the traces reported for requests.
+type Provider interface {
+	// Name of the provider, such as "zipkin".
+	Name() string
+	// CountTraces returns the number of the requests with the IDs, as seen by the server, that have a trace.
+	CountTraces(ids []string) (int, error)
+}
+
+type zipkinProvider struct {
+	z zipkin.Instance
+}
+
+// Zipkin returns the provider counting the traces reported to the zipkin instance.
+func Zipkin(z zipkin.Instance) Provider {
+	return zipkinProvider{z: z}
+}
+
+func (p zipkinProvider) Name() string {
+	return "zipkin"
+}
+
+func (p zipkinProvider) CountTraces(ids []string) (int, error) {
+	n := 0
+	for _, id := range ids {
+		traces, err := p.z.QueryTraces(1, "", zipkin.RequestIDQuery(id))
+		if err != nil {
+			return n, fmt.Errorf("cannot get traces from zipkin: %v", err)
+		}
+		if len(traces) > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Result of a run of requests.
+type Result struct {
+	// Requests is the number of requests sent.
+	Requests int
+	// Sampled is the number of requests the proxies decided to trace.
+	Sampled int
+	// Traced is the number of requests with a trace reported to the provider.
+	Traced int
+	// Provider is the name of the provider traces were counted in, if any.
+	Provider string
+	// IDs are the request IDs of the requests, as seen by the server.
+	IDs []string
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%d requests, %d sampled, %d traced in %s", r.Requests, r.Sampled, r.Traced, r.Provider)
+}
+
+// sampled returns true if the request ID seen by the server carries a decision to trace the request.
+func sampled(id string) bool {
+	if len(id) <= traceReason {
+		return false
+	}
+	switch id[traceReason] {
+	case traceSampled, traceForced, traceClient:
+		return true
+	}
+	return false
+}
+
+// Run sends n requests from the client with the call options, each with its own request ID, and counts those that
+// were sampled and those whose traces were reported to the provider. Reported traces are counted once the provider
+// has all of the sampled ones, or after a minute.
+func Run(from echo.Instance, opts echo.CallOptions, n int, p Provider) (Result, error) {
+	ids := make([]string, n)
+	var errs error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			o := opts
+			o.Count = 1
+			o.RequestID = echo.NewRequestID()
+			resp, err := from.Call(o)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = multierror.Append(errs, err)
+				return
+			}
+			ids[i] = resp[0].ID
+		}(i)
+	}
+	wg.Wait()
+	if errs != nil {
+		return Result{}, errs
+	}
+
+	r := Result{Requests: n, IDs: ids}
+	var sampledIDs []string
+	for _, id := range ids {
+		if sampled(id) {
+			sampledIDs = append(sampledIDs, id)
+		}
+	}
+	r.Sampled = len(sampledIDs)
+	if p == nil {
+		return r, nil
+	}
+	r.Provider = p.Name()
+	// Traces are reported asynchronously. Wait until the provider has as many as were sampled, leaving any shortfall
+	// to be reported by Check.
+	var queryErr error
+	_ = retry.UntilSuccess(func() error {
+		r.Traced, queryErr = p.CountTraces(ids)
+		if queryErr != nil {
+			return queryErr
+		}
+		if r.Traced < r.Sampled {
+			return fmt.Errorf("%s has %d of %d sampled traces", p.Name(), r.Traced, r.Sampled)
+		}
+		return nil
+	}, retry.Delay(5*time.Second), retry.Timeout(time.Minute))
+	return r, queryErr
+}
+
+// Check that the sampled count of the result, and its traced count if it has a provider, are within the bounds of the
+// rate.
+func (r Result) Check(rate float64) error {
+	min, max := Bounds(r.Requests, rate)
+	var errs error
+	if r.Sampled < min || r.Sampled > max {
+		errs = multierror.Append(errs, fmt.Errorf("%d of %d requests sampled, expected %d to %d at %v%%",
+			r.Sampled, r.Requests, min, max, rate))
+	}
+	if r.Provider != "" && (r.Traced < min || r.Traced > max) {
+		errs = multierror.Append(errs, fmt.Errorf("%d of %d requests traced in %s, expected %d to %d at %v%%",
+			r.Traced, r.Requests, r.Provider, min, max, rate))
+	}
+	return errs
+}
+
+// RunOrFail runs the requests and fails the test unless their sampled and traced counts are within the bounds of
+// the rate.
+func RunOrFail(t test.Failer, from echo.Instance, opts echo.CallOptions, n int, p Provider, rate float64) Result {
+	t.Helper()
+	r, err := Run(from, opts, n, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Check(rate); err != nil {
+		t.Fatalf("%v: %v", r, err)
+	}
+	return r
+}
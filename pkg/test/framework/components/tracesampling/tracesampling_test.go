@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracesampling
+
+import (
+	"reflect"
+	"testing"
+
+	envoyAdmin "github.com/envoyproxy/go-control-plane/envoy/admin/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	xdstype "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func marshalAny(t *testing.T, m proto.Message) *any.Any {
+	t.Helper()
+	a, err := ptypes.MarshalAny(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// httpListener returns a listener in the direction with an HTTP connection manager sampling at the rate.
+func httpListener(t *testing.T, direction core.TrafficDirection, rate float64) *envoyAdmin.ListenersConfigDump_DynamicListener {
+	h := &hcm.HttpConnectionManager{Tracing: &hcm.HttpConnectionManager_Tracing{
+		RandomSampling: &xdstype.Percent{Value: rate},
+	}}
+	l := &listener.Listener{
+		TrafficDirection: direction,
+		FilterChains: []*listener.FilterChain{{Filters: []*listener.Filter{{
+			Name:       wellknown.HTTPConnectionManager,
+			ConfigType: &listener.Filter_TypedConfig{TypedConfig: marshalAny(t, h)},
+		}}}},
+	}
+	return &envoyAdmin.ListenersConfigDump_DynamicListener{
+		ActiveState: &envoyAdmin.ListenersConfigDump_DynamicListenerState{Listener: marshalAny(t, l)},
+	}
+}
+
+func TestOutboundSampling(t *testing.T) {
+	listeners := &envoyAdmin.ListenersConfigDump{DynamicListeners: []*envoyAdmin.ListenersConfigDump_DynamicListener{
+		httpListener(t, core.TrafficDirection_OUTBOUND, 10),
+		httpListener(t, core.TrafficDirection_INBOUND, 25),
+		httpListener(t, core.TrafficDirection_OUTBOUND, 100),
+		// A listener still warming has no active state.
+		{Name: "warming"},
+	}}
+	dump := &envoyAdmin.ConfigDump{Configs: []*any.Any{marshalAny(t, &envoyAdmin.BootstrapConfigDump{}), marshalAny(t, listeners)}}
+	got, err := outboundSampling(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{10, 100}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got rates %v, want %v", got, want)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	got := Matrix(25, 10, 100)
+	want := []Case{
+		{Source: MeshDefault, Rate: 25},
+		{Source: Workload, Rate: 10},
+		{Source: Workload, Rate: 100},
+		{Source: TelemetryAPI, Rate: 10},
+		{Source: TelemetryAPI, Rate: 100},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got cases %v, want %v", got, want)
+	}
+}
@@ -15,6 +15,7 @@
 package zipkin
 
 import (
+	"net/url"
 	"testing"
 
 	"istio.io/istio/pkg/test/framework/resource"
@@ -64,3 +65,10 @@ func NewOrFail(t *testing.T, ctx resource.Context, c Config) Instance {
 
 	return i
 }
+
+// RequestIDQuery returns the annotation query of QueryTraces matching the spans of requests with the ID, as sent in
+// echo.CallOptions.RequestID. Envoy packs the trace reason into the ID, so the ID must be the one seen by the server,
+// such as the ID of an echo response.
+func RequestIDQuery(id string) string {
+	return url.QueryEscape("guid:x-request-id=" + id)
+}
@@ -15,14 +15,27 @@
 package framework
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 
+	kubeErrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/kustomize"
+	"sigs.k8s.io/kustomize/pkg/fs"
+
+	"istio.io/istio/pkg/config"
+	"istio.io/istio/pkg/config/schema/collections"
 	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/components/namespace"
 	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
 	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/yml"
 )
 
 var _ resource.ConfigManager = &configManager{}
@@ -31,6 +44,13 @@ type configManager struct {
 	ctx      resource.Context
 	clusters []resource.Cluster
 	prefix   string
+
+	// waitForDistribution indicates that applying config should block until it has been distributed.
+	waitForDistribution bool
+
+	// restrictedNamespaces caches whether namespaces are restricted for the config managers derived from the same
+	// ctx.Config() call, so that a label changed later is seen by the next call.
+	restrictedNamespaces *restrictedNamespaces
 }
 
 func newConfigManager(ctx resource.Context, clusters []resource.Cluster) resource.ConfigManager {
@@ -38,8 +58,9 @@ func newConfigManager(ctx resource.Context, clusters []resource.Cluster) resourc
 		clusters = ctx.Clusters()
 	}
 	return &configManager{
-		ctx:      ctx,
-		clusters: clusters,
+		ctx:                  ctx,
+		clusters:             clusters,
+		restrictedNamespaces: newRestrictedNamespaces(),
 	}
 }
 
@@ -48,20 +69,170 @@ func (c *configManager) ApplyYAML(ns string, yamlText ...string) error {
 		return c.WithFilePrefix("apply").ApplyYAML(ns, yamlText...)
 	}
 
+	if tenant := c.ctx.Settings().Tenant; tenant != "" {
+		var err error
+		if yamlText, err = configForTenant(tenant, yamlText); err != nil {
+			return err
+		}
+	}
+	// Workloads are restricted in the clusters whose namespace enforces the restricted profile.
+	restricted := make([]bool, len(c.clusters))
+	anyRestricted := false
+	for i, cluster := range c.clusters {
+		r, err := c.restricted(cluster, ns)
+		if err != nil {
+			return err
+		}
+		restricted[i] = r
+		anyRestricted = anyRestricted || r
+	}
+	var restrictedText []string
+	if anyRestricted {
+		var err error
+		if restrictedText, err = restrictWorkloads(yamlText); err != nil {
+			return err
+		}
+	}
+
 	// Convert the content to files.
 	yamlFiles, err := c.ctx.WriteYAML(c.prefix, yamlText...)
 	if err != nil {
 		return err
 	}
+	restrictedFiles := yamlFiles
+	if anyRestricted {
+		if restrictedFiles, err = c.ctx.WriteYAML(c.prefix, restrictedText...); err != nil {
+			return err
+		}
+	}
 
-	for _, c := range c.clusters {
-		if err := c.ApplyYAMLFiles(ns, yamlFiles...); err != nil {
+	for i, c := range c.clusters {
+		files := yamlFiles
+		if restricted[i] {
+			files = restrictedFiles
+		}
+		if err := c.ApplyYAMLFiles(ns, files...); err != nil {
 			return fmt.Errorf("failed applying YAML to cluster %s: %v", c.Name(), err)
 		}
 	}
+
+	if c.waitForDistribution {
+		return c.waitForConfigDistribution(ns, yamlText...)
+	}
 	return nil
 }
 
+// waitForConfigDistribution waits for each Istio resource in the given YAML to be distributed in all clusters.
+// Non-Istio resources are ignored.
+func (c *configManager) waitForConfigDistribution(ns string, yamlText ...string) error {
+	for _, text := range yamlText {
+		parts, err := yml.Parse(text)
+		if err != nil {
+			return err
+		}
+		for _, part := range parts {
+			d := part.Descriptor
+			s, found := collections.Pilot.FindByGroupVersionKind(config.GroupVersionKind{
+				Group:   d.Group,
+				Version: d.APIVersion,
+				Kind:    d.Kind,
+			})
+			if !found {
+				continue
+			}
+
+			resourceNs := d.Metadata.Namespace
+			if resourceNs == "" {
+				resourceNs = ns
+			}
+			if resourceNs == "" {
+				resourceNs = "default"
+			}
+
+			for _, cluster := range c.clusters {
+				scopes.Framework.Debugf("Waiting for distribution of %s %s/%s in cluster %s",
+					d.Kind, resourceNs, d.Metadata.Name, cluster.Name())
+				if err := kube.WaitForConfigDistribution(cluster, s.Resource().GroupVersionResource(),
+					resourceNs, d.Metadata.Name); err != nil {
+					return fmt.Errorf("failed waiting for distribution of %s %s/%s in cluster %s: %v",
+						d.Kind, resourceNs, d.Metadata.Name, cluster.Name(), err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// restricted returns whether the namespace of the cluster enforces the restricted PodSecurity profile, as the
+// namespaces created for tests do when it is enabled. Other namespaces, such as those of privileged framework
+// components, are not.
+func (c *configManager) restricted(cluster resource.Cluster, ns string) (bool, error) {
+	if !c.ctx.Settings().PodSecurityRestricted || ns == "" {
+		return false, nil
+	}
+	return c.restrictedNamespaces.get(cluster, ns)
+}
+
+type restrictedKey struct {
+	cluster   string
+	namespace string
+}
+
+// restrictedNamespaces caches whether namespaces are restricted, by cluster and namespace, as config is often applied
+// to the same namespaces many times. A nil cache looks namespaces up every time.
+type restrictedNamespaces struct {
+	mu sync.Mutex
+	m  map[restrictedKey]bool
+}
+
+func newRestrictedNamespaces() *restrictedNamespaces {
+	return &restrictedNamespaces{m: map[restrictedKey]bool{}}
+}
+
+func (r *restrictedNamespaces) get(c resource.Cluster, ns string) (bool, error) {
+	key := restrictedKey{cluster: c.Name(), namespace: ns}
+	if r != nil {
+		r.mu.Lock()
+		restricted, ok := r.m[key]
+		r.mu.Unlock()
+		if ok {
+			return restricted, nil
+		}
+	}
+	n, err := c.CoreV1().Namespaces().Get(context.TODO(), ns, kubeApiMeta.GetOptions{})
+	if kubeErrors.IsNotFound(err) {
+		// The namespace may be created by the config itself. Its label is not known yet.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed getting namespace %s in cluster %s: %v", ns, c.Name(), err)
+	}
+	restricted := n.Labels[namespace.PodSecurityEnforceLabel] == namespace.PodSecurityRestricted
+	if r != nil {
+		r.mu.Lock()
+		r.m[key] = restricted
+		r.mu.Unlock()
+	}
+	return restricted, nil
+}
+
+// restrictedUID is the user that the containers of restricted workloads run as, unless they set their own.
+const restrictedUID = 1000
+
+// restrictWorkloads makes the workloads in the YAML comply with the restricted PodSecurity profile, so that the
+// workloads the framework or the tests deploy can be admitted into the restricted test namespaces.
+func restrictWorkloads(yamlText []string) ([]string, error) {
+	out := make([]string, 0, len(yamlText))
+	for _, text := range yamlText {
+		restricted, err := yml.ApplyRestricted(text, restrictedUID)
+		if err != nil {
+			return nil, fmt.Errorf("failed applying the restricted PodSecurity profile: %v", err)
+		}
+		out = append(out, restricted)
+	}
+	return out, nil
+}
+
 func (c *configManager) ApplyYAMLOrFail(t test.Failer, ns string, yamlText ...string) {
 	err := c.ApplyYAML(ns, yamlText...)
 	if err != nil {
@@ -129,10 +300,73 @@ func (c *configManager) DeleteYAMLDir(ns string, configDir string) error {
 	})
 }
 
+func (c *configManager) ApplyKustomize(ns string, dir string) error {
+	return c.forKustomize(dir, func(cm resource.ConfigManager, yamlText string) error {
+		return cm.ApplyYAML(ns, yamlText)
+	})
+}
+
+func (c *configManager) DeleteKustomize(ns string, dir string) error {
+	return c.forKustomize(dir, func(cm resource.ConfigManager, yamlText string) error {
+		return cm.DeleteYAML(ns, yamlText)
+	})
+}
+
+// forKustomize builds the kustomization of each cluster, that is its overlay if there is one and the kustomization
+// of the directory otherwise, and calls fn with a config manager for the clusters sharing the result.
+func (c *configManager) forKustomize(dir string, fn func(cm resource.ConfigManager, yamlText string) error) error {
+	built := map[string]string{}
+	clustersByDir := map[string][]resource.Cluster{}
+	var dirs []string
+	for _, cluster := range c.clusters {
+		d := dir
+		overlay := filepath.Join(dir, "overlays", cluster.Name())
+		if _, err := os.Stat(filepath.Join(overlay, "kustomization.yaml")); err == nil {
+			d = overlay
+		}
+		if _, ok := clustersByDir[d]; !ok {
+			dirs = append(dirs, d)
+		}
+		clustersByDir[d] = append(clustersByDir[d], cluster)
+	}
+	for _, d := range dirs {
+		var out bytes.Buffer
+		if err := kustomize.RunKustomizeBuild(&out, fs.MakeRealFS(), d); err != nil {
+			return fmt.Errorf("failed building kustomization %s: %v", d, err)
+		}
+		built[d] = out.String()
+	}
+	for _, d := range dirs {
+		cm := &configManager{
+			ctx:                  c.ctx,
+			prefix:               c.prefix,
+			clusters:             clustersByDir[d],
+			waitForDistribution:  c.waitForDistribution,
+			restrictedNamespaces: c.restrictedNamespaces,
+		}
+		if err := fn(cm, built[d]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *configManager) WithFilePrefix(prefix string) resource.ConfigManager {
 	return &configManager{
-		ctx:      c.ctx,
-		prefix:   prefix,
-		clusters: c.clusters,
+		ctx:                  c.ctx,
+		prefix:               prefix,
+		clusters:             c.clusters,
+		waitForDistribution:  c.waitForDistribution,
+		restrictedNamespaces: c.restrictedNamespaces,
+	}
+}
+
+func (c *configManager) WaitForDistribution() resource.ConfigManager {
+	return &configManager{
+		ctx:                  c.ctx,
+		prefix:               c.prefix,
+		clusters:             c.clusters,
+		waitForDistribution:  true,
+		restrictedNamespaces: c.restrictedNamespaces,
 	}
 }
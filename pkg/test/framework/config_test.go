@@ -0,0 +1,162 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestForKustomize(t *testing.T) {
+	g := NewWithT(t)
+	dir, err := ioutil.TempDir("", "kustomize")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = os.RemoveAll(dir) }()
+	writeFiles(t, dir, map[string]string{
+		"kustomization.yaml":      "bases:\n- base\n",
+		"base/kustomization.yaml": "resources:\n- config.yaml\n",
+		"base/config.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+`,
+		"overlays/remote/kustomization.yaml": "namePrefix: remote-\nbases:\n- ../../base\n",
+		// An overlay of a cluster that is not in the environment.
+		"overlays/absent/kustomization.yaml": "namePrefix: absent-\nbases:\n- ../../base\n",
+	})
+
+	c := &configManager{clusters: []resource.Cluster{
+		resource.FakeCluster{NameValue: "primary"},
+		resource.FakeCluster{NameValue: "remote"},
+		resource.FakeCluster{NameValue: "other"},
+	}}
+	built := map[string]string{}
+	g.Expect(c.forKustomize(dir, func(cm resource.ConfigManager, yamlText string) error {
+		var names []string
+		for _, cluster := range cm.(*configManager).clusters {
+			names = append(names, cluster.Name())
+		}
+		built[strings.Join(names, ",")] = yamlText
+		return nil
+	})).To(Succeed())
+
+	// Clusters without an overlay share the kustomization of the directory.
+	g.Expect(built).To(HaveLen(2))
+	g.Expect(built).To(HaveKey("primary,other"))
+	g.Expect(built).To(HaveKey("remote"))
+	g.Expect(built["primary,other"]).To(ContainSubstring("name: config\n"))
+	g.Expect(built["remote"]).To(ContainSubstring("name: remote-config\n"))
+	g.Expect(built["remote"]).To(ContainSubstring("key: value"))
+}
+
+func TestForKustomizeInvalid(t *testing.T) {
+	g := NewWithT(t)
+	dir, err := ioutil.TempDir("", "kustomize")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer func() { _ = os.RemoveAll(dir) }()
+	writeFiles(t, dir, map[string]string{"kustomization.yaml": "resources:\n- missing.yaml\n"})
+
+	c := &configManager{clusters: []resource.Cluster{resource.FakeCluster{NameValue: "primary"}}}
+	called := false
+	err = c.forKustomize(dir, func(resource.ConfigManager, string) error {
+		called = true
+		return nil
+	})
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("failed building kustomization"))
+	g.Expect(called).To(BeFalse())
+}
+
+func TestNamespaceRestricted(t *testing.T) {
+	g := NewWithT(t)
+	newCluster := func(name string, restricted bool) resource.Cluster {
+		ns := &kubeApiCore.Namespace{ObjectMeta: kubeApiMeta.ObjectMeta{Name: "test"}}
+		if restricted {
+			ns.Labels = map[string]string{namespace.PodSecurityEnforceLabel: namespace.PodSecurityRestricted}
+		}
+		return resource.FakeCluster{
+			ExtendedClient: kube.NewFakeClient(ns, &kubeApiCore.Namespace{
+				ObjectMeta: kubeApiMeta.ObjectMeta{Name: "privileged"},
+			}).(kube.ExtendedClient),
+			NameValue: name,
+		}
+	}
+	restricted := newCluster("restricted", true)
+	unrestricted := newCluster("unrestricted", false)
+
+	cache := newRestrictedNamespaces()
+	r, err := cache.get(restricted, "test")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r).To(BeTrue())
+	r, err = cache.get(restricted, "privileged")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r).To(BeFalse())
+	r, err = cache.get(restricted, "missing")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r).To(BeFalse())
+
+	// The same namespace is cached separately for each cluster.
+	r, err = cache.get(unrestricted, "test")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r).To(BeFalse())
+
+	// Once looked up, the namespace is not read again by the same cache.
+	ns, err := restricted.CoreV1().Namespaces().Get(context.TODO(), "test", kubeApiMeta.GetOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+	ns.Labels = nil
+	_, err = restricted.CoreV1().Namespaces().Update(context.TODO(), ns, kubeApiMeta.UpdateOptions{})
+	g.Expect(err).NotTo(HaveOccurred())
+	r, err = cache.get(restricted, "test")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r).To(BeTrue())
+
+	// A new cache, as held by the next config manager, sees the change.
+	r, err = newRestrictedNamespaces().get(restricted, "test")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r).To(BeFalse())
+
+	// Without a cache, the namespace is read every time.
+	var none *restrictedNamespaces
+	r, err = none.get(restricted, "test")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(r).To(BeFalse())
+}
@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crs offers typed access to the Istio CRs of the clusters of a test, such as VirtualServices and
+// AuthorizationPolicies, so that assertions need not convert unstructured objects of the dynamic client. For each
+// kind, the typed client of a namespace of a cluster is returned by its plural, such as VirtualServices, and Get and
+// List helpers fan out to several clusters, keying results by cluster name, while Watch helpers merge the events of
+// several clusters.
+package crs
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/watch"
+
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// nolint: lll
+//go:generate go run $REPO_ROOT/pkg/test/framework/crs/gen/main.go --template $REPO_ROOT/pkg/test/framework/crs/gen/crs.go.tmpl --output $REPO_ROOT/pkg/test/framework/crs/crs.gen.go
+
+// Event is a watch event of a cluster.
+type Event struct {
+	watch.Event
+	// Cluster is the name of the cluster of the event.
+	Cluster string
+}
+
+// Watcher merges the watches of several clusters. Its events are closed once the watches of all clusters end.
+type Watcher struct {
+	events  chan Event
+	stop    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+	watches []watch.Interface
+}
+
+// watchClusters watches each of the clusters. If any of them fails, those already started are stopped.
+func watchClusters(clusters resource.Clusters, watchFn func(c resource.Cluster) (watch.Interface, error)) (*Watcher, error) {
+	w := &Watcher{events: make(chan Event), stop: make(chan struct{})}
+	for _, c := range clusters {
+		cw, err := watchFn(c)
+		if err != nil {
+			w.Stop()
+			return nil, fmt.Errorf("cluster %s: %v", c.Name(), err)
+		}
+		w.watches = append(w.watches, cw)
+		w.wg.Add(1)
+		go w.forward(c.Name(), cw)
+	}
+	go func() {
+		w.wg.Wait()
+		close(w.events)
+	}()
+	return w, nil
+}
+
+func (w *Watcher) forward(cluster string, cw watch.Interface) {
+	defer w.wg.Done()
+	for ev := range cw.ResultChan() {
+		select {
+		case w.events <- Event{Event: ev, Cluster: cluster}:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// ResultChan returns the events of all clusters.
+func (w *Watcher) ResultChan() <-chan Event {
+	return w.events
+}
+
+// Stop stops the watches of all clusters.
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+		for _, cw := range w.watches {
+			cw.Stop()
+		}
+	})
+}
@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	clientnetworkingv1alpha3 "istio.io/client-go/pkg/apis/networking/v1alpha3"
+	istioclient "istio.io/client-go/pkg/clientset/versioned"
+	istiofake "istio.io/client-go/pkg/clientset/versioned/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// fakeClient serves Istio CRs from a fake clientset.
+type fakeClient struct {
+	kube.ExtendedClient
+	istio istioclient.Interface
+}
+
+func (c fakeClient) Istio() istioclient.Interface {
+	return c.istio
+}
+
+func cluster(name string, objects ...runtime.Object) resource.Cluster {
+	return resource.FakeCluster{
+		ExtendedClient: fakeClient{istio: istiofake.NewSimpleClientset(objects...)},
+		NameValue:      name,
+	}
+}
+
+func virtualService(name string) *clientnetworkingv1alpha3.VirtualService {
+	return &clientnetworkingv1alpha3.VirtualService{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+}
+
+func TestGetAndList(t *testing.T) {
+	clusters := resource.Clusters{
+		cluster("cluster-0", virtualService("a"), virtualService("b")),
+		cluster("cluster-1", virtualService("a")),
+	}
+	got, err := GetVirtualService(context.TODO(), clusters, "default", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["cluster-0"].Name != "a" || got["cluster-1"].Name != "a" {
+		t.Fatalf("got %v, want a from each cluster", got)
+	}
+
+	if _, err := GetVirtualService(context.TODO(), clusters, "default", "b"); err == nil {
+		t.Fatal("expected an error for the VirtualService missing from cluster-1")
+	}
+
+	lists, err := ListVirtualServices(context.TODO(), clusters, "default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists["cluster-0"]) != 2 || len(lists["cluster-1"]) != 1 {
+		t.Fatalf("got %v, want 2 VirtualServices in cluster-0 and 1 in cluster-1", lists)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	clusters := resource.Clusters{cluster("cluster-0"), cluster("cluster-1")}
+	w, err := WatchVirtualServices(context.TODO(), clusters, "default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	for _, c := range clusters {
+		if _, err := VirtualServices(c, "default").Create(context.TODO(), virtualService("a"),
+			metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := map[string]bool{}
+	for len(seen) < len(clusters) {
+		select {
+		case ev := <-w.ResultChan():
+			if ev.Type != watch.Added || ev.Object.(*clientnetworkingv1alpha3.VirtualService).Name != "a" {
+				t.Fatalf("unexpected event %v", ev)
+			}
+			seen[ev.Cluster] = true
+		case <-time.After(10 * time.Second):
+			t.Fatalf("got events of %v, want those of each cluster", seen)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	fakes := map[string]*watch.FakeWatcher{"cluster-0": watch.NewFake(), "cluster-1": watch.NewFake()}
+	w, err := watchClusters(resource.Clusters{cluster("cluster-0"), cluster("cluster-1")},
+		func(c resource.Cluster) (watch.Interface, error) {
+			return fakes[c.Name()], nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go fakes["cluster-1"].Add(virtualService("a"))
+	if ev := <-w.ResultChan(); ev.Cluster != "cluster-1" || ev.Type != watch.Added {
+		t.Fatalf("unexpected event %v", ev)
+	}
+
+	w.Stop()
+	if _, ok := <-w.ResultChan(); ok {
+		t.Fatal("expected the events to be closed once stopped")
+	}
+	for name, f := range fakes {
+		if !f.IsStopped() {
+			t.Fatalf("watch of %s not stopped", name)
+		}
+	}
+}
+
+func TestWatcherFails(t *testing.T) {
+	started := watch.NewFake()
+	_, err := watchClusters(resource.Clusters{cluster("cluster-0"), cluster("cluster-1")},
+		func(c resource.Cluster) (watch.Interface, error) {
+			if c.Name() == "cluster-1" {
+				return nil, errors.New("forbidden")
+			}
+			return started, nil
+		})
+	if err == nil {
+		t.Fatal("expected the failed watch to be reported")
+	}
+	if !started.IsStopped() {
+		t.Fatal("expected the watch already started to be stopped")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Tool to generate pkg/test/framework/crs/crs.gen.go
+// Example run command:
+// REPO_ROOT=`pwd` go generate ./pkg/test/framework/crs/...
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"text/template"
+)
+
+// TypeData is data struct to feed to crs.go template.
+type TypeData struct {
+	// Kind of the CR, such as VirtualService.
+	Kind string
+	// Plural of the kind in client-go, such as VirtualServices.
+	Plural string
+	// ClientGroupPath is the accessor of the group version in client-go, such as NetworkingV1alpha3.
+	ClientGroupPath string
+	// ClientImport is the import alias of the package of the types in client-go.
+	ClientImport string
+	// TypedImport is the import alias of the package of the typed clients in client-go.
+	TypedImport string
+}
+
+func networking(kind, plural string) TypeData {
+	return TypeData{
+		Kind:            kind,
+		Plural:          plural,
+		ClientGroupPath: "NetworkingV1alpha3",
+		ClientImport:    "clientnetworkingv1alpha3",
+		TypedImport:     "typednetworkingv1alpha3",
+	}
+}
+
+func security(kind, plural string) TypeData {
+	return TypeData{
+		Kind:            kind,
+		Plural:          plural,
+		ClientGroupPath: "SecurityV1beta1",
+		ClientImport:    "clientsecurityv1beta1",
+		TypedImport:     "typedsecurityv1beta1",
+	}
+}
+
+// types are the Istio CRs to generate accessors for.
+var types = []TypeData{
+	networking("DestinationRule", "DestinationRules"),
+	networking("EnvoyFilter", "EnvoyFilters"),
+	networking("Gateway", "Gateways"),
+	networking("ServiceEntry", "ServiceEntries"),
+	networking("Sidecar", "Sidecars"),
+	networking("VirtualService", "VirtualServices"),
+	networking("WorkloadEntry", "WorkloadEntries"),
+	networking("WorkloadGroup", "WorkloadGroups"),
+	security("AuthorizationPolicy", "AuthorizationPolicies"),
+	security("PeerAuthentication", "PeerAuthentications"),
+	security("RequestAuthentication", "RequestAuthentications"),
+}
+
+func main() {
+	templateFile := flag.String("template", "crs.go.tmpl", "Template file")
+	outputFile := flag.String("output", "", "Output file. Leave blank to go to stdout")
+	flag.Parse()
+
+	tmpl := template.Must(template.ParseFiles(*templateFile))
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, types); err != nil {
+		log.Fatal(fmt.Errorf("template: %v", err))
+	}
+
+	// Format source code.
+	out, err := format.Source(buffer.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Output
+	if outputFile == nil || *outputFile == "" {
+		fmt.Println(string(out))
+	} else if err := ioutil.WriteFile(*outputFile, out, 0644); err != nil {
+		panic(err)
+	}
+}
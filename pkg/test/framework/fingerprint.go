@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	goruntime "runtime"
+	"sort"
+	"strings"
+)
+
+// fingerprintPrefix prefixes the fingerprint of a failed test in its output, so that it is part of the failure
+// reported in JUnit.
+const fingerprintPrefix = "FAILURE FINGERPRINT: "
+
+// volatile matches the parts of failure messages that vary between runs of the same failure, each replaced by its
+// placeholder, in order.
+var volatile = []struct {
+	re          *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}T[0-9:.]+(Z|[+-]\d{2}:\d{2})?`), "<time>"},
+	{regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`), "<uuid>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\[?[0-9a-fA-F]*:[0-9a-fA-F:]*:[0-9a-fA-F]+\]?(:\d+)?`), "<ip>"},
+	// Generated names, such as those of namespaces (echo-1-12345) and pods (a-v1-5d8f7b9c4-x7k2p).
+	{regexp.MustCompile(`\b([a-z0-9]+(-[a-z0-9]+)*?)-[0-9]{3,}\b`), "$1-<n>"},
+	{regexp.MustCompile(`-[0-9a-f]{8,10}-[0-9a-z]{5}\b`), "-<pod>"},
+	{regexp.MustCompile(`\b[0-9a-f]{12,}\b`), "<hex>"},
+	{regexp.MustCompile(`\b\d+(\.\d+)?(ns|µs|us|ms|s|m|h)\b`), "<duration>"},
+	{regexp.MustCompile(`\b\d+\b`), "<n>"},
+}
+
+// signature returns the normalized form of a failure message, with the parts that vary between runs replaced.
+// Only its first line is kept, as later lines are typically dumps of responses or resources.
+func signature(msg string) string {
+	msg = strings.TrimSpace(msg)
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	for _, v := range volatile {
+		msg = v.re.ReplaceAllString(msg, v.placeholder)
+	}
+	return msg
+}
+
+// failure is a failure message of a test, with the component it was raised in.
+type failure struct {
+	message string
+	// component is the framework component, such as "echo", or else the package of the test, the failure was raised
+	// in.
+	component string
+}
+
+const (
+	testPackages      = "istio.io/istio/pkg/test/"
+	componentPackages = testPackages + "framework/components/"
+)
+
+// callerComponent returns the component of the stack of the caller: the first framework component in it, or else
+// the first package outside of the test libraries, which is that of the test.
+func callerComponent() string {
+	pcs := make([]uintptr, 64)
+	frames := goruntime.CallersFrames(pcs[:goruntime.Callers(2, pcs)])
+	caller := ""
+	for {
+		f, more := frames.Next()
+		pkg := funcPackage(f.Function)
+		if strings.HasPrefix(pkg, componentPackages) {
+			return strings.SplitN(strings.TrimPrefix(pkg, componentPackages), "/", 2)[0]
+		}
+		if caller == "" && strings.Contains(pkg, "/") && !strings.HasPrefix(pkg, testPackages) {
+			caller = pkg
+		}
+		if !more {
+			return caller
+		}
+	}
+}
+
+// funcPackage returns the package of a function name, such as "istio.io/istio/pkg/test/framework" for
+// "istio.io/istio/pkg/test/framework.(*testContext).Fatal".
+func funcPackage(name string) string {
+	slash := strings.LastIndexByte(name, '/') + 1
+	if dot := strings.IndexByte(name[slash:], '.'); dot >= 0 {
+		return name[:slash+dot]
+	}
+	return name
+}
+
+// fingerprint returns a stable hash of a failure, made of the name of the test, the component the failure was raised
+// in, the signature of its message, and the clusters that message names, so that identical failures can be clustered
+// across runs.
+func fingerprint(test string, f failure, clusters []string) string {
+	var named []string
+	for _, c := range clusters {
+		if c != "" && strings.Contains(f.message, c) {
+			named = append(named, c)
+		}
+	}
+	sort.Strings(named)
+	h := sha256.New()
+	for _, part := range []string{test, f.component, signature(f.message), strings.Join(named, ",")} {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
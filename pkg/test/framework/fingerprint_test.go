@@ -0,0 +1,58 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSignature(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(signature("call to a-v1-5d8f7b9c4-x7k2p.echo-1-12345 (10.1.2.3:8080) failed after 3.5s\nbody")).
+		To(Equal("call to a-v1-<pod>.echo-<n>-<n> (<ip>) failed after <duration>"))
+}
+
+func TestFingerprint(t *testing.T) {
+	g := NewWithT(t)
+	clusters := []string{"primary", "remote"}
+	failed := func(component, msg string) failure {
+		return failure{message: msg, component: component}
+	}
+	a := fingerprint("TestT", failed("echo", "call in primary to echo-1-123 failed: 503 after 2s"), clusters)
+	b := fingerprint("TestT", failed("echo", "call in primary to echo-2-456 failed: 503 after 5s"), clusters)
+	g.Expect(a).To(Equal(b))
+	g.Expect(a).To(HaveLen(16))
+	g.Expect(fingerprint("TestT", failed("echo", "call in remote to echo-1-123 failed: 503 after 2s"), clusters)).
+		NotTo(Equal(a))
+	g.Expect(fingerprint("TestOther", failed("echo", "call in primary to echo-1-123 failed: 503 after 2s"), clusters)).
+		NotTo(Equal(a))
+	g.Expect(fingerprint("TestT", failed("istio", "call in primary to echo-1-123 failed: 503 after 2s"), clusters)).
+		NotTo(Equal(a))
+	// Failures raised through the raw *testing.T have no message, but are still fingerprinted.
+	g.Expect(fingerprint("TestT", failed("istio.io/istio/tests/integration/pilot", ""), clusters)).To(HaveLen(16))
+}
+
+func TestFuncPackage(t *testing.T) {
+	g := NewWithT(t)
+	g.Expect(funcPackage("istio.io/istio/pkg/test/framework.(*testContext).Fatal")).
+		To(Equal("istio.io/istio/pkg/test/framework"))
+	g.Expect(funcPackage("istio.io/istio/tests/integration/pilot.TestTraffic.func1.2")).
+		To(Equal("istio.io/istio/tests/integration/pilot"))
+	g.Expect(funcPackage("istio.io/istio/pkg/test/framework/components/echo/kube.(*instance).CallOrFail")).
+		To(Equal("istio.io/istio/pkg/test/framework/components/echo/kube"))
+	g.Expect(funcPackage("testing.tRunner")).To(Equal("testing"))
+}
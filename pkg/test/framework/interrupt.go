@@ -0,0 +1,118 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/scopes"
+)
+
+// interruptCleanupTimeout bounds the cleanup of an interrupted run, as CI systems only grant a grace period between
+// the signal and killing the run.
+const interruptCleanupTimeout = 2 * time.Minute
+
+// handleInterrupts cleans up the suite when the run is interrupted, by Ctrl-C or by CI aborting the job, rather than
+// leaving its namespaces and gateways behind on shared clusters. The namespaces of the run are first annotated as
+// aborted, so that they can be found if cleanup does not complete, then the resources of the suite and of running
+// tests are closed, and the outcome of the suite is written. A second signal exits immediately. The returned function
+// stops handling interrupts.
+func handleInterrupts(s *suiteImpl, ctx *suiteContext) func() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+			return
+		case sig := <-signals:
+			scopes.Framework.Errorf("=== INTERRUPTED: Test Run: '%s' (%v), cleaning up ===", ctx.Settings().TestID, sig)
+			go func() {
+				<-signals
+				scopes.Framework.Errorf("Interrupted again, exiting without cleaning up")
+				os.Exit(exitCodeInterrupted)
+			}()
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			emergencyCleanup(ctx)
+		}()
+		select {
+		case <-done:
+		case <-time.After(interruptCleanupTimeout):
+			scopes.Framework.Errorf("Cleanup of interrupted run timed out after %v", interruptCleanupTimeout)
+		}
+		s.writeOutput()
+		s.osExit(exitCodeInterrupted)
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(stop)
+	}
+}
+
+func emergencyCleanup(ctx *suiteContext) {
+	// Resources kept for debugging are not marked, so that the janitor leaves them until they expire.
+	if ctx.Settings().NoCleanup {
+		return
+	}
+	if err := markAborted(ctx); err != nil {
+		scopes.Framework.Errorf("Error marking namespaces of interrupted run: %v", err)
+	}
+	if err := ctx.globalScope.abort(); err != nil {
+		scopes.Framework.Errorf("Error cleaning up interrupted run: %v", err)
+	}
+	if err := cleanupTenant(ctx); err != nil {
+		scopes.Framework.Errorf("Error cleaning up tenant of interrupted run: %v", err)
+	}
+}
+
+// markAborted annotates the namespaces created by the run with the time it was interrupted.
+func markAborted(ctx resource.Context) error {
+	if ctx.Environment() == nil {
+		return nil
+	}
+	selector := namespace.RunLabel + "=" + ctx.Settings().RunID.String()
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, namespace.AbortedAnnotation,
+		time.Now().UTC().Format(time.RFC3339)))
+	var errs error
+	for _, c := range ctx.Clusters() {
+		list, err := c.CoreV1().Namespaces().List(context.TODO(), kubeApiMeta.ListOptions{LabelSelector: selector})
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed listing namespaces of the run in cluster %s: %v",
+				c.Name(), err))
+			continue
+		}
+		for _, ns := range list.Items {
+			if _, err := c.CoreV1().Namespaces().Patch(context.TODO(), ns.Name, types.MergePatchType, patch,
+				kubeApiMeta.PatchOptions{}); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,169 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	istioKube "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// closingResource records the order resources are closed in.
+type closingResource struct {
+	name   string
+	mu     *sync.Mutex
+	closed *[]string
+	err    error
+}
+
+func (r closingResource) ID() resource.ID {
+	return resource.FakeID(r.name)
+}
+
+func (r closingResource) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.closed = append(*r.closed, r.name)
+	return r.err
+}
+
+// clusterEnvironment is an environment of the given clusters.
+type clusterEnvironment struct {
+	resource.FakeEnvironment
+	clusters resource.Clusters
+}
+
+func (e clusterEnvironment) Clusters() resource.Clusters {
+	return e.clusters
+}
+
+func TestScopeAbort(t *testing.T) {
+	var mu sync.Mutex
+	var closed []string
+	add := func(s *scope, name string, err error) {
+		s.add(closingResource{name: name, mu: &mu, closed: &closed, err: err}, &resourceID{id: name})
+	}
+	suite := newScope("suite", nil)
+	add(suite, "suite-1", nil)
+	add(suite, "suite-2", nil)
+	test := newScope("test", suite)
+	add(test, "test-1", errors.New("in use"))
+	add(test, "test-2", nil)
+
+	if err := suite.abort(); err == nil {
+		t.Fatal("expected the error of the resource that failed to close")
+	}
+	want := []string{"test-2", "test-1", "suite-2", "suite-1"}
+	if !reflect.DeepEqual(closed, want) {
+		t.Fatalf("got resources closed in order %v, want %v", closed, want)
+	}
+
+	// Resources are closed once, even if the scope is aborted again or done afterwards.
+	closed = nil
+	if err := suite.abort(); err != nil {
+		t.Fatal(err)
+	}
+	if len(closed) != 0 {
+		t.Fatalf("got resources closed again: %v", closed)
+	}
+}
+
+func TestEmergencyCleanup(t *testing.T) {
+	for _, noCleanup := range []bool{false, true} {
+		settings := resource.DefaultSettings()
+		settings.NoCleanup = noCleanup
+		runNamespace := &kubeApiCore.Namespace{ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:   "echo-1",
+			Labels: map[string]string{namespace.RunLabel: settings.RunID.String()},
+		}}
+		otherNamespace := &kubeApiCore.Namespace{ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:   "echo-2",
+			Labels: map[string]string{namespace.RunLabel: "other-run"},
+		}}
+		cluster := resource.FakeCluster{
+			ExtendedClient: istioKube.NewFakeClient(runNamespace, otherNamespace).(istioKube.ExtendedClient),
+			NameValue:      "cluster-0",
+		}
+		ctx := &suiteContext{
+			settings:    settings,
+			environment: clusterEnvironment{clusters: resource.Clusters{cluster}},
+			globalScope: newScope("suite", nil),
+		}
+		var mu sync.Mutex
+		var closed []string
+		ctx.globalScope.add(closingResource{name: "gateway", mu: &mu, closed: &closed}, &resourceID{id: "gateway"})
+
+		emergencyCleanup(ctx)
+
+		aborted := func(name string) bool {
+			ns, err := cluster.CoreV1().Namespaces().Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, ok := ns.Annotations[namespace.AbortedAnnotation]
+			return ok
+		}
+		if got := aborted("echo-1"); got == noCleanup {
+			t.Errorf("with noCleanup %v, got namespace of the run marked aborted: %v", noCleanup, got)
+		}
+		if aborted("echo-2") {
+			t.Errorf("namespace of another run was marked aborted")
+		}
+		if got := len(closed) == 1; got == noCleanup {
+			t.Errorf("with noCleanup %v, got resources closed: %v", noCleanup, closed)
+		}
+	}
+}
+
+func TestHandleInterrupts(t *testing.T) {
+	defer func(artifacts string) { _ = os.Setenv("ARTIFACTS", artifacts) }(os.Getenv("ARTIFACTS"))
+	_ = os.Unsetenv("ARTIFACTS")
+
+	exit := make(chan int, 1)
+	s := newTestSuite("tid", nil, func(code int) { exit <- code }, defaultSettingsFn)
+	settings := resource.DefaultSettings()
+	// Nothing is cleaned up, so that only the handling of the signal is covered.
+	settings.NoCleanup = true
+	ctx := &suiteContext{settings: settings, globalScope: newScope("suite", nil)}
+
+	stop := handleInterrupts(s, ctx)
+	defer stop()
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case code := <-exit:
+		if code != exitCodeInterrupted {
+			t.Fatalf("got exit code %d, want %d", code, exitCodeInterrupted)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the interrupted run did not exit")
+	}
+}
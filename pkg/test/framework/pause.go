@@ -0,0 +1,128 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+)
+
+// pauseMu serializes pauses of tests running in parallel, so that their instructions do not interleave.
+var pauseMu sync.Mutex
+
+var (
+	pauseInputOnce sync.Once
+	// pauseInput receives a value each time the user presses enter. It is fed by a single reader for the lifetime of
+	// the process, so that a pause that times out does not leave a reader behind that would consume the next input.
+	pauseInput chan struct{}
+)
+
+// pauseOnFailure halts the failed test before its resources are cleaned up, printing how to inspect them, until the
+// user presses enter or the pause times out. Input is read from the terminal, since go test does not always connect
+// the test binary to stdin; output is only streamed when go test runs a single package or with -v.
+func pauseOnFailure(ctx *testContext, testName string) {
+	settings := ctx.Settings()
+	if !settings.PauseOnFailure {
+		return
+	}
+	pauseMu.Lock()
+	defer pauseMu.Unlock()
+
+	input := readPauseInput()
+	// Discard input given while no test was paused.
+	select {
+	case <-input:
+	default:
+	}
+	fmt.Fprint(os.Stdout, pauseInstructions(ctx, testName, settings.PauseTimeout))
+
+	select {
+	case <-input:
+		fmt.Fprintf(os.Stdout, "Resuming %s\n", testName)
+	case <-time.After(settings.PauseTimeout):
+		fmt.Fprintf(os.Stdout, "Pause of %s timed out after %v, resuming\n", testName, settings.PauseTimeout)
+	}
+}
+
+// readPauseInput starts reading lines from the terminal, or from stdin if there is none, on first use.
+func readPauseInput() <-chan struct{} {
+	pauseInputOnce.Do(func() {
+		pauseInput = make(chan struct{})
+		input := io.Reader(os.Stdin)
+		if tty, err := os.Open("/dev/tty"); err == nil {
+			input = tty
+		}
+		go func() {
+			r := bufio.NewReader(input)
+			for {
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+				pauseInput <- struct{}{}
+			}
+		}()
+	})
+	return pauseInput
+}
+
+func pauseInstructions(ctx *testContext, testName string, timeout time.Duration) string {
+	namespaces := ctx.scope.namespaces()
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "\n=== PAUSED: Test '%s' failed ===\n", testName)
+	fmt.Fprintf(out, "Its resources are left in place for inspection.\n\n")
+	fmt.Fprintf(out, "Clusters:\n")
+	for _, c := range ctx.Clusters() {
+		if kc, ok := c.(kube.Cluster); ok {
+			fmt.Fprintf(out, "  %s: kubectl --kubeconfig=%s\n", c.Name(), kc.Filename())
+		} else {
+			fmt.Fprintf(out, "  %s\n", c.Name())
+		}
+	}
+	fmt.Fprintf(out, "Namespaces:\n")
+	for _, ns := range namespaces {
+		fmt.Fprintf(out, "  %s\n", ns)
+	}
+	if len(namespaces) > 0 && len(ctx.Clusters()) > 0 {
+		if kc, ok := ctx.Clusters()[0].(kube.Cluster); ok {
+			fmt.Fprintf(out, "\nFor example:\n  kubectl --kubeconfig=%s -n %s get pods\n", kc.Filename(), namespaces[0])
+			fmt.Fprintf(out, "  istioctl --kubeconfig=%s proxy-status\n", kc.Filename())
+		}
+	}
+	fmt.Fprintf(out, "\nPress enter to clean up and continue, or wait %v.\n", timeout)
+	return out.String()
+}
+
+// namespaces returns the names of the namespaces tracked by the scope and its parents.
+func (s *scope) namespaces() []string {
+	var out []string
+	for cur := s; cur != nil; cur = cur.parent {
+		cur.mu.Lock()
+		for _, r := range cur.resources {
+			if ns, ok := r.(namespace.Instance); ok {
+				out = append(out, ns.Name())
+			}
+		}
+		cur.mu.Unlock()
+	}
+	return out
+}
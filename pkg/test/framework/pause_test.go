@@ -0,0 +1,105 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// trackedNamespace is a namespace that can be tracked by a scope.
+type trackedNamespace struct {
+	namespace.Fake
+}
+
+func (n trackedNamespace) ID() resource.ID {
+	return resource.FakeID(n.NameValue)
+}
+
+func newPauseContext(settings *resource.Settings) *testContext {
+	suite := &suiteContext{
+		settings:    settings,
+		environment: resource.FakeEnvironment{NumClusters: 1},
+		globalScope: newScope("suite", nil),
+	}
+	suite.globalScope.add(trackedNamespace{namespace.Fake{NameValue: "suite-ns"}}, &resourceID{id: "suite-ns"})
+	ctx := &testContext{suite: suite, scope: newScope("test", suite.globalScope)}
+	ctx.scope.add(&resource.FakeResource{IDValue: "other"}, &resourceID{id: "other"})
+	ctx.scope.add(trackedNamespace{namespace.Fake{NameValue: "test-ns"}}, &resourceID{id: "test-ns"})
+	return ctx
+}
+
+func TestPauseInstructions(t *testing.T) {
+	ctx := newPauseContext(resource.DefaultSettings())
+	if got := ctx.scope.namespaces(); len(got) != 2 || got[0] != "test-ns" || got[1] != "suite-ns" {
+		t.Fatalf("got namespaces %v, want those of the test and then of the suite", got)
+	}
+
+	out := pauseInstructions(ctx, "TestFailing", time.Minute)
+	for _, want := range []string{"Test 'TestFailing' failed", "  test-ns\n", "  suite-ns\n", "wait 1m0s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("instructions do not contain %q:\n%s", want, out)
+		}
+	}
+	// There is no kubeconfig to give commands for outside of Kubernetes environments.
+	if strings.Contains(out, "kubectl") {
+		t.Errorf("got kubectl instructions without a Kubernetes cluster:\n%s", out)
+	}
+}
+
+func TestPauseOnFailure(t *testing.T) {
+	// Feed the pause from the test rather than from the terminal.
+	pauseInputOnce.Do(func() {
+		pauseInput = make(chan struct{})
+	})
+	settings := resource.DefaultSettings()
+	ctx := newPauseContext(settings)
+
+	t.Run("disabled", func(t *testing.T) {
+		start := time.Now()
+		pauseOnFailure(ctx, t.Name())
+		if time.Since(start) > time.Second {
+			t.Fatal("paused while pausing on failure is disabled")
+		}
+	})
+
+	settings.PauseOnFailure = true
+
+	t.Run("timeout", func(t *testing.T) {
+		settings.PauseTimeout = 10 * time.Millisecond
+		pauseOnFailure(ctx, t.Name())
+	})
+
+	t.Run("input", func(t *testing.T) {
+		settings.PauseTimeout = time.Hour
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			// Input given before the pause is discarded, so it is given until the pause ends.
+			for {
+				select {
+				case pauseInput <- struct{}{}:
+				case <-done:
+					return
+				}
+			}
+		}()
+		pauseOnFailure(ctx, t.Name())
+	})
+}
@@ -0,0 +1,94 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// ComponentFactory creates a component. As with the built-in components, the factory tracks the component with
+// ctx.TrackResource, which allocates its ID, so that it is closed when the context it was created in is done if it
+// implements io.Closer, and dumped when a test fails if it implements resource.Dumper.
+type ComponentFactory func(ctx resource.Context) (resource.Resource, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]ComponentFactory{}
+)
+
+// RegisterComponent registers a component under the given name, so that suites create it with SetupComponent or
+// tests with NewComponent, and any suite of a run creates it when named by --istio.test.components. This allows
+// repositories other than Istio, such as those of vendors or extensions, to add components, for example for their
+// own CRDs or gateways, without changing the framework. It is meant to be called from init, and panics if the name
+// is already registered.
+func RegisterComponent(name string, f ComponentFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("component %q is already registered", name))
+	}
+	registry[name] = f
+}
+
+// RegisteredComponents returns the names of the registered components, sorted.
+func RegisteredComponents() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]string, 0, len(registry))
+	for name := range registry {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NewComponent creates the registered component of the given name. The created component is retrieved with its
+// type from the context, or its children, with GetResource.
+func NewComponent(ctx resource.Context, name string) (resource.Resource, error) {
+	registryMu.RLock()
+	f, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no component named %q is registered, expected one of %v", name, RegisteredComponents())
+	}
+	r, err := f(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating component %q: %v", name, err)
+	}
+	return r, nil
+}
+
+// NewComponentOrFail calls NewComponent and fails the test if it returns an error.
+func NewComponentOrFail(t test.Failer, ctx resource.Context, name string) resource.Resource {
+	t.Helper()
+	r, err := NewComponent(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// SetupComponent returns a setup function that creates the registered component of the given name for the suite.
+func SetupComponent(name string) resource.SetupFn {
+	return func(ctx resource.Context) error {
+		_, err := NewComponent(ctx, name)
+		return err
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// registerTestComponent registers a component creating and tracking a fake resource, for the duration of the test.
+func registerTestComponent(t *testing.T, name string, err error) *int {
+	created := 0
+	RegisterComponent(name, func(ctx resource.Context) (resource.Resource, error) {
+		if err != nil {
+			return nil, err
+		}
+		created++
+		r := &resource.FakeResource{IDValue: name, OtherValue: name}
+		ctx.TrackResource(r)
+		return r, nil
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		delete(registry, name)
+	})
+	return &created
+}
+
+func TestRegisterComponent(t *testing.T) {
+	g := NewWithT(t)
+	registerTestComponent(t, "test-b", nil)
+	registerTestComponent(t, "test-a", nil)
+
+	g.Expect(RegisteredComponents()).To(ContainElements("test-a", "test-b"))
+	g.Expect(func() { RegisterComponent("test-a", nil) }).To(Panic())
+}
+
+func TestNewComponent(t *testing.T) {
+	defer cleanupRT()
+	g := NewWithT(t)
+	created := registerTestComponent(t, "test-component", nil)
+	registerTestComponent(t, "test-failing", errors.New("no CRD"))
+
+	var (
+		tracked    OtherInterface
+		getErr     error
+		unknownErr error
+		failingErr error
+	)
+	runFn := func(ctx *suiteContext) int {
+		getErr = ctx.GetResource(&tracked)
+		_, unknownErr = NewComponent(ctx, "test-unknown")
+		_, failingErr = NewComponent(ctx, "test-failing")
+		return 0
+	}
+	s := newTestSuite("tid", runFn, defaultExitFn, defaultSettingsFn)
+	s.Setup(SetupComponent("test-component"))
+	s.Run()
+
+	g.Expect(*created).To(Equal(1))
+	g.Expect(getErr).To(BeNil())
+	g.Expect(tracked.GetOtherValue()).To(Equal("test-component"))
+	g.Expect(unknownErr).To(MatchError(ContainSubstring(`no component named "test-unknown"`)))
+	g.Expect(failingErr).To(MatchError(`failed creating component "test-failing": no CRD`))
+}
+
+func TestSuite_Components(t *testing.T) {
+	defer cleanupRT()
+	g := NewWithT(t)
+	created := registerTestComponent(t, "test-flag-component", nil)
+
+	var setupCreated int
+	settings := resource.DefaultSettings()
+	settings.Components = []string{"test-flag-component"}
+	s := newTestSuite("tid", func(*suiteContext) int { return 0 }, defaultExitFn, settingsFn(settings))
+	s.Setup(func(resource.Context) error {
+		// Components named on the command line are created after the setup of the suite.
+		setupCreated = *created
+		return nil
+	})
+	s.Run()
+
+	g.Expect(setupCreated).To(Equal(0))
+	g.Expect(*created).To(Equal(1))
+}
@@ -39,8 +39,22 @@ type ConfigManager interface {
 	// DeleteYAMLDir recursively deletes all the config files in the specified directory
 	DeleteYAMLDir(ns string, configDir string) error
 
+	// ApplyKustomize builds the kustomization in the specified directory, and applies the result. A cluster for
+	// which the directory has an overlay, a kustomization in overlays/<cluster name>, gets the overlay instead. As
+	// kustomize rejects bases containing the kustomization that uses them, config shared by the kustomization and its
+	// overlays belongs in a base directory next to overlays, such as base/.
+	ApplyKustomize(ns string, dir string) error
+
+	// DeleteKustomize deletes the config applied by ApplyKustomize for the specified directory.
+	DeleteKustomize(ns string, dir string) error
+
 	// WithFilePrefix sets the prefix used for intermediate files.
 	WithFilePrefix(prefix string) ConfigManager
+
+	// WaitForDistribution returns a ConfigManager that, after applying config, blocks until every Istio resource
+	// reports that it has been distributed to all proxies in each of the target clusters. This relies on the
+	// distribution status written by istiod, which must be enabled (PILOT_ENABLE_STATUS=true).
+	WaitForDistribution() ConfigManager
 }
 
 // Context is the core context interface that is used by resources.
@@ -14,9 +14,66 @@
 
 package resource
 
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
 // EnvironmentFactory creates an Environment.
 type EnvironmentFactory func(ctx Context) (Environment, error)
 
+// DefaultEnvironment is the name of the environment tests run in unless --istio.test.env names another.
+const DefaultEnvironment = "kube"
+
+var (
+	environmentsMu sync.RWMutex
+	environments   = map[string]EnvironmentFactory{}
+)
+
+// RegisterEnvironment registers the factory of an environment under the given name, selected with
+// --istio.test.env. This allows environments other than Kubernetes, such as VMs run with docker-compose or a mesh
+// of local processes, to be provided by repositories other than Istio.
+//
+// Only the selection of the environment is pluggable: Cluster remains a Kubernetes client, which the clusters of
+// other environments must provide, for example backed by the API server their mesh is configured with. The
+// framework itself learns what an environment supports through the optional interfaces below, such as
+// LoadBalancerEnvironment. Components and tests that need the Kubernetes environment itself, such as the istio
+// component, resolve it with kube.EnvironmentFrom, failing rather than assuming it.
+//
+// It is meant to be called from init, and panics if the name is already registered.
+func RegisterEnvironment(name string, f EnvironmentFactory) {
+	environmentsMu.Lock()
+	defer environmentsMu.Unlock()
+	if _, ok := environments[name]; ok {
+		panic(fmt.Sprintf("environment %q is already registered", name))
+	}
+	environments[name] = f
+}
+
+// EnvironmentNames returns the names of the registered environments, sorted.
+func EnvironmentNames() []string {
+	environmentsMu.RLock()
+	defer environmentsMu.RUnlock()
+	out := make([]string, 0, len(environments))
+	for name := range environments {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// GetEnvironmentFactory returns the factory of the registered environment of the given name.
+func GetEnvironmentFactory(name string) (EnvironmentFactory, error) {
+	environmentsMu.RLock()
+	f, ok := environments[name]
+	environmentsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown environment %q, expected one of %v", name, EnvironmentNames())
+	}
+	return f, nil
+}
+
 var _ EnvironmentFactory = NilEnvironmentFactory
 
 // NilEnvironmentFactory is an EnvironmentFactory that returns nil.
@@ -36,6 +93,34 @@ type Environment interface {
 	IsMultinetwork() bool
 }
 
+// LoadBalancerEnvironment is implemented by environments that know whether they provision services of type
+// LoadBalancer. Others are assumed to.
+type LoadBalancerEnvironment interface {
+	LoadBalancerSupported() bool
+}
+
+// NodeAccessEnvironment is implemented by environments that know whether tests may access the nodes of the clusters,
+// for example to run privileged pods on them. Others are assumed to allow it.
+type NodeAccessEnvironment interface {
+	NodeAccessSupported() bool
+}
+
+// LoadBalancerSupported returns whether the environment provisions services of type LoadBalancer.
+func LoadBalancerSupported(e Environment) bool {
+	if l, ok := e.(LoadBalancerEnvironment); ok {
+		return l.LoadBalancerSupported()
+	}
+	return true
+}
+
+// NodeAccessSupported returns whether tests may access the nodes of the clusters of the environment.
+func NodeAccessSupported(e Environment) bool {
+	if n, ok := e.(NodeAccessEnvironment); ok {
+		return n.NodeAccessSupported()
+	}
+	return true
+}
+
 var _ Environment = FakeEnvironment{}
 
 // FakeEnvironment for testing.
@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRegisterEnvironment(t *testing.T) {
+	fake := func(Context) (Environment, error) {
+		return FakeEnvironment{Name: "compose"}, nil
+	}
+	RegisterEnvironment("test-compose", fake)
+	RegisterEnvironment("test-process", NilEnvironmentFactory)
+
+	names := EnvironmentNames()
+	for _, name := range []string{"test-compose", "test-process"} {
+		found := false
+		for _, n := range names {
+			found = found || n == name
+		}
+		if !found {
+			t.Fatalf("expected %q in the registered environments %v", name, names)
+		}
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Fatalf("expected the environment names to be sorted, got %v", names)
+	}
+
+	f, err := GetEnvironmentFactory("test-compose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := f(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.EnvironmentName() != "compose" {
+		t.Fatalf("expected the registered factory, got environment %q", env.EnvironmentName())
+	}
+
+	if _, err := GetEnvironmentFactory("test-unknown"); err == nil {
+		t.Fatal("expected an error for an unregistered environment")
+	}
+}
+
+func TestRegisterEnvironmentTwice(t *testing.T) {
+	RegisterEnvironment("test-twice", NilEnvironmentFactory)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected registering an environment twice to panic")
+		}
+	}()
+	RegisterEnvironment("test-twice", NilEnvironmentFactory)
+}
+
+type capableEnvironment struct {
+	FakeEnvironment
+	loadBalancer bool
+	nodeAccess   bool
+}
+
+func (e capableEnvironment) LoadBalancerSupported() bool {
+	return e.loadBalancer
+}
+
+func (e capableEnvironment) NodeAccessSupported() bool {
+	return e.nodeAccess
+}
+
+func TestEnvironmentCapabilities(t *testing.T) {
+	cases := []struct {
+		name string
+		env  Environment
+		want []bool
+	}{
+		{"unknown capabilities", FakeEnvironment{}, []bool{true, true}},
+		{"none", capableEnvironment{}, []bool{false, false}},
+		{"load balancer", capableEnvironment{loadBalancer: true}, []bool{true, false}},
+		{"node access", capableEnvironment{nodeAccess: true}, []bool{false, true}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := []bool{LoadBalancerSupported(tc.env), NodeAccessSupported(tc.env)}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got load balancer and node access %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -18,14 +18,22 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	"istio.io/istio/pkg/test/framework/label"
 )
 
 var (
 	settingsFromCommandLine = DefaultSettings()
+
+	components string
 )
 
+// maxTenantLength leaves room in namespace and revision names for the names they are prefixed to.
+const maxTenantLength = 20
+
 // SettingsFromCommandLine returns settings obtained from command-line flags. flag.Parse must be called before
 // calling this function.
 func SettingsFromCommandLine(testID string) (*Settings, error) {
@@ -33,6 +41,10 @@ func SettingsFromCommandLine(testID string) (*Settings, error) {
 		panic("flag.Parse must be called before this function")
 	}
 
+	if err := applyProfile(flag.CommandLine, settingsFromCommandLine.Profile); err != nil {
+		return nil, err
+	}
+
 	s := settingsFromCommandLine.Clone()
 	s.TestID = testID
 
@@ -42,12 +54,38 @@ func SettingsFromCommandLine(testID string) (*Settings, error) {
 	}
 	s.Selector = f
 
+	for _, c := range strings.Split(components, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			s.Components = append(s.Components, c)
+		}
+	}
+
 	if s.FailOnDeprecation && s.NoCleanup {
 		return nil,
 			fmt.Errorf("checking for deprecation occurs at cleanup level, thus flags -istio.test.nocleanup and" +
 				" -istio.test.deprecation_failure must not be used at the same time")
 	}
 
+	if s.PauseOnFailure && s.CIMode {
+		return nil, fmt.Errorf("-istio.test.pauseOnFailure is meant for local debugging and must not be used with -istio.test.ci")
+	}
+
+	if s.CheckClusterState && (s.NoCleanup || s.Tenant != "") {
+		return nil, fmt.Errorf("-istio.test.checkClusterState must not be used with -istio.test.nocleanup, " +
+			"nor with -istio.test.tenant, whose concurrent runs change the clusters")
+	}
+
+	if s.TestTimeout < 0 {
+		return nil, fmt.Errorf("-istio.test.testTimeout must not be negative, got %v", s.TestTimeout)
+	}
+
+	if s.Tenant != "" {
+		if errs := validation.IsDNS1123Label(s.Tenant); len(errs) > 0 || len(s.Tenant) > maxTenantLength {
+			return nil, fmt.Errorf("invalid tenant %q: must be a DNS label of at most %d characters",
+				s.Tenant, maxTenantLength)
+		}
+	}
+
 	return s, nil
 }
 
@@ -56,8 +94,9 @@ func init() {
 	flag.StringVar(&settingsFromCommandLine.BaseDir, "istio.test.work_dir", os.TempDir(),
 		"Local working directory for creating logs/temp files. If left empty, os.TempDir() is used.")
 
-	var env string
-	flag.StringVar(&env, "istio.test.env", "", "Deprecated. This flag does nothing")
+	flag.StringVar(&settingsFromCommandLine.Environment, "istio.test.env", settingsFromCommandLine.Environment,
+		"Name of the registered environment the tests run in. Environments other than "+DefaultEnvironment+
+			" are registered by the packages providing them, which must be imported by the tests.")
 
 	flag.BoolVar(&settingsFromCommandLine.NoCleanup, "istio.test.nocleanup", settingsFromCommandLine.NoCleanup,
 		"Do not cleanup resources after test completion")
@@ -74,6 +113,55 @@ func init() {
 	flag.BoolVar(&settingsFromCommandLine.StableNamespaces, "istio.test.stableNamespaces", settingsFromCommandLine.StableNamespaces,
 		"If set, will use consistent namespace rather than randomly generated. Useful with nocleanup to develop tests.")
 
+	flag.BoolVar(&settingsFromCommandLine.PodSecurityRestricted, "istio.test.pod_security_restricted",
+		settingsFromCommandLine.PodSecurityRestricted,
+		"If set, test namespaces enforce the restricted PodSecurity profile. Requires the Istio CNI plugin for sidecars.")
+
+	flag.BoolVar(&settingsFromCommandLine.Watchdog, "istio.test.watchdog", settingsFromCommandLine.Watchdog,
+		"If set, tests fail when istiod, gateway or echo containers restart while they run.")
+
+	flag.BoolVar(&settingsFromCommandLine.BugReport, "istio.test.bugReport", settingsFromCommandLine.BugReport,
+		"If set, an istioctl bug-report of each cluster is added to the artifacts when the suite fails.")
+
+	flag.BoolVar(&settingsFromCommandLine.AuditAPI, "istio.test.auditAPI", settingsFromCommandLine.AuditAPI,
+		"If set, the Kubernetes API calls of the framework are recorded, summarized in the work dir of each test, "+
+			"and written to api-audit.yaml in the run dir of the suite.")
+
+	flag.BoolVar(&settingsFromCommandLine.CheckClusterState, "istio.test.checkClusterState", settingsFromCommandLine.CheckClusterState,
+		"If set, the suite fails if the CRDs, webhooks and cluster-scoped Istio resources of the clusters differ "+
+			"after it is cleaned up from before it was set up. Not meaningful on clusters shared by concurrent runs.")
+
+	flag.StringVar(&settingsFromCommandLine.Tenant, "istio.test.tenant", settingsFromCommandLine.Tenant,
+		"If set, the run shares its clusters with other tenants, isolating its namespaces, Istio revision and Istio "+
+			"config by this name. Service discovery is not isolated.")
+
+	flag.BoolVar(&settingsFromCommandLine.PauseOnFailure, "istio.test.pauseOnFailure", settingsFromCommandLine.PauseOnFailure,
+		"If set, a failed test halts before cleanup, printing its clusters and namespaces, until enter is pressed. "+
+			"Meant for local debugging; go test only streams the instructions when run on a single package or with -v.")
+
+	flag.DurationVar(&settingsFromCommandLine.PauseTimeout, "istio.test.pauseTimeout", settingsFromCommandLine.PauseTimeout,
+		"How long a failed test is paused with istio.test.pauseOnFailure before it is cleaned up anyway.")
+
+	flag.DurationVar(&settingsFromCommandLine.TestTimeout, "istio.test.testTimeout", settingsFromCommandLine.TestTimeout,
+		"If set, the deadline of each top-level test, after which it is failed, its state dumped and its resources "+
+			"cleaned up. Should be well below go test -timeout, which aborts the binary without artifacts.")
+
+	flag.StringVar(&settingsFromCommandLine.LogLevels, "istio.test.logLevel", settingsFromCommandLine.LogLevels,
+		"Comma separated list of <scope>:<level> pairs, with scopes named as in --log_output_level "+
+			"(e.g. 'tf:debug,tfecho:info').")
+
+	flag.StringVar(&settingsFromCommandLine.EchoRecordFile, "istio.test.echo.record", settingsFromCommandLine.EchoRecordFile,
+		"If set, every echo call and a summary of its results are appended to this file, for replay against "+
+			"another environment.")
+
+	flag.StringVar(&settingsFromCommandLine.Profile, "istio.test.profile", settingsFromCommandLine.Profile,
+		"Named set of framework flags describing the environment, one of "+strings.Join(ProfileNames(), ", ")+
+			". Flags given on the command line override those of the profile.")
+
+	flag.StringVar(&components, "istio.test.components", components,
+		"Comma separated list of registered components, such as those of vendors, that every suite creates after its "+
+			"own setup.")
+
 	flag.BoolVar(&settingsFromCommandLine.FailOnDeprecation, "istio.test.deprecation_failure", settingsFromCommandLine.FailOnDeprecation,
 		"Make tests fail if any usage of deprecated stuff (e.g. Envoy flags) is detected.")
 }
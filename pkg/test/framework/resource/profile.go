@@ -0,0 +1,83 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// profiles are named sets of framework flags describing a kind of environment. Flags given on the command line take
+// precedence over those of the profile.
+var profiles = map[string]map[string]string{
+	// A single KinD cluster, without MetalLB.
+	"kind-singlecluster": {
+		"istio.test.kube.loadbalancer": "false",
+	},
+	// Two primary clusters, given by the kubeconfig, each on its own network.
+	"multicluster-multinetwork": {
+		"istio.test.kube.loadbalancer":         "true",
+		"istio.test.kube.controlPlaneTopology": "0:0,1:1",
+		"istio.test.kube.networkTopology":      "0:network-1,1:network-2",
+		"istio.test.kube.configTopology":       "0:0,1:1",
+	},
+	// GKE Autopilot clusters, where traffic is redirected by the CNI plugin rather than privileged init containers.
+	// There is no ambient data plane in this tree; this profile covers the constraints of the clusters it runs on.
+	"ambient-gke": {
+		"istio.test.kube.loadbalancer": "true",
+		"istio.test.kube.autopilot":    "true",
+	},
+}
+
+// ProfileNames returns the names of the known profiles.
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the flags of the named profile, other than those already set in the flag set.
+func applyProfile(fs *flag.FlagSet, name string) error {
+	if name == "" {
+		return nil
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(ProfileNames(), ", "))
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	flags := make([]string, 0, len(p))
+	for f := range p {
+		flags = append(flags, f)
+	}
+	sort.Strings(flags)
+	for _, f := range flags {
+		if explicit[f] {
+			continue
+		}
+		if err := fs.Set(f, p[f]); err != nil {
+			return fmt.Errorf("failed applying profile %s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resource
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+// profileFlags returns a flag set with a string flag for each flag used by any profile.
+func profileFlags() *flag.FlagSet {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	for _, p := range profiles {
+		for f := range p {
+			if fs.Lookup(f) == nil {
+				fs.String(f, "", "")
+			}
+		}
+	}
+	return fs
+}
+
+func TestApplyProfile(t *testing.T) {
+	for _, name := range ProfileNames() {
+		t.Run(name, func(t *testing.T) {
+			fs := profileFlags()
+			if err := applyProfile(fs, name); err != nil {
+				t.Fatal(err)
+			}
+			for f, want := range profiles[name] {
+				if got := fs.Lookup(f).Value.String(); got != want {
+					t.Errorf("%s: got %q, want %q", f, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyProfileExplicitFlags(t *testing.T) {
+	fs := profileFlags()
+	if err := fs.Parse([]string{"--istio.test.kube.loadbalancer=false"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyProfile(fs, "ambient-gke"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("istio.test.kube.loadbalancer").Value.String(); got != "false" {
+		t.Errorf("explicit flag was overridden by the profile: got %q", got)
+	}
+	if got := fs.Lookup("istio.test.kube.autopilot").Value.String(); got != "true" {
+		t.Errorf("profile flag was not applied: got %q", got)
+	}
+}
+
+func TestApplyProfileNone(t *testing.T) {
+	fs := profileFlags()
+	if err := applyProfile(fs, ""); err != nil {
+		t.Fatal(err)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Value.String() != "" {
+			t.Errorf("%s was set to %q without a profile", f.Name, f.Value.String())
+		}
+	})
+}
+
+func TestApplyProfileErrors(t *testing.T) {
+	if err := applyProfile(profileFlags(), "unknown"); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+	// The flags of the profile are not registered.
+	if err := applyProfile(flag.NewFlagSet("empty", flag.ContinueOnError), "ambient-gke"); err == nil {
+		t.Error("expected an error for unregistered flags")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -57,12 +58,67 @@ type Settings struct {
 	// This is useful when combined with NoCleanup, to allow quickly iterating on tests.
 	StableNamespaces bool
 
+	// If enabled, test namespaces enforce the Kubernetes "restricted" PodSecurity profile, and the workloads applied
+	// to them through the config manager are made to comply with it. Echo instances listen on unprivileged ports, and those
+	// that need privileges, such as VMs, are rejected.
+	PodSecurityRestricted bool
+
+	// If enabled, framework-owned pods (istiod, gateways and echo workloads) are monitored during the run, and tests
+	// fail if containers restart, are OOMKilled or crash loop while they run.
+	Watchdog bool
+
+	// If enabled, an istioctl bug-report is captured from every cluster into the artifacts when the suite fails.
+	BugReport bool
+
+	// If enabled, the Kubernetes API calls of the framework are recorded, and summarized per test and for the suite.
+	AuditAPI bool
+
+	// If enabled, the CRDs, webhook configurations and cluster-scoped Istio resources of the clusters are recorded
+	// before the suite sets up, and the suite fails if they differ once it is cleaned up.
+	CheckClusterState bool
+
+	// Tenant, if set, lets the run share its clusters with concurrent runs of other tenants. Namespaces are prefixed
+	// and labeled with the tenant, Istio is installed as a revision named after it into its own system namespace, and
+	// the namespaces of the tenant left over by a previous run are deleted before the suite starts. Only Istio config
+	// is isolated, by the revision it is labeled with: the istiod of this tree cannot restrict the namespaces it
+	// discovers services in, so the proxies of a tenant also receive the services of other tenants, and tests must not
+	// assume that the services of their namespaces are the only ones in the mesh.
+	Tenant string
+
+	// If enabled, a failed test halts before its resources are cleaned up, printing how to inspect them, until the
+	// user presses enter or PauseTimeout elapses.
+	PauseOnFailure bool
+
+	// PauseTimeout bounds how long a failed test is paused with PauseOnFailure.
+	PauseTimeout time.Duration
+
+	// TestTimeout, if set, is the deadline of each top-level test. A test that exceeds it is failed, its state dumped
+	// and its resources cleaned up, before go test -timeout would abort the whole binary without artifacts.
+	TestTimeout time.Duration
+
+	// LogLevels is a comma separated list of <scope>:<level> pairs, such as "tf:debug,tfecho:info", applied to the
+	// logging scopes once logging is configured.
+	LogLevels string
+
+	// EchoRecordFile, if set, is the file every echo call and a summary of its results are appended to, for replay
+	// against another environment.
+	EchoRecordFile string
+
+	// Profile is the name of the set of framework flags the run was started with, such as "kind-singlecluster".
+	Profile string
+
+	// Components are the names of the registered components every suite of the run creates, after its own setup.
+	Components []string
+
 	// The label selector that the user has specified.
 	SelectorString string
 
 	// The label selector, in parsed form.
 	Selector label.Selector
 
+	// Environment is the name of the registered environment the tests run in.
+	Environment string
+
 	// EnvironmentFactory allows caller to override the environment creation. If nil, a default is used based
 	// on the known environment names.
 	EnvironmentFactory EnvironmentFactory
@@ -82,16 +138,27 @@ func (s *Settings) RunDir() string {
 	return path.Join(s.BaseDir, n)
 }
 
+// TenantPrefix is the prefix of the names of the namespaces of the tenant, or empty if the run is not a tenant.
+func (s *Settings) TenantPrefix() string {
+	if s.Tenant == "" {
+		return ""
+	}
+	return s.Tenant + "-"
+}
+
 // Clone settings
 func (s *Settings) Clone() *Settings {
 	cl := *s
+	cl.Components = append([]string(nil), s.Components...)
 	return &cl
 }
 
 // DefaultSettings returns a default settings instance.
 func DefaultSettings() *Settings {
 	return &Settings{
-		RunID: uuid.New(),
+		RunID:        uuid.New(),
+		PauseTimeout: 30 * time.Minute,
+		Environment:  DefaultEnvironment,
 	}
 }
 
@@ -100,6 +167,7 @@ func (s *Settings) String() string {
 	result := ""
 
 	result += fmt.Sprintf("TestID:            %s\n", s.TestID)
+	result += fmt.Sprintf("Environment:       %s\n", s.Environment)
 	result += fmt.Sprintf("RunID:             %s\n", s.RunID.String())
 	result += fmt.Sprintf("NoCleanup:         %v\n", s.NoCleanup)
 	result += fmt.Sprintf("BaseDir:           %s\n", s.BaseDir)
@@ -108,5 +176,17 @@ func (s *Settings) String() string {
 	result += fmt.Sprintf("CIMode:            %v\n", s.CIMode)
 	result += fmt.Sprintf("Retries:           %v\n", s.Retries)
 	result += fmt.Sprintf("StableNamespaces:  %v\n", s.StableNamespaces)
+	result += fmt.Sprintf("PodSecurity:       %v\n", s.PodSecurityRestricted)
+	result += fmt.Sprintf("Watchdog:          %v\n", s.Watchdog)
+	result += fmt.Sprintf("BugReport:         %v\n", s.BugReport)
+	result += fmt.Sprintf("AuditAPI:          %v\n", s.AuditAPI)
+	result += fmt.Sprintf("CheckClusterState: %v\n", s.CheckClusterState)
+	result += fmt.Sprintf("Tenant:            %s\n", s.Tenant)
+	result += fmt.Sprintf("LogLevels:         %s\n", s.LogLevels)
+	result += fmt.Sprintf("EchoRecordFile:    %s\n", s.EchoRecordFile)
+	result += fmt.Sprintf("Profile:           %s\n", s.Profile)
+	result += fmt.Sprintf("Components:        %v\n", s.Components)
+	result += fmt.Sprintf("PauseOnFailure:    %v\n", s.PauseOnFailure)
+	result += fmt.Sprintf("TestTimeout:       %v\n", s.TestTimeout)
 	return result
 }
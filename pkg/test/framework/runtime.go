@@ -19,6 +19,7 @@ import (
 
 	"istio.io/istio/pkg/test/framework/label"
 	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
 )
 
 var _ resource.Dumper = &runtime{}
@@ -26,6 +27,9 @@ var _ resource.Dumper = &runtime{}
 // runtime for the test environment.
 type runtime struct {
 	context *suiteContext
+
+	// watchdog monitors framework-owned pods, if enabled in the settings.
+	watchdog *kube.Watchdog
 }
 
 // newRuntime returns a new runtime instance.
@@ -34,9 +38,14 @@ func newRuntime(s *resource.Settings, fn resource.EnvironmentFactory, labels lab
 	if err != nil {
 		return nil, err
 	}
-	return &runtime{
+	r := &runtime{
 		context: ctx,
-	}, nil
+	}
+	if s.Watchdog {
+		r.watchdog = newWatchdog(ctx.Environment())
+		r.watchdog.Start()
+	}
+	return r, nil
 }
 
 // Dump state for all allocated resources.
@@ -56,5 +65,8 @@ func (i *runtime) newRootContext(test *testImpl, goTest *testing.T, labels label
 
 // Close implements io.Closer
 func (i *runtime) Close() error {
+	if i.watchdog != nil {
+		i.watchdog.Stop()
+	}
 	return i.context.globalScope.done(i.context.settings.NoCleanup)
 }
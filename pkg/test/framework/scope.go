@@ -167,6 +167,30 @@ func (s *scope) done(nocleanup bool) error {
 	return err
 }
 
+// abort closes the resources of the scope and of its children without waiting for them to be done, when the run is
+// interrupted. Resources still in use by running tests may fail to close, which is reported but not retried.
+func (s *scope) abort() error {
+	s.mu.Lock()
+	children := append([]*scope{}, s.children...)
+	closers := s.closers
+	s.resources = nil
+	s.closers = nil
+	s.mu.Unlock()
+
+	var err error
+	for i := len(children) - 1; i >= 0; i-- {
+		if e := children[i].abort(); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if e := closers[i].Close(); e != nil {
+			err = multierror.Append(err, e)
+		}
+	}
+	return err
+}
+
 func (s *scope) waitForDone() {
 	<-s.closeChan
 }
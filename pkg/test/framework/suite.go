@@ -15,6 +15,7 @@
 package framework
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -24,13 +25,16 @@ import (
 	"path/filepath"
 	"regexp"
 	goruntime "runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"gopkg.in/yaml.v2"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"istio.io/istio/pkg/test/framework/apiaudit"
 	"istio.io/istio/pkg/test/framework/components/environment/kube"
 	ferrors "istio.io/istio/pkg/test/framework/errors"
 	"istio.io/istio/pkg/test/framework/label"
@@ -46,6 +50,9 @@ const (
 
 	// Indicates an error due to the setup function supplied by the user
 	exitCodeSetupError = -2
+
+	// Indicates that the run was interrupted, by a signal
+	exitCodeInterrupted = -3
 )
 
 var (
@@ -92,6 +99,18 @@ type Suite interface {
 	RequireSingleCluster() Suite
 	// RequireEnvironmentVersion validates the environment meets a minimum version
 	RequireEnvironmentVersion(version string) Suite
+	// RequireK8sVersion ensures that every cluster runs at least the given minor version of Kubernetes 1.x.
+	// Otherwise it skips the suite.
+	RequireK8sVersion(minor int) Suite
+	// RequireMultiNetwork ensures that the clusters of the environment span more than one network.
+	// Otherwise it skips the suite.
+	RequireMultiNetwork() Suite
+	// RequireLoadBalancer ensures that the environment supports services of type LoadBalancer.
+	// Otherwise it skips the suite.
+	RequireLoadBalancer() Suite
+	// RequireArch ensures that every node of every cluster has one of the given architectures, such as "amd64".
+	// Otherwise it skips the suite.
+	RequireArch(archs ...string) Suite
 	// Setup runs enqueues the given setup function to run before test execution.
 	Setup(fn resource.SetupFn) Suite
 	// Run the suite. This method calls os.Exit and does not return.
@@ -102,6 +121,7 @@ type Suite interface {
 type suiteImpl struct {
 	testID      string
 	skipMessage string
+	skipReason  *SkipReason
 	mRun        mRunFn
 	osExit      func(int)
 	labels      label.Set
@@ -191,7 +211,7 @@ func (s *suiteImpl) RequireMinClusters(minClusters int) Suite {
 
 	fn := func(ctx resource.Context) error {
 		if len(clusters(ctx)) < minClusters {
-			s.Skip(fmt.Sprintf("Number of clusters %d does not exceed minimum %d",
+			s.unmet("minClusters", fmt.Sprintf("Number of clusters %d does not exceed minimum %d",
 				len(clusters(ctx)), minClusters))
 		}
 		return nil
@@ -208,7 +228,7 @@ func (s *suiteImpl) RequireMaxClusters(maxClusters int) Suite {
 
 	fn := func(ctx resource.Context) error {
 		if len(clusters(ctx)) > maxClusters {
-			s.Skip(fmt.Sprintf("Number of clusters %d exceeds maximum %d",
+			s.unmet("maxClusters", fmt.Sprintf("Number of clusters %d exceeds maximum %d",
 				len(clusters(ctx)), maxClusters))
 		}
 		return nil
@@ -224,14 +244,56 @@ func (s *suiteImpl) RequireSingleCluster() Suite {
 
 func (s *suiteImpl) RequireEnvironmentVersion(version string) Suite {
 	fn := func(ctx resource.Context) error {
-		ver, err := ctx.Clusters()[0].GetKubernetesVersion()
+		parts := strings.Split(version, ".")
+		if len(parts) < 2 || parts[0] != "1" {
+			return fmt.Errorf("invalid required Kubernetes version %q, expected 1.x", version)
+		}
+		minor, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid required Kubernetes version %q: %v", version, err)
+		}
+		return s.checkK8sVersion(ctx, minor)
+	}
+
+	s.requireFns = append(s.requireFns, fn)
+	return s
+}
+
+func (s *suiteImpl) RequireK8sVersion(minor int) Suite {
+	fn := func(ctx resource.Context) error {
+		return s.checkK8sVersion(ctx, minor)
+	}
+
+	s.requireFns = append(s.requireFns, fn)
+	return s
+}
+
+// checkK8sVersion compares minor versions as numbers, since "1.9" sorts after "1.16" as a string.
+func (s *suiteImpl) checkK8sVersion(ctx resource.Context, minor int) error {
+	for _, c := range clusters(ctx) {
+		ver, err := c.GetKubernetesVersion()
 		if err != nil {
-			return fmt.Errorf("failed to get Kubernetes version: %v", err)
+			return fmt.Errorf("failed to get Kubernetes version of cluster %s: %v", c.Name(), err)
 		}
-		serverVersion := fmt.Sprintf("%s.%s", ver.Major, ver.Minor)
-		if serverVersion < version {
-			s.Skip(fmt.Sprintf("Required Kubernetes version (%v) is greater than current: %v",
-				version, serverVersion))
+		// Some providers report minor versions such as "18+".
+		serverMinor, err := strconv.Atoi(strings.TrimRight(ver.Minor, "+"))
+		if err != nil {
+			return fmt.Errorf("failed to parse Kubernetes version %s.%s of cluster %s: %v",
+				ver.Major, ver.Minor, c.Name(), err)
+		}
+		if ver.Major == "1" && serverMinor < minor {
+			s.unmet("k8sVersion", fmt.Sprintf("Required Kubernetes version (1.%d) is greater than current: %s.%s in cluster %s",
+				minor, ver.Major, ver.Minor, c.Name()))
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *suiteImpl) RequireMultiNetwork() Suite {
+	fn := func(ctx resource.Context) error {
+		if ctx.Environment() == nil || !ctx.Environment().IsMultinetwork() {
+			s.unmet("multiNetwork", "Environment does not span multiple networks")
 		}
 		return nil
 	}
@@ -240,6 +302,51 @@ func (s *suiteImpl) RequireEnvironmentVersion(version string) Suite {
 	return s
 }
 
+func (s *suiteImpl) RequireLoadBalancer() Suite {
+	fn := func(ctx resource.Context) error {
+		if !resource.LoadBalancerSupported(ctx.Environment()) {
+			s.unmet("loadBalancer", "Environment does not support services of type LoadBalancer")
+		}
+		return nil
+	}
+
+	s.requireFns = append(s.requireFns, fn)
+	return s
+}
+
+func (s *suiteImpl) RequireArch(archs ...string) Suite {
+	fn := func(ctx resource.Context) error {
+		supported := map[string]bool{}
+		for _, a := range archs {
+			supported[a] = true
+		}
+		for _, c := range clusters(ctx) {
+			nodes, err := c.CoreV1().Nodes().List(context.TODO(), kubeApiMeta.ListOptions{})
+			if err != nil {
+				return fmt.Errorf("failed listing nodes of cluster %s: %v", c.Name(), err)
+			}
+			for _, n := range nodes.Items {
+				if arch := n.Status.NodeInfo.Architecture; !supported[arch] {
+					s.unmet("arch", fmt.Sprintf("Node %s of cluster %s has architecture %s, required one of %v",
+						n.Name, c.Name(), arch, archs))
+					return nil
+				}
+			}
+		}
+		return nil
+	}
+
+	s.requireFns = append(s.requireFns, fn)
+	return s
+}
+
+// unmet skips the suite for a requirement the environment does not meet, recording the requirement in the outcome of
+// the suite.
+func (s *suiteImpl) unmet(requirement, message string) {
+	s.skipReason = &SkipReason{Requirement: requirement, Message: message}
+	s.Skip(message)
+}
+
 func (s *suiteImpl) Setup(fn resource.SetupFn) Suite {
 	s.setupFns = append(s.setupFns, fn)
 	return s
@@ -273,6 +380,7 @@ func (s *suiteImpl) doSkip(ctx *suiteContext) int {
 	// Run the tests so that the golang test framework exits normally. The tests will not run because
 	// they see that this suite has been skipped.
 	_ = s.mRun(ctx)
+	s.writeOutput()
 
 	// Return success.
 	return 0
@@ -302,10 +410,17 @@ func (s *suiteImpl) run() (errLevel int) {
 
 	start := time.Now()
 
+	stopInterrupts := handleInterrupts(s, ctx)
+	defer stopInterrupts()
+
+	var stateBefore clusterState
 	defer func() {
 		if errLevel != 0 && ctx.Settings().CIMode {
 			rt.Dump(ctx)
 		}
+		if errLevel != 0 && ctx.Settings().BugReport {
+			captureBugReports(ctx)
+		}
 
 		if err := rt.Close(); err != nil {
 			scopes.Framework.Errorf("Error during close: %v", err)
@@ -315,9 +430,39 @@ func (s *suiteImpl) run() (errLevel int) {
 				}
 			}
 		}
+		// Anything of the tenant not deleted by closing its resources, such as claimed namespaces, is deleted.
+		if !ctx.Settings().NoCleanup {
+			if err := cleanupTenant(ctx); err != nil {
+				scopes.Framework.Errorf("Error cleaning up tenant: %v", err)
+			}
+		}
+		if ctx.Settings().AuditAPI {
+			writeAudit(ctx.Settings().RunDir(), apiaudit.Totals(), apiaudit.Distinct())
+		}
+		if stateBefore != nil {
+			if err := checkClusterState(ctx, stateBefore); err != nil {
+				scopes.Framework.Errorf("%v", err)
+				if errLevel == 0 {
+					errLevel = 1
+				}
+			}
+		}
 		rt = nil
 	}()
 
+	if err := cleanupTenant(ctx); err != nil {
+		scopes.Framework.Errorf("Exiting due to failure cleaning up a previous run of the tenant: %v", err)
+		return exitCodeSetupError
+	}
+
+	if ctx.Settings().CheckClusterState {
+		var err error
+		if stateBefore, err = snapshotClusterState(ctx); err != nil {
+			scopes.Framework.Errorf("Exiting due to failure recording the state of the clusters: %v", err)
+			return exitCodeSetupError
+		}
+	}
+
 	if err := s.runSetupFns(ctx); err != nil {
 		scopes.Framework.Errorf("Exiting due to setup failure: %v", err)
 		return exitCodeSetupError
@@ -358,9 +503,17 @@ type SuiteOutcome struct {
 	Name         string
 	Environment  string
 	Multicluster bool
+	// SkipReason is set if the suite was skipped because the environment does not meet one of its requirements.
+	SkipReason   *SkipReason `yaml:",omitempty"`
 	TestOutcomes []TestOutcome
 }
 
+// SkipReason is the requirement of a suite that the environment does not meet, such as "multiNetwork".
+type SkipReason struct {
+	Requirement string
+	Message     string
+}
+
 func environmentName(ctx resource.Context) string {
 	if ctx.Environment() != nil {
 		return ctx.Environment().EnvironmentName()
@@ -392,6 +545,7 @@ func (s *suiteImpl) writeOutput() {
 			Name:         ctx.Settings().TestID,
 			Environment:  environmentName(ctx),
 			Multicluster: isMulticluster(ctx),
+			SkipReason:   s.skipReason,
 			TestOutcomes: ctx.testOutcomes,
 		}
 		ctx.outcomeMu.RUnlock()
@@ -409,8 +563,12 @@ func (s *suiteImpl) writeOutput() {
 func (s *suiteImpl) runSetupFns(ctx SuiteContext) (err error) {
 	scopes.Framework.Infof("=== BEGIN: Setup: '%s' ===", ctx.Settings().TestID)
 
-	// Run all the require functions first, then the setup functions.
+	// Run all the require functions first, then the setup functions, then create the registered components named on
+	// the command line, which may depend on what the suite set up.
 	setupFns := append(append([]resource.SetupFn{}, s.requireFns...), s.setupFns...)
+	for _, name := range ctx.Settings().Components {
+		setupFns = append(setupFns, SetupComponent(name))
+	}
 
 	start := time.Now()
 	for _, fn := range setupFns {
@@ -449,12 +607,17 @@ func initRuntime(s *suiteImpl) error {
 		environmentFactory = settings.EnvironmentFactory
 	}
 	if environmentFactory == nil {
-		environmentFactory = newEnvironment
+		if environmentFactory, err = resource.GetEnvironmentFactory(settings.Environment); err != nil {
+			return err
+		}
 	}
 
 	if err := configureLogging(); err != nil {
 		return err
 	}
+	if err := scopes.SetLevels(settings.LogLevels); err != nil {
+		return err
+	}
 
 	scopes.Framework.Infof("=== Test Framework Settings ===")
 	scopes.Framework.Info(settings.String())
@@ -466,10 +629,18 @@ func initRuntime(s *suiteImpl) error {
 	}
 	scopes.Framework.Infof("Test run dir: %v", settings.RunDir())
 
+	if settings.AuditAPI {
+		apiaudit.Enable()
+	}
+
 	rt, err = newRuntime(settings, environmentFactory, s.labels)
 	return err
 }
 
+func init() {
+	resource.RegisterEnvironment(resource.DefaultEnvironment, newEnvironment)
+}
+
 func newEnvironment(ctx resource.Context) (resource.Environment, error) {
 	s, err := kube.NewSettingsFromCommandLine()
 	if err != nil {
@@ -21,7 +21,12 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeVersion "k8s.io/apimachinery/pkg/version"
 
+	istioKube "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/test/framework/label"
 	"istio.io/istio/pkg/test/framework/resource"
 )
@@ -233,6 +238,144 @@ func TestSuite_RequireMinMaxClusters(t *testing.T) {
 	}
 }
 
+// requirementsEnvironment is a fake environment whose clusters report a Kubernetes version and have nodes.
+type requirementsEnvironment struct {
+	resource.FakeEnvironment
+	clusters     resource.Clusters
+	multinetwork bool
+}
+
+func (e requirementsEnvironment) Clusters() resource.Clusters {
+	return e.clusters
+}
+
+func (e requirementsEnvironment) IsMultinetwork() bool {
+	return e.multinetwork
+}
+
+// versionedClient is a fake client reporting the given Kubernetes minor version.
+type versionedClient struct {
+	istioKube.ExtendedClient
+	minor string
+}
+
+func (c versionedClient) GetKubernetesVersion() (*kubeVersion.Info, error) {
+	return &kubeVersion.Info{Major: "1", Minor: c.minor}, nil
+}
+
+func requirementsCluster(minor string, archs ...string) resource.Cluster {
+	var nodes []runtime.Object
+	for i, arch := range archs {
+		nodes = append(nodes, &kubeApiCore.Node{
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: fmt.Sprintf("node-%d", i)},
+			Status:     kubeApiCore.NodeStatus{NodeInfo: kubeApiCore.NodeSystemInfo{Architecture: arch}},
+		})
+	}
+	return resource.FakeCluster{
+		ExtendedClient: versionedClient{
+			ExtendedClient: istioKube.NewFakeClient(nodes...).(istioKube.ExtendedClient),
+			minor:          minor,
+		},
+		NameValue: "cluster-0",
+	}
+}
+
+func TestSuite_Requirements(t *testing.T) {
+	cases := []struct {
+		name         string
+		cluster      resource.Cluster
+		multinetwork bool
+		require      func(s *suiteImpl)
+		// requirement is the unmet requirement the suite is expected to be skipped for, if any.
+		requirement string
+	}{
+		{
+			name:    "k8s version met",
+			cluster: requirementsCluster("18+"),
+			require: func(s *suiteImpl) { s.RequireK8sVersion(16) },
+		},
+		{
+			name:        "k8s version unmet",
+			cluster:     requirementsCluster("9"),
+			require:     func(s *suiteImpl) { s.RequireK8sVersion(16) },
+			requirement: "k8sVersion",
+		},
+		{
+			// Minor versions are compared as numbers, as "1.9" sorts after "1.16" as a string.
+			name:        "environment version unmet",
+			cluster:     requirementsCluster("9"),
+			require:     func(s *suiteImpl) { s.RequireEnvironmentVersion("1.16") },
+			requirement: "k8sVersion",
+		},
+		{
+			name:    "environment version met",
+			cluster: requirementsCluster("16"),
+			require: func(s *suiteImpl) { s.RequireEnvironmentVersion("1.16") },
+		},
+		{
+			name:         "multinetwork met",
+			cluster:      requirementsCluster("18"),
+			multinetwork: true,
+			require:      func(s *suiteImpl) { s.RequireMultiNetwork() },
+		},
+		{
+			name:        "multinetwork unmet",
+			cluster:     requirementsCluster("18"),
+			require:     func(s *suiteImpl) { s.RequireMultiNetwork() },
+			requirement: "multiNetwork",
+		},
+		{
+			// Only the kube environment can lack load balancer support.
+			name:    "load balancer outside of kube",
+			cluster: requirementsCluster("18"),
+			require: func(s *suiteImpl) { s.RequireLoadBalancer() },
+		},
+		{
+			name:    "arch met",
+			cluster: requirementsCluster("18", "amd64", "arm64"),
+			require: func(s *suiteImpl) { s.RequireArch("amd64", "arm64") },
+		},
+		{
+			name:        "arch unmet",
+			cluster:     requirementsCluster("18", "amd64", "arm64"),
+			require:     func(s *suiteImpl) { s.RequireArch("amd64") },
+			requirement: "arch",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer cleanupRT()
+			g := NewWithT(t)
+
+			var runSkipped bool
+			runFn := func(ctx *suiteContext) int {
+				runSkipped = ctx.skipped
+				return 0
+			}
+
+			s := newTestSuite("tid", runFn, defaultExitFn, settingsFn(resource.DefaultSettings()))
+			env := requirementsEnvironment{
+				clusters:     resource.Clusters{c.cluster},
+				multinetwork: c.multinetwork,
+			}
+			s.envFactory = func(ctx resource.Context) (resource.Environment, error) {
+				return env, nil
+			}
+			c.require(s)
+			s.Run()
+
+			g.Expect(runSkipped).To(Equal(c.requirement != ""))
+			if c.requirement == "" {
+				g.Expect(s.skipReason).To(BeNil())
+			} else {
+				g.Expect(s.skipReason).NotTo(BeNil())
+				g.Expect(s.skipReason.Requirement).To(Equal(c.requirement))
+			}
+		})
+	}
+}
+
 func TestSuite_Setup(t *testing.T) {
 	defer cleanupRT()
 	g := NewWithT(t)
@@ -194,6 +194,11 @@ type TestOutcome struct {
 	Type          string
 	Outcome       Outcome
 	FeatureLabels map[features.Feature][]string
+	// HealthEvents are the restarts of framework-owned containers observed while the test ran.
+	HealthEvents []string
+	// Fingerprint of the failure of the test, a stable hash of the component and first failure message of the test
+	// with the parts that vary between runs removed, so that identical failures can be clustered across runs.
+	Fingerprint string `yaml:",omitempty"`
 }
 
 func (s *suiteContext) registerOutcome(test *testImpl) {
@@ -212,6 +217,8 @@ func (s *suiteContext) registerOutcome(test *testImpl) {
 		Type:          "integration",
 		Outcome:       o,
 		FeatureLabels: test.featureLabels,
+		HealthEvents:  test.healthEvents,
+		Fingerprint:   test.fingerprint,
 	}
 	s.contextMu.Lock()
 	defer s.contextMu.Unlock()
@@ -0,0 +1,78 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/api/label"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/scopes"
+	"istio.io/istio/pkg/test/util/yml"
+)
+
+// configForTenant labels the Istio config in the YAML with the revision of the tenant. istiod ignores config labeled
+// with other revisions, so that the config of concurrent tenants does not affect each other's proxies. Services are
+// not labeled, as istiod discovers them regardless of revision.
+func configForTenant(tenant string, yamlText []string) ([]string, error) {
+	out := make([]string, 0, len(yamlText))
+	for _, text := range yamlText {
+		labeled, err := yml.ApplyLabel(text, label.IstioRev, tenant, func(d yml.Descriptor) bool {
+			return strings.HasSuffix(d.Group, "istio.io") && d.Metadata.Name != ""
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed labeling config for tenant %s: %v", tenant, err)
+		}
+		out = append(out, labeled)
+	}
+	return out, nil
+}
+
+// cleanupTenant deletes the namespaces of the tenant of the run, such as those left over by a run of the same tenant
+// that was killed before cleaning up. Namespaces of other tenants are never touched.
+func cleanupTenant(ctx resource.Context) error {
+	tenant := ctx.Settings().Tenant
+	if tenant == "" || ctx.Environment() == nil {
+		return nil
+	}
+	selector := namespace.TenantLabel + "=" + tenant
+	var errs error
+	for _, c := range ctx.Clusters() {
+		list, err := c.CoreV1().Namespaces().List(context.TODO(), kubeApiMeta.ListOptions{LabelSelector: selector})
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed listing namespaces of tenant %s in cluster %s: %v",
+				tenant, c.Name(), err))
+			continue
+		}
+		for _, ns := range list.Items {
+			scopes.Framework.Infof("deleting namespace %s of tenant %s in cluster %s", ns.Name, tenant, c.Name())
+			if err := c.CoreV1().Namespaces().Delete(context.TODO(), ns.Name, kube.DeleteOptionsForeground()); err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+			if err := kube.WaitForNamespaceDeletion(c, ns.Name); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+	return errs
+}
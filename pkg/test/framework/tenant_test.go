@@ -0,0 +1,74 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package framework
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/test/util/yml"
+)
+
+func TestConfigForTenant(t *testing.T) {
+	g := NewWithT(t)
+	in := []string{`
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: a
+spec:
+  hosts:
+  - a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+`, `
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: b
+  labels:
+    app: b
+`}
+	out, err := configForTenant("t1", in)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(out).To(HaveLen(2))
+
+	revisions := map[string]string{}
+	for _, text := range out {
+		for _, part := range yml.SplitString(text) {
+			var obj struct {
+				Kind     string `json:"kind"`
+				Metadata struct {
+					Labels map[string]string `json:"labels"`
+				} `json:"metadata"`
+			}
+			g.Expect(yaml.Unmarshal([]byte(part), &obj)).To(Succeed())
+			revisions[obj.Kind] = obj.Metadata.Labels["istio.io/rev"]
+			if obj.Kind == "AuthorizationPolicy" {
+				g.Expect(obj.Metadata.Labels).To(HaveKeyWithValue("app", "b"))
+			}
+		}
+	}
+	g.Expect(revisions).To(Equal(map[string]string{
+		"VirtualService":      "t1",
+		"ConfigMap":           "",
+		"AuthorizationPolicy": "t1",
+	}))
+}
@@ -15,13 +15,23 @@
 package framework
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	goruntime "runtime"
+	"runtime/pprof"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"istio.io/istio/pkg/test/framework/apiaudit"
 	"istio.io/istio/pkg/test/framework/features"
 	"istio.io/istio/pkg/test/framework/label"
+	"istio.io/istio/pkg/test/framework/resource"
 	"istio.io/istio/pkg/test/scopes"
 	"istio.io/pkg/log"
 )
@@ -40,6 +50,9 @@ type Test interface {
 	RequiresMaxClusters(maxClusters int) Test
 	// RequiresSingleCluster this a utility that requires the min/max clusters to both = 1.
 	RequiresSingleCluster() Test
+	// RequiresNodeAccess indicates that the test needs privileged access to nodes (e.g. hostNetwork, privileged
+	// containers or NET_ADMIN). The test is skipped in environments that forbid it, such as GKE Autopilot.
+	RequiresNodeAccess() Test
 	// Run the test, supplied as a lambda.
 	Run(fn func(ctx TestContext))
 	// RunParallel runs this test in parallel with other children of the same parent test/suite. Under the hood,
@@ -104,9 +117,24 @@ type testImpl struct {
 	s                   *suiteContext
 	requiredMinClusters int
 	requiredMaxClusters int
+	requiresNodeAccess  bool
 
 	ctx *testContext
 
+	// healthEvents are the restarts of framework-owned containers observed by the watchdog while the test ran.
+	healthEvents []string
+
+	// pkg is the package of the test function, the component of failures raised through the raw *testing.T.
+	pkg string
+	// failuresMu guards failures and childFailed.
+	failuresMu sync.Mutex
+	// failures are the messages the test failed with, through its context.
+	failures []failure
+	// childFailed is set if a subtest failed, which fails the test too.
+	childFailed bool
+	// fingerprint of the failure of the test, if it failed.
+	fingerprint string
+
 	// Indicates that at least one child test is being run in parallel. In Go, when
 	// t.Parallel() is called on a test, execution is halted until the parent test exits.
 	// Only after that point, are the Parallel children are resumed. Because the parent test
@@ -173,6 +201,11 @@ func (t *testImpl) RequiresSingleCluster() Test {
 	return t.RequiresMaxClusters(1).RequiresMinClusters(1)
 }
 
+func (t *testImpl) RequiresNodeAccess() Test {
+	t.requiresNodeAccess = true
+	return t
+}
+
 func (t *testImpl) Run(fn func(ctx TestContext)) {
 	t.runInternal(fn, false)
 }
@@ -216,6 +249,7 @@ func (t *testImpl) doRun(ctx *testContext, fn func(ctx TestContext), parallel bo
 	}
 
 	t.ctx = ctx
+	t.pkg = funcPackage(goruntime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name())
 
 	if t.requiredMinClusters > 0 && len(t.s.Environment().Clusters()) < t.requiredMinClusters {
 		ctx.Done()
@@ -231,6 +265,14 @@ func (t *testImpl) doRun(ctx *testContext, fn func(ctx TestContext), parallel bo
 		return
 	}
 
+	if t.requiresNodeAccess {
+		if !resource.NodeAccessSupported(t.s.Environment()) {
+			ctx.Done()
+			t.goTest.Skipf("Skipping %q: node access is not available in the environment", t.goTest.Name())
+			return
+		}
+	}
+
 	start := time.Now()
 
 	scopes.Framework.Infof("=== BEGIN: Test: '%s[%s]' ===", rt.suiteContext().Settings().TestID, t.goTest.Name())
@@ -247,7 +289,18 @@ func (t *testImpl) doRun(ctx *testContext, fn func(ctx TestContext), parallel bo
 		t.goTest.Parallel()
 	}
 
+	auditMark := apiaudit.Mark()
 	defer func() {
+		t.checkHealth(start, time.Now())
+		calls := apiaudit.Since(auditMark)
+		apiaudit.Release(auditMark)
+		if ctx.Settings().AuditAPI {
+			writeAudit(ctx.WorkDir(), apiaudit.Summarize(calls), calls)
+		}
+		if t.goTest.Failed() {
+			t.fingerprintFailure(ctx)
+		}
+
 		doneFn := func() {
 			message := "passed"
 			if t.goTest.Failed() {
@@ -260,6 +313,9 @@ func (t *testImpl) doRun(ctx *testContext, fn func(ctx TestContext), parallel bo
 				t.goTest.Name(),
 				end.Sub(start))
 			rt.suiteContext().registerOutcome(t)
+			if t.goTest.Failed() {
+				pauseOnFailure(ctx, t.goTest.Name())
+			}
 			ctx.Done()
 			if t.hasParallelChildren {
 				globalParentLock.Delete(t)
@@ -276,5 +332,118 @@ func (t *testImpl) doRun(ctx *testContext, fn func(ctx TestContext), parallel bo
 		}
 	}()
 
+	t.runWithDeadline(ctx, fn)
+}
+
+// runWithDeadline runs the test function, failing the test if it is a top-level test that exceeds the deadline set
+// with --istio.test.testTimeout. The function runs on the test goroutine. Once the deadline passes, the failure is
+// reported, the goroutines dumped, and the context of the test canceled, so that operations waiting on it give up;
+// the test is then stopped with FailNow when the function returns to the test goroutine.
+func (t *testImpl) runWithDeadline(ctx *testContext, fn func(ctx TestContext)) {
+	timeout := ctx.Settings().TestTimeout
+	if timeout <= 0 || t.parent != nil {
+		fn(ctx)
+		return
+	}
+	deadline, cancel := context.WithCancel(context.Background())
+	ctx.deadline = deadline
+	expired := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() {
+		defer close(expired)
+		// Unlike FailNow, Errorf may be called from another goroutine than the test's.
+		ctx.Errorf("test did not complete within its deadline of %v set with --istio.test.testTimeout", timeout)
+		dumpGoroutines(ctx)
+		cancel()
+	})
+	defer func() {
+		// Do not report to the test once it is complete, even if fn exited with FailNow.
+		if !timer.Stop() {
+			<-expired
+		}
+		cancel()
+	}()
 	fn(ctx)
+	if deadline.Err() != nil {
+		ctx.FailNow()
+	}
+}
+
+// dumpGoroutines writes the stacks of all goroutines to the work dir of the context, to show where a test is stuck.
+func dumpGoroutines(ctx *testContext) {
+	f, err := os.Create(path.Join(ctx.WorkDir(), "goroutines.txt"))
+	if err != nil {
+		scopes.Framework.Errorf("failed dumping goroutines: %v", err)
+		return
+	}
+	defer f.Close()
+	if err := pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		scopes.Framework.Errorf("failed dumping goroutines: %v", err)
+	}
+}
+
+// writeAudit writes the summary of the API calls, and the calls, to the directory. The calls made while a test ran
+// include those of tests running in parallel with it.
+func writeAudit(dir string, summaries []apiaudit.Summary, calls []apiaudit.Record) {
+	summary := apiaudit.Format(summaries)
+	if err := ioutil.WriteFile(path.Join(dir, "api-audit.txt"), []byte(summary), 0644); err != nil {
+		scopes.Framework.Errorf("failed writing API audit summary: %v", err)
+	}
+	if err := apiaudit.Write(path.Join(dir, "api-audit.yaml"), calls); err != nil {
+		scopes.Framework.Errorf("failed writing API audit: %v", err)
+	}
+	total := 0
+	for _, s := range summaries {
+		total += s.Calls
+	}
+	scopes.Framework.Infof("%d Kubernetes API calls, summarized in %s", total, path.Join(dir, "api-audit.txt"))
+}
+
+// recordFailure records a failure of the test, for its fingerprint.
+func (t *testImpl) recordFailure(f failure) {
+	t.failuresMu.Lock()
+	defer t.failuresMu.Unlock()
+	t.failures = append(t.failures, f)
+}
+
+// fingerprintFailure computes the fingerprint of the failure of the test, and logs it so that it is part of the
+// failure reported in JUnit. The first failure raised through the context of the test is fingerprinted. As the
+// messages of failures raised through the raw *testing.T are not available, those are fingerprinted by the test and
+// its package alone. Tests that only failed through their subtests are not fingerprinted, as the subtests are.
+func (t *testImpl) fingerprintFailure(ctx resource.Context) {
+	if t.parent != nil {
+		t.parent.failuresMu.Lock()
+		t.parent.childFailed = true
+		t.parent.failuresMu.Unlock()
+	}
+	t.failuresMu.Lock()
+	f := failure{component: t.pkg}
+	if len(t.failures) > 0 {
+		f = t.failures[0]
+	} else if t.childFailed {
+		t.failuresMu.Unlock()
+		return
+	}
+	t.failuresMu.Unlock()
+	if f.component == "" {
+		f.component = t.pkg
+	}
+	var names []string
+	for _, c := range clusters(ctx) {
+		names = append(names, c.Name())
+	}
+	t.fingerprint = fingerprint(t.goTest.Name(), f, names)
+	t.goTest.Log(fingerprintPrefix + t.fingerprint)
+}
+
+// checkHealth fails the test if the watchdog observed restarts of framework-owned containers while it ran.
+func (t *testImpl) checkHealth(start, end time.Time) {
+	if rt.watchdog == nil {
+		return
+	}
+	for _, e := range rt.watchdog.EventsBetween(start, end) {
+		t.healthEvents = append(t.healthEvents, e.String())
+	}
+	if len(t.healthEvents) > 0 {
+		t.goTest.Errorf("framework components restarted during the test:\n%s", strings.Join(t.healthEvents, "\n"))
+	}
 }
@@ -15,6 +15,7 @@
 package framework
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -108,6 +109,9 @@ type testContext struct {
 
 	// The workDir for this particular context
 	workDir string
+
+	// deadline is done once the top-level test exceeds its deadline, if any.
+	deadline context.Context
 }
 
 // Before executing a new context, we should wait for existing contexts to terminate if they are NOT parents of this context.
@@ -255,7 +259,19 @@ func (c *testContext) CreateTmpDirectoryOrFail(prefix string) string {
 }
 
 func (c *testContext) newChildContext(test *testImpl) *testContext {
-	return newTestContext(test, test.goTest, c.suite, c.scope, label.NewSet(test.labels...))
+	child := newTestContext(test, test.goTest, c.suite, c.scope, label.NewSet(test.labels...))
+	child.deadline = c.deadline
+	return child
+}
+
+// Context returns a context that is done once the top-level test exceeds its deadline set with
+// --istio.test.testTimeout, so that operations waiting on it, such as retry.UntilSuccessOrFail, give up and let the
+// test fail.
+func (c *testContext) Context() context.Context {
+	if c.deadline == nil {
+		return context.Background()
+	}
+	return c.deadline
 }
 
 func (c *testContext) NewSubTest(name string) Test {
@@ -309,13 +325,21 @@ func (c *testContext) Done() {
 	scopes.Framework.Debugf("Completed cleaning up testContext: %q", c.id)
 }
 
+func (c *testContext) recordFailure(msg string) {
+	if c.test != nil {
+		c.test.recordFailure(failure{message: msg, component: callerComponent()})
+	}
+}
+
 func (c *testContext) Error(args ...interface{}) {
 	c.Helper()
+	c.recordFailure(fmt.Sprint(args...))
 	c.T.Error(args...)
 }
 
 func (c *testContext) Errorf(format string, args ...interface{}) {
 	c.Helper()
+	c.recordFailure(fmt.Sprintf(format, args...))
 	c.T.Errorf(format, args...)
 }
 
@@ -336,11 +360,13 @@ func (c *testContext) Failed() bool {
 
 func (c *testContext) Fatal(args ...interface{}) {
 	c.Helper()
+	c.recordFailure(fmt.Sprint(args...))
 	c.T.Fatal(args...)
 }
 
 func (c *testContext) Fatalf(format string, args ...interface{}) {
 	c.Helper()
+	c.recordFailure(fmt.Sprintf(format, args...))
 	c.T.Fatalf(format, args...)
 }
 
@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/metadata"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	kube2 "istio.io/istio/pkg/test/kube"
+)
+
+var (
+	kubeconfigs string
+	ttl         time.Duration
+	dryRun      bool
+
+	rootCmd = &cobra.Command{
+		Use:   "janitor [OPTIONS]",
+		Short: "Janitor deletes the resources left behind by test runs on long-lived clusters",
+		Long: "Janitor deletes the namespaces and cluster scoped resources created by runs of the test framework " +
+			"that are older than the TTL, or that belong to runs that were interrupted. It is meant to run as a " +
+			"periodic job against shared clusters.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var errs error
+			for _, kc := range strings.Split(kubeconfigs, ",") {
+				if kc = strings.TrimSpace(kc); kc == "" {
+					continue
+				}
+				client, err := kube.NewClient(kube.BuildClientCmd(kc, ""))
+				if err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("failed creating client for %s: %v", kc, err))
+					continue
+				}
+				deleted, err := Clean(client.Metadata(), Options{TTL: ttl, DryRun: dryRun})
+				for _, r := range deleted {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: deleted %s\n", kc, r)
+				}
+				if err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("failed cleaning up %s: %v", kc, err))
+				}
+			}
+			return errs
+		},
+	}
+)
+
+// Execute runs the janitor, exiting if it fails.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println("Error running janitor:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.Flags().StringVarP(&kubeconfigs, "kubeconfig", "k", os.Getenv("KUBECONFIG"),
+		"comma separated list of the kubeconfigs of the clusters to clean up")
+	rootCmd.Flags().DurationVar(&ttl, "ttl", 6*time.Hour,
+		"age after which the resources of a run are deleted, which must exceed the duration of the longest run")
+	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only print the resources that would be deleted")
+}
+
+// Options of a cleanup.
+type Options struct {
+	// TTL is the age after which the resources of a run are deleted.
+	TTL time.Duration
+	// DryRun returns the resources that would be deleted, without deleting them.
+	DryRun bool
+	// Now is the time the age of resources is computed at. Defaults to the current time.
+	Now time.Time
+}
+
+// resources are those the janitor deletes, in order. Namespaces come first, since the resources within them are
+// deleted along with them, followed by the cluster scoped resources a run may create.
+var resources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "namespaces"},
+	{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"},
+}
+
+// Clean deletes the resources created by runs of the test framework that are older than the TTL, or that belong to a
+// run whose namespaces were annotated as aborted when it was interrupted, and returns them sorted, as
+// "<resource>/<name>". Only resources labeled with the run that created them are considered, so that those claimed
+// by tests, such as the system namespace, are never deleted.
+func Clean(client metadata.Interface, opts Options) ([]string, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	aborted := map[string]bool{}
+	var deleted []string
+	var errs error
+	for _, gvr := range resources {
+		list, err := client.Resource(gvr).List(context.TODO(), kubeApiMeta.ListOptions{LabelSelector: namespace.RunLabel})
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed listing %s: %v", gvr.Resource, err))
+			continue
+		}
+		for _, obj := range list.Items {
+			if obj.DeletionTimestamp != nil {
+				continue
+			}
+			run := obj.Labels[namespace.RunLabel]
+			if _, ok := obj.Annotations[namespace.AbortedAnnotation]; ok {
+				aborted[run] = true
+			}
+			if !aborted[run] && now.Sub(obj.CreationTimestamp.Time) < opts.TTL {
+				continue
+			}
+			if !opts.DryRun {
+				if err := client.Resource(gvr).Delete(context.TODO(), obj.Name, kube2.DeleteOptionsForeground()); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("failed deleting %s %s: %v", gvr.Resource, obj.Name, err))
+					continue
+				}
+			}
+			deleted = append(deleted, gvr.Resource+"/"+obj.Name)
+		}
+	}
+	sort.Strings(deleted)
+	return deleted, errs
+}
@@ -0,0 +1,107 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	metadatafake "k8s.io/client-go/metadata/fake"
+
+	"istio.io/istio/pkg/test/framework/components/namespace"
+)
+
+func testObject(gvr schema.GroupVersionResource, kind, name string, age time.Duration, now time.Time,
+	labels, annotations map[string]string) *kubeApiMeta.PartialObjectMetadata {
+	return &kubeApiMeta.PartialObjectMetadata{
+		TypeMeta: kubeApiMeta.TypeMeta{APIVersion: gvr.GroupVersion().String(), Kind: kind},
+		ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:              name,
+			Labels:            labels,
+			Annotations:       annotations,
+			CreationTimestamp: kubeApiMeta.NewTime(now.Add(-age)),
+		},
+	}
+}
+
+func TestClean(t *testing.T) {
+	now := time.Now()
+	namespaces, crds := resources[0], resources[1]
+	run := map[string]string{namespace.RunLabel: "run"}
+	interrupted := map[string]string{namespace.RunLabel: "interrupted"}
+	aborted := map[string]string{namespace.AbortedAnnotation: "now"}
+
+	s := runtime.NewScheme()
+	if err := kubeApiMeta.AddMetaToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	for _, gvr := range resources {
+		s.AddKnownTypeWithName(gvr.GroupVersion().WithKind("List"), &kubeApiMeta.List{})
+	}
+	client := metadatafake.NewSimpleMetadataClient(s,
+		testObject(namespaces, "Namespace", "old", 2*time.Hour, now, run, nil),
+		testObject(namespaces, "Namespace", "recent", time.Minute, now, run, nil),
+		testObject(namespaces, "Namespace", "aborted", time.Minute, now, interrupted, aborted),
+		testObject(namespaces, "Namespace", "istio-system", 2*time.Hour, now, nil, nil),
+		testObject(crds, "CustomResourceDefinition", "old.example.com", 2*time.Hour, now, run, nil),
+		testObject(crds, "CustomResourceDefinition", "recent.example.com", time.Minute, now, run, nil),
+		testObject(crds, "CustomResourceDefinition", "interrupted.example.com", time.Minute, now, interrupted, nil),
+		testObject(crds, "CustomResourceDefinition", "installed.example.com", 2*time.Hour, now, nil, nil),
+	)
+
+	deleted, err := Clean(client, Options{TTL: time.Hour, DryRun: true, Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"customresourcedefinitions/interrupted.example.com",
+		"customresourcedefinitions/old.example.com",
+		"namespaces/aborted",
+		"namespaces/old",
+	}
+	if !reflect.DeepEqual(deleted, want) {
+		t.Fatalf("Expected %v to be deleted, got %v", want, deleted)
+	}
+	if _, err := client.Resource(namespaces).Get(context.TODO(), "old", kubeApiMeta.GetOptions{}); err != nil {
+		t.Fatalf("Expected dry run to leave resources in place: %v", err)
+	}
+
+	if _, err := Clean(client, Options{TTL: time.Hour, Now: now}); err != nil {
+		t.Fatal(err)
+	}
+	for gvr, remaining := range map[schema.GroupVersionResource][]string{
+		namespaces: {"istio-system", "recent"},
+		crds:       {"installed.example.com", "recent.example.com"},
+	} {
+		list, err := client.Resource(gvr).List(context.TODO(), kubeApiMeta.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, obj := range list.Items {
+			got = append(got, obj.Name)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, remaining) {
+			t.Fatalf("Expected %v to remain, got %v", remaining, got)
+		}
+	}
+}
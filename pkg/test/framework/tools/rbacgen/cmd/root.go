@@ -0,0 +1,122 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	kubeApiCore "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pkg/test/framework/apiaudit"
+	"istio.io/istio/pkg/test/util/yml"
+)
+
+var (
+	audits         []string
+	name           string
+	serviceAccount string
+	output         string
+
+	rootCmd = &cobra.Command{
+		Use:   "rbacgen [OPTIONS]",
+		Short: "RBACGen derives the RBAC needed to run test suites from the API calls they made",
+		Long: "RBACGen reads the api-audit.yaml files written by suites run with --istio.test.auditAPI, and generates a " +
+			"ClusterRole granting exactly the calls they made, bound to the service account to run the suites as with " +
+			"--istio.test.kube.serviceAccount.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var recs []apiaudit.Record
+			for _, f := range audits {
+				r, err := apiaudit.Load(f)
+				if err != nil {
+					return err
+				}
+				recs = append(recs, r...)
+			}
+			out, err := Generate(name, serviceAccount, recs)
+			if err != nil {
+				return err
+			}
+			if output == "" {
+				fmt.Fprint(cmd.OutOrStdout(), out)
+				return nil
+			}
+			return ioutil.WriteFile(output, []byte(out), 0644)
+		},
+	}
+)
+
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println("Error running rbacgen:")
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.Flags().StringSliceVarP(&audits, "audit", "a", nil, "api-audit.yaml files of the suites")
+	rootCmd.Flags().StringVarP(&name, "name", "n", "istio-test", "name of the ClusterRole and its binding")
+	rootCmd.Flags().StringVarP(&serviceAccount, "serviceAccount", "s", "istio-test/istio-test",
+		"namespace/name of the service account the suites run as")
+	rootCmd.Flags().StringVarP(&output, "outputFile", "o", "", "output YAML file, or stdout if empty")
+}
+
+// Generate the service account, and a ClusterRole granting it the calls, as YAML.
+func Generate(name, serviceAccount string, recs []apiaudit.Record) (string, error) {
+	parts := strings.Split(serviceAccount, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("service account must be namespace/name, got %q", serviceAccount)
+	}
+	if len(recs) == 0 {
+		return "", fmt.Errorf("no API calls to derive RBAC from")
+	}
+	objs := []interface{}{
+		&kubeApiCore.ServiceAccount{
+			TypeMeta:   kubeApiMeta.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: parts[1], Namespace: parts[0]},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   kubeApiMeta.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: name},
+			Rules:      apiaudit.Rules(recs),
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   kubeApiMeta.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: kubeApiMeta.ObjectMeta{Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			Subjects: []rbacv1.Subject{{
+				Kind:      rbacv1.ServiceAccountKind,
+				Namespace: parts[0],
+				Name:      parts[1],
+			}},
+		},
+	}
+	texts := make([]string, 0, len(objs))
+	for _, o := range objs {
+		by, err := yaml.Marshal(o)
+		if err != nil {
+			return "", err
+		}
+		texts = append(texts, string(by))
+	}
+	return yml.JoinString(texts...), nil
+}
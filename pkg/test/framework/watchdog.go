@@ -0,0 +1,39 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package framework
+
+import (
+	"time"
+
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/kube"
+)
+
+const watchdogInterval = 5 * time.Second
+
+// newWatchdog returns a watchdog monitoring istiod and the gateways, as well as the echo workloads in the test
+// namespaces, of every cluster in the environment.
+func newWatchdog(env resource.Environment) *kube.Watchdog {
+	targets := make([]kube.WatchdogTarget, 0, len(env.Clusters()))
+	for _, c := range env.Clusters() {
+		targets = append(targets, kube.WatchdogTarget{
+			Cluster:           c.Name(),
+			Client:            c,
+			Selectors:         []string{"app=istiod", "istio"},
+			NamespaceSelector: "istio-testing=istio-test",
+		})
+	}
+	return kube.NewWatchdog(watchdogInterval, targets...)
+}
@@ -0,0 +1,233 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiPolicy "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/pkg/test/scopes"
+	testRetry "istio.io/istio/pkg/test/util/retry"
+)
+
+// EvictPod evicts the pod using the Eviction API, honoring PodDisruptionBudgets and the pod's termination grace
+// period. If the eviction is rejected because of a disruption budget, it is retried until the options time out.
+func EvictPod(a kubernetes.Interface, ns, name string, opts ...testRetry.Option) error {
+	scopes.Kube.Infof("Evicting pod %s/%s", ns, name)
+	_, err := testRetry.Do(func() (interface{}, bool, error) {
+		err := a.CoreV1().Pods(ns).Evict(context.TODO(), &kubeApiPolicy.Eviction{
+			ObjectMeta: kubeApiMeta.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+			},
+		})
+		switch {
+		case err == nil, errors.IsNotFound(err):
+			return nil, true, nil
+		case errors.IsTooManyRequests(err):
+			// Blocked by a PodDisruptionBudget.
+			return nil, false, err
+		default:
+			return nil, true, err
+		}
+	}, newRetryOptions(opts...)...)
+	return err
+}
+
+// EvictPodOrFail calls EvictPod and fails the test if it returns an error.
+func EvictPodOrFail(t test.Failer, a kubernetes.Interface, ns, name string, opts ...testRetry.Option) {
+	t.Helper()
+	if err := EvictPod(a, ns, name, opts...); err != nil {
+		t.Fatalf("failed evicting pod %s/%s: %v", ns, name, err)
+	}
+}
+
+// CordonNode marks the node as unschedulable.
+func CordonNode(a kubernetes.Interface, node string) error {
+	scopes.Kube.Infof("Cordoning node %s", node)
+	return setNodeUnschedulable(a, node, true)
+}
+
+// UncordonNode marks the node as schedulable.
+func UncordonNode(a kubernetes.Interface, node string) error {
+	scopes.Kube.Infof("Uncordoning node %s", node)
+	return setNodeUnschedulable(a, node, false)
+}
+
+// CordonNodeOrFail calls CordonNode and fails the test if it returns an error. The node is uncordoned
+// when the test completes.
+func CordonNodeOrFail(t test.Failer, a kubernetes.Interface, node string) {
+	t.Helper()
+	if err := CordonNode(a, node); err != nil {
+		t.Fatalf("failed cordoning node %s: %v", node, err)
+	}
+	t.Cleanup(func() {
+		if err := UncordonNode(a, node); err != nil {
+			scopes.Kube.Errorf("failed uncordoning node %s: %v", node, err)
+		}
+	})
+}
+
+func setNodeUnschedulable(a kubernetes.Interface, node string, unschedulable bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		n, err := a.CoreV1().Nodes().Get(context.TODO(), node, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if n.Spec.Unschedulable == unschedulable {
+			return nil
+		}
+		n.Spec.Unschedulable = unschedulable
+		_, err = a.CoreV1().Nodes().Update(context.TODO(), n, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+}
+
+// NotReadyOptions configures SetNodeNotReady.
+type NotReadyOptions struct {
+	// Image of the privileged pod that stops the kubelet. It must provide sh and nsenter, such as the proxyv2 image.
+	// Required.
+	Image string
+
+	// Duration is how long the kubelet is stopped for. As the pod cannot be reached while the kubelet is stopped, it
+	// starts the kubelet again on its own once the duration has passed. Defaults to 2m.
+	Duration time.Duration
+}
+
+// SetNodeNotReady makes the node NotReady by stopping its kubelet from a privileged pod running on the node, so that
+// the node controller marks the node NotReady and taints it just as it would for a failed node. The kubelet is
+// started again after the configured duration; RestoreNodeReady waits for that and cleans up the pod.
+func SetNodeNotReady(a kubernetes.Interface, node string, opts NotReadyOptions, retryOpts ...testRetry.Option) error {
+	if opts.Image == "" {
+		return fmt.Errorf("image must be provided")
+	}
+	if opts.Duration <= 0 {
+		opts.Duration = 2 * time.Minute
+	}
+	scopes.Kube.Infof("Stopping the kubelet of node %s for %v", node, opts.Duration)
+	if _, err := a.CoreV1().Pods(notReadyNamespace).Create(context.TODO(), notReadyPod(node, opts),
+		kubeApiMeta.CreateOptions{}); err != nil {
+		return fmt.Errorf("failed creating pod to stop the kubelet of node %s: %v", node, err)
+	}
+	return waitForNodeReady(a, node, false, retryOpts...)
+}
+
+// RestoreNodeReady waits until the node made NotReady by SetNodeNotReady is Ready again, and deletes the pod that
+// stopped its kubelet.
+func RestoreNodeReady(a kubernetes.Interface, node string, opts ...testRetry.Option) error {
+	scopes.Kube.Infof("Waiting for node %s to be Ready", node)
+	if err := waitForNodeReady(a, node, true, opts...); err != nil {
+		return err
+	}
+	err := a.CoreV1().Pods(notReadyNamespace).Delete(context.TODO(), notReadyPodName(node), kubeApiMeta.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed deleting pod that stopped the kubelet of node %s: %v", node, err)
+	}
+	return nil
+}
+
+// SetNodeNotReadyOrFail calls SetNodeNotReady and fails the test if it returns an error. The node is restored
+// when the test completes.
+func SetNodeNotReadyOrFail(t test.Failer, a kubernetes.Interface, node string, opts NotReadyOptions,
+	retryOpts ...testRetry.Option) {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := RestoreNodeReady(a, node, retryOpts...); err != nil {
+			scopes.Kube.Errorf("failed restoring node %s: %v", node, err)
+		}
+	})
+	if err := SetNodeNotReady(a, node, opts, retryOpts...); err != nil {
+		t.Fatalf("failed marking node %s NotReady: %v", node, err)
+	}
+}
+
+const notReadyNamespace = "kube-system"
+
+func notReadyPodName(node string) string {
+	return "not-ready-" + node
+}
+
+func notReadyPod(node string, opts NotReadyOptions) *kubeApiCore.Pod {
+	privileged := true
+	// The kubelet is stopped and started in the namespaces of the host.
+	kubelet := "nsenter --target 1 --mount --uts --ipc --net --pid -- systemctl %s kubelet"
+	return &kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:        notReadyPodName(node),
+			Namespace:   notReadyNamespace,
+			Annotations: map[string]string{"sidecar.istio.io/inject": "false"},
+		},
+		Spec: kubeApiCore.PodSpec{
+			NodeName:      node,
+			HostPID:       true,
+			RestartPolicy: kubeApiCore.RestartPolicyNever,
+			// Keep the pod on the node once it is tainted, so that it can start the kubelet again.
+			Tolerations: []kubeApiCore.Toleration{{Operator: kubeApiCore.TolerationOpExists}},
+			Containers: []kubeApiCore.Container{{
+				Name:            "kubelet",
+				Image:           opts.Image,
+				SecurityContext: &kubeApiCore.SecurityContext{Privileged: &privileged},
+				Command: []string{"/bin/sh", "-c", fmt.Sprintf("%s; sleep %d; %s",
+					fmt.Sprintf(kubelet, "stop"), int(opts.Duration.Seconds()), fmt.Sprintf(kubelet, "start"))},
+			}},
+		},
+	}
+}
+
+func waitForNodeReady(a kubernetes.Interface, node string, ready bool, opts ...testRetry.Option) error {
+	_, err := testRetry.Do(func() (interface{}, bool, error) {
+		n, err := a.CoreV1().Nodes().Get(context.TODO(), node, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return nil, false, err
+		}
+		if nodeReady(n) != ready {
+			return nil, false, fmt.Errorf("node %s Ready is not %v", node, ready)
+		}
+		return nil, true, nil
+	}, newRetryOptions(opts...)...)
+	return err
+}
+
+// nodeReady returns true if the Ready condition of the node is True. The condition is Unknown once the kubelet stops
+// reporting the status of the node.
+func nodeReady(n *kubeApiCore.Node) bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == kubeApiCore.NodeReady {
+			return c.Status == kubeApiCore.ConditionTrue
+		}
+	}
+	return false
+}
+
+// NodeForPod returns the name of the node the pod is scheduled on.
+func NodeForPod(a kubernetes.Interface, ns, name string) (string, error) {
+	pod, err := a.CoreV1().Pods(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if pod.Spec.NodeName == "" {
+		return "", fmt.Errorf("pod %s/%s is not scheduled", ns, name)
+	}
+	return pod.Spec.NodeName, nil
+}
@@ -0,0 +1,96 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func readyNode(status kubeApiCore.ConditionStatus) *kubeApiCore.Node {
+	return &kubeApiCore.Node{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "node"},
+		Status: kubeApiCore.NodeStatus{Conditions: []kubeApiCore.NodeCondition{
+			{Type: kubeApiCore.NodeReady, Status: status},
+		}},
+	}
+}
+
+// setReady updates the Ready condition of the node once the pod stopping its kubelet exists, as the node controller
+// would.
+func setReady(t *testing.T, a kubernetes.Interface, status kubeApiCore.ConditionStatus) {
+	go func() {
+		_ = retry.UntilSuccess(func() error {
+			_, err := a.CoreV1().Pods(notReadyNamespace).Get(context.TODO(), notReadyPodName("node"), kubeApiMeta.GetOptions{})
+			return err
+		}, retry.Delay(10*time.Millisecond), retry.Timeout(10*time.Second))
+		if _, err := a.CoreV1().Nodes().UpdateStatus(context.TODO(), readyNode(status), kubeApiMeta.UpdateOptions{}); err != nil {
+			t.Error(err)
+		}
+	}()
+}
+
+func TestSetNodeNotReady(t *testing.T) {
+	a := fake.NewSimpleClientset(readyNode(kubeApiCore.ConditionTrue))
+	opts := []retry.Option{retry.Delay(10 * time.Millisecond), retry.Timeout(10 * time.Second)}
+
+	setReady(t, a, kubeApiCore.ConditionUnknown)
+	if err := SetNodeNotReady(a, "node", NotReadyOptions{Image: "proxyv2", Duration: time.Minute}, opts...); err != nil {
+		t.Fatal(err)
+	}
+	pod, err := a.CoreV1().Pods(notReadyNamespace).Get(context.TODO(), notReadyPodName("node"), kubeApiMeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pod.Spec.NodeName != "node" || !pod.Spec.HostPID || !*pod.Spec.Containers[0].SecurityContext.Privileged {
+		t.Fatalf("expected a privileged pod in the host PID namespace of the node, got %+v", pod.Spec)
+	}
+	script := pod.Spec.Containers[0].Command[2]
+	for _, want := range []string{"systemctl stop kubelet; sleep 60; ", "systemctl start kubelet"} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("expected %q in the script, got %q", want, script)
+		}
+	}
+
+	setReady(t, a, kubeApiCore.ConditionTrue)
+	if err := RestoreNodeReady(a, "node", opts...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.CoreV1().Pods(notReadyNamespace).Get(context.TODO(), notReadyPodName("node"),
+		kubeApiMeta.GetOptions{}); err == nil {
+		t.Fatal("expected the pod to be deleted once the node is Ready")
+	}
+}
+
+func TestSetNodeNotReadyTimeout(t *testing.T) {
+	a := fake.NewSimpleClientset(readyNode(kubeApiCore.ConditionTrue))
+	err := SetNodeNotReady(a, "node", NotReadyOptions{Image: "proxyv2"}, retry.Delay(10*time.Millisecond),
+		retry.Timeout(100*time.Millisecond))
+	if err == nil {
+		t.Fatal("expected a node that stays Ready to time out")
+	}
+	if err := SetNodeNotReady(a, "node", NotReadyOptions{}); err == nil {
+		t.Fatal("expected an error without an image")
+	}
+}
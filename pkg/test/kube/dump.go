@@ -39,7 +39,7 @@ type PodDumper func(ctx resource.Context, cluster resource.Cluster, workDir stri
 func outputPath(workDir string, cluster resource.Cluster, pod corev1.Pod, name string) string {
 	dir := path.Join(workDir, cluster.Name())
 	if err := os.MkdirAll(dir, os.ModeDir|0700); err != nil {
-		scopes.Framework.Warnf("failed creating directory: %s", dir)
+		scopes.CI.Warnf("failed creating directory: %s", dir)
 	}
 	return path.Join(dir, fmt.Sprintf("%s_%s", pod.Name, name))
 }
@@ -61,7 +61,7 @@ func DumpPods(ctx resource.Context, workDir, namespace string, dumpers ...PodDum
 	for _, cluster := range ctx.Clusters() {
 		pods, err := cluster.PodsForSelector(context.TODO(), namespace)
 		if err != nil {
-			scopes.Framework.Errorf("Error getting pods list via kubectl: %v", err)
+			scopes.CI.Errorf("Error getting pods list via kubectl: %v", err)
 			return
 		}
 		for _, dump := range dumpers {
@@ -80,7 +80,7 @@ func podsOrFetch(a resource.Cluster, pods []corev1.Pod, namespace string) []core
 	if len(pods) == 0 {
 		podList, err := a.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
 		if err != nil {
-			scopes.Framework.Errorf("Error getting pods list via kubectl: %v", err)
+			scopes.CI.Errorf("Error getting pods list via kubectl: %v", err)
 			return nil
 		}
 		pods = podList.Items
@@ -99,13 +99,13 @@ func DumpPodState(_ resource.Context, c resource.Cluster, workDir string, namesp
 	for _, pod := range pods {
 		str, err := marshaler.MarshalToString(&pod)
 		if err != nil {
-			scopes.Framework.Errorf("Error marshaling pod state for output: %v", err)
+			scopes.CI.Errorf("Error marshaling pod state for output: %v", err)
 			continue
 		}
 
 		outPath := outputPath(workDir, c, pod, "pod-state.yaml")
 		if err := ioutil.WriteFile(outPath, []byte(str), os.ModePerm); err != nil {
-			scopes.Framework.Infof("Error writing out pod state to file: %v", err)
+			scopes.CI.Infof("Error writing out pod state to file: %v", err)
 		}
 	}
 }
@@ -124,7 +124,7 @@ func DumpPodEvents(_ resource.Context, c resource.Cluster, workDir, namespace st
 				FieldSelector: "involvedObject.name=" + pod.Name,
 			})
 		if err != nil {
-			scopes.Framework.Errorf("Error getting events list for pod %s/%s via kubectl: %v", namespace, pod.Name, err)
+			scopes.CI.Errorf("Error getting events list for pod %s/%s via kubectl: %v", namespace, pod.Name, err)
 			return
 		}
 
@@ -132,7 +132,7 @@ func DumpPodEvents(_ resource.Context, c resource.Cluster, workDir, namespace st
 		for _, event := range list.Items {
 			eventStr, err := marshaler.MarshalToString(&event)
 			if err != nil {
-				scopes.Framework.Errorf("Error marshaling pod event for output: %v", err)
+				scopes.CI.Errorf("Error marshaling pod event for output: %v", err)
 				continue
 			}
 
@@ -142,7 +142,7 @@ func DumpPodEvents(_ resource.Context, c resource.Cluster, workDir, namespace st
 
 		outPath := outputPath(workDir, c, pod, "pod-events.yaml")
 		if err := ioutil.WriteFile(outPath, []byte(eventsStr), os.ModePerm); err != nil {
-			scopes.Framework.Infof("Error writing out pod events to file: %v", err)
+			scopes.CI.Infof("Error writing out pod events to file: %v", err)
 		}
 	}
 }
@@ -158,13 +158,13 @@ func DumpPodLogs(_ resource.Context, c resource.Cluster, workDir, namespace stri
 		for _, container := range containers {
 			l, err := c.PodLogs(context.TODO(), pod.Name, pod.Namespace, container.Name, false /* previousLog */)
 			if err != nil {
-				scopes.Framework.Errorf("Unable to get logs for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
+				scopes.CI.Errorf("Unable to get logs for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
 				continue
 			}
 
 			fname := outputPath(workDir, c, pod, fmt.Sprintf("%s.log", container.Name))
 			if err = ioutil.WriteFile(fname, []byte(l), os.ModePerm); err != nil {
-				scopes.Framework.Errorf("Unable to write logs for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
+				scopes.CI.Errorf("Unable to write logs for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
 			}
 
 			// Get envoy logs if the pod is a VM, since kubectl logs only shows the logs from iptables for VMs
@@ -172,19 +172,19 @@ func DumpPodLogs(_ resource.Context, c resource.Cluster, workDir, namespace stri
 				if stdout, stderr, err := c.PodExec(pod.Name, pod.Namespace, container.Name, "cat /var/log/istio/istio.err.log"); err == nil {
 					fname := outputPath(workDir, c, pod, fmt.Sprintf("%s.envoy.err.log", container.Name))
 					if err = ioutil.WriteFile(fname, []byte(stdout+stderr), os.ModePerm); err != nil {
-						scopes.Framework.Errorf("Unable to write envoy err log for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
+						scopes.CI.Errorf("Unable to write envoy err log for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
 					}
 				} else {
-					scopes.Framework.Errorf("Unable to get envoy err log for pod: %s/%s", pod.Namespace, pod.Name)
+					scopes.CI.Errorf("Unable to get envoy err log for pod: %s/%s", pod.Namespace, pod.Name)
 				}
 
 				if stdout, stderr, err := c.PodExec(pod.Name, pod.Namespace, container.Name, "cat /var/log/istio/istio.log"); err == nil {
 					fname := outputPath(workDir, c, pod, fmt.Sprintf("%s.envoy.log", container.Name))
 					if err = ioutil.WriteFile(fname, []byte(stdout+stderr), os.ModePerm); err != nil {
-						scopes.Framework.Errorf("Unable to write envoy log for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
+						scopes.CI.Errorf("Unable to write envoy log for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
 					}
 				} else {
-					scopes.Framework.Errorf("Unable to get envoy log for pod: %s/%s", pod.Namespace, pod.Name)
+					scopes.CI.Errorf("Unable to get envoy log for pod: %s/%s", pod.Namespace, pod.Name)
 				}
 			}
 		}
@@ -206,19 +206,19 @@ func DumpPodProxies(_ resource.Context, c resource.Cluster, workDir, namespace s
 			if cfgDump, _, err := c.PodExec(pod.Name, pod.Namespace, container.Name, "pilot-agent request GET config_dump"); err == nil {
 				fname := outputPath(workDir, c, pod, "proxy-config.json")
 				if err = ioutil.WriteFile(fname, []byte(cfgDump), os.ModePerm); err != nil {
-					scopes.Framework.Errorf("Unable to write config dump for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
+					scopes.CI.Errorf("Unable to write config dump for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
 				}
 			} else {
-				scopes.Framework.Errorf("Unable to get istio-proxy config dump for pod: %s/%s", pod.Namespace, pod.Name)
+				scopes.CI.Errorf("Unable to get istio-proxy config dump for pod: %s/%s", pod.Namespace, pod.Name)
 			}
 
 			if cfgDump, _, err := c.PodExec(pod.Name, pod.Namespace, container.Name, "pilot-agent request GET clusters"); err == nil {
 				fname := outputPath(workDir, c, pod, "proxy-clusters.txt")
 				if err = ioutil.WriteFile(fname, []byte(cfgDump), os.ModePerm); err != nil {
-					scopes.Framework.Errorf("Unable to write clusters for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
+					scopes.CI.Errorf("Unable to write clusters for pod/container: %s/%s/%s", pod.Namespace, pod.Name, container.Name)
 				}
 			} else {
-				scopes.Framework.Errorf("Unable to get istio-proxy clusters for pod: %s/%s", pod.Namespace, pod.Name)
+				scopes.CI.Errorf("Unable to get istio-proxy clusters for pod: %s/%s", pod.Namespace, pod.Name)
 			}
 		}
 	}
@@ -236,20 +236,20 @@ func checkIfVM(pod corev1.Pod) bool {
 func DumpNdsz(ctx resource.Context, c resource.Cluster, workDir string, namespace string, pods ...corev1.Pod) {
 	cp, istiod, err := getControlPlane(ctx, c)
 	if err != nil {
-		scopes.Framework.Errorf("failed dumping ndsz: %v", err)
+		scopes.CI.Errorf("failed dumping ndsz: %v", err)
 		return
 	}
 	for _, p := range pods {
 		endpoint := fmt.Sprintf("/debug/ndsz?proxyID=%s.%s", p.Name, p.Namespace)
 		out, err := dumpDebug(cp, istiod, endpoint)
 		if err != nil {
-			scopes.Framework.Errorf("failed dumping ndsz: %v", err)
+			scopes.CI.Errorf("failed dumping ndsz: %v", err)
 			continue
 		}
 		// dump to the cluster directory for the proxy
 		outPath := outputPath(workDir, c, p, "ndsz.json")
 		if err := ioutil.WriteFile(outPath, []byte(out), 0644); err != nil {
-			scopes.Framework.Errorf("failed dumping ndsz: %v", err)
+			scopes.CI.Errorf("failed dumping ndsz: %v", err)
 		}
 	}
 }
@@ -268,7 +268,11 @@ func dumpDebug(cp resource.Cluster, istiodPod corev1.Pod, endpoint string) (stri
 
 func getControlPlane(ctx resource.Context, cluster resource.Cluster) (resource.Cluster, corev1.Pod, error) {
 	// fetch istiod from the control-plane cluster
-	cp, err := ctx.Environment().(*kube.Environment).GetControlPlaneCluster(cluster)
+	env, err := kube.EnvironmentFrom(ctx)
+	if err != nil {
+		return nil, corev1.Pod{}, err
+	}
+	cp, err := env.GetControlPlaneCluster(cluster)
 	if err != nil {
 		return nil, corev1.Pod{}, err
 	}
@@ -0,0 +1,127 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	istioKube "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test"
+	"istio.io/istio/security/pkg/util"
+)
+
+const (
+	// IstioTokenPath is the path of the projected service account token used by the Istio agent.
+	IstioTokenPath = "/var/run/secrets/tokens/istio-token"
+
+	// EKSTokenPath is the path of the projected service account token mounted by the EKS pod identity webhook
+	// for pods using IAM Roles for Service Accounts (IRSA).
+	EKSTokenPath = "/var/run/secrets/eks.amazonaws.com/serviceaccount/token"
+
+	// EKSTokenAudience is the audience of the token mounted by the EKS pod identity webhook.
+	EKSTokenAudience = "sts.amazonaws.com"
+
+	// EKSRoleARNAnnotation is the service account annotation that associates an IAM role with the service account.
+	EKSRoleARNAnnotation = "eks.amazonaws.com/role-arn"
+)
+
+// TokenClaims are the claims of a projected service account token that are relevant to token exchange.
+type TokenClaims struct {
+	Issuer    string
+	Subject   string
+	Audiences []string
+	IssuedAt  time.Time
+	Expiry    time.Time
+}
+
+// Lifetime returns the validity period of the token.
+func (c *TokenClaims) Lifetime() time.Duration {
+	return c.Expiry.Sub(c.IssuedAt)
+}
+
+// Check verifies that the token is issued for the given audience, is currently valid, and has a lifetime no
+// longer than maxLifetime. A maxLifetime of zero skips the lifetime check.
+func (c *TokenClaims) Check(audience string, maxLifetime time.Duration) error {
+	found := false
+	for _, aud := range c.Audiences {
+		if aud == audience {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("token audiences %v do not include %q", c.Audiences, audience)
+	}
+	if c.Expiry.IsZero() {
+		return fmt.Errorf("token for %s has no expiry", c.Subject)
+	}
+	if time.Now().After(c.Expiry) {
+		return fmt.Errorf("token for %s expired at %v", c.Subject, c.Expiry)
+	}
+	if maxLifetime > 0 && c.Lifetime() > maxLifetime {
+		return fmt.Errorf("token for %s has lifetime %v, expected at most %v", c.Subject, c.Lifetime(), maxLifetime)
+	}
+	return nil
+}
+
+// ReadProjectedToken reads the service account token at the given path in the pod container, and returns its claims.
+// The token signature is not verified.
+func ReadProjectedToken(a istioKube.ExtendedClient, ns, pod, container, path string) (*TokenClaims, error) {
+	stdout, stderr, err := a.PodExec(pod, ns, container, "cat "+path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading token %s from %s/%s: %v: %s", path, ns, pod, err, stderr)
+	}
+	return ParseTokenClaims(strings.TrimSpace(stdout))
+}
+
+// ReadProjectedTokenOrFail calls ReadProjectedToken and fails the test if it returns an error.
+func ReadProjectedTokenOrFail(t test.Failer, a istioKube.ExtendedClient, ns, pod, container, path string) *TokenClaims {
+	t.Helper()
+	c, err := ReadProjectedToken(a, ns, pod, container, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// ParseTokenClaims decodes the claims of the JWT, without verifying it.
+func ParseTokenClaims(token string) (*TokenClaims, error) {
+	raw, err := util.ParseJwtClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	claims := &TokenClaims{
+		IssuedAt: unixClaim(raw["iat"]),
+		Expiry:   unixClaim(raw["exp"]),
+	}
+	claims.Issuer, _ = raw["iss"].(string)
+	claims.Subject, _ = raw["sub"].(string)
+	if raw["aud"] != nil {
+		if claims.Audiences, err = util.GetAud(token); err != nil {
+			return nil, fmt.Errorf("failed decoding token audience: %v", err)
+		}
+	}
+	return claims, nil
+}
+
+// unixClaim returns the time of a numeric date claim, or the zero time if it is not set.
+func unixClaim(v interface{}) time.Time {
+	if n, ok := v.(float64); ok && n > 0 {
+		return time.Unix(int64(n), 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,123 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".c2ln"
+}
+
+func TestParseTokenClaims(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	claims, err := ParseTokenClaims(testToken(t, map[string]interface{}{
+		"iss": "https://kubernetes.default.svc",
+		"sub": "system:serviceaccount:default:a",
+		"aud": []string{"istio-ca", EKSTokenAudience},
+		"iat": now.Unix(),
+		"exp": now.Add(12 * time.Hour).Unix(),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &TokenClaims{
+		Issuer:    "https://kubernetes.default.svc",
+		Subject:   "system:serviceaccount:default:a",
+		Audiences: []string{"istio-ca", EKSTokenAudience},
+		IssuedAt:  now,
+		Expiry:    now.Add(12 * time.Hour),
+	}
+	if !reflect.DeepEqual(claims, want) {
+		t.Fatalf("got %+v, want %+v", claims, want)
+	}
+	if claims.Lifetime() != 12*time.Hour {
+		t.Fatalf("got lifetime %v, want 12h", claims.Lifetime())
+	}
+
+	// A single audience is a string rather than a list, and an unbound token has no audience or expiry.
+	single, err := ParseTokenClaims(testToken(t, map[string]interface{}{"aud": "istio-ca"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(single.Audiences, []string{"istio-ca"}) {
+		t.Fatalf("got audiences %v, want [istio-ca]", single.Audiences)
+	}
+	unbound, err := ParseTokenClaims(testToken(t, map[string]interface{}{"sub": "a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unbound.Audiences != nil || !unbound.Expiry.IsZero() {
+		t.Fatalf("expected no audiences or expiry, got %+v", unbound)
+	}
+
+	if _, err := ParseTokenClaims("not a token"); err == nil {
+		t.Fatal("expected an error for a malformed token")
+	}
+}
+
+func TestTokenClaimsCheck(t *testing.T) {
+	now := time.Now()
+	valid := TokenClaims{
+		Subject:   "a",
+		Audiences: []string{"istio-ca"},
+		IssuedAt:  now.Add(-time.Hour),
+		Expiry:    now.Add(11 * time.Hour),
+	}
+	expired := valid
+	expired.Expiry = now.Add(-time.Minute)
+	noExpiry := valid
+	noExpiry.Expiry = time.Time{}
+
+	cases := []struct {
+		name        string
+		claims      TokenClaims
+		audience    string
+		maxLifetime time.Duration
+		errMsg      string
+	}{
+		{name: "valid", claims: valid, audience: "istio-ca", maxLifetime: 12 * time.Hour},
+		{name: "lifetime unchecked", claims: valid, audience: "istio-ca"},
+		{name: "other audience", claims: valid, audience: EKSTokenAudience, errMsg: "do not include"},
+		{name: "no expiry", claims: noExpiry, audience: "istio-ca", errMsg: "has no expiry"},
+		{name: "expired", claims: expired, audience: "istio-ca", errMsg: "expired at"},
+		{name: "too long", claims: valid, audience: "istio-ca", maxLifetime: time.Hour, errMsg: "expected at most 1h0m0s"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.claims.Check(tc.audience, tc.maxLifetime)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Fatalf("expected error %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
@@ -17,13 +17,18 @@ package kube
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
 	kubeApiCore "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
+	kubeRetry "k8s.io/client-go/util/retry"
 
 	istioKube "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/test"
@@ -63,7 +68,7 @@ func NewSinglePodFetch(a istioKube.ExtendedClient, namespace string, selectors .
 		}
 
 		if len(pods.Items) > 1 {
-			scopes.Framework.Warnf("More than one pod found matching selectors: %v", selectors)
+			scopes.Kube.Warnf("More than one pod found matching selectors: %v", selectors)
 		}
 
 		return []kubeApiCore.Pod{pods.Items[0]}, nil
@@ -87,11 +92,11 @@ func NewPodMustFetch(a istioKube.ExtendedClient, namespace string, selectors ...
 
 // CheckPodsAreReady checks whether the pods that are selected by the given function is in ready state or not.
 func CheckPodsAreReady(fetchFunc PodFetchFunc) ([]kubeApiCore.Pod, error) {
-	scopes.Framework.Infof("Checking pods ready...")
+	scopes.Kube.Infof("Checking pods ready...")
 
 	fetched, err := fetchFunc()
 	if err != nil {
-		scopes.Framework.Infof("Failed retrieving pods: %v", err)
+		scopes.Kube.Infof("Failed retrieving pods: %v", err)
 		return nil, err
 	}
 
@@ -101,7 +106,7 @@ func CheckPodsAreReady(fetchFunc PodFetchFunc) ([]kubeApiCore.Pod, error) {
 			msg = e.Error()
 			err = multierror.Append(err, fmt.Errorf("%s/%s: %s", p.Namespace, p.Name, msg))
 		}
-		scopes.Framework.Infof("  [%2d] %45s %15s (%v)", i, p.Name, p.Status.Phase, msg)
+		scopes.Kube.Infof("  [%2d] %45s %15s (%v)", i, p.Name, p.Status.Phase, msg)
 	}
 
 	if err != nil {
@@ -126,7 +131,7 @@ func WaitUntilPodsAreReady(fetchFunc PodFetchFunc, opts ...retry.Option) ([]kube
 	var pods []kubeApiCore.Pod
 	_, err := retry.Do(func() (interface{}, bool, error) {
 
-		scopes.Framework.Infof("Checking pods ready...")
+		scopes.Kube.Infof("Checking pods ready...")
 
 		fetched, err := CheckPodsAreReady(fetchFunc)
 		if err != nil {
@@ -230,6 +235,176 @@ func WaitForNamespaceDeletion(a kubernetes.Interface, ns string, opts ...retry.O
 	return err
 }
 
+// ScaleDeployment sets the number of replicas of the deployment and waits until the deployment reports that all of
+// them are ready.
+func ScaleDeployment(a kubernetes.Interface, ns, name string, replicas int32, opts ...retry.Option) error {
+	scale, err := a.AppsV1().Deployments(ns).GetScale(context.TODO(), name, kubeApiMeta.GetOptions{})
+	if err != nil {
+		return err
+	}
+	scale.Spec.Replicas = replicas
+	if _, err := a.AppsV1().Deployments(ns).UpdateScale(context.TODO(), name, scale, kubeApiMeta.UpdateOptions{}); err != nil {
+		return err
+	}
+	_, err = retry.Do(func() (interface{}, bool, error) {
+		d, err := a.AppsV1().Deployments(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return nil, false, err
+		}
+		if d.Status.ObservedGeneration < d.Generation || d.Status.ReadyReplicas != replicas || d.Status.Replicas != replicas {
+			return nil, false, fmt.Errorf("deployment %s/%s has %d/%d ready replicas, expected %d",
+				ns, name, d.Status.ReadyReplicas, d.Status.Replicas, replicas)
+		}
+		return nil, true, nil
+	}, newRetryOptions(opts...)...)
+	return err
+}
+
+// SetContainerImage sets the image of the container in the deployment, waits for the rollout to complete, and returns
+// the previous image.
+func SetContainerImage(a kubernetes.Interface, ns, name, container, image string, opts ...retry.Option) (string, error) {
+	var previous string
+	err := kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		d, err := a.AppsV1().Deployments(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		found := false
+		for i, c := range d.Spec.Template.Spec.Containers {
+			if c.Name == container {
+				previous = c.Image
+				d.Spec.Template.Spec.Containers[i].Image = image
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("deployment %s/%s has no container %s", ns, name, container)
+		}
+		_, err = a.AppsV1().Deployments(ns).Update(context.TODO(), d, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return previous, WaitForDeploymentRollout(a, ns, name, opts...)
+}
+
+// SetContainerArgs sets the args of the container in the deployment, waits for the rollout to complete, and returns
+// the previous args.
+func SetContainerArgs(a kubernetes.Interface, ns, name, container string, args []string,
+	opts ...retry.Option) ([]string, error) {
+	var previous []string
+	err := kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+		d, err := a.AppsV1().Deployments(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		found := false
+		for i, c := range d.Spec.Template.Spec.Containers {
+			if c.Name == container {
+				previous = c.Args
+				d.Spec.Template.Spec.Containers[i].Args = args
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("deployment %s/%s has no container %s", ns, name, container)
+		}
+		_, err = a.AppsV1().Deployments(ns).Update(context.TODO(), d, kubeApiMeta.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return previous, WaitForDeploymentRollout(a, ns, name, opts...)
+}
+
+// WaitForDeploymentRollout waits until all of the replicas of the deployment are updated to the latest revision and
+// ready.
+func WaitForDeploymentRollout(a kubernetes.Interface, ns, name string, opts ...retry.Option) error {
+	_, err := retry.Do(func() (interface{}, bool, error) {
+		d, err := a.AppsV1().Deployments(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return nil, false, err
+		}
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		if d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedReplicas != replicas ||
+			d.Status.ReadyReplicas != replicas || d.Status.Replicas != replicas {
+			return nil, false, fmt.Errorf("deployment %s/%s has %d/%d updated and %d ready replicas, expected %d",
+				ns, name, d.Status.UpdatedReplicas, d.Status.Replicas, d.Status.ReadyReplicas, replicas)
+		}
+		return nil, true, nil
+	}, newRetryOptions(opts...)...)
+	return err
+}
+
+// RestartDeployments triggers a rolling restart of the deployments matching the label selector, as done by
+// `kubectl rollout restart`, and waits for the rollouts to complete.
+func RestartDeployments(a kubernetes.Interface, ns, selector string, opts ...retry.Option) error {
+	names, err := RolloutRestart(a, ns, selector)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := WaitForDeploymentRollout(a, ns, name, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RolloutRestart triggers a rolling restart of the deployments matching the label selector without waiting for the
+// rollouts, and returns the names of the deployments.
+func RolloutRestart(a kubernetes.Interface, ns, selector string) ([]string, error) {
+	deployments, err := a.AppsV1().Deployments(ns).List(context.TODO(), kubeApiMeta.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	restartedAt := time.Now().Format(time.RFC3339)
+	names := make([]string, 0, len(deployments.Items))
+	for _, d := range deployments.Items {
+		name := d.Name
+		err := kubeRetry.RetryOnConflict(kubeRetry.DefaultRetry, func() error {
+			d, err := a.AppsV1().Deployments(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if d.Spec.Template.Annotations == nil {
+				d.Spec.Template.Annotations = map[string]string{}
+			}
+			d.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = restartedAt
+			_, err = a.AppsV1().Deployments(ns).Update(context.TODO(), d, kubeApiMeta.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed restarting deployment %s/%s: %v", ns, name, err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// PodSecurityViolations returns the messages of the events reporting pods in the namespace that were rejected by
+// PodSecurity admission.
+func PodSecurityViolations(a kubernetes.Interface, ns string) ([]string, error) {
+	events, err := a.CoreV1().Events(ns).List(context.TODO(), kubeApiMeta.ListOptions{
+		FieldSelector: "reason=FailedCreate",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range events.Items {
+		if strings.Contains(e.Message, "violates PodSecurity") {
+			out = append(out, fmt.Sprintf("%s/%s: %s", e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Message))
+		}
+	}
+	return out, nil
+}
+
 // NamespaceExists returns true if the given namespace exists.
 func NamespaceExists(a kubernetes.Interface, ns string) bool {
 	allNs, err := a.CoreV1().Namespaces().List(context.TODO(), kubeApiMeta.ListOptions{})
@@ -244,6 +419,57 @@ func NamespaceExists(a kubernetes.Interface, ns string) bool {
 	return false
 }
 
+// WaitForConfigDistribution waits until the Istio config resource with the given name/namespace reports, through
+// its status, that it has been distributed to all proxies. This requires istiod to write the distribution status
+// of config (PILOT_ENABLE_STATUS=true), otherwise the wait will time out.
+func WaitForConfigDistribution(a istioKube.ExtendedClient, gvr schema.GroupVersionResource, ns, name string,
+	opts ...retry.Option) error {
+	return retry.UntilSuccess(func() error {
+		obj, err := a.Dynamic().Resource(gvr).Namespace(ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return CheckConfigDistributed(obj)
+	}, newRetryOptions(opts...)...)
+}
+
+// CheckConfigDistributed returns an error if the status of the given Istio config resource does not show
+// that its latest generation has been distributed to all proxies.
+func CheckConfigDistributed(obj *unstructured.Unstructured) error {
+	key := fmt.Sprintf("%s %s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	// Without observedGeneration, a Reconciled condition may be left over from an earlier generation.
+	observed, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "status", "observedGeneration")
+	if !found {
+		return fmt.Errorf("%s: no observedGeneration in status", key)
+	}
+	gen, err := strconv.ParseInt(fmt.Sprint(observed), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s: failed parsing observedGeneration %v: %v", key, observed, err)
+	}
+	if gen != obj.GetGeneration() {
+		return fmt.Errorf("%s: observedGeneration %d does not match generation %d", key, gen, obj.GetGeneration())
+	}
+
+	conditions, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return fmt.Errorf("%s: failed reading status conditions: %v", key, err)
+	}
+	if !found {
+		return fmt.Errorf("%s: no distribution status reported", key)
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Reconciled" {
+			continue
+		}
+		if condition["status"] != "True" {
+			return fmt.Errorf("%s: not yet distributed: %v", key, condition["message"])
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: no Reconciled condition in status", key)
+}
+
 func newRetryOptions(opts ...retry.Option) []retry.Option {
 	out := make([]retry.Option, 0, 2+len(opts))
 	out = append(out, defaultRetryTimeout, defaultRetryDelay)
@@ -0,0 +1,209 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+func distributedConfig(generation int64, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1alpha3",
+		"kind":       "VirtualService",
+		"metadata":   map[string]interface{}{"name": "vs", "namespace": "default"},
+	}}
+	obj.SetGeneration(generation)
+	if status != nil {
+		obj.Object["status"] = status
+	}
+	return obj
+}
+
+func reconciled(status string) []interface{} {
+	return []interface{}{map[string]interface{}{"type": "Reconciled", "status": status, "message": "1/2 proxies up to date."}}
+}
+
+func TestCheckConfigDistributed(t *testing.T) {
+	cases := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		errMsg string
+	}{
+		{
+			name: "distributed",
+			obj:  distributedConfig(2, map[string]interface{}{"observedGeneration": "2", "conditions": reconciled("True")}),
+		},
+		{
+			name:   "stale without observedGeneration",
+			obj:    distributedConfig(2, map[string]interface{}{"conditions": reconciled("True")}),
+			errMsg: "no observedGeneration in status",
+		},
+		{
+			name:   "stale generation",
+			obj:    distributedConfig(2, map[string]interface{}{"observedGeneration": int64(1), "conditions": reconciled("True")}),
+			errMsg: "observedGeneration 1 does not match generation 2",
+		},
+		{
+			name:   "not reconciled",
+			obj:    distributedConfig(2, map[string]interface{}{"observedGeneration": int64(2), "conditions": reconciled("False")}),
+			errMsg: "not yet distributed: 1/2 proxies up to date.",
+		},
+		{
+			name:   "no conditions",
+			obj:    distributedConfig(2, map[string]interface{}{"observedGeneration": int64(2)}),
+			errMsg: "no distribution status reported",
+		},
+		{
+			name:   "no status",
+			obj:    distributedConfig(1, nil),
+			errMsg: "no observedGeneration in status",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckConfigDistributed(tc.obj)
+			if tc.errMsg == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.errMsg) {
+				t.Fatalf("expected error %q, got %v", tc.errMsg, err)
+			}
+		})
+	}
+}
+
+// scalableClient serves the scale subresource of deployments, which the fake clientset does not implement, and
+// stands in for the deployment controller, reporting unready of the scaled replicas as not ready.
+func scalableClient(unready int32) *fake.Clientset {
+	client := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "a", Namespace: "default", Generation: 1},
+	})
+	gvr := appsv1.SchemeGroupVersion.WithResource("deployments")
+	client.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.GetAction).GetName()
+		if _, err := client.Tracker().Get(gvr, action.GetNamespace(), name); err != nil {
+			return true, nil, err
+		}
+		return true, &autoscalingv1.Scale{ObjectMeta: kubeApiMeta.ObjectMeta{Name: name, Namespace: action.GetNamespace()}}, nil
+	})
+	client.PrependReactor("update", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "scale" {
+			return false, nil, nil
+		}
+		scale := action.(k8stesting.UpdateAction).GetObject().(*autoscalingv1.Scale)
+		obj, err := client.Tracker().Get(gvr, scale.Namespace, scale.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		d := obj.(*appsv1.Deployment)
+		replicas := scale.Spec.Replicas
+		d.Spec.Replicas = &replicas
+		d.Generation++
+		d.Status = appsv1.DeploymentStatus{
+			ObservedGeneration: d.Generation,
+			Replicas:           replicas,
+			ReadyReplicas:      replicas - unready,
+		}
+		return true, scale, client.Tracker().Update(gvr, d, scale.Namespace)
+	})
+	return client
+}
+
+func TestScaleDeployment(t *testing.T) {
+	client := scalableClient(0)
+	if err := ScaleDeployment(client, "default", "a", 3, retry.Timeout(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := client.AppsV1().Deployments("default").Get(context.TODO(), "a", kubeApiMeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *d.Spec.Replicas != 3 {
+		t.Fatalf("got %d replicas, want 3", *d.Spec.Replicas)
+	}
+
+	err = ScaleDeployment(scalableClient(1), "default", "a", 3, retry.Timeout(100*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "has 2/3 ready replicas, expected 3") {
+		t.Fatalf("expected the unready replica to be reported, got %v", err)
+	}
+	if err := ScaleDeployment(client, "default", "missing", 1); err == nil {
+		t.Fatal("expected an error scaling a missing deployment")
+	}
+}
+
+func imageDeployment(ready int32, images map[string]string) *appsv1.Deployment {
+	replicas := int32(1)
+	d := &appsv1.Deployment{
+		ObjectMeta: kubeApiMeta.ObjectMeta{Name: "a", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, ReadyReplicas: ready},
+	}
+	for name, image := range images {
+		d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, kubeApiCore.Container{Name: name, Image: image})
+	}
+	return d
+}
+
+func TestSetContainerImage(t *testing.T) {
+	client := fake.NewSimpleClientset(imageDeployment(1, map[string]string{"istio-proxy": "proxyv2:1.7.0", "app": "app:1"}))
+	previous, err := SetContainerImage(client, "default", "a", "istio-proxy", "proxyv2:1.8.0", retry.Timeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != "proxyv2:1.7.0" {
+		t.Fatalf("got previous image %s, want proxyv2:1.7.0", previous)
+	}
+	d, err := client.AppsV1().Deployments("default").Get(context.TODO(), "a", kubeApiMeta.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range d.Spec.Template.Spec.Containers {
+		want := map[string]string{"istio-proxy": "proxyv2:1.8.0", "app": "app:1"}[c.Name]
+		if c.Image != want {
+			t.Fatalf("got image %s for container %s, want %s", c.Image, c.Name, want)
+		}
+	}
+
+	_, err = SetContainerImage(client, "default", "a", "missing", "proxyv2:1.8.0", retry.Timeout(time.Second))
+	if err == nil || !strings.Contains(err.Error(), "has no container missing") {
+		t.Fatalf("expected the missing container to be reported, got %v", err)
+	}
+
+	unready := fake.NewSimpleClientset(imageDeployment(0, map[string]string{"istio-proxy": "proxyv2:1.7.0"}))
+	_, err = SetContainerImage(unready, "default", "a", "istio-proxy", "proxyv2:1.8.0", retry.Timeout(100*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "0 ready replicas, expected 1") {
+		t.Fatalf("expected the unready rollout to be reported, got %v", err)
+	}
+}
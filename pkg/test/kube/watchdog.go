@@ -0,0 +1,217 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pkg/test/scopes"
+)
+
+// WatchdogTarget selects the pods monitored by a Watchdog in a cluster.
+type WatchdogTarget struct {
+	// Name of the cluster, used in reported events.
+	Cluster string
+	Client  kubernetes.Interface
+	// Selectors of monitored pods across all namespaces.
+	Selectors []string
+	// NamespaceSelector selects namespaces whose pods are all monitored.
+	NamespaceSelector string
+}
+
+// PodEvent is an unexpected restart of a container.
+type PodEvent struct {
+	// Time the container last terminated at, or the time the restart was observed at if it is not known.
+	Time      time.Time
+	Cluster   string
+	Namespace string
+	Pod       string
+	Container string
+	// Reason of the restart, such as OOMKilled, Error or CrashLoopBackOff.
+	Reason string
+	// Restarts is the number of restarts of the container since it was last polled.
+	Restarts int32
+}
+
+func (e PodEvent) String() string {
+	return fmt.Sprintf("%s: container %s of pod %s/%s in %s restarted (%s, %d restarts)",
+		e.Time.Format(time.RFC3339), e.Container, e.Namespace, e.Pod, e.Cluster, e.Reason, e.Restarts)
+}
+
+// Watchdog polls the targeted pods in the background and records the restarts of their containers, turning silent
+// restarts of framework components into events that can be attributed to the tests running at the time.
+type Watchdog struct {
+	targets  []WatchdogTarget
+	interval time.Duration
+	started  time.Time
+
+	mu       sync.Mutex
+	restarts map[string]int32
+	events   []PodEvent
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewWatchdog returns a Watchdog polling the targets at the interval.
+func NewWatchdog(interval time.Duration, targets ...WatchdogTarget) *Watchdog {
+	return &Watchdog{
+		targets:  targets,
+		interval: interval,
+		restarts: map[string]int32{},
+	}
+}
+
+// Start polling in the background, until Stop is called.
+func (w *Watchdog) Start() {
+	w.started = time.Now()
+	w.stop = make(chan struct{})
+	w.poll()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.poll()
+			}
+		}
+	}()
+}
+
+// Stop polling.
+func (w *Watchdog) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	w.wg.Wait()
+	w.stop = nil
+}
+
+// EventsBetween returns the events observed between start and end. The end is extended by one polling interval, as
+// events are only observed when the pods are next polled.
+func (w *Watchdog) EventsBetween(start, end time.Time) []PodEvent {
+	end = end.Add(w.interval)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []PodEvent
+	for _, e := range w.events {
+		if !e.Time.Before(start) && !e.Time.After(end) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (w *Watchdog) poll() {
+	for _, t := range w.targets {
+		pods, err := t.pods()
+		if err != nil {
+			scopes.Kube.Warnf("watchdog failed listing pods in %s: %v", t.Cluster, err)
+			continue
+		}
+		for _, p := range pods {
+			w.observe(t.Cluster, p)
+		}
+	}
+}
+
+func (t WatchdogTarget) pods() ([]kubeApiCore.Pod, error) {
+	var out []kubeApiCore.Pod
+	for _, s := range t.Selectors {
+		pods, err := t.Client.CoreV1().Pods("").List(context.TODO(), kubeApiMeta.ListOptions{LabelSelector: s})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pods.Items...)
+	}
+	if t.NamespaceSelector == "" {
+		return out, nil
+	}
+	namespaces, err := t.Client.CoreV1().Namespaces().List(context.TODO(), kubeApiMeta.ListOptions{
+		LabelSelector: t.NamespaceSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, ns := range namespaces.Items {
+		pods, err := t.Client.CoreV1().Pods(ns.Name).List(context.TODO(), kubeApiMeta.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pods.Items...)
+	}
+	return out, nil
+}
+
+func (w *Watchdog) observe(cluster string, p kubeApiCore.Pod) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	statuses := append(append([]kubeApiCore.ContainerStatus{}, p.Status.InitContainerStatuses...),
+		p.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		key := fmt.Sprintf("%s/%s/%s/%s/%s", cluster, p.Namespace, p.Name, p.UID, s.Name)
+		prev, seen := w.restarts[key]
+		w.restarts[key] = s.RestartCount
+		if !seen && p.CreationTimestamp.Time.Before(w.started) {
+			// Restarts of pods that existed before the watchdog started are not attributable.
+			continue
+		}
+		if s.RestartCount <= prev {
+			continue
+		}
+		w.events = append(w.events, PodEvent{
+			Time:      restartTime(s),
+			Cluster:   cluster,
+			Namespace: p.Namespace,
+			Pod:       p.Name,
+			Container: s.Name,
+			Reason:    restartReason(s),
+			Restarts:  s.RestartCount - prev,
+		})
+		scopes.Kube.Warnf("watchdog: %v", w.events[len(w.events)-1])
+	}
+}
+
+// restartTime returns when the container last terminated, so that the restart is attributed to the test running at
+// the time rather than at the next poll.
+func restartTime(s kubeApiCore.ContainerStatus) time.Time {
+	if t := s.LastTerminationState.Terminated; t != nil && !t.FinishedAt.IsZero() {
+		return t.FinishedAt.Time
+	}
+	return time.Now()
+}
+
+func restartReason(s kubeApiCore.ContainerStatus) string {
+	if s.State.Waiting != nil && s.State.Waiting.Reason == "CrashLoopBackOff" {
+		return s.State.Waiting.Reason
+	}
+	if t := s.LastTerminationState.Terminated; t != nil && t.Reason != "" {
+		return t.Reason
+	}
+	return "Unknown"
+}
@@ -0,0 +1,114 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kube
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func watchdogPod(created time.Time, restarts int32, finished time.Time) *kubeApiCore.Pod {
+	status := kubeApiCore.ContainerStatus{
+		Name:         "discovery",
+		RestartCount: restarts,
+	}
+	if !finished.IsZero() {
+		status.LastTerminationState.Terminated = &kubeApiCore.ContainerStateTerminated{
+			Reason:     "OOMKilled",
+			FinishedAt: kubeApiMeta.NewTime(finished),
+		}
+	}
+	return &kubeApiCore.Pod{
+		ObjectMeta: kubeApiMeta.ObjectMeta{
+			Name:              "istiod",
+			Namespace:         "istio-system",
+			UID:               "uid",
+			Labels:            map[string]string{"app": "istiod"},
+			CreationTimestamp: kubeApiMeta.NewTime(created),
+		},
+		Status: kubeApiCore.PodStatus{ContainerStatuses: []kubeApiCore.ContainerStatus{status}},
+	}
+}
+
+func newTestWatchdog(started time.Time, pod *kubeApiCore.Pod) (*Watchdog, *fake.Clientset) {
+	client := fake.NewSimpleClientset(pod)
+	w := NewWatchdog(time.Second, WatchdogTarget{
+		Cluster:   "cluster-0",
+		Client:    client,
+		Selectors: []string{"app=istiod"},
+	})
+	w.started = started
+	return w, client
+}
+
+func TestWatchdogAttributesRestartsToTermination(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	pod := watchdogPod(started.Add(-time.Minute), 0, time.Time{})
+	w, client := newTestWatchdog(started, pod)
+	w.poll()
+	if events := w.EventsBetween(started, time.Now()); len(events) != 0 {
+		t.Fatalf("expected no events before a restart, got %v", events)
+	}
+
+	finished := started.Add(10 * time.Minute)
+	pod = watchdogPod(started.Add(-time.Minute), 1, finished)
+	if _, err := client.CoreV1().Pods(pod.Namespace).Update(context.TODO(), pod, kubeApiMeta.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	w.poll()
+	w.poll()
+
+	events := w.EventsBetween(started, time.Now())
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %v", events)
+	}
+	e := events[0]
+	if !e.Time.Equal(finished) || e.Restarts != 1 || e.Reason != "OOMKilled" {
+		t.Fatalf("unexpected event %v", e)
+	}
+	// The restart is attributed to the test running when the container terminated, not when it was polled.
+	if got := w.EventsBetween(finished.Add(time.Minute), time.Now()); len(got) != 0 {
+		t.Fatalf("expected no events after the termination, got %v", got)
+	}
+}
+
+func TestWatchdogCountsRestartsOfNewPods(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	finished := started.Add(5 * time.Minute)
+	w, _ := newTestWatchdog(started, watchdogPod(started.Add(time.Minute), 3, finished))
+	w.poll()
+
+	events := w.EventsBetween(started, time.Now())
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %v", events)
+	}
+	if e := events[0]; !e.Time.Equal(finished) || e.Restarts != 3 {
+		t.Fatalf("unexpected event %v", e)
+	}
+}
+
+func TestWatchdogIgnoresRestartsBeforeStart(t *testing.T) {
+	started := time.Now().Add(-time.Hour)
+	w, _ := newTestWatchdog(started, watchdogPod(started.Add(-time.Minute), 2, started.Add(-time.Second)))
+	w.poll()
+	if events := w.EventsBetween(started.Add(-time.Hour), time.Now()); len(events) != 0 {
+		t.Fatalf("expected no events for a pod created before the watchdog started, got %v", events)
+	}
+}
@@ -0,0 +1,100 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package scopes
+
+import (
+	"fmt"
+	"strings"
+
+	"istio.io/istio/pkg/test"
+	"istio.io/pkg/log"
+)
+
+// Find returns the registered scope with the given name, which is the name used by the --log_output_level flag, such
+// as tf for the Framework scope.
+func Find(name string) (*log.Scope, error) {
+	if s := log.FindScope(name); s != nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown logging scope %q", name)
+}
+
+var levels = map[string]log.Level{
+	"none":  log.NoneLevel,
+	"fatal": log.FatalLevel,
+	"error": log.ErrorLevel,
+	"warn":  log.WarnLevel,
+	"info":  log.InfoLevel,
+	"debug": log.DebugLevel,
+}
+
+// SetLevels sets the output level of scopes from a comma separated list of <scope>:<level> pairs, with scopes named
+// as in --log_output_level, such as "tf:debug,tfecho:info". All pairs are validated before any level is changed.
+func SetLevels(spec string) error {
+	type pair struct {
+		scope string
+		level log.Level
+	}
+	var pairs []pair
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid log level %q, expected <scope>:<level>", entry)
+		}
+		if _, err := Find(parts[0]); err != nil {
+			return err
+		}
+		l, ok := levels[strings.ToLower(parts[1])]
+		if !ok {
+			return fmt.Errorf("invalid log level %q for scope %s", parts[1], parts[0])
+		}
+		pairs = append(pairs, pair{scope: parts[0], level: l})
+	}
+	for _, p := range pairs {
+		if _, err := SetLevel(p.scope, p.level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetLevel sets the output level of the scope named as in --log_output_level, returning a function that restores the previous level. This
+// allows noisy sections of a test to be silenced, or quiet ones to be traced.
+func SetLevel(name string, level log.Level) (func(), error) {
+	s, err := Find(name)
+	if err != nil {
+		return nil, err
+	}
+	prev := s.GetOutputLevel()
+	s.SetOutputLevel(level)
+	return func() {
+		s.SetOutputLevel(prev)
+	}, nil
+}
+
+// SetLevelOrFail calls SetLevel and fails the test if it returns an error. The previous level is restored when the
+// test completes.
+func SetLevelOrFail(t test.Failer, name string, level log.Level) {
+	t.Helper()
+	restore, err := SetLevel(name, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restore)
+}
@@ -0,0 +1,72 @@
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package scopes
+
+import (
+	"testing"
+
+	"istio.io/pkg/log"
+)
+
+func TestSetLevel(t *testing.T) {
+	prev := Echo.GetOutputLevel()
+	restore, err := SetLevel("tfecho", log.DebugLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Echo.GetOutputLevel(); got != log.DebugLevel {
+		t.Fatalf("got level %v, want %v", got, log.DebugLevel)
+	}
+	restore()
+	if got := Echo.GetOutputLevel(); got != prev {
+		t.Fatalf("got level %v after restoring, want %v", got, prev)
+	}
+
+	// Only the names used by --log_output_level are accepted.
+	if _, err := SetLevel("echo", log.DebugLevel); err == nil {
+		t.Fatal("expected an error for a name that is not registered")
+	}
+}
+
+func TestSetLevels(t *testing.T) {
+	prevFramework, prevEcho := Framework.GetOutputLevel(), Echo.GetOutputLevel()
+	defer func() {
+		Framework.SetOutputLevel(prevFramework)
+		Echo.SetOutputLevel(prevEcho)
+	}()
+
+	if err := SetLevels("tf:debug, tfecho:ERROR"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Framework.GetOutputLevel(); got != log.DebugLevel {
+		t.Fatalf("got level %v for tf, want %v", got, log.DebugLevel)
+	}
+	if got := Echo.GetOutputLevel(); got != log.ErrorLevel {
+		t.Fatalf("got level %v for tfecho, want %v", got, log.ErrorLevel)
+	}
+	if err := SetLevels(""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, spec := range []string{"tf", "tf:debug:info", "tf:verbose", "echo:debug", "tf:info,unknown:debug"} {
+		if err := SetLevels(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+	// No level is changed by an invalid list.
+	if got := Framework.GetOutputLevel(); got != log.DebugLevel {
+		t.Fatalf("got level %v for tf after an invalid list, want %v", got, log.DebugLevel)
+	}
+}
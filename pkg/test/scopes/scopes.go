@@ -16,7 +16,18 @@ package scopes
 
 import "istio.io/pkg/log"
 
+// The output level of each scope can be set with the standard --log_output_level flag, such as
+// --log_output_level=tf:debug,tfecho:info, or with --istio.test.logLevel, which takes the same list.
 var (
 	// Framework is the general logging scope for the framework.
 	Framework = log.RegisterScope("tf", "General scope for the test framework", 0)
+
+	// Kube is the logging scope for Kubernetes operations of the framework.
+	Kube = log.RegisterScope("tfkube", "Kubernetes operations of the test framework", 0)
+
+	// Echo is the logging scope for the deployment of echo workloads.
+	Echo = log.RegisterScope("tfecho", "Echo workloads of the test framework", 0)
+
+	// CI is the logging scope for the state dumped in CI mode.
+	CI = log.RegisterScope("tfci", "State dumps of the test framework in CI mode", 0)
 )
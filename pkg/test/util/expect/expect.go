@@ -0,0 +1,243 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expect asserts that Kubernetes objects eventually reach a condition, such as a status field, an annotation,
+// or deletion. Rather than polling the API server, the object is watched, so the condition is checked as soon as the
+// object changes, and a failure reports every change observed while waiting.
+package expect
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+
+	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/test"
+)
+
+// DefaultTimeout is how long Eventually waits for the condition by default.
+const DefaultTimeout = 30 * time.Second
+
+// Condition of an object.
+type Condition struct {
+	// Description of the condition, such as `status.phase == "Running"`.
+	Description string
+	// Met returns whether the object, nil if it does not exist, meets the condition, and what was observed of it for
+	// the history of the object, such as `status.phase="Pending"`.
+	Met func(obj *unstructured.Unstructured) (met bool, observed string)
+}
+
+// Exists is met once the object exists.
+func Exists() Condition {
+	return Condition{
+		Description: "exists",
+		Met: func(obj *unstructured.Unstructured) (bool, string) {
+			return obj != nil, ""
+		},
+	}
+}
+
+// Deleted is met once the object does not exist.
+func Deleted() Condition {
+	return Condition{
+		Description: "deleted",
+		Met: func(obj *unstructured.Unstructured) (bool, string) {
+			return obj == nil, ""
+		},
+	}
+}
+
+// FieldEquals is met once the field at the dot separated path, such as "status.phase", formats as the value.
+func FieldEquals(path, value string) Condition {
+	return Condition{
+		Description: fmt.Sprintf("%s == %q", path, value),
+		Met: func(obj *unstructured.Unstructured) (bool, string) {
+			if obj == nil {
+				return false, ""
+			}
+			v, found, err := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(path, ".")...)
+			switch {
+			case err != nil:
+				return false, err.Error()
+			case !found:
+				return false, path + " not set"
+			}
+			got := fmt.Sprint(v)
+			return got == value, fmt.Sprintf("%s=%q", path, got)
+		},
+	}
+}
+
+// HasAnnotation is met once the object has the annotation with the value.
+func HasAnnotation(key, value string) Condition {
+	return Condition{
+		Description: fmt.Sprintf("annotation %s == %q", key, value),
+		Met: func(obj *unstructured.Unstructured) (bool, string) {
+			if obj == nil {
+				return false, ""
+			}
+			got, found := obj.GetAnnotations()[key]
+			if !found {
+				return false, "annotation " + key + " not set"
+			}
+			return got == value, fmt.Sprintf("annotation %s=%q", key, got)
+		},
+	}
+}
+
+// StatusCondition is met once the object has a condition in status.conditions of the type with the status, such as
+// the Established condition of a CustomResourceDefinition being "True".
+func StatusCondition(condType, status string) Condition {
+	return Condition{
+		Description: fmt.Sprintf("condition %s == %q", condType, status),
+		Met: func(obj *unstructured.Unstructured) (bool, string) {
+			if obj == nil {
+				return false, ""
+			}
+			conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+			if err != nil {
+				return false, err.Error()
+			}
+			for _, c := range conditions {
+				c, ok := c.(map[string]interface{})
+				if !ok || c["type"] != condType {
+					continue
+				}
+				got := fmt.Sprint(c["status"])
+				return got == status, fmt.Sprintf("condition %s=%q", condType, got)
+			}
+			return false, "condition " + condType + " not set"
+		},
+	}
+}
+
+// Options of Eventually.
+type Options struct {
+	// Timeout of the wait. Defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Eventually waits until the object of the resource meets the condition. If it does not before the timeout, the
+// returned error lists the changes of the object observed while waiting.
+func Eventually(c kube.Client, gvr schema.GroupVersionResource, namespace, name string, cond Condition,
+	opts Options) error {
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := c.Dynamic().Resource(gvr).Namespace(namespace)
+	selector := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(o kubeApiMeta.ListOptions) (runtime.Object, error) {
+			o.FieldSelector = selector
+			return client.List(ctx, o)
+		},
+		WatchFunc: func(o kubeApiMeta.ListOptions) (watch.Interface, error) {
+			o.FieldSelector = selector
+			return client.Watch(ctx, o)
+		},
+	}
+
+	h := &history{start: time.Now()}
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	precondition := func(store cache.Store) (bool, error) {
+		var obj *unstructured.Unstructured
+		if item, exists, err := store.GetByKey(key); err != nil {
+			return false, err
+		} else if exists {
+			obj = item.(*unstructured.Unstructured)
+		}
+		return h.check("INITIAL", obj, cond), nil
+	}
+	condition := func(ev watch.Event) (bool, error) {
+		obj, ok := objectOf(ev.Object)
+		if !ok || obj.GetName() != name {
+			return false, nil
+		}
+		if ev.Type == watch.Deleted {
+			return h.check(string(ev.Type), nil, cond), nil
+		}
+		return h.check(string(ev.Type), obj, cond), nil
+	}
+	if _, err := watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{}, precondition, condition); err != nil {
+		return fmt.Errorf("%s %s did not meet condition %s within %v (%v); observed:\n%s", gvr.Resource, key,
+			cond.Description, timeout, err, h)
+	}
+	return nil
+}
+
+// EventuallyOrFail calls Eventually and fails the test if it returns an error.
+func EventuallyOrFail(t test.Failer, c kube.Client, gvr schema.GroupVersionResource, namespace, name string,
+	cond Condition, opts Options) {
+	t.Helper()
+	if err := Eventually(c, gvr, namespace, name, cond, opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// objectOf returns the object of a watch event, unwrapping the last known state of an object whose deletion was
+// missed by the informer.
+func objectOf(o interface{}) (*unstructured.Unstructured, bool) {
+	if d, ok := o.(cache.DeletedFinalStateUnknown); ok {
+		o = d.Obj
+	}
+	obj, ok := o.(*unstructured.Unstructured)
+	return obj, ok
+}
+
+// history of the states of an object observed while waiting.
+type history struct {
+	mu      sync.Mutex
+	start   time.Time
+	entries []string
+}
+
+// check records the state of the object, and returns whether it meets the condition.
+func (h *history) check(event string, obj *unstructured.Unstructured, cond Condition) bool {
+	met, observed := cond.Met(obj)
+	state := "absent"
+	if obj != nil {
+		state = "resourceVersion=" + obj.GetResourceVersion()
+	}
+	if observed != "" {
+		state += " " + observed
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, fmt.Sprintf("  +%v %s %s", time.Since(h.start).Round(time.Millisecond), event, state))
+	return met
+}
+
+func (h *history) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return strings.Join(h.entries, "\n")
+}
@@ -0,0 +1,168 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expect
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/istio/pkg/kube"
+)
+
+var gvr = schema.GroupVersionResource{Group: "test.istio.io", Version: "v1", Resource: "things"}
+
+// fakeClient serves the objects of gvr from a fake dynamic client.
+type fakeClient struct {
+	kube.Client
+	dynamic dynamic.Interface
+}
+
+func (c *fakeClient) Dynamic() dynamic.Interface {
+	return c.dynamic
+}
+
+func newClient(objects ...runtime.Object) *fakeClient {
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(gvr.GroupVersion().WithKind("Thing"), &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(gvr.GroupVersion().WithKind("List"), &unstructured.UnstructuredList{})
+	return &fakeClient{dynamic: dynamicfake.NewSimpleDynamicClient(s, objects...)}
+}
+
+func thing(name, phase string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": gvr.GroupVersion().String(),
+		"kind":       "Thing",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+	}}
+	if phase != "" {
+		_ = unstructured.SetNestedField(obj.Object, phase, "status", "phase")
+	}
+	return obj
+}
+
+func TestEventually(t *testing.T) {
+	opts := Options{Timeout: 10 * time.Second}
+	t.Run("already met", func(t *testing.T) {
+		c := newClient(thing("a", "Running"))
+		if err := Eventually(c, gvr, "default", "a", FieldEquals("status.phase", "Running"), opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("met on update", func(t *testing.T) {
+		c := newClient(thing("a", "Pending"))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_, _ = c.dynamic.Resource(gvr).Namespace("default").Update(context.TODO(), thing("a", "Running"),
+				kubeApiMeta.UpdateOptions{})
+		}()
+		if err := Eventually(c, gvr, "default", "a", FieldEquals("status.phase", "Running"), opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("met on creation", func(t *testing.T) {
+		c := newClient()
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_, _ = c.dynamic.Resource(gvr).Namespace("default").Create(context.TODO(), thing("a", ""),
+				kubeApiMeta.CreateOptions{})
+		}()
+		if err := Eventually(c, gvr, "default", "a", Exists(), opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("deleted", func(t *testing.T) {
+		c := newClient(thing("a", ""), thing("b", ""))
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_ = c.dynamic.Resource(gvr).Namespace("default").Delete(context.TODO(), "a", kubeApiMeta.DeleteOptions{})
+		}()
+		if err := Eventually(c, gvr, "default", "a", Deleted(), opts); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		c := newClient(thing("a", "Pending"))
+		err := Eventually(c, gvr, "default", "a", FieldEquals("status.phase", "Running"),
+			Options{Timeout: 100 * time.Millisecond})
+		if err == nil {
+			t.Fatal("expected the condition not to be met")
+		}
+		for _, want := range []string{`status.phase == "Running"`, "INITIAL", `status.phase="Pending"`} {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("expected the error to contain %q, got %v", want, err)
+			}
+		}
+	})
+}
+
+func TestConditions(t *testing.T) {
+	annotated := thing("a", "Running")
+	annotated.SetAnnotations(map[string]string{"key": "value"})
+	established := thing("a", "")
+	_ = unstructured.SetNestedSlice(established.Object, []interface{}{
+		map[string]interface{}{"type": "NamesAccepted", "status": "True"},
+		map[string]interface{}{"type": "Established", "status": "False"},
+	}, "status", "conditions")
+
+	cases := []struct {
+		name     string
+		cond     Condition
+		obj      *unstructured.Unstructured
+		met      bool
+		observed string
+	}{
+		{"exists", Exists(), annotated, true, ""},
+		{"exists absent", Exists(), nil, false, ""},
+		{"deleted", Deleted(), nil, true, ""},
+		{"field", FieldEquals("status.phase", "Running"), annotated, true, `status.phase="Running"`},
+		{"field differs", FieldEquals("status.phase", "Failed"), annotated, false, `status.phase="Running"`},
+		{"field not set", FieldEquals("status.reason", "x"), annotated, false, "status.reason not set"},
+		{"annotation", HasAnnotation("key", "value"), annotated, true, `annotation key="value"`},
+		{"annotation not set", HasAnnotation("other", "value"), annotated, false, "annotation other not set"},
+		{"condition", StatusCondition("NamesAccepted", "True"), established, true, `condition NamesAccepted="True"`},
+		{"condition differs", StatusCondition("Established", "True"), established, false, `condition Established="False"`},
+		{"condition not set", StatusCondition("Terminating", "True"), established, false, "condition Terminating not set"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			met, observed := tc.cond.Met(tc.obj)
+			if met != tc.met || observed != tc.observed {
+				t.Fatalf("got (%v, %q), want (%v, %q)", met, observed, tc.met, tc.observed)
+			}
+		})
+	}
+}
+
+func TestObjectOf(t *testing.T) {
+	obj := thing("a", "")
+	for _, o := range []interface{}{obj, cache.DeletedFinalStateUnknown{Key: "default/a", Obj: obj}} {
+		if got, ok := objectOf(o); !ok || got != obj {
+			t.Fatalf("got %v for %T, want the object", got, o)
+		}
+	}
+	if _, ok := objectOf(&kubeApiMeta.Status{}); ok {
+		t.Fatal("expected an object of another type to be ignored")
+	}
+}
@@ -15,6 +15,7 @@
 package retry
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -44,6 +45,7 @@ type config struct {
 	timeout  time.Duration
 	delay    time.Duration
 	converge int
+	ctx      context.Context
 }
 
 // Option for a retry opteration.
@@ -72,6 +74,13 @@ func Converge(successes int) Option {
 	}
 }
 
+// Context stops the retry operation once the context is done, such as when a test exceeds its deadline.
+func Context(ctx context.Context) Option {
+	return func(cfg *config) {
+		cfg.ctx = ctx
+	}
+}
+
 // RetriableFunc a function that can be retried.
 type RetriableFunc func() (result interface{}, completed bool, err error)
 
@@ -89,9 +98,13 @@ func UntilSuccess(fn func() error, options ...Option) error {
 	return e
 }
 
-// UntilSuccessOrFail calls UntilSuccess, and fails t with Fatalf if it ends up returning an error
+// UntilSuccessOrFail calls UntilSuccess, and fails t with Fatalf if it ends up returning an error. If t has a
+// context, such as the context of a framework test, the retry operation stops once it is done.
 func UntilSuccessOrFail(t test.Failer, fn func() error, options ...Option) {
 	t.Helper()
+	if c, ok := t.(interface{ Context() context.Context }); ok {
+		options = append([]Option{Context(c.Context())}, options...)
+	}
 	err := UntilSuccess(fn, options...)
 	if err != nil {
 		t.Fatalf("retry.UntilSuccessOrFail: %v", err)
@@ -105,6 +118,11 @@ func Do(fn RetriableFunc, options ...Option) (interface{}, error) {
 		option(&cfg)
 	}
 
+	ctx := cfg.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	successes := 0
 	var lasterr error
 	to := time.After(cfg.timeout)
@@ -112,6 +130,8 @@ func Do(fn RetriableFunc, options ...Option) (interface{}, error) {
 		select {
 		case <-to:
 			return nil, fmt.Errorf("timeout while waiting (last error: %v)", lasterr)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("stopped while waiting: %v (last error: %v)", ctx.Err(), lasterr)
 		default:
 		}
 
@@ -135,6 +155,9 @@ func Do(fn RetriableFunc, options ...Option) (interface{}, error) {
 			lasterr = err
 		}
 
-		<-time.After(cfg.delay)
+		select {
+		case <-time.After(cfg.delay):
+		case <-ctx.Done():
+		}
 	}
 }
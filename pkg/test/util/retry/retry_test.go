@@ -15,6 +15,7 @@
 package retry
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -49,3 +50,25 @@ func TestConverge(t *testing.T) {
 		}
 	})
 }
+
+func TestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	start := time.Now()
+	err := UntilSuccess(func() error {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+		return fmt.Errorf("not yet")
+	}, Context(ctx), Timeout(time.Minute), Delay(time.Millisecond))
+	if err == nil {
+		t.Fatal("expected the retry to stop with an error once the context is done")
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls before the context was done, got %d", calls)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("expected the retry to stop with the context, took %v", elapsed)
+	}
+}
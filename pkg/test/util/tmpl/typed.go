@@ -0,0 +1,155 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tmpl
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+
+	"istio.io/istio/pkg/test"
+)
+
+// Typed is a template evaluated with a struct of parameters, rather than a free-form map. Fields of the struct, or
+// of structs it embeds, tagged with `tmpl:"required"` must be set, and referencing a field that does not exist is an
+// error rather than an empty value. Errors report where the template was created.
+//
+// Typed is meant for templates defined in Go source. Templates read from testdata files, or built at run time, are
+// still evaluated with Evaluate, as the lines of their errors do not map to a line of the source.
+type Typed struct {
+	t      *template.Template
+	file   string
+	line   int
+	source string
+}
+
+const typedName = "typed"
+
+// NewTyped parses a typed template. The lines of errors are those of the file if the template literal starts on the
+// line of the call, as in:
+//
+//	var tpl = tmpl.MustNewTyped(`
+//	apiVersion: ...
+//	`)
+func NewTyped(tpl string) (*Typed, error) {
+	return newTyped(tpl, 2)
+}
+
+// MustNewTyped calls NewTyped and panics if it returns an error.
+func MustNewTyped(tpl string) *Typed {
+	t, err := newTyped(tpl, 2)
+	if err != nil {
+		panic(fmt.Sprintf("tmpl.MustNewTyped: %v", err))
+	}
+	return t
+}
+
+func newTyped(tpl string, skip int) (*Typed, error) {
+	t := &Typed{source: "unknown"}
+	if _, file, line, ok := runtime.Caller(skip); ok {
+		t.file = filepath.Base(file)
+		t.line = line
+		t.source = fmt.Sprintf("%s:%d", t.file, line)
+	}
+	parsed, err := template.New(typedName).Option("missingkey=error").Funcs(sprig.TxtFuncMap()).Parse(tpl)
+	if err != nil {
+		return nil, t.wrap(err)
+	}
+	t.t = parsed
+	return t, nil
+}
+
+// Evaluate the template with the parameters, which must be a struct or a pointer to one.
+func (t *Typed) Evaluate(params interface{}) (string, error) {
+	if err := Validate(params); err != nil {
+		return "", fmt.Errorf("%s: %v", t.source, err)
+	}
+	out, err := Execute(t.t, params)
+	if err != nil {
+		return "", t.wrap(err)
+	}
+	return out, nil
+}
+
+// EvaluateOrFail calls Evaluate and fails the test if it returns an error.
+func (t *Typed) EvaluateOrFail(tst test.Failer, params interface{}) string {
+	tst.Helper()
+	out, err := t.Evaluate(params)
+	if err != nil {
+		tst.Fatal(err)
+	}
+	return out
+}
+
+// MustEvaluate calls Evaluate and panics if it returns an error.
+func (t *Typed) MustEvaluate(params interface{}) string {
+	out, err := t.Evaluate(params)
+	if err != nil {
+		panic(fmt.Sprintf("tmpl.MustEvaluate: %v", err))
+	}
+	return out
+}
+
+// templateErr matches the errors of text/template, such as `template: typed:3:5: executing ...`.
+var templateErr = regexp.MustCompile(`^template: ` + typedName + `:(\d+)(?::\d+)?: (.*)$`)
+
+// wrap reports the error at the line of the file it maps to.
+func (t *Typed) wrap(err error) error {
+	m := templateErr.FindStringSubmatch(err.Error())
+	if m == nil || t.file == "" {
+		return fmt.Errorf("template at %s: %v", t.source, err)
+	}
+	n, _ := strconv.Atoi(m[1])
+	return fmt.Errorf("%s:%d (line %d of the template): %s", t.file, t.line+n-1, n, m[2])
+}
+
+// Validate that the fields of params tagged with `tmpl:"required"` are set.
+func Validate(params interface{}) error {
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return fmt.Errorf("template parameters are nil")
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("template parameters must be a struct, got %v", v.Kind())
+	}
+	return validate(v, "")
+}
+
+func validate(v reflect.Value, prefix string) error {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		fv := v.Field(i)
+		if f.Anonymous && fv.Kind() == reflect.Struct {
+			if err := validate(fv, prefix); err != nil {
+				return err
+			}
+			continue
+		}
+		if f.Tag.Get("tmpl") == "required" && fv.IsZero() {
+			return fmt.Errorf("required template parameter %s%s is not set", prefix, f.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tmpl
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type embedded struct {
+	Namespace string `tmpl:"required"`
+}
+
+type params struct {
+	embedded
+	Name     string `tmpl:"required"`
+	Replicas int
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		params interface{}
+		err    string
+	}{
+		{"set", params{embedded: embedded{Namespace: "ns"}, Name: "a"}, ""},
+		{"pointer", &params{embedded: embedded{Namespace: "ns"}, Name: "a"}, ""},
+		{"optional", params{embedded: embedded{Namespace: "ns"}, Name: "a", Replicas: 0}, ""},
+		{"required", params{embedded: embedded{Namespace: "ns"}}, "required template parameter Name is not set"},
+		{"embedded required", params{Name: "a"}, "required template parameter Namespace is not set"},
+		{"nil", (*params)(nil), "template parameters are nil"},
+		{"map", map[string]string{"Name": "a"}, "template parameters must be a struct, got map"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Validate(c.params)
+			if c.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != c.err {
+				t.Fatalf("expected error %q, got %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestTyped(t *testing.T) {
+	_, _, line, _ := runtime.Caller(0)
+	tpl := MustNewTyped(`name: {{ .Name }}
+namespace: {{ .Namespace }}
+replicas: {{ .Replicas | default 1 }}
+{{- if .Missing }}
+missing: true
+{{- end }}
+`)
+	// The template starts on the line after the call to runtime.Caller, and the missing field is on its 4th line.
+	want := fmt.Sprintf("typed_test.go:%d (line 4 of the template): ", line+4)
+
+	_, err := tpl.Evaluate(params{embedded: embedded{Namespace: "ns"}, Name: "a"})
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("expected error starting with %q, got %v", want, err)
+	}
+
+	_, err = tpl.Evaluate(params{Name: "a"})
+	if err == nil || !strings.Contains(err.Error(), "required template parameter Namespace is not set") {
+		t.Fatalf("expected the missing parameter to be reported, got %v", err)
+	}
+}
+
+func TestTypedEvaluate(t *testing.T) {
+	tpl := MustNewTyped(`name: {{ .Name }}
+namespace: {{ .Namespace }}
+replicas: {{ .Replicas | default 1 }}
+`)
+	got := tpl.EvaluateOrFail(t, params{embedded: embedded{Namespace: "ns"}, Name: "a"})
+	want := "name: a\nnamespace: ns\nreplicas: 1\n"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestNewTypedParseError(t *testing.T) {
+	_, _, line, _ := runtime.Caller(0)
+	_, err := NewTyped(`name: a
+namespace: {{ end }}
+`)
+	want := fmt.Sprintf("typed_test.go:%d (line 2 of the template): ", line+2)
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("expected error starting with %q, got %v", want, err)
+	}
+}
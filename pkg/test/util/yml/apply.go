@@ -71,3 +71,199 @@ func ensureChildMap(m map[string]interface{}, name string) (map[string]interface
 
 	return cm, nil
 }
+
+// ApplyLabel sets the label on the resources in the yamlText for which the filter, if any, returns true.
+func ApplyLabel(yamlText, key, value string, filter func(d Descriptor) bool) (string, error) {
+	chunks := SplitString(yamlText)
+
+	toJoin := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		if filter != nil {
+			d, err := ParseDescriptor(chunk)
+			if err != nil {
+				return "", err
+			}
+			if !filter(d) {
+				toJoin = append(toJoin, chunk)
+				continue
+			}
+		}
+		chunk, err := applyLabel(chunk, key, value)
+		if err != nil {
+			return "", err
+		}
+		toJoin = append(toJoin, chunk)
+	}
+
+	return JoinString(toJoin...), nil
+}
+
+func applyLabel(yamlText, key, value string) (string, error) {
+	m := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(yamlText), &m); err != nil {
+		return "", err
+	}
+
+	meta, err := ensureChildMap(m, "metadata")
+	if err != nil {
+		return "", err
+	}
+	labels, err := ensureChildMap(meta, "labels")
+	if err != nil {
+		return "", err
+	}
+	labels[key] = value
+	meta["labels"] = labels
+	m["metadata"] = meta
+
+	by, err := yaml.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return string(by), nil
+}
+
+// ApplyRestricted makes the pods of the workloads in the yamlText, such as Deployments and Jobs, comply with the
+// Kubernetes "restricted" PodSecurity profile: they run as non-root with the given uid, unless a container sets
+// runAsUser, with the runtime's default seccomp profile, no privilege escalation, and all capabilities but
+// NET_BIND_SERVICE dropped. Only fields the workload leaves unset are filled in: a workload that asks for what the
+// profile forbids, such as privileges, capabilities, root or the host network, is an error rather than silently
+// stripped of it.
+func ApplyRestricted(yamlText string, uid int64) (string, error) {
+	chunks := SplitString(yamlText)
+
+	toJoin := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		d, err := ParseDescriptor(chunk)
+		if err != nil {
+			return "", err
+		}
+		path, ok := podSpecPaths[d.Kind]
+		if !ok {
+			toJoin = append(toJoin, chunk)
+			continue
+		}
+		chunk, err := applyRestricted(chunk, path, uid)
+		if err != nil {
+			return "", fmt.Errorf("failed restricting %s %s: %v", d.Kind, d.Metadata.Name, err)
+		}
+		toJoin = append(toJoin, chunk)
+	}
+
+	return JoinString(toJoin...), nil
+}
+
+// podSpecPaths are the paths to the pod spec of each kind of workload.
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"CronJob":     {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+func applyRestricted(yamlText string, path []string, uid int64) (string, error) {
+	m := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(yamlText), &m); err != nil {
+		return "", err
+	}
+
+	spec := m
+	for _, name := range path {
+		child, err := ensureChildMap(spec, name)
+		if err != nil {
+			return "", err
+		}
+		spec[name] = child
+		spec = child
+	}
+	for _, field := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+		if v, _ := spec[field].(bool); v {
+			return "", fmt.Errorf("%s is not allowed", field)
+		}
+	}
+	podContext, err := ensureChildMap(spec, "securityContext")
+	if err != nil {
+		return "", err
+	}
+	if err := checkRestrictedUser(podContext); err != nil {
+		return "", err
+	}
+	podContext["runAsNonRoot"] = true
+	podContext["seccompProfile"] = map[string]interface{}{"type": "RuntimeDefault"}
+	spec["securityContext"] = podContext
+
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _ := spec[field].([]interface{})
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("%s entry is not a map: %v", field, reflect.TypeOf(c))
+			}
+			sc, err := ensureChildMap(container, "securityContext")
+			if err != nil {
+				return "", err
+			}
+			if err := checkRestrictedContainer(sc); err != nil {
+				return "", fmt.Errorf("container %v: %v", container["name"], err)
+			}
+			if _, ok := sc["runAsUser"]; !ok {
+				sc["runAsUser"] = uid
+			}
+			sc["allowPrivilegeEscalation"] = false
+			caps, err := ensureChildMap(sc, "capabilities")
+			if err != nil {
+				return "", err
+			}
+			caps["drop"] = []interface{}{"ALL"}
+			sc["capabilities"] = caps
+			container["securityContext"] = sc
+		}
+	}
+
+	by, err := yaml.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return string(by), nil
+}
+
+// checkRestrictedUser returns an error if the security context runs as root.
+func checkRestrictedUser(sc map[string]interface{}) error {
+	if v, ok := sc["runAsNonRoot"].(bool); ok && !v {
+		return fmt.Errorf("runAsNonRoot must not be false")
+	}
+	if v, ok := sc["runAsUser"].(float64); ok && v == 0 {
+		return fmt.Errorf("running as root is not allowed")
+	}
+	return nil
+}
+
+// checkRestrictedContainer returns an error if the security context of a container asks for more than the
+// restricted profile allows.
+func checkRestrictedContainer(sc map[string]interface{}) error {
+	if err := checkRestrictedUser(sc); err != nil {
+		return err
+	}
+	if v, _ := sc["privileged"].(bool); v {
+		return fmt.Errorf("privileged containers are not allowed")
+	}
+	if v, _ := sc["allowPrivilegeEscalation"].(bool); v {
+		return fmt.Errorf("privilege escalation is not allowed")
+	}
+	caps, err := ensureChildMap(sc, "capabilities")
+	if err != nil {
+		return err
+	}
+	add, _ := caps["add"].([]interface{})
+	for _, c := range add {
+		if c != "NET_BIND_SERVICE" {
+			return fmt.Errorf("capability %v is not allowed", c)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yml_test
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/test/util/yml"
+)
+
+func TestApplyLabel(t *testing.T) {
+	base := `
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: a
+  labels:
+    app: a
+spec:
+  hosts:
+  - a
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: a
+`
+	cases := []struct {
+		name   string
+		filter func(yml.Descriptor) bool
+		want   map[string]map[string]string
+	}{
+		{
+			name: "all",
+			want: map[string]map[string]string{
+				"VirtualService": {"app": "a", "key": "value"},
+				"Service":        {"key": "value"},
+			},
+		},
+		{
+			name:   "filtered",
+			filter: yml.Select("VirtualService", ""),
+			want: map[string]map[string]string{
+				"VirtualService": {"app": "a", "key": "value"},
+				"Service":        {},
+			},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			out, err := yml.ApplyLabel(base, "key", "value", tt.filter)
+			g.Expect(err).NotTo(HaveOccurred())
+			parts := yml.SplitString(out)
+			g.Expect(parts).To(HaveLen(2))
+			for _, part := range parts {
+				d, err := yml.ParseDescriptor(part)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(labels(g, part)).To(Equal(tt.want[d.Kind]), d.Kind)
+			}
+		})
+	}
+}
+
+func labels(g *WithT, text string) map[string]string {
+	var obj struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	g.Expect(yaml.Unmarshal([]byte(text), &obj)).To(Succeed())
+	if obj.Metadata.Labels == nil {
+		return map[string]string{}
+	}
+	return obj.Metadata.Labels
+}
+
+func TestApplyRestricted(t *testing.T) {
+	base := `
+apiVersion: v1
+kind: Service
+metadata:
+  name: a
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: a
+spec:
+  template:
+    spec:
+      securityContext:
+        fsGroup: 1337
+      initContainers:
+      - name: init
+        securityContext:
+          runAsUser: 1337
+      containers:
+      - name: app
+        securityContext:
+          capabilities:
+            add:
+            - NET_BIND_SERVICE
+---
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: a
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: app
+`
+	g := NewWithT(t)
+	out, err := yml.ApplyRestricted(base, 1000)
+	g.Expect(err).NotTo(HaveOccurred())
+	parts := yml.SplitString(out)
+	g.Expect(parts).To(HaveLen(3))
+
+	g.Expect(parts[0]).NotTo(ContainSubstring("securityContext"))
+
+	var deployment appsv1.Deployment
+	g.Expect(yaml.Unmarshal([]byte(parts[1]), &deployment)).To(Succeed())
+	pod := deployment.Spec.Template.Spec
+	g.Expect(pod.SecurityContext.FSGroup).To(Equal(int64Ptr(1337)))
+	expectRestrictedPod(g, pod)
+	g.Expect(*pod.InitContainers[0].SecurityContext.RunAsUser).To(Equal(int64(1337)))
+	g.Expect(*pod.Containers[0].SecurityContext.RunAsUser).To(Equal(int64(1000)))
+	g.Expect(pod.Containers[0].SecurityContext.Capabilities.Add).To(Equal([]corev1.Capability{"NET_BIND_SERVICE"}))
+
+	var cronJob batchv1beta1.CronJob
+	g.Expect(yaml.Unmarshal([]byte(parts[2]), &cronJob)).To(Succeed())
+	pod = cronJob.Spec.JobTemplate.Spec.Template.Spec
+	expectRestrictedPod(g, pod)
+	g.Expect(*pod.Containers[0].SecurityContext.RunAsUser).To(Equal(int64(1000)))
+}
+
+func expectRestrictedPod(g *WithT, pod corev1.PodSpec) {
+	g.Expect(pod.SecurityContext.RunAsNonRoot).To(Equal(boolPtr(true)))
+	g.Expect(pod.SecurityContext.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+	for _, c := range append(pod.InitContainers, pod.Containers...) {
+		g.Expect(c.SecurityContext.AllowPrivilegeEscalation).To(Equal(boolPtr(false)), c.Name)
+		g.Expect(c.SecurityContext.Capabilities.Drop).To(Equal([]corev1.Capability{"ALL"}), c.Name)
+	}
+}
+
+func TestApplyRestrictedViolations(t *testing.T) {
+	cases := []struct {
+		name string
+		pod  string
+	}{
+		{"host network", `
+      hostNetwork: true
+      containers:
+      - name: app`},
+		{"root pod", `
+      securityContext:
+        runAsUser: 0
+      containers:
+      - name: app`},
+		{"root container", `
+      containers:
+      - name: app
+        securityContext:
+          runAsNonRoot: false`},
+		{"privileged", `
+      containers:
+      - name: app
+        securityContext:
+          privileged: true`},
+		{"privilege escalation", `
+      initContainers:
+      - name: init
+        securityContext:
+          allowPrivilegeEscalation: true`},
+		{"capability", `
+      containers:
+      - name: app
+        securityContext:
+          capabilities:
+            add:
+            - NET_ADMIN`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			_, err := yml.ApplyRestricted(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: a
+spec:
+  template:
+    spec:`+tc.pod, 1000)
+			g.Expect(err).To(HaveOccurred())
+		})
+	}
+}
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,178 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yml
+
+import (
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"istio.io/istio/pkg/test"
+)
+
+// PatchType is the format of a patch.
+type PatchType string
+
+const (
+	// JSONPatch is a list of RFC 6902 operations, such as `[{"op": "replace", "path": "/spec/hosts/0", "value": "b"}]`.
+	JSONPatch PatchType = "json"
+	// MergePatch is an RFC 7386 merge patch, in which lists are replaced as a whole.
+	MergePatch PatchType = "merge"
+	// StrategicMergePatch is a Kubernetes strategic merge patch, in which lists are merged by key. It is only
+	// supported for the kinds of Kubernetes, as it relies on the patch strategies of their Go types.
+	StrategicMergePatch PatchType = "strategic"
+)
+
+// ParseObjects parses the given multi-part yaml text into objects.
+func ParseObjects(yamlText string) ([]unstructured.Unstructured, error) {
+	var out []unstructured.Unstructured
+	for _, part := range SplitString(yamlText) {
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(part), &obj); err != nil {
+			return nil, err
+		}
+		if len(obj) == 0 {
+			// Only comments.
+			continue
+		}
+		out = append(out, unstructured.Unstructured{Object: obj})
+	}
+	return out, nil
+}
+
+// Decode the given single-part yaml text into a typed object, such as a Kubernetes or Istio API type.
+func Decode(yamlText string, into interface{}) error {
+	return yaml.Unmarshal([]byte(yamlText), into)
+}
+
+// Serialize the objects into a multi-part yaml text. Objects are either typed, or unstructured.
+func Serialize(objs ...interface{}) (string, error) {
+	parts := make([]string, 0, len(objs))
+	for _, o := range objs {
+		switch u := o.(type) {
+		case unstructured.Unstructured:
+			o = u.Object
+		case *unstructured.Unstructured:
+			o = u.Object
+		}
+		by, err := yaml.Marshal(o)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, string(by))
+	}
+	return JoinString(parts...), nil
+}
+
+// Select returns a filter of the resources of the given kind and name, or of all resources of the kind if name is
+// empty.
+func Select(kind, name string) func(d Descriptor) bool {
+	return func(d Descriptor) bool {
+		return d.Kind == kind && (name == "" || d.Metadata.Name == name)
+	}
+}
+
+// Patch applies the patch, in YAML or JSON, to the resources in the yamlText for which the filter, if any, returns
+// true. It fails if no resource was patched.
+func Patch(yamlText string, filter func(d Descriptor) bool, pt PatchType, patch string) (string, error) {
+	patchJSON, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return "", fmt.Errorf("failed converting patch to JSON: %v", err)
+	}
+	chunks := SplitString(yamlText)
+	toJoin := make([]string, 0, len(chunks))
+	patched := 0
+	for _, chunk := range chunks {
+		d, err := ParseDescriptor(chunk)
+		if err != nil {
+			return "", err
+		}
+		if filter != nil && !filter(d) {
+			toJoin = append(toJoin, chunk)
+			continue
+		}
+		chunk, err = patchPart(chunk, pt, patchJSON)
+		if err != nil {
+			return "", fmt.Errorf("failed patching %s %s: %v", d.Kind, d.Metadata.Name, err)
+		}
+		toJoin = append(toJoin, chunk)
+		patched++
+	}
+	if patched == 0 {
+		return "", fmt.Errorf("no resources matched the filter of the patch")
+	}
+	return JoinString(toJoin...), nil
+}
+
+// PatchOrFail calls Patch and fails the test if it returns an error.
+func PatchOrFail(t test.Failer, yamlText string, filter func(d Descriptor) bool, pt PatchType, patch string) string {
+	t.Helper()
+	out, err := Patch(yamlText, filter, pt, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func patchPart(yamlText string, pt PatchType, patchJSON []byte) (string, error) {
+	doc, err := yaml.YAMLToJSON([]byte(yamlText))
+	if err != nil {
+		return "", err
+	}
+	var out []byte
+	switch pt {
+	case JSONPatch:
+		p, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return "", fmt.Errorf("invalid JSON patch: %v", err)
+		}
+		out, err = p.Apply(doc)
+		if err != nil {
+			return "", err
+		}
+	case MergePatch:
+		out, err = jsonpatch.MergePatch(doc, patchJSON)
+		if err != nil {
+			return "", err
+		}
+	case StrategicMergePatch:
+		var typeMeta kubeApiMeta.TypeMeta
+		if err := yaml.Unmarshal(doc, &typeMeta); err != nil {
+			return "", err
+		}
+		typed, err := scheme.Scheme.New(schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind))
+		if err != nil {
+			return "", fmt.Errorf("strategic merge patches are only supported for Kubernetes kinds, "+
+				"use a merge patch instead: %v", err)
+		}
+		out, err = strategicpatch.StrategicMergePatch(doc, patchJSON, typed)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unknown patch type %q", pt)
+	}
+	by, err := yaml.JSONToYAML(out)
+	if err != nil {
+		return "", err
+	}
+	return string(by), nil
+}
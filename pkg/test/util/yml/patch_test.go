@@ -0,0 +1,124 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yml_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/pkg/test/util/yml"
+)
+
+const patchBase = `
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: a
+spec:
+  hosts:
+  - a
+  http:
+  - timeout: 1s
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: a
+spec:
+  ports:
+  - name: http
+    port: 80
+`
+
+func TestPatch(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter func(yml.Descriptor) bool
+		pt     yml.PatchType
+		patch  string
+		path   []string
+		want   interface{}
+	}{
+		{
+			name:   "json",
+			filter: yml.Select("VirtualService", "a"),
+			pt:     yml.JSONPatch,
+			patch:  `[{"op": "replace", "path": "/spec/hosts/0", "value": "b"}]`,
+			path:   []string{"spec", "hosts"},
+			want:   []interface{}{"b"},
+		},
+		{
+			name:   "merge",
+			filter: yml.Select("VirtualService", ""),
+			pt:     yml.MergePatch,
+			patch: `
+metadata:
+  labels:
+    variant: b
+`,
+			path: []string{"metadata", "labels", "variant"},
+			want: "b",
+		},
+		{
+			name:   "strategic",
+			filter: yml.Select("Service", "a"),
+			pt:     yml.StrategicMergePatch,
+			patch: `
+spec:
+  ports:
+  - name: tcp
+    port: 90
+`,
+			path: []string{"spec", "ports"},
+			want: []interface{}{
+				map[string]interface{}{"name": "http", "port": float64(80)},
+				map[string]interface{}{"name": "tcp", "port": float64(90)},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := NewWithT(t)
+			out, err := yml.Patch(patchBase, c.filter, c.pt, c.patch)
+			g.Expect(err).To(BeNil())
+			objs, err := yml.ParseObjects(out)
+			g.Expect(err).To(BeNil())
+			g.Expect(objs).To(HaveLen(2))
+			for _, o := range objs {
+				if !c.filter(yml.Descriptor{Kind: o.GetKind(), Metadata: yml.Metadata{Name: o.GetName()}}) {
+					continue
+				}
+				var got interface{} = o.Object
+				for _, p := range c.path {
+					got = got.(map[string]interface{})[p]
+				}
+				g.Expect(got).To(Equal(c.want))
+			}
+		})
+	}
+}
+
+func TestPatchNoMatch(t *testing.T) {
+	g := NewWithT(t)
+	_, err := yml.Patch(patchBase, yml.Select("Gateway", ""), yml.MergePatch, `{"spec": {}}`)
+	g.Expect(err).NotTo(BeNil())
+}
+
+func TestStrategicPatchUnknownKind(t *testing.T) {
+	g := NewWithT(t)
+	_, err := yml.Patch(patchBase, yml.Select("VirtualService", ""), yml.StrategicMergePatch, `{"spec": {}}`)
+	g.Expect(err).NotTo(BeNil())
+}
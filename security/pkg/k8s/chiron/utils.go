@@ -73,6 +73,14 @@ func getRandomCsrName(secretName, namespace string) string {
 // 5. Clean up the artifacts (e.g., delete CSR)
 func GenKeyCertK8sCA(certClient certclient.CertificateSigningRequestInterface, dnsName,
 	secretName, secretNamespace, caFilePath string) ([]byte, []byte, []byte, error) {
+	return GenKeyCertK8sCAWithSigner(certClient, dnsName, secretName, secretNamespace, caFilePath, "")
+}
+
+// GenKeyCertK8sCAWithSigner generates a certificate and key as GenKeyCertK8sCA does, requesting them from the given
+// signer. An empty signer name requests none, which Kubernetes sets to kubernetes.io/legacy-unknown. Requests to a
+// named signer are not approved by the caller but left to the signer.
+func GenKeyCertK8sCAWithSigner(certClient certclient.CertificateSigningRequestInterface, dnsName,
+	secretName, secretNamespace, caFilePath, signerName string) ([]byte, []byte, []byte, error) {
 	// 1. Generate a CSR
 	options := util.CertOptions{
 		Host:       dnsName,
@@ -89,7 +97,7 @@ func GenKeyCertK8sCA(certClient certclient.CertificateSigningRequestInterface, d
 	// 2. Submit the CSR
 	csrName := getRandomCsrName(secretName, secretNamespace)
 	numRetries := 3
-	r, err := submitCSR(certClient, csrName, csrPEM, numRetries)
+	r, err := submitCSR(certClient, csrName, csrPEM, signerName, numRetries)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -97,24 +105,28 @@ func GenKeyCertK8sCA(certClient certclient.CertificateSigningRequestInterface, d
 		return nil, nil, nil, fmt.Errorf("the CSR returned is nil")
 	}
 
-	// 3. Approve a CSR
-	log.Debugf("approve CSR (%v) ...", csrName)
-	csrMsg := fmt.Sprintf("CSR (%s) for the certificate (%s) is approved", csrName, dnsName)
-	r.Status.Conditions = append(r.Status.Conditions, cert.CertificateSigningRequestCondition{
-		Type:    cert.CertificateApproved,
-		Reason:  csrMsg,
-		Message: csrMsg,
-	})
-	reqApproval, err := certClient.UpdateApproval(context.TODO(), r, metav1.UpdateOptions{})
-	if err != nil {
-		log.Errorf("failed to approve CSR (%v): %v", csrName, err)
-		errCsr := cleanUpCertGen(certClient, csrName)
-		if errCsr != nil {
-			log.Errorf("failed to clean up CSR (%v): %v", csrName, err)
+	// 3. Approve a CSR. Requests to a named signer are left to the approver of that signer: the ClusterRole of istiod
+	// only allows approving for kubernetes.io/legacy-unknown. Not approving grants nothing, as signers only sign
+	// approved requests; a request nobody approves is deleted once reading its certificate times out.
+	if signerName == "" {
+		log.Debugf("approve CSR (%v) ...", csrName)
+		csrMsg := fmt.Sprintf("CSR (%s) for the certificate (%s) is approved", csrName, dnsName)
+		r.Status.Conditions = append(r.Status.Conditions, cert.CertificateSigningRequestCondition{
+			Type:    cert.CertificateApproved,
+			Reason:  csrMsg,
+			Message: csrMsg,
+		})
+		reqApproval, err := certClient.UpdateApproval(context.TODO(), r, metav1.UpdateOptions{})
+		if err != nil {
+			log.Errorf("failed to approve CSR (%v): %v", csrName, err)
+			errCsr := cleanUpCertGen(certClient, csrName)
+			if errCsr != nil {
+				log.Errorf("failed to clean up CSR (%v): %v", csrName, err)
+			}
+			return nil, nil, nil, err
 		}
-		return nil, nil, nil, err
+		log.Debugf("CSR (%v) is approved: %v", csrName, reqApproval)
 	}
-	log.Debugf("CSR (%v) is approved: %v", csrName, reqApproval)
 
 	// 4. Read the signed certificate
 	certChain, caCert, err := readSignedCertificate(certClient,
@@ -188,7 +200,7 @@ func reloadCACert(wc *WebhookController) (bool, error) {
 }
 
 func submitCSR(certClient certclient.CertificateSigningRequestInterface, csrName string,
-	csrPEM []byte, numRetries int) (*cert.CertificateSigningRequest, error) {
+	csrPEM []byte, signerName string, numRetries int) (*cert.CertificateSigningRequest, error) {
 	k8sCSR := &cert.CertificateSigningRequest{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "certificates.k8s.io/v1beta1",
@@ -208,6 +220,9 @@ func submitCSR(certClient certclient.CertificateSigningRequestInterface, csrName
 			},
 		},
 	}
+	if signerName != "" {
+		k8sCSR.Spec.SignerName = &signerName
+	}
 	var reqRet *cert.CertificateSigningRequest
 	var errRet error
 	for i := 0; i < numRetries; i++ {
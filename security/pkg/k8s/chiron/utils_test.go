@@ -149,6 +149,67 @@ func TestGenKeyCertK8sCA(t *testing.T) {
 	}
 }
 
+func TestGenKeyCertK8sCAWithSigner(t *testing.T) {
+	testCases := map[string]struct {
+		signerName     string
+		expectApproved bool
+	}{
+		"no signer is approved by the caller": {
+			signerName:     "",
+			expectApproved: true,
+		},
+		"named signer is left to its approver": {
+			signerName:     "example.com/signer",
+			expectApproved: false,
+		},
+	}
+
+	for id, tc := range testCases {
+		client := fake.NewSimpleClientset()
+		csr := &cert.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "domain-cluster.local-ns--secret-mock-secret",
+			},
+			Status: cert.CertificateSigningRequestStatus{
+				Certificate: []byte(exampleIssuedCert),
+			},
+		}
+		client.PrependReactor("get", "certificatesigningrequests", defaultReactionFunc(csr))
+
+		_, _, _, err := GenKeyCertK8sCAWithSigner(client.CertificatesV1beta1().CertificateSigningRequests(), "foo",
+			"istio.webhook.foo", "foo.ns", "./test-data/example-ca-cert.pem", tc.signerName)
+		if err != nil {
+			t.Errorf("%s: failed unexpectedly: %v", id, err)
+			continue
+		}
+
+		approved := false
+		var submitted *cert.CertificateSigningRequest
+		for _, action := range client.Actions() {
+			switch {
+			case action.Matches("update", "certificatesigningrequests") && action.GetSubresource() == "approval":
+				approved = true
+			case action.Matches("create", "certificatesigningrequests"):
+				submitted = action.(kt.CreateAction).GetObject().(*cert.CertificateSigningRequest)
+			}
+		}
+		if approved != tc.expectApproved {
+			t.Errorf("%s: got approved %v, want %v", id, approved, tc.expectApproved)
+		}
+		if submitted == nil {
+			t.Errorf("%s: no CSR was submitted", id)
+			continue
+		}
+		var got string
+		if submitted.Spec.SignerName != nil {
+			got = *submitted.Spec.SignerName
+		}
+		if got != tc.signerName {
+			t.Errorf("%s: got signer name %q, want %q", id, got, tc.signerName)
+		}
+	}
+}
+
 func TestReadCACert(t *testing.T) {
 	testCases := map[string]struct {
 		certPath     string
@@ -362,7 +423,7 @@ func TestSubmitCSR(t *testing.T) {
 			}
 		}
 
-		r, err := submitCSR(wc.certClient.CertificateSigningRequests(), csrName, []byte(csrPEM), numRetries)
+		r, err := submitCSR(wc.certClient.CertificateSigningRequests(), csrName, []byte(csrPEM), "", numRetries)
 		if tc.expectFail {
 			if err == nil {
 				t.Errorf("should have failed")
@@ -373,6 +434,23 @@ func TestSubmitCSR(t *testing.T) {
 	}
 }
 
+func TestSubmitCSRSignerName(t *testing.T) {
+	for _, signerName := range []string{"", "example.com/signer"} {
+		client := fake.NewSimpleClientset()
+		r, err := submitCSR(client.CertificatesV1beta1().CertificateSigningRequests(), "csr", []byte("fake-csr"), signerName, 1)
+		if err != nil {
+			t.Fatalf("failed submitting the CSR: %v", err)
+		}
+		var got string
+		if r.Spec.SignerName != nil {
+			got = *r.Spec.SignerName
+		}
+		if got != signerName {
+			t.Errorf("got signer name %q, want %q", got, signerName)
+		}
+	}
+}
+
 func TestReadSignedCertificate(t *testing.T) {
 	testCases := map[string]struct {
 		gracePeriodRatio  float32
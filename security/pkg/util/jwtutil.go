@@ -25,7 +25,7 @@ import (
 
 // IsJwtExpired checks if the JWT token is expired compared with the given time, without validating it.
 func IsJwtExpired(token string, now time.Time) (bool, error) {
-	claims, err := parseJwtClaims(token)
+	claims, err := ParseJwtClaims(token)
 	if err != nil {
 		return true, err
 	}
@@ -51,7 +51,7 @@ func IsJwtExpired(token string, now time.Time) (bool, error) {
 
 // GetAud returns the claim `aud` from the token. Returns nil if not found.
 func GetAud(token string) ([]string, error) {
-	claims, err := parseJwtClaims(token)
+	claims, err := ParseJwtClaims(token)
 	if err != nil {
 		return nil, err
 	}
@@ -113,7 +113,8 @@ func IsK8SUnbound(jwt string) bool {
 	return len(structuredPayload.Aud) == 0
 }
 
-func parseJwtClaims(token string) (map[string]interface{}, error) {
+// ParseJwtClaims decodes the claims of the JWT token, without validating it.
+func ParseJwtClaims(token string) (map[string]interface{}, error) {
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
 		return nil, fmt.Errorf("token contains an invalid number of segments: %d, expected: 3", len(parts))
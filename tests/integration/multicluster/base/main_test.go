@@ -58,3 +58,11 @@ func TestClusterLocalService(t *testing.T) {
 func TestTelemetry(t *testing.T) {
 	multicluster.TelemetryTest(t, appCtx, "installation.multicluster.multimaster", "installation.multicluster.remote")
 }
+
+func TestWANLatency(t *testing.T) {
+	multicluster.WANLatencyTest(t, appCtx, "installation.multicluster.multimaster", "installation.multicluster.remote")
+}
+
+func TestCredentialRotation(t *testing.T) {
+	multicluster.CredentialRotationTest(t, appCtx, ist, "installation.multicluster.multimaster", "installation.multicluster.remote")
+}
@@ -0,0 +1,143 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/features"
+	"istio.io/istio/pkg/test/framework/label"
+	"istio.io/istio/pkg/test/kube"
+)
+
+// CredentialRotationTest tests that the endpoints of a cluster stay discovered by the control planes of the other
+// clusters while the credentials of their remote secrets for it are rotated, and that discovery continues with the
+// new credentials once the previous ones are revoked.
+func CredentialRotationTest(t *testing.T, apps AppContext, ist istio.Instance, features ...features.Feature) {
+	framework.NewTest(t).
+		Label(label.Multicluster).
+		Features(features...).
+		Run(func(ctx framework.TestContext) {
+			clusters := ctx.Clusters()
+			source := clusters[len(clusters)-1]
+			dest := apps.UniqueEchos.GetOrFail(ctx, echo.InCluster(source))
+			var srcs echo.Instances
+			for _, c := range clusters[:len(clusters)-1] {
+				srcs = append(srcs, apps.UniqueEchos.GetOrFail(ctx, echo.InCluster(c)))
+			}
+			for _, src := range srcs {
+				callOrFail(ctx, src, dest)
+			}
+
+			// Requests keep being routed to the endpoints of the source cluster while its remote secrets are replaced.
+			stop := sendContinuously(srcs, dest)
+			rotateErr := ist.RotateRemoteCredentials(source)
+			requests, failures := stop()
+			if rotateErr != nil {
+				ctx.Fatal(rotateErr)
+			}
+			if len(failures) > 0 {
+				ctx.Fatalf("%d of %d requests failed during the rotation of the credentials of %s: %v",
+					len(failures), requests, source.Name(), failures[0])
+			}
+			if requests == 0 {
+				ctx.Fatalf("no requests were sent during the rotation of the credentials of %s", source.Name())
+			}
+
+			// The previous credentials are revoked, so new endpoints can only be discovered with the new ones.
+			ns := dest.Config().Namespace.Name()
+			selector := "app=" + dest.Config().Service
+			if err := kube.RestartDeployments(source, ns, selector); err != nil {
+				ctx.Fatal(err)
+			}
+			dest.RefreshOrFail(ctx)
+			pods, err := source.PodsForSelector(context.TODO(), ns, selector)
+			if err != nil {
+				ctx.Fatal(err)
+			}
+			restarted := map[string]bool{}
+			for _, p := range pods.Items {
+				if p.DeletionTimestamp == nil {
+					restarted[p.Name] = true
+				}
+			}
+			for _, src := range srcs {
+				callOrFail(ctx, src, dest, func(responses client.ParsedResponses) error {
+					for _, r := range responses {
+						if !restarted[r.Hostname] {
+							return fmt.Errorf("request reached %s rather than a restarted pod of %s", r.Hostname,
+								dest.Config().Service)
+						}
+					}
+					return nil
+				})
+			}
+		})
+}
+
+// sendContinuously calls dest from each source until the returned function is called, which returns the number of
+// requests sent and the failed ones.
+func sendContinuously(srcs echo.Instances, dest echo.Instance) func() (int, []error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		requests int
+		failures []error
+	)
+	done := make(chan struct{})
+	for _, src := range srcs {
+		src := src
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				case <-time.After(retryDelay):
+				}
+				results, err := src.Call(echo.CallOptions{
+					Target:   dest,
+					PortName: "http",
+					Scheme:   scheme.HTTP,
+				})
+				if err == nil {
+					err = results.CheckOK()
+				}
+				mu.Lock()
+				requests++
+				if err != nil {
+					failures = append(failures, fmt.Errorf("%s to %s: %v", src.Config().Service, dest.Config().Service, err))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	return func() (int, []error) {
+		close(done)
+		wg.Wait()
+		return requests, failures
+	}
+}
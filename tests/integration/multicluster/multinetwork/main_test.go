@@ -0,0 +1,132 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multinetwork
+
+import (
+	"fmt"
+	"testing"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/crossnetwork"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/label"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+var (
+	ist istio.Instance
+	// servers has one instance per cluster, each in a namespace of its own, so that calls to it must reach that
+	// cluster. This also holds for virtual clusters, which share namespaces.
+	servers echo.Instances
+)
+
+func TestMain(m *testing.M) {
+	framework.
+		NewSuite(m).
+		Label(label.Multicluster).
+		RequireMinClusters(2).
+		RequireMultiNetwork().
+		Setup(istio.Setup(&ist, nil)).
+		Setup(setupApps).
+		Run()
+}
+
+func setupApps(ctx resource.Context) error {
+	builder := echoboot.NewBuilder(ctx)
+	for _, c := range ctx.Clusters() {
+		cfg := namespace.Config{Prefix: "multinetwork-" + c.Name(), Inject: true}
+		if kc, ok := c.(kube.Cluster); ok {
+			// Virtual clusters other than the first inject through their own revision.
+			cfg.Revision = kc.Revision()
+		}
+		ns, err := namespace.New(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		builder.With(nil, echo.Config{
+			Service:   "server",
+			Namespace: ns,
+			Cluster:   c,
+			Subsets:   []echo.SubsetConfig{{}},
+			Ports: []echo.Port{{
+				Name:         "http",
+				Protocol:     protocol.HTTP,
+				InstancePort: 8090,
+			}},
+		})
+	}
+	var err error
+	servers, err = builder.Build()
+	return err
+}
+
+// TestCrossNetworkThroughGateway tests that calls to workloads on another network reach them through the east-west
+// gateway of that network.
+func TestCrossNetworkThroughGateway(t *testing.T) {
+	framework.NewTest(t).
+		Features("installation.multicluster.multinetwork").
+		Run(func(ctx framework.TestContext) {
+			for _, src := range servers {
+				for _, dst := range servers {
+					src, dst := src, dst
+					if src.Config().Cluster.NetworkName() == dst.Config().Cluster.NetworkName() {
+						continue
+					}
+					ctx.NewSubTest(fmt.Sprintf("%s to %s", src.Config().Cluster.Name(), dst.Config().Cluster.Name())).
+						Run(func(ctx framework.TestContext) {
+							gw := ist.EastWestGatewayFor(dst.Config().Cluster)
+							retry.UntilSuccessOrFail(ctx, func() error {
+								before, err := crossnetwork.GatewayStats(gw)
+								if err != nil {
+									return err
+								}
+								resp, err := src.Call(echo.CallOptions{
+									Target:   dst,
+									PortName: "http",
+									Scheme:   scheme.HTTP,
+									Count:    5,
+								})
+								if err != nil {
+									return err
+								}
+								if err := resp.CheckOK(); err != nil {
+									return err
+								}
+								if err := resp.CheckCluster(dst.Config().Cluster.Name()); err != nil {
+									return err
+								}
+								after, err := crossnetwork.GatewayStats(gw)
+								if err != nil {
+									return err
+								}
+								if after.TxBytes <= before.TxBytes {
+									return fmt.Errorf("no bytes were forwarded by the east-west gateway of %s",
+										dst.Config().Cluster.Name())
+								}
+								return nil
+							})
+						})
+				}
+			}
+		})
+}
@@ -0,0 +1,84 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package multicluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/netshaping"
+	"istio.io/istio/pkg/test/framework/features"
+	"istio.io/istio/pkg/test/framework/label"
+)
+
+// wanLatency is added to the traffic of the first cluster to the others.
+const wanLatency = 300 * time.Millisecond
+
+// WANLatencyTest tests that requests to other clusters reach them, while taking the latency of a degraded network
+// between the clusters, and that requests within the cluster are unaffected.
+func WANLatencyTest(t *testing.T, apps AppContext, features ...features.Feature) {
+	framework.NewTest(t).
+		Label(label.Multicluster).
+		Features(features...).
+		Run(func(ctx framework.TestContext) {
+			clusters := ctx.Clusters()
+			var links []netshaping.Link
+			for _, to := range clusters[1:] {
+				links = append(links, netshaping.Link{
+					From:       clusters[0],
+					To:         to,
+					Conditions: netshaping.Conditions{Latency: wanLatency},
+				})
+			}
+			netshaping.ShapeOrFail(ctx, ctx, links...)
+
+			src := apps.UniqueEchos.GetOrFail(ctx, echo.InCluster(clusters[0]))
+			for _, dstCluster := range clusters {
+				dstCluster := dstCluster
+				dest := apps.UniqueEchos.GetOrFail(ctx, echo.InCluster(dstCluster))
+				ctx.NewSubTest(fmt.Sprintf("to %s", dstCluster.Name())).
+					Run(func(ctx framework.TestContext) {
+						callOrFail(ctx, src, dest)
+						// The fastest of several requests bounds the latency of the network, rather than of the proxies.
+						fastest := time.Duration(0)
+						for i := 0; i < 5; i++ {
+							start := time.Now()
+							if _, err := src.Call(echo.CallOptions{
+								Target:   dest,
+								PortName: "http",
+								Scheme:   scheme.HTTP,
+							}); err != nil {
+								ctx.Fatal(err)
+							}
+							if d := time.Since(start); fastest == 0 || d < fastest {
+								fastest = d
+							}
+						}
+						shaped := dstCluster.Name() != clusters[0].Name()
+						if shaped && fastest < wanLatency {
+							ctx.Fatalf("request to shaped cluster took %v, less than the added latency of %v", fastest, wanLatency)
+						}
+						if !shaped && fastest >= wanLatency {
+							ctx.Fatalf("request within the cluster took %v, at least the latency added to other clusters", fastest)
+						}
+					})
+			}
+		})
+}
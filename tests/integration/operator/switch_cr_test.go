@@ -43,6 +43,7 @@ import (
 	"istio.io/istio/pkg/test/framework/components/environment/kube"
 	"istio.io/istio/pkg/test/framework/components/istioctl"
 	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/crs"
 	"istio.io/istio/pkg/test/framework/image"
 	"istio.io/istio/pkg/test/framework/resource"
 	kube2 "istio.io/istio/pkg/test/kube"
@@ -348,12 +349,6 @@ func compareInClusterAndGeneratedResources(t *testing.T, istioCtl istioctl.Insta
 	if err != nil {
 		return fmt.Errorf("failed to parse generated manifest: %v", err)
 	}
-	efgvr := schema.GroupVersionResource{
-		Group:    "networking.istio.io",
-		Version:  "v1alpha3",
-		Resource: "envoyfilters",
-	}
-
 	for _, genK8SObject := range genK8SObjects {
 		kind := genK8SObject.Kind
 		ns := genK8SObject.Namespace
@@ -394,8 +389,7 @@ func compareInClusterAndGeneratedResources(t *testing.T, istioCtl istioctl.Insta
 					return fmt.Errorf("failed to get expected CustomResourceDefinition: %s from cluster", name)
 				}
 			case "EnvoyFilter":
-				if _, err := cs.Dynamic().Resource(efgvr).Namespace(ns).Get(context.TODO(), name,
-					kubeApiMeta.GetOptions{}); err != nil {
+				if _, err := crs.EnvoyFilters(cs, ns).Get(context.TODO(), name, kubeApiMeta.GetOptions{}); err != nil {
 					return fmt.Errorf("failed to get expected Envoyfilter: %s from cluster", name)
 				}
 			case "PodDisruptionBudget":
@@ -0,0 +1,73 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package abtest
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/abtest"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+var (
+	// Both revisions run the same build, so any difference in their xDS is an artifact of the harness.
+	baseline  = abtest.Revision{Name: "baseline"}
+	candidate = abtest.Revision{Name: "candidate"}
+)
+
+func TestMain(m *testing.M) {
+	framework.
+		NewSuite(m).
+		RequireSingleCluster().
+		Setup(abtest.Setup(baseline, candidate)).
+		Run()
+}
+
+func TestIdenticalRevisions(t *testing.T) {
+	framework.NewTest(t).
+		Run(func(ctx framework.TestContext) {
+			h := abtest.NewOrFail(t, ctx, "abtest", baseline, candidate)
+			ports := []echo.Port{{
+				Name:         "http",
+				Protocol:     protocol.HTTP,
+				InstancePort: 8090,
+			}}
+			pairs := h.DeployOrFail(t,
+				echo.Config{Service: "client", Ports: ports},
+				echo.Config{Service: "server", Ports: ports})
+			h.ApplyConfigOrFail(t, `
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: server
+spec:
+  hosts:
+  - server
+  http:
+  - route:
+    - destination:
+        host: server
+    timeout: 5s
+`)
+			// The config is pushed to each revision independently, so wait for both to converge.
+			retry.UntilSuccessOrFail(t, func() error {
+				return h.CheckNoDiff(pairs...)
+			})
+		})
+}
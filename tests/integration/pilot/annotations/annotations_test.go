@@ -0,0 +1,48 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/proxyannotations"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// TestProxyAnnotations deploys a workload for each case of the matrix, and verifies the behavior of calls between it
+// and its unannotated peer.
+func TestProxyAnnotations(t *testing.T) {
+	framework.NewTest(t).
+		Run(func(ctx framework.TestContext) {
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{
+				Prefix: "proxy-annotations",
+				Inject: true,
+			})
+			cases := proxyannotations.Cases(true)
+			d := proxyannotations.DeployOrFail(ctx, ctx, ns, cases)
+			for _, c := range cases {
+				c := c
+				ctx.NewSubTest(c.Name).Run(func(ctx framework.TestContext) {
+					if err := proxyannotations.Verify(d, c, retry.Timeout(time.Minute)); err != nil {
+						ctx.Fatal(err)
+					}
+				})
+			}
+		})
+}
@@ -0,0 +1,74 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// churnWorkload is applied to each churned namespace, so that istiod has services to add and remove.
+const churnWorkload = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: churn
+spec:
+  selector:
+    app: churn
+  ports:
+  - name: http
+    port: 80
+`
+
+// TestNamespaceChurn verifies that traffic between existing workloads is unaffected while injected namespaces with
+// services are continuously created and deleted.
+func TestNamespaceChurn(t *testing.T) {
+	framework.NewTest(t).
+		Run(func(ctx framework.TestContext) {
+			if ctx.Settings().StableNamespaces {
+				ctx.Skip("namespace churn is not supported with stable namespaces")
+			}
+			churner := namespace.ChurnOrFail(ctx, ctx, namespace.ChurnConfig{
+				Namespace:     namespace.Config{Prefix: "churn", Inject: true},
+				Interval:      2 * time.Second,
+				MaxNamespaces: 5,
+				Workload:      churnWorkload,
+			})
+			src := apps.PodA[0]
+			dst := apps.PodB.GetOrFail(ctx, echo.InCluster(src.Config().Cluster))
+			end := time.Now().Add(30 * time.Second)
+			for time.Now().Before(end) {
+				retry.UntilSuccessOrFail(ctx, func() error {
+					resp, err := src.Call(echo.CallOptions{Target: dst, PortName: "http"})
+					if err != nil {
+						return err
+					}
+					return resp.CheckOK()
+				}, retry.Delay(100*time.Millisecond), retry.Timeout(10*time.Second))
+				time.Sleep(time.Second)
+			}
+			churner.StopOrFail(ctx)
+			if stats := churner.Stats(); stats.Created < 2 || stats.Deleted != stats.Created {
+				ctx.Fatalf("expected namespaces to be churned and then deleted, got %+v", stats)
+			}
+		})
+}
@@ -0,0 +1,108 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// TestCircuitBreaker verifies that requests exceeding the connection pool of the destination are rejected by the
+// client proxy, and that the overflows are reported in its stats.
+func TestCircuitBreaker(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.circuitbreaker").
+		RequiresSingleCluster().
+		Run(func(ctx framework.TestContext) {
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "circuitbreaker", Inject: true})
+			clientCfg := echoConfig(ns, "client")
+			clientCfg.Subsets = []echo.SubsetConfig{{
+				Annotations: echo.NewAnnotations().Set(echo.SidecarStatsInclusionPrefixes, echo.CircuitBreakerStatsPrefix),
+			}}
+			var client, server echo.Instance
+			echoboot.NewBuilder(ctx).
+				With(&client, clientCfg).
+				With(&server, echoConfig(ns, "server")).
+				BuildOrFail(ctx)
+
+			// A single connection with a single pending request: all other concurrent requests overflow.
+			ctx.Config().ApplyYAMLOrFail(ctx, ns.Name(), fmt.Sprintf(`
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: server
+spec:
+  host: %s
+  trafficPolicy:
+    connectionPool:
+      tcp:
+        maxConnections: 1
+      http:
+        http1MaxPendingRequests: 1
+        maxRequestsPerConnection: 1
+`, server.Config().FQDN()))
+
+			port := server.Config().Ports[0]
+			cluster := echo.OutboundClusterName(server, port)
+			sidecar := client.WorkloadsOrFail(ctx)[0].Sidecar()
+			retry.UntilSuccessOrFail(ctx, func() error {
+				result := echo.CallConcurrently(client, echo.ConcurrentCallOptions{
+					Call:        echo.CallOptions{Target: server, PortName: port.Name},
+					Concurrency: 10,
+				})
+				if result.Errors > 0 || result.Overflowed == 0 {
+					return fmt.Errorf("expected requests to overflow without errors, got %d overflowed and %d errors",
+						result.Overflowed, result.Errors)
+				}
+				return nil
+			}, retry.Delay(time.Second), retry.Timeout(time.Minute))
+
+			retry.UntilSuccessOrFail(ctx, func() error {
+				stats, err := sidecar.Stats()
+				if err != nil {
+					return err
+				}
+				if echo.ClusterStat(stats, echo.UpstreamPendingRequestOverflow, cluster) == 0 {
+					return fmt.Errorf("expected %s to report overflows of %s", echo.UpstreamPendingRequestOverflow, cluster)
+				}
+				return nil
+			}, retry.Delay(time.Second), retry.Timeout(30*time.Second))
+
+			// Requests made one at a time stay within the pool and are not counted as overflows.
+			stats, err := sidecar.Stats()
+			if err != nil {
+				ctx.Fatal(err)
+			}
+			overflowed := echo.ClusterStat(stats, echo.UpstreamPendingRequestOverflow, cluster)
+			result := echo.CallConcurrently(client, echo.ConcurrentCallOptions{
+				Call: echo.CallOptions{Target: server, PortName: port.Name},
+				Hold: 100 * time.Millisecond,
+			})
+			if result.Overflowed != 0 || result.Errors != 0 {
+				ctx.Fatalf("expected a single request to succeed, got %+v", result)
+			}
+			if err := echo.CheckClusterStat(sidecar, echo.UpstreamPendingRequestOverflow, cluster, overflowed); err != nil {
+				ctx.Fatal(err)
+			}
+		})
+}
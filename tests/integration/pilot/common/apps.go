@@ -220,7 +220,21 @@ spec:
 		return err
 	}
 
-	se, err := tmpl.Evaluate(`apiVersion: networking.istio.io/v1alpha3
+	se, err := serviceEntryTemplate.Evaluate(serviceEntryParams{
+		Namespace: apps.ExternalNamespace.Name(),
+		Hostname:  externalHostname,
+		Ports:     serviceEntryPorts(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := ctx.Config().ApplyYAML(apps.Namespace.Name(), se); err != nil {
+		return err
+	}
+	return nil
+}
+
+var serviceEntryTemplate = tmpl.MustNewTyped(`apiVersion: networking.istio.io/v1alpha3
 kind: ServiceEntry
 metadata:
   name: external-service
@@ -237,14 +251,13 @@ spec:
     number: {{$p.ServicePort}}
     protocol: "{{$p.Protocol}}"
 {{- end }}
-`, map[string]interface{}{"Namespace": apps.ExternalNamespace.Name(), "Hostname": externalHostname, "Ports": serviceEntryPorts()})
-	if err != nil {
-		return err
-	}
-	if err := ctx.Config().ApplyYAML(apps.Namespace.Name(), se); err != nil {
-		return err
-	}
-	return nil
+`)
+
+// serviceEntryParams are the parameters of serviceEntryTemplate.
+type serviceEntryParams struct {
+	Namespace string `tmpl:"required"`
+	Hostname  string `tmpl:"required"`
+	Ports     []echo.Port
 }
 
 func (d EchoDeployments) IsMulticluster() bool {
@@ -47,8 +47,7 @@ spec:
 `, host)
 }
 
-func httpVirtualService(gateway, host string, port int) string {
-	return tmpl.MustEvaluate(`apiVersion: networking.istio.io/v1alpha3
+var httpVirtualServiceTemplate = tmpl.MustNewTyped(`apiVersion: networking.istio.io/v1alpha3
 kind: VirtualService
 metadata:
   name: {{.Host}}
@@ -64,11 +63,17 @@ spec:
         port:
           number: {{.Port}}
 ---
-`, struct {
-		Gateway string
-		Host    string
-		Port    int
-	}{gateway, host, port})
+`)
+
+// httpVirtualServiceParams are the parameters of httpVirtualServiceTemplate.
+type httpVirtualServiceParams struct {
+	Gateway string `tmpl:"required"`
+	Host    string `tmpl:"required"`
+	Port    int    `tmpl:"required"`
+}
+
+func httpVirtualService(gateway, host string, port int) string {
+	return httpVirtualServiceTemplate.MustEvaluate(httpVirtualServiceParams{Gateway: gateway, Host: host, Port: port})
 }
 
 func virtualServiceCases(apps *EchoDeployments) []TrafficTestCase {
@@ -620,3 +625,89 @@ func serverFirstTestCases(apps *EchoDeployments) []TrafficTestCase {
 
 	return cases
 }
+
+const (
+	// sessionCookie is the cookie of the sessions generated by the proxies in stickySessionCases.
+	sessionCookie = "echo-session"
+	// sessionHeader is the header of the sessions issued by the echo servers in stickySessionCases.
+	sessionHeader = "x-echo-session"
+)
+
+func stickySessionCases(apps *EchoDeployments) []TrafficTestCase {
+	var cases []TrafficTestCase
+	callCount := callsPerCluster * len(apps.PodB)
+	for _, podA := range apps.PodA {
+		podA := podA
+		cases = append(cases,
+			TrafficTestCase{
+				name: fmt.Sprintf("proxy generated cookie from %s", podA.Config().Cluster.Name()),
+				// The proxies generate the cookie for requests that do not carry it. With a zero TTL, it is a session
+				// cookie, which has no expiry.
+				config: fmt.Sprintf(`apiVersion: networking.istio.io/v1beta1
+kind: DestinationRule
+metadata:
+  name: b
+spec:
+  host: b
+  trafficPolicy:
+    loadBalancer:
+      consistentHash:
+        httpCookie:
+          name: %s
+          ttl: 0s
+`, sessionCookie),
+				call: func() (echoclient.ParsedResponses, error) {
+					return podA.Call(echo.CallOptions{
+						Target:   apps.PodB[0],
+						PortName: "http",
+						Count:    callCount,
+						Session:  &echo.SessionOptions{Cookie: sessionCookie},
+					})
+				},
+				validator: func(responses echoclient.ParsedResponses) error {
+					setCookie := responses[0].RawResponse["Set-Cookie"]
+					if !strings.HasPrefix(setCookie, sessionCookie+"=") {
+						return fmt.Errorf("the proxies did not generate the session cookie, got Set-Cookie %q", setCookie)
+					}
+					if lower := strings.ToLower(setCookie); strings.Contains(lower, "max-age") || strings.Contains(lower, "expires") {
+						return fmt.Errorf("the proxies generated a persistent cookie, want a session cookie: %q", setCookie)
+					}
+					return responses.CheckSticky()
+				},
+			},
+			TrafficTestCase{
+				name: fmt.Sprintf("server issued header from %s", podA.Config().Cluster.Name()),
+				// The proxies never generate a header, unlike a cookie, so the session must be the one issued by
+				// the server.
+				config: fmt.Sprintf(`apiVersion: networking.istio.io/v1beta1
+kind: DestinationRule
+metadata:
+  name: b
+spec:
+  host: b
+  trafficPolicy:
+    loadBalancer:
+      consistentHash:
+        httpHeaderName: %s
+`, sessionHeader),
+				call: func() (echoclient.ParsedResponses, error) {
+					return podA.Call(echo.CallOptions{
+						Target:   apps.PodB[0],
+						PortName: "http",
+						Count:    callCount,
+						Session:  &echo.SessionOptions{Header: sessionHeader, Issue: true},
+					})
+				},
+				validator: func(responses echoclient.ParsedResponses) error {
+					// The first request starts the session, so only the requests after it carry the header.
+					for i, r := range responses[1:] {
+						if r.RawResponse[http.CanonicalHeaderKey(sessionHeader)] == "" {
+							return fmt.Errorf("request %d did not carry the session issued by the server", i+1)
+						}
+					}
+					return responses[1:].CheckSticky()
+				},
+			})
+	}
+	return cases
+}
@@ -102,6 +102,7 @@ func RunTrafficTest(ctx framework.TestContext, apps *EchoDeployments) {
 	cases["serverfirst"] = serverFirstTestCases(apps)
 	cases["gateway"] = gatewayCases(apps)
 	cases["vm"] = VMTestCases(apps.VM, apps)
+	cases["session"] = stickySessionCases(apps)
 	for n, tts := range cases {
 		ctx.NewSubTest(n).Run(func(ctx framework.TestContext) {
 			for _, tt := range tts {
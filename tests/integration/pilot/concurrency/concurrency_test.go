@@ -0,0 +1,227 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concurrency
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/check"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/proxyconcurrency"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const (
+	// connections opened to a proxy to observe their distribution across its workers.
+	connections = 40
+	// throughputDuration over which the throughput of the gateway is measured.
+	throughputDuration = 10 * time.Second
+)
+
+var (
+	inst    istio.Instance
+	ns      namespace.Instance
+	client  echo.Instance
+	servers = map[int]echo.Instance{1: nil, 4: nil}
+)
+
+func TestMain(m *testing.M) {
+	framework.
+		NewSuite(m).
+		RequireSingleCluster().
+		Setup(istio.Setup(&inst, func(_ resource.Context, cfg *istio.Config) {
+			cfg.ControlPlaneValues = proxyconcurrency.GatewayValues
+		})).
+		Setup(setupApps).
+		Run()
+}
+
+func setupApps(ctx resource.Context) error {
+	var err error
+	if ns, err = namespace.New(ctx, namespace.Config{Prefix: "concurrency", Inject: true}); err != nil {
+		return err
+	}
+	builder := echoboot.NewBuilder(ctx).With(&client, echoConfig("client"))
+	instances := map[int]*echo.Instance{}
+	for n := range servers {
+		var i echo.Instance
+		instances[n] = &i
+		builder = builder.With(instances[n], proxyconcurrency.WithConcurrency(echoConfig(fmt.Sprintf("server-%d", n)), n))
+	}
+	if _, err := builder.Build(); err != nil {
+		return err
+	}
+	for n, i := range instances {
+		servers[n] = *i
+	}
+	return nil
+}
+
+func echoConfig(name string) echo.Config {
+	return echo.Config{
+		Service:   name,
+		Namespace: ns,
+		Subsets:   []echo.SubsetConfig{{}},
+		Ports: []echo.Port{
+			{
+				Name:         "http",
+				Protocol:     protocol.HTTP,
+				InstancePort: 8090,
+			},
+			{
+				Name:         "tcp",
+				Protocol:     protocol.TCP,
+				InstancePort: 9090,
+			},
+		},
+	}
+}
+
+func TestSidecarConcurrency(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.proxy.concurrency").
+		Run(func(ctx framework.TestContext) {
+			for n, server := range servers {
+				n, server := n, server
+				ctx.NewSubTest(fmt.Sprintf("concurrency-%d", n)).Run(func(ctx framework.TestContext) {
+					if err := proxyconcurrency.CheckSidecarConcurrency(server, n); err != nil {
+						ctx.Fatal(err)
+					}
+					w := server.WorkloadsOrFail(ctx)[0]
+					before, err := proxyconcurrency.SidecarWorkers(w)
+					if err != nil {
+						ctx.Fatal(err)
+					}
+					// Every TCP request is a new connection, which the client sidecar forwards on a new connection.
+					check.CallOrFail(ctx, client, echo.CallOptions{
+						Target:   server,
+						PortName: "tcp",
+						Scheme:   scheme.TCP,
+						Count:    connections,
+					}, check.OK())
+					retry.UntilSuccessOrFail(ctx, func() error {
+						after, err := proxyconcurrency.SidecarWorkers(w)
+						if err != nil {
+							return err
+						}
+						accepted := after.Sub(before)
+						if err := accepted.Check(n); err != nil {
+							return err
+						}
+						return accepted.CheckDistribution(minActive(n))
+					}, retry.Timeout(30*time.Second))
+				})
+			}
+		})
+}
+
+func TestGatewayConcurrency(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.proxy.concurrency").
+		Run(func(ctx framework.TestContext) {
+			server := servers[4]
+			ctx.Config().ApplyYAMLOrFail(ctx, ns.Name(), fmt.Sprintf(`
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: concurrency
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - "*"
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: concurrency
+spec:
+  hosts:
+  - "*"
+  gateways:
+  - concurrency
+  http:
+  - route:
+    - destination:
+        host: %s
+        port:
+          number: 8090
+`, server.Config().FQDN()))
+			gw := inst.IngressFor(ctx.Clusters().Default())
+			opts := echo.CallOptions{
+				Port: &echo.Port{Protocol: protocol.HTTP},
+				// Close each connection, so that the gateway accepts a new one for every request.
+				Headers: http.Header{"Connection": []string{"close"}},
+				Count:   connections,
+			}
+			load := proxyconcurrency.GatewayLoad(gw, opts)
+
+			throughput := map[int]float64{}
+			for _, n := range []int{1, 4} {
+				gw.SetConcurrencyOrFail(ctx, n)
+				retry.UntilSuccessOrFail(ctx, func() error {
+					_, err := load()
+					return err
+				}, retry.Timeout(time.Minute))
+
+				before, err := proxyconcurrency.GatewayWorkers(gw)
+				if err != nil {
+					ctx.Fatal(err)
+				}
+				if throughput[n], err = proxyconcurrency.Throughput(load, throughputDuration); err != nil {
+					ctx.Fatal(err)
+				}
+				after, err := proxyconcurrency.GatewayWorkers(gw)
+				if err != nil {
+					ctx.Fatal(err)
+				}
+				accepted := after.Sub(before)
+				ctx.Logf("gateway with concurrency %d: %.1f requests per second, %v", n, throughput[n], accepted)
+				if err := accepted.Check(n); err != nil {
+					ctx.Fatal(err)
+				}
+				if err := accepted.CheckDistribution(minActive(n)); err != nil {
+					ctx.Fatal(err)
+				}
+			}
+			if err := proxyconcurrency.CheckThroughput(throughput[1], throughput[4], 0.8); err != nil {
+				ctx.Fatal(err)
+			}
+		})
+}
+
+// minActive is the number of workers expected to accept some of the connections to a proxy running n workers.
+func minActive(n int) int {
+	if n > 1 {
+		return 2
+	}
+	return 1
+}
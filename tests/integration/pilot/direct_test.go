@@ -0,0 +1,65 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// TestDirectPodCalls calls the pods of a ClusterIP and a headless service directly, by pod IP and by per-pod DNS
+// name, bypassing the service VIP. The mesh allows any outbound traffic, so every call is expected to succeed.
+func TestDirectPodCalls(t *testing.T) {
+	framework.NewTest(t).
+		Run(func(ctx framework.TestContext) {
+			for _, src := range apps.PodA {
+				for _, dst := range []echo.Instance{
+					apps.PodB.GetOrFail(ctx, echo.InCluster(src.Config().Cluster)),
+					apps.Headless.GetOrFail(ctx, echo.InCluster(src.Config().Cluster)),
+				} {
+					cases, err := echo.DirectCallCases(src, dst, "http", false)
+					if err != nil {
+						ctx.Fatal(err)
+					}
+					for _, c := range cases {
+						c := c
+						src := src
+						name := fmt.Sprintf("%s/%s", src.Config().Cluster.Name(), c.Name)
+						ctx.NewSubTest(name).Run(func(ctx framework.TestContext) {
+							retry.UntilSuccessOrFail(ctx, func() error {
+								resp, err := src.Call(c.Options)
+								if !c.ExpectSuccess {
+									if err == nil && resp.CheckOK() == nil {
+										return fmt.Errorf("expected the call to fail")
+									}
+									return nil
+								}
+								if err != nil {
+									return err
+								}
+								return resp.CheckOK()
+							}, retry.Delay(time.Millisecond*100), retry.Timeout(time.Second*30))
+						})
+					}
+				}
+			}
+		})
+}
@@ -0,0 +1,63 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gatewayconformance
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/crd"
+	"istio.io/istio/pkg/test/framework/components/gatewayconformance"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+// TestMain installs Istio with the Gateway API enabled, for the upstream conformance tests to run against.
+func TestMain(m *testing.M) {
+	framework.
+		NewSuite(m).
+		RequireSingleCluster().
+		Setup(crd.Setup(crd.Config{Files: []string{"../testdata/service-apis-crd.yaml"}, Replace: true})).
+		Setup(istio.Setup(nil, func(_ resource.Context, cfg *istio.Config) {
+			cfg.ControlPlaneValues = `
+values:
+  pilot:
+    env:
+      PILOT_ENABLED_SERVICE_APIS: "true"`
+		})).
+		Run()
+}
+
+// TestConformance runs the Gateway API conformance tests from the checkout given by
+// --istio.test.gatewayConformance.dir, with the profiles and features given by the other
+// istio.test.gatewayConformance flags.
+func TestConformance(t *testing.T) {
+	framework.NewTest(t).
+		Run(func(ctx framework.TestContext) {
+			if !gatewayconformance.Enabled() {
+				ctx.Skip("no Gateway API checkout provided")
+			}
+			supported, reason, err := gatewayconformance.Supported(ctx.Clusters().Default())
+			if err != nil {
+				ctx.Fatal(err)
+			}
+			if !supported {
+				ctx.Skip(reason)
+			}
+			report := gatewayconformance.RunOrFail(ctx, ctx, gatewayconformance.Config{})
+			ctx.Logf("conformance report: %s", report)
+		})
+}
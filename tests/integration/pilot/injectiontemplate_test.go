@@ -0,0 +1,95 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	kubeApiCore "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/injectiontemplate"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// ndotsTemplate extends the default injection template with a DNS config.
+const ndotsTemplate = `{{ template "sidecar" . }}
+dnsConfig:
+  options:
+  - name: ndots
+    value: "3"
+`
+
+// hasNdots checks that the pod is configured with the ndots DNS option of ndotsTemplate.
+func hasNdots(expected bool) injectiontemplate.PodCheck {
+	return func(pod kubeApiCore.Pod) error {
+		found := false
+		if pod.Spec.DNSConfig != nil {
+			for _, o := range pod.Spec.DNSConfig.Options {
+				found = found || (o.Name == "ndots" && o.Value != nil && *o.Value == "3")
+			}
+		}
+		if found != expected {
+			return fmt.Errorf("expected ndots DNS option=%v, got DNS config %+v", expected, pod.Spec.DNSConfig)
+		}
+		return nil
+	}
+}
+
+// TestInjectionTemplate verifies that pods selecting a custom injection template are injected with it, while the
+// others are still injected with the default template.
+func TestInjectionTemplate(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.injection.template").
+		RequiresSingleCluster().
+		Run(func(ctx framework.TestContext) {
+			injectiontemplate.OverrideOrFail(ctx, ctx, injectiontemplate.Config{
+				SystemNamespace: i.Settings().SystemNamespace,
+				Templates:       map[string]string{"ndots": ndotsTemplate},
+			})
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "injection-template", Inject: true})
+			custom := echoConfig(ns, "custom")
+			custom.Subsets = []echo.SubsetConfig{{Annotations: echo.NewAnnotations().Set(echo.InjectTemplates, "ndots")}}
+			var a, b echo.Instance
+			echoboot.NewBuilder(ctx).
+				With(&a, echoConfig(ns, "default")).
+				With(&b, custom).
+				BuildOrFail(ctx)
+
+			injectiontemplate.CheckPodsOrFail(ctx, a, []injectiontemplate.PodCheck{
+				injectiontemplate.HasContainer("istio-proxy"),
+				hasNdots(false),
+			})
+			injectiontemplate.CheckPodsOrFail(ctx, b, []injectiontemplate.PodCheck{
+				injectiontemplate.HasContainer("istio-proxy"),
+				injectiontemplate.HasAnnotation(echo.InjectTemplates.Name, "ndots"),
+				hasNdots(true),
+			})
+			// The sidecar rendered by the custom template serves traffic like the default one.
+			retry.UntilSuccessOrFail(ctx, func() error {
+				resp, err := a.Call(echo.CallOptions{Target: b, PortName: "http"})
+				if err != nil {
+					return err
+				}
+				return resp.CheckOK()
+			}, retry.Delay(time.Millisecond*100), retry.Timeout(time.Second*30))
+		})
+}
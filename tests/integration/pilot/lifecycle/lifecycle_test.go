@@ -0,0 +1,140 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lifecycle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/check"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/pilotagent"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// drainDuration of the server, after which its drained listeners stop accepting connections.
+const drainDuration = 2 * time.Second
+
+var (
+	inst   istio.Instance
+	client echo.Instance
+	server echo.Instance
+)
+
+func TestMain(m *testing.M) {
+	framework.
+		NewSuite(m).
+		RequireSingleCluster().
+		Setup(istio.Setup(&inst, nil)).
+		Setup(setupApps).
+		Run()
+}
+
+func setupApps(ctx resource.Context) error {
+	ns, err := namespace.New(ctx, namespace.Config{Prefix: "lifecycle", Inject: true})
+	if err != nil {
+		return err
+	}
+	ports := []echo.Port{{
+		Name:         "http",
+		Protocol:     protocol.HTTP,
+		InstancePort: 8090,
+	}}
+	_, err = echoboot.NewBuilder(ctx).
+		With(&client, echo.Config{
+			Service:   "client",
+			Namespace: ns,
+			Subsets:   []echo.SubsetConfig{{}},
+			Ports:     ports,
+		}).
+		With(&server, echo.Config{
+			Service:   "server",
+			Namespace: ns,
+			Subsets: []echo.SubsetConfig{{
+				Annotations: echo.NewAnnotations().Set(echo.SidecarProxyConfig, "drainDuration: "+drainDuration.String()),
+			}},
+			Ports: ports,
+		}).
+		Build()
+	return err
+}
+
+func TestReady(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.proxy.lifecycle").
+		Run(func(ctx framework.TestContext) {
+			ctx.NewSubTest("sidecar").Run(func(ctx framework.TestContext) {
+				for _, agent := range pilotagent.ForInstanceOrFail(ctx, server) {
+					agent.WaitReadyOrFail(ctx)
+					stats := agent.StatsOrFail(ctx)
+					// The stats of the proxy are merged with those of pilot-agent.
+					if _, ok := stats["envoy_server_live"]; !ok {
+						ctx.Fatalf("stats of %s do not include those of the proxy", agent)
+					}
+				}
+			})
+			ctx.NewSubTest("gateway").Run(func(ctx framework.TestContext) {
+				cfg, err := istio.DefaultConfig(ctx)
+				if err != nil {
+					ctx.Fatal(err)
+				}
+				agents, err := pilotagent.ForPods(ctx.Clusters().Default(), cfg.IngressNamespace, "istio=ingressgateway")
+				if err != nil {
+					ctx.Fatal(err)
+				}
+				for _, agent := range agents {
+					agent.WaitReadyOrFail(ctx)
+				}
+			})
+		})
+}
+
+func TestDrainAndQuit(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.proxy.lifecycle").
+		Run(func(ctx framework.TestContext) {
+			opts := echo.CallOptions{
+				Target:   server,
+				PortName: "http",
+				Scheme:   scheme.HTTP,
+				Count:    1,
+			}
+			check.CallOrFail(ctx, client, opts, check.OK())
+
+			agent := pilotagent.ForInstanceOrFail(ctx, server)[0]
+			agent.DrainOrFail(ctx, true)
+			// Once drained, the inbound listeners of the server no longer accept connections.
+			retry.UntilSuccessOrFail(ctx, func() error {
+				if check.OK().Check(client.Call(opts)) == nil {
+					return errors.New("server still accepts requests")
+				}
+				return nil
+			}, retry.Timeout(drainDuration+30*time.Second))
+
+			// Quitting restarts the proxy, with its listeners restored.
+			agent.QuitOrFail(ctx)
+			agent.WaitReadyOrFail(ctx, retry.Timeout(2*time.Minute))
+			check.CallOrFail(ctx, client, opts, check.OK(), retry.Timeout(time.Minute))
+		})
+}
@@ -0,0 +1,118 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/kube"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const localitySpillTemplate = `
+apiVersion: networking.istio.io/v1alpha3
+kind: ServiceEntry
+metadata:
+  name: spill
+spec:
+  hosts:
+  - {{.Host}}
+  location: MESH_INTERNAL
+  ports:
+  - name: http
+    number: 80
+    protocol: HTTP
+  resolution: DNS
+  endpoints:
+  - address: {{.Local}}
+    locality: region/zone/subzone
+  - address: {{.Failover}}
+    locality: region/otherzone/subzone
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: spill
+spec:
+  host: {{.Host}}
+  trafficPolicy:
+    connectionPool:
+      tcp:
+        connectTimeout: 250ms
+    outlierDetection:
+      consecutive5xxErrors: 1
+      interval: 1s
+      baseEjectionTime: 10s
+      maxEjectionPercent: 100`
+
+// TestLocalitySpill verifies that traffic stays in the zone of the client, spills over to another zone while the
+// endpoints of its own zone are down, and returns once they are back.
+func TestLocalitySpill(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.locality").
+		RequiresSingleCluster().
+		Run(func(ctx framework.TestContext) {
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "locality-spill", Inject: true})
+			withLocality := func(name, locality string) echo.Config {
+				cfg := echoConfig(ns, name)
+				cfg.Locality = locality
+				cfg.Ports[0].ServicePort = 80
+				return cfg
+			}
+			var client, local, failover echo.Instance
+			echoboot.NewBuilder(ctx).
+				With(&client, withLocality("client", "region.zone.subzone")).
+				With(&local, withLocality("local", "region.zone.subzone")).
+				With(&failover, withLocality("failover", "region.otherzone.subzone")).
+				BuildOrFail(ctx)
+
+			host := "spill.example.com"
+			applyAndCleanup(ctx, ns.Name(), runTemplate(ctx, localitySpillTemplate, map[string]string{
+				"Host":     host,
+				"Local":    local.Config().FQDN(),
+				"Failover": failover.Config().FQDN(),
+			}))
+
+			cfg := local.Config()
+			scale := func(replicas int32) func() error {
+				return func() error {
+					return kube.ScaleDeployment(cfg.Cluster, cfg.Namespace.Name(), cfg.Service+"-v1", replicas)
+				}
+			}
+			err := echo.CheckLocalitySpill(client, echo.LocalitySpillOptions{
+				// As in TestLocality, the Host header routes the call of the client to itself to the ServiceEntry.
+				Call: echo.CallOptions{
+					Target:   client,
+					PortName: "http",
+					Headers:  http.Header{"Host": []string{host}},
+					Count:    10,
+				},
+				Local:    local.Config().Locality,
+				Failover: failover.Config().Locality,
+				Degrade:  scale(0),
+				Restore:  scale(1),
+			}, retry.Delay(time.Second), retry.Timeout(time.Minute))
+			if err != nil {
+				ctx.Fatalf("traffic from %s did not spill over as expected: %v", client.Config().Locality, err)
+			}
+		})
+}
@@ -0,0 +1,130 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/client"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+const malformedHost = "malformed.example.com"
+
+// TestMalformedTraffic verifies that the ingress gateway rejects malformed requests, and reports them as downstream
+// protocol errors in its access logs.
+func TestMalformedTraffic(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.ingress.malformed").
+		RequiresSingleCluster().
+		Run(func(ctx framework.TestContext) {
+			dst := apps.PodA[0]
+			applyAndCleanup(ctx, apps.Namespace.Name(), fmt.Sprintf(`
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: malformed
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port:
+      number: 80
+      name: http
+      protocol: HTTP
+    hosts:
+    - %[1]s
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: malformed
+spec:
+  hosts:
+  - %[1]s
+  gateways:
+  - malformed
+  http:
+  - route:
+    - destination:
+        host: %[2]s
+        port:
+          number: %[3]d
+`, malformedHost, dst.Config().FQDN(), dst.Config().PortByName("http").ServicePort))
+			retry.UntilSuccessOrFail(ctx, func() error {
+				resp, err := apps.Ingress.CallEcho(echo.CallOptions{Port: &echo.Port{Protocol: protocol.HTTP}, Host: malformedHost})
+				if err != nil {
+					return err
+				}
+				return resp.CheckOK()
+			}, retry.Delay(time.Second), retry.Timeout(time.Minute))
+
+			address := apps.Ingress.HTTPAddress()
+			for _, tc := range []struct {
+				kind client.MalformedKind
+				// status of the response, or 0 if the connection is expected to be rejected without one.
+				status int
+			}{
+				{client.BadContentLength, http.StatusBadRequest},
+				{client.ConflictingContentLength, http.StatusBadRequest},
+				{client.OversizedHeaders, http.StatusRequestHeaderFieldsTooLarge},
+				{client.BadHTTP2Frame, 0},
+			} {
+				tc := tc
+				ctx.NewSubTest(string(tc.kind)).Run(func(ctx framework.TestContext) {
+					result, err := client.SendMalformed(client.MalformedOptions{
+						Kind:    tc.kind,
+						Address: address.String(),
+						Host:    malformedHost,
+					})
+					if err != nil {
+						ctx.Fatal(err)
+					}
+					check := result.CheckRejected
+					if tc.status != 0 {
+						check = func() error { return result.CheckStatus(tc.status) }
+					}
+					if err := check(); err != nil {
+						ctx.Fatal(err)
+					}
+				})
+			}
+
+			pod, err := apps.Ingress.PodID(0)
+			if err != nil {
+				ctx.Fatal(err)
+			}
+			retry.UntilSuccessOrFail(ctx, func() error {
+				logs, err := ctx.Clusters().Default().PodLogs(context.TODO(), pod, i.Settings().IngressNamespace,
+					"istio-proxy", false)
+				if err != nil {
+					return err
+				}
+				// Both requests with an invalid Content-Length are downstream protocol errors.
+				if n := client.ResponseFlags(logs)["DPE"]; n < 2 {
+					return fmt.Errorf("expected at least 2 requests logged with DPE, got %d", n)
+				}
+				return nil
+			}, retry.Delay(time.Second), retry.Timeout(30*time.Second))
+		})
+}
@@ -0,0 +1,89 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/networkpolicy"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+// TestNetworkPolicy verifies that common NetworkPolicy patterns have the same outcome for workloads with and without
+// sidecars.
+func TestNetworkPolicy(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.networkpolicy").
+		RequiresSingleCluster().
+		Run(func(ctx framework.TestContext) {
+			clientNs := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "netpol-client", Inject: true})
+			serverNs := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "netpol-server", Inject: true})
+			clients := networkpolicy.DeployModesOrFail(ctx, ctx, echoConfig(clientNs, "client"))
+			servers := networkpolicy.DeployModesOrFail(ctx, ctx, echoConfig(serverNs, "server"))
+			client, server := clients[networkpolicy.NoSidecar], servers[networkpolicy.NoSidecar]
+
+			// Not every network plugin enforces NetworkPolicies, in which case the denied cases cannot be verified.
+			denied := networkpolicy.Case{
+				Name:     "enforced",
+				Policies: networkpolicy.DefaultDeny(serverNs.Name()),
+				Source:   client,
+				Target:   server,
+			}
+			if err := networkpolicy.Verify(ctx, []networkpolicy.Case{denied}, retry.Timeout(30*time.Second)); err != nil {
+				ctx.Skipf("NetworkPolicies are not enforced by the network plugin of the cluster: %v", err)
+			}
+
+			// The sidecars of the servers must still reach istiod, and the clients must resolve the servers.
+			isolated := func(policies ...networkpolicy.Policy) []networkpolicy.Policy {
+				return append(append(networkpolicy.DefaultDeny(serverNs.Name()),
+					networkpolicy.AllowIstioSystem(serverNs.Name(), i.Settings().SystemNamespace)), policies...)
+			}
+			var cases []networkpolicy.Case
+			for _, tc := range []struct {
+				name     string
+				policies func(src, dst echo.Instance) []networkpolicy.Policy
+			}{
+				{"default-deny", func(src, dst echo.Instance) []networkpolicy.Policy {
+					return isolated()
+				}},
+				{"allow-from-namespace", func(src, dst echo.Instance) []networkpolicy.Policy {
+					return isolated(networkpolicy.AllowFromNamespaces(serverNs.Name(), dst.Config().Service, clientNs.Name()))
+				}},
+				{"allow-port", func(src, dst echo.Instance) []networkpolicy.Policy {
+					return isolated(networkpolicy.AllowPorts(serverNs.Name(), dst.Config().Service,
+						dst.Config().PortByName("http").InstancePort))
+				}},
+				{"allow-other-port", func(src, dst echo.Instance) []networkpolicy.Policy {
+					return isolated(networkpolicy.AllowPorts(serverNs.Name(), dst.Config().Service, 9999))
+				}},
+				{"egress-to-namespace", func(src, dst echo.Instance) []networkpolicy.Policy {
+					return []networkpolicy.Policy{
+						networkpolicy.DefaultDeny(clientNs.Name())[1],
+						networkpolicy.AllowIstioSystem(clientNs.Name(), i.Settings().SystemNamespace),
+						networkpolicy.AllowDNS(clientNs.Name()),
+						networkpolicy.AllowToNamespaces(clientNs.Name(), src.Config().Service, serverNs.Name()),
+					}
+				}},
+			} {
+				cases = append(cases, networkpolicy.ModeCases(tc.name, clients, servers, tc.policies)...)
+			}
+			networkpolicy.VerifyOrFail(ctx, ctx, cases)
+		})
+}
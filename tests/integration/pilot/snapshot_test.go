@@ -0,0 +1,44 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"os"
+	"testing"
+
+	"istio.io/istio/pilot/test/util"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/snapshot"
+)
+
+// snapshotGoldens holds the config of the canonical workloads, recorded against a live cluster with
+// REFRESH_GOLDEN=true.
+const snapshotGoldens = "testdata/snapshot"
+
+// TestConfigSnapshot compares the Envoy config generated for the canonical workloads against the golden files.
+func TestConfigSnapshot(t *testing.T) {
+	framework.NewTest(t).
+		RequiresSingleCluster().
+		Run(func(ctx framework.TestContext) {
+			if _, err := os.Stat(snapshotGoldens); os.IsNotExist(err) && !util.Refresh() {
+				ctx.Skipf("no golden files in %s, run with REFRESH_GOLDEN=true to record them", snapshotGoldens)
+			}
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "snapshot", Inject: true})
+			instances := snapshot.DeployOrFail(ctx, ctx, ns)
+			snapshot.CheckOrFail(ctx, snapshotGoldens, instances...)
+		})
+}
@@ -0,0 +1,92 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pilot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/kube"
+)
+
+// TestNewEndpointShare verifies that, without slow start, an endpoint added to a loaded service receives its full
+// round robin share as soon as it is ready, and that failed requests are retried on another endpoint.
+func TestNewEndpointShare(t *testing.T) {
+	framework.NewTest(t).
+		Features("traffic.loadbalancing").
+		RequiresSingleCluster().
+		Run(func(ctx framework.TestContext) {
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "warmup", Inject: true})
+			var client, server echo.Instance
+			echoboot.NewBuilder(ctx).
+				With(&client, echoConfig(ns, "client")).
+				With(&server, echoConfig(ns, "server")).
+				BuildOrFail(ctx)
+			ctx.Config().ApplyYAMLOrFail(ctx, ns.Name(), fmt.Sprintf(`
+apiVersion: networking.istio.io/v1alpha3
+kind: DestinationRule
+metadata:
+  name: server
+spec:
+  host: %[1]s
+  trafficPolicy:
+    loadBalancer:
+      simple: ROUND_ROBIN
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: server
+spec:
+  hosts:
+  - %[1]s
+  http:
+  - route:
+    - destination:
+        host: %[1]s
+    retries:
+      attempts: 3
+      retryOn: connect-failure,refused-stream,unavailable,5xx
+`, server.Config().FQDN()))
+
+			cfg := server.Config()
+			samples, err := echo.SampleWarmup(client, echo.WarmupOptions{
+				Call: echo.CallOptions{Target: server, PortName: "http"},
+				AddEndpoint: func() error {
+					return kube.ScaleDeployment(cfg.Cluster, cfg.Namespace.Name(), cfg.Service+"-v1", 2)
+				},
+				Samples:  5,
+				Interval: 2 * time.Second,
+			})
+			if err != nil {
+				ctx.Fatal(err)
+			}
+			if err := samples.CheckRetriesToOtherEndpoints(); err != nil {
+				ctx.Fatal(err)
+			}
+			for _, s := range samples {
+				if s.Endpoints != 2 || s.Share < 0.3 {
+					ctx.Fatalf("new endpoint received share %.2f of %d endpoints after %v, expected its full share "+
+						"without slow start: %v", s.Share, s.Endpoints, s.Elapsed, samples)
+				}
+			}
+		})
+}
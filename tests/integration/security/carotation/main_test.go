@@ -0,0 +1,37 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package carotation rotates the root of the plugged-in CA while workloads keep communicating.
+package carotation
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/label"
+)
+
+var inst istio.Instance
+
+func TestMain(m *testing.M) {
+	framework.
+		NewSuite(m).
+		// The CA is rotated by replacing the cacerts secret and restarting istiod, which other tests must not see.
+		Label(label.CustomSetup).
+		RequireSingleCluster().
+		Setup(istio.Setup(&inst, nil)).
+		Run()
+}
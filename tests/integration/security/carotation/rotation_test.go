@@ -0,0 +1,56 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package carotation
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/echo/common/scheme"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/carotation"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/tests/integration/security/util"
+)
+
+// TestRootRotation rotates the root of the CA through each phase of the runbook, while a calls b over mTLS. Every
+// call must get an OK response, through the restarts of istiod and of the workloads in each phase.
+func TestRootRotation(t *testing.T) {
+	framework.NewTest(t).
+		Features("security.control-plane.plugin-cert.rotation").
+		Run(func(ctx framework.TestContext) {
+			cfg := istio.DefaultConfigOrFail(ctx, ctx)
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "ca-rotation", Inject: true})
+			var a, b echo.Instance
+			echoboot.NewBuilder(ctx).
+				With(&a, util.EchoConfig("a", ns, false, nil)).
+				With(&b, util.EchoConfig("b", ns, false, nil)).
+				BuildOrFail(ctx)
+
+			h := carotation.NewOrFail(ctx, ctx, carotation.Config{
+				SystemNamespace: cfg.SystemNamespace,
+				Workloads:       []echo.Instance{a, b},
+				Traffic: carotation.CallOK(a, echo.CallOptions{
+					Target:   b,
+					PortName: "http",
+					Scheme:   scheme.HTTP,
+				}),
+			})
+			h.RunOrFail(ctx)
+		})
+}
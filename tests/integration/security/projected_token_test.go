@@ -0,0 +1,76 @@
+// +build integ
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package security
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	kubeEnv "istio.io/istio/pkg/test/framework/components/environment/kube"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/kube"
+)
+
+// TestProjectedToken verifies the claims of the projected token the Istio agent authenticates to istiod with and, on
+// EKS clusters with a role for service accounts, of the token that the agent exchanges for AWS credentials.
+func TestProjectedToken(t *testing.T) {
+	framework.NewTest(t).
+		Run(func(ctx framework.TestContext) {
+			ns := namespace.NewOrFail(ctx, ctx, namespace.Config{Prefix: "projected-token", Inject: true})
+			var app echo.Instance
+			echoboot.NewBuilder(ctx).
+				With(&app, echo.Config{
+					Service:        "token",
+					Namespace:      ns,
+					ServiceAccount: true,
+					Ports: []echo.Port{{
+						Name:         "http",
+						Protocol:     protocol.HTTP,
+						InstancePort: 8090,
+					}},
+				}).
+				BuildOrFail(ctx)
+
+			c := app.Config().Cluster
+			pods, err := c.PodsForSelector(context.TODO(), ns.Name(), "app=token")
+			if err != nil {
+				ctx.Fatal(err)
+			}
+			eksRole := ""
+			if env, err := kubeEnv.EnvironmentFrom(ctx); err == nil {
+				eksRole = env.Settings().EKSRoleARN
+			}
+			for _, p := range pods.Items {
+				// The injector projects a token for istiod that expires after 12h.
+				claims := kube.ReadProjectedTokenOrFail(ctx, c, p.Namespace, p.Name, "istio-proxy", kube.IstioTokenPath)
+				if err := claims.Check("istio-ca", 12*time.Hour); err != nil {
+					ctx.Fatalf("pod %s: %v", p.Name, err)
+				}
+				if eksRole == "" {
+					continue
+				}
+				claims = kube.ReadProjectedTokenOrFail(ctx, c, p.Namespace, p.Name, "app", kube.EKSTokenPath)
+				if err := claims.Check(kube.EKSTokenAudience, 0); err != nil {
+					ctx.Fatalf("pod %s: %v", p.Name, err)
+				}
+			}
+		})
+}
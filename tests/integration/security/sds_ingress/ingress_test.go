@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/gatewaytls"
 	"istio.io/istio/pkg/test/framework/components/istio"
 	"istio.io/istio/pkg/test/framework/components/istio/ingress"
 	ingressutil "istio.io/istio/tests/integration/security/sds_ingress/util"
@@ -539,3 +540,29 @@ func TestMultiMtlsGateway_InvalidSecret(t *testing.T) {
 			}
 		})
 }
+
+// TestGatewayTLSMatrix verifies the SIMPLE and MUTUAL gateway servers against each combination of SNI and client
+// certificate. Certificates signed by CA B are untrusted by the gateway, whose credential is signed by CA A.
+func TestGatewayTLSMatrix(t *testing.T) {
+	framework.
+		NewTest(t).
+		Run(func(ctx framework.TestContext) {
+			ns := ingressutil.SetupTest(ctx)
+			gatewaytls.RunOrFail(ctx, ctx, gatewaytls.Config{
+				Ingress:          inst.IngressFor(ctx.Clusters().Default()),
+				IngressNamespace: inst.Settings().IngressNamespace,
+				Namespace:        ns.Name(),
+				Backend:          "server",
+				Credentials: gatewaytls.Credentials{
+					CaCert:              ingressutil.CaCertA,
+					ServerCert:          ingressutil.TLSServerCertA,
+					ServerKey:           ingressutil.TLSServerKeyA,
+					ClientCert:          ingressutil.TLSClientCertA,
+					ClientKey:           ingressutil.TLSClientKeyA,
+					UntrustedClientCert: ingressutil.TLSClientCertB,
+					UntrustedClientKey:  ingressutil.TLSClientKeyB,
+				},
+				Cases: gatewaytls.Matrix(gatewaytls.Simple, gatewaytls.Mutual),
+			})
+		})
+}
@@ -0,0 +1,43 @@
+// +build integ
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smoke
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/smoke"
+)
+
+var (
+	i istio.Instance
+	s smoke.Instance
+)
+
+// TestMain installs Istio, unless run against an existing mesh with --istio.test.kube.deploy=false, and deploys the
+// workloads of the smoke checks.
+func TestMain(m *testing.M) {
+	framework.
+		NewSuite(m).
+		Setup(istio.Setup(&i, nil)).
+		Setup(smoke.Setup(&s, &i)).
+		Run()
+}
+
+func TestSmoke(t *testing.T) {
+	framework.NewTest(t).Run(s.Run)
+}
@@ -0,0 +1,105 @@
+// +build integ
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+// Package customizemetrics is a suite verifying the series of the stats filter when its metrics are customized, run
+// by the packages under it with their own overrides, as overrides are installed with Istio.
+package customizemetrics
+
+import (
+	"testing"
+	"time"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/prometheus"
+	"istio.io/istio/pkg/test/framework/components/statsoverride"
+	"istio.io/istio/pkg/test/framework/label"
+	"istio.io/istio/pkg/test/framework/resource"
+	"istio.io/istio/pkg/test/util/retry"
+)
+
+var (
+	client, server echo.Instance
+	ist            istio.Instance
+	appNsInst      namespace.Instance
+	promInst       prometheus.Instance
+)
+
+// Run the suite of the package installing Istio with the overrides, from its TestMain.
+func Run(m *testing.M, overrides statsoverride.Overrides) {
+	framework.NewSuite(m).
+		RequireSingleCluster().
+		Label(label.CustomSetup).
+		Setup(istio.Setup(&ist, statsoverride.SetupConfig(overrides))).
+		Setup(setup).
+		Run()
+}
+
+// TestCustomizeMetrics sends traffic from the client to the server, and verifies the shape of the series reported by
+// both sidecars against the overrides the suite was installed with.
+func TestCustomizeMetrics(t *testing.T, overrides statsoverride.Overrides) {
+	framework.NewTest(t).
+		Features("observability.telemetry.stats.prometheus.customize-metric").
+		Run(func(ctx framework.TestContext) {
+			start := time.Now()
+			retry.UntilSuccessOrFail(ctx, func() error {
+				if _, err := client.Call(echo.CallOptions{
+					Target:   server,
+					PortName: "http",
+				}); err != nil {
+					return err
+				}
+				return statsoverride.Verify(promInst, overrides, appNsInst.Name(), start)
+			}, retry.Delay(3*time.Second), retry.Timeout(2*time.Minute))
+		})
+}
+
+func setup(ctx resource.Context) (err error) {
+	appNsInst, err = namespace.New(ctx, namespace.Config{
+		Prefix: "customize-metrics",
+		Inject: true,
+	})
+	if err != nil {
+		return
+	}
+	_, err = echoboot.NewBuilder(ctx).
+		With(&client, echo.Config{
+			Service:   "client",
+			Namespace: appNsInst,
+			Subsets:   []echo.SubsetConfig{{}},
+		}).
+		With(&server, echo.Config{
+			Service:   "server",
+			Namespace: appNsInst,
+			Subsets:   []echo.SubsetConfig{{}},
+			Ports: []echo.Port{
+				{
+					Name:         "http",
+					Protocol:     protocol.HTTP,
+					InstancePort: 8090,
+				},
+			},
+		}).
+		Build()
+	if err != nil {
+		return
+	}
+	promInst, err = prometheus.New(ctx, prometheus.Config{})
+	return
+}
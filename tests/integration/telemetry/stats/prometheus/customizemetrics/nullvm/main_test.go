@@ -0,0 +1,42 @@
+// +build integ
+//  Copyright Istio Authors
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package nullvm
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/test/framework/components/statsoverride"
+	"istio.io/istio/tests/integration/telemetry/stats/prometheus/customizemetrics"
+)
+
+// overrides add a dimension to and remove one from the inbound metrics, and drop an outbound metric.
+var overrides = statsoverride.Overrides{
+	Inbound: []statsoverride.Override{
+		{Metric: "requests_total", Dimensions: map[string]string{"request_host": "request.host"}},
+		{TagsToRemove: []string{"request_protocol"}},
+	},
+	Outbound: []statsoverride.Override{
+		{Metric: "request_bytes", Drop: true},
+	},
+}
+
+func TestCustomizeMetrics(t *testing.T) {
+	customizemetrics.TestCustomizeMetrics(t, overrides)
+}
+
+func TestMain(m *testing.M) {
+	customizemetrics.Run(m, overrides)
+}
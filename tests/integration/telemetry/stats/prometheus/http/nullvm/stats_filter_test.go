@@ -34,6 +34,11 @@ func TestStatsFilter(t *testing.T) {
 	common.TestStatsFilter(t, features.Feature("observability.telemetry.stats.prometheus.http.nullvm"))
 }
 
+// TestCardinality verifies the series emitted for standard traffic stay within their cardinality budget.
+func TestCardinality(t *testing.T) {
+	common.TestCardinality(t, features.Feature("observability.telemetry.stats.prometheus.http.nullvm"))
+}
+
 func TestMain(m *testing.M) {
 	framework.NewSuite(m).
 		RequireSingleCluster().
@@ -68,11 +68,11 @@ func TestStatsFilter(t *testing.T, feature features.Feature) {
 					return err
 				}
 				// Query client side metrics
-				if err := promUtil.QueryPrometheus(t, sourceQuery, GetPromInstance()); err != nil {
+				if err := promUtil.QueryPrometheus(ctx, sourceQuery, GetPromInstance()); err != nil {
 					t.Logf("prometheus values for istio_requests_total: \n%s", util.PromDump(promInst, "istio_requests_total"))
 					return err
 				}
-				if err := promUtil.QueryPrometheus(t, destinationQuery, GetPromInstance()); err != nil {
+				if err := promUtil.QueryPrometheus(ctx, destinationQuery, GetPromInstance()); err != nil {
 					t.Logf("prometheus values for istio_requests_total: \n%s", util.PromDump(promInst, "istio_requests_total"))
 					return err
 				}
@@ -86,6 +86,37 @@ func TestStatsFilter(t *testing.T, feature features.Feature) {
 		})
 }
 
+// cardinalityBudget bounds the series produced by the standard traffic between the client and server. Each label has
+// a single value per side of the calls, other than the buckets of histograms.
+func cardinalityBudget(ns string) prometheus.CardinalityBudget {
+	return prometheus.CardinalityBudget{
+		Selector:           fmt.Sprintf(`{__name__=~"istio_.*",destination_workload_namespace=%q}`, ns),
+		MaxSeries:          500,
+		MaxSeriesPerMetric: 100,
+		MaxLabelValues:     4,
+		LabelValues:        map[string]int{"le": 25},
+	}
+}
+
+// TestCardinality sends standard traffic and fails if the series it produces exceed the cardinality budget, catching
+// regressions adding labels or unbounded label values to the metrics of the stats filter.
+func TestCardinality(t *testing.T, feature features.Feature) {
+	framework.NewTest(t).
+		Features(feature).
+		Run(func(ctx framework.TestContext) {
+			start := time.Now()
+			_, destinationQuery, _ := buildQuery()
+			retry.UntilSuccessOrFail(ctx, func() error {
+				if err := SendTraffic(); err != nil {
+					return err
+				}
+				return promUtil.QueryPrometheus(ctx, destinationQuery, GetPromInstance())
+			}, retry.Delay(3*time.Second), retry.Timeout(80*time.Second))
+			c := prometheus.CheckCardinalityOrFail(ctx, GetPromInstance(), cardinalityBudget(GetAppNamespace().Name()), start)
+			ctx.Logf("series of the standard traffic:\n%s", c)
+		})
+}
+
 // TestSetup set up bookinfo app for stats testing.
 func TestSetup(ctx resource.Context) (err error) {
 	appNsInst, err = namespace.New(ctx, namespace.Config{
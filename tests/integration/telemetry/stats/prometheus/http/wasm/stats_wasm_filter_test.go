@@ -35,6 +35,11 @@ func TestWasmStatsFilter(t *testing.T) {
 	common.TestStatsFilter(t, features.Feature("observability.telemetry.stats.prometheus.http.wasm"))
 }
 
+// TestWasmCardinality verifies the series emitted for standard traffic stay within their cardinality budget.
+func TestWasmCardinality(t *testing.T) {
+	common.TestCardinality(t, features.Feature("observability.telemetry.stats.prometheus.http.wasm"))
+}
+
 func TestMain(m *testing.M) {
 	framework.NewSuite(m).
 		RequireSingleCluster().
@@ -86,7 +86,7 @@ func TestTcpMetric(t *testing.T) { // nolint:interfacer
 			retry.UntilSuccessOrFail(t, func() error {
 				util.SendTraffic(ing, t, "Sending traffic", url, "", 200)
 				// TODO(gargnupur): Use TCP metrics like in Telemetry V1 (https://github.com/istio/istio/issues/20283)
-				if err := util_prometheus.QueryPrometheus(t, destinationQuery, prom); err != nil {
+				if err := util_prometheus.QueryPrometheus(ctx, destinationQuery, prom); err != nil {
 					t.Logf("prometheus values for istio_tcp_connections_opened_total: \n%s", util.PromDump(prom, "istio_tcp_connections_opened_total"))
 					return err
 				}
@@ -21,12 +21,13 @@ import (
 	"testing"
 	"time"
 
+	"istio.io/istio/pkg/test/framework"
 	"istio.io/istio/pkg/test/framework/components/prometheus"
 	"istio.io/istio/pkg/test/util/retry"
 )
 
 // QueryPrometheus queries prometheus and returns the result once the query stabilizes
-func QueryPrometheus(t *testing.T, query string, promInst prometheus.Instance) error {
+func QueryPrometheus(t framework.TestContext, query string, promInst prometheus.Instance) error {
 	t.Logf("query prometheus with: %v", query)
 	val, err := promInst.WaitForQuiesce(query)
 	if err != nil {
@@ -0,0 +1,141 @@
+// +build integ
+// Copyright Istio Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package propagation
+
+import (
+	"testing"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/echo/common"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/check"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/label"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+var (
+	ist            istio.Instance
+	client, server echo.Instance
+)
+
+// customFormat is a trace context of the application, which proxies neither read nor write.
+var customFormat = common.CustomTraceFormat("X-App-Trace-Id")
+
+// TestPropagation verifies which trace context the proxies, using the zipkin tracer, propagate from the client and
+// which they generate, for each format the client sends.
+func TestPropagation(t *testing.T) {
+	cases := []struct {
+		name   string
+		sent   []common.TraceFormat
+		checks []check.ResponseChecker
+	}{
+		{
+			name:   "none",
+			checks: []check.ResponseChecker{check.TraceGenerated(common.B3), check.NoTraceContext(common.W3C)},
+		},
+		{
+			name:   "b3",
+			sent:   []common.TraceFormat{common.B3},
+			checks: []check.ResponseChecker{check.TracePropagated(common.B3)},
+		},
+		{
+			// The single header is read, and the trace continued with multiple headers.
+			name:   "b3-single",
+			sent:   []common.TraceFormat{common.B3Single},
+			checks: []check.ResponseChecker{check.TracePropagated(common.B3)},
+		},
+		{
+			// The zipkin tracer does not read W3C trace context, which reaches the server unchanged alongside the
+			// trace the proxy started.
+			name:   "w3c",
+			sent:   []common.TraceFormat{common.W3C},
+			checks: []check.ResponseChecker{check.TraceGenerated(common.B3), check.TracePropagated(common.W3C)},
+		},
+		{
+			name:   "b3-and-w3c",
+			sent:   []common.TraceFormat{common.B3, common.W3C},
+			checks: []check.ResponseChecker{check.TracePropagated(common.B3), check.TracePropagated(common.W3C)},
+		},
+		{
+			name:   "custom",
+			sent:   []common.TraceFormat{customFormat},
+			checks: []check.ResponseChecker{check.TraceGenerated(common.B3), check.TracePropagated(customFormat)},
+		},
+	}
+	framework.NewTest(t).
+		Features("observability.telemetry.tracing.client").
+		Run(func(ctx framework.TestContext) {
+			for _, c := range cases {
+				c := c
+				ctx.NewSubTest(c.name).Run(func(ctx framework.TestContext) {
+					check.CallOrFail(ctx, client, echo.CallOptions{
+						Target:       server,
+						PortName:     "http",
+						TraceContext: c.sent,
+					}, check.And(check.OK(), check.Each(c.checks...)))
+				})
+			}
+		})
+}
+
+func TestMain(m *testing.M) {
+	framework.NewSuite(m).
+		RequireSingleCluster().
+		Label(label.CustomSetup).
+		Setup(istio.Setup(&ist, setupConfig)).
+		Setup(testSetup).
+		Run()
+}
+
+func setupConfig(_ resource.Context, cfg *istio.Config) {
+	if cfg == nil {
+		return
+	}
+	cfg.Values["meshConfig.enableTracing"] = "true"
+	cfg.Values["pilot.traceSampling"] = "100.0"
+}
+
+func testSetup(ctx resource.Context) error {
+	ns, err := namespace.New(ctx, namespace.Config{
+		Prefix: "propagation",
+		Inject: true,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = echoboot.NewBuilder(ctx).
+		With(&client, echo.Config{
+			Service:   "client",
+			Namespace: ns,
+			Subsets:   []echo.SubsetConfig{{}},
+		}).
+		With(&server, echo.Config{
+			Service:   "server",
+			Namespace: ns,
+			Subsets:   []echo.SubsetConfig{{}},
+			Ports: []echo.Port{{
+				Name:         "http",
+				Protocol:     protocol.HTTP,
+				InstancePort: 8090,
+			}},
+		}).
+		Build()
+	return err
+}
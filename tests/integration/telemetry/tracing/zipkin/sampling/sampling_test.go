@@ -0,0 +1,146 @@
+// +build integ
+// Copyright Istio Authors. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sampling
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	kubeApiMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/test/framework"
+	"istio.io/istio/pkg/test/framework/components/echo"
+	"istio.io/istio/pkg/test/framework/components/echo/echoboot"
+	"istio.io/istio/pkg/test/framework/components/istio"
+	"istio.io/istio/pkg/test/framework/components/namespace"
+	"istio.io/istio/pkg/test/framework/components/tracesampling"
+	"istio.io/istio/pkg/test/framework/components/zipkin"
+	"istio.io/istio/pkg/test/framework/label"
+	"istio.io/istio/pkg/test/framework/resource"
+)
+
+const (
+	// meshDefault is the sampling rate Istio is installed with.
+	meshDefault = 25.0
+	// requests sent by each case. At 25%, 4 standard deviations are about 25 requests either way.
+	requests = 200
+
+	telemetryCRD = "telemetries.telemetry.istio.io"
+)
+
+var (
+	ist        istio.Instance
+	zipkinInst zipkin.Instance
+	server     echo.Instance
+)
+
+// TestSampling verifies that the share of requests sampled by proxies, and traced in zipkin, matches the sampling
+// rate, for each way the rate is configured.
+func TestSampling(t *testing.T) {
+	framework.NewTest(t).
+		Features("observability.telemetry.tracing.sampling").
+		Run(func(ctx framework.TestContext) {
+			// A rate of 0 is not set, as proxies fall back to the rate of PILOT_TRACE_SAMPLING for it.
+			for _, c := range tracesampling.Matrix(meshDefault, 10, 100) {
+				c := c
+				ctx.NewSubTest(c.String()).Run(func(ctx framework.TestContext) {
+					client := deployClient(ctx, c)
+					r := tracesampling.RunOrFail(ctx, client, echo.CallOptions{
+						Target:   server,
+						PortName: "http",
+					}, requests, tracesampling.Zipkin(zipkinInst), c.Rate)
+					ctx.Logf("%s: %v", c, r)
+				})
+			}
+		})
+}
+
+// deployClient deploys a client in a namespace of its own, configured with the sampling rate of the case.
+func deployClient(ctx framework.TestContext, c tracesampling.Case) echo.Instance {
+	ns := namespace.NewOrFail(ctx, ctx, namespace.Config{
+		Prefix: "sampling-" + string(c.Source),
+		Inject: true,
+	})
+	subset := echo.SubsetConfig{}
+	switch c.Source {
+	case tracesampling.Workload:
+		subset.Annotations = tracesampling.WorkloadAnnotations(c.Rate)
+	case tracesampling.TelemetryAPI:
+		if _, err := ctx.Clusters().Default().Ext().ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(),
+			telemetryCRD, kubeApiMeta.GetOptions{}); kerrors.IsNotFound(err) {
+			ctx.Skipf("the control plane does not serve the Telemetry API: %v", err)
+		} else if err != nil {
+			ctx.Fatal(err)
+		}
+		ctx.Config().ApplyYAMLOrFail(ctx, ns.Name(), tracesampling.TelemetryYAML(c.Rate))
+	}
+	var client echo.Instance
+	echoboot.NewBuilder(ctx).
+		With(&client, echo.Config{
+			Service:   fmt.Sprintf("client-%v", c.Rate),
+			Namespace: ns,
+			Subsets:   []echo.SubsetConfig{subset},
+		}).
+		BuildOrFail(ctx)
+	// The Telemetry resource may reach the sidecar after it starts, so the run waits for the rate to apply.
+	tracesampling.WaitForRateOrFail(ctx, client, c.Rate)
+	return client
+}
+
+func TestMain(m *testing.M) {
+	framework.NewSuite(m).
+		RequireSingleCluster().
+		Label(label.CustomSetup).
+		Setup(istio.Setup(&ist, setupConfig)).
+		Setup(testSetup).
+		Run()
+}
+
+func setupConfig(_ resource.Context, cfg *istio.Config) {
+	if cfg == nil {
+		return
+	}
+	cfg.ControlPlaneValues = tracesampling.MeshConfigValues(meshDefault)
+}
+
+func testSetup(ctx resource.Context) (err error) {
+	ns, err := namespace.New(ctx, namespace.Config{
+		Prefix: "sampling",
+		Inject: true,
+	})
+	if err != nil {
+		return
+	}
+	if _, err = echoboot.NewBuilder(ctx).
+		With(&server, echo.Config{
+			Service:   "server",
+			Namespace: ns,
+			Subsets:   []echo.SubsetConfig{{}},
+			Ports: []echo.Port{{
+				Name:         "http",
+				Protocol:     protocol.HTTP,
+				InstancePort: 8090,
+			}},
+		}).
+		Build(); err != nil {
+		return
+	}
+	zipkinInst, err = zipkin.New(ctx, zipkin.Config{})
+	return
+}